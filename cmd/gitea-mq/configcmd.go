@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/Mic92/gitea-mq/internal/config"
+)
+
+const configUsage = `usage: gitea-mq config check [-json]`
+
+// ConfigCheck is the JSON result of `gitea-mq config check`.
+type ConfigCheck struct {
+	Valid bool     `json:"valid"`
+	Error string   `json:"error,omitempty"`
+	File  string   `json:"file,omitempty"`
+	Repos []string `json:"repos,omitempty"`
+}
+
+// configCmd validates the configuration the service would start with,
+// without connecting anywhere.
+func configCmd(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New(configUsage)
+	}
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New(configUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		if *asJSON {
+			if perr := printJSON(ConfigCheck{Error: err.Error()}); perr != nil {
+				return perr
+			}
+		}
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	res := ConfigCheck{Valid: true, File: cfg.ConfigFile}
+	for _, ref := range cfg.Repos() {
+		res.Repos = append(res.Repos, ref.String())
+	}
+	if *asJSON {
+		return printJSON(res)
+	}
+	fmt.Println("config OK")
+	if res.File != "" {
+		fmt.Println("file:", res.File)
+	}
+	for _, r := range res.Repos {
+		fmt.Println("repo:", r)
+	}
+	return nil
+}
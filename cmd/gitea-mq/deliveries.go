@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/admin"
+	"github.com/Mic92/gitea-mq/internal/config"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/Mic92/gitea-mq/internal/webhook"
+)
+
+const deliveriesUsage = `usage: gitea-mq deliveries list [-limit N] [-json]
+       gitea-mq deliveries replay [-dry] [-json] <id>`
+
+// deliveriesCmd inspects and replays recorded webhook deliveries. It talks
+// to the database directly, so it works while the service is down; replays
+// are stored in the inbox for the leader to process.
+func deliveriesCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(deliveriesUsage)
+	}
+	switch args[0] {
+	case "list":
+		return deliveriesList(ctx, args[1:])
+	case "replay":
+		return deliveriesReplay(ctx, args[1:])
+	default:
+		return errors.New(deliveriesUsage)
+	}
+}
+
+func deliveriesList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("deliveries list", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "number of deliveries to show")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	_, svc, done, err := openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ds, err := svc.ListDeliveries(ctx, *limit)
+	if err != nil {
+		return fmt.Errorf("list deliveries: %w", err)
+	}
+	out := make([]admin.Delivery, 0, len(ds))
+	for i := range ds {
+		out = append(out, admin.NewDelivery(&ds[i], false))
+	}
+	if *asJSON {
+		return printJSON(out)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tRECEIVED\tFORGE\tEVENT\tREPO\tSTATE\tATTEMPTS\tERROR")
+	for _, d := range out {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			d.ID, d.ReceivedAt.Local().Format(time.DateTime), d.Forge, d.Event, d.Repo, d.State, d.Attempts, d.LastError)
+	}
+	return tw.Flush()
+}
+
+func deliveriesReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("deliveries replay", flag.ContinueOnError)
+	dry := fs.Bool("dry", false, "only print how the delivery would be routed")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(deliveriesUsage)
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid delivery id %q", fs.Arg(0))
+	}
+
+	cfg, svc, done, err := openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	d, err := svc.GetDelivery(ctx, id)
+	if err != nil {
+		return fmt.Errorf("load delivery: %w", err)
+	}
+	if d == nil {
+		return fmt.Errorf("delivery %d not found", id)
+	}
+
+	inbox := &webhook.Inbox{Queue: svc}
+	if *dry {
+		if inbox.Repos, err = managedRepos(ctx, svc); err != nil {
+			return err
+		}
+		dec, err := inbox.Explain(ctx, d)
+		if err != nil {
+			return fmt.Errorf("route delivery %d: %w", id, err)
+		}
+		if *asJSON {
+			return printJSON(admin.ReplayResult{Dry: true, Decision: &dec})
+		}
+		fmt.Println(dec)
+		return nil
+	}
+
+	host, _ := forge.ParseHost(d.Forge)
+	secret, ok := webhookSecrets(cfg)[host]
+	if !ok {
+		return fmt.Errorf("forge %s is not configured", d.Forge)
+	}
+	status, err := webhook.Replay(ctx, inbox, d, secret)
+	if err != nil {
+		return fmt.Errorf("replay delivery %d: %w", id, err)
+	}
+	if *asJSON {
+		return printJSON(admin.ReplayResult{Status: status})
+	}
+	fmt.Printf("replayed delivery %d: HTTP %d\n", id, status)
+	return nil
+}
+
+// openQueue loads the service configuration and connects to its database.
+func openQueue(ctx context.Context) (*config.Config, *queue.Service, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	pool, err := pg.Connect(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return cfg, queue.NewService(pool), pool.Close, nil
+}
+
+// managedRepos maps the leader's repos for routing. The monitors only carry
+// what routing reads; nothing is acted on.
+func managedRepos(ctx context.Context, svc *queue.Service) (webhook.MapRepoLookup, error) {
+	rows, err := svc.ListManagedRepos(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list managed repos: %w", err)
+	}
+	repos := make(webhook.MapRepoLookup, len(rows))
+	for _, row := range rows {
+		repos[repoRef(row).String()] = &webhook.RepoMonitor{
+			Deps: &monitor.Deps{Queue: svc, Owner: row.Owner, Repo: row.Name, RepoID: row.ID},
+		}
+	}
+	return repos, nil
+}
+
+// webhookSecrets returns the webhook secret of each configured forge server.
+func webhookSecrets(cfg *config.Config) map[forge.Host]string {
+	secrets := map[forge.Host]string{}
+	if cfg.Gitea != nil {
+		secrets[forge.Host{Kind: forge.KindGitea}] = cfg.Gitea.WebhookSecret
+	}
+	for _, gc := range cfg.GiteaInstances {
+		secrets[forge.Host{Kind: forge.KindGitea, Instance: gc.Instance}] = gc.WebhookSecret
+	}
+	if cfg.Forgejo != nil {
+		secrets[forge.Host{Kind: forge.KindForgejo}] = cfg.Forgejo.WebhookSecret
+	}
+	if cfg.Gitlab != nil {
+		secrets[forge.Host{Kind: forge.KindGitlab}] = cfg.Gitlab.WebhookSecret
+	}
+	if cfg.Github != nil {
+		secrets[forge.Host{Kind: forge.KindGithub}] = cfg.Github.WebhookSecret
+	}
+	return secrets
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
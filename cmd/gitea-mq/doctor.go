@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Mic92/gitea-mq/internal/doctor"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+const doctorUsage = `usage: gitea-mq doctor [-json] [<repo>]`
+
+// DoctorReport is the JSON view of one repo in `gitea-mq doctor`.
+type DoctorReport struct {
+	Repo   string        `json:"repo"`
+	OK     bool          `json:"ok"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// DoctorCheck is one diagnostic item; Status is pass, warn or fail.
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// doctorCmd checks every managed repo, or one, for setup problems that would
+// otherwise only surface once a PR is queued. It fails when any check does.
+func doctorCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New(doctorUsage)
+	}
+
+	cfg, svc, done, err := openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	rows, err := svc.ListManagedRepos(ctx)
+	if err != nil {
+		return fmt.Errorf("list managed repos: %w", err)
+	}
+	if fs.NArg() == 1 {
+		row, err := findRepo(rows, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		rows = []pg.Repo{row}
+	}
+	forges, _, _, err := buildForges(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	setup := forge.SetupConfig{ExternalURL: cfg.ExternalURL, BranchPatterns: cfg.BranchPatterns}
+	out := make([]DoctorReport, 0, len(rows))
+	failed := 0
+	for _, row := range rows {
+		ref := repoRef(row)
+		rep := DoctorReport{Repo: ref.String(), Checks: []DoctorCheck{}}
+		f, err := forges.For(ref)
+		if err != nil {
+			rep.Checks = append(rep.Checks, DoctorCheck{Name: "forge", Status: string(forge.DoctorFail),
+				Detail: err.Error(), Fix: "configure the forge or remove the repo"})
+		} else {
+			report := doctor.Run(ctx, f, svc, ref, row.ID, setup)
+			for _, c := range report.Checks {
+				rep.Checks = append(rep.Checks, DoctorCheck{Name: c.Name, Status: string(c.Status), Detail: c.Detail, Fix: c.Fix})
+			}
+			rep.OK = !report.Failed()
+		}
+		if !rep.OK {
+			failed++
+		}
+		out = append(out, rep)
+	}
+
+	if *asJSON {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		for i, rep := range out {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(rep.Repo)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			for _, c := range rep.Checks {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+				if c.Fix != "" {
+					fmt.Fprintf(tw, "  \t\t→ %s\n", c.Fix)
+				}
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repos failed checks", failed, len(out))
+	}
+	return nil
+}
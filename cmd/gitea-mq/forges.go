@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/Mic92/gitea-mq/internal/config"
+	"github.com/Mic92/gitea-mq/internal/discovery"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/forgejo"
+	"github.com/Mic92/gitea-mq/internal/gitea"
+	"github.com/Mic92/gitea-mq/internal/github"
+	"github.com/Mic92/gitea-mq/internal/gitlab"
+)
+
+// buildForges connects every configured forge server and returns the
+// discovery source of each that has one. app is the GitHub App, if any, for
+// the server to sync its webhook config.
+func buildForges(ctx context.Context, cfg *config.Config) (forges *forge.Set, sources []discovery.Source, app *github.App, err error) {
+	forges = forge.NewSet()
+
+	if cfg.Gitea != nil {
+		giteaClient := gitea.NewHTTPClient(cfg.Gitea.URL, cfg.Gitea.Token)
+		giteaClient.SetGitCacheDir(cfg.CacheDir)
+		giteaClient.CleanupGitCache(gitea.DefaultCacheMaxAge)
+		forges.Register(gitea.NewForge(giteaClient, cfg.Gitea.URL))
+		if cfg.Gitea.Topic != "" {
+			sources = append(sources, discovery.Source{
+				Host: forge.Host{Kind: forge.KindGitea},
+				List: gitea.TopicSource(giteaClient, forge.Host{Kind: forge.KindGitea}, cfg.Gitea.Topic),
+			})
+		}
+	}
+
+	for _, gc := range cfg.GiteaInstances {
+		host := forge.Host{Kind: forge.KindGitea, Instance: gc.Instance}
+		client := gitea.NewHTTPClient(gc.URL, gc.Token)
+		client.SetGitCacheDir(filepath.Join(cfg.CacheDir, host.String()))
+		client.CleanupGitCache(gitea.DefaultCacheMaxAge)
+		forges.Register(gitea.NewNamedForge(gc.Instance, client, gc.URL))
+		if gc.Topic != "" {
+			sources = append(sources, discovery.Source{
+				Host: host,
+				List: gitea.TopicSource(client, host, gc.Topic),
+			})
+		}
+	}
+
+	if cfg.Forgejo != nil {
+		forgejoClient := gitea.NewHTTPClient(cfg.Forgejo.URL, cfg.Forgejo.Token)
+		forgejoClient.SetGitCacheDir(filepath.Join(cfg.CacheDir, "forgejo"))
+		forgejoClient.CleanupGitCache(gitea.DefaultCacheMaxAge)
+		features, err := forgejo.Probe(ctx, forgejoClient)
+		if err != nil {
+			slog.Warn("forgejo: version probe failed, assuming minimal features", "err", err)
+		}
+		slog.Info("forgejo features", "version", features.Version,
+			"status_webhook", features.StatusWebhook, "auto_merge_api", features.AutoMergeAPI)
+		forges.Register(forgejo.NewForge(forgejoClient, cfg.Forgejo.URL, features))
+		if cfg.Forgejo.Topic != "" {
+			sources = append(sources, discovery.Source{
+				Host: forge.Host{Kind: forge.KindForgejo},
+				List: gitea.TopicSource(forgejoClient, forge.Host{Kind: forge.KindForgejo}, cfg.Forgejo.Topic),
+			})
+		}
+	}
+
+	if cfg.Gitlab != nil {
+		gitlabClient := gitlab.NewClient(cfg.Gitlab.URL, cfg.Gitlab.Token)
+		gitlabClient.SetGitCacheDir(filepath.Join(cfg.CacheDir, "gitlab"))
+		gitlabClient.CleanupGitCache(gitea.DefaultCacheMaxAge)
+		forges.Register(gitlab.NewForge(gitlabClient, cfg.Gitlab.URL))
+		if cfg.Gitlab.Topic != "" {
+			sources = append(sources, discovery.Source{
+				Host: forge.Host{Kind: forge.KindGitlab},
+				List: gitlab.TopicSource(gitlabClient, cfg.Gitlab.Topic),
+			})
+		}
+	}
+
+	if cfg.Github != nil && cfg.Github.Token != "" {
+		tc, err := github.NewTokenClient(cfg.Github.Token, githubEndpoints(cfg.Github))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("init github token client: %w", err)
+		}
+		forges.Register(github.NewTokenForge(tc))
+		if cfg.Github.Topic != "" {
+			sources = append(sources, discovery.Source{
+				Host: forge.Host{Kind: forge.KindGithub},
+				List: github.TopicSource(tc, cfg.Github.Topic),
+			})
+		}
+	} else if cfg.Github != nil {
+		app, err = github.NewApp(cfg.Github.AppID, cfg.Github.PrivateKey, githubEndpoints(cfg.Github))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("init github app: %w", err)
+		}
+		// Populate the installation→repo map before any forge call so the
+		// initial registry.Add for explicit repos can resolve a client.
+		if err := app.Refresh(ctx); err != nil {
+			slog.Warn("github: initial installation refresh failed", "err", err)
+		}
+		forges.Register(github.NewForge(app))
+		sources = append(sources, discovery.Source{
+			Host: forge.Host{Kind: forge.KindGithub},
+			List: github.InstallationSource(app),
+		})
+	}
+	return forges, sources, app, nil
+}
+
+// githubEndpoints resolves the configured GitHub deployment URLs; unset ones
+// follow github.com or the GHES layout below GITEA_MQ_GITHUB_URL.
+func githubEndpoints(gc *config.GithubConfig) github.Endpoints {
+	return github.Endpoints{
+		Web:     gc.URL,
+		API:     gc.APIURL,
+		Upload:  gc.UploadURL,
+		GraphQL: gc.GraphQLURL,
+	}.WithDefaults()
+}
@@ -5,17 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/Mic92/gitea-mq/internal/admin"
+	"github.com/Mic92/gitea-mq/internal/auth"
 	"github.com/Mic92/gitea-mq/internal/config"
 	"github.com/Mic92/gitea-mq/internal/discovery"
-	"github.com/Mic92/gitea-mq/internal/forge"
-	"github.com/Mic92/gitea-mq/internal/gitea"
-	"github.com/Mic92/gitea-mq/internal/github"
+	"github.com/Mic92/gitea-mq/internal/leader"
+	"github.com/Mic92/gitea-mq/internal/notify"
 	"github.com/Mic92/gitea-mq/internal/queue"
 	"github.com/Mic92/gitea-mq/internal/registry"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
@@ -24,12 +26,43 @@ import (
 )
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	if len(os.Args) > 1 {
+		err = runCommand(os.Args[1:])
+	} else {
+		err = run()
+	}
+	if err != nil {
 		slog.Error("fatal", "error", err)
 		os.Exit(1)
 	}
 }
 
+// runCommand runs an operator subcommand instead of the service.
+func runCommand(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "status":
+		return statusCmd(ctx, args[1:])
+	case "doctor":
+		return doctorCmd(ctx, args[1:])
+	case "dequeue":
+		return dequeueCmd(ctx, args[1:])
+	case "cleanup-branches":
+		return cleanupBranchesCmd(ctx, args[1:])
+	case "migrate":
+		return migrateCmd(ctx, args[1:])
+	case "config":
+		return configCmd(args[1:])
+	case "deliveries":
+		return deliveriesCmd(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (available: status, doctor, dequeue, cleanup-branches, migrate, config, deliveries)", args[0])
+	}
+}
+
 func slogLevel(level string) slog.Level {
 	switch level {
 	case "debug":
@@ -49,8 +82,10 @@ func run() error {
 		return fmt.Errorf("load config: %w", err)
 	}
 
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(slogLevel(cfg.LogLevel))
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: slogLevel(cfg.LogLevel),
+		Level: logLevel,
 	})))
 
 	slog.Info(
@@ -58,6 +93,7 @@ func run() error {
 		"listen", cfg.ListenAddr,
 		"repos", cfg.Repos(),
 		"gitea", cfg.Gitea != nil,
+		"forgejo", cfg.Forgejo != nil,
 		"github", cfg.Github != nil,
 		"poll_interval", cfg.PollInterval,
 		"idle_poll_interval", cfg.IdlePollInterval,
@@ -77,49 +113,29 @@ func run() error {
 	defer pool.Close()
 
 	queueSvc := queue.NewService(pool)
-	forges := forge.NewSet()
-	var discSources []discovery.Source
-
-	var giteaWebhookSecret string
-	if cfg.Gitea != nil {
-		giteaClient := gitea.NewHTTPClient(cfg.Gitea.URL, cfg.Gitea.Token)
-		giteaClient.SetGitCacheDir(cfg.CacheDir)
-		giteaClient.CleanupGitCache(gitea.DefaultCacheMaxAge)
-		forges.Register(gitea.NewForge(giteaClient, cfg.Gitea.URL))
-		giteaWebhookSecret = cfg.Gitea.WebhookSecret
-		if cfg.Gitea.Topic != "" {
-			discSources = append(discSources, discovery.Source{
-				Kind: forge.KindGitea,
-				List: gitea.TopicSource(giteaClient, cfg.Gitea.Topic),
-			})
-		}
+	forges, discSources, app, err := buildForges(ctx, cfg)
+	if err != nil {
+		return err
 	}
-
-	if cfg.Github != nil {
-		app, err := github.NewApp(cfg.Github.AppID, cfg.Github.PrivateKey, github.DefaultBaseURL)
-		if err != nil {
-			return fmt.Errorf("init github app: %w", err)
-		}
-		// Populate the installation→repo map before any forge call so the
-		// initial registry.Add for explicit repos can resolve a client.
-		if err := app.Refresh(ctx); err != nil {
-			slog.Warn("github: initial installation refresh failed", "err", err)
-		}
+	if app != nil {
 		if err := app.SyncHookConfig(ctx, cfg.ExternalURL, cfg.Github.WebhookSecret); err != nil {
 			slog.Warn("github: sync app webhook config failed", "err", err)
 		}
-		forges.Register(github.NewForge(app, ""))
-		discSources = append(discSources, discovery.Source{
-			Kind: forge.KindGithub,
-			List: github.InstallationSource(app),
-		})
+	}
+
+	notifier, err := newNotifier(cfg, queueSvc)
+	if err != nil {
+		return err
 	}
 
 	// Create the repo registry — central coordination for managed repos.
 	reg := registry.New(ctx, &registry.Deps{
 		Forges:              forges,
 		Queue:               queueSvc,
-		WebhookSecret:       giteaWebhookSecret,
+		WebhookSecrets:      webhookSecrets(cfg),
+		BranchPatterns:      cfg.BranchPatterns,
+		CreateProtection:    cfg.CreateProtection,
+		TeardownOnRemove:    cfg.TeardownOnRemove,
 		ExternalURL:         cfg.ExternalURL,
 		PollInterval:        cfg.PollInterval,
 		IdlePollInterval:    cfg.IdlePollInterval,
@@ -129,6 +145,9 @@ func run() error {
 		SkipQueueIfUpToDate: cfg.SkipQueueIfUpToDate,
 		BatchMax:            cfg.BatchMax,
 		BisectMaxSteps:      cfg.BisectMaxSteps,
+		Notifier:            notifier,
+		ShadowRepos:         cfg.ShadowRepos,
+		Standby:             true,
 	})
 
 	discTrigger := make(chan struct{}, 1)
@@ -138,18 +157,47 @@ func run() error {
 		ExplicitRepos: cfg.Repos(),
 		Trigger:       discTrigger,
 	}
-	// Initial discovery blocks startup so the dashboard and webhook see the
-	// full repo set immediately. Explicit repos are added via the same path.
-	discovery.DiscoverOnce(ctx, discDeps)
-	if len(discSources) > 0 {
-		go discovery.Run(ctx, discDeps, cfg.DiscoveryInterval)
+	triggerDiscovery := func() {
+		select {
+		case discTrigger <- struct{}{}:
+		default:
+		}
 	}
 
+	// Every replica stores verified webhook deliveries; the leader processes
+	// them.
+	inbox := &webhook.Inbox{
+		Queue:            queueSvc,
+		Repos:            reg,
+		Retention:        cfg.WebhookRetention,
+		TriggerDiscovery: triggerDiscovery,
+	}
+
+	// Only one replica leads at a time; the others stand by with a mirror of
+	// the leader's repos.
+	elector := &leader.Elector{Queue: queueSvc, Interval: cfg.LeaderCheckInterval}
+	if err := reg.Sync(ctx); err != nil {
+		slog.Warn("failed to load managed repos", "error", err)
+	}
+	go reg.Follow(ctx, cfg.LeaderCheckInterval)
+	go elector.Run(ctx, func(term context.Context) {
+		reg.Activate(term)
+		defer reg.Deactivate()
+		// Explicit repos are added via the same path as discovered ones. The
+		// loop runs even without sources so a config reload can change them.
+		discovery.DiscoverOnce(term, discDeps)
+		go discovery.Run(term, discDeps, cfg.DiscoveryInterval)
+		if notifier != nil {
+			go notifier.Run(term)
+		}
+		inbox.Run(term)
+	})
+
 	// HTTP server: webhook + dashboard on the same mux.
 	mux := http.NewServeMux()
 
 	if cfg.Gitea != nil {
-		h := webhook.Handler(giteaWebhookSecret, reg, queueSvc)
+		h := webhook.Handler(cfg.Gitea.WebhookSecret, inbox)
 		mux.Handle("/webhook/gitea", h)
 		// Legacy alias kept so existing per-repo webhooks created by earlier
 		// versions keep working.
@@ -157,16 +205,26 @@ func run() error {
 			mux.Handle(cfg.WebhookPath, h)
 		}
 	}
+	for _, gc := range cfg.GiteaInstances {
+		mux.Handle("/webhook/gitea/"+gc.Instance, webhook.GiteaInstanceHandler(gc.Instance, gc.WebhookSecret, inbox))
+	}
+	if cfg.Forgejo != nil {
+		mux.Handle("/webhook/forgejo", webhook.ForgejoHandler(cfg.Forgejo.WebhookSecret, inbox))
+	}
+	if cfg.Gitlab != nil {
+		mux.Handle("/webhook/gitlab", webhook.GitlabHandler(cfg.Gitlab.WebhookSecret, inbox))
+	}
 	if cfg.Github != nil {
-		mux.Handle("/webhook/github", webhook.GithubHandler(
-			[]byte(cfg.Github.WebhookSecret), reg, queueSvc,
-			func() {
-				select {
-				case discTrigger <- struct{}{}:
-				default:
-				}
-			},
-		))
+		mux.Handle("/webhook/github", webhook.GithubHandler([]byte(cfg.Github.WebhookSecret), inbox))
+	}
+
+	if cfg.AdminToken != "" {
+		mux.Handle("/admin/", admin.NewMux(&admin.Deps{
+			Queue:   queueSvc,
+			Inbox:   inbox,
+			Secrets: webhookSecrets(cfg),
+			Token:   cfg.AdminToken,
+		}))
 	}
 
 	// Health check.
@@ -176,18 +234,61 @@ func run() error {
 		_, _ = w.Write([]byte("ok\n"))
 	})
 
-	// Dashboard — uses registry for dynamic repo listing.
+	// Dashboard — uses registry for dynamic repo listing. The hub pushes
+	// database change notifications to open pages.
+	hub := web.NewHub()
+	go hub.Run(ctx, queueSvc)
+	var authn *auth.Authenticator
+	if cfg.Auth != nil {
+		var providers []*auth.Provider
+		if cfg.Auth.GiteaClientID != "" {
+			providers = append(providers, auth.NewGiteaProvider(cfg.Gitea.URL, cfg.Auth.GiteaClientID, cfg.Auth.GiteaClientSecret))
+		}
+		if cfg.Auth.GithubClientID != "" {
+			ep := githubEndpoints(cfg.Github)
+			providers = append(providers, auth.NewGithubProvider(ep.Web, ep.API, cfg.Auth.GithubClientID, cfg.Auth.GithubClientSecret))
+		}
+		authn = auth.New(auth.Config{
+			Providers:   providers,
+			Queue:       queueSvc,
+			Forges:      forges,
+			ExternalURL: cfg.ExternalURL,
+			SessionKey:  cfg.Auth.SessionKey,
+			CacheTTL:    cfg.Auth.CacheTTL,
+		})
+	}
 	webDeps := &web.Deps{
 		Queue:           queueSvc,
 		Repos:           reg,
 		Forges:          forges,
 		FallbackChecks:  cfg.RequiredChecks,
 		RefreshInterval: int(cfg.RefreshInterval.Seconds()),
+		BatchMax:        cfg.BatchMax,
+		Events:          hub,
+		Auth:            authn,
+		ExternalURL:     cfg.ExternalURL,
+		BranchPatterns:  cfg.BranchPatterns,
+		ShadowRepos:     cfg.ShadowRepos,
+		AdminToken:      cfg.AdminToken,
 	}
 	dashMux := web.NewMux(webDeps)
-	// Mount dashboard routes — the web mux handles /, /repo/, /static/.
+
+	rl := &reloader{
+		boot:             cfg,
+		reg:              reg,
+		discovery:        discDeps,
+		web:              webDeps,
+		logLevel:         logLevel,
+		triggerDiscovery: triggerDiscovery,
+	}
+	go rl.run(ctx)
+	// Mount dashboard routes — the web mux handles /, /repo/, /static/,
+	// /events, /badge/ and /auth/.
 	mux.Handle("/static/", dashMux)
 	mux.Handle("/repo/", dashMux)
+	mux.Handle("/events", dashMux)
+	mux.Handle("/badge/", dashMux)
+	mux.Handle("/auth/", dashMux)
 	// Root must be last to avoid overriding other routes.
 	mux.Handle("/", dashMux)
 
@@ -195,6 +296,9 @@ func run() error {
 		Addr:              cfg.ListenAddr,
 		Handler:           mux,
 		ReadHeaderTimeout: 10 * time.Second,
+		// Derive request contexts from ctx so open event streams end on
+		// shutdown instead of holding up server.Shutdown.
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	// Start HTTP server.
@@ -229,3 +333,28 @@ func run() error {
 
 	return nil
 }
+
+// newNotifier returns the mail notifier configured by cfg, or nil without
+// SMTP settings.
+func newNotifier(cfg *config.Config, svc *queue.Service) (*notify.Notifier, error) {
+	if cfg.SMTP == nil {
+		return nil, nil
+	}
+	n, err := notify.New(notify.Config{
+		SMTP: notify.SMTP{
+			Addr:     cfg.SMTP.Addr,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+		},
+		ExternalURL:    cfg.ExternalURL,
+		NotifyAuthors:  cfg.SMTP.NotifyAuthors,
+		Digest:         cfg.SMTP.Digest,
+		DigestInterval: cfg.SMTP.DigestInterval,
+		TemplateDir:    cfg.SMTP.TemplateDir,
+	}, svc)
+	if err != nil {
+		return nil, fmt.Errorf("init notifications: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/config"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = `usage: gitea-mq migrate [-json] up|down|status`
+
+// Migration is the JSON view of one schema migration.
+type Migration struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrateCmd applies, rolls back or lists the schema migrations. The service
+// migrates up on start; down is for stepping back before a downgrade.
+func migrateCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	pool, err := pg.Open(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer pool.Close()
+	p, err := pg.Migrations(pool)
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+
+	var results []*goose.MigrationResult
+	switch fs.Arg(0) {
+	case "up":
+		results, err = p.Up(ctx)
+	case "down":
+		var r *goose.MigrationResult
+		if r, err = p.Down(ctx); r != nil {
+			results = append(results, r)
+		}
+	case "status":
+		return migrateStatus(ctx, p, *asJSON)
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", fs.Arg(0), err)
+	}
+
+	if *asJSON {
+		out := make([]Migration, 0, len(results))
+		for _, r := range results {
+			out = append(out, Migration{Version: r.Source.Version, Name: r.Source.Path, Applied: r.Direction == "up"})
+		}
+		return printJSON(out)
+	}
+	if len(results) == 0 {
+		fmt.Println("no migrations to run")
+	}
+	for _, r := range results {
+		fmt.Println(r)
+	}
+	return nil
+}
+
+func migrateStatus(ctx context.Context, p *goose.Provider, asJSON bool) error {
+	status, err := p.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("migration status: %w", err)
+	}
+	out := make([]Migration, 0, len(status))
+	for _, s := range status {
+		m := Migration{Version: s.Source.Version, Name: s.Source.Path, Applied: s.State == goose.StateApplied}
+		if m.Applied {
+			at := s.AppliedAt
+			m.AppliedAt = &at
+		}
+		out = append(out, m)
+	}
+	if asJSON {
+		return printJSON(out)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, m := range out {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	return tw.Flush()
+}
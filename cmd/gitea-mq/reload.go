@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/config"
+	"github.com/Mic92/gitea-mq/internal/discovery"
+	"github.com/Mic92/gitea-mq/internal/registry"
+	"github.com/Mic92/gitea-mq/internal/web"
+)
+
+// configWatchInterval is how often the config file's mtime is checked.
+// Polling survives editors that replace the file and symlink swaps that
+// inotify-based watchers miss.
+const configWatchInterval = 5 * time.Second
+
+// reloader re-reads the configuration on SIGHUP or when the config file
+// changes and applies what can change at runtime: repo lists, intervals,
+// timeouts, required checks and the log level. An invalid config is logged
+// and the previous one kept.
+type reloader struct {
+	// boot is the config the process started with. Restart-only settings
+	// are compared against it, so the warning repeats until a restart.
+	boot             *config.Config
+	reg              *registry.RepoRegistry
+	discovery        *discovery.Deps
+	web              *web.Deps
+	logLevel         *slog.LevelVar
+	triggerDiscovery func()
+}
+
+func (r *reloader) run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var watch <-chan time.Time
+	var lastMod time.Time
+	if r.boot.ConfigFile != "" {
+		lastMod = modTime(r.boot.ConfigFile)
+		ticker := time.NewTicker(configWatchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP received, reloading config")
+			r.reload()
+		case <-watch:
+			mod := modTime(r.boot.ConfigFile)
+			if mod.IsZero() || mod.Equal(lastMod) {
+				continue
+			}
+			lastMod = mod
+			slog.Info("config file changed, reloading", "path", r.boot.ConfigFile)
+			r.reload()
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+func (r *reloader) reload() {
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("config reload failed, keeping previous config", "error", err)
+		return
+	}
+	if fields := config.RestartRequired(r.boot, cfg); len(fields) > 0 {
+		slog.Warn("config changes take effect only after a restart", "fields", fields)
+	}
+
+	r.logLevel.Set(slogLevel(cfg.LogLevel))
+	applied := r.reg.Retune(registry.Tuning{
+		PollInterval:        cfg.PollInterval,
+		IdlePollInterval:    cfg.IdlePollInterval,
+		CheckTimeout:        cfg.CheckTimeout,
+		FallbackChecks:      cfg.RequiredChecks,
+		SkipQueueIfUpToDate: cfg.SkipQueueIfUpToDate,
+		BatchMax:            cfg.BatchMax,
+		BisectMaxSteps:      cfg.BisectMaxSteps,
+	})
+	r.web.Retune(applied.FallbackChecks, applied.BatchMax)
+	r.discovery.SetExplicitRepos(cfg.Repos())
+	r.triggerDiscovery()
+
+	slog.Info("config reloaded", "repos", cfg.Repos(), "poll_interval", cfg.PollInterval,
+		"check_timeout", cfg.CheckTimeout, "batch_max", applied.BatchMax)
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/batch"
+	"github.com/Mic92/gitea-mq/internal/config"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/merge"
+	"github.com/Mic92/gitea-mq/internal/notify"
+	"github.com/Mic92/gitea-mq/internal/poller"
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/registry"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+const (
+	statusUsage          = `usage: gitea-mq status [-json] [<repo>]`
+	dequeueUsage         = `usage: gitea-mq dequeue [-reason TEXT] [-json] <repo> <pr>`
+	cleanupBranchesUsage = `usage: gitea-mq cleanup-branches [-json] <repo>
+
+Refuses to run while a gitea-mq leader is up: the leader may be creating a
+merge branch it has not recorded yet. It holds the leader lock while it runs,
+so a service starting meanwhile waits as a standby.`
+)
+
+// RepoStatus is the JSON view of a repo's queues in `gitea-mq status`.
+type RepoStatus struct {
+	Repo    string        `json:"repo"`
+	Entries []EntryStatus `json:"entries"`
+	Batches []BatchStatus `json:"batches"`
+}
+
+// EntryStatus is one queued PR.
+type EntryStatus struct {
+	PR           int64     `json:"pr"`
+	TargetBranch string    `json:"target_branch"`
+	State        string    `json:"state"`
+	HeadSHA      string    `json:"head_sha"`
+	EnqueuedAt   time.Time `json:"enqueued_at"`
+	Batch        int64     `json:"batch,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// BatchStatus is one live batch; PR lists are by PR number.
+type BatchStatus struct {
+	ID           int64   `json:"id"`
+	TargetBranch string  `json:"target_branch"`
+	State        string  `json:"state"`
+	Branch       string  `json:"branch,omitempty"`
+	Testing      []int64 `json:"testing"`
+	Landed       []int64 `json:"landed"`
+	Ejected      []int64 `json:"ejected"`
+	Builds       int32   `json:"builds"`
+}
+
+// statusCmd prints the queues and live batches of every managed repo, or of
+// one.
+func statusCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New(statusUsage)
+	}
+
+	_, svc, done, err := openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	rows, err := svc.ListManagedRepos(ctx)
+	if err != nil {
+		return fmt.Errorf("list managed repos: %w", err)
+	}
+	if fs.NArg() == 1 {
+		row, err := findRepo(rows, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		rows = []pg.Repo{row}
+	}
+
+	out := make([]RepoStatus, 0, len(rows))
+	for _, row := range rows {
+		rs, err := repoStatus(ctx, svc, row)
+		if err != nil {
+			return err
+		}
+		out = append(out, rs)
+	}
+	if *asJSON {
+		return printJSON(out)
+	}
+
+	for i, rs := range out {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(rs.Repo)
+		if len(rs.Entries) == 0 {
+			fmt.Println("  queue empty")
+			continue
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  PR\tBRANCH\tSTATE\tHEAD\tENQUEUED\tBATCH\tERROR")
+		for _, e := range rs.Entries {
+			batchID := "-"
+			if e.Batch != 0 {
+				batchID = strconv.FormatInt(e.Batch, 10)
+			}
+			fmt.Fprintf(tw, "  #%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				e.PR, e.TargetBranch, e.State, shortSHA(e.HeadSHA), e.EnqueuedAt.Local().Format(time.DateTime), batchID, e.Error)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		for _, b := range rs.Batches {
+			fmt.Printf("  batch %d on %s: %s, build %d, testing %s, landed %s, ejected %s\n",
+				b.ID, b.TargetBranch, b.State, b.Builds, prList(b.Testing), prList(b.Landed), prList(b.Ejected))
+		}
+	}
+	return nil
+}
+
+func repoStatus(ctx context.Context, svc *queue.Service, row pg.Repo) (RepoStatus, error) {
+	rs := RepoStatus{Repo: repoRef(row).String(), Entries: []EntryStatus{}, Batches: []BatchStatus{}}
+	entries, err := svc.ListActiveEntries(ctx, row.ID)
+	if err != nil {
+		return rs, fmt.Errorf("%s: list entries: %w", rs.Repo, err)
+	}
+	for _, e := range entries {
+		rs.Entries = append(rs.Entries, EntryStatus{
+			PR:           e.PrNumber,
+			TargetBranch: e.TargetBranch,
+			State:        string(e.State),
+			HeadSHA:      e.PrHeadSha,
+			EnqueuedAt:   e.EnqueuedAt.Time,
+			Batch:        e.ActiveBatchID.Int64,
+			Error:        e.ErrorMessage.String,
+		})
+	}
+
+	batches, err := svc.ListLiveBatches(ctx, row.ID)
+	if err != nil {
+		return rs, fmt.Errorf("%s: list batches: %w", rs.Repo, err)
+	}
+	for _, b := range batches {
+		prs := func(ids []int64) []int64 {
+			out := []int64{}
+			members, err := svc.GetEntriesByIDs(ctx, ids)
+			if err != nil {
+				return out
+			}
+			for _, m := range members {
+				out = append(out, m.PrNumber)
+			}
+			return out
+		}
+		rs.Batches = append(rs.Batches, BatchStatus{
+			ID:           b.ID,
+			TargetBranch: b.TargetBranch,
+			State:        string(b.State),
+			Branch:       b.BranchName.String,
+			Testing:      prs(b.CurrentIds),
+			Landed:       prs(b.LandedIds),
+			Ejected:      prs(b.EjectedIds),
+			Builds:       b.Builds,
+		})
+	}
+	return rs, nil
+}
+
+// dequeueCmd ejects a PR from its queue with a comment, as the service does
+// when a check fails.
+func dequeueCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dequeue", flag.ContinueOnError)
+	reason := fs.String("reason", "", "reason included in the PR comment")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New(dequeueUsage)
+	}
+	pr, err := strconv.ParseInt(strings.TrimPrefix(fs.Arg(1), "#"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid PR number %q", fs.Arg(1))
+	}
+
+	cfg, svc, done, err := openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ref, row, f, err := repoForge(ctx, cfg, svc, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var notifier *notify.Notifier
+	if !cfg.Shadowed(ref) {
+		if notifier, err = newNotifier(cfg, svc); err != nil {
+			return err
+		}
+	}
+
+	deps := &poller.Deps{
+		Forge:       f,
+		Queue:       svc,
+		RepoID:      row.ID,
+		Owner:       ref.Owner,
+		Repo:        ref.Name,
+		ExternalURL: cfg.ExternalURL,
+		Notifier:    notifier,
+	}
+	if cfg.BatchMax != 1 {
+		deps.Batch = &batch.Engine{
+			Forge:          f,
+			Queue:          svc,
+			Owner:          ref.Owner,
+			Repo:           ref.Name,
+			RepoID:         row.ID,
+			ExternalURL:    cfg.ExternalURL,
+			BatchMax:       cfg.BatchMax,
+			BisectMaxSteps: cfg.BisectMaxSteps,
+			CheckTimeout:   cfg.CheckTimeout,
+			FallbackChecks: cfg.RequiredChecks,
+			Notifier:       notifier,
+		}
+	}
+	if err := poller.Eject(ctx, deps, pr, *reason); err != nil {
+		return fmt.Errorf("dequeue %s#%d: %w", ref, pr, err)
+	}
+	notifier.Flush(ctx)
+	if *asJSON {
+		return printJSON(struct {
+			Repo string `json:"repo"`
+			PR   int64  `json:"pr"`
+		}{ref.String(), pr})
+	}
+	fmt.Printf("removed %s#%d from the merge queue\n", ref, pr)
+	return nil
+}
+
+// cleanupBranchesCmd deletes merge branches no queue entry or live batch
+// refers to, as the service does when it takes over a repo. It takes the
+// leader lock for that, so it never races a live leader creating branches.
+func cleanupBranchesCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cleanup-branches", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(cleanupBranchesUsage)
+	}
+
+	cfg, svc, done, err := openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ref, row, f, err := repoForge(ctx, cfg, svc, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+	var cleanupErr error
+	led, err := svc.Lead(ctx, cfg.LeaderCheckInterval, func(ctx context.Context) {
+		batches, err := svc.ListLiveBatches(ctx, row.ID)
+		if err != nil {
+			cleanupErr = fmt.Errorf("list batches: %w", err)
+			return
+		}
+		var spare []string
+		for _, b := range batches {
+			if b.BranchName.Valid {
+				spare = append(spare, b.BranchName.String)
+			}
+		}
+		deleted, cleanupErr = merge.CleanupStaleBranches(ctx, f, svc, ref.Owner, ref.Name, row.ID, spare)
+	})
+	if err == nil {
+		err = cleanupErr
+	}
+	if err != nil {
+		return fmt.Errorf("cleanup %s: %w", ref, err)
+	}
+	if !led {
+		return errors.New("a gitea-mq leader is running and cleans up stale branches itself; stop it to run cleanup-branches")
+	}
+	if *asJSON {
+		if deleted == nil {
+			deleted = []string{}
+		}
+		return printJSON(struct {
+			Repo    string   `json:"repo"`
+			Deleted []string `json:"deleted"`
+		}{ref.String(), deleted})
+	}
+	for _, b := range deleted {
+		fmt.Println("deleted", b)
+	}
+	fmt.Printf("%s: %d stale branches deleted\n", ref, len(deleted))
+	return nil
+}
+
+// repoForge resolves a managed repo and connects its forge, wrapped as the
+// service wraps it.
+func repoForge(ctx context.Context, cfg *config.Config, svc *queue.Service, arg string) (forge.RepoRef, pg.Repo, forge.Forge, error) {
+	rows, err := svc.ListManagedRepos(ctx)
+	if err != nil {
+		return forge.RepoRef{}, pg.Repo{}, nil, fmt.Errorf("list managed repos: %w", err)
+	}
+	row, err := findRepo(rows, arg)
+	if err != nil {
+		return forge.RepoRef{}, pg.Repo{}, nil, err
+	}
+	ref := repoRef(row)
+	forges, _, _, err := buildForges(ctx, cfg)
+	if err != nil {
+		return forge.RepoRef{}, pg.Repo{}, nil, err
+	}
+	f, err := forges.For(ref)
+	if err != nil {
+		return forge.RepoRef{}, pg.Repo{}, nil, err
+	}
+	f = registry.WrapForge(f, svc, row.ID, cfg.Shadowed(ref), cfg.ExternalURL,
+		func() []string { return cfg.RequiredChecks })
+	return ref, row, f, nil
+}
+
+// findRepo picks the managed repo named by arg, either in full
+// ("gitea:org/app") or as owner/name when that is unambiguous.
+func findRepo(rows []pg.Repo, arg string) (pg.Repo, error) {
+	var matches []pg.Repo
+	for _, row := range rows {
+		ref := repoRef(row)
+		if ref.String() == arg {
+			return row, nil
+		}
+		if ref.Owner+"/"+ref.Name == arg {
+			matches = append(matches, row)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return pg.Repo{}, fmt.Errorf("repo %q is not managed", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return pg.Repo{}, fmt.Errorf("repo %q is ambiguous, prefix it with the forge, e.g. %s", arg, repoRef(matches[0]))
+	}
+}
+
+func repoRef(row pg.Repo) forge.RepoRef {
+	return forge.RepoRef{Forge: forge.Kind(row.Forge), Instance: row.Instance, Owner: row.Owner, Name: row.Name}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 10 {
+		return sha[:10]
+	}
+	return sha
+}
+
+func prList(prs []int64) string {
+	if len(prs) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(prs))
+	for i, pr := range prs {
+		parts[i] = "#" + strconv.FormatInt(pr, 10)
+	}
+	return strings.Join(parts, " ")
+}
@@ -3,6 +3,7 @@ module github.com/Mic92/gitea-mq
 go 1.25.7
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/bradleyfalzon/ghinstallation/v2 v2.19.0
 	github.com/google/go-github/v84 v84.0.0
 	github.com/jackc/pgx/v5 v5.10.0
@@ -0,0 +1,210 @@
+// Package admin serves the token-protected operator API for inspecting and
+// replaying recorded webhook deliveries.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/Mic92/gitea-mq/internal/webhook"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// Deps holds what the admin API needs.
+type Deps struct {
+	Queue *queue.Service
+	Inbox *webhook.Inbox
+	// Secrets holds the webhook secret per configured forge; replays are
+	// signed with it so they pass the regular handler.
+	Secrets map[forge.Host]string
+	// Token is the bearer token every request must carry.
+	Token string
+}
+
+// Delivery is the JSON view of a recorded webhook delivery. Headers and
+// Payload are only filled in for a single delivery.
+type Delivery struct {
+	ID          int64           `json:"id"`
+	Forge       string          `json:"forge"`
+	DeliveryID  string          `json:"delivery_id"`
+	Event       string          `json:"event"`
+	Repo        string          `json:"repo,omitempty"`
+	State       string          `json:"state"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty"`
+	Headers     json.RawMessage `json:"headers,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+// NewDelivery converts a stored delivery, with or without its body.
+func NewDelivery(d *pg.WebhookDelivery, full bool) Delivery {
+	out := Delivery{
+		ID:         d.ID,
+		Forge:      d.Forge,
+		DeliveryID: d.DeliveryID,
+		Event:      d.Event,
+		Repo:       d.Repo,
+		State:      string(d.State),
+		Attempts:   d.Attempts,
+		LastError:  d.LastError.String,
+		ReceivedAt: d.ReceivedAt.Time,
+	}
+	if d.ProcessedAt.Valid {
+		out.ProcessedAt = &d.ProcessedAt.Time
+	}
+	if full {
+		out.Headers = json.RawMessage(d.Headers)
+		if json.Valid(d.Payload) {
+			out.Payload = json.RawMessage(d.Payload)
+		}
+	}
+	return out
+}
+
+// ReplayResult reports a replay. A dry run only fills in Decision.
+type ReplayResult struct {
+	Dry      bool              `json:"dry"`
+	Decision *webhook.Decision `json:"decision,omitempty"`
+	Status   int               `json:"status,omitempty"`
+}
+
+// NewMux returns the handler for /admin/.
+func NewMux(deps *Deps) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/deliveries", listHandler(deps))
+	mux.HandleFunc("GET /admin/deliveries/{id}", getHandler(deps))
+	mux.HandleFunc("POST /admin/deliveries/{id}/replay", replayHandler(deps))
+	return requireToken(deps.Token, mux)
+}
+
+// Authorized reports whether r carries token as its bearer token. An empty
+// token authorizes nobody.
+func Authorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Authorized(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gitea-mq admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func listHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultListLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxListLimit)
+		}
+		ds, err := deps.Queue.ListDeliveries(r.Context(), limit)
+		if err != nil {
+			slog.Error("failed to list webhook deliveries", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		out := make([]Delivery, 0, len(ds))
+		for i := range ds {
+			out = append(out, NewDelivery(&ds[i], false))
+		}
+		writeJSON(w, out)
+	}
+}
+
+func getHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, ok := lookup(deps, w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, NewDelivery(d, true))
+	}
+}
+
+func replayHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, ok := lookup(deps, w, r)
+		if !ok {
+			return
+		}
+		dry, _ := strconv.ParseBool(r.URL.Query().Get("dry"))
+
+		if dry {
+			dec, err := deps.Inbox.Explain(r.Context(), d)
+			if err != nil {
+				http.Error(w, "route delivery: "+err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			slog.Info("dry webhook replay", "delivery", d.ID, "decision", dec.String())
+			writeJSON(w, ReplayResult{Dry: true, Decision: &dec})
+			return
+		}
+
+		host, _ := forge.ParseHost(d.Forge)
+		secret, ok := deps.Secrets[host]
+		if !ok {
+			http.Error(w, "forge "+d.Forge+" is not configured", http.StatusConflict)
+			return
+		}
+		status, err := webhook.Replay(r.Context(), deps.Inbox, d, secret)
+		if err != nil {
+			http.Error(w, "replay: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Info("replayed webhook delivery", "delivery", d.ID, "status", status)
+		writeJSON(w, ReplayResult{Status: status})
+	}
+}
+
+// lookup resolves the {id} path value, answering the request itself when it
+// does not name a stored delivery.
+func lookup(deps *Deps, w http.ResponseWriter, r *http.Request) (*pg.WebhookDelivery, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	d, err := deps.Queue.GetDelivery(r.Context(), id)
+	if err != nil {
+		slog.Error("failed to load webhook delivery", "id", id, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if d == nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return d, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		slog.Warn("failed to write admin response", "error", err)
+	}
+}
@@ -0,0 +1,75 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/admin"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/testutil"
+	"github.com/Mic92/gitea-mq/internal/webhook"
+)
+
+const token = "s3cret"
+
+// The API answers only with the token; a dry replay reports the routing
+// decision and leaves the inbox untouched, a real one stores a new delivery.
+func TestAdmin(t *testing.T) {
+	svc, ctx, _ := testutil.TestQueueService(t)
+	h := admin.NewMux(&admin.Deps{
+		Queue:   svc,
+		Inbox:   &webhook.Inbox{Queue: svc, Repos: webhook.MapRepoLookup{}},
+		Secrets: map[forge.Host]string{{Kind: forge.KindGitea}: "hook-secret"},
+		Token:   token,
+	})
+	body := `{"sha":"abc","context":"ci/build","state":"success","repository":{"full_name":"org/app"}}`
+	if _, err := svc.StoreDelivery(ctx, "gitea", "d-1", "status", "gitea:org/app", []byte(`{}`), []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path, auth string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/admin/deliveries", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: code=%d", rec.Code)
+	}
+
+	rec := do(http.MethodGet, "/admin/deliveries", token)
+	var list []admin.Delivery
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("list: code=%d body=%s", rec.Code, rec.Body)
+	}
+	path := fmt.Sprintf("/admin/deliveries/%d", list[0].ID)
+
+	rec = do(http.MethodPost, path+"/replay?dry=1", token)
+	var res admin.ReplayResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || !res.Dry || res.Decision == nil {
+		t.Fatalf("dry replay: code=%d body=%s", rec.Code, rec.Body)
+	}
+	if res.Decision.Action != webhook.ActionIgnore || res.Decision.Reason != "repo not managed" {
+		t.Errorf("decision = %+v", res.Decision)
+	}
+
+	rec = do(http.MethodPost, path+"/replay", token)
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || res.Status != http.StatusOK {
+		t.Fatalf("replay: code=%d body=%s", rec.Code, rec.Body)
+	}
+	if pending, _, _ := svc.InboxStats(ctx); pending != 2 {
+		t.Errorf("pending=%d, want the replay stored next to the original", pending)
+	}
+
+	if rec := do(http.MethodGet, "/admin/deliveries/999999", token); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown delivery: code=%d", rec.Code)
+	}
+}
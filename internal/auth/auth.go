@@ -0,0 +1,349 @@
+// Package auth implements optional dashboard login through the forges' OAuth2
+// providers and answers "may this visitor see that repo?". Logged-in users
+// see the repos their forge account can read; everyone else sees only public
+// repos. Answers are cached for a short TTL so page views do not turn into
+// forge API storms.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+const (
+	sessionCookie = "gitea_mq_session"
+	stateCookie   = "gitea_mq_oauth_state"
+	// sessionTTL matches the lifetime of GitHub's expiring user tokens; a
+	// longer session would only hold a dead token.
+	sessionTTL = 8 * time.Hour
+	// maxCacheEntries bounds the access cache; expired entries are swept
+	// once it grows past this.
+	maxCacheEntries = 4096
+)
+
+// Config configures an Authenticator.
+type Config struct {
+	Providers   []*Provider
+	Queue       *queue.Service // session storage
+	Forges      *forge.Set     // answers visibility for anonymous visitors
+	ExternalURL string         // callback URLs are built from this
+	SessionKey  string         // encrypts stored forge tokens; empty = random per process
+	CacheTTL    time.Duration
+}
+
+// Authenticator owns the login routes and the permission cache.
+type Authenticator struct {
+	providers   map[forge.Kind]*Provider
+	queue       *queue.Service
+	forges      *forge.Set
+	externalURL string
+	sealer      *sealer
+	ttl         time.Duration
+
+	mu    sync.Mutex
+	cache map[accessKey]accessEntry
+}
+
+// accessKey identifies one cached answer. Login is empty for the anonymous
+// (public-only) view; write separates push access from read access.
+type accessKey struct {
+	kind  forge.Kind
+	login string
+	repo  forge.RepoRef
+	write bool
+}
+
+type accessEntry struct {
+	ok      bool
+	expires time.Time
+}
+
+// New creates an Authenticator. CacheTTL defaults to one minute.
+func New(cfg Config) *Authenticator {
+	a := &Authenticator{
+		providers:   make(map[forge.Kind]*Provider, len(cfg.Providers)),
+		queue:       cfg.Queue,
+		forges:      cfg.Forges,
+		externalURL: strings.TrimRight(cfg.ExternalURL, "/"),
+		sealer:      newSealer(cfg.SessionKey),
+		ttl:         cfg.CacheTTL,
+		cache:       make(map[accessKey]accessEntry),
+	}
+	if a.ttl <= 0 {
+		a.ttl = time.Minute
+	}
+	for _, p := range cfg.Providers {
+		a.providers[p.Kind] = p
+	}
+	return a
+}
+
+// Providers returns the forges users can log in with, sorted.
+func (a *Authenticator) Providers() []forge.Kind {
+	out := make([]forge.Kind, 0, len(a.providers))
+	for k := range a.providers {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Session returns the visitor's login session, or nil when anonymous. A
+// session whose token no longer opens, because the session key changed, is
+// treated as anonymous.
+func (a *Authenticator) Session(r *http.Request) *pg.Session {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	sess, err := a.queue.GetSession(r.Context(), hashID(c.Value))
+	if err != nil {
+		slog.Warn("load dashboard session failed", "error", err)
+		return nil
+	}
+	if sess != nil {
+		if _, err := a.sealer.open(sess.ID, sess.SealedToken); err != nil {
+			return nil
+		}
+	}
+	return sess
+}
+
+// CanView reports whether the visitor behind r may see ref. A session for
+// ref's forge is checked with the user's own token; anonymous visitors and
+// users logged in to the other forge only see public repos. Lookup errors
+// deny access.
+func (a *Authenticator) CanView(r *http.Request, ref forge.RepoRef) bool {
+	return a.canView(r.Context(), a.Session(r), ref)
+}
+
+// Filter returns the refs the visitor behind r may see, loading the session
+// once for the whole list.
+func (a *Authenticator) Filter(r *http.Request, refs []forge.RepoRef) []forge.RepoRef {
+	sess := a.Session(r)
+	var out []forge.RepoRef
+	for _, ref := range refs {
+		if a.canView(r.Context(), sess, ref) {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+func (a *Authenticator) canView(ctx context.Context, sess *pg.Session, ref forge.RepoRef) bool {
+	key := accessKey{kind: ref.Forge, repo: ref}
+	p := a.providers[ref.Forge]
+	// Login providers belong to the default server of their kind; repos of
+	// named instances are judged by their visibility alone.
+	if sess != nil && forge.Kind(sess.Forge) == ref.Forge && ref.Instance == "" && p != nil {
+		key.login = sess.Login
+	}
+
+	return a.cached(key, func() (bool, error) {
+		if key.login == "" {
+			return a.public(ctx, ref)
+		}
+		token, err := a.sealer.open(sess.ID, sess.SealedToken)
+		if err != nil {
+			return false, err
+		}
+		return p.CanRead(ctx, token, ref.Owner, ref.Name)
+	})
+}
+
+// CanWrite reports whether the visitor behind r is logged in to ref's forge
+// with push access to ref. Anonymous visitors, users of the other forge and
+// repos of named instances never qualify. Lookup errors deny access.
+func (a *Authenticator) CanWrite(r *http.Request, ref forge.RepoRef) bool {
+	sess := a.Session(r)
+	p := a.providers[ref.Forge]
+	if sess == nil || forge.Kind(sess.Forge) != ref.Forge || ref.Instance != "" || p == nil {
+		return false
+	}
+	key := accessKey{kind: ref.Forge, login: sess.Login, repo: ref, write: true}
+	return a.cached(key, func() (bool, error) {
+		token, err := a.sealer.open(sess.ID, sess.SealedToken)
+		if err != nil {
+			return false, err
+		}
+		return p.CanWrite(r.Context(), token, ref.Owner, ref.Name)
+	})
+}
+
+// cached returns the cached answer for key, asking check on a miss.
+func (a *Authenticator) cached(key accessKey, check func() (bool, error)) bool {
+	now := time.Now()
+	a.mu.Lock()
+	e, hit := a.cache[key]
+	a.mu.Unlock()
+	if hit && now.Before(e.expires) {
+		return e.ok
+	}
+
+	ok, err := check()
+	if err != nil {
+		slog.Warn("repo permission check failed", "repo", key.repo.String(), "login", key.login, "write", key.write, "error", err)
+		return false // not cached: retry on the next view
+	}
+
+	a.mu.Lock()
+	if len(a.cache) >= maxCacheEntries {
+		for k, v := range a.cache {
+			if !now.Before(v.expires) {
+				delete(a.cache, k)
+			}
+		}
+	}
+	a.cache[key] = accessEntry{ok: ok, expires: now.Add(a.ttl)}
+	a.mu.Unlock()
+	return ok
+}
+
+// public asks the forge, with gitea-mq's own credentials, whether ref is
+// public.
+func (a *Authenticator) public(ctx context.Context, ref forge.RepoRef) (bool, error) {
+	if a.forges == nil {
+		return false, nil
+	}
+	f, err := a.forges.For(ref)
+	if err != nil {
+		return false, err
+	}
+	v, ok := f.(forge.RepoVisibility)
+	if !ok {
+		return false, nil
+	}
+	private, err := v.RepoPrivate(ctx, ref.Owner, ref.Name)
+	return !private, err
+}
+
+func (a *Authenticator) callbackURL(k forge.Kind) string {
+	return a.externalURL + "/auth/callback/" + string(k)
+}
+
+func (a *Authenticator) secureCookies() bool {
+	return strings.HasPrefix(a.externalURL, "https://")
+}
+
+// LoginHandler serves GET /auth/login/{forge}: it remembers a random state
+// and where to return to in a short-lived cookie and redirects to the forge.
+func (a *Authenticator) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	p := a.providers[forge.Kind(r.PathValue("forge"))]
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	state := randomToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state + "|" + safeNext(r.URL.Query().Get("next")),
+		Path:     "/auth/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   a.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, p.AuthCodeURL(state, a.callbackURL(p.Kind)), http.StatusFound)
+}
+
+// CallbackHandler serves GET /auth/callback/{forge}: it checks the state,
+// exchanges the code, and starts a session.
+func (a *Authenticator) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	p := a.providers[forge.Kind(r.PathValue("forge"))]
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	c, err := r.Cookie(stateCookie)
+	state, next, _ := strings.Cut(valueOf(c, err), "|")
+	if state == "" || r.URL.Query().Get("state") != state {
+		http.Error(w, "login expired or invalid, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/auth/", MaxAge: -1})
+
+	ctx := r.Context()
+	token, err := p.Exchange(ctx, r.URL.Query().Get("code"), a.callbackURL(p.Kind))
+	if err != nil {
+		slog.Warn("dashboard login failed", "forge", p.Kind, "error", err)
+		http.Error(w, "login failed", http.StatusBadGateway)
+		return
+	}
+	login, err := p.Login(ctx, token)
+	if err != nil {
+		slog.Warn("dashboard login failed", "forge", p.Kind, "error", err)
+		http.Error(w, "login failed", http.StatusBadGateway)
+		return
+	}
+
+	id := randomToken()
+	sealed := a.sealer.seal(hashID(id), token)
+	if err := a.queue.CreateSession(ctx, hashID(id), string(p.Kind), login, sealed, time.Now().Add(sessionTTL)); err != nil {
+		slog.Error("create dashboard session failed", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.Info("dashboard login", "forge", p.Kind, "login", login)
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+// LogoutHandler serves POST /auth/logout.
+func (a *Authenticator) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		if err := a.queue.DeleteSession(r.Context(), hashID(c.Value)); err != nil {
+			slog.Warn("delete dashboard session failed", "error", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func valueOf(c *http.Cookie, err error) string {
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// safeNext only allows local absolute paths as post-login redirect targets,
+// so the login flow cannot be used as an open redirect.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsAny(next, "\\|") {
+		return "/"
+	}
+	return next
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // never fails on supported platforms
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashID derives the stored session ID from the cookie value.
+func hashID(cookie string) string {
+	sum := sha256.Sum256([]byte(cookie))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,125 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/auth"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/testutil"
+)
+
+// login drives /auth/login → forge → /auth/callback and returns the session
+// cookie.
+func login(t *testing.T, a *auth.Authenticator) *http.Cookie {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/login/{forge}", a.LoginHandler)
+	mux.HandleFunc("GET /auth/callback/{forge}", a.CallbackHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login/gitea?next=/repo/gitea/org/secret", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: code=%d", rec.Code)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	state := loc.Query().Get("state")
+	stateCookie := rec.Result().Cookies()[0]
+
+	// A forged state is rejected.
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback/gitea?code=good&state=forged", nil)
+	req.AddCookie(stateCookie)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("forged state: code=%d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/auth/callback/gitea?code=good&state="+url.QueryEscape(state), nil)
+	req.AddCookie(stateCookie)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/repo/gitea/org/secret" {
+		t.Fatalf("callback: code=%d location=%q body=%s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "gitea_mq_session" && c.Value != "" {
+			return c
+		}
+	}
+	t.Fatal("no session cookie")
+	return nil
+}
+
+func TestAuthenticator_LoginAndVisibility(t *testing.T) {
+	svc, _, _ := testutil.TestQueueService(t)
+	srv := fakeOAuth(t)
+
+	mf := &forge.MockForge{
+		KindVal: forge.KindGitea,
+		RepoPrivateFn: func(_ context.Context, _, name string) (bool, error) {
+			return name != "app", nil
+		},
+	}
+	forges := forge.NewSet()
+	forges.Register(mf)
+	a := auth.New(auth.Config{
+		Providers:   []*auth.Provider{auth.NewGiteaProvider(srv.URL, "client", "secret")},
+		Queue:       svc,
+		Forges:      forges,
+		ExternalURL: "https://mq.example.com",
+	})
+
+	app := forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app"}
+	secret := forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "secret"}
+	other := forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "other"}
+	all := []forge.RepoRef{app, secret, other}
+
+	anon := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := a.Filter(anon, all); len(got) != 1 || got[0] != app {
+		t.Errorf("anonymous sees %v, want only public org/app", got)
+	}
+	// Answers are cached: no further forge lookups for the same view.
+	before := len(mf.CallsTo("RepoPrivate"))
+	a.Filter(anon, all)
+	if after := len(mf.CallsTo("RepoPrivate")); after != before {
+		t.Errorf("RepoPrivate calls went from %d to %d; expected cache hits", before, after)
+	}
+
+	cookie := login(t, a)
+	user := httptest.NewRequest(http.MethodGet, "/", nil)
+	user.AddCookie(cookie)
+	if sess := a.Session(user); sess == nil || sess.Login != "alice" {
+		t.Fatalf("session = %+v", sess)
+	}
+	if got := a.Filter(user, all); len(got) != 2 || got[0] != app || got[1] != secret {
+		t.Errorf("alice sees %v, want org/app and org/secret", got)
+	}
+	if !a.CanWrite(user, secret) || a.CanWrite(user, app) {
+		t.Error("alice should have write access to org/secret only")
+	}
+	if a.CanWrite(anon, app) {
+		t.Error("anonymous visitors never have write access")
+	}
+	// Logged in to Gitea says nothing about GitHub: public repos only.
+	gh := forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"}
+	if a.CanView(user, gh) {
+		t.Error("github repo without github forge must be hidden")
+	}
+	// Nor about another Gitea server: its repos are judged by visibility.
+	internal := forge.RepoRef{Forge: forge.KindGitea, Instance: "internal", Owner: "org", Name: "secret"}
+	if a.CanView(user, internal) {
+		t.Error("repo of a named instance must not be opened by a default-instance login")
+	}
+
+	rec := httptest.NewRecorder()
+	logout := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	logout.AddCookie(cookie)
+	a.LogoutHandler(rec, logout)
+	if a.Session(user) != nil {
+		t.Error("session survived logout")
+	}
+}
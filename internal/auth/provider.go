@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+// Provider is one forge's OAuth2 authorization-code endpoint plus the REST
+// calls needed after login. Gitea and GitHub agree on the shape of both
+// (form-encoded token exchange, GET /user, GET /repos/{owner}/{name}), so a
+// provider is just a set of URLs.
+type Provider struct {
+	Kind         forge.Kind
+	ClientID     string
+	ClientSecret string
+	AuthorizeURL string
+	TokenURL     string
+	APIURL       string // REST root: .../api/v1 on Gitea, api.github.com on GitHub
+
+	hc *http.Client
+}
+
+// NewGiteaProvider configures login via a Gitea OAuth2 application.
+// baseURL is the instance root without /api/v1.
+func NewGiteaProvider(baseURL, clientID, clientSecret string) *Provider {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &Provider{
+		Kind:         forge.KindGitea,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthorizeURL: baseURL + "/login/oauth/authorize",
+		TokenURL:     baseURL + "/login/oauth/access_token",
+		APIURL:       baseURL + "/api/v1",
+	}
+}
+
+// NewGithubProvider configures login via the GitHub App's user-to-server
+// OAuth. The resulting token can only see repos the App is installed on and
+// the user can read, which is exactly the set the dashboard may show.
+// Empty URLs default to github.com.
+func NewGithubProvider(webURL, apiURL, clientID, clientSecret string) *Provider {
+	webURL = strings.TrimRight(cmp.Or(webURL, "https://github.com"), "/")
+	return &Provider{
+		Kind:         forge.KindGithub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		AuthorizeURL: webURL + "/login/oauth/authorize",
+		TokenURL:     webURL + "/login/oauth/access_token",
+		APIURL:       strings.TrimRight(cmp.Or(apiURL, "https://api.github.com"), "/"),
+	}
+}
+
+func (p *Provider) client() *http.Client {
+	if p.hc != nil {
+		return p.hc
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// AuthCodeURL returns the forge page asking the user to authorize us.
+func (p *Provider) AuthCodeURL(state, redirectURI string) string {
+	q := url.Values{
+		"client_id":     {p.ClientID},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"state":         {state},
+	}
+	return p.AuthorizeURL + "?" + q.Encode()
+}
+
+// Exchange trades an authorization code for an access token.
+func (p *Provider) Exchange(ctx context.Context, code, redirectURI string) (string, error) {
+	form := url.Values{
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code":          {code},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {redirectURI},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub answers form-encoded unless asked for JSON.
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var tok struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return "", fmt.Errorf("token exchange: HTTP %d: %w", resp.StatusCode, err)
+	}
+	// GitHub reports errors with HTTP 200 and an error field.
+	if tok.Error != "" {
+		return "", fmt.Errorf("token exchange: %s: %s", tok.Error, tok.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || tok.AccessToken == "" {
+		return "", fmt.Errorf("token exchange: HTTP %d without access token", resp.StatusCode)
+	}
+	return tok.AccessToken, nil
+}
+
+// get issues an authenticated GET against the provider's REST API.
+func (p *Provider) get(ctx context.Context, token, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	return p.client().Do(req)
+}
+
+// Login returns the login name the token belongs to.
+func (p *Provider) Login(ctx context.Context, token string) (string, error) {
+	resp, err := p.get(ctx, token, "/user")
+	if err != nil {
+		return "", fmt.Errorf("get user: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get user: HTTP %d", resp.StatusCode)
+	}
+	var u struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		return "", fmt.Errorf("get user: %w", err)
+	}
+	if u.Login == "" {
+		return "", fmt.Errorf("get user: empty login")
+	}
+	return u.Login, nil
+}
+
+// CanRead reports whether the token's user can see owner/name. Both forges
+// answer 404 for repos the user may not read.
+func (p *Provider) CanRead(ctx context.Context, token, owner, name string) (bool, error) {
+	resp, err := p.get(ctx, token, "/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name))
+	if err != nil {
+		return false, fmt.Errorf("get repo: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("get repo %s/%s: HTTP %d", owner, name, resp.StatusCode)
+	}
+}
+
+// CanWrite reports whether the token's user can push to owner/name. Both
+// forges include the caller's permissions in the repo response.
+func (p *Provider) CanWrite(ctx context.Context, token, owner, name string) (bool, error) {
+	resp, err := p.get(ctx, token, "/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name))
+	if err != nil {
+		return false, fmt.Errorf("get repo: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	default:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, fmt.Errorf("get repo %s/%s: HTTP %d", owner, name, resp.StatusCode)
+	}
+	var repo struct {
+		Permissions struct {
+			Admin bool `json:"admin"`
+			Push  bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return false, fmt.Errorf("get repo %s/%s: %w", owner, name, err)
+	}
+	return repo.Permissions.Push || repo.Permissions.Admin, nil
+}
@@ -0,0 +1,113 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/auth"
+)
+
+// fakeOAuth is a minimal Gitea-shaped OAuth2 provider and REST API. Code
+// "good" yields token "tok-alice", who can read org/app and org/secret and
+// push to org/secret.
+func fakeOAuth(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("code") != "good" {
+			// GitHub style: 200 with an error field.
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code", "error_description": "nope"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "tok-alice", "token_type": "bearer"})
+	})
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer tok-alice" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("GET /api/v1/user", authed(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"login":"alice"}`))
+	}))
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{name}", authed(func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("name") {
+		case "app":
+			_, _ = w.Write([]byte(`{"permissions":{"admin":false,"push":false,"pull":true}}`))
+		case "secret":
+			_, _ = w.Write([]byte(`{"permissions":{"admin":false,"push":true,"pull":true}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	p := auth.NewGiteaProvider("https://gitea.example.com/", "client", "secret")
+	u, err := url.Parse(p.AuthCodeURL("st", "https://mq.example.com/auth/callback/gitea"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "gitea.example.com" || u.Path != "/login/oauth/authorize" {
+		t.Errorf("authorize URL = %s", u)
+	}
+	q := u.Query()
+	if q.Get("client_id") != "client" || q.Get("state") != "st" || q.Get("response_type") != "code" ||
+		q.Get("redirect_uri") != "https://mq.example.com/auth/callback/gitea" {
+		t.Errorf("query = %v", q)
+	}
+
+	gh := auth.NewGithubProvider("", "", "Iv1.x", "s")
+	if !strings.HasPrefix(gh.AuthCodeURL("st", "cb"), "https://github.com/login/oauth/authorize?") || gh.APIURL != "https://api.github.com" {
+		t.Errorf("github defaults: %s %s", gh.AuthorizeURL, gh.APIURL)
+	}
+}
+
+func TestProvider_ExchangeLoginCanRead(t *testing.T) {
+	srv := fakeOAuth(t)
+	p := auth.NewGiteaProvider(srv.URL, "client", "secret")
+	ctx := context.Background()
+
+	if _, err := p.Exchange(ctx, "bad", "cb"); err == nil || !strings.Contains(err.Error(), "bad_verification_code") {
+		t.Fatalf("expected error from error field, got %v", err)
+	}
+	token, err := p.Exchange(ctx, "good", "cb")
+	if err != nil || token != "tok-alice" {
+		t.Fatalf("Exchange = %q, %v", token, err)
+	}
+	if login, err := p.Login(ctx, token); err != nil || login != "alice" {
+		t.Fatalf("Login = %q, %v", login, err)
+	}
+	if ok, err := p.CanRead(ctx, token, "org", "secret"); err != nil || !ok {
+		t.Errorf("CanRead(secret) = %v, %v", ok, err)
+	}
+	if ok, err := p.CanRead(ctx, token, "org", "other"); err != nil || ok {
+		t.Errorf("CanRead(other) = %v, %v; want false", ok, err)
+	}
+	if _, err := p.CanRead(ctx, "revoked", "org", "app"); err == nil {
+		t.Error("expected error for rejected token")
+	}
+	for name, want := range map[string]bool{"secret": true, "app": false, "other": false} {
+		if ok, err := p.CanWrite(ctx, token, "org", name); err != nil || ok != want {
+			t.Errorf("CanWrite(%s) = %v, %v; want %v", name, ok, err, want)
+		}
+	}
+	if _, err := p.CanWrite(ctx, "revoked", "org", "app"); err == nil {
+		t.Error("expected error for rejected token")
+	}
+}
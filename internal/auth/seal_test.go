@@ -0,0 +1,25 @@
+package auth
+
+import "testing"
+
+func TestSealer_RoundTrip(t *testing.T) {
+	s := newSealer("key")
+	sealed := s.seal("id1", "tok-alice")
+	if sealed == "tok-alice" {
+		t.Fatal("token stored in plaintext")
+	}
+
+	got, err := newSealer("key").open("id1", sealed)
+	if err != nil || got != "tok-alice" {
+		t.Fatalf("open = %q, %v; want tok-alice", got, err)
+	}
+	if _, err := s.open("id2", sealed); err == nil {
+		t.Error("token opened under another session ID")
+	}
+	if _, err := newSealer("other").open("id1", sealed); err == nil {
+		t.Error("token opened with another key")
+	}
+	if _, err := newSealer("").open("id1", s.seal("id1", "x")); err == nil {
+		t.Error("random key matched a configured one")
+	}
+}
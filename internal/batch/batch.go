@@ -17,7 +17,9 @@ import (
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/logutil"
 	"github.com/Mic92/gitea-mq/internal/merge"
+	"github.com/Mic92/gitea-mq/internal/notify"
 	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -45,6 +47,7 @@ type Engine struct {
 	RepoID      int64
 	ExternalURL string
 
+	// Tunables; change them on a running Engine only through Retune.
 	BatchMax       int
 	BisectMaxSteps int
 	CheckTimeout   time.Duration
@@ -58,10 +61,48 @@ type Engine struct {
 	// formed without waiting for the poll tick. Optional.
 	Advance func()
 
+	// Notifier mails authors of landed and ejected members. Optional.
+	Notifier *notify.Notifier
+
 	mu    sync.Mutex // guards locks
 	locks map[string]*sync.Mutex
+
+	tuneMu sync.RWMutex // guards the tunables above after Retune
 }
 
+// Tuning holds the Engine settings a config reload may change.
+type Tuning struct {
+	BatchMax       int
+	BisectMaxSteps int
+	CheckTimeout   time.Duration
+	FallbackChecks []string
+}
+
+// Retune applies new settings. Batches already testing keep their members;
+// the next batch formed uses the new size.
+func (e *Engine) Retune(t Tuning) {
+	e.tuneMu.Lock()
+	defer e.tuneMu.Unlock()
+	e.BatchMax = t.BatchMax
+	e.BisectMaxSteps = t.BisectMaxSteps
+	e.CheckTimeout = t.CheckTimeout
+	e.FallbackChecks = t.FallbackChecks
+}
+
+func (e *Engine) tuning() Tuning {
+	e.tuneMu.RLock()
+	defer e.tuneMu.RUnlock()
+	return Tuning{
+		BatchMax:       e.BatchMax,
+		BisectMaxSteps: e.BisectMaxSteps,
+		CheckTimeout:   e.CheckTimeout,
+		FallbackChecks: e.FallbackChecks,
+	}
+}
+
+// Size returns the current maximum batch size.
+func (e *Engine) Size() int { return e.tuning().BatchMax }
+
 // lock returns the per-target-branch unlock func. Batches for different
 // branches in the same repo are independent (ux_batches_live is per branch),
 // so serialising them on one mutex would head-of-line block on forge I/O.
@@ -82,7 +123,7 @@ func (e *Engine) lock(targetBranch string) func() {
 
 // Enabled reports whether batching is active. BatchMax==1 keeps the legacy
 // single-PR path byte-for-byte intact.
-func (e *Engine) Enabled() bool { return e != nil && e.BatchMax != 1 }
+func (e *Engine) Enabled() bool { return e != nil && e.Size() != 1 }
 
 // pendingStack is the JSONB stack of int64 slices stored on the batch row.
 type pendingStack [][]int64
@@ -132,7 +173,7 @@ func (e *Engine) FormAndBuild(ctx context.Context, targetBranch string) (*pg.Bat
 		}
 		return nil, nil
 	}
-	b, err := e.Queue.FormBatch(ctx, e.RepoID, targetBranch, e.BatchMax)
+	b, err := e.Queue.FormBatch(ctx, e.RepoID, targetBranch, e.Size())
 	if err != nil || b == nil {
 		return nil, err
 	}
@@ -207,16 +248,24 @@ func (e *Engine) Build(ctx context.Context, b *pg.Batch) error {
 	// Route check events: every current entry carries the batch branch/sha.
 	// Clearing the check ledger comes last so a stale SaveCheckStatus that
 	// raced in before SetMergeBranch (still keyed on the old SHA) is wiped.
-	desc := fmt.Sprintf("Testing batch #%d (%d PRs)", b.ID, len(b.MemberIds))
 	for _, ent := range surv {
 		logutil.WarnIfErr(e.Queue.SetMergeBranch(ctx, e.RepoID, ent.PrNumber, branch, tip), "set merge branch failed", "pr", ent.PrNumber)
-		if first {
+	}
+	logutil.WarnIfErr(e.Queue.ClearCheckStatuses(ctx, b.CurrentIds), "clear check statuses failed", "batch", b.ID)
+
+	// Forges that show a status report get every rebuild so the bisection
+	// can be followed on the PR; the others only the first.
+	if first || e.Forge.Capabilities().StatusReport {
+		desc := fmt.Sprintf("Testing batch #%d (%d PRs)", b.ID, len(b.MemberIds))
+		if !first {
+			desc = fmt.Sprintf("Testing batch #%d, build %d (%d of %d PRs)", b.ID, b.Builds, len(surv), len(b.MemberIds))
+		}
+		for _, ent := range surv {
 			logutil.WarnIfErr(e.Forge.SetMQStatus(ctx, e.Owner, e.Repo, ent.PrHeadSha, forge.MQStatus{
 				State: pg.CheckStatePending, Description: desc, TargetURL: e.prURL(ent.PrNumber),
 			}), "set mq status failed", "pr", ent.PrNumber)
 		}
 	}
-	logutil.WarnIfErr(e.Queue.ClearCheckStatuses(ctx, b.CurrentIds), "clear check statuses failed", "batch", b.ID)
 
 	slog.Info("batch built", "batch", b.ID, "build", b.Builds, "sha", tip,
 		"current", len(surv), "pending", len(loadPending(b.Pending)))
@@ -229,8 +278,11 @@ func (e *Engine) HandlePass(ctx context.Context, b *pg.Batch) error {
 	if b.State != pg.BatchStateTesting {
 		return nil
 	}
+	e.recordBuild(ctx, b, true, "", "")
 	sha := b.BranchSha.String
-	if err := e.Forge.FastForward(ctx, e.Owner, e.Repo, b.TargetBranch, sha); err != nil {
+	// Landing is the one call a throttled forge must not postpone.
+	ffCtx := ratelimit.WithPriority(ctx, ratelimit.High)
+	if err := e.Forge.FastForward(ffCtx, e.Owner, e.Repo, b.TargetBranch, sha); err != nil {
 		var denied *forge.PushDeniedError
 		switch {
 		case errors.Is(err, forge.ErrNotFastForward):
@@ -268,6 +320,7 @@ func (e *Engine) HandlePass(ctx context.Context, b *pg.Batch) error {
 		if _, err := e.Queue.Dequeue(ctx, e.RepoID, ent.PrNumber); err != nil {
 			slog.Warn("dequeue landed PR failed", "pr", ent.PrNumber, "err", err)
 		}
+		e.notify(ctx, notify.Landed, ent.PrNumber, desc)
 		wg.Go(func() { e.ensureMergedOrClose(ctx, ent, sha, b.ID) })
 	}
 	wg.Wait()
@@ -284,6 +337,7 @@ func (e *Engine) HandleFail(ctx context.Context, b *pg.Batch, failedCheck, targe
 	if b.State != pg.BatchStateTesting {
 		return nil
 	}
+	e.recordBuild(ctx, b, false, failedCheck, targetURL)
 	if len(b.CurrentIds) == 1 {
 		entries, _ := e.Queue.GetEntriesByIDs(ctx, b.CurrentIds)
 		if len(entries) == 1 {
@@ -299,7 +353,7 @@ func (e *Engine) HandleFail(ctx context.Context, b *pg.Batch, failedCheck, targe
 		return e.next(ctx, b)
 	}
 
-	if e.BisectMaxSteps > 0 && int(b.Builds) >= e.BisectMaxSteps {
+	if limit := e.tuning().BisectMaxSteps; limit > 0 && int(b.Builds) >= limit {
 		// Cap is on builds, not splits: drain pending too so next() finishes
 		// instead of popping another slice and rebuilding past the cap.
 		for _, s := range loadPending(b.Pending) {
@@ -308,7 +362,7 @@ func (e *Engine) HandleFail(ctx context.Context, b *pg.Batch, failedCheck, targe
 		b.Pending = nil
 		e.ejectCurrent(ctx, b, pg.CheckStateError,
 			"Bisection limit reached",
-			fmt.Sprintf("⚠️ Removed from merge queue: batch bisection reached the configured limit of %d builds.", e.BisectMaxSteps))
+			fmt.Sprintf("⚠️ Removed from merge queue: batch bisection reached the configured limit of %d builds.", limit))
 		return e.next(ctx, b)
 	}
 
@@ -326,13 +380,33 @@ func (e *Engine) HandleFail(ctx context.Context, b *pg.Batch, failedCheck, targe
 	return e.rebuild(ctx, b)
 }
 
+// recordBuild adds the build of b's current members to the duration history
+// and its outcome to the batch's bisection history. failedCheck and
+// targetURL are empty for a passing build.
+func (e *Engine) recordBuild(ctx context.Context, b *pg.Batch, passed bool, failedCheck, targetURL string) {
+	logutil.WarnIfErr(e.Queue.RecordBuild(ctx, e.RepoID, b.TargetBranch, len(b.CurrentIds), passed, b.TestingStartedAt),
+		"record build duration failed", "batch", b.ID)
+
+	entries, err := e.Queue.GetEntriesByIDs(ctx, b.CurrentIds)
+	if err != nil {
+		slog.Warn("load batch members for history failed", "batch", b.ID, "err", err)
+		return
+	}
+	step := Step{Build: b.Builds, Passed: passed, Check: failedCheck, URL: targetURL}
+	for _, ent := range entries {
+		step.PRs = append(step.PRs, ent.PrNumber)
+	}
+	raw, _ := json.Marshal(step)
+	logutil.WarnIfErr(e.Queue.AppendBatchStep(ctx, b.ID, raw), "record batch step failed", "batch", b.ID)
+}
+
 // HandleTimeout treats a CI timeout as a batch failure. The batch is reloaded
 // under the lock so a poller snapshot that raced a webhook-driven rebuild
 // cannot bisect a stale view.
 func (e *Engine) HandleTimeout(ctx context.Context, targetBranch string, batchID int64) error {
 	defer e.lock(targetBranch)()
 	b, err := e.Queue.GetBatch(ctx, batchID)
-	if err != nil || b == nil || b.State != pg.BatchStateTesting || !TimedOut(b, e.CheckTimeout) {
+	if err != nil || b == nil || b.State != pg.BatchStateTesting || !TimedOut(b, e.tuning().CheckTimeout) {
 		return err
 	}
 	return e.HandleFail(ctx, b, "timeout", "")
@@ -493,6 +567,7 @@ func (e *Engine) eject(ctx context.Context, b *pg.Batch, ent *pg.QueueEntry, sta
 	if _, err := e.Queue.Dequeue(ctx, e.RepoID, ent.PrNumber); err != nil {
 		slog.Warn("dequeue ejected PR failed", "pr", ent.PrNumber, "err", err)
 	}
+	e.notify(ctx, notify.Ejected, ent.PrNumber, statusDesc)
 	b.EjectedIds = append(b.EjectedIds, ent.ID)
 }
 
@@ -526,8 +601,15 @@ func (e *Engine) ensureMergedOrClose(ctx context.Context, ent *pg.QueueEntry, sh
 	logutil.WarnIfErr(e.Forge.ClosePR(ctx, e.Owner, e.Repo, ent.PrNumber), "close pr failed", "pr", ent.PrNumber)
 }
 
+func (e *Engine) notify(ctx context.Context, kind notify.Kind, pr int64, reason string) {
+	e.Notifier.Notify(ctx, notify.Event{
+		Kind: kind, Forge: e.Forge, RepoID: e.RepoID,
+		Owner: e.Owner, Repo: e.Repo, PR: pr, Reason: reason,
+	})
+}
+
 func (e *Engine) prURL(n int64) string {
-	return forge.DashboardPRURL(e.ExternalURL, e.Forge.Kind(), e.Owner, e.Repo, n)
+	return forge.DashboardPRURL(e.ExternalURL, forge.RefOf(e.Forge, e.Owner, e.Repo), n)
 }
 
 // MemberBucket is a member's position within a live batch for UI display.
@@ -559,6 +641,24 @@ func Bucket(b *pg.Batch, id int64) MemberBucket {
 	return ""
 }
 
+// Step is one finished build of a batch in its bisection history.
+type Step struct {
+	Build  int32   `json:"build"`
+	PRs    []int64 `json:"prs"` // PR numbers on the branch
+	Passed bool    `json:"passed"`
+	Check  string  `json:"check,omitempty"` // the failing check, or "timeout"
+	URL    string  `json:"url,omitempty"`   // target URL of the failing check
+}
+
+// History returns b's finished builds, oldest first.
+func History(b *pg.Batch) []Step {
+	var h []Step
+	if len(b.History) > 0 {
+		_ = json.Unmarshal(b.History, &h)
+	}
+	return h
+}
+
 func ids(entries []pg.QueueEntry) []int64 {
 	out := make([]int64, len(entries))
 	for i, e := range entries {
@@ -241,12 +241,41 @@ func TestBisect_CulpritAt2(t *testing.T) {
 	if !strings.Contains(f.target, "sha40") {
 		t.Fatalf("final target = %q", f.target)
 	}
+	h := batch.History(b)
+	if len(h) != 5 || !slices.Equal(h[0].PRs, []int64{10, 20, 30, 40}) || h[0].Passed ||
+		h[0].Check != "ci/test" || h[0].URL != "http://ci/1" || !h[2].Passed || !slices.Equal(h[3].PRs, []int64{20}) {
+		t.Fatalf("history = %+v", h)
+	}
 	// Status discipline: 4 pending (build 1) + 4 terminal = 8.
 	if got := len(f.CallsTo("SetMQStatus")); got != 8 {
 		t.Fatalf("SetMQStatus calls = %d, want 8", got)
 	}
 }
 
+// Forges that show a status report get a fresh pending status on every
+// rebuild, so the bisection can be followed on the PR.
+func TestBisect_StatusReportRebuilds(t *testing.T) {
+	e, f, svc, ctx := setup(t, 10, 20)
+	f.CapabilitiesVal.StatusReport = true
+
+	b, err := e.FormAndBuild(ctx, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.HandleFail(ctx, b, "ci/test", ""); err != nil {
+		t.Fatal(err)
+	}
+	b = mustLive(t, svc, ctx, e.RepoID)
+	calls := f.CallsTo("SetMQStatus")
+	if len(calls) != 3 {
+		t.Fatalf("SetMQStatus calls = %d, want 2 for build 1 and 1 for build 2", len(calls))
+	}
+	st := calls[2].Args[3].(forge.MQStatus)
+	if want := fmt.Sprintf("Testing batch #%d, build 2 (1 of 2 PRs)", b.ID); st.Description != want {
+		t.Fatalf("rebuild status = %q, want %q", st.Description, want)
+	}
+}
+
 func TestBisect_BothHalvesPass_Flaky(t *testing.T) {
 	e, f, _, ctx := setup(t, 10, 20)
 	f.merged[10], f.merged[20] = true, true
@@ -26,7 +26,7 @@ func (e *Engine) HandleCheck(ctx context.Context, entry *pg.QueueEntry, checkCtx
 	if err := e.Queue.SaveCheckStatus(ctx, entry.ID, checkCtx, state, targetURL); err != nil {
 		return err
 	}
-	required, err := monitor.ResolveRequiredChecks(ctx, e.Forge, e.Owner, e.Repo, b.TargetBranch, e.FallbackChecks)
+	required, err := monitor.ResolveRequiredChecks(ctx, e.Forge, e.Owner, e.Repo, b.TargetBranch, e.tuning().FallbackChecks)
 	if err != nil {
 		return err
 	}
@@ -41,7 +41,7 @@ func (e *Engine) HandleCheck(ctx context.Context, entry *pg.QueueEntry, checkCtx
 	case monitor.CheckFailure:
 		return e.HandleFail(ctx, b, fc, fu)
 	default:
-		if TimedOut(b, e.CheckTimeout) {
+		if TimedOut(b, e.tuning().CheckTimeout) {
 			return e.HandleFail(ctx, b, "timeout", "")
 		}
 	}
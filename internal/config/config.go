@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,8 +14,14 @@ import (
 
 // Config holds all configuration for the gitea-mq service.
 type Config struct {
-	Gitea  *GiteaConfig  // nil if unconfigured
-	Github *GithubConfig // nil if unconfigured
+	Gitea *GiteaConfig // nil if unconfigured
+	// GiteaInstances are further Gitea servers, each under its own name.
+	GiteaInstances []*GiteaConfig
+	Forgejo        *GiteaConfig  // nil if unconfigured
+	Gitlab         *GiteaConfig  // nil if unconfigured
+	Github         *GithubConfig // nil if unconfigured
+	SMTP           *SMTPConfig   // nil if e-mail notifications are disabled
+	Auth           *AuthConfig   // nil if dashboard login is disabled
 
 	DatabaseURL         string
 	ListenAddr          string
@@ -24,17 +31,37 @@ type Config struct {
 	IdlePollInterval    time.Duration
 	CheckTimeout        time.Duration
 	RequiredChecks      []string
+	BranchPatterns      []string // gated like the default branch by auto-setup
+	CreateProtection    bool     // auto-setup creates missing Gitea/Forgejo protection rules
+	TeardownOnRemove    bool     // undo auto-setup when a repo is no longer managed
 	SkipQueueIfUpToDate bool
 	BatchMax            int
 	BisectMaxSteps      int
 	RefreshInterval     time.Duration
 	DiscoveryInterval   time.Duration
-	LogLevel            string
+	// LeaderCheckInterval is how often the leader replica verifies it still
+	// holds leadership and followers refresh their repo set.
+	LeaderCheckInterval time.Duration
+	// WebhookRetention is how long processed webhook deliveries are kept
+	// for de-duplication and replay.
+	WebhookRetention time.Duration
+	// ShadowRepos run in dry-run mode: the queue works as usual, but forge
+	// writes are only recorded for the dashboard.
+	ShadowRepos []forge.RepoRef
+	// AdminToken enables the /admin/ API; empty disables it.
+	AdminToken string
+	LogLevel   string
 	// CacheDir holds persistent bare git clones used for merge operations.
 	CacheDir string
+	// ConfigFile is the TOML file the config was read from; empty if it
+	// came from the environment alone.
+	ConfigFile string
 }
 
 type GiteaConfig struct {
+	// Instance names a server listed in GITEA_MQ_GITEA_INSTANCES; empty for
+	// the default one.
+	Instance      string
 	URL           string
 	Token         string
 	WebhookSecret string
@@ -43,53 +70,135 @@ type GiteaConfig struct {
 }
 
 type GithubConfig struct {
+	// Exactly one of AppID (with PrivateKey) and Token is set. Token mode
+	// serves orgs that cannot register an App and discovers repos by Topic
+	// instead of by installation.
 	AppID         int64
 	PrivateKey    []byte
+	Token         string
+	Topic         string
 	WebhookSecret string
 	Repos         []forge.RepoRef
+	// URL is the web root of a GitHub Enterprise Server; empty means
+	// github.com. The API, upload and GraphQL URLs default to the GHES
+	// layout below it and only need setting behind unusual proxies.
+	URL        string
+	APIURL     string
+	UploadURL  string
+	GraphQLURL string
 	// PollInterval defaults to Config.PollInterval; override via
 	// GITEA_MQ_GITHUB_POLL_INTERVAL when GitHub's higher rate limit
 	// warrants a different cadence.
 	PollInterval time.Duration
 }
 
+// SMTPConfig enables e-mail notifications when GITEA_MQ_SMTP_ADDR is set.
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	// NotifyAuthors mails PR authors when their PR lands or is ejected.
+	NotifyAuthors bool
+	// Digest maps repos to the addresses receiving the failure digest.
+	Digest         map[forge.RepoRef][]string
+	DigestInterval time.Duration
+	TemplateDir    string
+}
+
+// AuthConfig enables dashboard login when at least one OAuth client is
+// configured. The dashboard then only shows repos the visitor can read.
+type AuthConfig struct {
+	GiteaClientID      string
+	GiteaClientSecret  string
+	GithubClientID     string
+	GithubClientSecret string
+	// SessionKey encrypts the forge tokens kept in login sessions. Empty
+	// means a random key per process: logins do not survive a restart.
+	SessionKey string
+	// CacheTTL bounds how long a permission answer is reused.
+	CacheTTL time.Duration
+}
+
 func (c *Config) Repos() []forge.RepoRef {
 	var out []forge.RepoRef
 	if c.Gitea != nil {
 		out = append(out, c.Gitea.Repos...)
 	}
+	for _, gc := range c.GiteaInstances {
+		out = append(out, gc.Repos...)
+	}
+	if c.Forgejo != nil {
+		out = append(out, c.Forgejo.Repos...)
+	}
+	if c.Gitlab != nil {
+		out = append(out, c.Gitlab.Repos...)
+	}
 	if c.Github != nil {
 		out = append(out, c.Github.Repos...)
 	}
 	return out
 }
 
-// Load reads configuration from environment variables, validates required
-// fields, and applies defaults.
+// Load reads configuration from environment variables and, if
+// GITEA_MQ_CONFIG_FILE is set, from that TOML file, validates required
+// fields, and applies defaults. A variable set in the environment overrides
+// the file.
 func Load() (*Config, error) {
+	path := os.Getenv("GITEA_MQ_CONFIG_FILE")
+	var e env
+	if path != "" {
+		var err error
+		e.file, err = readFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("GITEA_MQ_CONFIG_FILE: %w", err)
+		}
+	}
+
 	cfg := &Config{
-		ListenAddr:  envOrDefault("GITEA_MQ_LISTEN_ADDR", ":8080"),
-		WebhookPath: envOrDefault("GITEA_MQ_WEBHOOK_PATH", "/webhook"),
+		ConfigFile:  path,
+		ListenAddr:  e.envOrDefault("GITEA_MQ_LISTEN_ADDR", ":8080"),
+		WebhookPath: e.envOrDefault("GITEA_MQ_WEBHOOK_PATH", "/webhook"),
 	}
 
 	var missing []string
 
-	cfg.DatabaseURL = os.Getenv("GITEA_MQ_DATABASE_URL")
+	cfg.DatabaseURL = e.get("GITEA_MQ_DATABASE_URL")
 	if cfg.DatabaseURL == "" {
 		missing = append(missing, "GITEA_MQ_DATABASE_URL")
 	}
 
-	cfg.ExternalURL = strings.TrimRight(os.Getenv("GITEA_MQ_EXTERNAL_URL"), "/")
+	cfg.ExternalURL = strings.TrimRight(e.get("GITEA_MQ_EXTERNAL_URL"), "/")
 	if cfg.ExternalURL == "" {
 		missing = append(missing, "GITEA_MQ_EXTERNAL_URL")
 	}
 
 	var err error
-	cfg.Gitea, err = loadGitea(&missing)
+	cfg.Gitea, err = e.loadGitea(giteaNames, &missing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.GiteaInstances, err = e.loadGiteaInstances(&missing)
 	if err != nil {
 		return nil, err
 	}
-	cfg.Github, err = loadGithub(&missing)
+	cfg.Forgejo, err = e.loadGitea(forgejoNames, &missing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Gitlab, err = e.loadGitea(gitlabNames, &missing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Github, err = e.loadGithub(&missing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SMTP, err = e.loadSMTP(&missing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Auth, err = e.loadAuth(cfg, &missing)
 	if err != nil {
 		return nil, err
 	}
@@ -98,41 +207,55 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
 	}
 
-	if cfg.Gitea == nil && cfg.Github == nil {
-		return nil, fmt.Errorf("no forge configured: set GITEA_MQ_GITEA_URL or GITEA_MQ_GITHUB_APP_ID")
+	if cfg.Gitea == nil && len(cfg.GiteaInstances) == 0 && cfg.Forgejo == nil && cfg.Gitlab == nil && cfg.Github == nil {
+		return nil, fmt.Errorf("no forge configured: set GITEA_MQ_GITEA_URL, GITEA_MQ_GITEA_INSTANCES, GITEA_MQ_FORGEJO_URL, GITEA_MQ_GITLAB_URL, GITEA_MQ_GITHUB_APP_ID or GITEA_MQ_GITHUB_TOKEN")
 	}
 
-	cfg.PollInterval, err = parseDurationOrDefault("GITEA_MQ_POLL_INTERVAL", 30*time.Second)
+	cfg.PollInterval, err = e.parseDurationOrDefault("GITEA_MQ_POLL_INTERVAL", 30*time.Second)
 	if err != nil {
 		return nil, err
 	}
 	// Idle repos are driven by webhooks; this periodic reconcile is only a
 	// safety net for deliveries missed during downtime, so it can run rarely.
-	cfg.IdlePollInterval, err = parseDurationOrDefault("GITEA_MQ_IDLE_POLL_INTERVAL", 15*time.Minute)
+	cfg.IdlePollInterval, err = e.parseDurationOrDefault("GITEA_MQ_IDLE_POLL_INTERVAL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CheckTimeout, err = e.parseDurationOrDefault("GITEA_MQ_CHECK_TIMEOUT", 1*time.Hour)
 	if err != nil {
 		return nil, err
 	}
-	cfg.CheckTimeout, err = parseDurationOrDefault("GITEA_MQ_CHECK_TIMEOUT", 1*time.Hour)
+	cfg.RefreshInterval, err = e.parseDurationOrDefault("GITEA_MQ_REFRESH_INTERVAL", 10*time.Second)
 	if err != nil {
 		return nil, err
 	}
-	cfg.RefreshInterval, err = parseDurationOrDefault("GITEA_MQ_REFRESH_INTERVAL", 10*time.Second)
+	cfg.DiscoveryInterval, err = e.parseDurationOrDefault("GITEA_MQ_DISCOVERY_INTERVAL", 5*time.Minute)
 	if err != nil {
 		return nil, err
 	}
-	cfg.DiscoveryInterval, err = parseDurationOrDefault("GITEA_MQ_DISCOVERY_INTERVAL", 5*time.Minute)
+	cfg.LeaderCheckInterval, err = e.parseDurationOrDefault("GITEA_MQ_LEADER_CHECK_INTERVAL", 5*time.Second)
 	if err != nil {
 		return nil, err
 	}
+	cfg.WebhookRetention, err = e.parseDurationOrDefault("GITEA_MQ_WEBHOOK_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	adminToken, err := e.readSecret("GITEA_MQ_ADMIN_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	cfg.AdminToken = strings.TrimSpace(string(adminToken))
 
 	if cfg.Github != nil {
-		cfg.Github.PollInterval, err = parseDurationOrDefault("GITEA_MQ_GITHUB_POLL_INTERVAL", cfg.PollInterval)
+		cfg.Github.PollInterval, err = e.parseDurationOrDefault("GITEA_MQ_GITHUB_POLL_INTERVAL", cfg.PollInterval)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if checks := os.Getenv("GITEA_MQ_REQUIRED_CHECKS"); checks != "" {
+	if checks := e.get("GITEA_MQ_REQUIRED_CHECKS"); checks != "" {
 		for _, c := range strings.Split(checks, ",") {
 			if c = strings.TrimSpace(c); c != "" {
 				cfg.RequiredChecks = append(cfg.RequiredChecks, c)
@@ -140,21 +263,46 @@ func Load() (*Config, error) {
 		}
 	}
 
-	cfg.SkipQueueIfUpToDate, err = parseBool("GITEA_MQ_SKIP_QUEUE_IF_UP_TO_DATE", true)
+	if patterns := e.get("GITEA_MQ_BRANCH_PATTERNS"); patterns != "" {
+		for _, p := range strings.Split(patterns, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				cfg.BranchPatterns = append(cfg.BranchPatterns, p)
+			}
+		}
+	}
+
+	cfg.CreateProtection, err = e.parseBool("GITEA_MQ_CREATE_BRANCH_PROTECTION", false)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.TeardownOnRemove, err = e.parseBool("GITEA_MQ_TEARDOWN_ON_REMOVE", false)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.BatchMax, err = parseInt("GITEA_MQ_BATCH_MAX", 1, 0)
+	cfg.SkipQueueIfUpToDate, err = e.parseBool("GITEA_MQ_SKIP_QUEUE_IF_UP_TO_DATE", true)
 	if err != nil {
 		return nil, err
 	}
-	cfg.BisectMaxSteps, err = parseInt("GITEA_MQ_BISECT_MAX_STEPS", 0, 0)
+
+	cfg.BatchMax, err = e.parseInt("GITEA_MQ_BATCH_MAX", 1, 0)
 	if err != nil {
 		return nil, err
 	}
+	cfg.BisectMaxSteps, err = e.parseInt("GITEA_MQ_BISECT_MAX_STEPS", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if s := e.get("GITEA_MQ_SHADOW_REPOS"); s != "" {
+		cfg.ShadowRepos, err = parseRepoRefs(s)
+		if err != nil {
+			return nil, fmt.Errorf("GITEA_MQ_SHADOW_REPOS: %w", err)
+		}
+	}
 
-	cfg.CacheDir = os.Getenv("GITEA_MQ_CACHE_DIR")
+	cfg.CacheDir = e.get("GITEA_MQ_CACHE_DIR")
 	if cfg.CacheDir == "" {
 		base, err := os.UserCacheDir()
 		if err != nil {
@@ -163,7 +311,7 @@ func Load() (*Config, error) {
 		cfg.CacheDir = filepath.Join(base, "gitea-mq")
 	}
 
-	cfg.LogLevel = envOrDefault("GITEA_MQ_LOG_LEVEL", "info")
+	cfg.LogLevel = e.envOrDefault("GITEA_MQ_LOG_LEVEL", "info")
 	switch cfg.LogLevel {
 	case "debug", "info", "warn", "error":
 	default:
@@ -173,67 +321,180 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
-// loadGitea returns a GiteaConfig if GITEA_MQ_GITEA_URL is set; otherwise nil.
-// Dependent variables are reported missing only when Gitea is configured so a
-// GitHub-only deployment carries no Gitea baggage.
-func loadGitea(missing *[]string) (*GiteaConfig, error) {
-	url := strings.TrimRight(os.Getenv("GITEA_MQ_GITEA_URL"), "/")
+// giteaVars names the environment variables of a token-authenticated forge.
+// Gitea keeps its unprefixed historical names; Forgejo's and GitLab's are
+// all prefixed.
+type giteaVars struct {
+	kind                                    forge.Kind
+	instance                                string
+	url, token, webhookSecret, topic, repos string
+}
+
+var (
+	giteaNames = giteaVars{
+		kind:          forge.KindGitea,
+		url:           "GITEA_MQ_GITEA_URL",
+		token:         "GITEA_MQ_GITEA_TOKEN",
+		webhookSecret: "GITEA_MQ_WEBHOOK_SECRET",
+		topic:         "GITEA_MQ_TOPIC",
+		repos:         "GITEA_MQ_REPOS",
+	}
+	forgejoNames = giteaVars{
+		kind:          forge.KindForgejo,
+		url:           "GITEA_MQ_FORGEJO_URL",
+		token:         "GITEA_MQ_FORGEJO_TOKEN",
+		webhookSecret: "GITEA_MQ_FORGEJO_WEBHOOK_SECRET",
+		topic:         "GITEA_MQ_FORGEJO_TOPIC",
+		repos:         "GITEA_MQ_FORGEJO_REPOS",
+	}
+	gitlabNames = giteaVars{
+		kind:          forge.KindGitlab,
+		url:           "GITEA_MQ_GITLAB_URL",
+		token:         "GITEA_MQ_GITLAB_TOKEN",
+		webhookSecret: "GITEA_MQ_GITLAB_WEBHOOK_SECRET",
+		topic:         "GITEA_MQ_GITLAB_TOPIC",
+		repos:         "GITEA_MQ_GITLAB_REPOS",
+	}
+)
+
+// giteaInstanceNames names the variables of a named Gitea instance:
+// GITEA_MQ_GITEA_<NAME>_URL and so on, NAME upper-cased with dashes as
+// underscores.
+func giteaInstanceNames(instance string) giteaVars {
+	prefix := "GITEA_MQ_GITEA_" + strings.ToUpper(strings.ReplaceAll(instance, "-", "_")) + "_"
+	return giteaVars{
+		kind:          forge.KindGitea,
+		instance:      instance,
+		url:           prefix + "URL",
+		token:         prefix + "TOKEN",
+		webhookSecret: prefix + "WEBHOOK_SECRET",
+		topic:         prefix + "TOPIC",
+		repos:         prefix + "REPOS",
+	}
+}
+
+// loadGiteaInstances loads the named Gitea servers listed in
+// GITEA_MQ_GITEA_INSTANCES. Each must set its URL.
+func (e env) loadGiteaInstances(missing *[]string) ([]*GiteaConfig, error) {
+	var out []*GiteaConfig
+	seen := map[string]bool{}
+	for _, name := range strings.Split(e.get("GITEA_MQ_GITEA_INSTANCES"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !forge.ValidInstanceName(name) {
+			return nil, fmt.Errorf("GITEA_MQ_GITEA_INSTANCES: invalid instance name %q, use lower-case letters, digits and dashes", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("GITEA_MQ_GITEA_INSTANCES: duplicate instance %q", name)
+		}
+		seen[name] = true
+
+		vars := giteaInstanceNames(name)
+		gc, err := e.loadGitea(vars, missing)
+		if err != nil {
+			return nil, err
+		}
+		if gc == nil {
+			*missing = append(*missing, vars.url)
+			continue
+		}
+		out = append(out, gc)
+	}
+	return out, nil
+}
+
+// loadGitea returns a GiteaConfig if the forge's URL variable is set;
+// otherwise nil. Dependent variables are reported missing only when the
+// forge is configured so other deployments carry no Gitea baggage.
+func (e env) loadGitea(vars giteaVars, missing *[]string) (*GiteaConfig, error) {
+	url := strings.TrimRight(e.get(vars.url), "/")
 	if url == "" {
 		return nil, nil
 	}
 	gc := &GiteaConfig{
-		URL:   url,
-		Topic: os.Getenv("GITEA_MQ_TOPIC"),
+		Instance: vars.instance,
+		URL:      url,
+		Topic:    e.get(vars.topic),
 	}
 
-	gc.Token = os.Getenv("GITEA_MQ_GITEA_TOKEN")
+	gc.Token = e.get(vars.token)
 	if gc.Token == "" {
-		*missing = append(*missing, "GITEA_MQ_GITEA_TOKEN")
+		*missing = append(*missing, vars.token)
 	}
-	gc.WebhookSecret = os.Getenv("GITEA_MQ_WEBHOOK_SECRET")
+	gc.WebhookSecret = e.get(vars.webhookSecret)
 	if gc.WebhookSecret == "" {
-		*missing = append(*missing, "GITEA_MQ_WEBHOOK_SECRET")
+		*missing = append(*missing, vars.webhookSecret)
 	}
 
-	reposStr := os.Getenv("GITEA_MQ_REPOS")
+	reposStr := e.get(vars.repos)
 	if reposStr == "" && gc.Topic == "" {
-		*missing = append(*missing, "GITEA_MQ_REPOS")
+		*missing = append(*missing, vars.repos)
 	}
 	if reposStr != "" {
-		repos, err := parseRepos(reposStr, forge.KindGitea)
+		repos, err := parseRepos(reposStr, vars.kind)
 		if err != nil {
-			return nil, fmt.Errorf("GITEA_MQ_REPOS: %w", err)
+			return nil, fmt.Errorf("%s: %w", vars.repos, err)
+		}
+		for i := range repos {
+			repos[i].Instance = vars.instance
 		}
 		gc.Repos = repos
 	}
 	return gc, nil
 }
 
-func loadGithub(missing *[]string) (*GithubConfig, error) {
-	appIDStr := os.Getenv("GITEA_MQ_GITHUB_APP_ID")
-	if appIDStr == "" {
+// loadGithub enables the GitHub backend either as an App
+// (GITEA_MQ_GITHUB_APP_ID) or with a user token (GITEA_MQ_GITHUB_TOKEN).
+func (e env) loadGithub(missing *[]string) (*GithubConfig, error) {
+	appIDStr := e.get("GITEA_MQ_GITHUB_APP_ID")
+	token, err := e.readSecret("GITEA_MQ_GITHUB_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	if appIDStr == "" && len(token) == 0 {
 		return nil, nil
 	}
-	appID, err := strconv.ParseInt(appIDStr, 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("GITEA_MQ_GITHUB_APP_ID: %w", err)
+	if appIDStr != "" && len(token) != 0 {
+		return nil, fmt.Errorf("GITEA_MQ_GITHUB_APP_ID and GITEA_MQ_GITHUB_TOKEN are mutually exclusive")
 	}
-	gc := &GithubConfig{AppID: appID}
-
-	gc.PrivateKey, err = readSecret("GITEA_MQ_GITHUB_PRIVATE_KEY")
-	if err != nil {
-		return nil, err
+	gc := &GithubConfig{
+		Token:      string(token),
+		Topic:      e.get("GITEA_MQ_GITHUB_TOPIC"),
+		URL:        e.get("GITEA_MQ_GITHUB_URL"),
+		APIURL:     e.get("GITEA_MQ_GITHUB_API_URL"),
+		UploadURL:  e.get("GITEA_MQ_GITHUB_UPLOAD_URL"),
+		GraphQLURL: e.get("GITEA_MQ_GITHUB_GRAPHQL_URL"),
 	}
-	if len(gc.PrivateKey) == 0 {
-		*missing = append(*missing, "GITEA_MQ_GITHUB_PRIVATE_KEY")
+
+	if appIDStr != "" {
+		gc.AppID, err = strconv.ParseInt(appIDStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("GITEA_MQ_GITHUB_APP_ID: %w", err)
+		}
+		// Installations are the App's discovery; a topic has no meaning.
+		if gc.Topic != "" {
+			return nil, fmt.Errorf("GITEA_MQ_GITHUB_TOPIC requires GITEA_MQ_GITHUB_TOKEN")
+		}
+		gc.PrivateKey, err = e.readSecret("GITEA_MQ_GITHUB_PRIVATE_KEY")
+		if err != nil {
+			return nil, err
+		}
+		if len(gc.PrivateKey) == 0 {
+			*missing = append(*missing, "GITEA_MQ_GITHUB_PRIVATE_KEY")
+		}
+	} else if e.get("GITEA_MQ_GITHUB_REPOS") == "" && gc.Topic == "" {
+		// A token has no installations to fall back on.
+		*missing = append(*missing, "GITEA_MQ_GITHUB_REPOS")
 	}
 
-	gc.WebhookSecret = os.Getenv("GITEA_MQ_GITHUB_WEBHOOK_SECRET")
+	gc.WebhookSecret = e.get("GITEA_MQ_GITHUB_WEBHOOK_SECRET")
 	if gc.WebhookSecret == "" {
 		*missing = append(*missing, "GITEA_MQ_GITHUB_WEBHOOK_SECRET")
 	}
 
-	if reposStr := os.Getenv("GITEA_MQ_GITHUB_REPOS"); reposStr != "" {
+	if reposStr := e.get("GITEA_MQ_GITHUB_REPOS"); reposStr != "" {
 		gc.Repos, err = parseRepos(reposStr, forge.KindGithub)
 		if err != nil {
 			return nil, fmt.Errorf("GITEA_MQ_GITHUB_REPOS: %w", err)
@@ -242,24 +503,148 @@ func loadGithub(missing *[]string) (*GithubConfig, error) {
 	return gc, nil
 }
 
-// readSecret reads <key> or, if unset, the file at <key>_FILE. The _FILE form
-// keeps multi-line PEM keys out of process environment listings.
-func readSecret(key string) ([]byte, error) {
-	if v := os.Getenv(key); v != "" {
-		return []byte(v), nil
+// loadSMTP returns an SMTPConfig if GITEA_MQ_SMTP_ADDR is set; otherwise nil.
+func (e env) loadSMTP(missing *[]string) (*SMTPConfig, error) {
+	addr := e.get("GITEA_MQ_SMTP_ADDR")
+	if addr == "" {
+		return nil, nil
+	}
+	sc := &SMTPConfig{
+		Addr:        addr,
+		Username:    e.get("GITEA_MQ_SMTP_USERNAME"),
+		From:        e.get("GITEA_MQ_SMTP_FROM"),
+		TemplateDir: e.get("GITEA_MQ_NOTIFY_TEMPLATE_DIR"),
+	}
+	if sc.From == "" {
+		*missing = append(*missing, "GITEA_MQ_SMTP_FROM")
+	}
+
+	password, err := e.readSecret("GITEA_MQ_SMTP_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+	sc.Password = strings.TrimSpace(string(password))
+
+	sc.NotifyAuthors, err = e.parseBool("GITEA_MQ_NOTIFY_AUTHORS", true)
+	if err != nil {
+		return nil, err
+	}
+	if s := e.get("GITEA_MQ_NOTIFY_DIGEST"); s != "" {
+		sc.Digest, err = parseDigest(s)
+		if err != nil {
+			return nil, fmt.Errorf("GITEA_MQ_NOTIFY_DIGEST: %w", err)
+		}
+	}
+	sc.DigestInterval, err = e.parseDurationOrDefault("GITEA_MQ_NOTIFY_DIGEST_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// loadAuth returns an AuthConfig if an OAuth client ID is set for a
+// configured forge; otherwise nil. A client ID for an unconfigured forge is
+// an error rather than silently ignored.
+func (e env) loadAuth(cfg *Config, missing *[]string) (*AuthConfig, error) {
+	ac := &AuthConfig{
+		GiteaClientID:  e.get("GITEA_MQ_GITEA_OAUTH_CLIENT_ID"),
+		GithubClientID: e.get("GITEA_MQ_GITHUB_OAUTH_CLIENT_ID"),
+	}
+	if ac.GiteaClientID == "" && ac.GithubClientID == "" {
+		return nil, nil
+	}
+	if ac.GiteaClientID != "" && cfg.Gitea == nil {
+		return nil, fmt.Errorf("GITEA_MQ_GITEA_OAUTH_CLIENT_ID requires GITEA_MQ_GITEA_URL")
 	}
-	if path := os.Getenv(key + "_FILE"); path != "" {
-		b, err := os.ReadFile(path)
+	if ac.GithubClientID != "" && cfg.Github == nil {
+		return nil, fmt.Errorf("GITEA_MQ_GITHUB_OAUTH_CLIENT_ID requires GITEA_MQ_GITHUB_APP_ID or GITEA_MQ_GITHUB_TOKEN")
+	}
+
+	for _, s := range []struct {
+		id     string
+		key    string
+		target *string
+	}{
+		{ac.GiteaClientID, "GITEA_MQ_GITEA_OAUTH_CLIENT_SECRET", &ac.GiteaClientSecret},
+		{ac.GithubClientID, "GITEA_MQ_GITHUB_OAUTH_CLIENT_SECRET", &ac.GithubClientSecret},
+	} {
+		if s.id == "" {
+			continue
+		}
+		secret, err := e.readSecret(s.key)
 		if err != nil {
-			return nil, fmt.Errorf("%s_FILE: %w", key, err)
+			return nil, err
+		}
+		*s.target = strings.TrimSpace(string(secret))
+		if *s.target == "" {
+			*missing = append(*missing, s.key)
+		}
+	}
+
+	key, err := e.readSecret("GITEA_MQ_AUTH_SESSION_KEY")
+	if err != nil {
+		return nil, err
+	}
+	ac.SessionKey = strings.TrimSpace(string(key))
+
+	ac.CacheTTL, err = e.parseDurationOrDefault("GITEA_MQ_AUTH_CACHE_TTL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
+// parseDigest parses "<forge>:<owner>/<name>=<addr>,<addr>;..." into a
+// per-repo recipient list. The forge prefix is required because the same
+// owner/name may exist on both forges.
+func parseDigest(s string) (map[forge.RepoRef][]string, error) {
+	out := make(map[forge.RepoRef][]string)
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		repo, addrs, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q, expected <forge>:<owner>/<name>=<addr>[,<addr>...]", part)
+		}
+		ref, ok := forge.ParseRepoRef(strings.TrimSpace(repo))
+		if !ok {
+			return nil, fmt.Errorf("invalid repo %q, expected <forge>:<owner>/<name>", repo)
+		}
+		for _, a := range strings.Split(addrs, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				out[ref] = append(out[ref], a)
+			}
+		}
+		if len(out[ref]) == 0 {
+			return nil, fmt.Errorf("no addresses for %s", ref)
+		}
+	}
+	return out, nil
+}
+
+// readSecret reads <key> or, if unset, the file at <key>_FILE. The _FILE form
+// keeps multi-line PEM keys out of process environment listings.
+func (e env) readSecret(key string) ([]byte, error) {
+	// Both forms in the environment beat both forms in the config file.
+	for _, get := range []func(string) string{os.Getenv, e.fromFile} {
+		if v := get(key); v != "" {
+			return []byte(v), nil
+		}
+		if path := get(key + "_FILE"); path != "" {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s_FILE: %w", key, err)
+			}
+			return b, nil
 		}
-		return b, nil
 	}
 	return nil, nil
 }
 
-func envOrDefault(key, defaultVal string) string {
-	if v := os.Getenv(key); v != "" {
+func (e env) envOrDefault(key, defaultVal string) string {
+	if v := e.get(key); v != "" {
 		return v
 	}
 	return defaultVal
@@ -275,8 +660,9 @@ func parseRepos(s string, kind forge.Kind) ([]forge.RepoRef, error) {
 		if part == "" {
 			continue
 		}
+		// GitLab subgroups (group/sub/project) are not supported.
 		owner, name, ok := strings.Cut(part, "/")
-		if !ok || owner == "" || name == "" {
+		if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
 			return nil, fmt.Errorf("invalid repo format %q, expected owner/name", part)
 		}
 		repos = append(repos, forge.RepoRef{Forge: kind, Owner: owner, Name: name})
@@ -287,8 +673,31 @@ func parseRepos(s string, kind forge.Kind) ([]forge.RepoRef, error) {
 	return repos, nil
 }
 
-func parseInt(envKey string, defaultVal, minVal int) (int, error) {
-	s := os.Getenv(envKey)
+// parseRepoRefs parses a comma-separated list of "<forge>:<owner>/<name>"
+// entries, for settings that may name repos on any forge.
+func parseRepoRefs(s string) ([]forge.RepoRef, error) {
+	var refs []forge.RepoRef
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		ref, ok := forge.ParseRepoRef(part)
+		if !ok {
+			return nil, fmt.Errorf("invalid repo %q, expected <forge>:<owner>/<name>", part)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// Shadowed reports whether ref runs in shadow mode.
+func (c *Config) Shadowed(ref forge.RepoRef) bool {
+	return slices.Contains(c.ShadowRepos, ref)
+}
+
+func (e env) parseInt(envKey string, defaultVal, minVal int) (int, error) {
+	s := e.get(envKey)
 	if s == "" {
 		return defaultVal, nil
 	}
@@ -302,8 +711,8 @@ func parseInt(envKey string, defaultVal, minVal int) (int, error) {
 	return n, nil
 }
 
-func parseBool(envKey string, defaultVal bool) (bool, error) {
-	s := os.Getenv(envKey)
+func (e env) parseBool(envKey string, defaultVal bool) (bool, error) {
+	s := e.get(envKey)
 	if s == "" {
 		return defaultVal, nil
 	}
@@ -314,8 +723,8 @@ func parseBool(envKey string, defaultVal bool) (bool, error) {
 	return b, nil
 }
 
-func parseDurationOrDefault(envKey string, defaultVal time.Duration) (time.Duration, error) {
-	s := os.Getenv(envKey)
+func (e env) parseDurationOrDefault(envKey string, defaultVal time.Duration) (time.Duration, error) {
+	s := e.get(envKey)
 	if s == "" {
 		return defaultVal, nil
 	}
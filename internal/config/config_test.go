@@ -32,6 +32,9 @@ func TestParseRepos_Invalid(t *testing.T) {
 	if _, err := parseRepos("noslash", forge.KindGitea); err == nil {
 		t.Fatal("expected error for missing slash")
 	}
+	if _, err := parseRepos("group/sub/app", forge.KindGitlab); err == nil {
+		t.Fatal("expected error for a subgroup path")
+	}
 }
 
 // setEnv resets every GITEA_MQ_* variable and applies the given map so each
@@ -98,6 +101,25 @@ func TestLoad_BatchMax(t *testing.T) {
 	}
 }
 
+func TestLoad_ShadowRepos(t *testing.T) {
+	setEnv(t, giteaEnv)
+	t.Setenv("GITEA_MQ_SHADOW_REPOS", "gitea:o/r, github:org/app")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Shadowed(forge.RepoRef{Forge: forge.KindGitea, Owner: "o", Name: "r"}) ||
+		!cfg.Shadowed(forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"}) ||
+		cfg.Shadowed(forge.RepoRef{Forge: forge.KindForgejo, Owner: "o", Name: "r"}) {
+		t.Errorf("ShadowRepos = %v", cfg.ShadowRepos)
+	}
+
+	t.Setenv("GITEA_MQ_SHADOW_REPOS", "o/r")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GITEA_MQ_SHADOW_REPOS") {
+		t.Fatalf("expected error for a repo without forge, got %v", err)
+	}
+}
+
 func TestLoad_NoForgeFails(t *testing.T) {
 	setEnv(t, baseEnv)
 	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "no forge configured") {
@@ -132,6 +154,89 @@ func TestLoad_GithubOnlyOK(t *testing.T) {
 	}
 }
 
+func TestLoad_GithubEnterpriseURLs(t *testing.T) {
+	setEnv(t, with(map[string]string{
+		"GITEA_MQ_GITHUB_APP_ID":         "1",
+		"GITEA_MQ_GITHUB_PRIVATE_KEY":    "k",
+		"GITEA_MQ_GITHUB_WEBHOOK_SECRET": "s",
+		"GITEA_MQ_GITHUB_URL":            "https://ghe.example.com",
+		"GITEA_MQ_GITHUB_GRAPHQL_URL":    "https://ghe.example.com/custom/graphql",
+	}))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Github.URL != "https://ghe.example.com" || cfg.Github.GraphQLURL != "https://ghe.example.com/custom/graphql" {
+		t.Errorf("Github = %+v", cfg.Github)
+	}
+	// Unset URLs are derived by the GitHub client, not here.
+	if cfg.Github.APIURL != "" || cfg.Github.UploadURL != "" {
+		t.Errorf("APIURL=%q UploadURL=%q, want empty", cfg.Github.APIURL, cfg.Github.UploadURL)
+	}
+}
+
+func TestLoad_GithubToken(t *testing.T) {
+	setEnv(t, with(map[string]string{
+		"GITEA_MQ_GITHUB_TOKEN":          "ghp_x",
+		"GITEA_MQ_GITHUB_WEBHOOK_SECRET": "s",
+		"GITEA_MQ_GITHUB_TOPIC":          "merge-queue",
+	}))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Github == nil || cfg.Github.Token != "ghp_x" || cfg.Github.AppID != 0 || cfg.Github.Topic != "merge-queue" {
+		t.Fatalf("Github = %+v", cfg.Github)
+	}
+}
+
+func TestLoad_GithubTokenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "app and token",
+			env: map[string]string{
+				"GITEA_MQ_GITHUB_APP_ID":         "1",
+				"GITEA_MQ_GITHUB_PRIVATE_KEY":    "k",
+				"GITEA_MQ_GITHUB_TOKEN":          "ghp_x",
+				"GITEA_MQ_GITHUB_WEBHOOK_SECRET": "s",
+			},
+			want: "mutually exclusive",
+		},
+		{
+			name: "token without repos or topic",
+			env: map[string]string{
+				"GITEA_MQ_GITHUB_TOKEN":          "ghp_x",
+				"GITEA_MQ_GITHUB_WEBHOOK_SECRET": "s",
+			},
+			want: "GITEA_MQ_GITHUB_REPOS",
+		},
+		{
+			name: "topic with app",
+			env: map[string]string{
+				"GITEA_MQ_GITHUB_APP_ID":         "1",
+				"GITEA_MQ_GITHUB_PRIVATE_KEY":    "k",
+				"GITEA_MQ_GITHUB_WEBHOOK_SECRET": "s",
+				"GITEA_MQ_GITHUB_TOPIC":          "merge-queue",
+			},
+			want: "GITEA_MQ_GITHUB_TOPIC requires GITEA_MQ_GITHUB_TOKEN",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, with(tt.env))
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestLoad_GithubAppIDWithoutKeyFails(t *testing.T) {
 	setEnv(t, with(map[string]string{
 		"GITEA_MQ_GITHUB_APP_ID":         "12345",
@@ -237,3 +342,335 @@ func TestLoad_GiteaOnlyStillWorks(t *testing.T) {
 		t.Errorf("Repos() = %+v", cfg.Repos())
 	}
 }
+
+// Gitea and Forgejo are configured independently and their repos are kept
+// apart by kind.
+func TestLoad_GiteaAndForgejo(t *testing.T) {
+	setEnv(t, with(map[string]string{
+		"GITEA_MQ_GITEA_URL":              "https://gitea.example.com",
+		"GITEA_MQ_GITEA_TOKEN":            "tok",
+		"GITEA_MQ_WEBHOOK_SECRET":         "sec",
+		"GITEA_MQ_REPOS":                  "org/app",
+		"GITEA_MQ_FORGEJO_URL":            "https://forgejo.example.com/",
+		"GITEA_MQ_FORGEJO_TOKEN":          "ftok",
+		"GITEA_MQ_FORGEJO_WEBHOOK_SECRET": "fsec",
+		"GITEA_MQ_FORGEJO_REPOS":          "org/app",
+	}))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Forgejo == nil || cfg.Forgejo.URL != "https://forgejo.example.com" || cfg.Forgejo.Token != "ftok" || cfg.Forgejo.WebhookSecret != "fsec" {
+		t.Fatalf("Forgejo = %+v", cfg.Forgejo)
+	}
+	got := cfg.Repos()
+	if len(got) != 2 || got[0].String() != "gitea:org/app" || got[1].String() != "forgejo:org/app" {
+		t.Errorf("Repos() = %v", got)
+	}
+
+	setEnv(t, with(map[string]string{"GITEA_MQ_FORGEJO_URL": "https://forgejo.example.com"}))
+	_, err = Load()
+	if err == nil || !strings.Contains(err.Error(), "GITEA_MQ_FORGEJO_TOKEN") || !strings.Contains(err.Error(), "GITEA_MQ_FORGEJO_REPOS") {
+		t.Errorf("err = %v, want the missing Forgejo variables", err)
+	}
+}
+
+// Named Gitea instances sit next to the default one; their repos carry the
+// instance so equal owner/name pairs stay apart.
+func TestLoad_GiteaInstances(t *testing.T) {
+	setEnv(t, with(map[string]string{
+		"GITEA_MQ_GITEA_URL":                     "https://gitea.example.com",
+		"GITEA_MQ_GITEA_TOKEN":                   "tok",
+		"GITEA_MQ_WEBHOOK_SECRET":                "sec",
+		"GITEA_MQ_REPOS":                         "org/app",
+		"GITEA_MQ_GITEA_INSTANCES":               "internal, eu-west",
+		"GITEA_MQ_GITEA_INTERNAL_URL":            "https://git.corp/",
+		"GITEA_MQ_GITEA_INTERNAL_TOKEN":          "itok",
+		"GITEA_MQ_GITEA_INTERNAL_WEBHOOK_SECRET": "isec",
+		"GITEA_MQ_GITEA_INTERNAL_REPOS":          "org/app",
+		"GITEA_MQ_GITEA_EU_WEST_URL":             "https://eu.git.corp",
+		"GITEA_MQ_GITEA_EU_WEST_TOKEN":           "etok",
+		"GITEA_MQ_GITEA_EU_WEST_WEBHOOK_SECRET":  "esec",
+		"GITEA_MQ_GITEA_EU_WEST_TOPIC":           "merge-queue",
+	}))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.GiteaInstances) != 2 {
+		t.Fatalf("GiteaInstances = %+v", cfg.GiteaInstances)
+	}
+	in := cfg.GiteaInstances[0]
+	if in.Instance != "internal" || in.URL != "https://git.corp" || in.Token != "itok" || in.WebhookSecret != "isec" {
+		t.Errorf("internal = %+v", in)
+	}
+	if eu := cfg.GiteaInstances[1]; eu.Instance != "eu-west" || eu.Topic != "merge-queue" {
+		t.Errorf("eu-west = %+v", eu)
+	}
+	got := cfg.Repos()
+	if len(got) != 2 || got[0].String() != "gitea:org/app" || got[1].String() != "gitea@internal:org/app" {
+		t.Errorf("Repos() = %v", got)
+	}
+
+	setEnv(t, with(map[string]string{"GITEA_MQ_GITEA_INSTANCES": "internal"}))
+	_, err = Load()
+	if err == nil || !strings.Contains(err.Error(), "GITEA_MQ_GITEA_INTERNAL_URL") {
+		t.Errorf("err = %v, want the missing instance URL", err)
+	}
+
+	setEnv(t, with(map[string]string{"GITEA_MQ_GITEA_INSTANCES": "Internal"}))
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "invalid instance name") {
+		t.Errorf("err = %v, want invalid instance name", err)
+	}
+}
+
+func TestLoad_GitlabOnly(t *testing.T) {
+	setEnv(t, with(map[string]string{
+		"GITEA_MQ_GITLAB_URL":            "https://gitlab.example.com/",
+		"GITEA_MQ_GITLAB_TOKEN":          "gtok",
+		"GITEA_MQ_GITLAB_WEBHOOK_SECRET": "gsec",
+		"GITEA_MQ_GITLAB_TOPIC":          "merge-queue",
+	}))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Gitea != nil || cfg.Gitlab == nil || cfg.Gitlab.URL != "https://gitlab.example.com" || cfg.Gitlab.Topic != "merge-queue" {
+		t.Fatalf("Gitlab = %+v", cfg.Gitlab)
+	}
+
+	setEnv(t, with(map[string]string{
+		"GITEA_MQ_GITLAB_URL":            "https://gitlab.example.com",
+		"GITEA_MQ_GITLAB_TOKEN":          "gtok",
+		"GITEA_MQ_GITLAB_WEBHOOK_SECRET": "gsec",
+		"GITEA_MQ_GITLAB_REPOS":          "group/app",
+	}))
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Repos(); len(got) != 1 || got[0].String() != "gitlab:group/app" {
+		t.Errorf("Repos() = %v", got)
+	}
+}
+
+func TestLoad_SMTP(t *testing.T) {
+	setEnv(t, giteaEnv)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SMTP != nil {
+		t.Fatalf("SMTP = %+v, want nil when GITEA_MQ_SMTP_ADDR unset", cfg.SMTP)
+	}
+
+	t.Setenv("GITEA_MQ_SMTP_ADDR", "mail:587")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GITEA_MQ_SMTP_FROM") {
+		t.Fatalf("expected missing from error, got %v", err)
+	}
+
+	t.Setenv("GITEA_MQ_SMTP_FROM", "mq@example.com")
+	t.Setenv("GITEA_MQ_NOTIFY_DIGEST", "gitea:org/app=a@example.com, b@example.com; github:org/app=c@example.com")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.SMTP.NotifyAuthors || cfg.SMTP.DigestInterval != 24*time.Hour {
+		t.Errorf("defaults: NotifyAuthors=%v DigestInterval=%v", cfg.SMTP.NotifyAuthors, cfg.SMTP.DigestInterval)
+	}
+	gitea := forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app"}
+	github := forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"}
+	if got := cfg.SMTP.Digest[gitea]; len(got) != 2 || got[1] != "b@example.com" {
+		t.Errorf("Digest[%s] = %v", gitea, got)
+	}
+	if got := cfg.SMTP.Digest[github]; len(got) != 1 || got[0] != "c@example.com" {
+		t.Errorf("Digest[%s] = %v", github, got)
+	}
+}
+
+func TestLoad_Auth(t *testing.T) {
+	setEnv(t, giteaEnv)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth != nil {
+		t.Fatalf("Auth = %+v, want nil without OAuth client", cfg.Auth)
+	}
+
+	t.Setenv("GITEA_MQ_GITHUB_OAUTH_CLIENT_ID", "Iv1.abc")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GITEA_MQ_GITHUB_APP_ID") {
+		t.Fatalf("expected error for GitHub login without GitHub App, got %v", err)
+	}
+	t.Setenv("GITEA_MQ_GITHUB_OAUTH_CLIENT_ID", "")
+
+	t.Setenv("GITEA_MQ_GITEA_OAUTH_CLIENT_ID", "client")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GITEA_MQ_GITEA_OAUTH_CLIENT_SECRET") {
+		t.Fatalf("expected missing secret error, got %v", err)
+	}
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITEA_MQ_GITEA_OAUTH_CLIENT_SECRET_FILE", secret)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.GiteaClientSecret != "s3cret" || cfg.Auth.SessionKey != "" || cfg.Auth.CacheTTL != time.Minute {
+		t.Errorf("Auth = %+v", cfg.Auth)
+	}
+
+	t.Setenv("GITEA_MQ_AUTH_SESSION_KEY_FILE", secret)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.SessionKey != "s3cret" {
+		t.Errorf("SessionKey = %q, want s3cret", cfg.Auth.SessionKey)
+	}
+}
+
+func TestParseDigest_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"org/app=a@example.com", // forge prefix required
+		"gitea:org/app",
+		"gitea:org/app= ,",
+	} {
+		if _, err := parseDigest(s); err == nil {
+			t.Errorf("parseDigest(%q): expected error", s)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gitea-mq.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+database_url = "postgres://file"
+external_url = "https://mq.example.com/"
+poll_interval = "10s"
+required_checks = ["ci/build", "lint"]
+branch_patterns = ["release/*"]
+create_branch_protection = true
+teardown_on_remove = true
+batch_max = 4
+
+[gitea]
+url = "https://gitea"
+token = "t"
+webhook_secret = "s"
+repos = ["o/a", "o/b"]
+
+[gitea_instances.corp]
+url = "https://git.corp"
+token = "t2"
+webhook_secret = "s2"
+topic = "merge-queue"
+
+[smtp]
+addr = "smtp:25"
+from = "mq@example.com"
+notify_authors = false
+
+[smtp.digest]
+"gitea:o/a" = ["dev@example.com"]
+`)
+	setEnv(t, map[string]string{
+		"GITEA_MQ_CONFIG_FILE":   path,
+		"GITEA_MQ_POLL_INTERVAL": "20s",
+	})
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConfigFile != path || cfg.DatabaseURL != "postgres://file" || cfg.ExternalURL != "https://mq.example.com" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if cfg.PollInterval != 20*time.Second {
+		t.Errorf("PollInterval = %v, want the environment's 20s", cfg.PollInterval)
+	}
+	if strings.Join(cfg.RequiredChecks, ",") != "ci/build,lint" || cfg.BatchMax != 4 {
+		t.Errorf("RequiredChecks = %v, BatchMax = %d", cfg.RequiredChecks, cfg.BatchMax)
+	}
+	if strings.Join(cfg.BranchPatterns, ",") != "release/*" || !cfg.CreateProtection || !cfg.TeardownOnRemove {
+		t.Errorf("BranchPatterns = %v, CreateProtection = %v, TeardownOnRemove = %v",
+			cfg.BranchPatterns, cfg.CreateProtection, cfg.TeardownOnRemove)
+	}
+	if cfg.Gitea == nil || len(cfg.Gitea.Repos) != 2 {
+		t.Fatalf("Gitea = %+v", cfg.Gitea)
+	}
+	if len(cfg.GiteaInstances) != 1 || cfg.GiteaInstances[0].Instance != "corp" || cfg.GiteaInstances[0].Topic != "merge-queue" {
+		t.Errorf("GiteaInstances = %+v", cfg.GiteaInstances)
+	}
+	ref := forge.RepoRef{Forge: forge.KindGitea, Owner: "o", Name: "a"}
+	if cfg.SMTP == nil || cfg.SMTP.NotifyAuthors || len(cfg.SMTP.Digest[ref]) != 1 {
+		t.Errorf("SMTP = %+v", cfg.SMTP)
+	}
+}
+
+func TestLoad_ConfigFileErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":      "databse_url = \"postgres://x\"\n",
+		"syntax":           "database_url = \n",
+		"invalid duration": "poll_interval = \"soon\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			setEnv(t, with(map[string]string{
+				"GITEA_MQ_CONFIG_FILE":    writeConfigFile(t, content),
+				"GITEA_MQ_GITEA_URL":      "https://gitea",
+				"GITEA_MQ_GITEA_TOKEN":    "t",
+				"GITEA_MQ_WEBHOOK_SECRET": "s",
+				"GITEA_MQ_REPOS":          "o/r",
+			}))
+			if _, err := Load(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	setEnv(t, map[string]string{"GITEA_MQ_CONFIG_FILE": filepath.Join(t.TempDir(), "missing.toml")})
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GITEA_MQ_CONFIG_FILE") {
+		t.Fatalf("expected missing file error, got %v", err)
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	setEnv(t, giteaEnv)
+	old, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GITEA_MQ_REPOS", "o/r,o/s")
+	t.Setenv("GITEA_MQ_POLL_INTERVAL", "5s")
+	t.Setenv("GITEA_MQ_BATCH_MAX", "3")
+	live, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := RestartRequired(old, live); len(got) != 0 {
+		t.Errorf("RestartRequired = %v, want none for repos and tunables", got)
+	}
+
+	t.Setenv("GITEA_MQ_GITEA_TOKEN", "rotated")
+	t.Setenv("GITEA_MQ_LISTEN_ADDR", ":9090")
+	restart, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(RestartRequired(old, restart), ","); got != "Gitea,ListenAddr" {
+		t.Errorf("RestartRequired = %q, want Gitea,ListenAddr", got)
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// env looks up settings: the process environment first, then the config
+// file flattened to the same variable names, so every validation and
+// default applies to both alike.
+type env struct {
+	file map[string]string
+}
+
+func (e env) get(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return e.fromFile(key)
+}
+
+func (e env) fromFile(key string) string { return e.file[key] }
+
+// fileConfig is the TOML layout of GITEA_MQ_CONFIG_FILE. It mirrors Config;
+// each key stands for the environment variable named in its comment.
+type fileConfig struct {
+	DatabaseURL         string   `toml:"database_url"`             // GITEA_MQ_DATABASE_URL
+	ListenAddr          string   `toml:"listen_addr"`              // GITEA_MQ_LISTEN_ADDR
+	WebhookPath         string   `toml:"webhook_path"`             // GITEA_MQ_WEBHOOK_PATH
+	ExternalURL         string   `toml:"external_url"`             // GITEA_MQ_EXTERNAL_URL
+	PollInterval        string   `toml:"poll_interval"`            // GITEA_MQ_POLL_INTERVAL
+	IdlePollInterval    string   `toml:"idle_poll_interval"`       // GITEA_MQ_IDLE_POLL_INTERVAL
+	CheckTimeout        string   `toml:"check_timeout"`            // GITEA_MQ_CHECK_TIMEOUT
+	RequiredChecks      []string `toml:"required_checks"`          // GITEA_MQ_REQUIRED_CHECKS
+	BranchPatterns      []string `toml:"branch_patterns"`          // GITEA_MQ_BRANCH_PATTERNS
+	CreateProtection    *bool    `toml:"create_branch_protection"` // GITEA_MQ_CREATE_BRANCH_PROTECTION
+	TeardownOnRemove    *bool    `toml:"teardown_on_remove"`       // GITEA_MQ_TEARDOWN_ON_REMOVE
+	SkipQueueIfUpToDate *bool    `toml:"skip_queue_if_up_to_date"` // GITEA_MQ_SKIP_QUEUE_IF_UP_TO_DATE
+	BatchMax            *int     `toml:"batch_max"`                // GITEA_MQ_BATCH_MAX
+	BisectMaxSteps      *int     `toml:"bisect_max_steps"`         // GITEA_MQ_BISECT_MAX_STEPS
+	ShadowRepos         []string `toml:"shadow_repos"`             // GITEA_MQ_SHADOW_REPOS
+	RefreshInterval     string   `toml:"refresh_interval"`         // GITEA_MQ_REFRESH_INTERVAL
+	DiscoveryInterval   string   `toml:"discovery_interval"`       // GITEA_MQ_DISCOVERY_INTERVAL
+	LeaderCheckInterval string   `toml:"leader_check_interval"`    // GITEA_MQ_LEADER_CHECK_INTERVAL
+	WebhookRetention    string   `toml:"webhook_retention"`        // GITEA_MQ_WEBHOOK_RETENTION
+	AdminToken          string   `toml:"admin_token"`              // GITEA_MQ_ADMIN_TOKEN
+	AdminTokenFile      string   `toml:"admin_token_file"`         // GITEA_MQ_ADMIN_TOKEN_FILE
+	LogLevel            string   `toml:"log_level"`                // GITEA_MQ_LOG_LEVEL
+	CacheDir            string   `toml:"cache_dir"`                // GITEA_MQ_CACHE_DIR
+
+	Gitea          *fileGitea           `toml:"gitea"`
+	GiteaInstances map[string]fileGitea `toml:"gitea_instances"` // GITEA_MQ_GITEA_INSTANCES
+	Forgejo        *fileGitea           `toml:"forgejo"`
+	Gitlab         *fileGitea           `toml:"gitlab"`
+	Github         *fileGithub          `toml:"github"`
+	SMTP           *fileSMTP            `toml:"smtp"`
+	Auth           *fileAuth            `toml:"auth"`
+}
+
+type fileGitea struct {
+	URL           string   `toml:"url"`
+	Token         string   `toml:"token"`
+	WebhookSecret string   `toml:"webhook_secret"`
+	Topic         string   `toml:"topic"`
+	Repos         []string `toml:"repos"`
+}
+
+type fileGithub struct {
+	AppID          int64    `toml:"app_id"`
+	PrivateKey     string   `toml:"private_key"`
+	PrivateKeyFile string   `toml:"private_key_file"`
+	Token          string   `toml:"token"`
+	TokenFile      string   `toml:"token_file"`
+	Topic          string   `toml:"topic"`
+	WebhookSecret  string   `toml:"webhook_secret"`
+	Repos          []string `toml:"repos"`
+	URL            string   `toml:"url"`
+	APIURL         string   `toml:"api_url"`
+	UploadURL      string   `toml:"upload_url"`
+	GraphQLURL     string   `toml:"graphql_url"`
+	PollInterval   string   `toml:"poll_interval"`
+}
+
+type fileSMTP struct {
+	Addr           string              `toml:"addr"`
+	Username       string              `toml:"username"`
+	Password       string              `toml:"password"`
+	PasswordFile   string              `toml:"password_file"`
+	From           string              `toml:"from"`
+	NotifyAuthors  *bool               `toml:"notify_authors"`
+	Digest         map[string][]string `toml:"digest"`
+	DigestInterval string              `toml:"digest_interval"`
+	TemplateDir    string              `toml:"template_dir"`
+}
+
+type fileAuth struct {
+	GiteaClientID          string `toml:"gitea_client_id"`
+	GiteaClientSecret      string `toml:"gitea_client_secret"`
+	GiteaClientSecretFile  string `toml:"gitea_client_secret_file"`
+	GithubClientID         string `toml:"github_client_id"`
+	GithubClientSecret     string `toml:"github_client_secret"`
+	GithubClientSecretFile string `toml:"github_client_secret_file"`
+	SessionKey             string `toml:"session_key"`
+	SessionKeyFile         string `toml:"session_key_file"`
+	CacheTTL               string `toml:"cache_ttl"`
+}
+
+// readFile parses the TOML config file into environment variable names.
+// Unknown keys are an error so a typo does not silently fall back to a
+// default.
+func readFile(path string) (map[string]string, error) {
+	var fc fileConfig
+	md, err := toml.DecodeFile(path, &fc)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		return nil, fmt.Errorf("%s: unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+	return fc.vars(), nil
+}
+
+func (fc *fileConfig) vars() map[string]string {
+	out := map[string]string{}
+	set := func(key, v string) {
+		if v != "" {
+			out[key] = v
+		}
+	}
+	list := func(key string, vs []string) { set(key, strings.Join(vs, ",")) }
+	setBool := func(key string, b *bool) {
+		if b != nil {
+			out[key] = strconv.FormatBool(*b)
+		}
+	}
+	setInt := func(key string, n *int) {
+		if n != nil {
+			out[key] = strconv.Itoa(*n)
+		}
+	}
+	gitea := func(vars giteaVars, g *fileGitea) {
+		if g == nil {
+			return
+		}
+		set(vars.url, g.URL)
+		set(vars.token, g.Token)
+		set(vars.webhookSecret, g.WebhookSecret)
+		set(vars.topic, g.Topic)
+		list(vars.repos, g.Repos)
+	}
+
+	set("GITEA_MQ_DATABASE_URL", fc.DatabaseURL)
+	set("GITEA_MQ_LISTEN_ADDR", fc.ListenAddr)
+	set("GITEA_MQ_WEBHOOK_PATH", fc.WebhookPath)
+	set("GITEA_MQ_EXTERNAL_URL", fc.ExternalURL)
+	set("GITEA_MQ_POLL_INTERVAL", fc.PollInterval)
+	set("GITEA_MQ_IDLE_POLL_INTERVAL", fc.IdlePollInterval)
+	set("GITEA_MQ_CHECK_TIMEOUT", fc.CheckTimeout)
+	list("GITEA_MQ_REQUIRED_CHECKS", fc.RequiredChecks)
+	list("GITEA_MQ_BRANCH_PATTERNS", fc.BranchPatterns)
+	setBool("GITEA_MQ_CREATE_BRANCH_PROTECTION", fc.CreateProtection)
+	setBool("GITEA_MQ_TEARDOWN_ON_REMOVE", fc.TeardownOnRemove)
+	setBool("GITEA_MQ_SKIP_QUEUE_IF_UP_TO_DATE", fc.SkipQueueIfUpToDate)
+	setInt("GITEA_MQ_BATCH_MAX", fc.BatchMax)
+	setInt("GITEA_MQ_BISECT_MAX_STEPS", fc.BisectMaxSteps)
+	list("GITEA_MQ_SHADOW_REPOS", fc.ShadowRepos)
+	set("GITEA_MQ_REFRESH_INTERVAL", fc.RefreshInterval)
+	set("GITEA_MQ_DISCOVERY_INTERVAL", fc.DiscoveryInterval)
+	set("GITEA_MQ_LEADER_CHECK_INTERVAL", fc.LeaderCheckInterval)
+	set("GITEA_MQ_WEBHOOK_RETENTION", fc.WebhookRetention)
+	set("GITEA_MQ_ADMIN_TOKEN", fc.AdminToken)
+	set("GITEA_MQ_ADMIN_TOKEN_FILE", fc.AdminTokenFile)
+	set("GITEA_MQ_LOG_LEVEL", fc.LogLevel)
+	set("GITEA_MQ_CACHE_DIR", fc.CacheDir)
+
+	gitea(giteaNames, fc.Gitea)
+	gitea(forgejoNames, fc.Forgejo)
+	gitea(gitlabNames, fc.Gitlab)
+	names := make([]string, 0, len(fc.GiteaInstances))
+	for name := range fc.GiteaInstances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list("GITEA_MQ_GITEA_INSTANCES", names)
+	for _, name := range names {
+		g := fc.GiteaInstances[name]
+		gitea(giteaInstanceNames(name), &g)
+	}
+
+	if g := fc.Github; g != nil {
+		if g.AppID != 0 {
+			out["GITEA_MQ_GITHUB_APP_ID"] = strconv.FormatInt(g.AppID, 10)
+		}
+		set("GITEA_MQ_GITHUB_PRIVATE_KEY", g.PrivateKey)
+		set("GITEA_MQ_GITHUB_PRIVATE_KEY_FILE", g.PrivateKeyFile)
+		set("GITEA_MQ_GITHUB_TOKEN", g.Token)
+		set("GITEA_MQ_GITHUB_TOKEN_FILE", g.TokenFile)
+		set("GITEA_MQ_GITHUB_TOPIC", g.Topic)
+		set("GITEA_MQ_GITHUB_WEBHOOK_SECRET", g.WebhookSecret)
+		list("GITEA_MQ_GITHUB_REPOS", g.Repos)
+		set("GITEA_MQ_GITHUB_URL", g.URL)
+		set("GITEA_MQ_GITHUB_API_URL", g.APIURL)
+		set("GITEA_MQ_GITHUB_UPLOAD_URL", g.UploadURL)
+		set("GITEA_MQ_GITHUB_GRAPHQL_URL", g.GraphQLURL)
+		set("GITEA_MQ_GITHUB_POLL_INTERVAL", g.PollInterval)
+	}
+
+	if s := fc.SMTP; s != nil {
+		set("GITEA_MQ_SMTP_ADDR", s.Addr)
+		set("GITEA_MQ_SMTP_USERNAME", s.Username)
+		set("GITEA_MQ_SMTP_PASSWORD", s.Password)
+		set("GITEA_MQ_SMTP_PASSWORD_FILE", s.PasswordFile)
+		set("GITEA_MQ_SMTP_FROM", s.From)
+		setBool("GITEA_MQ_NOTIFY_AUTHORS", s.NotifyAuthors)
+		repos := make([]string, 0, len(s.Digest))
+		for repo := range s.Digest {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		entries := make([]string, len(repos))
+		for i, repo := range repos {
+			entries[i] = repo + "=" + strings.Join(s.Digest[repo], ",")
+		}
+		set("GITEA_MQ_NOTIFY_DIGEST", strings.Join(entries, ";"))
+		set("GITEA_MQ_NOTIFY_DIGEST_INTERVAL", s.DigestInterval)
+		set("GITEA_MQ_NOTIFY_TEMPLATE_DIR", s.TemplateDir)
+	}
+
+	if a := fc.Auth; a != nil {
+		set("GITEA_MQ_GITEA_OAUTH_CLIENT_ID", a.GiteaClientID)
+		set("GITEA_MQ_GITEA_OAUTH_CLIENT_SECRET", a.GiteaClientSecret)
+		set("GITEA_MQ_GITEA_OAUTH_CLIENT_SECRET_FILE", a.GiteaClientSecretFile)
+		set("GITEA_MQ_GITHUB_OAUTH_CLIENT_ID", a.GithubClientID)
+		set("GITEA_MQ_GITHUB_OAUTH_CLIENT_SECRET", a.GithubClientSecret)
+		set("GITEA_MQ_GITHUB_OAUTH_CLIENT_SECRET_FILE", a.GithubClientSecretFile)
+		set("GITEA_MQ_AUTH_SESSION_KEY", a.SessionKey)
+		set("GITEA_MQ_AUTH_SESSION_KEY_FILE", a.SessionKeyFile)
+		set("GITEA_MQ_AUTH_CACHE_TTL", a.CacheTTL)
+	}
+	return out
+}
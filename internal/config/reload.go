@@ -0,0 +1,66 @@
+package config
+
+import (
+	"reflect"
+)
+
+// liveFields are the Config fields a running service applies on reload. The
+// forges' repo lists are live too; everything else (connections, secrets,
+// listeners) is read once at startup.
+var liveFields = map[string]bool{
+	"PollInterval":        true,
+	"IdlePollInterval":    true,
+	"CheckTimeout":        true,
+	"RequiredChecks":      true,
+	"SkipQueueIfUpToDate": true,
+	"BatchMax":            true,
+	"BisectMaxSteps":      true,
+	"LogLevel":            true,
+}
+
+// RestartRequired names the fields that differ between old and new but only
+// take effect after a restart.
+func RestartRequired(old, new *Config) []string {
+	a, b := withoutRepos(old), withoutRepos(new)
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var out []string
+	for i := range va.NumField() {
+		name := va.Type().Field(i).Name
+		if liveFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// withoutRepos returns a copy of c with the forges' live settings cleared so
+// the rest can be compared.
+func withoutRepos(c *Config) *Config {
+	cp := *c
+	stripGitea := func(gc *GiteaConfig) *GiteaConfig {
+		if gc == nil {
+			return nil
+		}
+		g := *gc
+		g.Repos = nil
+		return &g
+	}
+	cp.Gitea = stripGitea(c.Gitea)
+	cp.Forgejo = stripGitea(c.Forgejo)
+	cp.Gitlab = stripGitea(c.Gitlab)
+	cp.GiteaInstances = nil
+	for _, gc := range c.GiteaInstances {
+		cp.GiteaInstances = append(cp.GiteaInstances, stripGitea(gc))
+	}
+	if c.Github != nil {
+		g := *c.Github
+		g.Repos = nil
+		// Follows the global poll interval unless overridden.
+		g.PollInterval = 0
+		cp.Github = &g
+	}
+	return &cp
+}
@@ -7,15 +7,16 @@ import (
 	"context"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/registry"
 )
 
-// Source enumerates repos a forge wants managed.
+// Source enumerates repos a forge server wants managed.
 type Source struct {
-	Kind forge.Kind
+	Host forge.Host
 	List func(ctx context.Context) ([]forge.RepoRef, error)
 }
 
@@ -26,11 +27,29 @@ type Deps struct {
 	// Trigger fires an immediate cycle in addition to the interval, e.g. on
 	// an installation webhook.
 	Trigger <-chan struct{}
+
+	mu sync.Mutex // guards ExplicitRepos after SetExplicitRepos
+}
+
+// SetExplicitRepos replaces the configured repo list, e.g. on a config
+// reload. The next cycle adds new repos and removes dropped ones unless a
+// source still lists them.
+func (d *Deps) SetExplicitRepos(refs []forge.RepoRef) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ExplicitRepos = refs
+}
+
+func (d *Deps) explicitRepos() []forge.RepoRef {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ExplicitRepos
 }
 
 func DiscoverOnce(ctx context.Context, deps *Deps) {
-	explicit := make(map[string]forge.RepoRef, len(deps.ExplicitRepos))
-	for _, r := range deps.ExplicitRepos {
+	refs := deps.explicitRepos()
+	explicit := make(map[string]forge.RepoRef, len(refs))
+	for _, r := range refs {
 		explicit[r.String()] = r
 	}
 	// Explicit repos are added regardless of whether their forge has a
@@ -43,7 +62,7 @@ func DiscoverOnce(ctx context.Context, deps *Deps) {
 		if err != nil {
 			// Do not reconcile this forge: removing repos because the API is
 			// down would amplify an outage into queue loss.
-			slog.Warn("discovery: source failed; keeping current set", "forge", src.Kind, "err", err)
+			slog.Warn("discovery: source failed; keeping current set", "forge", src.Host, "err", err)
 			continue
 		}
 		desired := map[string]forge.RepoRef{}
@@ -52,7 +71,7 @@ func DiscoverOnce(ctx context.Context, deps *Deps) {
 		}
 		addNew(ctx, deps.Registry, desired)
 
-		prefix := string(src.Kind) + ":"
+		prefix := src.Host.String() + ":"
 		for key := range deps.Registry.Keys() {
 			if !strings.HasPrefix(key, prefix) {
 				continue
@@ -68,7 +87,25 @@ func DiscoverOnce(ctx context.Context, deps *Deps) {
 				deps.Registry.Remove(ref)
 			}
 		}
-		slog.Info("discovery: reconciled", "forge", src.Kind, "managed", len(desired))
+		slog.Info("discovery: reconciled", "forge", src.Host, "managed", len(desired))
+	}
+
+	// A forge without a source manages exactly its explicit repos, so one
+	// dropped from the list goes away.
+	sourced := make(map[forge.Host]bool, len(deps.Sources))
+	for _, src := range deps.Sources {
+		sourced[src.Host] = true
+	}
+	for key := range deps.Registry.Keys() {
+		ref, ok := forge.ParseRepoRef(key)
+		if !ok || sourced[ref.Host()] {
+			continue
+		}
+		if _, ok := explicit[key]; ok {
+			continue
+		}
+		slog.Info("discovery: removing repo", "repo", key)
+		deps.Registry.Remove(ref)
 	}
 }
 
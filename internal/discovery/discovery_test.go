@@ -22,6 +22,7 @@ func newTestSetup(t *testing.T) (*registry.RepoRegistry, *gitea.MockClient, cont
 	mock := &gitea.MockClient{}
 	forges := forge.NewSet()
 	forges.Register(gitea.NewForge(mock, "https://gitea.example.com"))
+	forges.Register(gitea.NewNamedForge("internal", mock, "https://git.corp"))
 	forges.Register(&forge.MockForge{KindVal: forge.KindGithub})
 	reg := registry.New(ctx, &registry.Deps{
 		Forges:         forges,
@@ -34,7 +35,7 @@ func newTestSetup(t *testing.T) (*registry.RepoRegistry, *gitea.MockClient, cont
 }
 
 func giteaSrc(mock *gitea.MockClient) discovery.Source {
-	return discovery.Source{Kind: forge.KindGitea, List: gitea.TopicSource(mock, "merge-queue")}
+	return discovery.Source{Host: forge.Host{Kind: forge.KindGitea}, List: gitea.TopicSource(mock, forge.Host{Kind: forge.KindGitea}, "merge-queue")}
 }
 
 func TestDiscoverOnce_TopicMatching_AdminFilter(t *testing.T) {
@@ -94,7 +95,7 @@ func TestDiscoverOnce_SourceErrorIsolated(t *testing.T) {
 		}, nil
 	}
 	ghRefs := []forge.RepoRef{{Forge: forge.KindGithub, Owner: "gh", Name: "proj"}}
-	ghSrc := discovery.Source{Kind: forge.KindGithub, List: func(context.Context) ([]forge.RepoRef, error) { return ghRefs, nil }}
+	ghSrc := discovery.Source{Host: forge.Host{Kind: forge.KindGithub}, List: func(context.Context) ([]forge.RepoRef, error) { return ghRefs, nil }}
 	deps := &discovery.Deps{Registry: reg, Sources: []discovery.Source{giteaSrc(mock), ghSrc}}
 
 	discovery.DiscoverOnce(ctx, deps)
@@ -116,3 +117,109 @@ func TestDiscoverOnce_SourceErrorIsolated(t *testing.T) {
 		t.Error("github repo not removed despite healthy empty source")
 	}
 }
+
+// Sources of two Gitea servers reconcile only their own repos, even where
+// owner/name coincide.
+func TestDiscoverOnce_InstancesReconcileSeparately(t *testing.T) {
+	reg, mock, ctx := newTestSetup(t)
+	mock.SearchReposByTopicFn = func(_ context.Context, _ string) ([]gitea.Repo, error) {
+		return []gitea.Repo{
+			{FullName: "org/app", Owner: gitea.RepoOwner{Login: "org"}, Name: "app", Permissions: gitea.RepoPermissions{Admin: true}},
+		}, nil
+	}
+	internal := forge.Host{Kind: forge.KindGitea, Instance: "internal"}
+	internalRefs := []forge.RepoRef{{Forge: forge.KindGitea, Instance: "internal", Owner: "org", Name: "app"}}
+	internalSrc := discovery.Source{Host: internal, List: func(context.Context) ([]forge.RepoRef, error) { return internalRefs, nil }}
+	deps := &discovery.Deps{Registry: reg, Sources: []discovery.Source{giteaSrc(mock), internalSrc}}
+
+	discovery.DiscoverOnce(ctx, deps)
+	if !reg.Contains("gitea:org/app") || !reg.Contains("gitea@internal:org/app") {
+		t.Fatal("seed failed")
+	}
+
+	internalRefs = nil
+	discovery.DiscoverOnce(ctx, deps)
+	if !reg.Contains("gitea:org/app") {
+		t.Error("default instance repo evicted by the internal source")
+	}
+	if reg.Contains("gitea@internal:org/app") {
+		t.Error("internal repo not removed despite healthy empty source")
+	}
+}
+
+// Without a source the explicit list is the whole truth for a forge: a repo
+// dropped from it on reload goes away, other forges' repos stay.
+func TestDiscoverOnce_DroppedExplicitRepoRemoved(t *testing.T) {
+	reg, mock, ctx := newTestSetup(t)
+	mock.SearchReposByTopicFn = func(_ context.Context, _ string) ([]gitea.Repo, error) {
+		return []gitea.Repo{
+			{FullName: "org/app", Owner: gitea.RepoOwner{Login: "org"}, Name: "app", Permissions: gitea.RepoPermissions{Admin: true}},
+		}, nil
+	}
+	deps := &discovery.Deps{
+		Registry: reg,
+		Sources:  []discovery.Source{giteaSrc(mock)},
+		ExplicitRepos: []forge.RepoRef{
+			{Forge: forge.KindGithub, Owner: "gh", Name: "a"},
+			{Forge: forge.KindGithub, Owner: "gh", Name: "b"},
+		},
+	}
+
+	discovery.DiscoverOnce(ctx, deps)
+	if !reg.Contains("github:gh/a") || !reg.Contains("github:gh/b") || !reg.Contains("gitea:org/app") {
+		t.Fatal("seed failed")
+	}
+
+	deps.SetExplicitRepos([]forge.RepoRef{{Forge: forge.KindGithub, Owner: "gh", Name: "b"}})
+	discovery.DiscoverOnce(ctx, deps)
+	if reg.Contains("github:gh/a") {
+		t.Error("dropped explicit repo not removed")
+	}
+	if !reg.Contains("github:gh/b") || !reg.Contains("gitea:org/app") {
+		t.Error("remaining repos must stay")
+	}
+}
+
+// Forge setup is only torn down for a repo a healthy source stopped
+// listing: neither an outage nor an explicit listing strips it.
+func TestDiscoverOnce_TeardownOnlyWhenUnlisted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	gh := &forge.MockForge{KindVal: forge.KindGithub}
+	forges := forge.NewSet()
+	forges.Register(gh)
+	reg := registry.New(ctx, &registry.Deps{
+		Forges:           forges,
+		Queue:            queue.NewService(testutil.TestDB(t)),
+		PollInterval:     time.Hour,
+		CheckTimeout:     time.Hour,
+		SuccessTimeout:   5 * time.Minute,
+		TeardownOnRemove: true,
+	})
+
+	var listErr error
+	refs := []forge.RepoRef{{Forge: forge.KindGithub, Owner: "gh", Name: "a"}, {Forge: forge.KindGithub, Owner: "gh", Name: "b"}}
+	src := discovery.Source{Host: forge.Host{Kind: forge.KindGithub}, List: func(context.Context) ([]forge.RepoRef, error) { return refs, listErr }}
+	deps := &discovery.Deps{
+		Registry:      reg,
+		Sources:       []discovery.Source{src},
+		ExplicitRepos: []forge.RepoRef{{Forge: forge.KindGithub, Owner: "gh", Name: "b"}},
+	}
+	discovery.DiscoverOnce(ctx, deps)
+
+	refs, listErr = nil, fmt.Errorf("connection refused")
+	discovery.DiscoverOnce(ctx, deps)
+	if calls := gh.CallsTo("TeardownRepoSetup"); len(calls) != 0 {
+		t.Fatalf("torn down while the source is down: %+v", calls)
+	}
+
+	listErr = nil
+	discovery.DiscoverOnce(ctx, deps)
+	calls := gh.CallsTo("TeardownRepoSetup")
+	if len(calls) != 1 || calls[0].Args[1] != "a" {
+		t.Fatalf("teardown calls = %+v, want one for gh/a", calls)
+	}
+	if !reg.Contains("github:gh/b") {
+		t.Error("explicit repo must stay")
+	}
+}
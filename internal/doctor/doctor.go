@@ -0,0 +1,75 @@
+// Package doctor runs preflight diagnostics on a managed repo: what the
+// forge adapter can check about permissions, branch protection, webhooks,
+// push access and auto-merge, plus whether CI has ever reported on the
+// repo's merge branches.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/merge"
+	"github.com/Mic92/gitea-mq/internal/queue"
+)
+
+// CICheck is the name of the check that CI runs on merge branches.
+const CICheck = "ci"
+
+// Report is the diagnosis of one repo.
+type Report struct {
+	Repo   forge.RepoRef
+	Checks []forge.DoctorCheck
+}
+
+// Failed reports whether any check failed.
+func (r Report) Failed() bool {
+	return slices.ContainsFunc(r.Checks, func(c forge.DoctorCheck) bool {
+		return c.Status == forge.DoctorFail
+	})
+}
+
+// Run diagnoses ref on f. repoID selects the repo's CI history and, unless
+// cfg names them, the target branches of its queue.
+func Run(ctx context.Context, f forge.Forge, svc *queue.Service, ref forge.RepoRef, repoID int64, cfg forge.SetupConfig) Report {
+	r := Report{Repo: ref}
+	if cfg.TargetBranches == nil {
+		// Best effort: without them only the default branch is checked.
+		cfg.TargetBranches, _ = svc.TargetBranches(ctx, repoID)
+	}
+	if d, ok := f.(forge.Doctor); ok {
+		r.Checks = d.Diagnose(ctx, ref.Owner, ref.Name, cfg)
+	} else {
+		r.Checks = append(r.Checks, forge.DoctorWarning("forge",
+			fmt.Sprintf("the %s adapter cannot check the repo setup", ref.Host()),
+			"check permissions, branch protection and webhooks by hand"))
+	}
+	r.Checks = append(r.Checks, ciCheck(ctx, svc, repoID))
+	return r
+}
+
+// ciCheck looks for CI results on merge branches in the duration history.
+// The forge cannot tell whether CI is configured for branches that do not
+// exist yet, so a repo that has never queued anything only gets a warning.
+func ciCheck(ctx context.Context, svc *queue.Service, repoID int64) forge.DoctorCheck {
+	durations, err := svc.CheckDurations(ctx, repoID)
+	if err != nil {
+		return forge.DoctorWarning(CICheck, "cannot read the CI history: "+err.Error(), "")
+	}
+	var contexts []string
+	for c := range durations {
+		if !forge.IsOwnContext(c) {
+			contexts = append(contexts, c)
+		}
+	}
+	if len(contexts) == 0 {
+		days := int(queue.DurationHistory.Hours() / 24)
+		return forge.DoctorWarning(CICheck,
+			fmt.Sprintf("no CI result on a %s* branch in the last %d days", merge.BranchPrefix, days),
+			fmt.Sprintf("make sure CI runs on pushes to %s* branches", merge.BranchPrefix))
+	}
+	slices.Sort(contexts)
+	return forge.DoctorPassed(CICheck, fmt.Sprintf("CI reported %s on merge branches", strings.Join(contexts, ", ")))
+}
@@ -0,0 +1,214 @@
+// Package eta predicts when queued PRs land. The model is deliberately
+// simple: every build takes the repo's median build time, PRs are taken in
+// FIFO order BatchMax at a time, and a batch that fails costs the extra
+// builds its bisection needs in proportion to the recent failure rate.
+package eta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// recentBuilds is how many of the latest builds feed the build time and
+// failure rate, so the estimate follows changes to the CI setup.
+const recentBuilds = 50
+
+// Model holds the inputs of a prediction.
+type Model struct {
+	BuildTime   time.Duration // median duration of one merge-branch build
+	FailureRate float64       // share of recent builds that failed, 0..1
+	BatchMax    int           // 1 = no batching, 0 = everything queued
+}
+
+// Load derives the model for a repo from its recorded history. Without
+// finished builds the slowest per-context median stands in for the build
+// time; a repo without any history gets a zero BuildTime, which disables
+// predictions.
+func Load(ctx context.Context, q *queue.Service, repoID int64, batchMax int) (Model, error) {
+	m := Model{BatchMax: batchMax}
+	builds, err := q.RecentBuilds(ctx, repoID, recentBuilds)
+	if err != nil {
+		return m, fmt.Errorf("load build history: %w", err)
+	}
+	if len(builds) > 0 {
+		durations := make([]time.Duration, len(builds))
+		failed := 0
+		for i, b := range builds {
+			durations[i] = time.Duration(b.DurationMs) * time.Millisecond
+			if !b.Passed {
+				failed++
+			}
+		}
+		m.BuildTime = median(durations)
+		m.FailureRate = float64(failed) / float64(len(builds))
+		return m, nil
+	}
+
+	checks, err := q.CheckDurations(ctx, repoID)
+	if err != nil {
+		return m, fmt.Errorf("load check durations: %w", err)
+	}
+	// Contexts run in parallel: the slowest one decides.
+	for _, d := range checks {
+		m.BuildTime = max(m.BuildTime, d)
+	}
+	return m, nil
+}
+
+// ForRepo predicts the landing time of every active entry of a repo.
+func ForRepo(ctx context.Context, q *queue.Service, repoID int64, batchMax int, now time.Time) (map[int64]time.Time, error) {
+	m, err := Load(ctx, q, repoID, batchMax)
+	if err != nil || m.BuildTime <= 0 {
+		return nil, err
+	}
+	entries, err := q.ListActiveEntries(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	batches, err := q.ListLiveBatches(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	return m.Predict(entries, batches, now), nil
+}
+
+// Predict returns the expected landing time per PR number. entries are the
+// repo's active entries in queue order (as from ListActiveEntries), batches
+// its live batches. Target branches are independent queues.
+func (m Model) Predict(entries []pg.QueueEntry, batches []pg.Batch, now time.Time) map[int64]time.Time {
+	out := make(map[int64]time.Time, len(entries))
+	if m.BuildTime <= 0 {
+		return out
+	}
+
+	byID := make(map[int64]*pg.QueueEntry, len(entries))
+	var branches []string
+	for i := range entries {
+		e := &entries[i]
+		byID[e.ID] = e
+		if !slices.Contains(branches, e.TargetBranch) {
+			branches = append(branches, e.TargetBranch)
+		}
+	}
+
+	for _, branch := range branches {
+		t := now
+		members := make(map[int64]bool)
+
+		// The live batch goes first: its current members land when the
+		// running build passes, each pending bisection slice one build later.
+		for _, b := range batches {
+			if b.TargetBranch != branch {
+				continue
+			}
+			t = m.finish(b.TestingStartedAt.Time, b.TestingStartedAt.Valid, now)
+			land := func(ids []int64) {
+				for _, id := range ids {
+					members[id] = true
+					if e := byID[id]; e != nil {
+						out[e.PrNumber] = t
+					}
+				}
+			}
+			land(b.CurrentIds)
+			var pending [][]int64 // stack of bisection halves, popped from the end
+			_ = json.Unmarshal(b.Pending, &pending)
+			for i := len(pending) - 1; i >= 0; i-- {
+				t = t.Add(m.BuildTime)
+				land(pending[i])
+			}
+		}
+
+		var queued []*pg.QueueEntry
+		for i := range entries {
+			e := &entries[i]
+			if e.TargetBranch != branch || members[e.ID] {
+				continue
+			}
+			switch e.State {
+			case pg.EntryStateSuccess:
+				out[e.PrNumber] = now // waiting for the forge to merge
+			case pg.EntryStateTesting:
+				t = m.finish(e.TestingStartedAt.Time, e.TestingStartedAt.Valid, now)
+				out[e.PrNumber] = t
+			default:
+				queued = append(queued, e)
+			}
+		}
+
+		size := m.BatchMax
+		if size <= 0 {
+			size = max(len(queued), 1)
+		}
+		for chunk := range slices.Chunk(queued, size) {
+			t = t.Add(m.batchCost(len(chunk)))
+			for _, e := range chunk {
+				out[e.PrNumber] = t
+			}
+		}
+	}
+	return out
+}
+
+// finish is when a build started at start (now when unknown) should be done.
+// Builds running over the estimate are assumed to finish any moment.
+func (m Model) finish(start time.Time, valid bool, now time.Time) time.Time {
+	if !valid {
+		start = now
+	}
+	return maxTime(start.Add(m.BuildTime), now)
+}
+
+// batchCost is the expected time to land a batch of n PRs: one build, plus,
+// with probability FailureRate, the log2(n) builds bisection needs to find
+// the culprit.
+func (m Model) batchCost(n int) time.Duration {
+	builds := 1.0
+	if n > 1 {
+		builds += m.FailureRate * float64(bits.Len(uint(n-1)))
+	}
+	return time.Duration(math.Round(builds * float64(m.BuildTime)))
+}
+
+// Format renders at relative to now for status descriptions and the
+// dashboard, e.g. "~25m" or "~1h05m".
+func Format(at, now time.Time) string {
+	d := at.Sub(now).Round(time.Minute)
+	if d < time.Minute {
+		return "any minute"
+	}
+	h, m := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	switch {
+	case h == 0:
+		return fmt.Sprintf("~%dm", m)
+	case m == 0:
+		return fmt.Sprintf("~%dh", h)
+	default:
+		return fmt.Sprintf("~%dh%02dm", h, m)
+	}
+}
+
+func median(ds []time.Duration) time.Duration {
+	ds = slices.Clone(ds)
+	slices.Sort(ds)
+	n := len(ds)
+	if n%2 == 1 {
+		return ds[n/2]
+	}
+	return (ds[n/2-1] + ds[n/2]) / 2
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
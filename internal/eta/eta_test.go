@@ -0,0 +1,109 @@
+package eta_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/eta"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func entry(id, pr int64, branch string, state pg.EntryState, started time.Duration) pg.QueueEntry {
+	e := pg.QueueEntry{ID: id, PrNumber: pr, TargetBranch: branch, State: state}
+	if state == pg.EntryStateTesting {
+		e.TestingStartedAt = pgtype.Timestamptz{Time: now.Add(-started), Valid: true}
+	}
+	return e
+}
+
+func TestPredict_SinglePR(t *testing.T) {
+	m := eta.Model{BuildTime: 10 * time.Minute, FailureRate: 0.5, BatchMax: 1}
+	got := m.Predict([]pg.QueueEntry{
+		entry(1, 10, "main", pg.EntryStateTesting, 4*time.Minute),
+		entry(2, 11, "main", pg.EntryStateQueued, 0),
+		entry(3, 12, "main", pg.EntryStateQueued, 0),
+		entry(4, 20, "release", pg.EntryStateQueued, 0),
+		entry(5, 21, "release", pg.EntryStateSuccess, 0),
+	}, nil, now)
+
+	want := map[int64]time.Duration{
+		10: 6 * time.Minute,  // remaining of the running build
+		11: 16 * time.Minute, // failures do not slow single-PR mode down
+		12: 26 * time.Minute,
+		20: 10 * time.Minute, // other branch is an independent queue
+		21: 0,                // waiting for the forge to merge
+	}
+	for pr, d := range want {
+		if got[pr] != now.Add(d) {
+			t.Errorf("PR #%d: ETA %v, want %v", pr, got[pr].Sub(now), d)
+		}
+	}
+}
+
+func TestPredict_OverdueBuild(t *testing.T) {
+	m := eta.Model{BuildTime: 10 * time.Minute, BatchMax: 1}
+	got := m.Predict([]pg.QueueEntry{
+		entry(1, 10, "main", pg.EntryStateTesting, 30*time.Minute),
+		entry(2, 11, "main", pg.EntryStateQueued, 0),
+	}, nil, now)
+	if got[10] != now || got[11] != now.Add(10*time.Minute) {
+		t.Errorf("overdue head should land any moment: %v / %v", got[10].Sub(now), got[11].Sub(now))
+	}
+}
+
+func TestPredict_Batches(t *testing.T) {
+	m := eta.Model{BuildTime: 10 * time.Minute, FailureRate: 0.5, BatchMax: 4}
+	live := pg.Batch{
+		TargetBranch:     "main",
+		CurrentIds:       []int64{1},
+		Pending:          []byte(`[[2]]`),
+		TestingStartedAt: pgtype.Timestamptz{Time: now.Add(-5 * time.Minute), Valid: true},
+	}
+	entries := []pg.QueueEntry{
+		entry(1, 10, "main", pg.EntryStateTesting, 5*time.Minute),
+		entry(2, 11, "main", pg.EntryStateTesting, 5*time.Minute),
+	}
+	for i := range int64(5) {
+		entries = append(entries, entry(10+i, 20+i, "main", pg.EntryStateQueued, 0))
+	}
+	got := m.Predict(entries, []pg.Batch{live}, now)
+
+	// Live batch: current lands after the running build, the pending half one
+	// build later. Then a batch of 4 (1 + 0.5*2 builds) and a batch of 1.
+	want := map[int64]time.Duration{
+		10: 5 * time.Minute,
+		11: 15 * time.Minute,
+		20: 35 * time.Minute,
+		23: 35 * time.Minute,
+		24: 45 * time.Minute,
+	}
+	for pr, d := range want {
+		if got[pr] != now.Add(d) {
+			t.Errorf("PR #%d: ETA %v, want %v", pr, got[pr].Sub(now), d)
+		}
+	}
+}
+
+func TestPredict_NoHistory(t *testing.T) {
+	got := eta.Model{BatchMax: 1}.Predict([]pg.QueueEntry{entry(1, 10, "main", pg.EntryStateQueued, 0)}, nil, now)
+	if len(got) != 0 {
+		t.Errorf("expected no predictions without a build time, got %v", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                "any minute",
+		25 * time.Second: "any minute",
+		25 * time.Minute: "~25m",
+		2 * time.Hour:    "~2h",
+		time.Hour + 5*time.Minute + 20*time.Second: "~1h05m",
+	} {
+		if got := eta.Format(now.Add(d), now); got != want {
+			t.Errorf("Format(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 // Package forge defines a forge-agnostic interface for repo, PR, status,
 // branch, comment and setup operations. Concrete implementations live in
-// sibling packages (internal/gitea, internal/github).
+// sibling packages (internal/gitea, internal/forgejo, internal/github,
+// internal/gitlab).
 package forge
 
 import (
@@ -40,6 +41,112 @@ type MergeStacker interface {
 	StackMerges(ctx context.Context, owner, repo, base string, heads []string, branch string) (tip string, steps []MergeStep, err error)
 }
 
+// EmailResolver is optionally implemented by a Forge that can look up a
+// user's e-mail address. owner/name select the credentials (GitHub App
+// installation) to ask with. An empty address with a nil error means the
+// forge hides it; notifications for that user are skipped.
+type EmailResolver interface {
+	UserEmail(ctx context.Context, owner, name, login string) (string, error)
+}
+
+// RepoVisibility is optionally implemented by a Forge that can tell whether
+// a repository is private. The dashboard shows anonymous visitors only repos
+// that report false; forges without it are treated as all-private.
+type RepoVisibility interface {
+	RepoPrivate(ctx context.Context, owner, name string) (bool, error)
+}
+
+// StatusActions is optionally implemented by a Forge whose gitea-mq status
+// offers buttons (GitHub check-run requested actions): "Retry in queue"
+// after a removal and "Remove from queue" while queued. A pressed button is
+// only acted on when CanWrite allows the user who pressed it.
+type StatusActions interface {
+	CanWrite(ctx context.Context, owner, name, login string) (bool, error)
+	// EnableAutoMerge re-schedules the PR, so the next poll enqueues it.
+	EnableAutoMerge(ctx context.Context, owner, name string, number int64) error
+}
+
+// BulkReconciler is optionally implemented by a Forge that advertises
+// Capabilities.BulkReconcile. It fetches what a poll needs to know about the
+// open PRs in a few paged requests instead of several per PR. An
+// errors.ErrUnsupported error means the server cannot, and the caller falls
+// back to ListOpenPRs and per-PR lookups.
+type BulkReconciler interface {
+	OpenPRSnapshot(ctx context.Context, owner, name string) (*Snapshot, error)
+}
+
+// Snapshot is a repo's open PRs with their head-commit checks and the
+// required checks of their base branches.
+type Snapshot struct {
+	PRs []PR
+	// Checks maps a PR's head SHA to its checks as GetCheckStates reports
+	// them.
+	Checks map[string]map[string]Check
+	// RequiredChecks maps a base branch to its required checks as
+	// GetRequiredChecks reports them.
+	RequiredChecks map[string][]string
+}
+
+// BranchGuard is optionally implemented by a Forge whose gitea-mq gate lists
+// the branches it covers (GitHub rulesets). The poller calls it when it
+// queues a PR, so that a target branch other than the default one is gated
+// before the PR can merge around the queue.
+type BranchGuard interface {
+	GuardBranch(ctx context.Context, owner, name, branch string) error
+}
+
+// Doctor is optionally implemented by a Forge that can check a repo's setup
+// against what gitea-mq needs. Problems are reported as failing checks, not
+// as an error, so one missing permission does not hide the rest.
+type Doctor interface {
+	Diagnose(ctx context.Context, owner, name string, cfg SetupConfig) []DoctorCheck
+}
+
+// DoctorStatus is the outcome of a DoctorCheck.
+type DoctorStatus string
+
+const (
+	DoctorPass DoctorStatus = "pass"
+	// DoctorWarn: gitea-mq works, but degraded, or the check could not be
+	// verified with the permissions it has.
+	DoctorWarn DoctorStatus = "warn"
+	DoctorFail DoctorStatus = "fail"
+)
+
+// Names of the checks every Doctor reports.
+const (
+	DoctorPermissions   = "permissions"
+	DoctorRequiredCheck = "required check"
+	DoctorWebhook       = "webhook"
+	DoctorPush          = "push"
+	DoctorAutoMerge     = "auto-merge"
+)
+
+// DoctorCheck is one diagnostic item. Fix tells the operator what to change
+// and is empty for passing checks.
+type DoctorCheck struct {
+	Name   string
+	Status DoctorStatus
+	Detail string
+	Fix    string
+}
+
+// DoctorPassed returns a passing check.
+func DoctorPassed(name, detail string) DoctorCheck {
+	return DoctorCheck{Name: name, Status: DoctorPass, Detail: detail}
+}
+
+// DoctorWarning returns a check that passed only partly or could not be
+// verified.
+func DoctorWarning(name, detail, fix string) DoctorCheck {
+	return DoctorCheck{Name: name, Status: DoctorWarn, Detail: detail, Fix: fix}
+}
+
+// DoctorFailed returns a failing check.
+func DoctorFailed(name, detail, fix string) DoctorCheck {
+	return DoctorCheck{Name: name, Status: DoctorFail, Detail: detail, Fix: fix}
+}
+
 func (e *PushDeniedError) Error() string {
 	return fmt.Sprintf("forge: push to %s denied: %s", e.Branch, e.Message)
 }
@@ -48,8 +155,10 @@ func (e *PushDeniedError) Error() string {
 type Kind string
 
 const (
-	KindGitea  Kind = "gitea"
-	KindGithub Kind = "github"
+	KindGitea   Kind = "gitea"
+	KindForgejo Kind = "forgejo"
+	KindGithub  Kind = "github"
+	KindGitlab  Kind = "gitlab"
 )
 
 const (
@@ -63,56 +172,129 @@ func IsOwnContext(ctx string) bool {
 	return ctx == MQContext || strings.HasPrefix(ctx, MirrorContextPrefix)
 }
 
+// DashboardRepoURL builds the gitea-mq dashboard link for a repo.
+func DashboardRepoURL(base string, ref RepoRef) string {
+	return fmt.Sprintf("%s/repo/%s/%s/%s", strings.TrimRight(base, "/"), ref.Host(), ref.Owner, ref.Name)
+}
+
 // DashboardPRURL builds the gitea-mq dashboard link for a PR. It is the
 // target_url of every MQStatus so users land on the queue page from the
 // forge's check UI.
-func DashboardPRURL(base string, kind Kind, owner, repo string, n int64) string {
-	return fmt.Sprintf("%s/repo/%s/%s/%s/pr/%d", strings.TrimRight(base, "/"), kind, owner, repo, n)
+func DashboardPRURL(base string, ref RepoRef, n int64) string {
+	return fmt.Sprintf("%s/pr/%d", DashboardRepoURL(base, ref), n)
 }
 
 // Valid reports whether k is a known forge kind.
 func (k Kind) Valid() bool {
 	switch k {
-	case KindGitea, KindGithub:
+	case KindGitea, KindForgejo, KindGithub, KindGitlab:
 		return true
 	}
 	return false
 }
 
+// Host identifies a forge server. Instance names one of several servers of
+// the same kind and is empty for the default one.
+type Host struct {
+	Kind     Kind
+	Instance string
+}
+
+// String returns "<kind>" or "<kind>@<instance>".
+func (h Host) String() string {
+	if h.Instance == "" {
+		return string(h.Kind)
+	}
+	return string(h.Kind) + "@" + h.Instance
+}
+
+// ParseHost parses the String form of a Host.
+func ParseHost(s string) (Host, bool) {
+	kind, instance, named := strings.Cut(s, "@")
+	h := Host{Kind: Kind(kind), Instance: instance}
+	if !h.Kind.Valid() || (named && !ValidInstanceName(instance)) {
+		return Host{}, false
+	}
+	return h, true
+}
+
+// ValidInstanceName reports whether s may name a forge instance: lower-case
+// letters, digits and dashes, so it fits URLs and environment variables.
+func ValidInstanceName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
 // RepoRef identifies a repository on a specific forge.
 type RepoRef struct {
-	Forge Kind
-	Owner string
-	Name  string
+	Forge    Kind
+	Instance string // empty for the default instance of Forge
+	Owner    string
+	Name     string
 }
 
-// String returns the canonical "<forge>:<owner>/<name>" form.
+// Host returns the forge server the repo lives on.
+func (r RepoRef) Host() Host {
+	return Host{Kind: r.Forge, Instance: r.Instance}
+}
+
+// String returns the canonical "<host>:<owner>/<name>" form, e.g.
+// "gitea:org/app" or "gitea@internal:org/app".
 func (r RepoRef) String() string {
-	return string(r.Forge) + ":" + r.Owner + "/" + r.Name
+	return r.Host().String() + ":" + r.Owner + "/" + r.Name
 }
 
-// ParseRepoRef parses a "<forge>:<owner>/<name>" string.
+// ParseRepoRef parses a "<host>:<owner>/<name>" string.
 // Returns false on invalid format or unknown forge kind.
 func ParseRepoRef(s string) (RepoRef, bool) {
-	forge, rest, ok := strings.Cut(s, ":")
+	host, rest, ok := strings.Cut(s, ":")
 	if !ok {
 		return RepoRef{}, false
 	}
-	k := Kind(forge)
-	if !k.Valid() {
+	h, ok := ParseHost(host)
+	if !ok {
 		return RepoRef{}, false
 	}
 	owner, name, ok := strings.Cut(rest, "/")
 	if !ok || owner == "" || name == "" {
 		return RepoRef{}, false
 	}
-	return RepoRef{Forge: k, Owner: owner, Name: name}, true
+	return RepoRef{Forge: h.Kind, Instance: h.Instance, Owner: owner, Name: name}, true
+}
+
+// Instanced is implemented by adapters bound to a named instance of their
+// kind. Adapters without it serve the default instance.
+type Instanced interface {
+	Instance() string
+}
+
+// HostOf returns the forge server f talks to.
+func HostOf(f Forge) Host {
+	h := Host{Kind: f.Kind()}
+	if i, ok := f.(Instanced); ok {
+		h.Instance = i.Instance()
+	}
+	return h
+}
+
+// RefOf returns the RepoRef of owner/name on f.
+func RefOf(f Forge, owner, name string) RepoRef {
+	h := HostOf(f)
+	return RepoRef{Forge: h.Kind, Instance: h.Instance, Owner: owner, Name: name}
 }
 
 // PR is a forge-agnostic pull request.
 //
 // AutoMergeEnabled normalises forge-specific signals (Gitea timeline comments,
-// GitHub auto_merge field) so callers do not handle forge internals.
+// GitHub auto_merge field, GitLab merge_when_pipeline_succeeds) so callers
+// do not handle forge internals.
 type PR struct {
 	Number           int64
 	Title            string
@@ -131,6 +313,9 @@ type MQStatus struct {
 	State       CheckState
 	Description string
 	TargetURL   string
+	// Report is an optional markdown account of the PR's place in the
+	// queue. Only forges with Capabilities.StatusReport show it.
+	Report string
 }
 
 // CheckState aliases pg.CheckState so callers do not import the store package.
@@ -163,11 +348,34 @@ type Check struct {
 // SetupConfig holds inputs to EnsureRepoSetup.
 type SetupConfig struct {
 	// ExternalURL is the public base URL of the gitea-mq instance. Used by
-	// Gitea for webhook URL construction and as the dashboard link target.
+	// adapters with per-repo webhooks for the webhook URL and as the
+	// dashboard link target.
 	ExternalURL string
-	// WebhookSecret is the shared secret for Gitea webhook signatures.
-	// Ignored by GitHub adapters (App webhook is configured out-of-band).
+	// WebhookSecret is the shared secret for Gitea/Forgejo webhook
+	// signatures. Ignored by the GitHub App adapter (its webhook is
+	// configured out-of-band).
 	WebhookSecret string
+	// TargetBranches are the branches PRs are queued against, besides the
+	// default branch.
+	TargetBranches []string
+	// BranchPatterns are further branches to gate, as glob patterns such
+	// as "release/*".
+	BranchPatterns []string
+	// CreateProtection lets Gitea and Forgejo create a protection rule for
+	// the default branch and each pattern no rule covers yet. GitHub always
+	// manages its own ruleset.
+	CreateProtection bool
+	// RequiredChecks are required by created rules besides gitea-mq.
+	RequiredChecks []string
+	// PushesTargets is set when gitea-mq fast-forwards target branches
+	// itself (batching), so created rules let the token user push.
+	PushesTargets bool
+	// RuleCreated, if set, is called with the name of each protection rule
+	// EnsureRepoSetup creates.
+	RuleCreated func(ctx context.Context, rule string) error
+	// CreatedRules are the protection rules gitea-mq created earlier.
+	// TeardownRepoSetup deletes them; other rules only lose gitea-mq.
+	CreatedRules []string
 }
 
 // Capabilities lets callers branch on forge features instead of on Kind.
@@ -176,6 +384,11 @@ type Capabilities struct {
 	// webhooks. Without it CI results must be polled, so idle gating is
 	// unsafe.
 	StatusWebhook bool
+	// StatusReport: the gitea-mq status has room for MQStatus.Report
+	// (GitHub check runs). Commit statuses only carry the description.
+	StatusReport bool
+	// BulkReconcile: the forge implements BulkReconciler (GitHub GraphQL).
+	BulkReconcile bool
 }
 
 // Forge abstracts all operations gitea-mq performs against a hosting forge.
@@ -208,6 +421,10 @@ type Forge interface {
 	Comment(ctx context.Context, owner, name string, number int64, body string) error
 
 	EnsureRepoSetup(ctx context.Context, owner, name string, cfg SetupConfig) error
+	// TeardownRepoSetup undoes EnsureRepoSetup for a repo gitea-mq stops
+	// managing: it deletes the webhook and stops requiring the gitea-mq
+	// check, so open PRs do not wait for a status nobody posts anymore.
+	TeardownRepoSetup(ctx context.Context, owner, name string, cfg SetupConfig) error
 
 	// MergeInto merges headSHA into an existing branch and returns the new
 	// tip. conflict=true (err=nil) on merge conflict. Used to stack batch
@@ -225,11 +442,11 @@ type Forge interface {
 }
 
 // UnknownForgeError is returned by Set.For when no adapter is registered for
-// a ref's forge kind.
+// a ref's forge host.
 type UnknownForgeError struct {
-	Kind Kind
+	Host Host
 }
 
 func (e *UnknownForgeError) Error() string {
-	return fmt.Sprintf("forge: no adapter registered for kind %q", e.Kind)
+	return fmt.Sprintf("forge: no adapter registered for %q", e.Host)
 }
@@ -8,8 +8,13 @@ func TestParseRepoRefRoundTrip(t *testing.T) {
 		want RepoRef
 		ok   bool
 	}{
-		{"gitea:o/n", RepoRef{KindGitea, "o", "n"}, true},
-		{"github:acme/hello-world", RepoRef{KindGithub, "acme", "hello-world"}, true},
+		{"gitea:o/n", RepoRef{KindGitea, "", "o", "n"}, true},
+		{"forgejo:o/n", RepoRef{KindForgejo, "", "o", "n"}, true},
+		{"github:acme/hello-world", RepoRef{KindGithub, "", "acme", "hello-world"}, true},
+		{"gitlab:group/app", RepoRef{KindGitlab, "", "group", "app"}, true},
+		{"gitea@internal:o/n", RepoRef{KindGitea, "internal", "o", "n"}, true},
+		{"gitea@:o/n", RepoRef{}, false},
+		{"gitea@In_ternal:o/n", RepoRef{}, false},
 		{"o/n", RepoRef{}, false},
 		{"unknown:o/n", RepoRef{}, false},
 		{"gitea:o", RepoRef{}, false},
@@ -35,3 +40,14 @@ func TestParseRepoRefRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestDashboardURLs(t *testing.T) {
+	ref := RepoRef{Forge: KindGitea, Instance: "internal", Owner: "org", Name: "app"}
+	if got, want := DashboardRepoURL("https://mq.example.com/", ref), "https://mq.example.com/repo/gitea@internal/org/app"; got != want {
+		t.Errorf("DashboardRepoURL = %q, want %q", got, want)
+	}
+	ref.Instance = ""
+	if got, want := DashboardPRURL("https://mq.example.com", ref, 7), "https://mq.example.com/repo/gitea/org/app/pr/7"; got != want {
+		t.Errorf("DashboardPRURL = %q, want %q", got, want)
+	}
+}
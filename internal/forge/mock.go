@@ -3,6 +3,7 @@ package forge
 import (
 	"cmp"
 	"context"
+	"errors"
 	"sync"
 )
 
@@ -19,6 +20,7 @@ type MockForge struct {
 	Calls []MockCall
 
 	KindVal         Kind
+	InstanceVal     string
 	CapabilitiesVal Capabilities
 
 	RepoHTMLURLFn       func(owner, name string) string
@@ -36,12 +38,27 @@ type MockForge struct {
 	CancelAutoMergeFn   func(ctx context.Context, owner, name string, number int64) error
 	CommentFn           func(ctx context.Context, owner, name string, number int64, body string) error
 	EnsureRepoSetupFn   func(ctx context.Context, owner, name string, cfg SetupConfig) error
+	TeardownRepoSetupFn func(ctx context.Context, owner, name string, cfg SetupConfig) error
 	MergeIntoFn         func(ctx context.Context, owner, name, branch, headSHA string) (string, bool, error)
 	FastForwardFn       func(ctx context.Context, owner, name, branch, sha string) error
 	ClosePRFn           func(ctx context.Context, owner, name string, number int64) error
+	UserEmailFn         func(ctx context.Context, owner, name, login string) (string, error)
+	RepoPrivateFn       func(ctx context.Context, owner, name string) (bool, error)
+	CanWriteFn          func(ctx context.Context, owner, name, login string) (bool, error)
+	EnableAutoMergeFn   func(ctx context.Context, owner, name string, number int64) error
+	OpenPRSnapshotFn    func(ctx context.Context, owner, name string) (*Snapshot, error)
+	GuardBranchFn       func(ctx context.Context, owner, name, branch string) error
 }
 
-var _ Forge = (*MockForge)(nil)
+var (
+	_ Forge          = (*MockForge)(nil)
+	_ EmailResolver  = (*MockForge)(nil)
+	_ RepoVisibility = (*MockForge)(nil)
+	_ Instanced      = (*MockForge)(nil)
+	_ StatusActions  = (*MockForge)(nil)
+	_ BulkReconciler = (*MockForge)(nil)
+	_ BranchGuard    = (*MockForge)(nil)
+)
 
 func (m *MockForge) record(method string, args ...any) {
 	m.mu.Lock()
@@ -66,6 +83,10 @@ func (m *MockForge) Kind() Kind {
 	return cmp.Or(m.KindVal, KindGitea)
 }
 
+func (m *MockForge) Instance() string {
+	return m.InstanceVal
+}
+
 func (m *MockForge) Capabilities() Capabilities {
 	return m.CapabilitiesVal
 }
@@ -190,6 +211,14 @@ func (m *MockForge) EnsureRepoSetup(ctx context.Context, owner, name string, cfg
 	return nil
 }
 
+func (m *MockForge) TeardownRepoSetup(ctx context.Context, owner, name string, cfg SetupConfig) error {
+	m.record("TeardownRepoSetup", owner, name, cfg)
+	if m.TeardownRepoSetupFn != nil {
+		return m.TeardownRepoSetupFn(ctx, owner, name, cfg)
+	}
+	return nil
+}
+
 func (m *MockForge) MergeInto(ctx context.Context, owner, name, branch, headSHA string) (string, bool, error) {
 	m.record("MergeInto", owner, name, branch, headSHA)
 	if m.MergeIntoFn != nil {
@@ -213,3 +242,52 @@ func (m *MockForge) ClosePR(ctx context.Context, owner, name string, number int6
 	}
 	return nil
 }
+
+func (m *MockForge) UserEmail(ctx context.Context, owner, name, login string) (string, error) {
+	m.record("UserEmail", owner, name, login)
+	if m.UserEmailFn != nil {
+		return m.UserEmailFn(ctx, owner, name, login)
+	}
+	return "", nil
+}
+
+func (m *MockForge) RepoPrivate(ctx context.Context, owner, name string) (bool, error) {
+	m.record("RepoPrivate", owner, name)
+	if m.RepoPrivateFn != nil {
+		return m.RepoPrivateFn(ctx, owner, name)
+	}
+	return false, nil
+}
+
+func (m *MockForge) CanWrite(ctx context.Context, owner, name, login string) (bool, error) {
+	m.record("CanWrite", owner, name, login)
+	if m.CanWriteFn != nil {
+		return m.CanWriteFn(ctx, owner, name, login)
+	}
+	return false, nil
+}
+
+func (m *MockForge) EnableAutoMerge(ctx context.Context, owner, name string, number int64) error {
+	m.record("EnableAutoMerge", owner, name, number)
+	if m.EnableAutoMergeFn != nil {
+		return m.EnableAutoMergeFn(ctx, owner, name, number)
+	}
+	return nil
+}
+
+// OpenPRSnapshot is unsupported unless OpenPRSnapshotFn is set.
+func (m *MockForge) OpenPRSnapshot(ctx context.Context, owner, name string) (*Snapshot, error) {
+	m.record("OpenPRSnapshot", owner, name)
+	if m.OpenPRSnapshotFn != nil {
+		return m.OpenPRSnapshotFn(ctx, owner, name)
+	}
+	return nil, errors.ErrUnsupported
+}
+
+func (m *MockForge) GuardBranch(ctx context.Context, owner, name, branch string) error {
+	m.record("GuardBranch", owner, name, branch)
+	if m.GuardBranchFn != nil {
+		return m.GuardBranchFn(ctx, owner, name, branch)
+	}
+	return nil
+}
@@ -1,35 +1,35 @@
 package forge
 
-// Set is a lookup of Forge implementations by Kind. Callers resolve the
+// Set is a lookup of Forge implementations by Host. Callers resolve the
 // correct adapter for a RepoRef via For.
 type Set struct {
-	forges map[Kind]Forge
+	forges map[Host]Forge
 }
 
 // NewSet returns an empty Set.
 func NewSet() *Set {
-	return &Set{forges: map[Kind]Forge{}}
+	return &Set{forges: map[Host]Forge{}}
 }
 
-// Register installs f under its Kind, replacing any previous entry.
+// Register installs f under its Host, replacing any previous entry.
 func (s *Set) Register(f Forge) {
-	s.forges[f.Kind()] = f
+	s.forges[HostOf(f)] = f
 }
 
 // For returns the adapter for ref, or *UnknownForgeError if unregistered.
 func (s *Set) For(ref RepoRef) (Forge, error) {
-	f, ok := s.forges[ref.Forge]
+	f, ok := s.forges[ref.Host()]
 	if !ok {
-		return nil, &UnknownForgeError{Kind: ref.Forge}
+		return nil, &UnknownForgeError{Host: ref.Host()}
 	}
 	return f, nil
 }
 
-// Kinds returns registered forge kinds in unspecified order.
-func (s *Set) Kinds() []Kind {
-	out := make([]Kind, 0, len(s.forges))
-	for k := range s.forges {
-		out = append(out, k)
+// Hosts returns registered forge hosts in unspecified order.
+func (s *Set) Hosts() []Host {
+	out := make([]Host, 0, len(s.forges))
+	for h := range s.forges {
+		out = append(out, h)
 	}
 	return out
 }
@@ -20,11 +20,30 @@ func TestSetRoutesByKind(t *testing.T) {
 	}
 }
 
+func TestSetRoutesByInstance(t *testing.T) {
+	s := NewSet()
+	def := &MockForge{KindVal: KindGitea}
+	internal := &MockForge{KindVal: KindGitea, InstanceVal: "internal"}
+	s.Register(def)
+	s.Register(internal)
+
+	if got, _ := s.For(RepoRef{Forge: KindGitea, Owner: "o", Name: "n"}); got != def {
+		t.Errorf("default: got %v want %v", got, def)
+	}
+	if got, _ := s.For(RepoRef{Forge: KindGitea, Instance: "internal", Owner: "o", Name: "n"}); got != internal {
+		t.Errorf("internal: got %v want %v", got, internal)
+	}
+	var uf *UnknownForgeError
+	if _, err := s.For(RepoRef{Forge: KindGitea, Instance: "public", Owner: "o", Name: "n"}); !errors.As(err, &uf) {
+		t.Errorf("public: want UnknownForgeError, got %v", err)
+	}
+}
+
 func TestSetForUnknownKind(t *testing.T) {
 	s := NewSet()
 	_, err := s.For(RepoRef{Forge: KindGithub, Owner: "o", Name: "n"})
 	var uf *UnknownForgeError
-	if !errors.As(err, &uf) || uf.Kind != KindGithub {
+	if !errors.As(err, &uf) || uf.Host.Kind != KindGithub {
 		t.Fatalf("want UnknownForgeError{github}, got %v", err)
 	}
 }
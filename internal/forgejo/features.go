@@ -0,0 +1,81 @@
+package forgejo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/gitea"
+)
+
+// Features is the optional Forgejo functionality an instance supports.
+// Forgejo versions its releases independently of Gitea (1.18 … 1.21, then
+// 7, 8, …), so the thresholds below are Forgejo versions.
+type Features struct {
+	// Version is the version string the instance reported.
+	Version string
+	// StatusWebhook: commit status changes are delivered as webhooks, so
+	// idle repos need not poll CI.
+	StatusWebhook bool
+	// AutoMergeAPI: PRs can be scheduled to merge once checks pass
+	// (merge_when_checks_succeed) and cancelled via DELETE
+	// /pulls/{index}/merge. Without it nobody can enable auto-merge, so the
+	// adapter skips the timeline lookups.
+	AutoMergeAPI bool
+}
+
+// Releases that introduced each feature, as {major, minor}.
+var (
+	autoMergeAPISince  = [2]int{1, 19}
+	statusWebhookSince = [2]int{11, 0}
+)
+
+// Probe asks the instance for its version and derives its features. An
+// unparsable version is treated conservatively: CI is polled and auto-merge
+// is read from the timeline as on Gitea. So is a failed probe, whose error
+// is returned alongside.
+func Probe(ctx context.Context, client gitea.Client) (Features, error) {
+	v, err := client.GetVersion(ctx)
+	if err != nil {
+		return featuresFor(""), err
+	}
+	return featuresFor(v), nil
+}
+
+func featuresFor(version string) Features {
+	f := Features{Version: version}
+	v, ok := parseVersion(version)
+	if !ok {
+		f.AutoMergeAPI = true
+		return f
+	}
+	f.AutoMergeAPI = atLeast(v, autoMergeAPISince)
+	f.StatusWebhook = atLeast(v, statusWebhookSince)
+	return f
+}
+
+// parseVersion extracts major and minor from versions such as "1.21.11-1"
+// or "9.0.1+gitea-1.22.0".
+func parseVersion(s string) ([2]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return [2]int{}, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return [2]int{}, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return [2]int{}, false
+	}
+	return [2]int{major, minor}, true
+}
+
+func atLeast(v, since [2]int) bool {
+	return v[0] > since[0] || (v[0] == since[0] && v[1] >= since[1])
+}
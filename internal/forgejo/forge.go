@@ -0,0 +1,157 @@
+// Package forgejo adapts Forgejo instances to forge.Forge. Forgejo still
+// speaks Gitea's REST API, so the adapter is built on the Gitea client and
+// adapter and only overrides where the two forges differ.
+package forgejo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/gitea"
+)
+
+// forgejoForge delegates to the Gitea adapter except for the forge kind,
+// the probed capabilities, auto-merge detection and webhook setup.
+type forgejoForge struct {
+	forge.Forge
+	client   gitea.Client
+	features Features
+}
+
+// NewForge wraps a Gitea client talking to a Forgejo instance. baseURL is
+// the instance root (no trailing /api/v1); features usually come from Probe.
+func NewForge(client gitea.Client, baseURL string, features Features) forge.Forge {
+	return &forgejoForge{
+		Forge:    gitea.NewForge(client, baseURL),
+		client:   client,
+		features: features,
+	}
+}
+
+var (
+	_ forge.Forge          = (*forgejoForge)(nil)
+	_ forge.MergeStacker   = (*forgejoForge)(nil)
+	_ forge.EmailResolver  = (*forgejoForge)(nil)
+	_ forge.RepoVisibility = (*forgejoForge)(nil)
+	_ forge.Doctor         = (*forgejoForge)(nil)
+)
+
+func (f *forgejoForge) Kind() forge.Kind { return forge.KindForgejo }
+
+func (f *forgejoForge) Capabilities() forge.Capabilities {
+	return forge.Capabilities{StatusWebhook: f.features.StatusWebhook}
+}
+
+// The optional interfaces are not promoted through the embedded
+// forge.Forge, so forward them to the Gitea adapter explicitly.
+
+func (f *forgejoForge) StackMerges(ctx context.Context, owner, repo, base string, heads []string, branch string) (string, []forge.MergeStep, error) {
+	return f.Forge.(forge.MergeStacker).StackMerges(ctx, owner, repo, base, heads, branch)
+}
+
+func (f *forgejoForge) UserEmail(ctx context.Context, owner, name, login string) (string, error) {
+	return f.Forge.(forge.EmailResolver).UserEmail(ctx, owner, name, login)
+}
+
+func (f *forgejoForge) RepoPrivate(ctx context.Context, owner, name string) (bool, error) {
+	return f.Forge.(forge.RepoVisibility).RepoPrivate(ctx, owner, name)
+}
+
+func (f *forgejoForge) ListOpenPRs(ctx context.Context, owner, name string) ([]forge.PR, error) {
+	prs, err := f.client.ListOpenPRs(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]forge.PR, 0, len(prs))
+	for i := range prs {
+		autoMerge, err := f.autoMergeScheduled(ctx, owner, name, prs[i].Index)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, gitea.ToForgePR(&prs[i], autoMerge))
+	}
+	return out, nil
+}
+
+func (f *forgejoForge) GetPR(ctx context.Context, owner, name string, number int64) (*forge.PR, error) {
+	pr, err := f.client.GetPR(ctx, owner, name, number)
+	if err != nil {
+		return nil, err
+	}
+	autoMerge, err := f.autoMergeScheduled(ctx, owner, name, number)
+	if err != nil {
+		return nil, err
+	}
+	fp := gitea.ToForgePR(pr, autoMerge)
+	return &fp, nil
+}
+
+// autoMergeScheduled reads the PR timeline, which Forgejo keeps in Gitea's
+// comment types. Instances without the auto-merge API cannot have it
+// scheduled, which saves one request per open PR.
+func (f *forgejoForge) autoMergeScheduled(ctx context.Context, owner, name string, number int64) (bool, error) {
+	if !f.features.AutoMergeAPI {
+		return false, nil
+	}
+	timeline, err := f.client.GetPRTimeline(ctx, owner, name, number)
+	if err != nil {
+		return false, fmt.Errorf("get timeline for PR #%d: %w", number, err)
+	}
+	return gitea.HasAutomergeScheduled(timeline), nil
+}
+
+func (f *forgejoForge) CancelAutoMerge(ctx context.Context, owner, name string, number int64) error {
+	if !f.features.AutoMergeAPI {
+		return nil
+	}
+	return f.client.CancelAutoMerge(ctx, owner, name, number)
+}
+
+// EnsureRepoSetup points the repo's webhook at the Forgejo endpoint, so
+// deliveries are routed to Forgejo repos even when a Gitea instance hosts a
+// repo of the same name.
+func (f *forgejoForge) EnsureRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	if err := gitea.EnsureBranchProtection(ctx, f.client, owner, name, cfg); err != nil {
+		return err
+	}
+	if cfg.ExternalURL == "" {
+		return nil
+	}
+	return gitea.EnsureWebhook(ctx, f.client, owner, name, webhookURL(cfg), cfg.WebhookSecret)
+}
+
+func (f *forgejoForge) TeardownRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	if err := gitea.RemoveBranchProtection(ctx, f.client, owner, name, cfg.CreatedRules); err != nil {
+		return err
+	}
+	if cfg.ExternalURL == "" {
+		return nil
+	}
+	return gitea.RemoveWebhook(ctx, f.client, owner, name, webhookURL(cfg))
+}
+
+// Diagnose runs the Gitea checks against the Forgejo endpoint and fails
+// auto-merge on releases that cannot schedule merges.
+func (f *forgejoForge) Diagnose(ctx context.Context, owner, name string, cfg forge.SetupConfig) []forge.DoctorCheck {
+	checks := gitea.Diagnose(ctx, f.client, owner, name, webhookURL(cfg))
+	if f.features.AutoMergeAPI {
+		return checks
+	}
+	for i, c := range checks {
+		if c.Name == forge.DoctorAutoMerge {
+			checks[i] = forge.DoctorFailed(forge.DoctorAutoMerge,
+				fmt.Sprintf("Forgejo %s cannot schedule PRs to merge once checks pass", f.features.Version),
+				"upgrade Forgejo to 1.19 or newer")
+		}
+	}
+	return checks
+}
+
+func webhookURL(cfg forge.SetupConfig) string {
+	if cfg.ExternalURL == "" {
+		return ""
+	}
+	return strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/forgejo"
+}
@@ -0,0 +1,83 @@
+package forgejo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/gitea"
+)
+
+func TestFeaturesFor(t *testing.T) {
+	for _, tc := range []struct {
+		version       string
+		autoMerge     bool
+		statusWebhook bool
+	}{
+		{"1.18.5-0", false, false},
+		{"1.21.11-1", true, false},
+		{"9.0.1+gitea-1.22.0", true, false},
+		{"11.0.0+gitea-1.22.0", true, true},
+		{"v12.1.0", true, true},
+		// Unknown versions must not disable polling or auto-merge detection.
+		{"", true, false},
+		{"dev", true, false},
+	} {
+		f := featuresFor(tc.version)
+		if f.AutoMergeAPI != tc.autoMerge || f.StatusWebhook != tc.statusWebhook {
+			t.Errorf("%q: got %+v, want auto-merge=%v status-webhook=%v", tc.version, f, tc.autoMerge, tc.statusWebhook)
+		}
+	}
+}
+
+func TestForge_AutoMergeFollowsFeatures(t *testing.T) {
+	mock := &gitea.MockClient{
+		ListOpenPRsFn: func(_ context.Context, _, _ string) ([]gitea.PR, error) {
+			return []gitea.PR{{Index: 1, State: "open", Head: &gitea.PRRef{Sha: "sha1"}}}, nil
+		},
+		GetPRTimelineFn: func(_ context.Context, _, _ string, _ int64) ([]gitea.TimelineComment, error) {
+			return []gitea.TimelineComment{{Type: "pull_scheduled_merge"}}, nil
+		},
+	}
+
+	f := NewForge(mock, "https://forgejo.example.com", Features{AutoMergeAPI: true})
+	prs, err := f.ListOpenPRs(context.Background(), "org", "app")
+	if err != nil || len(prs) != 1 || !prs[0].AutoMergeEnabled {
+		t.Fatalf("with auto-merge API: prs=%+v err=%v", prs, err)
+	}
+
+	mock.Reset()
+	f = NewForge(mock, "https://forgejo.example.com", Features{})
+	prs, err = f.ListOpenPRs(context.Background(), "org", "app")
+	if err != nil || len(prs) != 1 || prs[0].AutoMergeEnabled {
+		t.Fatalf("without auto-merge API: prs=%+v err=%v", prs, err)
+	}
+	if n := len(mock.CallsTo("GetPRTimeline")); n != 0 {
+		t.Errorf("timeline fetched %d times although auto-merge is unavailable", n)
+	}
+	if err := f.CancelAutoMerge(context.Background(), "org", "app", 1); err != nil || len(mock.CallsTo("CancelAutoMerge")) != 0 {
+		t.Errorf("CancelAutoMerge without the API: err=%v calls=%d", err, len(mock.CallsTo("CancelAutoMerge")))
+	}
+}
+
+func TestForge_EnsureRepoSetup_UsesForgejoWebhook(t *testing.T) {
+	mock := &gitea.MockClient{}
+	f := NewForge(mock, "https://forgejo.example.com", Features{})
+	if f.Kind() != forge.KindForgejo {
+		t.Fatalf("Kind() = %q", f.Kind())
+	}
+	err := f.EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		ExternalURL:   "https://mq.example.com",
+		WebhookSecret: "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := mock.CallsTo("CreateWebhook")
+	if len(hooks) != 1 {
+		t.Fatalf("got %d CreateWebhook calls, want 1", len(hooks))
+	}
+	if got := hooks[0].Args[2].(gitea.CreateWebhookOpts).Config["url"]; got != "https://mq.example.com/webhook/forgejo" {
+		t.Errorf("webhook url = %q", got)
+	}
+}
@@ -38,6 +38,9 @@ type PRRef struct {
 type User struct {
 	ID    int64  `json:"id"`
 	Login string `json:"login"`
+	// Email is only populated by GET /users/{username}, and only when the
+	// user's address is visible to the token (admin, or public profile).
+	Email string `json:"email"`
 }
 
 // TimelineComment represents a comment in a PR's timeline.
@@ -76,10 +79,14 @@ type Branch struct {
 // BranchProtection holds the relevant fields from a branch protection rule.
 // Matches Gitea's BranchProtection API response.
 type BranchProtection struct {
-	BranchName          string   `json:"branch_name"`
-	RuleName            string   `json:"rule_name"`
-	EnableStatusCheck   bool     `json:"enable_status_check"`
-	StatusCheckContexts []string `json:"status_check_contexts"`
+	BranchName             string   `json:"branch_name"`
+	RuleName               string   `json:"rule_name"`
+	EnableStatusCheck      bool     `json:"enable_status_check"`
+	StatusCheckContexts    []string `json:"status_check_contexts"`
+	EnablePush             bool     `json:"enable_push"`
+	EnablePushWhitelist    bool     `json:"enable_push_whitelist"`
+	PushWhitelistUsernames []string `json:"push_whitelist_usernames"`
+	PushWhitelistTeams     []string `json:"push_whitelist_teams"`
 }
 
 // Compare is the response from GET /repos/{owner}/{repo}/compare/{base}...{head}.
@@ -110,6 +117,18 @@ type EditBranchProtectionOpts struct {
 	StatusCheckContexts []string `json:"status_check_contexts"`
 }
 
+// CreateBranchProtectionOpts holds options for creating a branch protection
+// rule via POST /repos/{owner}/{repo}/branch_protections. RuleName may be a
+// glob pattern.
+type CreateBranchProtectionOpts struct {
+	RuleName               string   `json:"rule_name"`
+	EnableStatusCheck      bool     `json:"enable_status_check"`
+	StatusCheckContexts    []string `json:"status_check_contexts"`
+	EnablePush             bool     `json:"enable_push"`
+	EnablePushWhitelist    bool     `json:"enable_push_whitelist"`
+	PushWhitelistUsernames []string `json:"push_whitelist_usernames,omitempty"`
+}
+
 // CreateWebhookOpts holds options for creating a webhook via
 // POST /repos/{owner}/{repo}/hooks.
 type CreateWebhookOpts struct {
@@ -122,15 +141,27 @@ type CreateWebhookOpts struct {
 // Repo represents a repository from the Gitea API.
 // Used by topic-based discovery to list accessible repos and check permissions.
 type Repo struct {
-	FullName    string          `json:"full_name"`
-	Owner       RepoOwner       `json:"owner"`
-	Name        string          `json:"name"`
-	Permissions RepoPermissions `json:"permissions"`
+	FullName      string          `json:"full_name"`
+	Owner         RepoOwner       `json:"owner"`
+	Name          string          `json:"name"`
+	Private       bool            `json:"private"`
+	Permissions   RepoPermissions `json:"permissions"`
+	DefaultBranch string          `json:"default_branch"`
+	// HasPullRequests and the Allow* merge styles decide whether a PR can
+	// be scheduled to auto-merge at all.
+	HasPullRequests       bool `json:"has_pull_requests"`
+	AllowMergeCommits     bool `json:"allow_merge_commits"`
+	AllowRebase           bool `json:"allow_rebase"`
+	AllowRebaseExplicit   bool `json:"allow_rebase_explicit"`
+	AllowSquashMerge      bool `json:"allow_squash_merge"`
+	AllowFastForwardMerge bool `json:"allow_fast_forward_only_merge"`
 }
 
 // RepoOwner holds the owner info from a Gitea repo response.
 type RepoOwner struct {
 	Login string `json:"login"`
+	// Visibility is "public", "limited" (signed-in users only) or "private".
+	Visibility string `json:"visibility"`
 }
 
 // RepoPermissions holds the permission flags from a Gitea repo response.
@@ -159,6 +190,11 @@ type CommitStatusResult struct {
 // Client defines the Gitea API surface used by gitea-mq.
 // All methods accept a context for cancellation and return an error on failure.
 type Client interface {
+	// GetVersion returns the server version, e.g. "1.22.3" for Gitea or
+	// "9.0.1+gitea-1.22.0" for Forgejo.
+	// GET /version
+	GetVersion(ctx context.Context) (string, error)
+
 	// SearchReposByTopic returns all repositories with the given topic.
 	// Uses the search endpoint which, for site admins, returns repos across the
 	// entire instance — not just repos the user owns or collaborates on.
@@ -171,6 +207,18 @@ type Client interface {
 	// GetPR returns a single pull request by index.
 	GetPR(ctx context.Context, owner, repo string, index int64) (*PR, error)
 
+	// GetUser returns a user's public profile.
+	// GET /users/{username}
+	GetUser(ctx context.Context, username string) (*User, error)
+
+	// GetCurrentUser returns the user the token authenticates as.
+	// GET /user
+	GetCurrentUser(ctx context.Context) (*User, error)
+
+	// GetRepo returns a repository's metadata.
+	// GET /repos/{owner}/{repo}
+	GetRepo(ctx context.Context, owner, repo string) (*Repo, error)
+
 	// GetPRTimeline returns timeline comments for a pull request.
 	// Used to detect automerge scheduling via "pull_scheduled_merge" /
 	// "pull_cancel_scheduled_merge" comment types.
@@ -228,6 +276,12 @@ type Client interface {
 	// when branch protection denies the push.
 	FastForwardRef(ctx context.Context, owner, repo, branch, sha string) error
 
+	// PushDryRun runs `git push --dry-run` of branch onto itself, which
+	// checks that the token may push to the repo over smart-HTTP without
+	// changing anything. Server-side hooks do not run, so branch protection
+	// is not consulted.
+	PushDryRun(ctx context.Context, owner, repo, branch string) error
+
 	// EditIssueState sets the state ("open"/"closed") of an issue or PR.
 	// PATCH /repos/{owner}/{repo}/issues/{index}
 	EditIssueState(ctx context.Context, owner, repo string, index int64, state string) error
@@ -240,6 +294,14 @@ type Client interface {
 	// PATCH /repos/{owner}/{repo}/branch_protections/{name}
 	EditBranchProtection(ctx context.Context, owner, repo, name string, opts EditBranchProtectionOpts) error
 
+	// CreateBranchProtection creates a branch protection rule.
+	// POST /repos/{owner}/{repo}/branch_protections
+	CreateBranchProtection(ctx context.Context, owner, repo string, opts CreateBranchProtectionOpts) error
+
+	// DeleteBranchProtection deletes a branch protection rule.
+	// DELETE /repos/{owner}/{repo}/branch_protections/{name}
+	DeleteBranchProtection(ctx context.Context, owner, repo, name string) error
+
 	// ListWebhooks lists all webhooks for a repository.
 	// GET /repos/{owner}/{repo}/hooks
 	ListWebhooks(ctx context.Context, owner, repo string) ([]Webhook, error)
@@ -247,4 +309,8 @@ type Client interface {
 	// CreateWebhook creates a webhook on a repository.
 	// POST /repos/{owner}/{repo}/hooks
 	CreateWebhook(ctx context.Context, owner, repo string, opts CreateWebhookOpts) error
+
+	// DeleteWebhook deletes a webhook from a repository.
+	// DELETE /repos/{owner}/{repo}/hooks/{id}
+	DeleteWebhook(ctx context.Context, owner, repo string, id int64) error
 }
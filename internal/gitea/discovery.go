@@ -9,8 +9,9 @@ import (
 
 // TopicSource lists repos carrying the given topic that the token has admin
 // access to. Admin is required because EnsureRepoSetup mutates branch
-// protection and webhooks.
-func TopicSource(c Client, topic string) func(context.Context) ([]forge.RepoRef, error) {
+// protection and webhooks. host tags the refs, as the same client serves
+// Forgejo and named Gitea instances.
+func TopicSource(c Client, host forge.Host, topic string) func(context.Context) ([]forge.RepoRef, error) {
 	return func(ctx context.Context) ([]forge.RepoRef, error) {
 		repos, err := c.SearchReposByTopic(ctx, topic)
 		if err != nil {
@@ -22,7 +23,7 @@ func TopicSource(c Client, topic string) func(context.Context) ([]forge.RepoRef,
 				slog.Debug("discovery: skipping repo without admin access", "repo", r.FullName)
 				continue
 			}
-			out = append(out, forge.RepoRef{Forge: forge.KindGitea, Owner: r.Owner.Login, Name: r.Name})
+			out = append(out, forge.RepoRef{Forge: host.Kind, Instance: host.Instance, Owner: r.Owner.Login, Name: r.Name})
 		}
 		return out, nil
 	}
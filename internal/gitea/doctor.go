@@ -0,0 +1,213 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+var _ forge.Doctor = (*giteaForge)(nil)
+
+func (f *giteaForge) Diagnose(ctx context.Context, owner, name string, cfg forge.SetupConfig) []forge.DoctorCheck {
+	return Diagnose(ctx, f.client, owner, name, f.webhookURL(cfg))
+}
+
+// Diagnose checks a Gitea or Forgejo repo against what gitea-mq needs, on
+// the repo's default branch. webhookURL is the endpoint deliveries should
+// go to, empty without an external URL.
+func Diagnose(ctx context.Context, client Client, owner, repo, webhookURL string) []forge.DoctorCheck {
+	r, err := client.GetRepo(ctx, owner, repo)
+	if err != nil {
+		return []forge.DoctorCheck{forge.DoctorFailed(forge.DoctorPermissions,
+			"cannot read the repo: "+err.Error(),
+			"give the token user write access to the repo")}
+	}
+	branch := r.DefaultBranch
+	// Reading protection rules needs admin access; without it the checks
+	// that depend on them say so instead of failing.
+	rules, rulesErr := client.ListBranchProtections(ctx, owner, repo)
+	var matching []BranchProtection
+	for _, bp := range rules {
+		if ruleMatches(bp, branch) {
+			matching = append(matching, bp)
+		}
+	}
+
+	return []forge.DoctorCheck{
+		permissionsCheck(r),
+		requiredCheck(branch, matching, rulesErr),
+		webhookCheck(ctx, client, owner, repo, webhookURL),
+		pushCheck(ctx, client, owner, repo, branch, matching, rulesErr),
+		autoMergeCheck(r),
+	}
+}
+
+// ruleMatches reports whether a protection rule covers branch. Rule names
+// are glob patterns since Gitea 1.19; older rules only carry BranchName.
+func ruleMatches(bp BranchProtection, branch string) bool {
+	if bp.BranchName == branch || bp.RuleName == branch {
+		return true
+	}
+	ok, _ := path.Match(bp.RuleName, branch)
+	return ok
+}
+
+func ruleName(bp BranchProtection) string {
+	if bp.RuleName != "" {
+		return bp.RuleName
+	}
+	return bp.BranchName
+}
+
+func permissionsCheck(r *Repo) forge.DoctorCheck {
+	switch {
+	case !r.Permissions.Push:
+		return forge.DoctorFailed(forge.DoctorPermissions,
+			"the token user has no write access",
+			"give the token user write access so it can push merge branches")
+	case !r.Permissions.Admin:
+		return forge.DoctorWarning(forge.DoctorPermissions,
+			"the token user has write but not admin access, so gitea-mq cannot manage branch protection or webhooks",
+			"give the token user admin access, or set up branch protection and the webhook by hand")
+	}
+	return forge.DoctorPassed(forge.DoctorPermissions, "the token user has admin access")
+}
+
+func requiredCheck(branch string, matching []BranchProtection, rulesErr error) forge.DoctorCheck {
+	if rulesErr != nil {
+		return forge.DoctorWarning(forge.DoctorRequiredCheck,
+			"cannot read branch protection: "+rulesErr.Error(),
+			fmt.Sprintf("check by hand that a protection rule for %s requires the %s status", branch, forge.MQContext))
+	}
+	if len(matching) == 0 {
+		return forge.DoctorFailed(forge.DoctorRequiredCheck,
+			fmt.Sprintf("no branch protection rule covers %s, so PRs merge without waiting for the queue", branch),
+			fmt.Sprintf("add a protection rule for %s with status checks enabled; gitea-mq adds itself when it has admin access, or creates the rule with GITEA_MQ_CREATE_BRANCH_PROTECTION", branch))
+	}
+	for _, bp := range matching {
+		if bp.EnableStatusCheck && slices.Contains(bp.StatusCheckContexts, forge.MQContext) {
+			return forge.DoctorPassed(forge.DoctorRequiredCheck,
+				fmt.Sprintf("rule %q requires %s on %s", ruleName(bp), forge.MQContext, branch))
+		}
+	}
+	return forge.DoctorFailed(forge.DoctorRequiredCheck,
+		fmt.Sprintf("no protection rule for %s requires the %s status", branch, forge.MQContext),
+		fmt.Sprintf("enable status checks in rule %q and add %s to them", ruleName(matching[0]), forge.MQContext))
+}
+
+func webhookCheck(ctx context.Context, client Client, owner, repo, webhookURL string) forge.DoctorCheck {
+	if webhookURL == "" {
+		return forge.DoctorWarning(forge.DoctorWebhook,
+			"GITEA_MQ_EXTERNAL_URL is not set, so CI results are only picked up by polling",
+			"set GITEA_MQ_EXTERNAL_URL to the URL the forge reaches gitea-mq at")
+	}
+	hooks, err := client.ListWebhooks(ctx, owner, repo)
+	if err != nil {
+		return forge.DoctorWarning(forge.DoctorWebhook,
+			"cannot list webhooks: "+err.Error(),
+			fmt.Sprintf("check by hand that a webhook for status events points at %s", webhookURL))
+	}
+	var stale []string
+	for _, h := range hooks {
+		url := h.Config["url"]
+		if url != webhookURL {
+			if strings.Contains(url, "/webhook/") {
+				stale = append(stale, url)
+			}
+			continue
+		}
+		switch {
+		case !h.Active:
+			return forge.DoctorFailed(forge.DoctorWebhook,
+				fmt.Sprintf("the webhook to %s is inactive", webhookURL),
+				"activate the webhook")
+		case !slices.Contains(h.Events, "status"):
+			return forge.DoctorFailed(forge.DoctorWebhook,
+				fmt.Sprintf("the webhook to %s does not send status events", webhookURL),
+				"enable the commit status event on the webhook")
+		}
+		return forge.DoctorPassed(forge.DoctorWebhook, "webhook points at "+webhookURL)
+	}
+	detail := "no webhook points at " + webhookURL
+	if len(stale) > 0 {
+		detail += "; found " + strings.Join(stale, ", ") + ", which does not match GITEA_MQ_EXTERNAL_URL"
+	}
+	return forge.DoctorFailed(forge.DoctorWebhook, detail,
+		fmt.Sprintf("create a webhook for status events to %s, or give the token user admin access so gitea-mq creates it", webhookURL))
+}
+
+// pushCheck tries a dry-run push and then looks for protection rules that
+// would reject the real one, since the dry run never reaches the server's
+// protection hooks.
+func pushCheck(ctx context.Context, client Client, owner, repo, branch string, matching []BranchProtection, rulesErr error) forge.DoctorCheck {
+	if err := client.PushDryRun(ctx, owner, repo, branch); err != nil {
+		return forge.DoctorFailed(forge.DoctorPush,
+			fmt.Sprintf("dry-run push to %s failed: %v", branch, err),
+			"give the token user write access to the repo")
+	}
+	if rulesErr != nil {
+		return forge.DoctorWarning(forge.DoctorPush,
+			fmt.Sprintf("dry-run push to %s succeeded, but its push whitelist could not be read", branch),
+			"check by hand that the token user may push to "+branch)
+	}
+	if len(matching) == 0 {
+		return forge.DoctorPassed(forge.DoctorPush, fmt.Sprintf("dry-run push to %s succeeded", branch))
+	}
+	me, err := client.GetCurrentUser(ctx)
+	if err != nil {
+		return forge.DoctorWarning(forge.DoctorPush,
+			"cannot look up the token user: "+err.Error(),
+			"check by hand that the token user is in the push whitelist of "+branch)
+	}
+	for _, bp := range matching {
+		switch {
+		case !bp.EnablePush:
+			return forge.DoctorFailed(forge.DoctorPush,
+				fmt.Sprintf("rule %q disables pushes to %s, so passing PRs cannot be merged", ruleName(bp), branch),
+				fmt.Sprintf("enable push in rule %q with a whitelist containing %s", ruleName(bp), me.Login))
+		case !bp.EnablePushWhitelist || slices.Contains(bp.PushWhitelistUsernames, me.Login):
+			continue
+		case len(bp.PushWhitelistTeams) > 0:
+			return forge.DoctorWarning(forge.DoctorPush,
+				fmt.Sprintf("%s is not in the push whitelist of rule %q by name", me.Login, ruleName(bp)),
+				fmt.Sprintf("make sure %s is in one of the teams %s", me.Login, strings.Join(bp.PushWhitelistTeams, ", ")))
+		default:
+			return forge.DoctorFailed(forge.DoctorPush,
+				fmt.Sprintf("%s is not in the push whitelist of rule %q, so passing PRs cannot be merged", me.Login, ruleName(bp)),
+				fmt.Sprintf("add %s to the push whitelist of rule %q", me.Login, ruleName(bp)))
+		}
+	}
+	return forge.DoctorPassed(forge.DoctorPush,
+		fmt.Sprintf("dry-run push to %s succeeded and %s may push past its protection", branch, me.Login))
+}
+
+func autoMergeCheck(r *Repo) forge.DoctorCheck {
+	if !r.HasPullRequests {
+		return forge.DoctorFailed(forge.DoctorAutoMerge, "pull requests are disabled",
+			"enable pull requests in the repo settings")
+	}
+	var styles []string
+	for _, s := range []struct {
+		on   bool
+		name string
+	}{
+		{r.AllowMergeCommits, "merge"},
+		{r.AllowRebase, "rebase"},
+		{r.AllowRebaseExplicit, "rebase-merge"},
+		{r.AllowSquashMerge, "squash"},
+		{r.AllowFastForwardMerge, "fast-forward-only"},
+	} {
+		if s.on {
+			styles = append(styles, s.name)
+		}
+	}
+	if len(styles) == 0 {
+		return forge.DoctorFailed(forge.DoctorAutoMerge, "no merge style is allowed, so no PR can be scheduled to merge",
+			"allow at least one merge style in the repo settings")
+	}
+	return forge.DoctorPassed(forge.DoctorAutoMerge, "PRs can be scheduled to merge with "+strings.Join(styles, ", "))
+}
@@ -0,0 +1,150 @@
+package gitea_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/gitea"
+)
+
+// healthyMock is a repo gitea-mq can fully manage: admin token, protection
+// requiring gitea-mq with the token user whitelisted, and a webhook.
+func healthyMock() *gitea.MockClient {
+	return &gitea.MockClient{
+		GetRepoFn: func(_ context.Context, _, _ string) (*gitea.Repo, error) {
+			return &gitea.Repo{
+				DefaultBranch:     "main",
+				Permissions:       gitea.RepoPermissions{Admin: true, Push: true, Pull: true},
+				HasPullRequests:   true,
+				AllowMergeCommits: true,
+			}, nil
+		},
+		ListBranchProtectionsFn: func(_ context.Context, _, _ string) ([]gitea.BranchProtection, error) {
+			return []gitea.BranchProtection{{
+				RuleName:               "main",
+				EnableStatusCheck:      true,
+				StatusCheckContexts:    []string{"ci", "gitea-mq"},
+				EnablePush:             true,
+				EnablePushWhitelist:    true,
+				PushWhitelistUsernames: []string{"gitea-mq"},
+			}}, nil
+		},
+		ListWebhooksFn: func(_ context.Context, _, _ string) ([]gitea.Webhook, error) {
+			return []gitea.Webhook{{
+				Config: map[string]string{"url": "https://mq.example.com/webhook/gitea"},
+				Events: []string{"status"},
+				Active: true,
+			}}, nil
+		},
+	}
+}
+
+func diagnose(t *testing.T, mock *gitea.MockClient) map[string]forge.DoctorCheck {
+	t.Helper()
+	d := newForge(mock).(forge.Doctor)
+	checks := d.Diagnose(context.Background(), "org", "app", forge.SetupConfig{ExternalURL: "https://mq.example.com/"})
+	out := map[string]forge.DoctorCheck{}
+	for _, c := range checks {
+		out[c.Name] = c
+	}
+	return out
+}
+
+func TestDiagnose_Healthy(t *testing.T) {
+	checks := diagnose(t, healthyMock())
+	for _, name := range []string{forge.DoctorPermissions, forge.DoctorRequiredCheck, forge.DoctorWebhook, forge.DoctorPush, forge.DoctorAutoMerge} {
+		c, ok := checks[name]
+		if !ok {
+			t.Errorf("%s: missing", name)
+			continue
+		}
+		if c.Status != forge.DoctorPass {
+			t.Errorf("%s: %s (%s), want pass", name, c.Status, c.Detail)
+		}
+	}
+}
+
+func TestDiagnose_Problems(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tweak  func(m *gitea.MockClient)
+		check  string
+		status forge.DoctorStatus
+		detail string
+	}{
+		{
+			name: "token user not in push whitelist",
+			tweak: func(m *gitea.MockClient) {
+				m.GetCurrentUserFn = func(context.Context) (*gitea.User, error) { return &gitea.User{Login: "bot"}, nil }
+			},
+			check: forge.DoctorPush, status: forge.DoctorFail, detail: "bot is not in the push whitelist",
+		},
+		{
+			name: "dry-run push rejected",
+			tweak: func(m *gitea.MockClient) {
+				m.PushDryRunFn = func(context.Context, string, string, string) error { return errors.New("403") }
+			},
+			check: forge.DoctorPush, status: forge.DoctorFail, detail: "dry-run push to main failed",
+		},
+		{
+			name: "gitea-mq not required",
+			tweak: func(m *gitea.MockClient) {
+				m.ListBranchProtectionsFn = func(context.Context, string, string) ([]gitea.BranchProtection, error) {
+					return []gitea.BranchProtection{{RuleName: "ma*", EnableStatusCheck: true, StatusCheckContexts: []string{"ci"}, EnablePush: true}}, nil
+				}
+			},
+			check: forge.DoctorRequiredCheck, status: forge.DoctorFail, detail: "no protection rule for main requires",
+		},
+		{
+			name: "protection unreadable without admin",
+			tweak: func(m *gitea.MockClient) {
+				m.ListBranchProtectionsFn = func(context.Context, string, string) ([]gitea.BranchProtection, error) {
+					return nil, errors.New("403 forbidden")
+				}
+			},
+			check: forge.DoctorRequiredCheck, status: forge.DoctorWarn, detail: "cannot read branch protection",
+		},
+		{
+			name: "webhook points at old URL",
+			tweak: func(m *gitea.MockClient) {
+				m.ListWebhooksFn = func(context.Context, string, string) ([]gitea.Webhook, error) {
+					return []gitea.Webhook{{Config: map[string]string{"url": "https://old.example.com/webhook/gitea"}, Events: []string{"status"}, Active: true}}, nil
+				}
+			},
+			check: forge.DoctorWebhook, status: forge.DoctorFail, detail: "found https://old.example.com/webhook/gitea",
+		},
+		{
+			name: "no merge style",
+			tweak: func(m *gitea.MockClient) {
+				m.GetRepoFn = func(context.Context, string, string) (*gitea.Repo, error) {
+					return &gitea.Repo{DefaultBranch: "main", Permissions: gitea.RepoPermissions{Admin: true, Push: true}, HasPullRequests: true}, nil
+				}
+			},
+			check: forge.DoctorAutoMerge, status: forge.DoctorFail, detail: "no merge style is allowed",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := healthyMock()
+			tc.tweak(m)
+			c := diagnose(t, m)[tc.check]
+			if c.Status != tc.status || !strings.Contains(c.Detail, tc.detail) {
+				t.Errorf("%s: got %s %q, want %s containing %q", tc.check, c.Status, c.Detail, tc.status, tc.detail)
+			}
+			if c.Fix == "" {
+				t.Errorf("%s: no fix suggested", tc.check)
+			}
+		})
+	}
+}
+
+func TestDiagnose_RepoUnreadable(t *testing.T) {
+	m := healthyMock()
+	m.GetRepoFn = func(context.Context, string, string) (*gitea.Repo, error) { return nil, errors.New("404") }
+	checks := newForge(m).(forge.Doctor).Diagnose(context.Background(), "org", "app", forge.SetupConfig{})
+	if len(checks) != 1 || checks[0].Name != forge.DoctorPermissions || checks[0].Status != forge.DoctorFail {
+		t.Errorf("got %+v, want a single failed permissions check", checks)
+	}
+}
@@ -13,24 +13,38 @@ import (
 // MergeConflictError → conflict bool, self-status filtering) so the rest of
 // the system stays forge-agnostic.
 type giteaForge struct {
-	client  Client
-	baseURL string
+	client   Client
+	baseURL  string
+	instance string
 }
 
 // NewForge wraps a Gitea Client as a forge.Forge. baseURL is the Gitea
 // instance root (no trailing /api/v1), used for HTML URL construction.
 func NewForge(client Client, baseURL string) forge.Forge {
+	return NewNamedForge("", client, baseURL)
+}
+
+// NewNamedForge is NewForge for one of several Gitea servers. instance
+// becomes RepoRef.Instance of its repos and selects the webhook endpoint
+// /webhook/gitea/<instance>; empty means the default server.
+func NewNamedForge(instance string, client Client, baseURL string) forge.Forge {
 	return &giteaForge{
-		client:  client,
-		baseURL: strings.TrimRight(baseURL, "/"),
+		client:   client,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		instance: instance,
 	}
 }
 
 var (
-	_ forge.Forge        = (*giteaForge)(nil)
-	_ forge.MergeStacker = (*giteaForge)(nil)
+	_ forge.Forge          = (*giteaForge)(nil)
+	_ forge.MergeStacker   = (*giteaForge)(nil)
+	_ forge.EmailResolver  = (*giteaForge)(nil)
+	_ forge.RepoVisibility = (*giteaForge)(nil)
+	_ forge.Instanced      = (*giteaForge)(nil)
 )
 
+func (f *giteaForge) Instance() string { return f.instance }
+
 // StackMerges builds the batch branch in one clone instead of one per member.
 func (f *giteaForge) StackMerges(ctx context.Context, owner, repo, base string, heads []string, branch string) (string, []forge.MergeStep, error) {
 	tip, steps, err := f.client.StackMerges(ctx, owner, repo, base, heads, branch)
@@ -44,6 +58,27 @@ func (f *giteaForge) StackMerges(ctx context.Context, owner, repo, base string,
 	return tip, out, nil
 }
 
+// UserEmail returns the profile address. Gitea reports hidden addresses as
+// empty (or a noreply placeholder) unless the token belongs to an admin.
+func (f *giteaForge) UserEmail(ctx context.Context, _, _, login string) (string, error) {
+	u, err := f.client.GetUser(ctx, login)
+	if err != nil {
+		return "", err
+	}
+	return u.Email, nil
+}
+
+// RepoPrivate reports whether anonymous users are denied the repo. Repos of
+// limited or private owners are not flagged private by Gitea but are hidden
+// all the same.
+func (f *giteaForge) RepoPrivate(ctx context.Context, owner, name string) (bool, error) {
+	r, err := f.client.GetRepo(ctx, owner, name)
+	if err != nil {
+		return false, err
+	}
+	return r.Private || (r.Owner.Visibility != "" && r.Owner.Visibility != "public"), nil
+}
+
 func (f *giteaForge) Kind() forge.Kind { return forge.KindGitea }
 
 // Gitea/Forgejo have no commit-status webhook; CI results are polled.
@@ -59,7 +94,9 @@ func (f *giteaForge) BranchHTMLURL(owner, name, branch string) string {
 	return fmt.Sprintf("%s/%s/%s/src/branch/%s", f.baseURL, owner, name, branch)
 }
 
-func toForgePR(pr *PR, autoMerge bool) forge.PR {
+// ToForgePR converts a Gitea PR; autoMerge comes from the caller's reading
+// of the timeline.
+func ToForgePR(pr *PR, autoMerge bool) forge.PR {
 	out := forge.PR{
 		Number:           pr.Index,
 		Title:            pr.Title,
@@ -95,7 +132,7 @@ func (f *giteaForge) ListOpenPRs(ctx context.Context, owner, name string) ([]for
 		if err != nil {
 			return nil, fmt.Errorf("get timeline for PR #%d: %w", prs[i].Index, err)
 		}
-		out = append(out, toForgePR(&prs[i], HasAutomergeScheduled(timeline)))
+		out = append(out, ToForgePR(&prs[i], HasAutomergeScheduled(timeline)))
 	}
 	return out, nil
 }
@@ -109,7 +146,7 @@ func (f *giteaForge) GetPR(ctx context.Context, owner, name string, number int64
 	if err != nil {
 		return nil, err
 	}
-	fp := toForgePR(pr, HasAutomergeScheduled(timeline))
+	fp := ToForgePR(pr, HasAutomergeScheduled(timeline))
 	return &fp, nil
 }
 
@@ -253,7 +290,7 @@ func (f *giteaForge) Comment(ctx context.Context, owner, name string, number int
 }
 
 func (f *giteaForge) EnsureRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
-	if err := EnsureBranchProtection(ctx, f.client, owner, name); err != nil {
+	if err := EnsureBranchProtection(ctx, f.client, owner, name, cfg); err != nil {
 		return err
 	}
 	if cfg.ExternalURL == "" {
@@ -261,6 +298,28 @@ func (f *giteaForge) EnsureRepoSetup(ctx context.Context, owner, name string, cf
 		// reconcile poll covers us.
 		return nil
 	}
-	webhookURL := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/gitea"
-	return EnsureWebhook(ctx, f.client, owner, name, webhookURL, cfg.WebhookSecret)
+	return EnsureWebhook(ctx, f.client, owner, name, f.webhookURL(cfg), cfg.WebhookSecret)
+}
+
+func (f *giteaForge) TeardownRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	if err := RemoveBranchProtection(ctx, f.client, owner, name, cfg.CreatedRules); err != nil {
+		return err
+	}
+	if cfg.ExternalURL == "" {
+		return nil
+	}
+	return RemoveWebhook(ctx, f.client, owner, name, f.webhookURL(cfg))
+}
+
+// webhookURL is this instance's webhook endpoint, or "" without an external
+// URL.
+func (f *giteaForge) webhookURL(cfg forge.SetupConfig) string {
+	if cfg.ExternalURL == "" {
+		return ""
+	}
+	u := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/gitea"
+	if f.instance != "" {
+		u += "/" + f.instance
+	}
+	return u
 }
@@ -2,7 +2,10 @@ package gitea_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"slices"
 	"testing"
 
@@ -226,6 +229,29 @@ func TestForge_EnsureRepoSetup_BuildsWebhookURL(t *testing.T) {
 	}
 }
 
+// A named instance gets its own endpoint so deliveries are attributed to it.
+func TestForge_EnsureRepoSetup_NamedInstanceWebhookURL(t *testing.T) {
+	mock := &gitea.MockClient{}
+	f := gitea.NewNamedForge("internal", mock, "https://git.corp")
+	if h := forge.HostOf(f); h.String() != "gitea@internal" {
+		t.Errorf("HostOf = %q, want gitea@internal", h)
+	}
+	err := f.EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		ExternalURL:   "https://mq.example.com",
+		WebhookSecret: "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := mock.CallsTo("CreateWebhook")
+	if len(hooks) != 1 {
+		t.Fatalf("got %d CreateWebhook calls, want 1", len(hooks))
+	}
+	if got := hooks[0].Args[2].(gitea.CreateWebhookOpts).Config["url"]; got != "https://mq.example.com/webhook/gitea/internal" {
+		t.Errorf("webhook url = %q, want https://mq.example.com/webhook/gitea/internal", got)
+	}
+}
+
 func TestForge_EnsureRepoSetup_NoExternalURLSkipsWebhook(t *testing.T) {
 	mock := &gitea.MockClient{}
 	f := newForge(mock)
@@ -241,6 +267,168 @@ func TestForge_EnsureRepoSetup_NoExternalURLSkipsWebhook(t *testing.T) {
 	}
 }
 
+func TestForge_EnsureRepoSetup_CreatesProtection(t *testing.T) {
+	newMock := func() *gitea.MockClient {
+		return &gitea.MockClient{
+			GetRepoFn: func(_ context.Context, _, _ string) (*gitea.Repo, error) {
+				return &gitea.Repo{DefaultBranch: "main"}, nil
+			},
+			GetCurrentUserFn: func(_ context.Context) (*gitea.User, error) {
+				return &gitea.User{Login: "mq-bot"}, nil
+			},
+			ListBranchProtectionsFn: func(_ context.Context, _, _ string) ([]gitea.BranchProtection, error) {
+				return []gitea.BranchProtection{{RuleName: "release/*", StatusCheckContexts: []string{"gitea-mq"}}}, nil
+			},
+		}
+	}
+
+	// Opt-in off: only existing rules are touched.
+	mock := newMock()
+	if err := newForge(mock).EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		BranchPatterns: []string{"stable/*"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := mock.CallsTo("CreateBranchProtection"); len(calls) != 0 {
+		t.Fatalf("created %d rules without opt-in", len(calls))
+	}
+
+	mock = newMock()
+	err := newForge(mock).EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		BranchPatterns:   []string{"release/*", "stable/*"},
+		CreateProtection: true,
+		RequiredChecks:   []string{"ci/build"},
+		PushesTargets:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := mock.CallsTo("CreateBranchProtection")
+	var names []string
+	for _, c := range calls {
+		opts := c.Args[2].(gitea.CreateBranchProtectionOpts)
+		names = append(names, opts.RuleName)
+		if !opts.EnableStatusCheck || !slices.Equal(opts.StatusCheckContexts, []string{"gitea-mq", "ci/build"}) {
+			t.Errorf("rule %s checks = %v", opts.RuleName, opts.StatusCheckContexts)
+		}
+		if !opts.EnablePush || !opts.EnablePushWhitelist || !slices.Equal(opts.PushWhitelistUsernames, []string{"mq-bot"}) {
+			t.Errorf("rule %s does not let the token user push: %+v", opts.RuleName, opts)
+		}
+	}
+	if !slices.Equal(names, []string{"main", "stable/*"}) {
+		t.Errorf("created rules %v, want [main stable/*]", names)
+	}
+}
+
+func TestForge_TeardownRepoSetup(t *testing.T) {
+	mock := &gitea.MockClient{
+		ListBranchProtectionsFn: func(_ context.Context, _, _ string) ([]gitea.BranchProtection, error) {
+			return []gitea.BranchProtection{
+				{RuleName: "main", EnableStatusCheck: true, StatusCheckContexts: []string{"ci", "gitea-mq"}},
+				{RuleName: "release/*", EnableStatusCheck: true, StatusCheckContexts: []string{"gitea-mq"}},
+				{RuleName: "legacy", StatusCheckContexts: []string{"ci"}},
+			}, nil
+		},
+		ListWebhooksFn: func(_ context.Context, _, _ string) ([]gitea.Webhook, error) {
+			return []gitea.Webhook{
+				{ID: 1, Config: map[string]string{"url": "https://ci.example.com/hook"}},
+				{ID: 2, Config: map[string]string{"url": "https://mq.example.com/webhook/gitea"}},
+			}, nil
+		},
+	}
+	err := newForge(mock).TeardownRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		ExternalURL: "https://mq.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edits := mock.CallsTo("EditBranchProtection")
+	if len(edits) != 2 {
+		t.Fatalf("got %d EditBranchProtection calls, want 2", len(edits))
+	}
+	mainRule := edits[0].Args[3].(gitea.EditBranchProtectionOpts)
+	if !slices.Equal(mainRule.StatusCheckContexts, []string{"ci"}) || !*mainRule.EnableStatusCheck {
+		t.Errorf("main = %+v, want ci still required", mainRule)
+	}
+	// Without contexts Gitea would wait for every status, so checks go off.
+	release := edits[1].Args[3].(gitea.EditBranchProtectionOpts)
+	if len(release.StatusCheckContexts) != 0 || *release.EnableStatusCheck {
+		t.Errorf("release/* = %+v, want status checks disabled", release)
+	}
+
+	deletes := mock.CallsTo("DeleteWebhook")
+	if len(deletes) != 1 || deletes[0].Args[2] != int64(2) {
+		t.Errorf("DeleteWebhook calls = %+v, want hook 2 only", deletes)
+	}
+}
+
+// Rules gitea-mq created are deleted on teardown; a rule the operator had
+// before only loses gitea-mq, even though it looks the same.
+func TestForge_TeardownRepoSetup_DeletesCreatedRules(t *testing.T) {
+	existing := gitea.BranchProtection{RuleName: "release/*", EnableStatusCheck: true, StatusCheckContexts: []string{"gitea-mq", "ci/build"}}
+	rules := []gitea.BranchProtection{existing}
+	mock := &gitea.MockClient{
+		GetRepoFn: func(_ context.Context, _, _ string) (*gitea.Repo, error) {
+			return &gitea.Repo{DefaultBranch: "main"}, nil
+		},
+		GetCurrentUserFn: func(_ context.Context) (*gitea.User, error) {
+			return &gitea.User{Login: "mq-bot"}, nil
+		},
+		ListBranchProtectionsFn: func(_ context.Context, _, _ string) ([]gitea.BranchProtection, error) {
+			return slices.Clone(rules), nil
+		},
+		CreateBranchProtectionFn: func(_ context.Context, _, _ string, opts gitea.CreateBranchProtectionOpts) error {
+			rules = append(rules, gitea.BranchProtection{
+				RuleName:               opts.RuleName,
+				EnableStatusCheck:      opts.EnableStatusCheck,
+				StatusCheckContexts:    opts.StatusCheckContexts,
+				EnablePush:             opts.EnablePush,
+				EnablePushWhitelist:    opts.EnablePushWhitelist,
+				PushWhitelistUsernames: opts.PushWhitelistUsernames,
+			})
+			return nil
+		},
+	}
+	f := newForge(mock)
+
+	var created []string
+	err := f.EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		BranchPatterns:   []string{"release/*", "stable/*"},
+		CreateProtection: true,
+		RequiredChecks:   []string{"ci/build"},
+		PushesTargets:    true,
+		RuleCreated: func(_ context.Context, rule string) error {
+			created = append(created, rule)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(created, []string{"main", "stable/*"}) {
+		t.Fatalf("recorded %v, want [main stable/*]", created)
+	}
+
+	if err := f.TeardownRepoSetup(context.Background(), "org", "app", forge.SetupConfig{CreatedRules: created}); err != nil {
+		t.Fatal(err)
+	}
+	var deleted []string
+	for _, c := range mock.CallsTo("DeleteBranchProtection") {
+		deleted = append(deleted, c.Args[2].(string))
+	}
+	if !slices.Equal(deleted, []string{"main", "stable/*"}) {
+		t.Errorf("deleted %v, want [main stable/*]", deleted)
+	}
+	edits := mock.CallsTo("EditBranchProtection")
+	if len(edits) != 1 || edits[0].Args[2] != "release/*" {
+		t.Fatalf("EditBranchProtection calls = %+v, want release/* only", edits)
+	}
+	if opts := edits[0].Args[3].(gitea.EditBranchProtectionOpts); !slices.Equal(opts.StatusCheckContexts, []string{"ci/build"}) {
+		t.Errorf("release/* keeps %v, want [ci/build]", opts.StatusCheckContexts)
+	}
+}
+
 func TestForge_URLHelpers(t *testing.T) {
 	f := gitea.NewForge(&gitea.MockClient{}, "https://gitea.example.com/")
 	if got := f.RepoHTMLURL("org", "app"); got != "https://gitea.example.com/org/app" {
@@ -250,3 +438,63 @@ func TestForge_URLHelpers(t *testing.T) {
 		t.Errorf("BranchHTMLURL = %q", got)
 	}
 }
+
+func TestForge_UserEmail(t *testing.T) {
+	mock := &gitea.MockClient{
+		GetUserFn: func(_ context.Context, login string) (*gitea.User, error) {
+			return &gitea.User{Login: login, Email: login + "@example.com"}, nil
+		},
+	}
+	r, ok := newForge(mock).(forge.EmailResolver)
+	if !ok {
+		t.Fatal("gitea forge does not implement EmailResolver")
+	}
+	got, err := r.UserEmail(context.Background(), "org", "app", "alice")
+	if err != nil || got != "alice@example.com" {
+		t.Fatalf("UserEmail = %q, %v", got, err)
+	}
+	if calls := mock.CallsTo("GetUser"); len(calls) != 1 || calls[0].Args[0] != "alice" {
+		t.Errorf("GetUser calls = %+v", calls)
+	}
+}
+
+// Logins come from webhook payloads and must not reshape the request path.
+func TestGetUser_EscapesLogin(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.EscapedPath()
+		_ = json.NewEncoder(w).Encode(gitea.User{Login: "x"})
+	}))
+	defer srv.Close()
+
+	if _, err := gitea.NewHTTPClient(srv.URL, "t").GetUser(context.Background(), "../admin?x"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/api/v1/users/..%2Fadmin%3Fx"; got != want {
+		t.Errorf("path = %q, want %q", got, want)
+	}
+}
+
+// Public repos of limited/private owners are still hidden from anonymous users.
+func TestForge_RepoPrivate(t *testing.T) {
+	repos := map[string]*gitea.Repo{
+		"public":  {Owner: gitea.RepoOwner{Visibility: "public"}},
+		"private": {Private: true, Owner: gitea.RepoOwner{Visibility: "public"}},
+		"limited": {Owner: gitea.RepoOwner{Visibility: "limited"}},
+	}
+	mock := &gitea.MockClient{
+		GetRepoFn: func(_ context.Context, _, name string) (*gitea.Repo, error) {
+			return repos[name], nil
+		},
+	}
+	v, ok := newForge(mock).(forge.RepoVisibility)
+	if !ok {
+		t.Fatal("gitea forge does not implement RepoVisibility")
+	}
+	for name, want := range map[string]bool{"public": false, "private": true, "limited": true} {
+		got, err := v.RepoPrivate(context.Background(), "org", name)
+		if err != nil || got != want {
+			t.Errorf("RepoPrivate(%s) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+}
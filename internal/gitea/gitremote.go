@@ -0,0 +1,227 @@
+package gitea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitRemote merges and pushes through the persistent git cache for one forge
+// instance. Neither Gitea nor GitLab can merge two arbitrary refs into a new
+// branch over their APIs, so both adapters build merge commits here.
+type GitRemote struct {
+	baseURL string
+	secret  string
+	cache   *gitCache
+}
+
+// NewGitRemote returns a GitRemote for repos cloned from baseURL. auth is
+// sent as the Authorization header of every git request (empty for none);
+// secret is stripped from git output before it reaches logs or PR comments.
+func NewGitRemote(baseURL, auth, secret string) *GitRemote {
+	g := &GitRemote{baseURL: strings.TrimRight(baseURL, "/"), secret: secret}
+	// Git auth via extraHeader; see gitcache.go for why.
+	var authFlags []string
+	if auth != "" {
+		authFlags = []string{"-c", "http." + g.baseURL + "/.extraheader=Authorization: " + auth}
+	}
+	g.cache = newGitCache(filepath.Join(os.TempDir(), "gitea-mq-cache"), authFlags, g.redact)
+	return g
+}
+
+// SetCacheDir points the persistent git cache at dir. Call before any
+// merge/push operation; the default is a directory under os.TempDir().
+func (g *GitRemote) SetCacheDir(dir string) {
+	g.cache.baseDir = dir
+}
+
+// CleanupCache removes cached repositories not used within maxAge.
+func (g *GitRemote) CleanupCache(maxAge time.Duration) {
+	g.cache.cleanupStale(maxAge)
+}
+
+func (g *GitRemote) redact(s string) string {
+	if g.secret == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, g.secret, "***")
+}
+
+// MergeBranches creates a merge commit of head into base and pushes it as
+// branchName, entirely inside the persistent git cache. Conflicts are
+// returned as MergeConflictError.
+func (g *GitRemote) MergeBranches(ctx context.Context, owner, repo, base, head, branchName string) (*MergeResult, error) {
+	refs := []string{"+refs/heads/" + base + ":refs/heads/" + base, head}
+	var result *MergeResult
+	err := g.cache.withRepo(ctx, g.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
+		sha, conflictOut, err := mergeCommit(run, "refs/heads/"+base, head, "mq: merge "+head+" into "+base)
+		if err != nil {
+			return fmt.Errorf("merge: %w", err)
+		}
+		if sha == "" {
+			return &MergeConflictError{Base: base, Head: head, Message: conflictOut}
+		}
+		if _, err := run("push", "--quiet", "origin", sha+":refs/heads/"+branchName); err != nil {
+			return fmt.Errorf("push: %w", err)
+		}
+		result = &MergeResult{SHA: sha}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("created merge branch", "branch", branchName, "sha", shortSHA(result.SHA))
+	return result, nil
+}
+
+// gitRunFunc executes one git command inside the cached repository.
+type gitRunFunc = func(args ...string) (string, error)
+
+// mergeCommit merges head into base in-memory (merge-tree + commit-tree) and
+// returns the merge commit SHA; a conflict returns an empty SHA plus
+// merge-tree's report. A merge commit is created even when fast-forward would
+// be possible, so CI always sees the combined result (like merge --no-ff).
+func mergeCommit(run gitRunFunc, base, head, msg string) (sha, conflictOut string, err error) {
+	out, err := run("merge-tree", "--write-tree", base, head)
+	if err != nil {
+		// merge-tree exits 1 on content conflicts and >1 on real errors.
+		if gitExitCode(err) == 1 {
+			return "", out, nil
+		}
+		return "", "", err
+	}
+	tree := strings.TrimSpace(out)
+	commit, err := run("commit-tree", tree, "-p", base, "-p", head, "-m", msg)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(commit), "", nil
+}
+
+// gitExitCode extracts the git process exit code from a runner error, or -1.
+func gitExitCode(err error) int {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode()
+	}
+	return -1
+}
+
+// StackStep is the per-head outcome of StackMerges.
+type StackStep struct {
+	Conflict bool
+	Err      error
+}
+
+// StackMerges merges each head onto base in order inside the cached repo and
+// pushes the result as branch. On a per-head conflict or fetch failure the
+// step is marked and subsequent heads merge onto the pre-failure tip. A
+// cache or final-push failure is returned as err so the caller can retry the
+// whole build instead of mis-attributing a transient error to one PR.
+func (g *GitRemote) StackMerges(ctx context.Context, owner, repo, base string, heads []string, branch string) (string, []StackStep, error) {
+	steps := make([]StackStep, len(heads))
+	refs := []string{"+refs/heads/" + base + ":refs/heads/" + base}
+	var tip string
+	err := g.cache.withRepo(ctx, g.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
+		current := "refs/heads/" + base
+		for i, head := range heads {
+			// Heads are fetched one by one so a vanished head SHA fails only
+			// its own step, not the whole batch.
+			if _, err := run("fetch", "--quiet", "--filter=blob:none", "origin", head); err != nil {
+				steps[i].Err = fmt.Errorf("fetch %s: %w", shortSHA(head), err)
+				continue
+			}
+			sha, _, err := mergeCommit(run, current, head,
+				fmt.Sprintf("mq: merge %s into %s", shortSHA(head), base))
+			if err != nil {
+				steps[i].Err = fmt.Errorf("merge %s: %w", shortSHA(head), err)
+				continue
+			}
+			if sha == "" {
+				steps[i].Conflict = true
+				continue
+			}
+			current, tip = sha, sha
+		}
+		if tip == "" {
+			return nil
+		}
+		if _, err := run("push", "--quiet", "origin", tip+":refs/heads/"+branch); err != nil {
+			return fmt.Errorf("push %s: %w", branch, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if tip != "" {
+		slog.Debug("stack-merge built", "branch", branch, "heads", len(heads), "tip", shortSHA(tip))
+	}
+	return tip, steps, nil
+}
+
+// cloneURL returns the repo's plain HTTPS clone URL; authentication is
+// injected per git invocation via an extraHeader, never stored in the URL.
+func (g *GitRemote) cloneURL(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", g.baseURL, owner, repo)
+}
+
+// FastForwardRef pushes sha to refs/heads/branch with a non-force refspec
+// straight from the cached repo. git's client-side fast-forward check needs
+// sha's ancestry back to the current branch tip, so both are fetched first;
+// the push pack itself is empty because the server already has sha.
+func (g *GitRemote) FastForwardRef(ctx context.Context, owner, repo, branch, sha string) error {
+	refs := []string{"+refs/heads/" + branch + ":refs/heads/" + branch, sha}
+	return g.cache.withRepo(ctx, g.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
+		out, err := run("push", "--porcelain", "origin", sha+":refs/heads/"+branch)
+		if err == nil {
+			return nil
+		}
+		return classifyPushFailure(branch, sha, g.redact(out), err)
+	})
+}
+
+// PushDryRun pushes branch onto itself with --dry-run. git still asks the
+// server's receive-pack for its refs, which is where a token without write
+// access is turned away, but nothing is sent or updated.
+func (g *GitRemote) PushDryRun(ctx context.Context, owner, repo, branch string) error {
+	refs := []string{"+refs/heads/" + branch + ":refs/heads/" + branch}
+	return g.cache.withRepo(ctx, g.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
+		_, err := run("push", "--dry-run", "--porcelain", "origin", "refs/heads/"+branch+":refs/heads/"+branch)
+		return err
+	})
+}
+
+// classifyPushFailure maps a failed `git push --porcelain` to a typed error
+// by parsing its rejection line: "! <from>:<to> <summary> (<reason>)".
+// Client-side ancestry rejections carry fixed reasons; "[remote rejected]"
+// carries the server hook's message, i.e. branch protection denied the push.
+// Output without a rejection line stays a generic error.
+func classifyPushFailure(branch, sha, out string, err error) error {
+	for line := range strings.Lines(out) {
+		flag, rest, ok := strings.Cut(line, "\t")
+		if !ok || flag != "!" {
+			continue
+		}
+		_, result, ok := strings.Cut(rest, "\t")
+		if !ok {
+			continue
+		}
+		summary, reason, _ := strings.Cut(strings.TrimSpace(result), " (")
+		reason = strings.TrimSuffix(reason, ")")
+		if summary == "[remote rejected]" {
+			return &ProtectedBranchError{Branch: branch, Message: reason}
+		}
+		switch reason {
+		case "non-fast-forward", "fetch first", "needs force", "stale info":
+			return &NotFastForwardError{Branch: branch, SHA: sha}
+		}
+	}
+	return fmt.Errorf("git push %s: %w", branch, err)
+}
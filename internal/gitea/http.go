@@ -9,11 +9,12 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/Mic92/gitea-mq/internal/httpcache"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
 )
 
 // HTTPClient implements Client using Gitea's REST API over HTTP.
@@ -21,35 +22,38 @@ type HTTPClient struct {
 	baseURL    string
 	token      string
 	httpClient *http.Client
-	gitCache   *gitCache
+	git        *GitRemote
 }
 
 // NewHTTPClient creates a new HTTP-based Gitea API client.
 func NewHTTPClient(baseURL, token string) *HTTPClient {
+	// The budget sits under the cache so it sees the server's fresh
+	// rate-limit headers on a 304, not the cached ones.
+	name := strings.TrimRight(baseURL, "/")
+	cache := httpcache.New(name, ratelimit.NewTransport(nil, ratelimit.NewBudget(name)), httpcache.DefaultMaxBytes)
 	c := &HTTPClient{
-		baseURL:    strings.TrimRight(baseURL, "/"),
+		baseURL:    name,
 		token:      token,
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Transport: cache},
 	}
-	// Git auth via extraHeader; see gitcache.go for why.
-	var authFlags []string
+	var auth string
 	if token != "" {
-		authFlags = []string{"-c", "http." + c.baseURL + "/.extraheader=Authorization: token " + token}
+		auth = "token " + token
 	}
-	c.gitCache = newGitCache(filepath.Join(os.TempDir(), "gitea-mq-cache"), authFlags, c.redact)
+	c.git = NewGitRemote(c.baseURL, auth, token)
 	return c
 }
 
 // SetGitCacheDir points the persistent git cache at dir. Call before any
 // merge/push operation; the default is a directory under os.TempDir().
 func (c *HTTPClient) SetGitCacheDir(dir string) {
-	c.gitCache.baseDir = dir
+	c.git.SetCacheDir(dir)
 }
 
 // CleanupGitCache removes cached repositories not used within maxAge, e.g.
 // repositories removed from the configuration. Call at startup.
 func (c *HTTPClient) CleanupGitCache(maxAge time.Duration) {
-	c.gitCache.cleanupStale(maxAge)
+	c.git.CleanupCache(maxAge)
 }
 
 // do executes an HTTP request with authentication and returns the response.
@@ -224,6 +228,23 @@ type repoSearchResponse struct {
 	Data []Repo `json:"data"`
 }
 
+// GetVersion returns the server version string.
+func (c *HTTPClient) GetVersion(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/version", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := c.decodeJSON(resp, &v); err != nil {
+		return "", fmt.Errorf("get server version: %w", err)
+	}
+
+	return v.Version, nil
+}
+
 // SearchReposByTopic returns all repositories with the given topic.
 // Uses the search endpoint which, for site admins, returns repos across the
 // entire instance — not just repos the user owns or collaborates on.
@@ -259,6 +280,51 @@ func (c *HTTPClient) GetPR(ctx context.Context, owner, repo string, index int64)
 	return &pr, nil
 }
 
+// GetUser returns a user's profile by login.
+func (c *HTTPClient) GetUser(ctx context.Context, username string) (*User, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(username), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u User
+	if err := c.decodeJSON(resp, &u); err != nil {
+		return nil, fmt.Errorf("get user %s: %w", username, err)
+	}
+
+	return &u, nil
+}
+
+// GetCurrentUser returns the user the token authenticates as.
+func (c *HTTPClient) GetCurrentUser(ctx context.Context) (*User, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/user", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u User
+	if err := c.decodeJSON(resp, &u); err != nil {
+		return nil, fmt.Errorf("get current user: %w", err)
+	}
+
+	return &u, nil
+}
+
+// GetRepo returns a repository's metadata.
+func (c *HTTPClient) GetRepo(ctx context.Context, owner, repo string) (*Repo, error) {
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s", owner, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Repo
+	if err := c.decodeJSON(resp, &r); err != nil {
+		return nil, fmt.Errorf("get repo %s/%s: %w", owner, repo, err)
+	}
+
+	return &r, nil
+}
+
 // GetPRTimeline returns timeline comments for a pull request.
 // Handles pagination. The endpoint is GET /repos/{owner}/{repo}/issues/{index}/timeline.
 func (c *HTTPClient) GetPRTimeline(ctx context.Context, owner, repo string, index int64) ([]TimelineComment, error) {
@@ -402,176 +468,28 @@ func (c *HTTPClient) DeleteBranch(ctx context.Context, owner, repo, name string)
 	return nil
 }
 
-// redact strips the API token from git output before it reaches logs or PR
-// comments.
-func (c *HTTPClient) redact(s string) string {
-	if c.token == "" {
-		return s
-	}
-	return strings.ReplaceAll(s, c.token, "***")
-}
-
 // MergeBranches creates a merge commit of head into base and pushes it as
-// branchName, entirely inside the persistent git cache (Gitea has no API to
-// merge two arbitrary refs into a new branch). Conflicts are returned as
-// MergeConflictError.
+// branchName. Gitea has no API to merge two arbitrary refs into a new
+// branch, so this runs in the git cache; see GitRemote.
 func (c *HTTPClient) MergeBranches(ctx context.Context, owner, repo, base, head, branchName string) (*MergeResult, error) {
-	refs := []string{"+refs/heads/" + base + ":refs/heads/" + base, head}
-	var result *MergeResult
-	err := c.gitCache.withRepo(ctx, c.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
-		sha, conflictOut, err := mergeCommit(run, "refs/heads/"+base, head, "mq: merge "+head+" into "+base)
-		if err != nil {
-			return fmt.Errorf("merge: %w", err)
-		}
-		if sha == "" {
-			return &MergeConflictError{Base: base, Head: head, Message: conflictOut}
-		}
-		if _, err := run("push", "--quiet", "origin", sha+":refs/heads/"+branchName); err != nil {
-			return fmt.Errorf("push: %w", err)
-		}
-		result = &MergeResult{SHA: sha}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	slog.Debug("created merge branch", "branch", branchName, "sha", shortSHA(result.SHA))
-	return result, nil
-}
-
-// gitRunFunc executes one git command inside the cached repository.
-type gitRunFunc = func(args ...string) (string, error)
-
-// mergeCommit merges head into base in-memory (merge-tree + commit-tree) and
-// returns the merge commit SHA; a conflict returns an empty SHA plus
-// merge-tree's report. A merge commit is created even when fast-forward would
-// be possible, so CI always sees the combined result (like merge --no-ff).
-func mergeCommit(run gitRunFunc, base, head, msg string) (sha, conflictOut string, err error) {
-	out, err := run("merge-tree", "--write-tree", base, head)
-	if err != nil {
-		// merge-tree exits 1 on content conflicts and >1 on real errors.
-		if gitExitCode(err) == 1 {
-			return "", out, nil
-		}
-		return "", "", err
-	}
-	tree := strings.TrimSpace(out)
-	commit, err := run("commit-tree", tree, "-p", base, "-p", head, "-m", msg)
-	if err != nil {
-		return "", "", err
-	}
-	return strings.TrimSpace(commit), "", nil
-}
-
-// gitExitCode extracts the git process exit code from a runner error, or -1.
-func gitExitCode(err error) int {
-	var ee *exec.ExitError
-	if errors.As(err, &ee) {
-		return ee.ExitCode()
-	}
-	return -1
+	return c.git.MergeBranches(ctx, owner, repo, base, head, branchName)
 }
 
-// StackStep is the per-head outcome of StackMerges.
-type StackStep struct {
-	Conflict bool
-	Err      error
-}
-
-// StackMerges merges each head onto base in order inside the cached repo and
-// pushes the result as branch. On a per-head conflict or fetch failure the
-// step is marked and subsequent heads merge onto the pre-failure tip. A
-// cache or final-push failure is returned as err so the caller can retry the
-// whole build instead of mis-attributing a transient error to one PR.
+// StackMerges merges each head onto base in order and pushes the result as
+// branch; see GitRemote.StackMerges.
 func (c *HTTPClient) StackMerges(ctx context.Context, owner, repo, base string, heads []string, branch string) (string, []StackStep, error) {
-	steps := make([]StackStep, len(heads))
-	refs := []string{"+refs/heads/" + base + ":refs/heads/" + base}
-	var tip string
-	err := c.gitCache.withRepo(ctx, c.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
-		current := "refs/heads/" + base
-		for i, head := range heads {
-			// Heads are fetched one by one so a vanished head SHA fails only
-			// its own step, not the whole batch.
-			if _, err := run("fetch", "--quiet", "--filter=blob:none", "origin", head); err != nil {
-				steps[i].Err = fmt.Errorf("fetch %s: %w", shortSHA(head), err)
-				continue
-			}
-			sha, _, err := mergeCommit(run, current, head,
-				fmt.Sprintf("mq: merge %s into %s", shortSHA(head), base))
-			if err != nil {
-				steps[i].Err = fmt.Errorf("merge %s: %w", shortSHA(head), err)
-				continue
-			}
-			if sha == "" {
-				steps[i].Conflict = true
-				continue
-			}
-			current, tip = sha, sha
-		}
-		if tip == "" {
-			return nil
-		}
-		if _, err := run("push", "--quiet", "origin", tip+":refs/heads/"+branch); err != nil {
-			return fmt.Errorf("push %s: %w", branch, err)
-		}
-		return nil
-	})
-	if err != nil {
-		return "", nil, err
-	}
-	if tip != "" {
-		slog.Debug("stack-merge built", "branch", branch, "heads", len(heads), "tip", shortSHA(tip))
-	}
-	return tip, steps, nil
+	return c.git.StackMerges(ctx, owner, repo, base, heads, branch)
 }
 
-// cloneURL returns the repo's plain HTTPS clone URL; authentication is
-// injected per git invocation via an extraHeader, never stored in the URL.
-func (c *HTTPClient) cloneURL(owner, repo string) string {
-	return fmt.Sprintf("%s/%s/%s.git", c.baseURL, owner, repo)
+// FastForwardRef pushes sha to branch without force; see
+// GitRemote.FastForwardRef.
+func (c *HTTPClient) FastForwardRef(ctx context.Context, owner, repo, branch, sha string) error {
+	return c.git.FastForwardRef(ctx, owner, repo, branch, sha)
 }
 
-// FastForwardRef pushes sha to refs/heads/branch with a non-force refspec
-// straight from the cached repo. git's client-side fast-forward check needs
-// sha's ancestry back to the current branch tip, so both are fetched first;
-// the push pack itself is empty because the server already has sha.
-func (c *HTTPClient) FastForwardRef(ctx context.Context, owner, repo, branch, sha string) error {
-	refs := []string{"+refs/heads/" + branch + ":refs/heads/" + branch, sha}
-	return c.gitCache.withRepo(ctx, c.cloneURL(owner, repo), owner, repo, refs, func(run gitRunFunc) error {
-		out, err := run("push", "--porcelain", "origin", sha+":refs/heads/"+branch)
-		if err == nil {
-			return nil
-		}
-		return classifyPushFailure(branch, sha, c.redact(out), err)
-	})
-}
-
-// classifyPushFailure maps a failed `git push --porcelain` to a typed error
-// by parsing its rejection line: "! <from>:<to> <summary> (<reason>)".
-// Client-side ancestry rejections carry fixed reasons; "[remote rejected]"
-// carries the server hook's message, i.e. branch protection denied the push.
-// Output without a rejection line stays a generic error.
-func classifyPushFailure(branch, sha, out string, err error) error {
-	for line := range strings.Lines(out) {
-		flag, rest, ok := strings.Cut(line, "\t")
-		if !ok || flag != "!" {
-			continue
-		}
-		_, result, ok := strings.Cut(rest, "\t")
-		if !ok {
-			continue
-		}
-		summary, reason, _ := strings.Cut(strings.TrimSpace(result), " (")
-		reason = strings.TrimSuffix(reason, ")")
-		if summary == "[remote rejected]" {
-			return &ProtectedBranchError{Branch: branch, Message: reason}
-		}
-		switch reason {
-		case "non-fast-forward", "fetch first", "needs force", "stale info":
-			return &NotFastForwardError{Branch: branch, SHA: sha}
-		}
-	}
-	return fmt.Errorf("git push %s: %w", branch, err)
+// PushDryRun checks push access to branch; see GitRemote.PushDryRun.
+func (c *HTTPClient) PushDryRun(ctx context.Context, owner, repo, branch string) error {
+	return c.git.PushDryRun(ctx, owner, repo, branch)
 }
 
 // EditIssueState sets an issue/PR state via PATCH /repos/{o}/{r}/issues/{n}.
@@ -633,6 +551,21 @@ func (c *HTTPClient) EditBranchProtection(ctx context.Context, owner, repo, name
 		fmt.Sprintf("edit branch protection %s in %s/%s", name, owner, repo))
 }
 
+// CreateBranchProtection creates a branch protection rule.
+// POST /repos/{owner}/{repo}/branch_protections
+func (c *HTTPClient) CreateBranchProtection(ctx context.Context, owner, repo string, opts CreateBranchProtectionOpts) error {
+	return c.doDiscard(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/branch_protections", owner, repo), opts,
+		fmt.Sprintf("create branch protection %s in %s/%s", opts.RuleName, owner, repo))
+}
+
+// DeleteBranchProtection deletes a branch protection rule. Rule names may be
+// patterns such as "release/*", so the name is escaped.
+// DELETE /repos/{owner}/{repo}/branch_protections/{name}
+func (c *HTTPClient) DeleteBranchProtection(ctx context.Context, owner, repo, name string) error {
+	return c.doDiscard(ctx, http.MethodDelete, fmt.Sprintf("/repos/%s/%s/branch_protections/%s", owner, repo, url.PathEscape(name)), nil,
+		fmt.Sprintf("delete branch protection %s in %s/%s", name, owner, repo))
+}
+
 // ListWebhooks lists all webhooks for a repository. Handles pagination.
 func (c *HTTPClient) ListWebhooks(ctx context.Context, owner, repo string) ([]Webhook, error) {
 	return paginate[Webhook](ctx, c,
@@ -647,5 +580,12 @@ func (c *HTTPClient) CreateWebhook(ctx context.Context, owner, repo string, opts
 		fmt.Sprintf("create webhook in %s/%s", owner, repo))
 }
 
+// DeleteWebhook deletes a webhook from a repository.
+// DELETE /repos/{owner}/{repo}/hooks/{id}
+func (c *HTTPClient) DeleteWebhook(ctx context.Context, owner, repo string, id int64) error {
+	return c.doDiscard(ctx, http.MethodDelete, fmt.Sprintf("/repos/%s/%s/hooks/%d", owner, repo, id), nil,
+		fmt.Sprintf("delete webhook %d in %s/%s", id, owner, repo))
+}
+
 // Ensure HTTPClient implements Client at compile time.
 var _ Client = (*HTTPClient)(nil)
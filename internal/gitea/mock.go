@@ -21,9 +21,13 @@ type MockClient struct {
 	// Response configurators. Set these before calling the method under test.
 	// Each returns (result, error). If nil, the method returns zero value + nil.
 
+	GetVersionFn              func(ctx context.Context) (string, error)
 	SearchReposByTopicFn      func(ctx context.Context, topic string) ([]Repo, error)
 	ListOpenPRsFn             func(ctx context.Context, owner, repo string) ([]PR, error)
 	GetPRFn                   func(ctx context.Context, owner, repo string, index int64) (*PR, error)
+	GetUserFn                 func(ctx context.Context, username string) (*User, error)
+	GetCurrentUserFn          func(ctx context.Context) (*User, error)
+	GetRepoFn                 func(ctx context.Context, owner, repo string) (*Repo, error)
 	GetPRTimelineFn           func(ctx context.Context, owner, repo string, index int64) ([]TimelineComment, error)
 	GetCombinedCommitStatusFn func(ctx context.Context, owner, repo, ref string) (*CombinedStatus, error)
 	CreateCommitStatusFn      func(ctx context.Context, owner, repo, sha string, status CommitStatus) error
@@ -37,11 +41,15 @@ type MockClient struct {
 	MergeBranchesFn           func(ctx context.Context, owner, repo, base, head, branchName string) (*MergeResult, error)
 	StackMergesFn             func(ctx context.Context, owner, repo, base string, heads []string, branch string) (string, []StackStep, error)
 	FastForwardRefFn          func(ctx context.Context, owner, repo, branch, sha string) error
+	PushDryRunFn              func(ctx context.Context, owner, repo, branch string) error
 	EditIssueStateFn          func(ctx context.Context, owner, repo string, index int64, state string) error
 	ListBranchProtectionsFn   func(ctx context.Context, owner, repo string) ([]BranchProtection, error)
 	EditBranchProtectionFn    func(ctx context.Context, owner, repo, name string, opts EditBranchProtectionOpts) error
+	CreateBranchProtectionFn  func(ctx context.Context, owner, repo string, opts CreateBranchProtectionOpts) error
+	DeleteBranchProtectionFn  func(ctx context.Context, owner, repo, name string) error
 	ListWebhooksFn            func(ctx context.Context, owner, repo string) ([]Webhook, error)
 	CreateWebhookFn           func(ctx context.Context, owner, repo string, opts CreateWebhookOpts) error
+	DeleteWebhookFn           func(ctx context.Context, owner, repo string, id int64) error
 }
 
 // Ensure MockClient implements Client at compile time.
@@ -77,6 +85,16 @@ func (m *MockClient) Reset() {
 	m.Calls = nil
 }
 
+func (m *MockClient) GetVersion(ctx context.Context) (string, error) {
+	m.record("GetVersion")
+
+	if m.GetVersionFn != nil {
+		return m.GetVersionFn(ctx)
+	}
+
+	return "", nil
+}
+
 func (m *MockClient) SearchReposByTopic(ctx context.Context, topic string) ([]Repo, error) {
 	m.record("SearchReposByTopic", topic)
 
@@ -107,6 +125,36 @@ func (m *MockClient) GetPR(ctx context.Context, owner, repo string, index int64)
 	return nil, fmt.Errorf("PR #%d not found", index)
 }
 
+func (m *MockClient) GetUser(ctx context.Context, username string) (*User, error) {
+	m.record("GetUser", username)
+
+	if m.GetUserFn != nil {
+		return m.GetUserFn(ctx, username)
+	}
+
+	return nil, fmt.Errorf("user %s not found", username)
+}
+
+func (m *MockClient) GetCurrentUser(ctx context.Context) (*User, error) {
+	m.record("GetCurrentUser")
+
+	if m.GetCurrentUserFn != nil {
+		return m.GetCurrentUserFn(ctx)
+	}
+
+	return &User{Login: "gitea-mq"}, nil
+}
+
+func (m *MockClient) GetRepo(ctx context.Context, owner, repo string) (*Repo, error) {
+	m.record("GetRepo", owner, repo)
+
+	if m.GetRepoFn != nil {
+		return m.GetRepoFn(ctx, owner, repo)
+	}
+
+	return &Repo{Owner: RepoOwner{Login: owner}, Name: repo, FullName: owner + "/" + repo}, nil
+}
+
 func (m *MockClient) GetPRTimeline(ctx context.Context, owner, repo string, index int64) ([]TimelineComment, error) {
 	m.record("GetPRTimeline", owner, repo, index)
 
@@ -235,6 +283,16 @@ func (m *MockClient) FastForwardRef(ctx context.Context, owner, repo, branch, sh
 	return nil
 }
 
+func (m *MockClient) PushDryRun(ctx context.Context, owner, repo, branch string) error {
+	m.record("PushDryRun", owner, repo, branch)
+
+	if m.PushDryRunFn != nil {
+		return m.PushDryRunFn(ctx, owner, repo, branch)
+	}
+
+	return nil
+}
+
 func (m *MockClient) EditIssueState(ctx context.Context, owner, repo string, index int64, state string) error {
 	m.record("EditIssueState", owner, repo, index, state)
 
@@ -265,6 +323,26 @@ func (m *MockClient) EditBranchProtection(ctx context.Context, owner, repo, name
 	return nil
 }
 
+func (m *MockClient) CreateBranchProtection(ctx context.Context, owner, repo string, opts CreateBranchProtectionOpts) error {
+	m.record("CreateBranchProtection", owner, repo, opts)
+
+	if m.CreateBranchProtectionFn != nil {
+		return m.CreateBranchProtectionFn(ctx, owner, repo, opts)
+	}
+
+	return nil
+}
+
+func (m *MockClient) DeleteBranchProtection(ctx context.Context, owner, repo, name string) error {
+	m.record("DeleteBranchProtection", owner, repo, name)
+
+	if m.DeleteBranchProtectionFn != nil {
+		return m.DeleteBranchProtectionFn(ctx, owner, repo, name)
+	}
+
+	return nil
+}
+
 func (m *MockClient) ListWebhooks(ctx context.Context, owner, repo string) ([]Webhook, error) {
 	m.record("ListWebhooks", owner, repo)
 
@@ -284,3 +362,13 @@ func (m *MockClient) CreateWebhook(ctx context.Context, owner, repo string, opts
 
 	return nil
 }
+
+func (m *MockClient) DeleteWebhook(ctx context.Context, owner, repo string, id int64) error {
+	m.record("DeleteWebhook", owner, repo, id)
+
+	if m.DeleteWebhookFn != nil {
+		return m.DeleteWebhookFn(ctx, owner, repo, id)
+	}
+
+	return nil
+}
@@ -5,18 +5,26 @@ import (
 	"fmt"
 	"log/slog"
 	"slices"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
 )
 
 // EnsureBranchProtection adds `gitea-mq` to every branch-protection rule's
-// required status checks if missing. With no rules present it logs a warning
-// and returns nil — gitea-mq can still run, the user just won't get gating.
-func EnsureBranchProtection(ctx context.Context, client Client, owner, repo string) error {
+// required status checks if missing. With cfg.CreateProtection it first
+// creates rules for the default branch and cfg.BranchPatterns where none
+// exist; otherwise, with no rules present, it logs a warning and returns nil
+// — gitea-mq can still run, the user just won't get gating.
+func EnsureBranchProtection(ctx context.Context, client Client, owner, repo string, cfg forge.SetupConfig) error {
 	bps, err := client.ListBranchProtections(ctx, owner, repo)
 	if err != nil {
 		return fmt.Errorf("list branch protections for %s/%s: %w", owner, repo, err)
 	}
 
-	if len(bps) == 0 {
+	if cfg.CreateProtection {
+		if err := createBranchProtections(ctx, client, owner, repo, bps, cfg); err != nil {
+			return err
+		}
+	} else if len(bps) == 0 {
 		slog.Warn("no branch protection rules found, gitea-mq requires branch protection with status checks",
 			"owner", owner, "repo", repo)
 		return nil
@@ -48,6 +56,60 @@ func EnsureBranchProtection(ctx context.Context, client Client, owner, repo stri
 	return nil
 }
 
+// createBranchProtections creates a rule for the default branch unless an
+// existing rule covers it, and one for each pattern no rule is named after.
+// Whether a differently named rule covers a pattern cannot be told, so such
+// rules are left to the operator.
+func createBranchProtections(ctx context.Context, client Client, owner, repo string, bps []BranchProtection, cfg forge.SetupConfig) error {
+	r, err := client.GetRepo(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("get repo %s/%s: %w", owner, repo, err)
+	}
+
+	var names []string
+	if r.DefaultBranch != "" && !slices.ContainsFunc(bps, func(bp BranchProtection) bool { return ruleMatches(bp, r.DefaultBranch) }) {
+		names = append(names, r.DefaultBranch)
+	}
+	for _, p := range cfg.BranchPatterns {
+		if !slices.Contains(names, p) && !slices.ContainsFunc(bps, func(bp BranchProtection) bool { return ruleName(bp) == p }) {
+			names = append(names, p)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	opts := CreateBranchProtectionOpts{
+		EnableStatusCheck:   true,
+		StatusCheckContexts: append([]string{forge.MQContext}, cfg.RequiredChecks...),
+	}
+	if cfg.PushesTargets {
+		// Batching fast-forwards the target branch with a plain push, which
+		// a rule without a whitelist would reject.
+		me, err := client.GetCurrentUser(ctx)
+		if err != nil {
+			return fmt.Errorf("get token user: %w", err)
+		}
+		opts.EnablePush = true
+		opts.EnablePushWhitelist = true
+		opts.PushWhitelistUsernames = []string{me.Login}
+	}
+
+	for _, name := range names {
+		opts.RuleName = name
+		if err := client.CreateBranchProtection(ctx, owner, repo, opts); err != nil {
+			return err
+		}
+		slog.Info("created branch protection", "owner", owner, "repo", repo, "rule", name)
+		if cfg.RuleCreated != nil {
+			if err := cfg.RuleCreated(ctx, name); err != nil {
+				return fmt.Errorf("record created branch protection %q: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
 // EnsureWebhook creates a `status`-event webhook pointing at webhookURL unless
 // one already exists with that URL.
 func EnsureWebhook(ctx context.Context, client Client, owner, repo, webhookURL, secret string) error {
@@ -82,3 +144,64 @@ func EnsureWebhook(ctx context.Context, client Client, owner, repo, webhookURL,
 	slog.Info("created webhook", "owner", owner, "repo", repo, "url", webhookURL)
 	return nil
 }
+
+// RemoveBranchProtection deletes the rules gitea-mq created, named in
+// created, and drops `gitea-mq` from the required status checks of every
+// other rule. A rule left without checks has status checks disabled, since
+// Gitea would otherwise wait for every status of the head commit.
+func RemoveBranchProtection(ctx context.Context, client Client, owner, repo string, created []string) error {
+	bps, err := client.ListBranchProtections(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("list branch protections for %s/%s: %w", owner, repo, err)
+	}
+
+	for _, bp := range bps {
+		if slices.Contains(created, ruleName(bp)) {
+			if err := client.DeleteBranchProtection(ctx, owner, repo, ruleName(bp)); err != nil {
+				return err
+			}
+			slog.Info("deleted branch protection", "owner", owner, "repo", repo, "rule", ruleName(bp))
+			continue
+		}
+		if !slices.Contains(bp.StatusCheckContexts, forge.MQContext) {
+			continue
+		}
+
+		contexts := slices.DeleteFunc(slices.Clone(bp.StatusCheckContexts), func(c string) bool { return c == forge.MQContext })
+		enable := len(contexts) > 0 && bp.EnableStatusCheck
+		opts := EditBranchProtectionOpts{
+			EnableStatusCheck:   &enable,
+			StatusCheckContexts: contexts,
+		}
+
+		if err := client.EditBranchProtection(ctx, owner, repo, bp.RuleName, opts); err != nil {
+			return fmt.Errorf("remove gitea-mq from branch protection %q in %s/%s: %w",
+				bp.RuleName, owner, repo, err)
+		}
+
+		slog.Info("removed gitea-mq from required status checks",
+			"owner", owner, "repo", repo, "rule", bp.RuleName)
+	}
+
+	return nil
+}
+
+// RemoveWebhook deletes the webhooks pointing at webhookURL.
+func RemoveWebhook(ctx context.Context, client Client, owner, repo, webhookURL string) error {
+	hooks, err := client.ListWebhooks(ctx, owner, repo)
+	if err != nil {
+		return fmt.Errorf("list webhooks for %s/%s: %w", owner, repo, err)
+	}
+
+	for _, h := range hooks {
+		if h.Config["url"] != webhookURL {
+			continue
+		}
+		if err := client.DeleteWebhook(ctx, owner, repo, h.ID); err != nil {
+			return err
+		}
+		slog.Info("deleted webhook", "owner", owner, "repo", repo, "url", webhookURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	gh "github.com/google/go-github/v84/github"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// Identifiers of the buttons on the gitea-mq check run, as sent back in
+// check_run requested_action events.
+const (
+	ActionRetry   = "retry"
+	ActionDequeue = "dequeue"
+)
+
+var _ forge.StatusActions = (*githubForge)(nil)
+
+// mqActions returns the buttons for a gitea-mq status: removal while the PR
+// is queued, a retry once it was removed, nothing after it passed. GitHub
+// caps labels at 20 characters and descriptions at 40.
+func mqActions(state forge.CheckState) []*gh.CheckRunAction {
+	switch state {
+	case pg.CheckStatePending:
+		return []*gh.CheckRunAction{{
+			Label: "Remove from queue", Description: "Take this PR out of the merge queue", Identifier: ActionDequeue,
+		}}
+	case pg.CheckStateFailure, pg.CheckStateError:
+		return []*gh.CheckRunAction{{
+			Label: "Retry in queue", Description: "Re-schedule auto-merge for this PR", Identifier: ActionRetry,
+		}}
+	default:
+		return []*gh.CheckRunAction{}
+	}
+}
+
+// CanWrite reports whether login has write access to the repo. Maintainers
+// report "write" too.
+func (f *githubForge) CanWrite(ctx context.Context, owner, name, login string) (bool, error) {
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return false, err
+	}
+	lvl, _, err := c.Repositories.GetPermissionLevel(ctx, owner, name, login)
+	if err != nil {
+		return false, err
+	}
+	switch lvl.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	}
+	return false, nil
+}
+
+const enableAutoMergeMutation = `mutation($id:ID!,$method:PullRequestMergeMethod){enablePullRequestAutoMerge(input:{pullRequestId:$id,mergeMethod:$method}){clientMutationId}}`
+
+// EnableAutoMerge turns auto-merge back on with the first merge method the
+// repo allows. The author's original choice is gone once it was cancelled.
+func (f *githubForge) EnableAutoMerge(ctx context.Context, owner, name string, number int64) error {
+	if !f.src.capabilities(ctx).autoMerge {
+		return errors.New("the GitHub server has no auto-merge")
+	}
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return err
+	}
+	repo, _, err := c.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	method := "MERGE"
+	switch {
+	case repo.GetAllowMergeCommit():
+	case repo.GetAllowSquashMerge():
+		method = "SQUASH"
+	case repo.GetAllowRebaseMerge():
+		method = "REBASE"
+	}
+	msg, err := f.prMutation(ctx, owner, name, number, enableAutoMergeMutation, map[string]any{"method": method})
+	if err != nil || msg == "" {
+		return err
+	}
+	return fmt.Errorf("enablePullRequestAutoMerge: %s", msg)
+}
@@ -13,19 +13,54 @@ import (
 	gh "github.com/google/go-github/v84/github"
 
 	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/httpcache"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
 )
 
 // DefaultBaseURL is github.com's REST root. Tests inject a ghfake URL.
 const DefaultBaseURL = "https://api.github.com"
 
+// Endpoints locates a GitHub deployment. The zero value is github.com; for
+// GitHub Enterprise Server setting Web is enough, the other URLs follow the
+// GHES layout below it unless given explicitly.
+type Endpoints struct {
+	Web     string // user-facing root, e.g. https://ghe.example.com
+	API     string // REST root, e.g. https://ghe.example.com/api/v3
+	Upload  string // upload root, e.g. https://ghe.example.com/api/uploads
+	GraphQL string // GraphQL endpoint, e.g. https://ghe.example.com/api/graphql
+}
+
+// WithDefaults fills the unset URLs and strips trailing slashes.
+func (e Endpoints) WithDefaults() Endpoints {
+	trim := func(s string) string { return strings.TrimRight(s, "/") }
+	e.Web, e.API, e.Upload, e.GraphQL = trim(e.Web), trim(e.API), trim(e.Upload), trim(e.GraphQL)
+	if e.Web == "" {
+		if e.API == "" || e.API == DefaultBaseURL {
+			e.Web = "https://github.com"
+		} else {
+			e.Web = strings.TrimSuffix(e.API, "/api/v3")
+		}
+	}
+	if e.Web == "https://github.com" {
+		e.API = cmp.Or(e.API, DefaultBaseURL)
+		e.Upload = cmp.Or(e.Upload, "https://uploads.github.com")
+		e.GraphQL = cmp.Or(e.GraphQL, DefaultBaseURL+"/graphql")
+		return e
+	}
+	e.API = cmp.Or(e.API, e.Web+"/api/v3")
+	e.Upload = cmp.Or(e.Upload, e.Web+"/api/uploads")
+	e.GraphQL = cmp.Or(e.GraphQL, e.Web+"/api/graphql")
+	return e
+}
+
 // App holds a GitHub App identity and vends per-installation API clients.
 //
 // GitHub Apps cannot act on a repository without an installation that covers
 // it, so the repo→installation map is the routing table for every API call.
 type App struct {
-	appID   int64
-	baseURL string // REST root, no trailing slash
-	atr     *ghinstallation.AppsTransport
+	*deployment
+	appID int64
+	atr   *ghinstallation.AppsTransport
 
 	// appClient is JWT-authenticated and may only call /app/* endpoints.
 	appClient *gh.Client
@@ -35,28 +70,28 @@ type App struct {
 	repoInstall map[string]int64 // owner/name -> installation ID
 }
 
-// NewApp constructs an App. baseURL must be the REST root (e.g.
-// https://api.github.com or http://127.0.0.1:PORT/api/v3 for ghfake).
-func NewApp(appID int64, privateKey []byte, baseURL string) (*App, error) {
-	baseURL = cmp.Or(baseURL, DefaultBaseURL)
+// NewApp constructs an App talking to the deployment at ep (github.com when
+// zero).
+func NewApp(appID int64, privateKey []byte, ep Endpoints) (*App, error) {
+	ep = ep.WithDefaults()
 	// Wrap the base transport in an ETag cache so unchanged GETs revalidate
 	// with a 304, which GitHub does not charge against the rate limit. Both
 	// the app client and every installation client derive from this transport
 	// (installation clients reuse atr.tr), so all reads are covered.
-	caching := newETagCache(http.DefaultTransport, 4096)
+	caching := httpcache.New("github app", nil, httpcache.DefaultMaxBytes)
 	atr, err := ghinstallation.NewAppsTransport(caching, appID, privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("github app transport: %w", err)
 	}
-	atr.BaseURL = baseURL
+	atr.BaseURL = ep.API
 
-	appClient, err := newClient(&http.Client{Transport: atr}, baseURL)
+	appClient, err := newClient(&http.Client{Transport: ratelimit.NewTransport(atr, ratelimit.NewBudget("github app"))}, ep)
 	if err != nil {
 		return nil, err
 	}
 	return &App{
+		deployment:  &deployment{ep: ep, meta: appClient},
 		appID:       appID,
-		baseURL:     baseURL,
 		atr:         atr,
 		appClient:   appClient,
 		instClients: map[int64]*gh.Client{},
@@ -64,26 +99,17 @@ func NewApp(appID int64, privateKey []byte, baseURL string) (*App, error) {
 	}, nil
 }
 
-// newClient builds a go-github client rooted at baseURL. For github.com the
+// newClient builds a go-github client rooted at ep.API. For github.com the
 // enterprise-URL helper would mangle the path, so it is special-cased.
-func newClient(hc *http.Client, baseURL string) (*gh.Client, error) {
-	if baseURL == DefaultBaseURL {
+func newClient(hc *http.Client, ep Endpoints) (*gh.Client, error) {
+	if ep.API == DefaultBaseURL {
 		return gh.NewClient(hc), nil
 	}
-	return gh.NewClient(hc).WithEnterpriseURLs(baseURL, baseURL)
+	return gh.NewClient(hc).WithEnterpriseURLs(ep.API, ep.Upload)
 }
 
 func (a *App) AppID() int64 { return a.appID }
 
-// graphqlURL derives the GraphQL endpoint from the REST base. github.com and
-// GHES place it differently relative to the REST root.
-func (a *App) graphqlURL() string {
-	if a.baseURL == DefaultBaseURL {
-		return "https://api.github.com/graphql"
-	}
-	return strings.TrimSuffix(a.baseURL, "/api/v3") + "/api/graphql"
-}
-
 func (a *App) installationClient(id int64) (*gh.Client, error) {
 	a.mu.Lock()
 	if c, ok := a.instClients[id]; ok {
@@ -93,8 +119,10 @@ func (a *App) installationClient(id int64) (*gh.Client, error) {
 	a.mu.Unlock()
 
 	itr := ghinstallation.NewFromAppsTransport(a.atr, id)
-	itr.BaseURL = a.baseURL
-	c, err := newClient(&http.Client{Transport: itr}, a.baseURL)
+	itr.BaseURL = a.ep.API
+	// Every installation has its own rate limit.
+	budget := ratelimit.NewBudget(fmt.Sprintf("github installation %d", id))
+	c, err := newClient(&http.Client{Transport: ratelimit.NewTransport(itr, budget)}, a.ep)
 	if err != nil {
 		return nil, err
 	}
@@ -14,7 +14,7 @@ import (
 
 func newTestApp(t *testing.T, srv *ghfake.Server) *githubpkg.App {
 	t.Helper()
-	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), srv.URL+"/api/v3")
+	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), githubpkg.Endpoints{Web: srv.WebURL()})
 	if err != nil {
 		t.Fatalf("NewApp: %v", err)
 	}
@@ -80,3 +80,135 @@ func TestApp_SyncHookConfig(t *testing.T) {
 		t.Errorf("hook config = %+v, want %+v", got, want)
 	}
 }
+
+func TestEndpoints_WithDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		in   githubpkg.Endpoints
+		want githubpkg.Endpoints
+	}{
+		{
+			name: "github.com",
+			want: githubpkg.Endpoints{
+				Web:     "https://github.com",
+				API:     "https://api.github.com",
+				Upload:  "https://uploads.github.com",
+				GraphQL: "https://api.github.com/graphql",
+			},
+		},
+		{
+			name: "enterprise web root",
+			in:   githubpkg.Endpoints{Web: "https://ghe.example.com/"},
+			want: githubpkg.Endpoints{
+				Web:     "https://ghe.example.com",
+				API:     "https://ghe.example.com/api/v3",
+				Upload:  "https://ghe.example.com/api/uploads",
+				GraphQL: "https://ghe.example.com/api/graphql",
+			},
+		},
+		{
+			name: "enterprise REST root only",
+			in:   githubpkg.Endpoints{API: "https://ghe.example.com/api/v3"},
+			want: githubpkg.Endpoints{
+				Web:     "https://ghe.example.com",
+				API:     "https://ghe.example.com/api/v3",
+				Upload:  "https://ghe.example.com/api/uploads",
+				GraphQL: "https://ghe.example.com/api/graphql",
+			},
+		},
+		{
+			name: "explicit overrides win",
+			in: githubpkg.Endpoints{
+				Web:     "https://ghe.example.com",
+				API:     "https://api.ghe.example.com",
+				GraphQL: "https://api.ghe.example.com/graphql",
+			},
+			want: githubpkg.Endpoints{
+				Web:     "https://ghe.example.com",
+				API:     "https://api.ghe.example.com",
+				Upload:  "https://ghe.example.com/api/uploads",
+				GraphQL: "https://api.ghe.example.com/graphql",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.WithDefaults(); got != tt.want {
+				t.Errorf("WithDefaults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// An enterprise server behind a path prefix must be reachable for REST and
+// GraphQL alike when only its web root is configured.
+func TestApp_EnterpriseBasePath(t *testing.T) {
+	srv := ghfake.NewAt("/ghe")
+	defer srv.Close()
+	srv.SetInstalledVersion("3.14.2")
+	srv.AddRepo("org", "app")
+	srv.AddInstallation(100, "org/app")
+	srv.AddPR("org", "app", ghfake.PR{Number: 1, HeadSHA: "sha1", BaseRef: "main", AutoMerge: true})
+
+	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), githubpkg.Endpoints{Web: srv.WebURL()})
+	if err != nil {
+		t.Fatalf("NewApp: %v", err)
+	}
+	ctx := context.Background()
+	if err := app.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	f := githubpkg.NewForge(app)
+
+	if got, want := f.RepoHTMLURL("org", "app"), srv.WebURL()+"/org/app"; got != want {
+		t.Errorf("RepoHTMLURL = %q, want %q", got, want)
+	}
+	if err := f.EnsureRepoSetup(ctx, "org", "app", forge.SetupConfig{}); err != nil {
+		t.Fatalf("EnsureRepoSetup: %v", err)
+	}
+	if n := len(srv.Repo("org", "app").Rulesets); n != 1 {
+		t.Errorf("rulesets = %d, want 1 on a release with rulesets", n)
+	}
+	if err := f.CancelAutoMerge(ctx, "org", "app", 1); err != nil {
+		t.Fatalf("CancelAutoMerge: %v", err)
+	}
+	if srv.Repo("org", "app").PRs[1].AutoMerge {
+		t.Error("auto-merge still enabled: GraphQL call missed the prefixed endpoint")
+	}
+}
+
+// Releases predating rulesets and auto-merge must degrade instead of failing
+// every repo setup and cancellation on 404s.
+func TestApp_EnterpriseLegacyRelease(t *testing.T) {
+	srv := ghfake.New()
+	defer srv.Close()
+	srv.SetInstalledVersion("3.0.5")
+	srv.AddRepo("org", "app")
+	srv.AddInstallation(100, "org/app")
+	srv.Repo("org", "app").RequiredChecks["main"] = []string{"ci/build", forge.MQContext}
+
+	f := githubpkg.NewForge(newTestApp(t, srv))
+	ctx := context.Background()
+
+	if err := f.EnsureRepoSetup(ctx, "org", "app", forge.SetupConfig{}); err != nil {
+		t.Fatalf("EnsureRepoSetup: %v", err)
+	}
+	if n := len(srv.Repo("org", "app").Rulesets); n != 0 {
+		t.Errorf("rulesets = %d, want none", n)
+	}
+
+	checks, err := f.GetRequiredChecks(ctx, "org", "app", "main")
+	if err != nil {
+		t.Fatalf("GetRequiredChecks: %v", err)
+	}
+	if !slices.Equal(checks, []string{"ci/build"}) {
+		t.Errorf("required checks = %v, want [ci/build] from branch protection", checks)
+	}
+	if checks, err := f.GetRequiredChecks(ctx, "org", "app", "unprotected"); err != nil || len(checks) != 0 {
+		t.Errorf("unprotected branch: checks=%v err=%v", checks, err)
+	}
+
+	if err := f.CancelAutoMerge(ctx, "org", "app", 1); err != nil {
+		t.Errorf("CancelAutoMerge: %v", err)
+	}
+}
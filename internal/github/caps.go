@@ -0,0 +1,121 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	gh "github.com/google/go-github/v84/github"
+)
+
+// deployment is what App and TokenClient share about the server they talk
+// to: where it lives and which optional APIs it offers.
+type deployment struct {
+	ep   Endpoints
+	meta *gh.Client // any authenticated client; only used for GET /meta
+
+	capsMu sync.Mutex
+	caps   *serverCaps // nil until detected
+}
+
+// Endpoints returns the resolved URLs of the deployment.
+func (d *deployment) Endpoints() Endpoints { return d.ep }
+
+// serverCaps records which optional APIs the deployment offers. github.com
+// has all of them; GitHub Enterprise Server gained them release by release,
+// and older releases answer the missing endpoints with a bare 404 that is
+// indistinguishable from a permission problem.
+type serverCaps struct {
+	// version is GHES's installed_version, empty on github.com.
+	version string
+	// rulesets: repository rulesets and /rules/branches (GHES 3.11+).
+	// Without them required checks come from classic branch protection.
+	rulesets bool
+	// autoMerge: the auto-merge flag on PRs and its GraphQL mutations
+	// (GHES 3.1+).
+	autoMerge bool
+}
+
+var allCaps = serverCaps{rulesets: true, autoMerge: true}
+
+// capsForVersion maps a GHES installed_version to its capabilities. An empty
+// or unparsable version is treated as current.
+func capsForVersion(version string) serverCaps {
+	major, minor, ok := parseVersion(version)
+	if !ok {
+		c := allCaps
+		c.version = version
+		return c
+	}
+	atLeast := func(ma, mi int) bool { return major > ma || (major == ma && minor >= mi) }
+	return serverCaps{
+		version:   version,
+		rulesets:  atLeast(3, 11),
+		autoMerge: atLeast(3, 1),
+	}
+}
+
+func parseVersion(v string) (major, minor int, ok bool) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	return major, minor, err1 == nil && err2 == nil
+}
+
+// capabilities detects the deployment's capabilities once via GET /meta,
+// which on GHES carries installed_version. A failed probe is not cached and
+// assumes everything is available, so a transient error never downgrades the
+// App permanently.
+func (d *deployment) capabilities(ctx context.Context) serverCaps {
+	d.capsMu.Lock()
+	if d.caps != nil {
+		c := *d.caps
+		d.capsMu.Unlock()
+		return c
+	}
+	d.capsMu.Unlock()
+
+	if d.ep.API == DefaultBaseURL {
+		d.setCaps(allCaps)
+		return allCaps
+	}
+
+	version, err := d.installedVersion(ctx)
+	if err != nil {
+		slog.Warn("github: capability detection failed, assuming current GHES", "err", err)
+		return allCaps
+	}
+	c := capsForVersion(version)
+	if !c.rulesets || !c.autoMerge {
+		slog.Info("github: enterprise server lacks features",
+			"version", version, "rulesets", c.rulesets, "auto_merge", c.autoMerge)
+	}
+	d.setCaps(c)
+	return c
+}
+
+func (d *deployment) setCaps(c serverCaps) {
+	d.capsMu.Lock()
+	d.caps = &c
+	d.capsMu.Unlock()
+}
+
+func (d *deployment) installedVersion(ctx context.Context) (string, error) {
+	req, err := d.meta.NewRequest("GET", "meta", nil)
+	if err != nil {
+		return "", err
+	}
+	var meta struct {
+		InstalledVersion string `json:"installed_version"`
+	}
+	if _, err := d.meta.Do(ctx, req, &meta); err != nil {
+		return "", fmt.Errorf("get meta: %w", err)
+	}
+	return meta.InstalledVersion, nil
+}
@@ -2,6 +2,8 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"sync"
 
 	gh "github.com/google/go-github/v84/github"
@@ -71,8 +73,18 @@ func CheckRunToState(status, conclusion string) forge.CheckState {
 	}
 }
 
-func (f *githubForge) upsertCheckRun(ctx context.Context, owner, name, sha, checkName, status, conclusion, summary, detailsURL string) error {
-	c, err := f.app.ClientForRepo(owner, name)
+// checkRunUpdate is gh.UpdateCheckRunOptions with the actions always sent,
+// so an empty list clears the buttons of an earlier status.
+type checkRunUpdate struct {
+	gh.UpdateCheckRunOptions
+	Actions []*gh.CheckRunAction `json:"actions"`
+}
+
+// upsertCheckRun creates or updates the named check run on sha. text, when
+// set, is the markdown body shown below the summary. Non-nil actions replace
+// the run's buttons; nil leaves them as they are.
+func (f *githubForge) upsertCheckRun(ctx context.Context, owner, name, sha, checkName, status, conclusion, summary, text, detailsURL string, actions []*gh.CheckRunAction) error {
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return err
 	}
@@ -94,6 +106,9 @@ func (f *githubForge) upsertCheckRun(ctx context.Context, owner, name, sha, chec
 	}
 
 	output := &gh.CheckRunOutput{Title: gh.Ptr(checkName), Summary: gh.Ptr(summary)}
+	if text != "" {
+		output.Text = gh.Ptr(text)
+	}
 	var conclP *string
 	if conclusion != "" {
 		conclP = gh.Ptr(conclusion)
@@ -104,10 +119,22 @@ func (f *githubForge) upsertCheckRun(ctx context.Context, owner, name, sha, chec
 	}
 
 	if cached {
-		_, _, err = c.Checks.UpdateCheckRun(ctx, owner, name, id, gh.UpdateCheckRunOptions{
+		opts := gh.UpdateCheckRunOptions{
 			Name: checkName, Status: gh.Ptr(status), Conclusion: conclP,
 			DetailsURL: urlP, Output: output,
-		})
+		}
+		if actions == nil {
+			_, _, err = c.Checks.UpdateCheckRun(ctx, owner, name, id, opts)
+		} else {
+			// UpdateCheckRun omits an empty action list, which would keep
+			// stale buttons.
+			var req *http.Request
+			req, err = c.NewRequest("PATCH", fmt.Sprintf("repos/%v/%v/check-runs/%v", owner, name, id),
+				checkRunUpdate{UpdateCheckRunOptions: opts, Actions: actions})
+			if err == nil {
+				_, err = c.Do(ctx, req, nil)
+			}
+		}
 		if err == nil {
 			f.checkRuns.set(repoKey, sha, checkName, id)
 		}
@@ -116,7 +143,7 @@ func (f *githubForge) upsertCheckRun(ctx context.Context, owner, name, sha, chec
 
 	cr, _, err := c.Checks.CreateCheckRun(ctx, owner, name, gh.CreateCheckRunOptions{
 		Name: checkName, HeadSHA: sha, Status: gh.Ptr(status), Conclusion: conclP,
-		DetailsURL: urlP, Output: output,
+		DetailsURL: urlP, Output: output, Actions: actions,
 	})
 	if err != nil {
 		return err
@@ -124,3 +151,43 @@ func (f *githubForge) upsertCheckRun(ctx context.Context, owner, name, sha, chec
 	f.checkRuns.set(repoKey, sha, checkName, cr.GetID())
 	return nil
 }
+
+// statusState renders a CheckState in the commit-status vocabulary, which
+// lacks "skipped": the stale-mirror sentinel becomes success so the cleared
+// mirror stops blocking, as a skipped check run would.
+func statusState(state string) string {
+	switch state {
+	case "success", "failure", "error", "pending":
+		return state
+	case "skipped":
+		return "success"
+	default:
+		return "pending"
+	}
+}
+
+// maxStatusDescription is GitHub's limit; longer descriptions get a 422.
+const maxStatusDescription = 140
+
+// createStatus posts a commit status, the token-mode stand-in for a check
+// run. Statuses are append-only and the newest per context wins, so no
+// lookup is needed.
+func (f *githubForge) createStatus(ctx context.Context, owner, name, sha, statusContext, state, description, targetURL string) error {
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return err
+	}
+	if r := []rune(description); len(r) > maxStatusDescription {
+		description = string(r[:maxStatusDescription-1]) + "…"
+	}
+	st := gh.RepoStatus{
+		Context:     gh.Ptr(statusContext),
+		State:       gh.Ptr(statusState(state)),
+		Description: gh.Ptr(description),
+	}
+	if targetURL != "" {
+		st.TargetURL = gh.Ptr(targetURL)
+	}
+	_, _, err = c.Repositories.CreateStatus(ctx, owner, name, sha, st)
+	return err
+}
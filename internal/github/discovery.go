@@ -2,6 +2,10 @@ package github
 
 import (
 	"context"
+	"log/slog"
+	"slices"
+
+	gh "github.com/google/go-github/v84/github"
 
 	"github.com/Mic92/gitea-mq/internal/forge"
 )
@@ -17,3 +21,26 @@ func InstallationSource(app *App) func(context.Context) ([]forge.RepoRef, error)
 		return app.Repos(), nil
 	}
 }
+
+// TopicSource lists repos carrying topic that the token has admin access to.
+// Admin is required because EnsureRepoSetup edits rulesets and webhooks.
+func TopicSource(tc *TokenClient, topic string) func(context.Context) ([]forge.RepoRef, error) {
+	return func(ctx context.Context) ([]forge.RepoRef, error) {
+		var out []forge.RepoRef
+		opts := &gh.RepositoryListByAuthenticatedUserOptions{ListOptions: gh.ListOptions{PerPage: 100}}
+		for r, err := range tc.client.Repositories.ListByAuthenticatedUserIter(ctx, opts) {
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(r.Topics, topic) {
+				continue
+			}
+			if !r.GetPermissions().GetAdmin() {
+				slog.Debug("discovery: skipping repo without admin access", "repo", r.GetFullName())
+				continue
+			}
+			out = append(out, forge.RepoRef{Forge: forge.KindGithub, Owner: r.GetOwner().GetLogin(), Name: r.GetName()})
+		}
+		return out, nil
+	}
+}
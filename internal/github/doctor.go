@@ -0,0 +1,260 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	gh "github.com/google/go-github/v84/github"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+var _ forge.Doctor = (*githubForge)(nil)
+
+// Diagnose checks the repo against what gitea-mq needs, on its default
+// branch.
+func (f *githubForge) Diagnose(ctx context.Context, owner, name string, cfg forge.SetupConfig) []forge.DoctorCheck {
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return []forge.DoctorCheck{forge.DoctorFailed(forge.DoctorPermissions, err.Error(),
+			"install the GitHub App on the repo")}
+	}
+	repo, _, err := c.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return []forge.DoctorCheck{forge.DoctorFailed(forge.DoctorPermissions,
+			"cannot read the repo: "+err.Error(),
+			"give gitea-mq access to the repo")}
+	}
+	caps := f.src.capabilities(ctx)
+	branch := repo.GetDefaultBranch()
+
+	// The gitea-mq rules on the branch feed both the required-check and the
+	// push diagnosis.
+	var gates []int64
+	var rulesErr error
+	if caps.rulesets {
+		var rules *gh.BranchRules
+		rules, _, rulesErr = c.Repositories.GetRulesForBranch(ctx, owner, name, branch, nil)
+		if rulesErr == nil {
+			for _, r := range rules.RequiredStatusChecks {
+				if slices.ContainsFunc(r.Parameters.RequiredStatusChecks, func(sc *gh.RuleStatusCheck) bool {
+					return sc.Context == forge.MQContext
+				}) {
+					gates = append(gates, r.RulesetID)
+				}
+			}
+		}
+	}
+
+	checks := []forge.DoctorCheck{
+		f.permissionsCheck(ctx, owner, name, repo),
+		requiredCheck(caps, branch, gates, rulesErr),
+	}
+	if caps.rulesets {
+		checks = append(checks, f.rulesetCheck(ctx, c, owner, name, branch, cfg))
+	}
+	return append(checks,
+		f.webhookCheck(ctx, c, owner, name, cfg),
+		f.pushCheck(ctx, c, owner, name, repo, gates),
+		autoMergeCheck(caps, repo),
+	)
+}
+
+// doctorRuleset names the check of the gitea-mq ruleset's contents.
+const doctorRuleset = "ruleset"
+
+// rulesetCheck reports how the gitea-mq ruleset has drifted from what
+// EnsureRepoSetup would make of it.
+func (f *githubForge) rulesetCheck(ctx context.Context, c *gh.Client, owner, name, branch string, cfg forge.SetupConfig) forge.DoctorCheck {
+	rs, _, err := mqRuleset(ctx, c, owner, name)
+	switch {
+	case err != nil:
+		return forge.DoctorWarning(doctorRuleset, "cannot read the rulesets: "+err.Error(),
+			fmt.Sprintf("check by hand that the %s ruleset covers every target branch", forge.MQContext))
+	case rs == nil:
+		return forge.DoctorFailed(doctorRuleset, fmt.Sprintf("the repo has no %s ruleset", forge.MQContext),
+			"gitea-mq creates it on startup when it has Administration permission")
+	}
+	drift := f.repairRuleset(rs, rulesetWant{defaultBranch: branch, patterns: cfg.BranchPatterns, branches: cfg.TargetBranches})
+	if len(drift) > 0 {
+		return forge.DoctorWarning(doctorRuleset,
+			fmt.Sprintf("the %s ruleset has drifted: it %s", forge.MQContext, strings.Join(drift, ", ")),
+			"gitea-mq repairs it on startup and when it queues a PR, given Administration permission")
+	}
+	return forge.DoctorPassed(doctorRuleset,
+		fmt.Sprintf("the %s ruleset covers %s", forge.MQContext, strings.Join(rs.Conditions.RefName.Include, ", ")))
+}
+
+func (f *githubForge) permissionsCheck(ctx context.Context, owner, name string, repo *gh.Repository) forge.DoctorCheck {
+	app, ok := f.src.(*App)
+	if !ok {
+		perms := repo.GetPermissions()
+		switch {
+		case !perms.GetPush():
+			return forge.DoctorFailed(forge.DoctorPermissions,
+				"the token has no write access to the repo",
+				"give the token Contents, Pull requests and Commit statuses write access")
+		case !perms.GetAdmin():
+			return forge.DoctorWarning(forge.DoctorPermissions,
+				"the token user is not a repo admin, so gitea-mq cannot manage the ruleset, webhook or auto-merge setting",
+				"make the token user a repo admin, or set these up by hand")
+		}
+		return forge.DoctorPassed(forge.DoctorPermissions, "the token user is a repo admin")
+	}
+
+	inst, _, err := app.appClient.Apps.FindRepositoryInstallation(ctx, owner, name)
+	if err != nil {
+		return forge.DoctorFailed(forge.DoctorPermissions,
+			"cannot read the App installation: "+err.Error(),
+			"install the GitHub App on the repo")
+	}
+	p := inst.GetPermissions()
+	var missing []string
+	for _, perm := range []struct {
+		name, have, want string
+	}{
+		{"Checks", p.GetChecks(), "write"},
+		{"Contents", p.GetContents(), "write"},
+		{"Pull requests", p.GetPullRequests(), "write"},
+		{"Commit statuses", p.GetStatuses(), "read"},
+	} {
+		if perm.have != "write" && perm.have != perm.want {
+			missing = append(missing, perm.name+" "+perm.want)
+		}
+	}
+	if len(missing) > 0 {
+		return forge.DoctorFailed(forge.DoctorPermissions,
+			"the App installation lacks "+strings.Join(missing, ", "),
+			"grant the missing permissions in the App settings and accept them on the installation")
+	}
+	if p.GetAdministration() != "write" {
+		return forge.DoctorWarning(forge.DoctorPermissions,
+			"the App has no Administration write permission, so gitea-mq cannot manage the ruleset or auto-merge setting",
+			"grant Administration read & write, or set these up by hand")
+	}
+	return forge.DoctorPassed(forge.DoctorPermissions, "the App installation has every permission gitea-mq uses")
+}
+
+func requiredCheck(caps serverCaps, branch string, gates []int64, rulesErr error) forge.DoctorCheck {
+	switch {
+	case !caps.rulesets:
+		return forge.DoctorWarning(forge.DoctorRequiredCheck,
+			fmt.Sprintf("GitHub Enterprise Server %s has no rulesets, so nothing stops PRs merging past the queue", caps.version),
+			"upgrade to 3.11 or newer, or guard "+branch+" against direct merges yourself")
+	case rulesErr != nil:
+		return forge.DoctorWarning(forge.DoctorRequiredCheck,
+			"cannot read the rules of "+branch+": "+rulesErr.Error(),
+			fmt.Sprintf("check by hand that a ruleset requires %s on %s", forge.MQContext, branch))
+	case len(gates) == 0:
+		return forge.DoctorFailed(forge.DoctorRequiredCheck,
+			fmt.Sprintf("no ruleset requires %s on %s, so PRs merge without waiting for the queue", forge.MQContext, branch),
+			fmt.Sprintf("add a ruleset requiring the %s check on %s; gitea-mq creates one when it has Administration permission", forge.MQContext, branch))
+	}
+	return forge.DoctorPassed(forge.DoctorRequiredCheck, fmt.Sprintf("a ruleset requires %s on %s", forge.MQContext, branch))
+}
+
+func (f *githubForge) webhookCheck(ctx context.Context, c *gh.Client, owner, name string, cfg forge.SetupConfig) forge.DoctorCheck {
+	if cfg.ExternalURL == "" {
+		return forge.DoctorWarning(forge.DoctorWebhook,
+			"GITEA_MQ_EXTERNAL_URL is not set, so no events are delivered",
+			"set GITEA_MQ_EXTERNAL_URL to the URL GitHub reaches gitea-mq at")
+	}
+	url := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/github"
+
+	if app, ok := f.src.(*App); ok {
+		hc, _, err := app.appClient.Apps.GetHookConfig(ctx)
+		if err != nil {
+			return forge.DoctorWarning(forge.DoctorWebhook, "cannot read the App's webhook: "+err.Error(),
+				"check by hand that the App's webhook URL is "+url)
+		}
+		if hc.GetURL() != url {
+			return forge.DoctorFailed(forge.DoctorWebhook,
+				fmt.Sprintf("the App's webhook points at %s, not %s", hc.GetURL(), url),
+				"set GITEA_MQ_GITHUB_WEBHOOK_SECRET so gitea-mq syncs the URL on startup, or fix it in the App settings")
+		}
+		return forge.DoctorPassed(forge.DoctorWebhook, "the App's webhook points at "+url)
+	}
+
+	var stale []string
+	for h, err := range c.Repositories.ListHooksIter(ctx, owner, name, &gh.ListOptions{PerPage: 100}) {
+		if err != nil {
+			return forge.DoctorWarning(forge.DoctorWebhook, "cannot list webhooks: "+err.Error(),
+				"check by hand that a webhook points at "+url)
+		}
+		hookURL := h.GetConfig().GetURL()
+		if hookURL != url {
+			if strings.Contains(hookURL, "/webhook/") {
+				stale = append(stale, hookURL)
+			}
+			continue
+		}
+		if !h.GetActive() {
+			return forge.DoctorFailed(forge.DoctorWebhook, "the webhook to "+url+" is inactive", "activate the webhook")
+		}
+		for _, ev := range webhookEvents {
+			if !slices.Contains(h.Events, ev) && !slices.Contains(h.Events, "*") {
+				return forge.DoctorFailed(forge.DoctorWebhook,
+					fmt.Sprintf("the webhook to %s does not send %s events", url, ev),
+					"enable the "+strings.Join(webhookEvents, ", ")+" events on the webhook")
+			}
+		}
+		return forge.DoctorPassed(forge.DoctorWebhook, "webhook points at "+url)
+	}
+	detail := "no webhook points at " + url
+	if len(stale) > 0 {
+		detail += "; found " + strings.Join(stale, ", ") + ", which does not match GITEA_MQ_EXTERNAL_URL"
+	}
+	return forge.DoctorFailed(forge.DoctorWebhook, detail,
+		"create a webhook to "+url+", or make the token user a repo admin so gitea-mq creates it")
+}
+
+// pushCheck asks each ruleset that requires gitea-mq whether the acting
+// App or user may bypass it, which is what lets merge branches be managed
+// and the target branch move once the queue reports success.
+func (f *githubForge) pushCheck(ctx context.Context, c *gh.Client, owner, name string, repo *gh.Repository, gates []int64) forge.DoctorCheck {
+	branch := repo.GetDefaultBranch()
+	if _, ok := f.src.(*App); !ok && !repo.GetPermissions().GetPush() {
+		return forge.DoctorFailed(forge.DoctorPush, "the token may not push to "+branch,
+			"give the token Contents write access")
+	}
+	actor := "the token user"
+	if f.appID != 0 {
+		actor = "the App"
+	}
+	for _, id := range gates {
+		rs, _, err := c.Repositories.GetRuleset(ctx, owner, name, id, true)
+		if err != nil {
+			return forge.DoctorWarning(forge.DoctorPush,
+				fmt.Sprintf("cannot read ruleset %d: %v", id, err),
+				fmt.Sprintf("check by hand that %s may bypass the rulesets requiring %s", actor, forge.MQContext))
+		}
+		switch mode := rs.GetCurrentUserCanBypass(); {
+		case mode != nil && *mode == gh.BypassModeAlways:
+			continue
+		case mode == nil:
+			return forge.DoctorWarning(forge.DoctorPush,
+				fmt.Sprintf("cannot tell whether %s may bypass ruleset %q", actor, rs.Name),
+				fmt.Sprintf("make sure %s is a bypass actor of ruleset %q", actor, rs.Name))
+		default:
+			return forge.DoctorFailed(forge.DoctorPush,
+				fmt.Sprintf("%s may not bypass ruleset %q, so merge branches cannot be pushed past the %s check", actor, rs.Name, forge.MQContext),
+				fmt.Sprintf("add %s to the bypass list of ruleset %q", actor, rs.Name))
+		}
+	}
+	return forge.DoctorPassed(forge.DoctorPush, fmt.Sprintf("%s may push to %s", actor, branch))
+}
+
+func autoMergeCheck(caps serverCaps, repo *gh.Repository) forge.DoctorCheck {
+	switch {
+	case !caps.autoMerge:
+		return forge.DoctorFailed(forge.DoctorAutoMerge,
+			fmt.Sprintf("GitHub Enterprise Server %s has no auto-merge, so PRs cannot be queued", caps.version),
+			"upgrade to 3.1 or newer")
+	case !repo.GetAllowAutoMerge():
+		return forge.DoctorFailed(forge.DoctorAutoMerge, "auto-merge is disabled for the repo",
+			"enable \"Allow auto-merge\" in the repo settings; gitea-mq does this itself with Administration permission")
+	}
+	return forge.DoctorPassed(forge.DoctorAutoMerge, "auto-merge is allowed")
+}
@@ -2,12 +2,13 @@ package github
 
 import (
 	"bytes"
-	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 
 	gh "github.com/google/go-github/v84/github"
@@ -15,24 +16,46 @@ import (
 	"github.com/Mic92/gitea-mq/internal/forge"
 )
 
-var _ forge.Forge = (*githubForge)(nil)
+var (
+	_ forge.Forge          = (*githubForge)(nil)
+	_ forge.EmailResolver  = (*githubForge)(nil)
+	_ forge.RepoVisibility = (*githubForge)(nil)
+)
+
+// clientSource vends the API client acting on a repo: an App installation
+// client, or the one client of a token.
+type clientSource interface {
+	ClientForRepo(owner, name string) (*gh.Client, error)
+	Endpoints() Endpoints
+	capabilities(ctx context.Context) serverCaps
+}
 
 type githubForge struct {
-	app       *App
+	src clientSource
+	// appID is zero in token mode. Only Apps may create check runs or be
+	// ruleset bypass actors, so without one results are commit statuses
+	// and the ruleset bypass falls to the repo admin role.
+	appID     int64
 	htmlURL   string // https://github.com or GHES web root
 	checkRuns checkRunCache
 }
 
-// NewForge wraps a GitHub App as a forge. htmlURL is the user-facing web root
-// (distinct from the API base URL); empty defaults to github.com.
-func NewForge(app *App, htmlURL string) forge.Forge {
-	return &githubForge{app: app, htmlURL: cmp.Or(htmlURL, "https://github.com")}
+// NewForge wraps a GitHub App as a forge. Web links point at the App's
+// deployment, so they stay on the enterprise server for GHES.
+func NewForge(app *App) forge.Forge {
+	return &githubForge{src: app, appID: app.AppID(), htmlURL: app.Endpoints().Web}
+}
+
+// NewTokenForge wraps a user token as a forge for orgs that cannot register
+// a GitHub App. Results are posted as commit statuses.
+func NewTokenForge(tc *TokenClient) forge.Forge {
+	return &githubForge{src: tc, htmlURL: tc.Endpoints().Web}
 }
 
 func (f *githubForge) Kind() forge.Kind { return forge.KindGithub }
 
 func (f *githubForge) Capabilities() forge.Capabilities {
-	return forge.Capabilities{StatusWebhook: true}
+	return forge.Capabilities{StatusWebhook: true, StatusReport: f.appID != 0, BulkReconcile: true}
 }
 
 func (f *githubForge) RepoHTMLURL(owner, name string) string {
@@ -59,7 +82,7 @@ func toForgePR(p *gh.PullRequest) forge.PR {
 }
 
 func (f *githubForge) ListOpenPRs(ctx context.Context, owner, name string) ([]forge.PR, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return nil, err
 	}
@@ -75,7 +98,7 @@ func (f *githubForge) ListOpenPRs(ctx context.Context, owner, name string) ([]fo
 }
 
 func (f *githubForge) GetPR(ctx context.Context, owner, name string, number int64) (*forge.PR, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return nil, err
 	}
@@ -87,21 +110,58 @@ func (f *githubForge) GetPR(ctx context.Context, owner, name string, number int6
 	return &fp, nil
 }
 
+// UserEmail returns the user's public profile address; GitHub exposes no
+// other address to an App installation token.
+func (f *githubForge) UserEmail(ctx context.Context, owner, name, login string) (string, error) {
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return "", err
+	}
+	u, _, err := c.Users.Get(ctx, login)
+	if err != nil {
+		return "", err
+	}
+	return u.GetEmail(), nil
+}
+
+// RepoPrivate reports GitHub's private flag; internal repos are flagged
+// private too, so they stay hidden from anonymous dashboard visitors.
+func (f *githubForge) RepoPrivate(ctx context.Context, owner, name string) (bool, error) {
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return false, err
+	}
+	r, _, err := c.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return false, err
+	}
+	return r.GetPrivate(), nil
+}
+
 func (f *githubForge) SetMQStatus(ctx context.Context, owner, name, sha string, st forge.MQStatus) error {
+	if f.appID == 0 {
+		return f.createStatus(ctx, owner, name, sha, forge.MQContext, string(st.State), st.Description, st.TargetURL)
+	}
 	status, concl := checkRunFields(string(st.State))
-	return f.upsertCheckRun(ctx, owner, name, sha, forge.MQContext, status, concl, st.Description, st.TargetURL)
+	return f.upsertCheckRun(ctx, owner, name, sha, forge.MQContext, status, concl, st.Description, st.Report, st.TargetURL, mqActions(st.State))
 }
 
 func (f *githubForge) MirrorCheck(ctx context.Context, owner, name, sha, checkContext string, c forge.Check) error {
+	if f.appID == 0 {
+		return f.createStatus(ctx, owner, name, sha, checkContext, string(c.State), c.Description, c.TargetURL)
+	}
 	status, concl := checkRunFields(string(c.State))
-	return f.upsertCheckRun(ctx, owner, name, sha, checkContext, status, concl, c.Description, c.TargetURL)
+	return f.upsertCheckRun(ctx, owner, name, sha, checkContext, status, concl, c.Description, "", c.TargetURL, nil)
 }
 
 func (f *githubForge) GetRequiredChecks(ctx context.Context, owner, name, branch string) ([]string, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return nil, err
 	}
+	if !f.src.capabilities(ctx).rulesets {
+		return classicRequiredChecks(ctx, c, owner, name, branch)
+	}
 	rules, _, err := c.Repositories.GetRulesForBranch(ctx, owner, name, branch, nil)
 	if err != nil {
 		return nil, err
@@ -118,8 +178,38 @@ func (f *githubForge) GetRequiredChecks(ctx context.Context, owner, name, branch
 	return out, nil
 }
 
+// classicRequiredChecks reads the required contexts from branch protection,
+// the only source on GHES releases without rulesets. An unprotected branch
+// answers 404, meaning nothing is required.
+func classicRequiredChecks(ctx context.Context, c *gh.Client, owner, name, branch string) ([]string, error) {
+	rsc, resp, err := c.Repositories.GetRequiredStatusChecks(ctx, owner, name, branch)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []string
+	add := func(sc string) {
+		if !forge.IsOwnContext(sc) && !slices.Contains(out, sc) {
+			out = append(out, sc)
+		}
+	}
+	if rsc.Contexts != nil {
+		for _, sc := range *rsc.Contexts {
+			add(sc)
+		}
+	}
+	if rsc.Checks != nil {
+		for _, chk := range *rsc.Checks {
+			add(chk.Context)
+		}
+	}
+	return out, nil
+}
+
 func (f *githubForge) GetCheckStates(ctx context.Context, owner, name, sha string) (map[string]forge.Check, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +253,7 @@ func (f *githubForge) GetCheckStates(ctx context.Context, owner, name, sha strin
 }
 
 func (f *githubForge) CreateMergeBranch(ctx context.Context, owner, name, base, headSHA, branch string) (string, bool, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return "", false, err
 	}
@@ -223,7 +313,7 @@ func mergeHead(ctx context.Context, c *gh.Client, owner, name, branch, headSHA s
 }
 
 func (f *githubForge) MergeInto(ctx context.Context, owner, name, branch, headSHA string) (string, bool, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return "", false, err
 	}
@@ -243,7 +333,7 @@ func (f *githubForge) MergeInto(ctx context.Context, owner, name, branch, headSH
 }
 
 func (f *githubForge) FastForward(ctx context.Context, owner, name, branch, sha string) error {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return err
 	}
@@ -270,7 +360,7 @@ func (f *githubForge) FastForward(ctx context.Context, owner, name, branch, sha
 }
 
 func (f *githubForge) ClosePR(ctx context.Context, owner, name string, number int64) error {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return err
 	}
@@ -288,7 +378,7 @@ func (f *githubForge) ClosePR(ctx context.Context, owner, name string, number in
 }
 
 func (f *githubForge) IsUpToDate(ctx context.Context, owner, name, base, headSHA string) (bool, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return false, err
 	}
@@ -301,7 +391,7 @@ func (f *githubForge) IsUpToDate(ctx context.Context, owner, name, base, headSHA
 }
 
 func (f *githubForge) DeleteBranch(ctx context.Context, owner, name, branch string) error {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return err
 	}
@@ -315,7 +405,7 @@ func (f *githubForge) DeleteBranch(ctx context.Context, owner, name, branch stri
 }
 
 func (f *githubForge) ListBranches(ctx context.Context, owner, name string) ([]string, error) {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return nil, err
 	}
@@ -333,50 +423,78 @@ func (f *githubForge) ListBranches(ctx context.Context, owner, name string) ([]s
 const disableAutoMergeMutation = `mutation($id:ID!){disablePullRequestAutoMerge(input:{pullRequestId:$id}){clientMutationId}}`
 
 func (f *githubForge) CancelAutoMerge(ctx context.Context, owner, name string, number int64) error {
-	c, err := f.app.ClientForRepo(owner, name)
-	if err != nil {
+	// Without auto-merge on the server there is nothing to cancel, and the
+	// mutation does not exist in its schema.
+	if !f.src.capabilities(ctx).autoMerge {
+		return nil
+	}
+	msg, err := f.prMutation(ctx, owner, name, number, disableAutoMergeMutation, nil)
+	if err != nil || msg == "" {
 		return err
 	}
+	// Already disabled is success for the queue's purposes.
+	if strings.Contains(strings.ToLower(msg), "not enabled") {
+		return nil
+	}
+	return fmt.Errorf("disablePullRequestAutoMerge: %s", msg)
+}
+
+// prMutation runs a GraphQL mutation whose $id is the PR's node ID, plus
+// vars. It returns the first GraphQL error message, if any.
+func (f *githubForge) prMutation(ctx context.Context, owner, name string, number int64, mutation string, vars map[string]any) (string, error) {
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return "", err
+	}
 	pr, _, err := c.PullRequests.Get(ctx, owner, name, int(number))
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	payload, _ := json.Marshal(map[string]any{
-		"query":     disableAutoMergeMutation,
-		"variables": map[string]any{"id": pr.GetNodeID()},
-	})
-	req, err := http.NewRequestWithContext(ctx, "POST", f.app.graphqlURL(), bytes.NewReader(payload))
+	variables := map[string]any{"id": pr.GetNodeID()}
+	maps.Copy(variables, vars)
+	return f.graphql(ctx, c, mutation, variables, nil)
+}
+
+// graphql posts query with vars and decodes its data into out, if non-nil.
+// It returns the first GraphQL error message, if any.
+func (f *githubForge) graphql(ctx context.Context, c *gh.Client, query string, vars map[string]any, out any) (string, error) {
+	payload, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+	req, err := http.NewRequestWithContext(ctx, "POST", f.src.Endpoints().GraphQL, bytes.NewReader(payload))
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.Client().Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("graphql: %s", resp.Status)
+	}
 
 	var body struct {
+		Data   json.RawMessage            `json:"data"`
 		Errors []struct{ Message string } `json:"errors"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return fmt.Errorf("decode graphql response: %w", err)
+		return "", fmt.Errorf("decode graphql response: %w", err)
 	}
 	if len(body.Errors) > 0 {
-		msg := body.Errors[0].Message
-		// Already disabled is success for the queue's purposes.
-		if strings.Contains(strings.ToLower(msg), "not enabled") {
-			return nil
+		return body.Errors[0].Message, nil
+	}
+	if out != nil {
+		if err := json.Unmarshal(body.Data, out); err != nil {
+			return "", fmt.Errorf("decode graphql data: %w", err)
 		}
-		return fmt.Errorf("disablePullRequestAutoMerge: %s", msg)
 	}
-	return nil
+	return "", nil
 }
 
 func (f *githubForge) Comment(ctx context.Context, owner, name string, number int64, body string) error {
-	c, err := f.app.ClientForRepo(owner, name)
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,8 @@ package github_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
 	"slices"
 	"strings"
 	"testing"
@@ -19,18 +21,17 @@ func newTestForge(t *testing.T) (*ghfake.Server, forge.Forge) {
 	t.Cleanup(srv.Close)
 	srv.AddRepo("org", "app")
 	srv.AddInstallation(100, "org/app")
-	// htmlURL irrelevant for API tests; use github.com so URL helpers are
-	// asserted against the production shape.
-	return srv, githubpkg.NewForge(newTestApp(t, srv), "")
+	return srv, githubpkg.NewForge(newTestApp(t, srv))
 }
 
 func TestForge_URLHelpers(t *testing.T) {
-	_, f := newTestForge(t)
-	if got := f.RepoHTMLURL("o", "r"); got != "https://github.com/o/r" {
-		t.Errorf("RepoHTMLURL = %q", got)
+	srv, f := newTestForge(t)
+	// Web links follow the App's deployment, here the fake's root.
+	if got, want := f.RepoHTMLURL("o", "r"), srv.WebURL()+"/o/r"; got != want {
+		t.Errorf("RepoHTMLURL = %q, want %q", got, want)
 	}
-	if got := f.BranchHTMLURL("o", "r", "gitea-mq/7"); got != "https://github.com/o/r/tree/gitea-mq/7" {
-		t.Errorf("BranchHTMLURL = %q", got)
+	if got, want := f.BranchHTMLURL("o", "r", "gitea-mq/7"), srv.WebURL()+"/o/r/tree/gitea-mq/7"; got != want {
+		t.Errorf("BranchHTMLURL = %q, want %q", got, want)
 	}
 }
 
@@ -83,7 +84,7 @@ func TestForge_SetMQStatus_Upsert(t *testing.T) {
 	}
 
 	// Fresh forge instance, same server state: cold cache must find existing.
-	f2 := githubpkg.NewForge(newTestApp(t, srv), "")
+	f2 := githubpkg.NewForge(newTestApp(t, srv))
 	if err := f2.SetMQStatus(ctx, "org", "app", "abc", forge.MQStatus{State: pg.CheckStateFailure}); err != nil {
 		t.Fatalf("restart: %v", err)
 	}
@@ -93,6 +94,22 @@ func TestForge_SetMQStatus_Upsert(t *testing.T) {
 	}
 }
 
+func TestForge_SetMQStatus_Report(t *testing.T) {
+	srv, f := newTestForge(t)
+	if !f.Capabilities().StatusReport {
+		t.Fatal("App mode should show status reports")
+	}
+	if err := f.SetMQStatus(context.Background(), "org", "app", "abc", forge.MQStatus{
+		State: pg.CheckStatePending, Description: "Testing batch #1 (2 PRs)", Report: "### Batch #1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runs := srv.Repo("org", "app").CheckRuns["abc"]
+	if len(runs) != 1 || runs[0].Output.Summary != "Testing batch #1 (2 PRs)" || runs[0].Output.Text != "### Batch #1" {
+		t.Fatalf("want the report as check-run text, got %+v", runs)
+	}
+}
+
 func TestForge_GetRequiredChecks_ExcludesSelf(t *testing.T) {
 	srv, f := newTestForge(t)
 	srv.Repo("org", "app").RequiredChecks["main"] = []string{"ci/build", forge.MQContext, "ci/test"}
@@ -202,6 +219,23 @@ func TestForge_ClosePR(t *testing.T) {
 	}
 }
 
+// Only the public profile address is visible to an App; a hidden one is "".
+func TestForge_UserEmail(t *testing.T) {
+	srv, f := newTestForge(t)
+	srv.SetUserEmail("alice", "alice@example.com")
+	r, ok := f.(forge.EmailResolver)
+	if !ok {
+		t.Fatal("github forge does not implement EmailResolver")
+	}
+	ctx := context.Background()
+	if got, err := r.UserEmail(ctx, "org", "app", "alice"); err != nil || got != "alice@example.com" {
+		t.Errorf("UserEmail(alice) = %q, %v", got, err)
+	}
+	if got, err := r.UserEmail(ctx, "org", "app", "bob"); err != nil || got != "" {
+		t.Errorf("UserEmail(bob) = %q, %v; want empty", got, err)
+	}
+}
+
 // 409 from the merge endpoint must surface as (conflict=true, err=nil).
 func TestForge_CreateMergeBranch_Conflict(t *testing.T) {
 	srv, f := newTestForge(t)
@@ -284,3 +318,142 @@ func TestForge_CancelAutoMerge(t *testing.T) {
 		t.Fatalf("second cancel: %v", err)
 	}
 }
+
+func TestForge_RepoPrivate(t *testing.T) {
+	srv, f := newTestForge(t)
+	v, ok := f.(forge.RepoVisibility)
+	if !ok {
+		t.Fatal("github forge does not implement RepoVisibility")
+	}
+	ctx := context.Background()
+	if got, err := v.RepoPrivate(ctx, "org", "app"); err != nil || got {
+		t.Errorf("RepoPrivate(public) = %v, %v", got, err)
+	}
+	srv.Repo("org", "app").Private = true
+	if got, err := v.RepoPrivate(ctx, "org", "app"); err != nil || !got {
+		t.Errorf("RepoPrivate(private) = %v, %v", got, err)
+	}
+}
+
+// The gitea-mq check run offers removal while queued and a retry once
+// removed; a later status replaces the buttons.
+func TestForge_SetMQStatus_Actions(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	buttons := func() []string {
+		var ids []string
+		for _, a := range srv.Repo("org", "app").CheckRuns["abc"][0].Actions {
+			ids = append(ids, a.Identifier)
+		}
+		return ids
+	}
+
+	for _, tc := range []struct {
+		state pg.CheckState
+		want  []string
+	}{
+		{pg.CheckStatePending, []string{githubpkg.ActionDequeue}},
+		{pg.CheckStateError, []string{githubpkg.ActionRetry}},
+		{pg.CheckStateSuccess, nil},
+	} {
+		if err := f.SetMQStatus(ctx, "org", "app", "abc", forge.MQStatus{State: tc.state}); err != nil {
+			t.Fatalf("%s: %v", tc.state, err)
+		}
+		if got := buttons(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: buttons = %v, want %v", tc.state, got, tc.want)
+		}
+	}
+}
+
+func TestForge_StatusActions(t *testing.T) {
+	srv, f := newTestForge(t)
+	a, ok := f.(forge.StatusActions)
+	if !ok {
+		t.Fatal("github forge does not implement StatusActions")
+	}
+	repo := srv.Repo("org", "app")
+	repo.Permissions["alice"] = "write"
+	repo.Permissions["bob"] = "read"
+	repo.Settings["allow_merge_commit"] = false
+	repo.Settings["allow_squash_merge"] = true
+	p := srv.AddPR("org", "app", ghfake.PR{Number: 1, BaseRef: "main"})
+	ctx := context.Background()
+
+	for login, want := range map[string]bool{"alice": true, "bob": false, "mallory": false} {
+		if got, err := a.CanWrite(ctx, "org", "app", login); err != nil || got != want {
+			t.Errorf("CanWrite(%s) = %v, %v; want %v", login, got, err, want)
+		}
+	}
+
+	if err := a.EnableAutoMerge(ctx, "org", "app", 1); err != nil {
+		t.Fatalf("EnableAutoMerge: %v", err)
+	}
+	if !p.AutoMerge || p.AutoMergeMethod != "SQUASH" {
+		t.Errorf("auto-merge = %v via %q, want on via SQUASH", p.AutoMerge, p.AutoMergeMethod)
+	}
+}
+
+func TestForge_OpenPRSnapshot(t *testing.T) {
+	srv, f := newTestForge(t)
+	if !f.Capabilities().BulkReconcile {
+		t.Fatal("github forge should advertise BulkReconcile")
+	}
+	b := f.(forge.BulkReconciler)
+	repo := srv.Repo("org", "app")
+	repo.RequiredChecks["main"] = []string{"ci/build", forge.MQContext}
+	// More than one page of PRs.
+	for n := int64(1); n <= 60; n++ {
+		srv.AddPR("org", "app", ghfake.PR{
+			Number: n, BaseRef: "main", HeadSHA: fmt.Sprintf("sha%d", n), User: "alice", AutoMerge: n == 7,
+		})
+	}
+	srv.AddPR("org", "app", ghfake.PR{Number: 61, State: "closed", BaseRef: "main", HeadSHA: "sha61"})
+	repo.CheckRuns["sha7"] = []*ghfake.CheckRun{
+		{Name: "ci/build", Status: "completed", Conclusion: "success"},
+		{Name: "ci/lint", Status: "in_progress"},
+		{Name: forge.MQContext, Status: "in_progress"},
+	}
+	repo.Statuses["sha7"] = []ghfake.Status{
+		{Context: "ci/build", State: "failure"},
+		{Context: "legacy", State: "pending"},
+		{Context: "legacy", State: "success"},
+	}
+
+	snap, err := b.OpenPRSnapshot(context.Background(), "org", "app")
+	if err != nil {
+		t.Fatalf("OpenPRSnapshot: %v", err)
+	}
+	if len(snap.PRs) != 60 {
+		t.Fatalf("got %d PRs, want the 60 open ones", len(snap.PRs))
+	}
+	pr := snap.PRs[6]
+	if pr.Number != 7 || !pr.AutoMergeEnabled || pr.HeadSHA != "sha7" || pr.AuthorLogin != "alice" || pr.BaseBranch != "main" {
+		t.Errorf("PR #7 = %+v", pr)
+	}
+	want := map[string]pg.CheckState{
+		"ci/build": pg.CheckStateSuccess, // the check run wins over the status
+		"ci/lint":  pg.CheckStatePending,
+		"legacy":   pg.CheckStateSuccess,
+	}
+	got := map[string]pg.CheckState{}
+	for name, c := range snap.Checks["sha7"] {
+		got[name] = c.State
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("checks = %v, want %v", got, want)
+	}
+	if req := snap.RequiredChecks["main"]; !slices.Equal(req, []string{"ci/build"}) {
+		t.Errorf("required checks = %v", req)
+	}
+}
+
+// Without rulesets the base branch rules cannot be queried, so the poller
+// has to fall back to REST.
+func TestForge_OpenPRSnapshot_LegacyServer(t *testing.T) {
+	srv, f := newTestForge(t)
+	srv.SetInstalledVersion("3.10.0")
+	_, err := f.(forge.BulkReconciler).OpenPRSnapshot(context.Background(), "org", "app")
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("err = %v, want ErrUnsupported", err)
+	}
+}
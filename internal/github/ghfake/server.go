@@ -6,8 +6,11 @@ package ghfake
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,7 +36,10 @@ type PR struct {
 	BaseRef   string
 	NodeID    string
 	AutoMerge bool
-	HTMLURL   string
+	// AutoMergeMethod is the mergeMethod enablePullRequestAutoMerge was
+	// called with.
+	AutoMergeMethod string
+	HTMLURL         string
 }
 
 type CheckRun struct {
@@ -43,16 +49,42 @@ type CheckRun struct {
 	Status     string
 	Conclusion string
 	DetailsURL string
-	Output     struct{ Title, Summary string }
+	Output     struct{ Title, Summary, Text string }
+	Actions    []gh.CheckRunAction
+}
+
+// Status is a commit status as posted via POST /statuses/{sha}.
+type Status struct {
+	Context     string `json:"context"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// Hook is a repo webhook as managed via /repos/{o}/{r}/hooks.
+type Hook struct {
+	ID     int64
+	Events []string
+	URL    string
+	Secret string
 }
 
 type Ruleset struct {
-	ID          int64           `json:"id"`
-	Name        string          `json:"name"`
-	Target      string          `json:"target"`
-	Enforcement string          `json:"enforcement"`
-	Conditions  json.RawMessage `json:"conditions,omitempty"`
-	Rules       []RulesetRule   `json:"rules"`
+	ID           int64           `json:"id"`
+	Name         string          `json:"name"`
+	Target       string          `json:"target"`
+	Enforcement  string          `json:"enforcement"`
+	BypassActors []BypassActor   `json:"bypass_actors,omitempty"`
+	Conditions   json.RawMessage `json:"conditions,omitempty"`
+	Rules        []RulesetRule   `json:"rules"`
+	// Updates counts PUTs, so tests can tell a repair from a no-op.
+	Updates int `json:"-"`
+}
+
+type BypassActor struct {
+	ActorID    int64  `json:"actor_id"`
+	ActorType  string `json:"actor_type"`
+	BypassMode string `json:"bypass_mode"`
 }
 
 type RulesetRule struct {
@@ -63,6 +95,7 @@ type RulesetRule struct {
 type Repo struct {
 	Owner, Name   string
 	DefaultBranch string
+	Private       bool
 
 	PRs  map[int64]*PR
 	Refs map[string]string // branch -> sha
@@ -71,13 +104,20 @@ type Repo struct {
 	// its fast-forward check.
 	Parents   map[string][]string
 	CheckRuns map[string][]*CheckRun
-	Rulesets  []*Ruleset
+	// Statuses[sha] lists commit statuses in posting order.
+	Statuses map[string][]Status
+	Rulesets []*Ruleset
+	Hooks    []*Hook
+	// Topics and Admin feed GET /user/repos for token-mode discovery.
+	// Admin reports the token user's admin permission.
+	Topics []string
+	Admin  bool
 	// BehindBy["base...head"] feeds GET /compare/{base}...{head}.behind_by.
 	// Missing entries default to 0 (head up to date with base).
 	BehindBy map[string]int
 	// ConflictOn[head] makes POST /merges with that head return 409.
 	ConflictOn map[string]bool
-	// Settings tracks PATCH /repos/{o}/{r} keys.
+	// Settings tracks PATCH /repos/{o}/{r} keys; GET returns them too.
 	Settings map[string]any
 
 	// ProtectedRefs[branch]=true makes a non-force PATCH on that ref return
@@ -88,6 +128,10 @@ type Repo struct {
 	// required_status_checks rule, decoupled from Rulesets so tests can
 	// stub rule evaluation directly.
 	RequiredChecks map[string][]string
+
+	// Permissions[login] feeds /collaborators/{login}/permission; missing
+	// users have "none".
+	Permissions map[string]string
 }
 
 // HookConfig mirrors the App-level webhook config (PATCH /app/hook/config).
@@ -104,20 +148,77 @@ type Server struct {
 	installs map[int64]*Installation
 	repos    map[string]*Repo // owner/name
 	hookCfg  HookConfig
+	emails   map[string]string // login -> public profile e-mail
 	idSeq    atomic.Int64
+
+	prefix string // path the deployment is served below, e.g. /ghe
+	// version is the GHES installed_version reported by /meta; empty
+	// behaves like github.com. Releases older than a feature hide its
+	// endpoints the way a real server does.
+	version string
 }
 
-func New() *Server {
+func New() *Server { return NewAt("") }
+
+// NewAt serves the fake below prefix (e.g. "/ghe"), like an enterprise server
+// behind a path-routing proxy. Requests outside the prefix get 404.
+func NewAt(prefix string) *Server {
 	s := &Server{
 		installs: map[int64]*Installation{},
 		repos:    map[string]*Repo{},
+		emails:   map[string]string{},
+		prefix:   strings.TrimRight(prefix, "/"),
 	}
 	mux := http.NewServeMux()
 	s.routes(mux)
-	s.Server = httptest.NewServer(mux)
+	var h http.Handler = mux
+	if s.prefix != "" {
+		h = http.StripPrefix(s.prefix, mux)
+	}
+	s.Server = httptest.NewServer(h)
 	return s
 }
 
+// WebURL is the deployment root, the value an operator configures as the
+// GitHub URL.
+func (s *Server) WebURL() string { return s.URL + s.prefix }
+
+// APIURL is the REST root below WebURL.
+func (s *Server) APIURL() string { return s.WebURL() + apiV3 }
+
+// SetInstalledVersion makes the fake report itself as the given GHES release.
+func (s *Server) SetInstalledVersion(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = v
+}
+
+// hasFeature reports whether the simulated release is at least major.minor.
+func (s *Server) hasFeature(major, minor int) bool {
+	s.mu.Lock()
+	v := s.version
+	s.mu.Unlock()
+	if v == "" {
+		return true
+	}
+	var ma, mi int
+	if _, err := fmt.Sscanf(v, "%d.%d", &ma, &mi); err != nil {
+		return true
+	}
+	return ma > major || (ma == major && mi >= minor)
+}
+
+func (s *Server) hMeta(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	v := s.version
+	s.mu.Unlock()
+	meta := map[string]any{"verifiable_password_authentication": false}
+	if v != "" {
+		meta["installed_version"] = v
+	}
+	writeJSON(w, 200, meta)
+}
+
 func (s *Server) nextID() int64 { return s.idSeq.Add(1) }
 
 func (s *Server) HookConfig() HookConfig {
@@ -170,11 +271,14 @@ func (s *Server) AddRepo(owner, name string) *Repo {
 		Refs:           map[string]string{"main": "sha-main"},
 		Parents:        map[string][]string{},
 		CheckRuns:      map[string][]*CheckRun{},
+		Statuses:       map[string][]Status{},
+		Admin:          true,
 		BehindBy:       map[string]int{},
 		ConflictOn:     map[string]bool{},
 		ProtectedRefs:  map[string]bool{},
 		Settings:       map[string]any{},
 		RequiredChecks: map[string][]string{},
+		Permissions:    map[string]string{},
 	}
 	s.repos[owner+"/"+name] = r
 	return r
@@ -198,6 +302,13 @@ func (s *Server) AddPR(owner, name string, pr PR) *PR {
 	return r.PRs[pr.Number]
 }
 
+// SetUserEmail sets the public profile e-mail GET /users/{login} returns.
+func (s *Server) SetUserEmail(login, email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emails[login] = email
+}
+
 func (s *Server) Repo(owner, name string) *Repo {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -205,7 +316,7 @@ func (s *Server) Repo(owner, name string) *Repo {
 }
 
 func (s *Server) Client() *gh.Client {
-	c, err := gh.NewClient(nil).WithEnterpriseURLs(s.URL, s.URL)
+	c, err := gh.NewClient(nil).WithEnterpriseURLs(s.APIURL(), s.APIURL())
 	if err != nil {
 		panic(err)
 	}
@@ -224,6 +335,11 @@ func (s *Server) routes(mux *http.ServeMux) {
 	mux.HandleFunc("POST "+apiV3+"/app/installations/{id}/access_tokens", s.hAccessToken)
 	mux.HandleFunc("GET "+apiV3+"/installation/repositories", s.hInstallRepos)
 
+	mux.HandleFunc("GET "+apiV3+"/meta", s.hMeta)
+
+	// Users.
+	mux.HandleFunc("GET "+apiV3+"/users/{login}", s.hGetUser)
+
 	// Repos.
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}", s.hGetRepo)
 	mux.HandleFunc("PATCH "+apiV3+"/repos/{o}/{r}", s.hPatchRepo)
@@ -234,6 +350,8 @@ func (s *Server) routes(mux *http.ServeMux) {
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/pulls/{n}", s.hGetPR)
 	mux.HandleFunc("PATCH "+apiV3+"/repos/{o}/{r}/pulls/{n}", s.hEditPR)
 
+	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/collaborators/{login}/permission", s.hPermission)
+
 	// Issues (comments).
 	mux.HandleFunc("POST "+apiV3+"/repos/{o}/{r}/issues/{n}/comments", s.hCreateComment)
 
@@ -242,6 +360,14 @@ func (s *Server) routes(mux *http.ServeMux) {
 	mux.HandleFunc("PATCH "+apiV3+"/repos/{o}/{r}/check-runs/{id}", s.hUpdateCheckRun)
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/commits/{sha}/check-runs", s.hListCheckRuns)
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/commits/{sha}/statuses", s.hListStatuses)
+	mux.HandleFunc("POST "+apiV3+"/repos/{o}/{r}/statuses/{sha}", s.hCreateStatus)
+
+	// Repo webhooks (token mode).
+	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/hooks", s.hListHooks)
+	mux.HandleFunc("POST "+apiV3+"/repos/{o}/{r}/hooks", s.hCreateHook)
+	mux.HandleFunc("PATCH "+apiV3+"/repos/{o}/{r}/hooks/{id}", s.hEditHook)
+	mux.HandleFunc("DELETE "+apiV3+"/repos/{o}/{r}/hooks/{id}", s.hDeleteHook)
+	mux.HandleFunc("GET "+apiV3+"/user/repos", s.hUserRepos)
 
 	// Git refs / branches.
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/git/ref/{ref...}", s.hGetRef)
@@ -249,13 +375,17 @@ func (s *Server) routes(mux *http.ServeMux) {
 	mux.HandleFunc("PATCH "+apiV3+"/repos/{o}/{r}/git/refs/{ref...}", s.hUpdateRef)
 	mux.HandleFunc("DELETE "+apiV3+"/repos/{o}/{r}/git/refs/{ref...}", s.hDeleteRef)
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/branches", s.hListBranches)
+	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/branches/{b}/protection/required_status_checks", s.hClassicRequiredChecks)
 	mux.HandleFunc("POST "+apiV3+"/repos/{o}/{r}/merges", s.hMerge)
 	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/compare/{basehead...}", s.hCompare)
 
 	// Rules / rulesets.
-	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/rules/branches/{b}", s.hRulesForBranch)
-	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/rulesets", s.hListRulesets)
-	mux.HandleFunc("POST "+apiV3+"/repos/{o}/{r}/rulesets", s.hCreateRuleset)
+	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/rules/branches/{b}", s.since(3, 11, s.hRulesForBranch))
+	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/rulesets", s.since(3, 11, s.hListRulesets))
+	mux.HandleFunc("POST "+apiV3+"/repos/{o}/{r}/rulesets", s.since(3, 11, s.hCreateRuleset))
+	mux.HandleFunc("GET "+apiV3+"/repos/{o}/{r}/rulesets/{id}", s.since(3, 11, s.hGetRuleset))
+	mux.HandleFunc("PUT "+apiV3+"/repos/{o}/{r}/rulesets/{id}", s.since(3, 11, s.hUpdateRuleset))
+	mux.HandleFunc("DELETE "+apiV3+"/repos/{o}/{r}/rulesets/{id}", s.since(3, 11, s.hDeleteRuleset))
 
 	// GraphQL.
 	mux.HandleFunc("POST /api/graphql", s.hGraphQL)
@@ -265,6 +395,18 @@ func (s *Server) routes(mux *http.ServeMux) {
 	})
 }
 
+// since hides an endpoint on simulated GHES releases older than
+// major.minor, which answer with a plain 404.
+func (s *Server) since(major, minor int, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.hasFeature(major, minor) {
+			http.NotFound(w, r)
+			return
+		}
+		h(w, r)
+	}
+}
+
 func (s *Server) repo(r *http.Request) (*Repo, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -340,15 +482,32 @@ func repoJSON(r *Repo) map[string]any {
 		"owner":          map[string]any{"login": r.Owner},
 		"default_branch": r.DefaultBranch,
 		"html_url":       "https://github.com/" + r.Owner + "/" + r.Name,
+		"private":        r.Private,
 	}
 }
 
+func (s *Server) hGetUser(w http.ResponseWriter, r *http.Request) {
+	login := r.PathValue("login")
+	s.mu.Lock()
+	email := s.emails[login]
+	s.mu.Unlock()
+	out := map[string]any{"login": login}
+	if email != "" {
+		out["email"] = email
+	}
+	writeJSON(w, 200, out)
+}
+
 func (s *Server) hGetRepo(w http.ResponseWriter, r *http.Request) {
 	rp, ok := s.repoOr404(w, r)
 	if !ok {
 		return
 	}
-	writeJSON(w, 200, repoJSON(rp))
+	out := repoJSON(rp)
+	s.mu.Lock()
+	maps.Copy(out, rp.Settings)
+	s.mu.Unlock()
+	writeJSON(w, 200, out)
 }
 
 func (s *Server) hPatchRepo(w http.ResponseWriter, r *http.Request) {
@@ -473,17 +632,35 @@ func (s *Server) hCreateComment(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, 201, map[string]any{"id": s.nextID()})
 }
 
+func (s *Server) hPermission(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	login := r.PathValue("login")
+	s.mu.Lock()
+	perm := rp.Permissions[login]
+	s.mu.Unlock()
+	if perm == "" {
+		perm = "none"
+	}
+	writeJSON(w, 200, map[string]any{"permission": perm, "user": map[string]any{"login": login}})
+}
+
 // --- handlers: check runs ---
 
 func checkRunJSON(c *CheckRun) map[string]any {
 	out := map[string]any{
 		"id": c.ID, "name": c.Name, "head_sha": c.HeadSHA,
 		"status": c.Status, "details_url": c.DetailsURL,
-		"output": map[string]any{"title": c.Output.Title, "summary": c.Output.Summary},
+		"output": map[string]any{"title": c.Output.Title, "summary": c.Output.Summary, "text": c.Output.Text},
 	}
 	if c.Conclusion != "" {
 		out["conclusion"] = c.Conclusion
 	}
+	if len(c.Actions) > 0 {
+		out["actions"] = c.Actions
+	}
 	return out
 }
 
@@ -498,13 +675,14 @@ func (s *Server) hCreateCheckRun(w http.ResponseWriter, r *http.Request) {
 		Status     string `json:"status"`
 		Conclusion string `json:"conclusion"`
 		DetailsURL string `json:"details_url"`
-		Output     struct{ Title, Summary string }
+		Output     struct{ Title, Summary, Text string }
+		Actions    []gh.CheckRunAction `json:"actions"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&body)
 	cr := &CheckRun{
 		ID: s.nextID(), Name: body.Name, HeadSHA: body.HeadSHA,
 		Status: body.Status, Conclusion: body.Conclusion, DetailsURL: body.DetailsURL,
-		Output: body.Output,
+		Output: body.Output, Actions: body.Actions,
 	}
 	s.mu.Lock()
 	rp.CheckRuns[body.HeadSHA] = append(rp.CheckRuns[body.HeadSHA], cr)
@@ -523,7 +701,9 @@ func (s *Server) hUpdateCheckRun(w http.ResponseWriter, r *http.Request) {
 		Status     string `json:"status"`
 		Conclusion string `json:"conclusion"`
 		DetailsURL string `json:"details_url"`
-		Output     struct{ Title, Summary string }
+		Output     struct{ Title, Summary, Text string }
+		// A present but empty list clears the buttons.
+		Actions *[]gh.CheckRunAction `json:"actions"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&body)
 	s.mu.Lock()
@@ -545,6 +725,9 @@ func (s *Server) hUpdateCheckRun(w http.ResponseWriter, r *http.Request) {
 			if body.Output.Title != "" || body.Output.Summary != "" {
 				cr.Output = body.Output
 			}
+			if body.Actions != nil {
+				cr.Actions = *body.Actions
+			}
 			writeJSON(w, 200, checkRunJSON(cr))
 			return
 		}
@@ -571,11 +754,156 @@ func (s *Server) hListCheckRuns(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, 200, map[string]any{"total_count": len(out), "check_runs": out})
 }
 
+// hListStatuses returns newest first, like GitHub.
 func (s *Server) hListStatuses(w http.ResponseWriter, r *http.Request) {
-	if _, ok := s.repoOr404(w, r); !ok {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	sts := rp.Statuses[r.PathValue("sha")]
+	out := make([]Status, 0, len(sts))
+	for i := len(sts) - 1; i >= 0; i-- {
+		out = append(out, sts[i])
+	}
+	s.mu.Unlock()
+	writeJSON(w, 200, out)
+}
+
+func (s *Server) hCreateStatus(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	var st Status
+	_ = json.NewDecoder(r.Body).Decode(&st)
+	switch st.State {
+	case "pending", "success", "failure", "error":
+	default:
+		http.Error(w, `{"message":"Validation Failed"}`, http.StatusUnprocessableEntity)
+		return
+	}
+	if len([]rune(st.Description)) > 140 {
+		http.Error(w, `{"message":"description is too long (maximum is 140 characters)"}`, http.StatusUnprocessableEntity)
+		return
+	}
+	if st.Context == "" {
+		st.Context = "default"
+	}
+	s.mu.Lock()
+	sha := r.PathValue("sha")
+	rp.Statuses[sha] = append(rp.Statuses[sha], st)
+	s.mu.Unlock()
+	writeJSON(w, 201, st)
+}
+
+// --- handlers: hooks ---
+
+func hookJSON(h *Hook) map[string]any {
+	return map[string]any{
+		"id":     h.ID,
+		"active": true,
+		"events": h.Events,
+		// GitHub never echoes the secret back.
+		"config": map[string]any{"url": h.URL, "content_type": "json", "secret": "********"},
+	}
+}
+
+type hookBody struct {
+	Events []string `json:"events"`
+	Config struct {
+		URL    string `json:"url"`
+		Secret string `json:"secret"`
+	} `json:"config"`
+}
+
+func (s *Server) hListHooks(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
 		return
 	}
-	writeJSON(w, 200, []any{})
+	s.mu.Lock()
+	out := make([]map[string]any, 0, len(rp.Hooks))
+	for _, h := range rp.Hooks {
+		out = append(out, hookJSON(h))
+	}
+	s.mu.Unlock()
+	writeJSON(w, 200, out)
+}
+
+func (s *Server) hCreateHook(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	var in hookBody
+	_ = json.NewDecoder(r.Body).Decode(&in)
+	h := &Hook{ID: s.nextID(), Events: in.Events, URL: in.Config.URL, Secret: in.Config.Secret}
+	s.mu.Lock()
+	rp.Hooks = append(rp.Hooks, h)
+	out := hookJSON(h)
+	s.mu.Unlock()
+	writeJSON(w, 201, out)
+}
+
+func (s *Server) hEditHook(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	var in hookBody
+	_ = json.NewDecoder(r.Body).Decode(&in)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range rp.Hooks {
+		if h.ID != id {
+			continue
+		}
+		if in.Events != nil {
+			h.Events = in.Events
+		}
+		if in.Config.URL != "" {
+			h.URL = in.Config.URL
+		}
+		if in.Config.Secret != "" {
+			h.Secret = in.Config.Secret
+		}
+		writeJSON(w, 200, hookJSON(h))
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (s *Server) hDeleteHook(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(rp.Hooks)
+	rp.Hooks = slices.DeleteFunc(rp.Hooks, func(h *Hook) bool { return h.ID == id })
+	if len(rp.Hooks) == n {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hUserRepos lists every repo as visible to the token user.
+func (s *Server) hUserRepos(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]map[string]any, 0, len(s.repos))
+	for _, rp := range s.repos {
+		j := repoJSON(rp)
+		j["topics"] = rp.Topics
+		j["permissions"] = map[string]any{"admin": rp.Admin, "push": true, "pull": true}
+		out = append(out, j)
+	}
+	writeJSON(w, 200, out)
 }
 
 // --- handlers: refs / merge ---
@@ -771,6 +1099,23 @@ func (s *Server) hRulesForBranch(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// hClassicRequiredChecks serves RequiredChecks as classic branch protection;
+// a branch without required checks is unprotected and answers 404.
+func (s *Server) hClassicRequiredChecks(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	checks := append([]string(nil), rp.RequiredChecks[r.PathValue("b")]...)
+	s.mu.Unlock()
+	if len(checks) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"strict": false, "contexts": checks})
+}
+
 func (s *Server) hListRulesets(w http.ResponseWriter, r *http.Request) {
 	rp, ok := s.repoOr404(w, r)
 	if !ok {
@@ -795,7 +1140,68 @@ func (s *Server) hCreateRuleset(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, 201, rs)
 }
 
-// --- GraphQL: only disablePullRequestAutoMerge ---
+// ruleset returns the ruleset named by the {id} path value; callers hold s.mu.
+func (rp *Repo) ruleset(r *http.Request) *Ruleset {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	for _, rs := range rp.Rulesets {
+		if rs.ID == id {
+			return rs
+		}
+	}
+	return nil
+}
+
+func (s *Server) hGetRuleset(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := rp.ruleset(r)
+	if rs == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, 200, rs)
+}
+
+func (s *Server) hUpdateRuleset(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	var upd Ruleset
+	_ = json.NewDecoder(r.Body).Decode(&upd)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := rp.ruleset(r)
+	if rs == nil {
+		http.NotFound(w, r)
+		return
+	}
+	upd.ID, upd.Updates = rs.ID, rs.Updates+1
+	*rs = upd
+	writeJSON(w, 200, rs)
+}
+
+func (s *Server) hDeleteRuleset(w http.ResponseWriter, r *http.Request) {
+	rp, ok := s.repoOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := rp.ruleset(r)
+	if rs == nil {
+		http.NotFound(w, r)
+		return
+	}
+	rp.Rulesets = slices.DeleteFunc(rp.Rulesets, func(x *Ruleset) bool { return x == rs })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- GraphQL: {enable,disable}PullRequestAutoMerge and the open PR query ---
 
 func (s *Server) hGraphQL(w http.ResponseWriter, r *http.Request) {
 	var body struct {
@@ -803,10 +1209,26 @@ func (s *Server) hGraphQL(w http.ResponseWriter, r *http.Request) {
 		Variables map[string]any `json:"variables"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&body)
-	if !strings.Contains(body.Query, "disablePullRequestAutoMerge") {
+	if strings.Contains(body.Query, "pullRequests(") {
+		s.gqlOpenPRs(w, body.Query, body.Variables)
+		return
+	}
+	var field string
+	switch {
+	case strings.Contains(body.Query, "disablePullRequestAutoMerge"):
+		field = "disablePullRequestAutoMerge"
+	case strings.Contains(body.Query, "enablePullRequestAutoMerge"):
+		field = "enablePullRequestAutoMerge"
+	default:
 		writeJSON(w, 200, map[string]any{"errors": []any{map[string]any{"message": "ghfake: unsupported query"}}})
 		return
 	}
+	if !s.hasFeature(3, 1) {
+		writeJSON(w, 200, map[string]any{"errors": []any{map[string]any{
+			"message": "Field '" + field + "' doesn't exist on type 'Mutation'",
+		}}})
+		return
+	}
 	nodeID, _ := body.Variables["id"].(string)
 	s.mu.Lock()
 	var found *PR
@@ -817,9 +1239,23 @@ func (s *Server) hGraphQL(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	if found == nil {
+		s.mu.Unlock()
+		writeJSON(w, 200, map[string]any{"errors": []any{map[string]any{"message": "Could not resolve to a node with the global id of '" + nodeID + "'"}}})
+		return
+	}
+	if field == "enablePullRequestAutoMerge" {
+		found.AutoMerge = true
+		found.AutoMergeMethod, _ = body.Variables["method"].(string)
+		s.mu.Unlock()
+		writeJSON(w, 200, map[string]any{"data": map[string]any{
+			field: map[string]any{"clientMutationId": nil},
+		}})
+		return
+	}
 	// Match real GitHub: disabling when not enabled is a GraphQL error so the
 	// adapter's tolerance path is exercised.
-	if found == nil || !found.AutoMerge {
+	if !found.AutoMerge {
 		s.mu.Unlock()
 		writeJSON(w, 200, map[string]any{"errors": []any{map[string]any{"message": "Pull request auto merge is not enabled."}}})
 		return
@@ -827,6 +1263,152 @@ func (s *Server) hGraphQL(w http.ResponseWriter, r *http.Request) {
 	found.AutoMerge = false
 	s.mu.Unlock()
 	writeJSON(w, 200, map[string]any{"data": map[string]any{
-		"disablePullRequestAutoMerge": map[string]any{"clientMutationId": nil},
+		field: map[string]any{"clientMutationId": nil},
 	}})
 }
+
+// gqlFirst matches the page size of a connection in a query.
+var gqlFirst = regexp.MustCompile(`(pullRequests|contexts)\([^)]*first:(\d+)`)
+
+// gqlOpenPRs answers the open PR query with RequiredChecks as the base
+// branch's rules and the latest check runs and statuses as the head
+// commit's rollup. Cursors are offsets into the PRs ordered by number.
+func (s *Server) gqlOpenPRs(w http.ResponseWriter, query string, vars map[string]any) {
+	first := map[string]int{"pullRequests": 100, "contexts": 100}
+	for _, m := range gqlFirst.FindAllStringSubmatch(query, -1) {
+		first[m[1]], _ = strconv.Atoi(m[2])
+	}
+	owner, _ := vars["owner"].(string)
+	name, _ := vars["name"].(string)
+	cursor, _ := vars["cursor"].(string)
+	offset, _ := strconv.Atoi(cursor)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rp := s.repos[owner+"/"+name]
+	if rp == nil {
+		writeJSON(w, 200, map[string]any{"errors": []any{map[string]any{
+			"message": "Could not resolve to a Repository with the name '" + owner + "/" + name + "'.",
+		}}})
+		return
+	}
+	var open []*PR
+	for _, p := range rp.PRs {
+		if p.State == "open" {
+			open = append(open, p)
+		}
+	}
+	slices.SortFunc(open, func(a, b *PR) int { return int(a.Number - b.Number) })
+	end := min(offset+first["pullRequests"], len(open))
+	offset = min(offset, end)
+
+	nodes := make([]map[string]any, 0, end-offset)
+	for _, p := range open[offset:end] {
+		var am any
+		if p.AutoMerge {
+			am = map[string]any{"enabledAt": "2024-01-01T00:00:00Z"}
+		}
+		var contexts []map[string]any
+		for _, cr := range rp.CheckRuns[p.HeadSHA] {
+			contexts = append(contexts, map[string]any{
+				"__typename": "CheckRun", "name": cr.Name,
+				"status": strings.ToUpper(cr.Status), "conclusion": strings.ToUpper(cr.Conclusion),
+				"detailsUrl": cr.DetailsURL, "summary": cr.Output.Summary,
+			})
+		}
+		seen := map[string]bool{}
+		sts := rp.Statuses[p.HeadSHA]
+		for i := len(sts) - 1; i >= 0; i-- {
+			if seen[sts[i].Context] {
+				continue
+			}
+			seen[sts[i].Context] = true
+			contexts = append(contexts, map[string]any{
+				"__typename": "StatusContext", "context": sts[i].Context,
+				"state": strings.ToUpper(sts[i].State), "description": sts[i].Description,
+				"targetUrl": sts[i].TargetURL,
+			})
+		}
+		var rollup any
+		if len(contexts) > 0 {
+			n := min(first["contexts"], len(contexts))
+			rollup = map[string]any{"contexts": map[string]any{
+				"pageInfo": map[string]any{"hasNextPage": n < len(contexts)},
+				"nodes":    contexts[:n],
+			}}
+		}
+		var rules []any
+		if checks := rp.RequiredChecks[p.BaseRef]; len(checks) > 0 {
+			var rc []map[string]any
+			for _, c := range checks {
+				rc = append(rc, map[string]any{"context": c})
+			}
+			rules = append(rules, map[string]any{
+				"type":       "REQUIRED_STATUS_CHECKS",
+				"parameters": map[string]any{"requiredStatusChecks": rc},
+			})
+		}
+		nodes = append(nodes, map[string]any{
+			"number": p.Number, "title": p.Title, "url": p.HTMLURL,
+			"headRefName": p.HeadRef, "headRefOid": p.HeadSHA, "baseRefName": p.BaseRef,
+			"author":           map[string]any{"login": p.User},
+			"autoMergeRequest": am,
+			"commits": map[string]any{"nodes": []any{map[string]any{
+				"commit": map[string]any{"statusCheckRollup": rollup},
+			}}},
+			"baseRef": map[string]any{"rules": map[string]any{"nodes": rules}},
+		})
+	}
+	writeJSON(w, 200, map[string]any{"data": map[string]any{
+		"repository": map[string]any{"pullRequests": map[string]any{
+			"pageInfo": map[string]any{"hasNextPage": end < len(open), "endCursor": strconv.Itoa(end)},
+			"nodes":    nodes,
+		}},
+	}})
+}
+
+// RequestAction returns the check_run "requested_action" event GitHub
+// delivers when sender presses the button identifier on check run runID.
+// It fails when the run does not offer that button.
+func (s *Server) RequestAction(owner, name string, runID int64, identifier, sender string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rp := s.repos[owner+"/"+name]
+	if rp == nil {
+		return nil, fmt.Errorf("ghfake: no repo %s/%s", owner, name)
+	}
+	var run *CheckRun
+	for _, runs := range rp.CheckRuns {
+		for _, c := range runs {
+			if c.ID == runID {
+				run = c
+			}
+		}
+	}
+	if run == nil {
+		return nil, fmt.Errorf("ghfake: no check run %d", runID)
+	}
+	if !slices.ContainsFunc(run.Actions, func(a gh.CheckRunAction) bool { return a.Identifier == identifier }) {
+		return nil, fmt.Errorf("ghfake: check run %d has no %q button", runID, identifier)
+	}
+
+	cr := checkRunJSON(run)
+	prs := make([]map[string]any, 0)
+	for _, p := range rp.PRs {
+		if p.State == "open" && p.HeadSHA == run.HeadSHA {
+			prs = append(prs, map[string]any{
+				"number": p.Number,
+				"head":   map[string]any{"ref": p.HeadRef, "sha": p.HeadSHA},
+				"base":   map[string]any{"ref": p.BaseRef},
+			})
+		}
+	}
+	cr["pull_requests"] = prs
+	return json.Marshal(map[string]any{
+		"action":           "requested_action",
+		"check_run":        cr,
+		"requested_action": map[string]any{"identifier": identifier},
+		"repository":       repoJSON(rp),
+		"sender":           map[string]any{"login": sender},
+	})
+}
@@ -0,0 +1,337 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	gh "github.com/google/go-github/v84/github"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+var _ forge.BranchGuard = (*githubForge)(nil)
+
+// defaultBranchRef is the ruleset include entry for the default branch.
+const defaultBranchRef = "~DEFAULT_BRANCH"
+
+// branchRef turns a branch name or pattern into a ruleset ref pattern.
+func branchRef(branch string) string { return "refs/heads/" + branch }
+
+// bypassActors are the actors the gitea-mq ruleset must exempt.
+func (f *githubForge) bypassActors() []*gh.BypassActor {
+	// Repo admins keep an escape hatch for hotfixes. In token mode this is
+	// also what lets the token's admin user fast-forward past the gate.
+	actors := []*gh.BypassActor{{
+		ActorID:    gh.Ptr(repoAdminRoleID),
+		ActorType:  gh.Ptr(gh.BypassActorTypeRepositoryRole),
+		BypassMode: gh.Ptr(gh.BypassModeAlways),
+	}}
+	if f.appID != 0 {
+		// The App must bypass its own gate to manage merge branches and
+		// to let GitHub fast-forward when it reports success.
+		actors = append([]*gh.BypassActor{{
+			ActorID:    gh.Ptr(f.appID),
+			ActorType:  gh.Ptr(gh.BypassActorTypeIntegration),
+			BypassMode: gh.Ptr(gh.BypassModeAlways),
+		}}, actors...)
+	}
+	return actors
+}
+
+// statusCheck is the required gitea-mq check. An App's check is pinned to
+// the App so nobody else can satisfy it with a commit status.
+func (f *githubForge) statusCheck() *gh.RuleStatusCheck {
+	check := &gh.RuleStatusCheck{Context: forge.MQContext}
+	if f.appID != 0 {
+		check.IntegrationID = gh.Ptr(f.appID)
+	}
+	return check
+}
+
+func (f *githubForge) newRuleset(include []string) gh.RepositoryRuleset {
+	return gh.RepositoryRuleset{
+		Name:         forge.MQContext,
+		Target:       gh.Ptr(gh.RulesetTargetBranch),
+		Enforcement:  gh.RulesetEnforcementActive,
+		BypassActors: f.bypassActors(),
+		Conditions: &gh.RepositoryRulesetConditions{
+			RefName: &gh.RepositoryRulesetRefConditionParameters{
+				// ~ALL would also gate every feature-branch push on a check
+				// that only ever reports for queued PRs.
+				Include: include,
+				Exclude: []string{},
+			},
+		},
+		Rules: &gh.RepositoryRulesetRules{
+			RequiredStatusChecks: &gh.RequiredStatusChecksRuleParameters{
+				RequiredStatusChecks:             []*gh.RuleStatusCheck{f.statusCheck()},
+				StrictRequiredStatusChecksPolicy: false,
+				// Otherwise the rule also gates branch *creation* and only
+				// the bypass actor could push a new branch.
+				DoNotEnforceOnCreate: gh.Ptr(true),
+			},
+		},
+	}
+}
+
+// rulesetWant is what the gitea-mq ruleset has to cover: the default
+// branch, the configured patterns and the branches PRs are queued against.
+type rulesetWant struct {
+	defaultBranch string
+	patterns      []string
+	branches      []string
+}
+
+func (w rulesetWant) include() []string {
+	include := []string{defaultBranchRef}
+	for _, p := range w.patterns {
+		include = append(include, branchRef(p))
+	}
+	for _, b := range w.branches {
+		if b == w.defaultBranch || slices.ContainsFunc(w.patterns, func(p string) bool { return globMatch(p, b) }) {
+			continue
+		}
+		include = append(include, branchRef(b))
+	}
+	return include
+}
+
+// refMatches reports whether the ruleset ref pattern covers branch.
+func refMatches(pattern, branch, defaultBranch string) bool {
+	switch pattern {
+	case "~ALL":
+		return true
+	case defaultBranchRef:
+		return branch == defaultBranch
+	}
+	return globMatch(strings.TrimPrefix(pattern, "refs/heads/"), branch)
+}
+
+// globMatch matches like GitHub's ref patterns: * and ? stop at a slash,
+// ** does not.
+func globMatch(pattern, branch string) bool {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			re.WriteString("[^/]*")
+		case pattern[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	ok, _ := regexp.MatchString(re.String(), branch)
+	return ok
+}
+
+// repairRuleset brings rs in line with what gitea-mq needs and describes
+// each difference it fixed. Entries the operator added are kept.
+func (f *githubForge) repairRuleset(rs *gh.RepositoryRuleset, want rulesetWant) []string {
+	var drift []string
+
+	if rs.Enforcement != gh.RulesetEnforcementActive {
+		drift = append(drift, fmt.Sprintf("is %s instead of active", rs.Enforcement))
+		rs.Enforcement = gh.RulesetEnforcementActive
+	}
+
+	if rs.Conditions == nil {
+		rs.Conditions = &gh.RepositoryRulesetConditions{}
+	}
+	if rs.Conditions.RefName == nil {
+		rs.Conditions.RefName = &gh.RepositoryRulesetRefConditionParameters{Exclude: []string{}}
+	}
+	refs := rs.Conditions.RefName
+	covered := func(branch string, patterns []string) bool {
+		return slices.ContainsFunc(patterns, func(p string) bool { return refMatches(p, branch, want.defaultBranch) })
+	}
+	// concrete names the branch a wanted include entry stands for; false
+	// for patterns and an unknown default branch.
+	concrete := func(ref string) (string, bool) {
+		if ref == defaultBranchRef {
+			return want.defaultBranch, want.defaultBranch != ""
+		}
+		b := strings.TrimPrefix(ref, "refs/heads/")
+		return b, !strings.ContainsAny(b, "*?")
+	}
+	include := want.include()
+	for _, ref := range include {
+		if slices.Contains(refs.Include, ref) || slices.Contains(refs.Include, "~ALL") {
+			continue
+		}
+		if b, ok := concrete(ref); ok && covered(b, refs.Include) {
+			continue
+		}
+		drift = append(drift, "does not include "+ref)
+		refs.Include = append(refs.Include, ref)
+	}
+	refs.Exclude = slices.DeleteFunc(refs.Exclude, func(ex string) bool {
+		hit := slices.Contains(include, ex) || slices.ContainsFunc(include, func(ref string) bool {
+			b, ok := concrete(ref)
+			return ok && refMatches(ex, b, want.defaultBranch)
+		})
+		if hit {
+			drift = append(drift, "excludes "+ex)
+		}
+		return hit
+	})
+
+	if rs.Rules == nil {
+		rs.Rules = &gh.RepositoryRulesetRules{}
+	}
+	check := f.statusCheck()
+	switch params := rs.Rules.RequiredStatusChecks; {
+	case params == nil:
+		drift = append(drift, "does not require "+forge.MQContext)
+		rs.Rules.RequiredStatusChecks = f.newRuleset(nil).Rules.RequiredStatusChecks
+	default:
+		i := slices.IndexFunc(params.RequiredStatusChecks, func(sc *gh.RuleStatusCheck) bool {
+			return sc.Context == forge.MQContext
+		})
+		switch {
+		case i < 0:
+			drift = append(drift, "does not require "+forge.MQContext)
+			params.RequiredStatusChecks = append(params.RequiredStatusChecks, check)
+		case params.RequiredStatusChecks[i].GetIntegrationID() != check.GetIntegrationID():
+			drift = append(drift, fmt.Sprintf("expects %s from another source", forge.MQContext))
+			params.RequiredStatusChecks[i] = check
+		}
+	}
+
+	// Only the actor gitea-mq itself pushes as is required; the admin role
+	// is an escape hatch in App mode and may be dropped.
+	need := f.bypassActors()
+	if f.appID != 0 {
+		need = need[:1]
+	}
+	for _, a := range need {
+		i := slices.IndexFunc(rs.BypassActors, func(b *gh.BypassActor) bool {
+			return b.ActorType != nil && *b.ActorType == *a.ActorType && b.GetActorID() == a.GetActorID()
+		})
+		switch {
+		case i < 0:
+			drift = append(drift, "does not exempt "+bypassActorName(a))
+			rs.BypassActors = append(rs.BypassActors, a)
+		case rs.BypassActors[i].BypassMode == nil || *rs.BypassActors[i].BypassMode != gh.BypassModeAlways:
+			drift = append(drift, "does not always exempt "+bypassActorName(a))
+			rs.BypassActors[i].BypassMode = gh.Ptr(gh.BypassModeAlways)
+		}
+	}
+	return drift
+}
+
+func bypassActorName(a *gh.BypassActor) string {
+	if *a.ActorType == gh.BypassActorTypeIntegration {
+		return "the App"
+	}
+	return "repo admins"
+}
+
+// mqRuleset returns the gitea-mq ruleset with its conditions and rules,
+// which the list endpoint leaves out; nil if the repo has none.
+func mqRuleset(ctx context.Context, c *gh.Client, owner, name string) (*gh.RepositoryRuleset, *gh.Response, error) {
+	rss, resp, err := c.Repositories.GetAllRulesets(ctx, owner, name, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+	for _, rs := range rss {
+		if rs.Name == forge.MQContext {
+			return c.Repositories.GetRuleset(ctx, owner, name, rs.GetID(), false)
+		}
+	}
+	return nil, resp, nil
+}
+
+// ensureRuleset creates the gitea-mq ruleset, or repairs it when it has
+// drifted from want. Missing Administration permission is logged, not
+// returned: the queue still runs against a hand-made setup.
+func (f *githubForge) ensureRuleset(ctx context.Context, c *gh.Client, owner, name string, want rulesetWant) error {
+	repo := owner + "/" + name
+	rs, resp, err := mqRuleset(ctx, c, owner, name)
+	if err != nil {
+		if !isForbidden(resp) {
+			return err
+		}
+		slog.Warn("github: cannot manage rulesets (no Administration permission)", "repo", repo, "err", err)
+		return nil
+	}
+
+	if rs == nil {
+		_, resp, err = c.Repositories.CreateRuleset(ctx, owner, name, f.newRuleset(want.include()))
+		if err != nil {
+			if !isForbidden(resp) {
+				return err
+			}
+			slog.Warn("github: cannot create ruleset (no Administration permission)", "repo", repo, "err", err)
+		}
+		return nil
+	}
+
+	drift := f.repairRuleset(rs, want)
+	if len(drift) == 0 {
+		return nil
+	}
+	_, resp, err = c.Repositories.UpdateRuleset(ctx, owner, name, rs.GetID(), gh.RepositoryRuleset{
+		Name:         rs.Name,
+		Target:       rs.Target,
+		Enforcement:  rs.Enforcement,
+		BypassActors: rs.BypassActors,
+		Conditions:   rs.Conditions,
+		Rules:        rs.Rules,
+	})
+	if err != nil {
+		if !isForbidden(resp) {
+			return fmt.Errorf("repair ruleset for %s: %w", repo, err)
+		}
+		slog.Warn("github: cannot repair ruleset (no Administration permission)",
+			"repo", repo, "drift", drift, "err", err)
+		return nil
+	}
+	slog.Info("github: repaired ruleset", "repo", repo, "drift", drift)
+	return nil
+}
+
+// removeRuleset deletes the gitea-mq ruleset, if the repo has one.
+func removeRuleset(ctx context.Context, c *gh.Client, owner, name string) error {
+	rs, _, err := mqRuleset(ctx, c, owner, name)
+	if err != nil {
+		return fmt.Errorf("list rulesets for %s/%s: %w", owner, name, err)
+	}
+	if rs == nil {
+		return nil
+	}
+	if _, err := c.Repositories.DeleteRuleset(ctx, owner, name, rs.GetID()); err != nil {
+		return fmt.Errorf("delete ruleset for %s/%s: %w", owner, name, err)
+	}
+	slog.Info("github: deleted ruleset", "repo", owner+"/"+name)
+	return nil
+}
+
+// GuardBranch adds branch to the gitea-mq ruleset unless it already
+// covers it, and repairs whatever else has drifted.
+func (f *githubForge) GuardBranch(ctx context.Context, owner, name, branch string) error {
+	if !f.src.capabilities(ctx).rulesets {
+		return nil
+	}
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return err
+	}
+	r, _, err := c.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("get repo %s/%s: %w", owner, name, err)
+	}
+	return f.ensureRuleset(ctx, c, owner, name, rulesetWant{
+		defaultBranch: r.GetDefaultBranch(),
+		branches:      []string{branch},
+	})
+}
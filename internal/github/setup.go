@@ -2,8 +2,11 @@ package github
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	gh "github.com/google/go-github/v84/github"
 
@@ -13,85 +16,143 @@ import (
 // GitHub's built-in repository role ID for "admin".
 const repoAdminRoleID int64 = 5
 
-func (f *githubForge) EnsureRepoSetup(ctx context.Context, owner, name string, _ forge.SetupConfig) error {
-	c, err := f.app.ClientForRepo(owner, name)
+// EnsureRepoSetup enables auto-merge, registers the webhook in token mode and
+// creates or repairs the gitea-mq ruleset on the default branch, the
+// configured patterns and the branches PRs are queued against.
+func (f *githubForge) EnsureRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
 		return err
 	}
 
+	caps := f.src.capabilities(ctx)
+
+	r, _, err := c.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("get repo %s/%s: %w", owner, name, err)
+	}
+	defaultBranch := r.GetDefaultBranch()
+
 	// Auto-merge is the user signal for "queue this PR"; without it the
 	// poller never enqueues anything.
-	if _, resp, err := c.Repositories.Edit(ctx, owner, name, &gh.Repository{
+	if !caps.autoMerge {
+		slog.Warn("github: enterprise server has no auto-merge, PRs cannot be queued",
+			"repo", owner+"/"+name, "version", caps.version)
+	} else if _, resp, err := c.Repositories.Edit(ctx, owner, name, &gh.Repository{
 		AllowAutoMerge: gh.Ptr(true),
 	}); err != nil {
 		if !isForbidden(resp) {
 			return err
 		}
-		slog.Warn("github: cannot enable allow_auto_merge (App lacks Administration permission)",
+		slog.Warn("github: cannot enable allow_auto_merge (no Administration permission)",
 			"repo", owner+"/"+name, "err", err)
 	}
 
-	rss, resp, err := c.Repositories.GetAllRulesets(ctx, owner, name, nil)
-	if err != nil {
-		if !isForbidden(resp) {
+	// An App's webhook is global and synced at startup; a token has to
+	// register one on every repo.
+	if f.appID == 0 && cfg.ExternalURL != "" {
+		url := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/github"
+		if err := ensureWebhook(ctx, c, owner, name, url, cfg.WebhookSecret); err != nil {
 			return err
 		}
-		slog.Warn("github: cannot manage rulesets (App lacks Administration permission)",
-			"repo", owner+"/"+name, "err", err)
-		return nil
 	}
-	for _, rs := range rss {
-		if rs.Name == forge.MQContext {
-			return nil
-		}
+
+	// Classic branch protection has no bypass for Apps, so requiring the
+	// gitea-mq check there would lock the queue out of its own target
+	// branch. The operator has to gate merges by hand on such servers.
+	if !caps.rulesets {
+		slog.Warn("github: enterprise server has no rulesets, not requiring the gitea-mq check",
+			"repo", owner+"/"+name, "version", caps.version)
+		return nil
 	}
 
-	_, resp, err = c.Repositories.CreateRuleset(ctx, owner, name, gh.RepositoryRuleset{
-		Name:        forge.MQContext,
-		Target:      gh.Ptr(gh.RulesetTargetBranch),
-		Enforcement: gh.RulesetEnforcementActive,
-		BypassActors: []*gh.BypassActor{
-			// The App must bypass its own gate to manage merge branches and
-			// to let GitHub fast-forward when it reports success.
-			{
-				ActorID:    gh.Ptr(f.app.AppID()),
-				ActorType:  gh.Ptr(gh.BypassActorTypeIntegration),
-				BypassMode: gh.Ptr(gh.BypassModeAlways),
-			},
-			// Repo admins keep an escape hatch for hotfixes.
-			{
-				ActorID:    gh.Ptr(repoAdminRoleID),
-				ActorType:  gh.Ptr(gh.BypassActorTypeRepositoryRole),
-				BypassMode: gh.Ptr(gh.BypassModeAlways),
-			},
-		},
-		Conditions: &gh.RepositoryRulesetConditions{
-			RefName: &gh.RepositoryRulesetRefConditionParameters{
-				// ~ALL would also gate every feature-branch push on a check
-				// that only ever reports for queued PRs.
-				Include: []string{"~DEFAULT_BRANCH"},
-				Exclude: []string{},
-			},
-		},
-		Rules: &gh.RepositoryRulesetRules{
-			RequiredStatusChecks: &gh.RequiredStatusChecksRuleParameters{
-				RequiredStatusChecks: []*gh.RuleStatusCheck{{
-					Context:       forge.MQContext,
-					IntegrationID: gh.Ptr(f.app.AppID()),
-				}},
-				StrictRequiredStatusChecksPolicy: false,
-				// Otherwise the rule also gates branch *creation* and only
-				// the bypass actor could push a new branch.
-				DoNotEnforceOnCreate: gh.Ptr(true),
-			},
-		},
+	return f.ensureRuleset(ctx, c, owner, name, rulesetWant{
+		defaultBranch: defaultBranch,
+		patterns:      cfg.BranchPatterns,
+		branches:      cfg.TargetBranches,
 	})
+}
+
+// TeardownRepoSetup deletes the gitea-mq ruleset and, in token mode, the
+// repo webhook. Auto-merge stays enabled: it is a repo setting people rely
+// on without gitea-mq too.
+func (f *githubForge) TeardownRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	c, err := f.src.ClientForRepo(owner, name)
 	if err != nil {
-		if !isForbidden(resp) {
+		return err
+	}
+
+	if f.src.capabilities(ctx).rulesets {
+		if err := removeRuleset(ctx, c, owner, name); err != nil {
 			return err
 		}
-		slog.Warn("github: cannot create ruleset (App lacks Administration permission)",
-			"repo", owner+"/"+name, "err", err)
+	}
+
+	if f.appID == 0 && cfg.ExternalURL != "" {
+		url := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/github"
+		return removeWebhook(ctx, c, owner, name, url)
+	}
+	return nil
+}
+
+// webhookEvents are the repo events routing acts on.
+var webhookEvents = []string{"pull_request", "check_run", "status"}
+
+// ensureWebhook creates the repo webhook pointing at url unless one exists.
+// An existing hook gets its secret and events refreshed, since a rotated
+// secret would otherwise silently reject every delivery.
+func ensureWebhook(ctx context.Context, c *gh.Client, owner, name, url, secret string) error {
+	repo := owner + "/" + name
+	hook := &gh.Hook{
+		Active: gh.Ptr(true),
+		Events: webhookEvents,
+		Config: &gh.HookConfig{
+			URL:         gh.Ptr(url),
+			ContentType: gh.Ptr("json"),
+			Secret:      gh.Ptr(secret),
+		},
+	}
+	for h, err := range c.Repositories.ListHooksIter(ctx, owner, name, &gh.ListOptions{PerPage: 100}) {
+		if err != nil {
+			var ghErr *gh.ErrorResponse
+			if errors.As(err, &ghErr) && isForbidden(&gh.Response{Response: ghErr.Response}) {
+				slog.Warn("github: cannot manage webhooks (no admin rights on repo)", "repo", repo, "err", err)
+				return nil
+			}
+			return fmt.Errorf("list webhooks for %s: %w", repo, err)
+		}
+		if h.GetConfig().GetURL() != url {
+			continue
+		}
+		if _, _, err := c.Repositories.EditHook(ctx, owner, name, h.GetID(), hook); err != nil {
+			return fmt.Errorf("update webhook for %s: %w", repo, err)
+		}
+		return nil
+	}
+	if _, _, err := c.Repositories.CreateHook(ctx, owner, name, hook); err != nil {
+		return fmt.Errorf("create webhook for %s: %w", repo, err)
+	}
+	slog.Info("created webhook", "repo", repo, "url", url)
+	return nil
+}
+
+// removeWebhook deletes the repo webhooks pointing at url.
+func removeWebhook(ctx context.Context, c *gh.Client, owner, name, url string) error {
+	repo := owner + "/" + name
+	var ids []int64
+	for h, err := range c.Repositories.ListHooksIter(ctx, owner, name, &gh.ListOptions{PerPage: 100}) {
+		if err != nil {
+			return fmt.Errorf("list webhooks for %s: %w", repo, err)
+		}
+		if h.GetConfig().GetURL() == url {
+			ids = append(ids, h.GetID())
+		}
+	}
+	for _, id := range ids {
+		if _, err := c.Repositories.DeleteHook(ctx, owner, name, id); err != nil {
+			return fmt.Errorf("delete webhook for %s: %w", repo, err)
+		}
+		slog.Info("deleted webhook", "repo", repo, "url", url)
 	}
 	return nil
 }
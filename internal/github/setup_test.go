@@ -3,9 +3,12 @@ package github_test
 import (
 	"context"
 	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/github/ghfake"
 )
 
 func TestForge_EnsureRepoSetup(t *testing.T) {
@@ -59,4 +62,130 @@ func TestForge_EnsureRepoSetup(t *testing.T) {
 	if len(repo.Rulesets) != 1 {
 		t.Errorf("idempotency: got %d rulesets", len(repo.Rulesets))
 	}
+	if repo.Rulesets[0].Updates != 0 {
+		t.Errorf("second run updated the ruleset %d times, want 0", repo.Rulesets[0].Updates)
+	}
+}
+
+func rulesetInclude(t *testing.T, rs *ghfake.Ruleset) (include, exclude []string) {
+	t.Helper()
+	var conds struct {
+		RefName struct{ Include, Exclude []string } `json:"ref_name"`
+	}
+	if err := json.Unmarshal(rs.Conditions, &conds); err != nil {
+		t.Fatalf("decode conditions: %v", err)
+	}
+	return conds.RefName.Include, conds.RefName.Exclude
+}
+
+func TestForge_EnsureRepoSetup_TargetBranches(t *testing.T) {
+	srv, f := newTestForge(t)
+	err := f.EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{
+		BranchPatterns: []string{"release/*"},
+		// main is the default branch and release/1.0 matches the pattern.
+		TargetBranches: []string{"main", "release/1.0", "staging"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	include, _ := rulesetInclude(t, srv.Repo("org", "app").Rulesets[0])
+	want := []string{"~DEFAULT_BRANCH", "refs/heads/release/*", "refs/heads/staging"}
+	if !slices.Equal(include, want) {
+		t.Errorf("include = %v, want %v", include, want)
+	}
+}
+
+func TestForge_EnsureRepoSetup_RepairsDrift(t *testing.T) {
+	srv, f := newTestForge(t)
+	repo := srv.Repo("org", "app")
+	repo.Rulesets = []*ghfake.Ruleset{{
+		ID: 7, Name: forge.MQContext, Target: "branch", Enforcement: "disabled",
+		BypassActors: []ghfake.BypassActor{{ActorID: 5, ActorType: "RepositoryRole", BypassMode: "always"}},
+		Conditions:   json.RawMessage(`{"ref_name":{"include":["~DEFAULT_BRANCH","refs/heads/hotfix"],"exclude":["refs/heads/main"]}}`),
+		Rules: []ghfake.RulesetRule{{
+			Type:       "required_status_checks",
+			Parameters: json.RawMessage(`{"required_status_checks":[{"context":"ci/build"}],"strict_required_status_checks_policy":false}`),
+		}},
+	}}
+
+	if err := f.EnsureRepoSetup(context.Background(), "org", "app", forge.SetupConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	rs := repo.Rulesets[0]
+	if rs.Updates != 1 || rs.Enforcement != "active" {
+		t.Fatalf("ruleset = %+v, want one update to active", rs)
+	}
+	include, exclude := rulesetInclude(t, rs)
+	if !slices.Equal(include, []string{"~DEFAULT_BRANCH", "refs/heads/hotfix"}) || len(exclude) != 0 {
+		t.Errorf("include = %v, exclude = %v; the operator's entries stay, the exclusion of main goes", include, exclude)
+	}
+	var params struct {
+		RequiredStatusChecks []struct {
+			Context       string `json:"context"`
+			IntegrationID int64  `json:"integration_id"`
+		} `json:"required_status_checks"`
+	}
+	if err := json.Unmarshal(rs.Rules[0].Parameters, &params); err != nil {
+		t.Fatal(err)
+	}
+	if len(params.RequiredStatusChecks) != 2 || params.RequiredStatusChecks[1].Context != forge.MQContext ||
+		params.RequiredStatusChecks[1].IntegrationID != 1 {
+		t.Errorf("required checks = %+v, want ci/build plus the App's %s", params.RequiredStatusChecks, forge.MQContext)
+	}
+	if !slices.ContainsFunc(rs.BypassActors, func(a ghfake.BypassActor) bool {
+		return a.ActorType == "Integration" && a.ActorID == 1 && a.BypassMode == "always"
+	}) {
+		t.Errorf("bypass actors = %+v, want the App", rs.BypassActors)
+	}
+
+	// Repaired is in line: the doctor has nothing to report.
+	checks := f.(forge.Doctor).Diagnose(context.Background(), "org", "app", forge.SetupConfig{})
+	for _, c := range checks {
+		if c.Name == "ruleset" && c.Status != forge.DoctorPass {
+			t.Errorf("ruleset check after repair = %+v", c)
+		}
+	}
+}
+
+func TestForge_Diagnose_RulesetDrift(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	if err := f.EnsureRepoSetup(ctx, "org", "app", forge.SetupConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	srv.Repo("org", "app").Rulesets[0].Enforcement = "evaluate"
+
+	checks := f.(forge.Doctor).Diagnose(ctx, "org", "app", forge.SetupConfig{TargetBranches: []string{"staging"}})
+	i := slices.IndexFunc(checks, func(c forge.DoctorCheck) bool { return c.Name == "ruleset" })
+	if i < 0 {
+		t.Fatalf("no ruleset check in %+v", checks)
+	}
+	c := checks[i]
+	if c.Status != forge.DoctorWarn || !strings.Contains(c.Detail, "evaluate") || !strings.Contains(c.Detail, "refs/heads/staging") {
+		t.Errorf("ruleset check = %+v, want a warning naming the enforcement and staging", c)
+	}
+}
+
+func TestForge_GuardBranch(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	if err := f.EnsureRepoSetup(ctx, "org", "app", forge.SetupConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	g := f.(forge.BranchGuard)
+	rs := srv.Repo("org", "app").Rulesets[0]
+
+	if err := g.GuardBranch(ctx, "org", "app", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if rs.Updates != 0 {
+		t.Errorf("guarding the default branch updated the ruleset")
+	}
+	if err := g.GuardBranch(ctx, "org", "app", "staging"); err != nil {
+		t.Fatal(err)
+	}
+	include, _ := rulesetInclude(t, srv.Repo("org", "app").Rulesets[0])
+	if !slices.Equal(include, []string{"~DEFAULT_BRANCH", "refs/heads/staging"}) {
+		t.Errorf("include = %v, want staging added", include)
+	}
 }
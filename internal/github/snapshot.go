@@ -0,0 +1,221 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+var _ forge.BulkReconciler = (*githubForge)(nil)
+
+// openPRsQuery pages through the open PRs with their auto-merge request,
+// the checks of their head commit and the rules of their base branch.
+// Ref.rules is evaluated like GET /rules/branches/{branch}.
+const openPRsQuery = `query($owner:String!,$name:String!,$cursor:String){
+  repository(owner:$owner,name:$name){
+    pullRequests(states:OPEN,first:50,after:$cursor){
+      pageInfo{hasNextPage endCursor}
+      nodes{
+        number title url headRefName headRefOid baseRefName
+        author{login}
+        autoMergeRequest{enabledAt}
+        commits(last:1){nodes{commit{statusCheckRollup{contexts(first:100){
+          pageInfo{hasNextPage}
+          nodes{
+            __typename
+            ... on CheckRun{name status conclusion detailsUrl summary}
+            ... on StatusContext{context state description targetUrl}
+          }
+        }}}}}
+        baseRef{rules(first:100){nodes{type parameters{
+          ... on RequiredStatusChecksParameters{requiredStatusChecks{context}}
+        }}}}
+      }
+    }
+  }
+}`
+
+type snapshotPage struct {
+	Repository struct {
+		PullRequests struct {
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   string
+			}
+			Nodes []snapshotPR
+		}
+	}
+}
+
+type snapshotPR struct {
+	Number           int64
+	Title            string
+	URL              string
+	HeadRefName      string
+	HeadRefOid       string
+	BaseRefName      string
+	Author           struct{ Login string }
+	AutoMergeRequest *struct{ EnabledAt string }
+	Commits          struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					Contexts struct {
+						PageInfo struct{ HasNextPage bool }
+						Nodes    []rollupContext
+					}
+				}
+			}
+		}
+	}
+	BaseRef *struct {
+		Rules struct {
+			Nodes []struct {
+				Type       string
+				Parameters *struct {
+					RequiredStatusChecks []struct{ Context string }
+				}
+			}
+		}
+	}
+}
+
+// rollupContext is a CheckRun or a StatusContext of a status check rollup.
+type rollupContext struct {
+	Typename string `json:"__typename"`
+	// CheckRun
+	Name       string
+	Status     string
+	Conclusion string
+	DetailsURL string
+	Summary    string
+	// StatusContext
+	Context     string
+	State       string
+	Description string
+	TargetURL   string
+}
+
+// OpenPRSnapshot fetches the open PRs through GraphQL, 50 per request. A
+// head commit with more checks than one page holds has them fetched through
+// REST. Servers without rulesets are not supported: their base branch rules
+// cannot be queried.
+func (f *githubForge) OpenPRSnapshot(ctx context.Context, owner, name string) (*forge.Snapshot, error) {
+	if !f.src.capabilities(ctx).rulesets {
+		return nil, fmt.Errorf("github: no branch rules on this server: %w", errors.ErrUnsupported)
+	}
+	c, err := f.src.ClientForRepo(owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &forge.Snapshot{
+		Checks:         map[string]map[string]forge.Check{},
+		RequiredChecks: map[string][]string{},
+	}
+	vars := map[string]any{"owner": owner, "name": name, "cursor": nil}
+	for {
+		var page snapshotPage
+		msg, err := f.graphql(ctx, c, openPRsQuery, vars, &page)
+		if err != nil {
+			return nil, err
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("list open PRs: %s", msg)
+		}
+		prs := page.Repository.PullRequests
+		for i := range prs.Nodes {
+			if err := f.addToSnapshot(ctx, snap, owner, name, &prs.Nodes[i]); err != nil {
+				return nil, err
+			}
+		}
+		if !prs.PageInfo.HasNextPage {
+			return snap, nil
+		}
+		vars["cursor"] = prs.PageInfo.EndCursor
+	}
+}
+
+func (f *githubForge) addToSnapshot(ctx context.Context, snap *forge.Snapshot, owner, name string, p *snapshotPR) error {
+	snap.PRs = append(snap.PRs, forge.PR{
+		Number:           p.Number,
+		Title:            p.Title,
+		State:            "open",
+		AuthorLogin:      p.Author.Login,
+		HeadBranch:       p.HeadRefName,
+		HeadSHA:          p.HeadRefOid,
+		BaseBranch:       p.BaseRefName,
+		HTMLURL:          p.URL,
+		AutoMergeEnabled: p.AutoMergeRequest != nil,
+	})
+
+	if _, ok := snap.RequiredChecks[p.BaseRefName]; !ok {
+		var required []string
+		if p.BaseRef != nil {
+			for _, r := range p.BaseRef.Rules.Nodes {
+				if r.Type != "REQUIRED_STATUS_CHECKS" || r.Parameters == nil {
+					continue
+				}
+				for _, sc := range r.Parameters.RequiredStatusChecks {
+					if !forge.IsOwnContext(sc.Context) {
+						required = append(required, sc.Context)
+					}
+				}
+			}
+		}
+		snap.RequiredChecks[p.BaseRefName] = required
+	}
+
+	if _, ok := snap.Checks[p.HeadRefOid]; ok {
+		return nil
+	}
+	checks := map[string]forge.Check{}
+	if len(p.Commits.Nodes) > 0 {
+		if rollup := p.Commits.Nodes[0].Commit.StatusCheckRollup; rollup != nil {
+			if rollup.Contexts.PageInfo.HasNextPage {
+				var err error
+				if checks, err = f.GetCheckStates(ctx, owner, name, p.HeadRefOid); err != nil {
+					return err
+				}
+			} else {
+				checks = rollupChecks(rollup.Contexts.Nodes)
+			}
+		}
+	}
+	snap.Checks[p.HeadRefOid] = checks
+	return nil
+}
+
+// rollupChecks maps rollup contexts like GetCheckStates maps check runs and
+// statuses: the gitea-mq run is left out, and a check run wins over a
+// commit status of the same name.
+func rollupChecks(nodes []rollupContext) map[string]forge.Check {
+	out := map[string]forge.Check{}
+	for _, n := range nodes {
+		if n.Typename != "CheckRun" || n.Name == forge.MQContext {
+			continue
+		}
+		out[n.Name] = forge.Check{
+			State:       CheckRunToState(strings.ToLower(n.Status), strings.ToLower(n.Conclusion)),
+			Description: n.Summary,
+			TargetURL:   n.DetailsURL,
+		}
+	}
+	for _, n := range nodes {
+		if n.Typename != "StatusContext" || n.Context == forge.MQContext {
+			continue
+		}
+		if _, ok := out[n.Context]; ok {
+			continue
+		}
+		out[n.Context] = forge.Check{
+			State:       forge.ParseCheckState(strings.ToLower(n.State)),
+			Description: n.Description,
+			TargetURL:   n.TargetURL,
+		}
+	}
+	return out
+}
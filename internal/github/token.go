@@ -0,0 +1,35 @@
+package github
+
+import (
+	"net/http"
+
+	gh "github.com/google/go-github/v84/github"
+
+	"github.com/Mic92/gitea-mq/internal/httpcache"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
+)
+
+// TokenClient acts as a single GitHub user, a bot account or a fine-grained
+// personal access token, for orgs that cannot register a GitHub App. Every
+// repo is reached with the same client; what the token may see is what
+// gitea-mq may manage.
+type TokenClient struct {
+	*deployment
+	client *gh.Client
+}
+
+// NewTokenClient builds a client authenticated with token against the
+// deployment at ep (github.com when zero).
+func NewTokenClient(token string, ep Endpoints) (*TokenClient, error) {
+	ep = ep.WithDefaults()
+	// Same ETag revalidation as the App clients: 304s are free.
+	hc := &http.Client{Transport: ratelimit.NewTransport(httpcache.New("github token", nil, httpcache.DefaultMaxBytes), ratelimit.NewBudget("github token"))}
+	c, err := newClient(hc, ep)
+	if err != nil {
+		return nil, err
+	}
+	c = c.WithAuthToken(token)
+	return &TokenClient{deployment: &deployment{ep: ep, meta: c}, client: c}, nil
+}
+
+func (t *TokenClient) ClientForRepo(_, _ string) (*gh.Client, error) { return t.client, nil }
@@ -0,0 +1,163 @@
+package github_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	githubpkg "github.com/Mic92/gitea-mq/internal/github"
+	"github.com/Mic92/gitea-mq/internal/github/ghfake"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+func newTokenForge(t *testing.T) (*ghfake.Server, *githubpkg.TokenClient, forge.Forge) {
+	t.Helper()
+	srv := ghfake.New()
+	t.Cleanup(srv.Close)
+	srv.AddRepo("org", "app")
+	tc, err := githubpkg.NewTokenClient("ghp_test", githubpkg.Endpoints{Web: srv.WebURL()})
+	if err != nil {
+		t.Fatalf("NewTokenClient: %v", err)
+	}
+	return srv, tc, githubpkg.NewTokenForge(tc)
+}
+
+// Only Apps may create check runs, so a token posts commit statuses and
+// reads them back like any third-party CI.
+func TestTokenForge_PostsCommitStatuses(t *testing.T) {
+	srv, _, f := newTokenForge(t)
+	ctx := context.Background()
+
+	if err := f.SetMQStatus(ctx, "org", "app", "abc", forge.MQStatus{
+		State: pg.CheckStatePending, Description: strings.Repeat("x", 200),
+	}); err != nil {
+		t.Fatalf("SetMQStatus: %v", err)
+	}
+	if err := f.MirrorCheck(ctx, "org", "app", "abc", "gitea-mq/ci", forge.Check{
+		State: pg.CheckStateFailure, Description: "build broke", TargetURL: "https://ci/1",
+	}); err != nil {
+		t.Fatalf("MirrorCheck: %v", err)
+	}
+	// The stale-mirror sentinel has no commit-status equivalent.
+	if err := f.MirrorCheck(ctx, "org", "app", "abc", "gitea-mq/ci", forge.Check{
+		State: forge.CheckState("skipped"),
+	}); err != nil {
+		t.Fatalf("MirrorCheck skipped: %v", err)
+	}
+
+	repo := srv.Repo("org", "app")
+	if len(repo.CheckRuns["abc"]) != 0 {
+		t.Errorf("check runs = %+v, want none in token mode", repo.CheckRuns["abc"])
+	}
+	sts := repo.Statuses["abc"]
+	if len(sts) != 3 || sts[0].Context != forge.MQContext || sts[0].State != "pending" {
+		t.Fatalf("statuses = %+v", sts)
+	}
+	if n := len([]rune(sts[0].Description)); n != 140 {
+		t.Errorf("description length = %d, want truncated to 140", n)
+	}
+
+	checks, err := f.GetCheckStates(ctx, "org", "app", "abc")
+	if err != nil {
+		t.Fatalf("GetCheckStates: %v", err)
+	}
+	if got := checks["gitea-mq/ci"]; got.State != pg.CheckStateSuccess {
+		t.Errorf("mirror = %+v, want newest (skipped → success) to win", got)
+	}
+	if _, ok := checks[forge.MQContext]; ok {
+		t.Error("own status must not be reported as a check")
+	}
+}
+
+func TestTokenForge_EnsureRepoSetup(t *testing.T) {
+	srv, _, f := newTokenForge(t)
+	ctx := context.Background()
+	cfg := forge.SetupConfig{ExternalURL: "https://mq.example.com/", WebhookSecret: "s1"}
+
+	if err := f.EnsureRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	repo := srv.Repo("org", "app")
+	if len(repo.Hooks) != 1 {
+		t.Fatalf("hooks = %+v", repo.Hooks)
+	}
+	h := repo.Hooks[0]
+	if h.URL != "https://mq.example.com/webhook/github" || h.Secret != "s1" {
+		t.Errorf("hook = %+v", h)
+	}
+	for _, ev := range []string{"pull_request", "check_run", "status"} {
+		if !slices.Contains(h.Events, ev) {
+			t.Errorf("hook events %v lack %q", h.Events, ev)
+		}
+	}
+
+	// A token user is no integration: the ruleset must neither pin the
+	// check to an App nor name one as bypass actor.
+	if len(repo.Rulesets) != 1 {
+		t.Fatalf("rulesets = %+v", repo.Rulesets)
+	}
+	if strings.Contains(string(repo.Rulesets[0].Rules[0].Parameters), "integration_id") {
+		t.Errorf("rule parameters pin an integration: %s", repo.Rulesets[0].Rules[0].Parameters)
+	}
+
+	// A rotated secret is pushed to the existing hook instead of adding one.
+	cfg.WebhookSecret = "s2"
+	if err := f.EnsureRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if len(repo.Hooks) != 1 || repo.Hooks[0].Secret != "s2" {
+		t.Errorf("hooks after rotation = %+v", repo.Hooks)
+	}
+	if len(repo.Rulesets) != 1 {
+		t.Errorf("idempotency: got %d rulesets", len(repo.Rulesets))
+	}
+}
+
+func TestTokenForge_TeardownRepoSetup(t *testing.T) {
+	srv, _, f := newTokenForge(t)
+	ctx := context.Background()
+	cfg := forge.SetupConfig{ExternalURL: "https://mq.example.com/", WebhookSecret: "s1"}
+	repo := srv.Repo("org", "app")
+	repo.Hooks = append(repo.Hooks, &ghfake.Hook{ID: 999, URL: "https://ci.example.com/hook"})
+
+	if err := f.EnsureRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TeardownRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Rulesets) != 0 {
+		t.Errorf("rulesets after teardown = %+v", repo.Rulesets)
+	}
+	if len(repo.Hooks) != 1 || repo.Hooks[0].ID != 999 {
+		t.Errorf("hooks after teardown = %+v, want only the foreign one", repo.Hooks)
+	}
+	if repo.Settings["allow_auto_merge"] != true {
+		t.Error("teardown must leave allow_auto_merge alone")
+	}
+
+	// Nothing left to undo.
+	if err := f.TeardownRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatalf("second teardown: %v", err)
+	}
+}
+
+func TestTopicSource(t *testing.T) {
+	srv, tc, _ := newTokenForge(t)
+	srv.Repo("org", "app").Topics = []string{"merge-queue"}
+	srv.AddRepo("org", "other").Topics = []string{"unrelated"}
+	ro := srv.AddRepo("org", "readonly")
+	ro.Topics = []string{"merge-queue"}
+	ro.Admin = false
+
+	got, err := githubpkg.TopicSource(tc, "merge-queue")(context.Background())
+	if err != nil {
+		t.Fatalf("TopicSource: %v", err)
+	}
+	want := []forge.RepoRef{{Forge: forge.KindGithub, Owner: "org", Name: "app"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("repos = %v, want %v", got, want)
+	}
+}
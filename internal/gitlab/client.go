@@ -0,0 +1,459 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/gitea"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
+)
+
+// Client talks to GitLab's REST API (v4). Merges and pushes go through the
+// git cache shared with the Gitea client, as GitLab has no API to merge two
+// arbitrary refs either.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	git        *gitea.GitRemote
+}
+
+// NewClient creates a client for the instance at baseURL (no trailing
+// /api/v4) authenticating with a personal, group or project access token.
+func NewClient(baseURL, token string) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Transport: ratelimit.NewTransport(nil, ratelimit.NewBudget(strings.TrimRight(baseURL, "/")))},
+	}
+	// GitLab's git endpoints only take basic auth; any user name works
+	// with an access token as password.
+	var auth string
+	if token != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte("oauth2:"+token))
+	}
+	c.git = gitea.NewGitRemote(c.baseURL, auth, token)
+	return c
+}
+
+// SetGitCacheDir points the persistent git cache at dir.
+func (c *Client) SetGitCacheDir(dir string) {
+	c.git.SetCacheDir(dir)
+}
+
+// CleanupGitCache removes cached repositories not used within maxAge.
+func (c *Client) CleanupGitCache(maxAge time.Duration) {
+	c.git.CleanupCache(maxAge)
+}
+
+// APIError represents a non-2xx response from the GitLab API.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitlab API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// IsNotFound returns true if the error is a 404 response.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// project returns the URL-encoded project path GitLab accepts in place of
+// the numeric project ID.
+func project(owner, name string) string {
+	return url.PathEscape(owner + "/" + name)
+}
+
+// do sends a request to path (below /api/v4) and decodes a 2xx JSON
+// response into v, which may be nil. It returns the response headers so
+// callers can paginate.
+func (c *Client) do(ctx context.Context, method, path string, body, v any) (http.Header, error) {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v4"+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request %s %s: %w", method, path, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	if v == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return resp.Header, nil
+}
+
+// paginate follows GitLab's X-Next-Page header.
+func paginate[T any](ctx context.Context, c *Client, path, errLabel string) ([]T, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	var all []T
+	for page := "1"; page != ""; {
+		var items []T
+		h, err := c.do(ctx, http.MethodGet, path+sep+"per_page=100&page="+page, nil, &items)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", errLabel, err)
+		}
+		all = append(all, items...)
+		page = h.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// MergeRequest is the subset of GitLab's merge request we use.
+type MergeRequest struct {
+	IID          int64  `json:"iid"`
+	Title        string `json:"title"`
+	State        string `json:"state"` // opened, closed, merged, locked
+	SHA          string `json:"sha"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	WebURL       string `json:"web_url"`
+	Author       struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	// MergeWhenPipelineSucceeds is set while the MR is scheduled to merge
+	// once its pipeline passes ("auto-merge" in newer GitLab releases).
+	MergeWhenPipelineSucceeds bool `json:"merge_when_pipeline_succeeds"`
+}
+
+// CommitStatus is a commit status, either an external one or a CI job.
+type CommitStatus struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"` // pending, running, success, failed, canceled, ...
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
+
+// Pipeline is a CI pipeline run.
+type Pipeline struct {
+	ID     int64  `json:"id"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"`
+	WebURL string `json:"web_url"`
+}
+
+// Project is the subset of a GitLab project we use.
+type Project struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	Visibility        string `json:"visibility"` // public, internal, private
+	// OnlyAllowMergeIfPipelineSucceeds is GitLab's "Pipelines must
+	// succeed" setting, its analogue of a required status check.
+	OnlyAllowMergeIfPipelineSucceeds bool   `json:"only_allow_merge_if_pipeline_succeeds"`
+	DefaultBranch                    string `json:"default_branch"`
+	MergeRequestsAccessLevel         string `json:"merge_requests_access_level"` // enabled, private, disabled
+	Permissions                      struct {
+		ProjectAccess *Access `json:"project_access"`
+		GroupAccess   *Access `json:"group_access"`
+	} `json:"permissions"`
+}
+
+// Access is a membership's role: 30 Developer, 40 Maintainer, 50 Owner.
+type Access struct {
+	AccessLevel int `json:"access_level"`
+}
+
+// AccessLevel returns the token user's effective role on the project,
+// either direct or inherited from the group.
+func (p *Project) AccessLevel() int {
+	level := 0
+	for _, a := range []*Access{p.Permissions.ProjectAccess, p.Permissions.GroupAccess} {
+		if a != nil && a.AccessLevel > level {
+			level = a.AccessLevel
+		}
+	}
+	return level
+}
+
+// Role levels used in access checks.
+const (
+	DeveloperAccess  = 30
+	MaintainerAccess = 40
+)
+
+// ProtectedBranch lists who may push to a protected branch. An entry
+// grants a role (AccessLevel, 0 meaning nobody) or one user.
+type ProtectedBranch struct {
+	Name             string `json:"name"`
+	PushAccessLevels []struct {
+		AccessLevel int   `json:"access_level"`
+		UserID      int64 `json:"user_id"`
+	} `json:"push_access_levels"`
+}
+
+// User is the subset of a GitLab user we use.
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// Hook is a project webhook.
+type Hook struct {
+	ID                    int64  `json:"id"`
+	URL                   string `json:"url"`
+	Token                 string `json:"token,omitempty"`
+	PipelineEvents        bool   `json:"pipeline_events"`
+	MergeRequestsEvents   bool   `json:"merge_requests_events"`
+	PushEvents            bool   `json:"push_events"`
+	EnableSSLVerification bool   `json:"enable_ssl_verification"`
+}
+
+func (c *Client) ListOpenMRs(ctx context.Context, owner, name string) ([]MergeRequest, error) {
+	return paginate[MergeRequest](ctx, c,
+		"/projects/"+project(owner, name)+"/merge_requests?state=opened",
+		fmt.Sprintf("list open merge requests for %s/%s", owner, name))
+}
+
+func (c *Client) GetMR(ctx context.Context, owner, name string, iid int64) (*MergeRequest, error) {
+	var mr MergeRequest
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d", project(owner, name), iid)
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &mr); err != nil {
+		return nil, fmt.Errorf("get merge request !%d in %s/%s: %w", iid, owner, name, err)
+	}
+	return &mr, nil
+}
+
+// SetCommitStatus posts an external commit status. GitLab rejects posting
+// the state a status already has ("Cannot transition status"); that is
+// treated as success so callers can re-post idempotently.
+func (c *Client) SetCommitStatus(ctx context.Context, owner, name, sha string, st CommitStatus) error {
+	body := map[string]string{
+		"name":        st.Name,
+		"state":       st.Status,
+		"description": st.Description,
+		"target_url":  st.TargetURL,
+	}
+	path := fmt.Sprintf("/projects/%s/statuses/%s", project(owner, name), sha)
+	_, err := c.do(ctx, http.MethodPost, path, body, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(apiErr.Body, "Cannot transition status") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("set status %s on %s in %s/%s: %w", st.Name, shortSHA(sha), owner, name, err)
+	}
+	return nil
+}
+
+// ListCommitStatuses returns the latest status per name on sha, CI jobs
+// included.
+func (c *Client) ListCommitStatuses(ctx context.Context, owner, name, sha string) ([]CommitStatus, error) {
+	return paginate[CommitStatus](ctx, c,
+		fmt.Sprintf("/projects/%s/repository/commits/%s/statuses?all=false", project(owner, name), sha),
+		fmt.Sprintf("list statuses of %s in %s/%s", shortSHA(sha), owner, name))
+}
+
+// LatestPipeline returns the newest pipeline for sha, or nil if none ran.
+func (c *Client) LatestPipeline(ctx context.Context, owner, name, sha string) (*Pipeline, error) {
+	var ps []Pipeline
+	path := fmt.Sprintf("/projects/%s/pipelines?sha=%s&order_by=id&sort=desc&per_page=1", project(owner, name), sha)
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &ps); err != nil {
+		return nil, fmt.Errorf("list pipelines of %s in %s/%s: %w", shortSHA(sha), owner, name, err)
+	}
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	return &ps[0], nil
+}
+
+// CancelMergeWhenPipelineSucceeds unschedules an MR's auto-merge. GitLab
+// answers 406 when none is scheduled, which counts as done.
+func (c *Client) CancelMergeWhenPipelineSucceeds(ctx context.Context, owner, name string, iid int64) error {
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/cancel_merge_when_pipeline_succeeds", project(owner, name), iid)
+	_, err := c.do(ctx, http.MethodPost, path, nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotAcceptable {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cancel auto-merge of !%d in %s/%s: %w", iid, owner, name, err)
+	}
+	return nil
+}
+
+func (c *Client) CreateNote(ctx context.Context, owner, name string, iid int64, body string) error {
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/notes", project(owner, name), iid)
+	if _, err := c.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("comment on !%d in %s/%s: %w", iid, owner, name, err)
+	}
+	return nil
+}
+
+func (c *Client) CloseMR(ctx context.Context, owner, name string, iid int64) error {
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d", project(owner, name), iid)
+	if _, err := c.do(ctx, http.MethodPut, path, map[string]string{"state_event": "close"}, nil); err != nil {
+		return fmt.Errorf("close !%d in %s/%s: %w", iid, owner, name, err)
+	}
+	return nil
+}
+
+func (c *Client) ListBranches(ctx context.Context, owner, name string) ([]string, error) {
+	bs, err := paginate[struct {
+		Name string `json:"name"`
+	}](ctx, c, "/projects/"+project(owner, name)+"/repository/branches", fmt.Sprintf("list branches for %s/%s", owner, name))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(bs))
+	for i, b := range bs {
+		out[i] = b.Name
+	}
+	return out, nil
+}
+
+// DeleteBranch deletes a branch; an already deleted branch is not an error.
+func (c *Client) DeleteBranch(ctx context.Context, owner, name, branch string) error {
+	path := fmt.Sprintf("/projects/%s/repository/branches/%s", project(owner, name), url.PathEscape(branch))
+	if _, err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("delete branch %s in %s/%s: %w", branch, owner, name, err)
+	}
+	return nil
+}
+
+// CommitsBehind counts the commits on to that from lacks.
+func (c *Client) CommitsBehind(ctx context.Context, owner, name, from, to string) (int, error) {
+	var cmp struct {
+		Commits []json.RawMessage `json:"commits"`
+	}
+	path := fmt.Sprintf("/projects/%s/repository/compare?from=%s&to=%s",
+		project(owner, name), url.QueryEscape(from), url.QueryEscape(to))
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &cmp); err != nil {
+		return 0, fmt.Errorf("compare %s...%s in %s/%s: %w", shortSHA(from), shortSHA(to), owner, name, err)
+	}
+	return len(cmp.Commits), nil
+}
+
+func (c *Client) GetProject(ctx context.Context, owner, name string) (*Project, error) {
+	var p Project
+	if _, err := c.do(ctx, http.MethodGet, "/projects/"+project(owner, name), nil, &p); err != nil {
+		return nil, fmt.Errorf("get project %s/%s: %w", owner, name, err)
+	}
+	return &p, nil
+}
+
+// GetProtectedBranch returns the protection of branch; an unprotected
+// branch answers 404 (see IsNotFound).
+func (c *Client) GetProtectedBranch(ctx context.Context, owner, name, branch string) (*ProtectedBranch, error) {
+	var pb ProtectedBranch
+	path := fmt.Sprintf("/projects/%s/protected_branches/%s", project(owner, name), url.PathEscape(branch))
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &pb); err != nil {
+		return nil, fmt.Errorf("get protected branch %s of %s/%s: %w", branch, owner, name, err)
+	}
+	return &pb, nil
+}
+
+// CurrentUser returns the user the token belongs to.
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var u User
+	if _, err := c.do(ctx, http.MethodGet, "/user", nil, &u); err != nil {
+		return nil, fmt.Errorf("get current user: %w", err)
+	}
+	return &u, nil
+}
+
+// ProjectsByTopic lists projects carrying topic on which the token is at
+// least Maintainer, the role needed to manage webhooks.
+func (c *Client) ProjectsByTopic(ctx context.Context, topic string) ([]Project, error) {
+	return paginate[Project](ctx, c,
+		"/projects?min_access_level=40&topic="+url.QueryEscape(topic),
+		"list projects with topic "+topic)
+}
+
+// UserPublicEmail returns the public e-mail of the user with the given
+// username, or "" if they have none.
+func (c *Client) UserPublicEmail(ctx context.Context, username string) (string, error) {
+	var us []struct {
+		PublicEmail string `json:"public_email"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/users?username="+url.QueryEscape(username), nil, &us); err != nil {
+		return "", fmt.Errorf("look up user %s: %w", username, err)
+	}
+	if len(us) == 0 {
+		return "", nil
+	}
+	return us[0].PublicEmail, nil
+}
+
+func (c *Client) ListHooks(ctx context.Context, owner, name string) ([]Hook, error) {
+	return paginate[Hook](ctx, c, "/projects/"+project(owner, name)+"/hooks",
+		fmt.Sprintf("list hooks for %s/%s", owner, name))
+}
+
+func (c *Client) AddHook(ctx context.Context, owner, name string, h Hook) error {
+	if _, err := c.do(ctx, http.MethodPost, "/projects/"+project(owner, name)+"/hooks", h, nil); err != nil {
+		return fmt.Errorf("add hook to %s/%s: %w", owner, name, err)
+	}
+	return nil
+}
+
+// EditHook replaces a hook's settings. The token is write-only in GitLab,
+// so it is always sent.
+func (c *Client) EditHook(ctx context.Context, owner, name string, h Hook) error {
+	path := fmt.Sprintf("/projects/%s/hooks/%d", project(owner, name), h.ID)
+	if _, err := c.do(ctx, http.MethodPut, path, h, nil); err != nil {
+		return fmt.Errorf("edit hook %d of %s/%s: %w", h.ID, owner, name, err)
+	}
+	return nil
+}
+
+func (c *Client) DeleteHook(ctx context.Context, owner, name string, id int64) error {
+	path := fmt.Sprintf("/projects/%s/hooks/%d", project(owner, name), id)
+	if _, err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+		return fmt.Errorf("delete hook %d of %s/%s: %w", id, owner, name, err)
+	}
+	return nil
+}
+
+func shortSHA(s string) string {
+	if len(s) > 8 {
+		return s[:8]
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+package gitlab
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+// TopicSource lists projects carrying the given topic on which the token is
+// at least Maintainer. Projects in subgroups are skipped: repo refs and
+// dashboard URLs hold a single owner segment.
+func TopicSource(c *Client, topic string) func(context.Context) ([]forge.RepoRef, error) {
+	return func(ctx context.Context) ([]forge.RepoRef, error) {
+		ps, err := c.ProjectsByTopic(ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+		var out []forge.RepoRef
+		for _, p := range ps {
+			owner, name, ok := strings.Cut(p.PathWithNamespace, "/")
+			if !ok || strings.Contains(name, "/") {
+				slog.Debug("discovery: skipping project in a subgroup", "project", p.PathWithNamespace)
+				continue
+			}
+			out = append(out, forge.RepoRef{Forge: forge.KindGitlab, Owner: owner, Name: name})
+		}
+		return out, nil
+	}
+}
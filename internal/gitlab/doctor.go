@@ -0,0 +1,137 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+var _ forge.Doctor = (*gitlabForge)(nil)
+
+// Diagnose checks the project against what gitea-mq needs, on its default
+// branch.
+func (f *gitlabForge) Diagnose(ctx context.Context, owner, name string, cfg forge.SetupConfig) []forge.DoctorCheck {
+	p, err := f.client.GetProject(ctx, owner, name)
+	if err != nil {
+		return []forge.DoctorCheck{forge.DoctorFailed(forge.DoctorPermissions,
+			"cannot read the project: "+err.Error(),
+			"make the token user a Maintainer of the project")}
+	}
+	return []forge.DoctorCheck{
+		permissionsCheck(p),
+		requiredCheck(p),
+		f.webhookCheck(ctx, owner, name, cfg),
+		f.pushCheck(ctx, owner, name, p),
+		autoMergeCheck(p),
+	}
+}
+
+func permissionsCheck(p *Project) forge.DoctorCheck {
+	switch level := p.AccessLevel(); {
+	case level < DeveloperAccess:
+		return forge.DoctorFailed(forge.DoctorPermissions,
+			"the token user cannot push to the project",
+			"make the token user a Maintainer of the project")
+	case level < MaintainerAccess:
+		return forge.DoctorWarning(forge.DoctorPermissions,
+			"the token user is a Developer, so gitea-mq cannot manage the webhook",
+			"make the token user a Maintainer, or set up the webhook by hand")
+	}
+	return forge.DoctorPassed(forge.DoctorPermissions, "the token user is a Maintainer")
+}
+
+// requiredCheck looks at "Pipelines must succeed": GitLab counts the
+// gitea-mq commit status as part of the pipeline, so this setting is what
+// keeps an MR from merging before the queue reports success.
+func requiredCheck(p *Project) forge.DoctorCheck {
+	if !p.OnlyAllowMergeIfPipelineSucceeds {
+		return forge.DoctorFailed(forge.DoctorRequiredCheck,
+			"\"Pipelines must succeed\" is off, so MRs merge without waiting for the queue",
+			"enable \"Pipelines must succeed\" in the project's merge request settings")
+	}
+	return forge.DoctorPassed(forge.DoctorRequiredCheck,
+		"\"Pipelines must succeed\" is on, so the "+forge.MQContext+" status gates merges")
+}
+
+func (f *gitlabForge) webhookCheck(ctx context.Context, owner, name string, cfg forge.SetupConfig) forge.DoctorCheck {
+	if cfg.ExternalURL == "" {
+		return forge.DoctorWarning(forge.DoctorWebhook,
+			"GITEA_MQ_EXTERNAL_URL is not set, so pipeline results are only picked up by polling",
+			"set GITEA_MQ_EXTERNAL_URL to the URL the forge reaches gitea-mq at")
+	}
+	webhookURL := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/gitlab"
+	hooks, err := f.client.ListHooks(ctx, owner, name)
+	if err != nil {
+		return forge.DoctorWarning(forge.DoctorWebhook,
+			"cannot list webhooks: "+err.Error(),
+			"check by hand that a webhook for pipeline and merge request events points at "+webhookURL)
+	}
+	var stale []string
+	for _, h := range hooks {
+		if h.URL != webhookURL {
+			if strings.Contains(h.URL, "/webhook/") {
+				stale = append(stale, h.URL)
+			}
+			continue
+		}
+		if !h.PipelineEvents || !h.MergeRequestsEvents {
+			return forge.DoctorFailed(forge.DoctorWebhook,
+				fmt.Sprintf("the webhook to %s does not send both pipeline and merge request events", webhookURL),
+				"enable pipeline and merge request events on the webhook")
+		}
+		return forge.DoctorPassed(forge.DoctorWebhook, "webhook points at "+webhookURL)
+	}
+	detail := "no webhook points at " + webhookURL
+	if len(stale) > 0 {
+		detail += "; found " + strings.Join(stale, ", ") + ", which does not match GITEA_MQ_EXTERNAL_URL"
+	}
+	return forge.DoctorFailed(forge.DoctorWebhook, detail,
+		fmt.Sprintf("create a webhook for pipeline and merge request events to %s, or make the token user a Maintainer so gitea-mq creates it", webhookURL))
+}
+
+// pushCheck tries a dry-run push and then compares the branch's push
+// access levels with the token user, since the dry run never reaches
+// GitLab's protection checks.
+func (f *gitlabForge) pushCheck(ctx context.Context, owner, name string, p *Project) forge.DoctorCheck {
+	branch := p.DefaultBranch
+	if err := f.client.git.PushDryRun(ctx, owner, name, branch); err != nil {
+		return forge.DoctorFailed(forge.DoctorPush,
+			fmt.Sprintf("dry-run push to %s failed: %v", branch, err),
+			"make the token user a Maintainer of the project")
+	}
+	pb, err := f.client.GetProtectedBranch(ctx, owner, name, branch)
+	if IsNotFound(err) {
+		return forge.DoctorPassed(forge.DoctorPush, fmt.Sprintf("dry-run push to %s succeeded", branch))
+	}
+	if err != nil {
+		return forge.DoctorWarning(forge.DoctorPush,
+			fmt.Sprintf("dry-run push to %s succeeded, but its protection could not be read", branch),
+			"check by hand that the token user may push to "+branch)
+	}
+	me, err := f.client.CurrentUser(ctx)
+	if err != nil {
+		return forge.DoctorWarning(forge.DoctorPush,
+			"cannot look up the token user: "+err.Error(),
+			"check by hand that the token user may push to "+branch)
+	}
+	level := p.AccessLevel()
+	for _, a := range pb.PushAccessLevels {
+		if a.UserID == me.ID || (a.AccessLevel > 0 && a.AccessLevel <= level) {
+			return forge.DoctorPassed(forge.DoctorPush,
+				fmt.Sprintf("dry-run push to %s succeeded and %s may push to the protected branch", branch, me.Username))
+		}
+	}
+	return forge.DoctorFailed(forge.DoctorPush,
+		fmt.Sprintf("%s may not push to the protected branch %s, so passing MRs cannot be merged", me.Username, branch),
+		fmt.Sprintf("allow Maintainers or %s to push to %s", me.Username, branch))
+}
+
+func autoMergeCheck(p *Project) forge.DoctorCheck {
+	if p.MergeRequestsAccessLevel == "disabled" {
+		return forge.DoctorFailed(forge.DoctorAutoMerge, "merge requests are disabled",
+			"enable merge requests in the project settings")
+	}
+	return forge.DoctorPassed(forge.DoctorAutoMerge, "MRs can be set to merge when their pipeline succeeds")
+}
@@ -0,0 +1,293 @@
+// Package gitlab adapts GitLab instances to forge.Forge. Merge requests map
+// to PRs, "merge when pipeline succeeds" to AutoMergeEnabled and external
+// commit statuses to gitea-mq's status; the pipeline of a commit is reported
+// as the PipelineContext check.
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/gitea"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// PipelineContext is the check name under which a commit's latest pipeline
+// is reported. GitLab CE has no per-check branch protection; "Pipelines
+// must succeed" gates on the whole pipeline instead.
+const PipelineContext = "pipeline"
+
+type gitlabForge struct {
+	client  *Client
+	baseURL string
+}
+
+// NewForge wraps a GitLab client as a forge.Forge. baseURL is the instance
+// root (no trailing /api/v4), used for HTML URL construction.
+func NewForge(client *Client, baseURL string) forge.Forge {
+	return &gitlabForge{client: client, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+var (
+	_ forge.Forge          = (*gitlabForge)(nil)
+	_ forge.MergeStacker   = (*gitlabForge)(nil)
+	_ forge.EmailResolver  = (*gitlabForge)(nil)
+	_ forge.RepoVisibility = (*gitlabForge)(nil)
+)
+
+func (f *gitlabForge) Kind() forge.Kind { return forge.KindGitlab }
+
+// Pipeline webhooks report CI results, so idle repos need not poll.
+func (f *gitlabForge) Capabilities() forge.Capabilities {
+	return forge.Capabilities{StatusWebhook: true}
+}
+
+func (f *gitlabForge) RepoHTMLURL(owner, name string) string {
+	return f.baseURL + "/" + owner + "/" + name
+}
+
+func (f *gitlabForge) BranchHTMLURL(owner, name, branch string) string {
+	return fmt.Sprintf("%s/%s/%s/-/tree/%s", f.baseURL, owner, name, branch)
+}
+
+// CheckState folds a GitLab pipeline or job status to a CheckState.
+// Skipped and manual jobs do not block a pipeline, so they count as passed.
+func CheckState(status string) forge.CheckState {
+	switch status {
+	case "success", "skipped", "manual":
+		return pg.CheckStateSuccess
+	case "failed":
+		return pg.CheckStateFailure
+	case "canceled":
+		return pg.CheckStateError
+	default:
+		return pg.CheckStatePending
+	}
+}
+
+// statusState maps a CheckState to the state GitLab accepts when posting a
+// commit status.
+func statusState(s forge.CheckState) string {
+	switch s {
+	case pg.CheckStateSuccess:
+		return "success"
+	case pg.CheckStateFailure, pg.CheckStateError:
+		return "failed"
+	default:
+		return "pending"
+	}
+}
+
+func toForgePR(mr *MergeRequest) forge.PR {
+	pr := forge.PR{
+		Number:           mr.IID,
+		Title:            mr.Title,
+		State:            "open",
+		AuthorLogin:      mr.Author.Username,
+		HeadBranch:       mr.SourceBranch,
+		HeadSHA:          mr.SHA,
+		BaseBranch:       mr.TargetBranch,
+		HTMLURL:          mr.WebURL,
+		AutoMergeEnabled: mr.MergeWhenPipelineSucceeds,
+	}
+	switch mr.State {
+	case "merged":
+		pr.State, pr.Merged = "closed", true
+	case "closed", "locked":
+		pr.State = "closed"
+	}
+	return pr
+}
+
+func (f *gitlabForge) ListOpenPRs(ctx context.Context, owner, name string) ([]forge.PR, error) {
+	mrs, err := f.client.ListOpenMRs(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]forge.PR, 0, len(mrs))
+	for i := range mrs {
+		out = append(out, toForgePR(&mrs[i]))
+	}
+	return out, nil
+}
+
+func (f *gitlabForge) GetPR(ctx context.Context, owner, name string, number int64) (*forge.PR, error) {
+	mr, err := f.client.GetMR(ctx, owner, name, number)
+	if err != nil {
+		return nil, err
+	}
+	pr := toForgePR(mr)
+	return &pr, nil
+}
+
+// SetMQStatus posts gitea-mq's external status. GitLab attaches external
+// statuses to the commit's pipeline, so a pending one also holds back
+// "merge when pipeline succeeds" until the queue lands the MR.
+func (f *gitlabForge) SetMQStatus(ctx context.Context, owner, name, sha string, st forge.MQStatus) error {
+	return f.client.SetCommitStatus(ctx, owner, name, sha, CommitStatus{
+		Name:        forge.MQContext,
+		Status:      statusState(st.State),
+		Description: st.Description,
+		TargetURL:   st.TargetURL,
+	})
+}
+
+func (f *gitlabForge) MirrorCheck(ctx context.Context, owner, name, sha, checkContext string, c forge.Check) error {
+	return f.client.SetCommitStatus(ctx, owner, name, sha, CommitStatus{
+		Name:        checkContext,
+		Status:      statusState(c.State),
+		Description: c.Description,
+		TargetURL:   c.TargetURL,
+	})
+}
+
+// GetRequiredChecks requires the pipeline when the project has "Pipelines
+// must succeed" enabled. GitLab has no per-branch list of required checks.
+func (f *gitlabForge) GetRequiredChecks(ctx context.Context, owner, name, _ string) ([]string, error) {
+	p, err := f.client.GetProject(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	if !p.OnlyAllowMergeIfPipelineSucceeds {
+		return nil, nil
+	}
+	return []string{PipelineContext}, nil
+}
+
+// GetCheckStates reports each job and external status on sha plus the
+// latest pipeline as PipelineContext.
+func (f *gitlabForge) GetCheckStates(ctx context.Context, owner, name, sha string) (map[string]forge.Check, error) {
+	sts, err := f.client.ListCommitStatuses(ctx, owner, name, sha)
+	if err != nil {
+		return nil, err
+	}
+	// gitea-mq/* mirrors are kept on purpose: stale-mirror cleanup needs them.
+	out := make(map[string]forge.Check, len(sts)+1)
+	for _, s := range sts {
+		if s.Name == forge.MQContext {
+			continue
+		}
+		out[s.Name] = forge.Check{
+			State:       CheckState(s.Status),
+			Description: s.Description,
+			TargetURL:   s.TargetURL,
+		}
+	}
+	p, err := f.client.LatestPipeline(ctx, owner, name, sha)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		out[PipelineContext] = forge.Check{
+			State:       CheckState(p.Status),
+			Description: "pipeline " + p.Status,
+			TargetURL:   p.WebURL,
+		}
+	}
+	return out, nil
+}
+
+func (f *gitlabForge) CreateMergeBranch(ctx context.Context, owner, name, base, headSHA, branch string) (string, bool, error) {
+	res, err := f.client.git.MergeBranches(ctx, owner, name, base, headSHA, branch)
+	if err != nil {
+		if gitea.IsMergeConflict(err) {
+			return "", true, nil
+		}
+		return "", false, err
+	}
+	return res.SHA, false, nil
+}
+
+func (f *gitlabForge) MergeInto(ctx context.Context, owner, name, branch, headSHA string) (string, bool, error) {
+	return f.CreateMergeBranch(ctx, owner, name, branch, headSHA, branch)
+}
+
+// StackMerges builds the batch branch in one clone instead of one per member.
+func (f *gitlabForge) StackMerges(ctx context.Context, owner, name, base string, heads []string, branch string) (string, []forge.MergeStep, error) {
+	tip, steps, err := f.client.git.StackMerges(ctx, owner, name, base, heads, branch)
+	if err != nil {
+		return "", nil, err
+	}
+	out := make([]forge.MergeStep, len(steps))
+	for i, s := range steps {
+		out[i] = forge.MergeStep{Conflict: s.Conflict, Err: s.Err}
+	}
+	return tip, out, nil
+}
+
+// FastForward pushes sha to branch. Protected branches only take pushes
+// from roles listed under "Allowed to push"; GitLab's rejection becomes a
+// PushDeniedError.
+func (f *gitlabForge) FastForward(ctx context.Context, owner, name, branch, sha string) error {
+	err := f.client.git.FastForwardRef(ctx, owner, name, branch, sha)
+	if err == nil {
+		return nil
+	}
+	var nff *gitea.NotFastForwardError
+	if errors.As(err, &nff) {
+		return forge.ErrNotFastForward
+	}
+	var pbe *gitea.ProtectedBranchError
+	if errors.As(err, &pbe) {
+		return &forge.PushDeniedError{Branch: branch, Message: pbe.Message}
+	}
+	return err
+}
+
+func (f *gitlabForge) IsUpToDate(ctx context.Context, owner, name, base, headSHA string) (bool, error) {
+	n, err := f.client.CommitsBehind(ctx, owner, name, headSHA, base)
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+func (f *gitlabForge) DeleteBranch(ctx context.Context, owner, name, branch string) error {
+	return f.client.DeleteBranch(ctx, owner, name, branch)
+}
+
+func (f *gitlabForge) ListBranches(ctx context.Context, owner, name string) ([]string, error) {
+	return f.client.ListBranches(ctx, owner, name)
+}
+
+func (f *gitlabForge) CancelAutoMerge(ctx context.Context, owner, name string, number int64) error {
+	return f.client.CancelMergeWhenPipelineSucceeds(ctx, owner, name, number)
+}
+
+func (f *gitlabForge) Comment(ctx context.Context, owner, name string, number int64, body string) error {
+	return f.client.CreateNote(ctx, owner, name, number, body)
+}
+
+// ClosePR closes the MR unless it is already closed or merged, which GitLab
+// would otherwise reject.
+func (f *gitlabForge) ClosePR(ctx context.Context, owner, name string, number int64) error {
+	mr, err := f.client.GetMR(ctx, owner, name, number)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if mr.State != "opened" {
+		return nil
+	}
+	return f.client.CloseMR(ctx, owner, name, number)
+}
+
+// UserEmail returns the user's public e-mail; GitLab hides all others.
+func (f *gitlabForge) UserEmail(ctx context.Context, _, _, login string) (string, error) {
+	return f.client.UserPublicEmail(ctx, login)
+}
+
+// RepoPrivate treats internal projects as private: anonymous visitors
+// cannot see them.
+func (f *gitlabForge) RepoPrivate(ctx context.Context, owner, name string) (bool, error) {
+	p, err := f.client.GetProject(ctx, owner, name)
+	if err != nil {
+		return false, err
+	}
+	return p.Visibility != "public", nil
+}
@@ -0,0 +1,296 @@
+package gitlab_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/gitlab"
+	"github.com/Mic92/gitea-mq/internal/gitlab/glfake"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+func newTestForge(t *testing.T) (*glfake.Server, forge.Forge) {
+	t.Helper()
+	srv := glfake.New()
+	t.Cleanup(srv.Close)
+	srv.AddProject("org", "app")
+	c := gitlab.NewClient(srv.URL, glfake.Token)
+	c.SetGitCacheDir(t.TempDir())
+	return srv, gitlab.NewForge(c, "https://gitlab.example.com/")
+}
+
+func TestForge_URLHelpers(t *testing.T) {
+	_, f := newTestForge(t)
+	if got := f.RepoHTMLURL("o", "r"); got != "https://gitlab.example.com/o/r" {
+		t.Errorf("RepoHTMLURL = %q", got)
+	}
+	if got := f.BranchHTMLURL("o", "r", "gitea-mq/7"); got != "https://gitlab.example.com/o/r/-/tree/gitea-mq/7" {
+		t.Errorf("BranchHTMLURL = %q", got)
+	}
+}
+
+func TestForge_ListAndGetPR(t *testing.T) {
+	srv, f := newTestForge(t)
+	srv.AddMR("org", "app", glfake.MR{
+		IID: 1, Title: "feat", Author: "alice",
+		SourceBranch: "feature", TargetBranch: "main", SHA: "sha1",
+		MergeWhenPipelineSucceeds: true,
+	})
+	srv.AddMR("org", "app", glfake.MR{IID: 2, SHA: "sha2", TargetBranch: "main"})
+	srv.AddMR("org", "app", glfake.MR{IID: 3, State: "merged", SHA: "sha3", TargetBranch: "main"})
+	ctx := context.Background()
+
+	prs, err := f.ListOpenPRs(ctx, "org", "app")
+	if err != nil || len(prs) != 2 {
+		t.Fatalf("ListOpenPRs = %+v, %v; want 2 open", prs, err)
+	}
+
+	pr, err := f.GetPR(ctx, "org", "app", 1)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if !pr.AutoMergeEnabled || pr.HeadSHA != "sha1" || pr.AuthorLogin != "alice" || pr.State != "open" || pr.HeadBranch != "feature" {
+		t.Errorf("GetPR mapping = %+v", pr)
+	}
+	if pr, err := f.GetPR(ctx, "org", "app", 3); err != nil || pr.State != "closed" || !pr.Merged {
+		t.Errorf("merged MR = %+v, %v", pr, err)
+	}
+}
+
+// GitLab rejects re-posting a status's current state; the adapter must
+// treat that as success so the monitor can re-assert statuses.
+func TestForge_SetMQStatus_Idempotent(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	st := forge.MQStatus{State: pg.CheckStatePending, Description: "queued"}
+
+	for range 2 {
+		if err := f.SetMQStatus(ctx, "org", "app", "abc", st); err != nil {
+			t.Fatalf("SetMQStatus: %v", err)
+		}
+	}
+	if err := f.SetMQStatus(ctx, "org", "app", "abc", forge.MQStatus{State: pg.CheckStateFailure}); err != nil {
+		t.Fatalf("SetMQStatus(failure): %v", err)
+	}
+	got := srv.Project("org", "app").Statuses["abc"]
+	if len(got) != 2 || got[0].Status != "pending" || got[1].Status != "failed" || got[1].Name != forge.MQContext {
+		t.Fatalf("statuses = %+v", got)
+	}
+}
+
+func TestForge_GetCheckStates_JobsAndPipeline(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	if err := f.MirrorCheck(ctx, "org", "app", "abc", "lint", forge.Check{State: pg.CheckStateSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetMQStatus(ctx, "org", "app", "abc", forge.MQStatus{State: pg.CheckStatePending}); err != nil {
+		t.Fatal(err)
+	}
+	srv.AddPipeline("org", "app", "abc", "success")
+	p := srv.AddPipeline("org", "app", "abc", "failed")
+
+	got, err := f.GetCheckStates(ctx, "org", "app", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got[forge.MQContext]; ok {
+		t.Error("own status must be excluded")
+	}
+	if got["lint"].State != pg.CheckStateSuccess {
+		t.Errorf("lint = %+v", got["lint"])
+	}
+	pl := got[gitlab.PipelineContext]
+	if pl.State != pg.CheckStateFailure || pl.TargetURL != p.WebURL {
+		t.Errorf("pipeline = %+v, want latest (failed) pipeline", pl)
+	}
+}
+
+func TestForge_GetRequiredChecks_FollowsPipelineSetting(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	if got, err := f.GetRequiredChecks(ctx, "org", "app", "main"); err != nil || len(got) != 0 {
+		t.Fatalf("without setting = %v, %v", got, err)
+	}
+	srv.Project("org", "app").PipelinesMustSucceed = true
+	if got, err := f.GetRequiredChecks(ctx, "org", "app", "main"); err != nil || !slices.Equal(got, []string{gitlab.PipelineContext}) {
+		t.Fatalf("with setting = %v, %v", got, err)
+	}
+}
+
+func TestForge_MergeAndFastForward(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	base := srv.Ref("org", "app", "main")
+	head := srv.CommitOn("org", "app", "feature", "main", "a", "a\n")
+
+	sha, conflict, err := f.CreateMergeBranch(ctx, "org", "app", "main", head, "gitea-mq/1")
+	if err != nil || conflict {
+		t.Fatalf("CreateMergeBranch: conflict=%v err=%v", conflict, err)
+	}
+	if srv.Ref("org", "app", "gitea-mq/1") != sha || !srv.IsAncestor("org", "app", head, sha) {
+		t.Fatalf("merge branch = %q, want %q containing head", srv.Ref("org", "app", "gitea-mq/1"), sha)
+	}
+
+	head2 := srv.CommitOn("org", "app", "feature2", "main", "b", "b\n")
+	sha2, conflict, err := f.MergeInto(ctx, "org", "app", "gitea-mq/1", head2)
+	if err != nil || conflict || !srv.IsAncestor("org", "app", sha, sha2) {
+		t.Fatalf("MergeInto: sha=%q conflict=%v err=%v", sha2, conflict, err)
+	}
+
+	clash := srv.CommitOn("org", "app", "clash", "main", "a", "other\n")
+	if _, conflict, err := f.MergeInto(ctx, "org", "app", "gitea-mq/1", clash); err != nil || !conflict {
+		t.Fatalf("conflicting MergeInto: conflict=%v err=%v", conflict, err)
+	}
+
+	if ok, err := f.IsUpToDate(ctx, "org", "app", "main", head); err != nil || !ok {
+		t.Fatalf("IsUpToDate before landing = %v, %v", ok, err)
+	}
+	if err := f.FastForward(ctx, "org", "app", "main", sha2); err != nil {
+		t.Fatalf("FastForward: %v", err)
+	}
+	if srv.Ref("org", "app", "main") != sha2 {
+		t.Fatalf("main = %q, want %q", srv.Ref("org", "app", "main"), sha2)
+	}
+	if ok, err := f.IsUpToDate(ctx, "org", "app", "main", head); err != nil || ok {
+		t.Fatalf("IsUpToDate after landing = %v, %v", ok, err)
+	}
+
+	if err := f.FastForward(ctx, "org", "app", "main", base); !errors.Is(err, forge.ErrNotFastForward) {
+		t.Fatalf("non-ff: err=%v, want ErrNotFastForward", err)
+	}
+
+	srv.Protect("org", "app", "main")
+	next := srv.CommitOn("org", "app", "next", "main", "c", "c\n")
+	var denied *forge.PushDeniedError
+	if err := f.FastForward(ctx, "org", "app", "main", next); !errors.As(err, &denied) {
+		t.Fatalf("protected: err=%v, want PushDeniedError", err)
+	}
+}
+
+func TestForge_DeleteAndListBranches(t *testing.T) {
+	srv, f := newTestForge(t)
+	srv.CommitOn("org", "app", "gitea-mq/9", "main", "x", "x\n")
+	ctx := context.Background()
+
+	if bs, err := f.ListBranches(ctx, "org", "app"); err != nil || !slices.Contains(bs, "gitea-mq/9") {
+		t.Fatalf("ListBranches = %v, %v", bs, err)
+	}
+	for range 2 { // idempotent
+		if err := f.DeleteBranch(ctx, "org", "app", "gitea-mq/9"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+	}
+	if srv.Ref("org", "app", "gitea-mq/9") != "" {
+		t.Error("branch still present")
+	}
+}
+
+func TestForge_CancelAutoMergeCommentAndClose(t *testing.T) {
+	srv, f := newTestForge(t)
+	mr := srv.AddMR("org", "app", glfake.MR{IID: 4, TargetBranch: "main", MergeWhenPipelineSucceeds: true})
+	ctx := context.Background()
+
+	for range 2 { // GitLab answers 406 once nothing is scheduled
+		if err := f.CancelAutoMerge(ctx, "org", "app", 4); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+	}
+	if err := f.Comment(ctx, "org", "app", 4, "ejected"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 { // closing a closed MR is a no-op
+		if err := f.ClosePR(ctx, "org", "app", 4); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+	if mr.MergeWhenPipelineSucceeds || mr.State != "closed" || !slices.Equal(mr.Notes, []string{"ejected"}) {
+		t.Errorf("MR = %+v", mr)
+	}
+}
+
+func TestForge_UserEmailAndVisibility(t *testing.T) {
+	srv, f := newTestForge(t)
+	srv.SetUserEmail("alice", "alice@example.com")
+	ctx := context.Background()
+
+	r := f.(forge.EmailResolver)
+	if got, err := r.UserEmail(ctx, "org", "app", "alice"); err != nil || got != "alice@example.com" {
+		t.Errorf("UserEmail(alice) = %q, %v", got, err)
+	}
+	if got, err := r.UserEmail(ctx, "org", "app", "bob"); err != nil || got != "" {
+		t.Errorf("UserEmail(bob) = %q, %v; want empty", got, err)
+	}
+
+	v := f.(forge.RepoVisibility)
+	if got, err := v.RepoPrivate(ctx, "org", "app"); err != nil || !got {
+		t.Errorf("RepoPrivate(private) = %v, %v", got, err)
+	}
+	srv.Project("org", "app").Visibility = "public"
+	if got, err := v.RepoPrivate(ctx, "org", "app"); err != nil || got {
+		t.Errorf("RepoPrivate(public) = %v, %v", got, err)
+	}
+}
+
+func TestForge_EnsureRepoSetup(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	cfg := forge.SetupConfig{ExternalURL: "https://mq.example.com/", WebhookSecret: "s3cret"}
+
+	for range 2 {
+		if err := f.EnsureRepoSetup(ctx, "org", "app", cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hooks := srv.Project("org", "app").Hooks
+	if len(hooks) != 1 {
+		t.Fatalf("got %d hooks, want 1", len(hooks))
+	}
+	h := hooks[0]
+	if h.URL != "https://mq.example.com/webhook/gitlab" || h.Token != "s3cret" || !h.PipelineEvents || !h.MergeRequestsEvents {
+		t.Errorf("hook = %+v", h)
+	}
+
+	// A hook missing pipeline events is repaired in place.
+	h.PipelineEvents = false
+	if err := f.EnsureRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if hooks := srv.Project("org", "app").Hooks; len(hooks) != 1 || !hooks[0].PipelineEvents || hooks[0].Token != "s3cret" {
+		t.Errorf("hooks after repair = %+v", hooks)
+	}
+}
+
+func TestForge_TeardownRepoSetup(t *testing.T) {
+	srv, f := newTestForge(t)
+	ctx := context.Background()
+	cfg := forge.SetupConfig{ExternalURL: "https://mq.example.com/", WebhookSecret: "s3cret"}
+	p := srv.Project("org", "app")
+	p.Hooks = append(p.Hooks, &glfake.Hook{ID: 999, URL: "https://ci.example.com/hook"})
+
+	if err := f.EnsureRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.TeardownRepoSetup(ctx, "org", "app", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Hooks) != 1 || p.Hooks[0].ID != 999 {
+		t.Errorf("hooks after teardown = %+v, want only the foreign one", p.Hooks)
+	}
+}
+
+func TestTopicSource_SkipsSubgroups(t *testing.T) {
+	srv, _ := newTestForge(t)
+	srv.Project("org", "app").Topics = []string{"merge-queue"}
+	srv.AddProject("org", "other")
+	srv.AddProject("org/sub", "lib").Topics = []string{"merge-queue"}
+
+	refs, err := gitlab.TopicSource(gitlab.NewClient(srv.URL, glfake.Token), "merge-queue")(context.Background())
+	want := []forge.RepoRef{{Forge: forge.KindGitlab, Owner: "org", Name: "app"}}
+	if err != nil || !slices.Equal(refs, want) {
+		t.Fatalf("TopicSource = %v, %v; want %v", refs, err, want)
+	}
+}
@@ -0,0 +1,759 @@
+// Package glfake is an in-process fake of the GitLab REST API (v4) for
+// offline tests of the GitLab adapter.
+//
+// It is not a faithful simulator: only the endpoints gitea-mq calls are
+// implemented. Unlike ghfake, every project is backed by a real bare
+// repository served through git http-backend, because the adapter merges and
+// pushes with git rather than through the API. Tests therefore need git.
+package glfake
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Token is the access token the fake accepts, both as PRIVATE-TOKEN and as
+// the git basic-auth password.
+const Token = "glpat-fake"
+
+type MR struct {
+	IID                       int64
+	Title                     string
+	State                     string // opened, closed, merged
+	Author                    string
+	SourceBranch              string
+	TargetBranch              string
+	SHA                       string
+	MergeWhenPipelineSucceeds bool
+	Notes                     []string
+}
+
+type Status struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
+
+type Pipeline struct {
+	ID     int64  `json:"id"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"`
+	WebURL string `json:"web_url"`
+}
+
+type Hook struct {
+	ID                    int64  `json:"id"`
+	URL                   string `json:"url"`
+	Token                 string `json:"token,omitempty"`
+	PipelineEvents        bool   `json:"pipeline_events"`
+	MergeRequestsEvents   bool   `json:"merge_requests_events"`
+	PushEvents            bool   `json:"push_events"`
+	EnableSSLVerification bool   `json:"enable_ssl_verification"`
+}
+
+type Project struct {
+	Owner, Name string
+	Visibility  string // public, internal, private
+	Topics      []string
+	// PipelinesMustSucceed is only_allow_merge_if_pipeline_succeeds.
+	PipelinesMustSucceed bool
+
+	MRs map[int64]*MR
+	// Statuses[sha] holds the status history, newest last.
+	Statuses  map[string][]*Status
+	Pipelines []*Pipeline
+	Hooks     []*Hook
+
+	// Dir is the bare repository behind the project.
+	Dir string
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	root     string              // GIT_PROJECT_ROOT
+	projects map[string]*Project // owner/name
+	emails   map[string]string   // username -> public e-mail
+	idSeq    atomic.Int64
+}
+
+func New() *Server {
+	root, err := os.MkdirTemp("", "glfake-")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		root:     root,
+		projects: map[string]*Project{},
+		emails:   map[string]string{},
+	}
+	mux := http.NewServeMux()
+	s.routes(mux)
+	git := s.gitHandler()
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, ".git/") {
+			git.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// Close stops the server and removes the project repositories.
+func (s *Server) Close() {
+	s.Server.Close()
+	_ = os.RemoveAll(s.root)
+}
+
+func (s *Server) nextID() int64 { return s.idSeq.Add(1) }
+
+// AddProject creates a project whose repository has one commit on main.
+func (s *Server) AddProject(owner, name string) *Project {
+	dir := filepath.Join(s.root, owner, name+".git")
+	mustGit("", "init", "--quiet", "--bare", "-b", "main", dir)
+	for _, kv := range [][2]string{
+		{"http.receivepack", "true"},
+		{"uploadpack.allowFilter", "true"},
+		{"uploadpack.allowAnySHA1InWant", "true"},
+	} {
+		mustGit(dir, "config", kv[0], kv[1])
+	}
+	// GitLab's own wording, so the adapter sees a realistic rejection.
+	hook := `#!/bin/sh
+while read old new ref; do
+	if grep -qxF "${ref#refs/heads/}" "$GIT_DIR/protected" 2>/dev/null; then
+		echo "GitLab: You are not allowed to push code to protected branches on this project."
+		exit 1
+	fi
+done
+`
+	if err := os.WriteFile(filepath.Join(dir, "hooks", "pre-receive"), []byte(hook), 0o755); err != nil {
+		panic(err)
+	}
+
+	p := &Project{
+		Owner:      owner,
+		Name:       name,
+		Visibility: "private",
+		MRs:        map[int64]*MR{},
+		Statuses:   map[string][]*Status{},
+		Dir:        dir,
+	}
+	s.mu.Lock()
+	s.projects[owner+"/"+name] = p
+	s.mu.Unlock()
+	s.Commit(owner, name, "main", "README", "base\n")
+	return p
+}
+
+func (s *Server) Project(owner, name string) *Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.projects[owner+"/"+name]
+}
+
+// Commit commits file with content on top of branch (creating the branch if
+// needed) and returns the new SHA.
+func (s *Server) Commit(owner, name, branch, file, content string) string {
+	return s.CommitOn(owner, name, branch, "refs/heads/"+branch, file, content)
+}
+
+// CommitOn is Commit with an explicit parent revision; parent may name a
+// branch that does not exist yet, which yields a root commit.
+func (s *Server) CommitOn(owner, name, branch, parent, file, content string) string {
+	dir := s.Project(owner, name).Dir
+	index := filepath.Join(dir, "fake-index")
+	defer os.Remove(index)
+	env := []string{"GIT_INDEX_FILE=" + index}
+
+	parentSHA, _ := gitOut(dir, nil, "rev-parse", "--verify", "--quiet", parent+"^{commit}")
+	if parentSHA != "" {
+		mustGitEnv(dir, env, "read-tree", parentSHA)
+	}
+	blob, err := gitStdin(dir, nil, content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		panic(fmt.Sprintf("glfake: hash-object: %v", err))
+	}
+	mustGitEnv(dir, env, "update-index", "--add", "--cacheinfo", "100644,"+blob+","+file)
+	tree := mustGitEnv(dir, env, "write-tree")
+	args := []string{"commit-tree", tree, "-m", "update " + file}
+	if parentSHA != "" {
+		args = append(args, "-p", parentSHA)
+	}
+	sha := mustGitEnv(dir, nil, args...)
+	mustGit(dir, "update-ref", "refs/heads/"+branch, sha)
+	return sha
+}
+
+// Ref returns the SHA branch points at, or "" if it does not exist.
+func (s *Server) Ref(owner, name, branch string) string {
+	out, _ := gitOut(s.Project(owner, name).Dir, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return out
+}
+
+// IsAncestor reports whether ancestor is reachable from sha.
+func (s *Server) IsAncestor(owner, name, ancestor, sha string) bool {
+	_, err := gitOut(s.Project(owner, name).Dir, nil, "merge-base", "--is-ancestor", ancestor, sha)
+	return err == nil
+}
+
+// Protect rejects pushes to branch the way GitLab's protected branches do
+// for users not allowed to push.
+func (s *Server) Protect(owner, name, branch string) {
+	f, err := os.OpenFile(filepath.Join(s.Project(owner, name).Dir, "protected"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	_, _ = fmt.Fprintln(f, branch)
+}
+
+func (s *Server) AddMR(owner, name string, mr MR) *MR {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mr.State == "" {
+		mr.State = "opened"
+	}
+	cp := mr
+	s.projects[owner+"/"+name].MRs[mr.IID] = &cp
+	return &cp
+}
+
+// AddPipeline records a pipeline for sha and returns it.
+func (s *Server) AddPipeline(owner, name, sha, status string) *Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID()
+	p := &Pipeline{
+		ID: id, SHA: sha, Status: status,
+		WebURL: fmt.Sprintf("%s/%s/%s/-/pipelines/%d", s.URL, owner, name, id),
+	}
+	pr := s.projects[owner+"/"+name]
+	pr.Pipelines = append(pr.Pipelines, p)
+	return p
+}
+
+// SetUserEmail sets the public e-mail GET /users?username= returns.
+func (s *Server) SetUserEmail(username, email string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emails[username] = email
+}
+
+// --- git ---
+
+// gitHandler serves the smart HTTP protocol for all projects, requiring the
+// token as basic-auth password like GitLab.
+func (s *Server) gitHandler() http.Handler {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		panic(err)
+	}
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + s.root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, ok := r.BasicAuth(); !ok || pass != Token {
+			w.Header().Set("WWW-Authenticate", `Basic realm="GitLab"`)
+			http.Error(w, "HTTP Basic: Access denied", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	})
+}
+
+func gitOut(dir string, env []string, args ...string) (string, error) {
+	return gitStdin(dir, env, "", args...)
+}
+
+func gitStdin(dir string, env []string, stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(), append([]string{
+		"GIT_AUTHOR_NAME=glfake", "GIT_AUTHOR_EMAIL=glfake@localhost",
+		"GIT_COMMITTER_NAME=glfake", "GIT_COMMITTER_EMAIL=glfake@localhost",
+	}, env...)...)
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func mustGitEnv(dir string, env []string, args ...string) string {
+	out, err := gitOut(dir, env, args...)
+	if err != nil {
+		panic(fmt.Sprintf("glfake: git %v: %v", args, err))
+	}
+	return out
+}
+
+func mustGit(dir string, args ...string) string { return mustGitEnv(dir, nil, args...) }
+
+// --- HTTP ---
+
+const apiV4 = "/api/v4"
+
+func (s *Server) routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+apiV4+"/projects", s.hListProjects)
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}", s.hGetProject)
+	mux.HandleFunc("GET "+apiV4+"/users", s.hListUsers)
+
+	// Merge requests.
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/merge_requests", s.hListMRs)
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/merge_requests/{iid}", s.hGetMR)
+	mux.HandleFunc("PUT "+apiV4+"/projects/{id}/merge_requests/{iid}", s.hEditMR)
+	mux.HandleFunc("POST "+apiV4+"/projects/{id}/merge_requests/{iid}/notes", s.hCreateNote)
+	mux.HandleFunc("POST "+apiV4+"/projects/{id}/merge_requests/{iid}/cancel_merge_when_pipeline_succeeds", s.hCancelMWPS)
+
+	// Statuses and pipelines.
+	mux.HandleFunc("POST "+apiV4+"/projects/{id}/statuses/{sha}", s.hCreateStatus)
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/repository/commits/{sha}/statuses", s.hListStatuses)
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/pipelines", s.hListPipelines)
+
+	// Repository.
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/repository/branches", s.hListBranches)
+	mux.HandleFunc("DELETE "+apiV4+"/projects/{id}/repository/branches/{branch}", s.hDeleteBranch)
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/repository/compare", s.hCompare)
+
+	// Hooks.
+	mux.HandleFunc("GET "+apiV4+"/projects/{id}/hooks", s.hListHooks)
+	mux.HandleFunc("POST "+apiV4+"/projects/{id}/hooks", s.hAddHook)
+	mux.HandleFunc("PUT "+apiV4+"/projects/{id}/hooks/{hid}", s.hEditHook)
+	mux.HandleFunc("DELETE "+apiV4+"/projects/{id}/hooks/{hid}", s.hDeleteHook)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "glfake: unhandled "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+	})
+}
+
+// authorized checks PRIVATE-TOKEN, replying 401 like GitLab otherwise.
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("PRIVATE-TOKEN") != Token {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"message": "401 Unauthorized"})
+		return false
+	}
+	return true
+}
+
+// projectOr404 resolves the URL-encoded project path, replying 404 when it
+// is unknown.
+func (s *Server) projectOr404(w http.ResponseWriter, r *http.Request) (*Project, bool) {
+	if !authorized(w, r) {
+		return nil, false
+	}
+	s.mu.Lock()
+	p, ok := s.projects[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"message": "404 Project Not Found"})
+	}
+	return p, ok
+}
+
+func (s *Server) mrOr404(w http.ResponseWriter, r *http.Request) (*Project, *MR, bool) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	iid, _ := strconv.ParseInt(r.PathValue("iid"), 10, 64)
+	s.mu.Lock()
+	mr, ok := p.MRs[iid]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"message": "404 Not found"})
+	}
+	return p, mr, ok
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writePage serves one page of items, announcing the next in X-Next-Page.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage <= 0 {
+		perPage = 20
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	writeJSON(w, 200, append([]T{}, items[start:end]...))
+}
+
+func (s *Server) projectJSON(p *Project) map[string]any {
+	return map[string]any{
+		"path_with_namespace":                   p.Owner + "/" + p.Name,
+		"visibility":                            p.Visibility,
+		"topics":                                p.Topics,
+		"only_allow_merge_if_pipeline_succeeds": p.PipelinesMustSucceed,
+	}
+}
+
+func (s *Server) mrJSON(p *Project, mr *MR) map[string]any {
+	return map[string]any{
+		"iid":                          mr.IID,
+		"title":                        mr.Title,
+		"state":                        mr.State,
+		"sha":                          mr.SHA,
+		"source_branch":                mr.SourceBranch,
+		"target_branch":                mr.TargetBranch,
+		"web_url":                      fmt.Sprintf("%s/%s/%s/-/merge_requests/%d", s.URL, p.Owner, p.Name, mr.IID),
+		"author":                       map[string]any{"username": mr.Author},
+		"merge_when_pipeline_succeeds": mr.MergeWhenPipelineSucceeds,
+	}
+}
+
+// --- handlers: projects / users ---
+
+func (s *Server) hListProjects(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r) {
+		return
+	}
+	topic := r.URL.Query().Get("topic")
+	s.mu.Lock()
+	var out []map[string]any
+	for _, p := range s.projects {
+		if topic == "" || slices.Contains(p.Topics, topic) {
+			out = append(out, s.projectJSON(p))
+		}
+	}
+	s.mu.Unlock()
+	writePage(w, r, out)
+}
+
+func (s *Server) hGetProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, 200, s.projectJSON(p))
+}
+
+func (s *Server) hListUsers(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r) {
+		return
+	}
+	username := r.URL.Query().Get("username")
+	s.mu.Lock()
+	email, ok := s.emails[username]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, 200, []any{})
+		return
+	}
+	writeJSON(w, 200, []any{map[string]any{"username": username, "public_email": email}})
+}
+
+// --- handlers: merge requests ---
+
+func (s *Server) hListMRs(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	state := r.URL.Query().Get("state")
+	s.mu.Lock()
+	iids := make([]int64, 0, len(p.MRs))
+	for iid, mr := range p.MRs {
+		if state == "" || state == "all" || mr.State == state {
+			iids = append(iids, iid)
+		}
+	}
+	slices.Sort(iids)
+	out := make([]map[string]any, 0, len(iids))
+	for _, iid := range iids {
+		out = append(out, s.mrJSON(p, p.MRs[iid]))
+	}
+	s.mu.Unlock()
+	writePage(w, r, out)
+}
+
+func (s *Server) hGetMR(w http.ResponseWriter, r *http.Request) {
+	p, mr, ok := s.mrOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, 200, s.mrJSON(p, mr))
+}
+
+func (s *Server) hEditMR(w http.ResponseWriter, r *http.Request) {
+	p, mr, ok := s.mrOr404(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		StateEvent string `json:"state_event"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch body.StateEvent {
+	case "close":
+		if mr.State != "opened" {
+			writeJSON(w, 422, map[string]any{"message": "merge request is not open"})
+			return
+		}
+		mr.State = "closed"
+	case "reopen":
+		mr.State = "opened"
+	}
+	writeJSON(w, 200, s.mrJSON(p, mr))
+}
+
+func (s *Server) hCreateNote(w http.ResponseWriter, r *http.Request) {
+	_, mr, ok := s.mrOr404(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Body string `json:"body"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	s.mu.Lock()
+	mr.Notes = append(mr.Notes, body.Body)
+	s.mu.Unlock()
+	writeJSON(w, 201, map[string]any{"id": s.nextID(), "body": body.Body})
+}
+
+// hCancelMWPS matches GitLab: cancelling when nothing is scheduled is 406.
+func (s *Server) hCancelMWPS(w http.ResponseWriter, r *http.Request) {
+	p, mr, ok := s.mrOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !mr.MergeWhenPipelineSucceeds {
+		writeJSON(w, 406, map[string]any{"message": "406 Not Acceptable"})
+		return
+	}
+	mr.MergeWhenPipelineSucceeds = false
+	writeJSON(w, 201, s.mrJSON(p, mr))
+}
+
+// --- handlers: statuses / pipelines ---
+
+// hCreateStatus matches GitLab's state machine closely enough to reject
+// re-posting a status's current state.
+func (s *Server) hCreateStatus(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Name        string `json:"name"`
+		State       string `json:"state"`
+		Description string `json:"description"`
+		TargetURL   string `json:"target_url"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	name := cmp.Or(body.Name, "default")
+	sha := r.PathValue("sha")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur := latestStatus(p.Statuses[sha], name); cur != nil && cur.Status == body.State {
+		writeJSON(w, 400, map[string]any{"message": fmt.Sprintf("Cannot transition status via :%s from :%s", body.State, cur.Status)})
+		return
+	}
+	st := &Status{Name: name, Status: body.State, Description: body.Description, TargetURL: body.TargetURL}
+	p.Statuses[sha] = append(p.Statuses[sha], st)
+	writeJSON(w, 201, st)
+}
+
+func latestStatus(history []*Status, name string) *Status {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Name == name {
+			return history[i]
+		}
+	}
+	return nil
+}
+
+// hListStatuses returns the latest status per name, as GitLab does without
+// all=true.
+func (s *Server) hListStatuses(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	history := p.Statuses[r.PathValue("sha")]
+	var out []Status
+	seen := map[string]bool{}
+	for i := len(history) - 1; i >= 0; i-- {
+		if !seen[history[i].Name] {
+			seen[history[i].Name] = true
+			out = append(out, *history[i])
+		}
+	}
+	s.mu.Unlock()
+	writePage(w, r, out)
+}
+
+// hListPipelines supports the sha filter and newest-first order.
+func (s *Server) hListPipelines(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	sha := r.URL.Query().Get("sha")
+	s.mu.Lock()
+	var out []Pipeline
+	for i := len(p.Pipelines) - 1; i >= 0; i-- {
+		if sha == "" || p.Pipelines[i].SHA == sha {
+			out = append(out, *p.Pipelines[i])
+		}
+	}
+	s.mu.Unlock()
+	writePage(w, r, out)
+}
+
+// --- handlers: repository ---
+
+func (s *Server) hListBranches(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	out, err := gitOut(p.Dir, nil, "for-each-ref", "--format=%(refname:lstrip=2) %(objectname)", "refs/heads")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var bs []map[string]any
+	for line := range strings.Lines(out) {
+		name, sha, _ := strings.Cut(strings.TrimSpace(line), " ")
+		bs = append(bs, map[string]any{"name": name, "commit": map[string]any{"id": sha}})
+	}
+	writePage(w, r, bs)
+}
+
+func (s *Server) hDeleteBranch(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	ref := "refs/heads/" + r.PathValue("branch")
+	if _, err := gitOut(p.Dir, nil, "rev-parse", "--verify", "--quiet", ref); err != nil {
+		writeJSON(w, 404, map[string]any{"message": "404 Branch Not Found"})
+		return
+	}
+	mustGit(p.Dir, "update-ref", "-d", ref)
+	w.WriteHeader(204)
+}
+
+// hCompare lists the commits on to that from lacks.
+func (s *Server) hCompare(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	out, err := gitOut(p.Dir, nil, "rev-list", q.Get("from")+".."+q.Get("to"))
+	if err != nil {
+		writeJSON(w, 404, map[string]any{"message": "404 Ref Not Found"})
+		return
+	}
+	commits := []map[string]any{}
+	for line := range strings.Lines(out) {
+		commits = append(commits, map[string]any{"id": strings.TrimSpace(line)})
+	}
+	writeJSON(w, 200, map[string]any{"commits": commits})
+}
+
+// --- handlers: hooks ---
+
+func (s *Server) hListHooks(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	out := make([]Hook, 0, len(p.Hooks))
+	for _, h := range p.Hooks {
+		cp := *h
+		cp.Token = "" // write-only in GitLab
+		out = append(out, cp)
+	}
+	s.mu.Unlock()
+	writePage(w, r, out)
+}
+
+func (s *Server) hAddHook(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	var h Hook
+	_ = json.NewDecoder(r.Body).Decode(&h)
+	h.ID = s.nextID()
+	s.mu.Lock()
+	p.Hooks = append(p.Hooks, &h)
+	s.mu.Unlock()
+	writeJSON(w, 201, h)
+}
+
+func (s *Server) hEditHook(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("hid"), 10, 64)
+	var in Hook
+	_ = json.NewDecoder(r.Body).Decode(&in)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range p.Hooks {
+		if h.ID == id {
+			in.ID = id
+			*h = in
+			writeJSON(w, 200, h)
+			return
+		}
+	}
+	writeJSON(w, 404, map[string]any{"message": "404 Not found"})
+}
+
+func (s *Server) hDeleteHook(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.projectOr404(w, r)
+	if !ok {
+		return
+	}
+	id, _ := strconv.ParseInt(r.PathValue("hid"), 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(p.Hooks)
+	p.Hooks = slices.DeleteFunc(p.Hooks, func(h *Hook) bool { return h.ID == id })
+	if len(p.Hooks) == n {
+		writeJSON(w, 404, map[string]any{"message": "404 Not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package gitlab
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+)
+
+// EnsureRepoSetup creates a project webhook for pipeline and merge request
+// events unless one with the same URL exists. GitLab cannot report a hook's
+// token, so an existing hook is left alone. Protected branches are not
+// touched: the token user needs push access to the target branches.
+func (f *gitlabForge) EnsureRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	if cfg.ExternalURL == "" {
+		// No public URL → GitLab has nowhere to deliver webhooks; the
+		// reconcile poll covers us.
+		return nil
+	}
+	webhookURL := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/gitlab"
+
+	hooks, err := f.client.ListHooks(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.URL != webhookURL {
+			continue
+		}
+		if h.PipelineEvents && h.MergeRequestsEvents {
+			slog.Debug("webhook already exists", "owner", owner, "repo", name, "url", webhookURL)
+			return nil
+		}
+		h.PipelineEvents, h.MergeRequestsEvents, h.Token = true, true, cfg.WebhookSecret
+		if err := f.client.EditHook(ctx, owner, name, h); err != nil {
+			return err
+		}
+		slog.Info("enabled webhook events", "owner", owner, "repo", name, "url", webhookURL)
+		return nil
+	}
+
+	if err := f.client.AddHook(ctx, owner, name, Hook{
+		URL:                   webhookURL,
+		Token:                 cfg.WebhookSecret,
+		PipelineEvents:        true,
+		MergeRequestsEvents:   true,
+		EnableSSLVerification: true,
+	}); err != nil {
+		return err
+	}
+	slog.Info("created webhook", "owner", owner, "repo", name, "url", webhookURL)
+	return nil
+}
+
+// TeardownRepoSetup deletes the project webhooks EnsureRepoSetup created.
+func (f *gitlabForge) TeardownRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	if cfg.ExternalURL == "" {
+		return nil
+	}
+	webhookURL := strings.TrimRight(cfg.ExternalURL, "/") + "/webhook/gitlab"
+
+	hooks, err := f.client.ListHooks(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.URL != webhookURL {
+			continue
+		}
+		if err := f.client.DeleteHook(ctx, owner, name, h.ID); err != nil {
+			return err
+		}
+		slog.Info("deleted webhook", "owner", owner, "repo", name, "url", webhookURL)
+	}
+	return nil
+}
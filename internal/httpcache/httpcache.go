@@ -0,0 +1,244 @@
+// Package httpcache revalidates forge API reads with If-None-Match and
+// If-Modified-Since, so polls of unchanged PR lists, timelines and statuses
+// cost the forge a 304 instead of a full response; GitHub does not even
+// charge a 304 against the rate limit. Upstream always sees the 200.
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBytes bounds the bodies a Transport keeps for revalidation.
+const DefaultMaxBytes = 32 << 20
+
+// statsInterval is how often a Transport logs its counters at debug level.
+const statsInterval = 10 * time.Minute
+
+// Stats counts the GET requests of one endpoint. A hit is answered by the
+// server with 304 Not Modified and served from the cache; everything else
+// is a miss.
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+// Transport is the caching http.RoundTripper. Bodies are kept up to maxBytes
+// and evicted least recently used first.
+type Transport struct {
+	name     string
+	next     http.RoundTripper
+	maxBytes int
+
+	mu       sync.Mutex
+	size     int
+	lru      *list.List // of *entry, most recently used first
+	entries  map[string]*list.Element
+	stats    map[string]*Stats
+	loggedAt time.Time
+}
+
+type entry struct {
+	key          string
+	etag         string
+	lastModified string
+	body         []byte
+	header       http.Header
+}
+
+// New returns a Transport in front of next, which defaults to
+// http.DefaultTransport; name appears in the counter log.
+func New(name string, next http.RoundTripper, maxBytes int) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		name:     name,
+		next:     next,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+		stats:    map[string]*Stats{},
+		loggedAt: time.Now(),
+	}
+}
+
+var (
+	apiPrefixRe = regexp.MustCompile(`^/api/v[0-9]+`)
+	repoPathRe  = regexp.MustCompile(`^/repos/[^/]+/[^/]+`)
+	idSegRe     = regexp.MustCompile(`/([0-9]+|[0-9a-f]{40}|[0-9a-f]{64})(/|$)`)
+)
+
+// endpoint names the API route of path for the counters: owner, repo,
+// numbers and commit SHAs are replaced by placeholders.
+func endpoint(path string) string {
+	path = apiPrefixRe.ReplaceAllString(path, "")
+	path = repoPathRe.ReplaceAllString(path, "/repos/{owner}/{repo}")
+	// Twice, because adjacent matches share their slash.
+	for range 2 {
+		path = idSegRe.ReplaceAllString(path, "/{id}$2")
+	}
+	return path
+}
+
+func (t *Transport) get(key string) *entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	el := t.entries[key]
+	if el == nil {
+		return nil
+	}
+	t.lru.MoveToFront(el)
+	return el.Value.(*entry)
+}
+
+func (t *Transport) put(e *entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if el := t.entries[e.key]; el != nil {
+		t.size -= len(el.Value.(*entry).body)
+		t.lru.Remove(el)
+	}
+	if len(e.body) > t.maxBytes {
+		delete(t.entries, e.key)
+		return
+	}
+	t.entries[e.key] = t.lru.PushFront(e)
+	t.size += len(e.body)
+	for t.size > t.maxBytes {
+		old := t.lru.Remove(t.lru.Back()).(*entry)
+		delete(t.entries, old.key)
+		t.size -= len(old.body)
+	}
+}
+
+// count records a request and, every statsInterval, logs the counters so
+// far when debug logging is on.
+func (t *Transport) count(ctx context.Context, path string, hit bool) {
+	t.mu.Lock()
+	ep := endpoint(path)
+	s := t.stats[ep]
+	if s == nil {
+		s = &Stats{}
+		t.stats[ep] = s
+	}
+	if hit {
+		s.Hits++
+	} else {
+		s.Misses++
+	}
+	due := time.Since(t.loggedAt) >= statsInterval
+	if due {
+		t.loggedAt = time.Now()
+	}
+	t.mu.Unlock()
+
+	if due && slog.Default().Enabled(ctx, slog.LevelDebug) {
+		t.logStats(ctx)
+	}
+}
+
+func (t *Transport) logStats(ctx context.Context) {
+	stats := t.snapshot()
+	eps := make([]string, 0, len(stats))
+	for ep := range stats {
+		eps = append(eps, ep)
+	}
+	slices.Sort(eps)
+	attrs := make([]any, 0, len(eps))
+	for _, ep := range eps {
+		attrs = append(attrs, slog.Group(ep, "hits", stats[ep].Hits, "misses", stats[ep].Misses))
+	}
+	slog.DebugContext(ctx, "http cache stats", "cache", t.name, slog.Group("endpoints", attrs...))
+}
+
+// snapshot returns the counters by endpoint, e.g.
+// "/repos/{owner}/{repo}/pulls".
+func (t *Transport) snapshot() map[string]Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]Stats, len(t.stats))
+	for ep, s := range t.stats {
+		out[ep] = *s
+	}
+	return out
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	// Accept selects the representation, so it is part of the key.
+	key := req.URL.String() + " " + req.Header.Get("Accept")
+	cached := t.get(key)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		_ = resp.Body.Close()
+		t.count(req.Context(), req.URL.Path, true)
+		return cached.response(req, resp.Header), nil
+	}
+	t.count(req.Context(), req.URL.Path, false)
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.put(&entry{
+			key:          key,
+			etag:         etag,
+			lastModified: lastModified,
+			body:         body,
+			header:       resp.Header.Clone(),
+		})
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
+
+// response rebuilds a 200 from the entry, overlaying the 304's live
+// rate-limit headers so a budget above the cache stays accurate.
+func (e *entry) response(req *http.Request, live http.Header) *http.Response {
+	h := e.header.Clone()
+	for k, v := range live {
+		if k = http.CanonicalHeaderKey(k); strings.HasPrefix(k, "X-Ratelimit") || strings.HasPrefix(k, "Ratelimit") {
+			h[k] = v
+		}
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     h,
+		Body:       io.NopCloser(bytes.NewReader(e.body)),
+		Request:    req,
+	}
+}
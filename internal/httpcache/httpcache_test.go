@@ -0,0 +1,186 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, c *Transport, url string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	return resp, string(b)
+}
+
+// TestTransport_Revalidates serves one path with an ETag and one with
+// Last-Modified; unchanged resources come back as 304s the caller never
+// sees.
+func TestTransport_Revalidates(t *testing.T) {
+	var full int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/org/app/pulls":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/api/v1/repos/org/app/pulls/7/timeline":
+			if r.Header.Get("If-Modified-Since") != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+		}
+		full++
+		_, _ = io.WriteString(w, "body of "+r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New("test", nil, DefaultMaxBytes)
+	for range 3 {
+		for _, path := range []string{"/api/v1/repos/org/app/pulls", "/api/v1/repos/org/app/pulls/7/timeline"} {
+			resp, body := get(t, c, srv.URL+path)
+			if resp.StatusCode != http.StatusOK || body != "body of "+path {
+				t.Fatalf("GET %s = %d %q", path, resp.StatusCode, body)
+			}
+		}
+	}
+	if full != 2 {
+		t.Errorf("%d full responses, want 2", full)
+	}
+	want := map[string]Stats{
+		"/repos/{owner}/{repo}/pulls":               {Hits: 2, Misses: 1},
+		"/repos/{owner}/{repo}/pulls/{id}/timeline": {Hits: 2, Misses: 1},
+	}
+	got := c.snapshot()
+	for ep, w := range want {
+		if got[ep] != w {
+			t.Errorf("stats[%s] = %+v, want %+v (all: %v)", ep, got[ep], w, got)
+		}
+	}
+}
+
+// A replayed body carries the 304's rate-limit headers, not the stale ones
+// stored with it.
+func TestTransport_LiveRateLimitHeaders(t *testing.T) {
+	remaining := 5000
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining--
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, "hello")
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New("test", nil, DefaultMaxBytes)
+	get(t, c, srv.URL+"/repos/org/app")
+	resp, body := get(t, c, srv.URL+"/repos/org/app")
+	if body != "hello" || resp.Header.Get("X-RateLimit-Remaining") != "4998" {
+		t.Errorf("replay = %q with remaining %s, want hello with 4998", body, resp.Header.Get("X-RateLimit-Remaining"))
+	}
+}
+
+// POST must bypass the cache entirely.
+func TestTransport_SkipsNonGET(t *testing.T) {
+	var inm string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inm = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New("test", nil, DefaultMaxBytes)
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/z", nil)
+		resp, err := c.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if inm != "" {
+		t.Fatalf("POST sent If-None-Match=%q, want empty (not cached)", inm)
+	}
+}
+
+// The least recently used bodies go once the budget is exceeded.
+func TestTransport_Budget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		_, _ = io.WriteString(w, strings.Repeat("x", 40))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New("test", nil, 100)
+	pull := func(n int) { get(t, c, fmt.Sprintf("%s/api/v1/repos/org/app/pulls/%d", srv.URL, n)) }
+	pull(1)
+	pull(2)
+	pull(1) // 1 is now the most recently used
+	pull(3) // over budget: evicts 2
+	if c.size > 100 || len(c.entries) != 2 {
+		t.Fatalf("cache holds %d bytes in %d entries", c.size, len(c.entries))
+	}
+	before := c.snapshot()["/repos/{owner}/{repo}/pulls/{id}"]
+	pull(1)
+	pull(2)
+	after := c.snapshot()["/repos/{owner}/{repo}/pulls/{id}"]
+	if after.Hits-before.Hits != 1 || after.Misses-before.Misses != 1 {
+		t.Errorf("after eviction: %+v → %+v, want one hit (1) and one miss (2)", before, after)
+	}
+}
+
+// The counters reach the debug log once statsInterval has passed.
+func TestTransport_LogsStats(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	c := New("gitea.example", nil, DefaultMaxBytes)
+	get(t, c, srv.URL+"/api/v1/version")
+	if buf.Len() != 0 {
+		t.Fatalf("logged before the interval: %s", buf.String())
+	}
+	c.loggedAt = time.Now().Add(-statsInterval)
+	get(t, c, srv.URL+"/api/v1/version")
+	if got := buf.String(); !strings.Contains(got, "cache=gitea.example") || !strings.Contains(got, "endpoints./version.misses=2") {
+		t.Errorf("log = %s", got)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	for path, want := range map[string]string{
+		"/api/v1/repos/org/app/commits/0123456789abcdef0123456789abcdef01234567/status": "/repos/{owner}/{repo}/commits/{id}/status",
+		"/api/v1/repos/org/app/issues/12/timeline":                                      "/repos/{owner}/{repo}/issues/{id}/timeline",
+		"/repos/org/app/pulls/3/reviews":                                                "/repos/{owner}/{repo}/pulls/{id}/reviews",
+		"/api/v1/version":                                                               "/version",
+	} {
+		if got := endpoint(path); got != want {
+			t.Errorf("endpoint(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/gitea"
 	"github.com/Mic92/gitea-mq/internal/monitor"
 	"github.com/Mic92/gitea-mq/internal/poller"
@@ -112,7 +113,7 @@ func TestFullMergeQueueFlow(t *testing.T) {
 	}
 
 	// Register repo in DB.
-	repo, err := svc.GetOrCreateRepo(ctx, "gitea", "testuser", repoName)
+	repo, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "testuser", Name: repoName})
 	if err != nil {
 		t.Fatalf("register repo: %v", err)
 	}
@@ -143,7 +144,8 @@ func TestFullMergeQueueFlow(t *testing.T) {
 		},
 	}
 	webhookSecret := "test-secret"
-	webhookHandler := webhook.Handler(webhookSecret, repoMonitors, svc)
+	inbox := &webhook.Inbox{Queue: svc, Repos: repoMonitors}
+	webhookHandler := webhook.Handler(webhookSecret, inbox)
 
 	// --- Step 1a: Poll while CI is still pending → PR is NOT enqueued ---
 	result, err := poller.PollOnce(ctx, pollerDeps)
@@ -212,6 +214,9 @@ func TestFullMergeQueueFlow(t *testing.T) {
 	if recorder.Code != http.StatusOK {
 		t.Fatalf("webhook returned %d", recorder.Code)
 	}
+	if err := inbox.Drain(ctx); err != nil {
+		t.Fatalf("process webhook: %v", err)
+	}
 
 	// --- Step 3b: Verify mirrored status gitea-mq/ci/build on PR head ---
 	_, mirrorStatusBody := api.Do(t, "GET", "/repos/testuser/"+repoName+"/statuses/"+pr.Head.SHA, "")
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Mic92/gitea-mq/internal/batch"
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/gitea"
 	"github.com/Mic92/gitea-mq/internal/monitor"
 	"github.com/Mic92/gitea-mq/internal/poller"
@@ -92,7 +93,7 @@ func TestGitea_BatchFlow_Green(t *testing.T) {
 	}
 	prs := []pr{mkPR("feature-1", "a.txt"), mkPR("feature-2", "b.txt")}
 
-	repo, _ := svc.GetOrCreateRepo(ctx, "gitea", "testuser", repoName)
+	repo, _ := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "testuser", Name: repoName})
 	eng := &batch.Engine{
 		Forge: f, Queue: svc, Owner: "testuser", Repo: repoName, RepoID: repo.ID,
 		BatchMax: 0, MergedPollInterval: 200 * time.Millisecond, MergedPollAttempts: 15,
@@ -106,8 +107,8 @@ func TestGitea_BatchFlow_Green(t *testing.T) {
 		CheckTimeout: time.Hour, Batch: eng,
 	}
 	const secret = "s"
-	hooks := webhook.Handler(secret,
-		webhook.MapRepoLookup{"gitea:testuser/" + repoName: {Deps: monDeps}}, svc)
+	inbox := &webhook.Inbox{Queue: svc, Repos: webhook.MapRepoLookup{"gitea:testuser/" + repoName: {Deps: monDeps}}}
+	hooks := webhook.Handler(secret, inbox)
 
 	// --- Poll: enqueue 2, form batch via StackMerges, push gitea-mq/batch/<id> ---
 	if r, err := poller.PollOnce(ctx, pollerDeps); err != nil {
@@ -143,6 +144,9 @@ func TestGitea_BatchFlow_Green(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Fatalf("webhook: %d %s", w.Code, w.Body.String())
 	}
+	if err := inbox.Drain(ctx); err != nil {
+		t.Fatalf("process webhook: %v", err)
+	}
 
 	// --- main fast-forwarded to the tested SHA, both PRs dequeued ---
 	if !waitFor(func() bool {
@@ -42,7 +42,7 @@ func TestGithub_BatchFlow_Green(t *testing.T) {
 		}
 	}
 
-	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), srv.URL+"/api/v3")
+	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), githubpkg.Endpoints{Web: srv.WebURL()})
 	if err != nil {
 		t.Fatalf("new app: %v", err)
 	}
@@ -50,11 +50,11 @@ func TestGithub_BatchFlow_Green(t *testing.T) {
 	if err := app.Refresh(ctx); err != nil {
 		t.Fatalf("refresh: %v", err)
 	}
-	f := githubpkg.NewForge(app, "")
+	f := githubpkg.NewForge(app)
 
 	pool := testutil.TestDB(t)
 	svc := queue.NewService(pool)
-	dbRepo, _ := svc.GetOrCreateRepo(ctx, "github", "org", "app")
+	dbRepo, _ := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"})
 
 	eng := &batch.Engine{
 		Forge: f, Queue: svc, Owner: "org", Repo: "app", RepoID: dbRepo.ID,
@@ -69,8 +69,8 @@ func TestGithub_BatchFlow_Green(t *testing.T) {
 		CheckTimeout: time.Hour, Batch: eng,
 	}
 	const secret = "s"
-	hooks := webhook.GithubHandler([]byte(secret),
-		webhook.MapRepoLookup{"github:org/app": {Deps: monDeps}}, svc, nil)
+	inbox := &webhook.Inbox{Queue: svc, Repos: webhook.MapRepoLookup{"github:org/app": {Deps: monDeps}}}
+	hooks := webhook.GithubHandler([]byte(secret), inbox)
 
 	// --- Poll: enqueue 3, form one batch, build branch ---
 	if _, err := poller.PollOnce(ctx, pollerDeps); err != nil {
@@ -96,6 +96,9 @@ func TestGithub_BatchFlow_Green(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Fatalf("webhook: %d %s", w.Code, w.Body.String())
 	}
+	if err := inbox.Drain(ctx); err != nil {
+		t.Fatalf("process webhook: %v", err)
+	}
 
 	// --- Engine fast-forwarded main to the tested SHA, batch done ---
 	if repo.Refs["main"] != branchSHA {
@@ -2,6 +2,7 @@ package integration_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -39,7 +40,7 @@ func TestGithub_FullMergeQueueFlow(t *testing.T) {
 		{Name: "ci/build", Status: "completed", Conclusion: "success"},
 	}
 
-	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), srv.URL+"/api/v3")
+	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), githubpkg.Endpoints{Web: srv.WebURL()})
 	if err != nil {
 		t.Fatalf("new app: %v", err)
 	}
@@ -47,11 +48,11 @@ func TestGithub_FullMergeQueueFlow(t *testing.T) {
 	if err := app.Refresh(ctx); err != nil {
 		t.Fatalf("refresh: %v", err)
 	}
-	f := githubpkg.NewForge(app, "")
+	f := githubpkg.NewForge(app)
 
 	pool := testutil.TestDB(t)
 	svc := queue.NewService(pool)
-	dbRepo, err := svc.GetOrCreateRepo(ctx, "github", "org", "app")
+	dbRepo, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"})
 	if err != nil {
 		t.Fatalf("create repo: %v", err)
 	}
@@ -65,8 +66,8 @@ func TestGithub_FullMergeQueueFlow(t *testing.T) {
 		CheckTimeout: time.Hour,
 	}
 	const secret = "gh-hook-secret"
-	hooks := webhook.GithubHandler([]byte(secret),
-		webhook.MapRepoLookup{"github:org/app": {Deps: monDeps}}, svc, nil)
+	inbox := &webhook.Inbox{Queue: svc, Repos: webhook.MapRepoLookup{"github:org/app": {Deps: monDeps}}}
+	hooks := webhook.GithubHandler([]byte(secret), inbox)
 
 	// --- Poll enqueues and creates merge branch (no PR webhook needed:
 	// proves the reconcile poll alone recovers after missed deliveries) ---
@@ -97,6 +98,9 @@ func TestGithub_FullMergeQueueFlow(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Fatalf("webhook: %d %s", w.Code, w.Body.String())
 	}
+	if err := inbox.Drain(ctx); err != nil {
+		t.Fatalf("process webhook: %v", err)
+	}
 
 	// Monitor must have flipped gitea-mq to success on the PR head.
 	var mqRun *ghfake.CheckRun
@@ -133,3 +137,131 @@ func TestGithub_FullMergeQueueFlow(t *testing.T) {
 		t.Error("merge branch not cleaned up")
 	}
 }
+
+// TestGithub_StatusButtons presses the gitea-mq check-run buttons through
+// check_run requested_action webhooks: only writers can remove a queued PR,
+// and "Retry in queue" turns auto-merge back on.
+func TestGithub_StatusButtons(t *testing.T) {
+	srv := ghfake.New()
+	t.Cleanup(srv.Close)
+	srv.AddRepo("org", "app")
+	srv.AddInstallation(100, "org/app")
+	repo := srv.Repo("org", "app")
+	repo.RequiredChecks["main"] = []string{"ci/build"}
+	repo.Permissions["alice"] = "write"
+	repo.Permissions["bob"] = "read"
+	pr := srv.AddPR("org", "app", ghfake.PR{
+		Number: 1, BaseRef: "main", HeadSHA: "sha-head", AutoMerge: true, User: "carol",
+	})
+	repo.CheckRuns["sha-head"] = []*ghfake.CheckRun{
+		{Name: "ci/build", Status: "completed", Conclusion: "success"},
+	}
+
+	app, err := githubpkg.NewApp(1, testutil.GithubAppKey(), githubpkg.Endpoints{Web: srv.WebURL()})
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	ctx := t.Context()
+	if err := app.Refresh(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	f := githubpkg.NewForge(app)
+
+	pool := testutil.TestDB(t)
+	svc := queue.NewService(pool)
+	dbRepo, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"})
+	if err != nil {
+		t.Fatalf("create repo: %v", err)
+	}
+	pollerDeps := &poller.Deps{
+		Forge: f, Queue: svc, RepoID: dbRepo.ID, Owner: "org", Repo: "app",
+		SuccessTimeout: 5 * time.Minute,
+	}
+	var polled int
+	rm := &webhook.RepoMonitor{
+		Deps:        &monitor.Deps{Forge: f, Queue: svc, Owner: "org", Repo: "app", RepoID: dbRepo.ID},
+		TriggerPoll: func() { polled++ },
+		Actions:     f.(forge.StatusActions),
+		Dequeue: func(ctx context.Context, prNumber int64, login string) error {
+			return poller.EjectBy(ctx, pollerDeps, prNumber, login)
+		},
+	}
+	const secret = "gh-hook-secret"
+	inbox := &webhook.Inbox{Queue: svc, Repos: webhook.MapRepoLookup{"github:org/app": rm}}
+	hooks := webhook.GithubHandler([]byte(secret), inbox)
+
+	press := func(identifier, sender string) {
+		t.Helper()
+		var run *ghfake.CheckRun
+		for _, cr := range repo.CheckRuns["sha-head"] {
+			if cr.Name == forge.MQContext {
+				run = cr
+			}
+		}
+		if run == nil {
+			t.Fatal("no gitea-mq check run")
+		}
+		body, err := srv.RequestAction("org", "app", run.ID, identifier, sender)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/webhook/github", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "check_run")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+webhook.ComputeSignature(body, secret))
+		w := httptest.NewRecorder()
+		hooks.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("webhook: %d %s", w.Code, w.Body.String())
+		}
+		if err := inbox.Drain(ctx); err != nil {
+			t.Fatalf("process webhook: %v", err)
+		}
+	}
+
+	if res, err := poller.PollOnce(ctx, pollerDeps); err != nil || len(res.Enqueued) != 1 {
+		t.Fatalf("PollOnce: %+v, %v", res, err)
+	}
+
+	// A reader's press is dropped.
+	press(githubpkg.ActionDequeue, "bob")
+	if e, _ := svc.GetEntry(ctx, dbRepo.ID, 1); e == nil {
+		t.Fatal("PR removed on a reader's request")
+	}
+
+	press(githubpkg.ActionDequeue, "alice")
+	if e, _ := svc.GetEntry(ctx, dbRepo.ID, 1); e != nil {
+		t.Fatalf("PR still queued: %+v", e)
+	}
+	if pr.AutoMerge {
+		t.Error("auto-merge not cancelled")
+	}
+
+	// Presses on a stale head or a closed PR are dropped, not retried.
+	pr.HeadSHA = "sha-new"
+	press(githubpkg.ActionRetry, "alice")
+	pr.HeadSHA = "sha-head"
+	pr.State = "closed"
+	press(githubpkg.ActionRetry, "alice")
+	if pr.AutoMerge || polled != 0 {
+		t.Fatalf("stale retry acted: auto-merge %v, %d polls", pr.AutoMerge, polled)
+	}
+	deliveries, err := svc.ListDeliveries(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deliveries {
+		if d.State != pg.DeliveryStateDone {
+			t.Errorf("delivery %s is %s, want done", d.DeliveryID, d.State)
+		}
+	}
+
+	pr.State = "open"
+	press(githubpkg.ActionRetry, "alice")
+	if !pr.AutoMerge {
+		t.Error("retry did not re-enable auto-merge")
+	}
+	if polled != 1 {
+		t.Errorf("retry triggered %d polls, want 1", polled)
+	}
+}
@@ -0,0 +1,52 @@
+// Package leader elects one active replica among gitea-mq processes that
+// share a database. The leader runs pollers, discovery and the batch engine;
+// the others stand by, serve the dashboard and forward webhooks.
+package leader
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/queue"
+)
+
+// Elector campaigns for leadership on behalf of this process.
+type Elector struct {
+	Queue *queue.Service
+	// Interval is both how often a held lock is checked and how often a
+	// standby retries to take it.
+	Interval time.Duration
+
+	leading atomic.Bool
+}
+
+// Leading reports whether this process currently holds leadership.
+func (e *Elector) Leading() bool {
+	return e.leading.Load()
+}
+
+// Run campaigns until ctx ends. Every time leadership is won, lead runs with
+// a context that is cancelled when it is lost; lead should block until then.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	for {
+		_, err := e.Queue.Lead(ctx, e.Interval, func(term context.Context) {
+			e.leading.Store(true)
+			slog.Info("became leader")
+			defer func() {
+				e.leading.Store(false)
+				slog.Info("leadership ended")
+			}()
+			lead(term)
+		})
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("leader election failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.Interval):
+		}
+	}
+}
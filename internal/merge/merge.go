@@ -29,7 +29,8 @@ func BranchName(prNumber int64) string {
 type StartTestingResult struct {
 	MergeBranchName string
 	MergeBranchSHA  string
-	Removed         bool // true if the PR was removed from the queue instead of entering testing
+	Removed         bool   // true if the PR was removed from the queue instead of entering testing
+	Reason          string // status description shown on the PR when Removed
 }
 
 // StartTesting creates a merge branch for the head-of-queue PR and
@@ -37,7 +38,7 @@ type StartTestingResult struct {
 // is removed from the queue with automerge cancelled and a comment posted.
 func StartTesting(ctx context.Context, f forge.Forge, svc *queue.Service, owner, repo string, repoID int64, entry *pg.QueueEntry, externalURL string) (*StartTestingResult, error) {
 	branchName := BranchName(entry.PrNumber)
-	targetURL := forge.DashboardPRURL(externalURL, f.Kind(), owner, repo, entry.PrNumber)
+	targetURL := forge.DashboardPRURL(externalURL, forge.RefOf(f, owner, repo), entry.PrNumber)
 
 	mergeSHA, conflict, err := f.CreateMergeBranch(ctx, owner, repo, entry.TargetBranch, entry.PrHeadSha, branchName)
 	if conflict {
@@ -54,7 +55,7 @@ func StartTesting(ctx context.Context, f forge.Forge, svc *queue.Service, owner,
 		if _, err := svc.Dequeue(ctx, repoID, entry.PrNumber); err != nil {
 			return nil, fmt.Errorf("dequeue conflicting PR #%d: %w", entry.PrNumber, err)
 		}
-		return &StartTestingResult{Removed: true}, nil
+		return &StartTestingResult{Removed: true, Reason: "Merge conflict with target branch"}, nil
 	}
 	if err != nil {
 		// Non-conflict failure (e.g. unrelated histories) — surface to the
@@ -72,7 +73,7 @@ func StartTesting(ctx context.Context, f forge.Forge, svc *queue.Service, owner,
 		if _, err := svc.Dequeue(ctx, repoID, entry.PrNumber); err != nil {
 			return nil, fmt.Errorf("dequeue PR #%d after merge error: %w", entry.PrNumber, err)
 		}
-		return &StartTestingResult{Removed: true}, nil
+		return &StartTestingResult{Removed: true, Reason: "Failed to create merge branch"}, nil
 	}
 
 	if err := svc.SetMergeBranch(ctx, repoID, entry.PrNumber, branchName, mergeSHA); err != nil {
@@ -124,10 +125,11 @@ func CleanupMergeBranch(ctx context.Context, f forge.Forge, owner, repo string,
 // CleanupStaleBranches deletes orphaned gitea-mq/* branches not referenced by
 // any active queue entry or live batch. Called on startup to clean up after
 // crashes. spare lists additional branch names to keep (live batch branches).
-func CleanupStaleBranches(ctx context.Context, f forge.Forge, svc *queue.Service, owner, repo string, repoID int64, spare []string) error {
+// Returns the branches deleted.
+func CleanupStaleBranches(ctx context.Context, f forge.Forge, svc *queue.Service, owner, repo string, repoID int64, spare []string) ([]string, error) {
 	activeEntries, err := svc.ListActiveEntries(ctx, repoID)
 	if err != nil {
-		return fmt.Errorf("list active entries: %w", err)
+		return nil, fmt.Errorf("list active entries: %w", err)
 	}
 
 	activeBranches := make(map[string]bool, len(activeEntries)+len(spare))
@@ -142,10 +144,10 @@ func CleanupStaleBranches(ctx context.Context, f forge.Forge, svc *queue.Service
 
 	branches, err := f.ListBranches(ctx, owner, repo)
 	if err != nil {
-		return fmt.Errorf("list branches: %w", err)
+		return nil, fmt.Errorf("list branches: %w", err)
 	}
 
-	var deleted int
+	var deleted []string
 	for _, b := range branches {
 		if !strings.HasPrefix(b, BranchPrefix) {
 			continue
@@ -159,10 +161,10 @@ func CleanupStaleBranches(ctx context.Context, f forge.Forge, svc *queue.Service
 			slog.Warn("failed to delete stale branch", "branch", b, "error", err)
 			continue
 		}
-		deleted++
+		deleted = append(deleted, b)
 	}
 
 	slog.Info("startup merge branch cleanup", "owner", owner, "repo", repo,
-		"active_branches", len(activeBranches), "stale_deleted", deleted)
-	return nil
+		"active_branches", len(activeBranches), "stale_deleted", len(deleted))
+	return deleted, nil
 }
@@ -11,7 +11,9 @@ import (
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/logutil"
 	"github.com/Mic92/gitea-mq/internal/merge"
+	"github.com/Mic92/gitea-mq/internal/notify"
 	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 )
 
@@ -28,6 +30,9 @@ type Deps struct {
 	// Batch, when non-nil, intercepts check results for entries that belong
 	// to a live batch. The single-PR success/failure handlers are skipped.
 	Batch BatchHandler
+
+	// Notifier mails the author when a PR is removed. Nil disables it.
+	Notifier *notify.Notifier
 }
 
 // BatchHandler dispatches a raw check event to the batch engine. Defined
@@ -112,6 +117,44 @@ func EvaluateChecks(statuses []pg.CheckStatus, requiredChecks []string) (CheckRe
 	return CheckSuccess, "", ""
 }
 
+// MergeCheckStatuses combines recorded check statuses with the required checks
+// list. Any required check that hasn't reported yet appears as pending.
+// If required is empty (meaning "any single success"), only recorded statuses
+// are returned.
+func MergeCheckStatuses(recorded []pg.CheckStatus, required []string) []pg.CheckStatus {
+	if len(required) == 0 {
+		return recorded
+	}
+
+	result := make([]pg.CheckStatus, 0, len(required))
+	// Add required checks in order, using recorded state or pending.
+	recordedMap := make(map[string]pg.CheckStatus, len(recorded))
+	for _, s := range recorded {
+		recordedMap[s.Context] = s
+	}
+	for _, ctx := range required {
+		if s, ok := recordedMap[ctx]; ok {
+			result = append(result, s)
+		} else {
+			result = append(result, pg.CheckStatus{Context: ctx, State: pg.CheckStatePending})
+		}
+	}
+	// Append any recorded checks not in the required list (unexpected extras).
+	for _, s := range recorded {
+		found := false
+		for _, req := range required {
+			if s.Context == req {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
 func CheckTimeout(entry *pg.QueueEntry, timeout time.Duration) bool {
 	if !entry.TestingStartedAt.Valid {
 		return false
@@ -124,10 +167,13 @@ func CheckTimeout(entry *pg.QueueEntry, timeout time.Duration) bool {
 // Does NOT advance — the poller confirms the PR is actually merged first.
 func HandleSuccess(ctx context.Context, deps *Deps, entry *pg.QueueEntry) error {
 	slog.Info("all checks passed", "pr", entry.PrNumber)
+	recordBuild(ctx, deps, entry, true)
 
-	targetURL := forge.DashboardPRURL(deps.ExternalURL, deps.Forge.Kind(), deps.Owner, deps.Repo, entry.PrNumber)
+	targetURL := forge.DashboardPRURL(deps.ExternalURL, forge.RefOf(deps.Forge, deps.Owner, deps.Repo), entry.PrNumber)
 
-	if err := deps.Forge.SetMQStatus(ctx, deps.Owner, deps.Repo, entry.PrHeadSha, forge.MQStatus{
+	// The success status lets the forge merge the PR, so it goes ahead of
+	// other calls when the rate limit runs low.
+	if err := deps.Forge.SetMQStatus(ratelimit.WithPriority(ctx, ratelimit.High), deps.Owner, deps.Repo, entry.PrHeadSha, forge.MQStatus{
 		State: pg.CheckStateSuccess, Description: "Merge queue passed", TargetURL: targetURL,
 	}); err != nil {
 		return fmt.Errorf("set success status for PR #%d: %w", entry.PrNumber, err)
@@ -170,6 +216,7 @@ func skipPendingMirroredStatuses(ctx context.Context, f forge.Forge, owner, repo
 
 func HandleFailure(ctx context.Context, deps *Deps, entry *pg.QueueEntry, failedCheck, targetURL string) error {
 	slog.Info("check failed", "pr", entry.PrNumber, "check", failedCheck)
+	recordBuild(ctx, deps, entry, false)
 
 	desc := fmt.Sprintf("Check failed: %s", failedCheck)
 	checkRef := failedCheck
@@ -183,13 +230,21 @@ func HandleFailure(ctx context.Context, deps *Deps, entry *pg.QueueEntry, failed
 
 func HandleTimeout(ctx context.Context, deps *Deps, entry *pg.QueueEntry) error {
 	slog.Info("check timeout exceeded", "pr", entry.PrNumber)
+	recordBuild(ctx, deps, entry, false)
 
 	return removeFromQueue(ctx, deps, entry, pg.CheckStateError, "Check timeout exceeded",
 		"⏰ Removed from merge queue: check timeout exceeded. Required checks did not complete in time.")
 }
 
+// recordBuild adds the finished single-PR build to the duration history the
+// landing-time estimate is based on.
+func recordBuild(ctx context.Context, deps *Deps, entry *pg.QueueEntry, passed bool) {
+	logutil.WarnIfErr(deps.Queue.RecordBuild(ctx, deps.RepoID, entry.TargetBranch, 1, passed, entry.TestingStartedAt),
+		"record build duration failed", "pr", entry.PrNumber)
+}
+
 func removeFromQueue(ctx context.Context, deps *Deps, entry *pg.QueueEntry, statusState pg.CheckState, statusDesc, comment string) error {
-	targetURL := forge.DashboardPRURL(deps.ExternalURL, deps.Forge.Kind(), deps.Owner, deps.Repo, entry.PrNumber)
+	targetURL := forge.DashboardPRURL(deps.ExternalURL, forge.RefOf(deps.Forge, deps.Owner, deps.Repo), entry.PrNumber)
 	if err := deps.Forge.SetMQStatus(ctx, deps.Owner, deps.Repo, entry.PrHeadSha, forge.MQStatus{
 		State: statusState, Description: statusDesc, TargetURL: targetURL,
 	}); err != nil {
@@ -206,6 +261,11 @@ func removeFromQueue(ctx context.Context, deps *Deps, entry *pg.QueueEntry, stat
 
 	merge.CleanupMergeBranch(ctx, deps.Forge, deps.Owner, deps.Repo, entry)
 
+	deps.Notifier.Notify(ctx, notify.Event{
+		Kind: notify.Ejected, Forge: deps.Forge, RepoID: deps.RepoID,
+		Owner: deps.Owner, Repo: deps.Repo, PR: entry.PrNumber, Reason: statusDesc,
+	})
+
 	if err := deps.Queue.UpdateState(ctx, deps.RepoID, entry.PrNumber, pg.EntryStateFailed); err != nil {
 		slog.Warn("failed to update state to failed", "pr", entry.PrNumber, "error", err)
 	}
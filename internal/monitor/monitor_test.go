@@ -6,8 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/gitea"
 	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/notify"
 	"github.com/Mic92/gitea-mq/internal/queue"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 	"github.com/Mic92/gitea-mq/internal/testutil"
@@ -246,3 +248,47 @@ func TestEvaluateChecks_FailureWinsOverPending(t *testing.T) {
 		t.Fatalf("unexpected failed check %q url %q", failed, url)
 	}
 }
+
+// A failing required check mails the author and records the failure for the
+// repo's digest.
+func TestProcessCheckStatus_Failure_NotifiesAuthor(t *testing.T) {
+	deps, mock, svc, ctx, repoID := setupMonitorTest(t)
+	withBranchProtection(mock, "gitea-mq", "ci/build")
+	mock.GetPRFn = func(_ context.Context, _, _ string, n int64) (*gitea.PR, error) {
+		return &gitea.PR{Index: n, Title: "Fix it", User: &gitea.User{Login: "alice"}}, nil
+	}
+	mock.GetUserFn = func(_ context.Context, login string) (*gitea.User, error) {
+		return &gitea.User{Login: login, Email: "alice@example.com"}, nil
+	}
+	smtpSrv := testutil.NewSMTPServer(t)
+	n, err := notify.New(notify.Config{
+		SMTP:          notify.SMTP{Addr: smtpSrv.Addr, From: "mq@example.com"},
+		NotifyAuthors: true,
+		Digest:        map[forge.RepoRef][]string{{Forge: forge.KindGitea, Owner: "org", Name: "app"}: {"ops@example.com"}},
+	}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go n.Run(ctx)
+	deps.Notifier = n
+	entry := testutil.EnqueueTesting(t, svc, repoID, 42, "sha42", "mergesha")
+
+	if err := monitor.ProcessCheckStatus(ctx, deps, entry, "ci/build", pg.CheckStateFailure, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	m := smtpSrv.WaitMessage(t)
+	if len(m.To) != 1 || m.To[0] != "alice@example.com" {
+		t.Fatalf("To = %v", m.To)
+	}
+	if !strings.Contains(m.Data, "Check failed: ci/build") {
+		t.Errorf("mail does not name the failed check:\n%s", m.Data)
+	}
+	failures, err := svc.ListFailures(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].PrNumber != 42 {
+		t.Errorf("digest failures = %+v", failures)
+	}
+}
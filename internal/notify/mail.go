@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+var templateNames = []string{"ejected.tmpl", "landed.tmpl", "digest.tmpl"}
+
+// loadTemplates parses the built-in templates and replaces each one that has
+// a same-named file in dir. A broken override fails startup rather than
+// silently falling back, so typos surface immediately.
+func loadTemplates(dir string) (*template.Template, error) {
+	t, err := template.ParseFS(defaultTemplates, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return t, nil
+	}
+	for _, name := range templateNames {
+		path := filepath.Join(dir, name)
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(name).Parse(string(b)); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	return t, nil
+}
+
+// render executes a template whose output is a "Subject: ..." line, a blank
+// line, and the plain-text body.
+func (n *Notifier) render(name string, data any) (subject, body string, err error) {
+	var buf bytes.Buffer
+	if err := n.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", "", err
+	}
+	head, body, _ := strings.Cut(buf.String(), "\n\n")
+	subject, ok := strings.CutPrefix(head, "Subject:")
+	if !ok || strings.Contains(head, "\n") {
+		return "", "", fmt.Errorf("template %s: output must start with a single Subject: line followed by a blank line", name)
+	}
+	return strings.TrimSpace(subject), body, nil
+}
+
+// send submits one message via the configured relay. net/smtp upgrades to
+// STARTTLS when the server offers it and refuses PLAIN auth over cleartext
+// except to localhost.
+func (n *Notifier) send(to []string, subject, body string) error {
+	var auth smtp.Auth
+	if n.cfg.SMTP.Username != "" {
+		host, _, err := net.SplitHostPort(n.cfg.SMTP.Addr)
+		if err != nil {
+			return fmt.Errorf("smtp address %q: %w", n.cfg.SMTP.Addr, err)
+		}
+		auth = smtp.PlainAuth("", n.cfg.SMTP.Username, n.cfg.SMTP.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.SMTP.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("Auto-Submitted: auto-generated\r\n\r\n")
+	msg.WriteString(body)
+
+	return smtp.SendMail(n.cfg.SMTP.Addr, auth, n.from, to, msg.Bytes())
+}
@@ -0,0 +1,281 @@
+// Package notify e-mails PR authors when the queue ejects or lands their PR,
+// and sends a periodic digest of queue failures to per-repo watchers. Forge
+// comments get buried among review notifications; mail is opt-in via
+// GITEA_MQ_SMTP_ADDR.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/logutil"
+	"github.com/Mic92/gitea-mq/internal/queue"
+)
+
+// Kind is the PR lifecycle event a notification is about.
+type Kind string
+
+const (
+	Ejected Kind = "ejected"
+	Landed  Kind = "landed"
+)
+
+// Event describes one ejection or landing. Reason is the MQ status
+// description shown on the PR (e.g. "Check failed: ci/build").
+type Event struct {
+	Kind   Kind
+	Forge  forge.Forge
+	RepoID int64
+	Owner  string
+	Repo   string
+	PR     int64
+	Reason string
+}
+
+// SMTP holds the relay settings. Username empty means no AUTH.
+type SMTP struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string // RFC 5322 address, e.g. "gitea-mq <mq@example.com>"
+}
+
+// Config configures a Notifier.
+type Config struct {
+	SMTP        SMTP
+	ExternalURL string
+	// NotifyAuthors mails PR authors on eject/land.
+	NotifyAuthors bool
+	// Digest maps a repo to the addresses receiving its failure digest.
+	// Failures of repos without an entry are not recorded.
+	Digest         map[forge.RepoRef][]string
+	DigestInterval time.Duration
+	// TemplateDir optionally overrides ejected.tmpl, landed.tmpl and
+	// digest.tmpl; missing files fall back to the built-in defaults.
+	TemplateDir string
+}
+
+// eventBuffer bounds pending author mails. Notify never blocks the queue;
+// when the relay is down long enough to fill it, further mails are dropped.
+const eventBuffer = 256
+
+// Notifier delivers mail in the background. A nil *Notifier is valid and
+// discards every event, so callers need no feature check.
+type Notifier struct {
+	cfg    Config
+	queue  *queue.Service
+	tmpl   *template.Template
+	from   string // envelope sender, bare address of cfg.SMTP.From
+	events chan Event
+}
+
+// New validates cfg and loads the templates.
+func New(cfg Config, q *queue.Service) (*Notifier, error) {
+	from, err := mail.ParseAddress(cfg.SMTP.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", cfg.SMTP.From, err)
+	}
+	tmpl, err := loadTemplates(cfg.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DigestInterval <= 0 {
+		cfg.DigestInterval = 24 * time.Hour
+	}
+	return &Notifier{
+		cfg:    cfg,
+		queue:  q,
+		tmpl:   tmpl,
+		from:   from.Address,
+		events: make(chan Event, eventBuffer),
+	}, nil
+}
+
+// Notify records ev for the digest and schedules the author mail. It only
+// touches the database synchronously; forge lookups and SMTP happen in Run.
+func (n *Notifier) Notify(ctx context.Context, ev Event) {
+	if n == nil {
+		return
+	}
+	ref := forge.RefOf(ev.Forge, ev.Owner, ev.Repo)
+	if ev.Kind == Ejected && len(n.cfg.Digest[ref]) > 0 {
+		logutil.WarnIfErr(n.queue.RecordFailure(ctx, ev.RepoID, ev.PR, ev.Reason),
+			"record failure for digest failed", "repo", ref.String(), "pr", ev.PR)
+	}
+	if !n.cfg.NotifyAuthors {
+		return
+	}
+	select {
+	case n.events <- ev:
+	default:
+		slog.Warn("notification backlog full, dropping mail", "repo", ref.String(), "pr", ev.PR, "kind", ev.Kind)
+	}
+}
+
+// Run delivers author mails and sends the digest every DigestInterval until
+// ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.cfg.DigestInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-n.events:
+			n.deliver(ctx, ev)
+		case <-ticker.C:
+			logutil.WarnIfErr(n.SendDigest(ctx), "failure digest failed")
+		}
+	}
+}
+
+// Flush delivers the author mails still pending. It is for short-lived
+// callers, such as the CLI, that never Run the notifier.
+func (n *Notifier) Flush(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	for {
+		select {
+		case ev := <-n.events:
+			n.deliver(ctx, ev)
+		default:
+			return
+		}
+	}
+}
+
+// prData is the template context for ejected.tmpl and landed.tmpl.
+type prData struct {
+	Repo      string // owner/name
+	Number    int64
+	Title     string
+	Author    string
+	Reason    string
+	URL       string // PR page on the forge
+	Dashboard string // PR page on the gitea-mq dashboard
+}
+
+func (n *Notifier) deliver(ctx context.Context, ev Event) {
+	log := slog.With("repo", ev.Owner+"/"+ev.Repo, "pr", ev.PR, "kind", ev.Kind)
+
+	resolver, ok := ev.Forge.(forge.EmailResolver)
+	if !ok {
+		return
+	}
+	pr, err := ev.Forge.GetPR(ctx, ev.Owner, ev.Repo, ev.PR)
+	if err != nil {
+		log.Warn("notification: get PR failed", "error", err)
+		return
+	}
+	addr, err := resolver.UserEmail(ctx, ev.Owner, ev.Repo, pr.AuthorLogin)
+	if err != nil {
+		log.Warn("notification: resolve author e-mail failed", "author", pr.AuthorLogin, "error", err)
+		return
+	}
+	if addr == "" || isNoReply(addr) {
+		log.Debug("notification: author has no usable e-mail", "author", pr.AuthorLogin)
+		return
+	}
+
+	subject, body, err := n.render(string(ev.Kind)+".tmpl", prData{
+		Repo:      ev.Owner + "/" + ev.Repo,
+		Number:    ev.PR,
+		Title:     pr.Title,
+		Author:    pr.AuthorLogin,
+		Reason:    ev.Reason,
+		URL:       pr.HTMLURL,
+		Dashboard: forge.DashboardPRURL(n.cfg.ExternalURL, forge.RefOf(ev.Forge, ev.Owner, ev.Repo), ev.PR),
+	})
+	if err != nil {
+		log.Warn("notification: render failed", "error", err)
+		return
+	}
+	if err := n.send([]string{addr}, subject, body); err != nil {
+		log.Warn("notification: send failed", "error", err)
+		return
+	}
+	log.Info("notification sent")
+}
+
+// digestData is the template context for digest.tmpl.
+type digestData struct {
+	Repo      string // owner/name
+	Failures  []digestFailure
+	Dashboard string // repo page on the gitea-mq dashboard
+}
+
+type digestFailure struct {
+	Number int64
+	Reason string
+	At     time.Time
+	URL    string // PR page on the gitea-mq dashboard
+}
+
+// SendDigest mails every recorded failure to its repo's watchers and drops
+// the delivered rows. Rows of repos whose send failed are kept for the next
+// round; rows of repos that no longer have watchers are dropped.
+func (n *Notifier) SendDigest(ctx context.Context) error {
+	rows, err := n.queue.ListFailures(ctx)
+	if err != nil {
+		return fmt.Errorf("list failures: %w", err)
+	}
+
+	var (
+		order  []forge.RepoRef
+		groups = make(map[forge.RepoRef]*digestData)
+		ids    = make(map[forge.RepoRef][]int64)
+	)
+	for _, r := range rows {
+		ref := forge.RepoRef{Forge: forge.Kind(r.Forge), Instance: r.Instance, Owner: r.Owner, Name: r.RepoName}
+		d, ok := groups[ref]
+		if !ok {
+			d = &digestData{
+				Repo:      ref.Owner + "/" + ref.Name,
+				Dashboard: forge.DashboardRepoURL(n.cfg.ExternalURL, ref),
+			}
+			groups[ref] = d
+			order = append(order, ref)
+		}
+		d.Failures = append(d.Failures, digestFailure{
+			Number: r.PrNumber,
+			Reason: r.Reason,
+			At:     r.CreatedAt.Time,
+			URL:    forge.DashboardPRURL(n.cfg.ExternalURL, ref, r.PrNumber),
+		})
+		ids[ref] = append(ids[ref], r.ID)
+	}
+
+	var done []int64
+	for _, ref := range order {
+		if to := n.cfg.Digest[ref]; len(to) > 0 {
+			subject, body, err := n.render("digest.tmpl", groups[ref])
+			if err == nil {
+				err = n.send(to, subject, body)
+			}
+			if err != nil {
+				slog.Warn("failure digest: send failed", "repo", ref.String(), "error", err)
+				continue
+			}
+			slog.Info("failure digest sent", "repo", ref.String(), "failures", len(ids[ref]))
+		}
+		done = append(done, ids[ref]...)
+	}
+	if len(done) == 0 {
+		return nil
+	}
+	return n.queue.DeleteFailures(ctx, done)
+}
+
+// isNoReply recognises the placeholder addresses forges hand out for users
+// with a private e-mail; mail to them bounces or vanishes.
+func isNoReply(addr string) bool {
+	_, domain, _ := strings.Cut(strings.ToLower(addr), "@")
+	return strings.HasPrefix(domain, "noreply.") || strings.HasSuffix(domain, ".noreply.github.com")
+}
@@ -0,0 +1,211 @@
+package notify_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/notify"
+	"github.com/Mic92/gitea-mq/internal/testutil"
+)
+
+func newNotifier(t *testing.T, cfg notify.Config) (*notify.Notifier, *testutil.SMTPServer) {
+	t.Helper()
+	srv := testutil.NewSMTPServer(t)
+	cfg.SMTP = notify.SMTP{Addr: srv.Addr, From: "gitea-mq <mq@example.com>"}
+	cfg.ExternalURL = "https://mq.example.com"
+	n, err := notify.New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go n.Run(ctx)
+	return n, srv
+}
+
+// authors maps PR number → (login, email) for a MockForge.
+func mockForge(authors map[int64][2]string) *forge.MockForge {
+	return &forge.MockForge{
+		GetPRFn: func(_ context.Context, _, _ string, n int64) (*forge.PR, error) {
+			return &forge.PR{Number: n, Title: "Add feature", AuthorLogin: authors[n][0], HTMLURL: "https://gitea/org/app/pulls/1"}, nil
+		},
+		UserEmailFn: func(_ context.Context, _, _, login string) (string, error) {
+			for _, a := range authors {
+				if a[0] == login {
+					return a[1], nil
+				}
+			}
+			return "", nil
+		},
+	}
+}
+
+func parse(t *testing.T, m testutil.Mail) (*mail.Message, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		t.Fatalf("parse mail: %v", err)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	return msg, string(body)
+}
+
+func TestNotify_EjectedMailsAuthor(t *testing.T) {
+	n, srv := newNotifier(t, notify.Config{NotifyAuthors: true})
+	mf := mockForge(map[int64][2]string{1: {"alice", "alice@example.com"}})
+
+	n.Notify(t.Context(), notify.Event{
+		Kind: notify.Ejected, Forge: mf, Owner: "org", Repo: "app", PR: 1,
+		Reason: "Check failed: ci/build",
+	})
+
+	m := srv.WaitMessage(t)
+	if m.From != "mq@example.com" || len(m.To) != 1 || m.To[0] != "alice@example.com" {
+		t.Fatalf("envelope = %s → %v", m.From, m.To)
+	}
+	msg, body := parse(t, m)
+	if got := msg.Header.Get("Subject"); got != "[org/app] #1 removed from merge queue: Check failed: ci/build" {
+		t.Errorf("Subject = %q", got)
+	}
+	for _, want := range []string{"Hi alice", "Check failed: ci/build", "https://mq.example.com/repo/gitea/org/app/pr/1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// Authors with a hidden or placeholder address are skipped; the next event
+// is still delivered.
+func TestNotify_SkipsUnresolvableAuthors(t *testing.T) {
+	n, srv := newNotifier(t, notify.Config{NotifyAuthors: true})
+	mf := mockForge(map[int64][2]string{
+		1: {"hidden", ""},
+		2: {"private", "private@noreply.gitea.example.com"},
+		3: {"bob", "bob@example.com"},
+	})
+
+	for pr := range int64(3) {
+		n.Notify(t.Context(), notify.Event{Kind: notify.Landed, Forge: mf, Owner: "org", Repo: "app", PR: pr + 1, Reason: "Merged into main"})
+	}
+
+	m := srv.WaitMessage(t)
+	if m.To[0] != "bob@example.com" {
+		t.Fatalf("To = %v, want bob", m.To)
+	}
+	if got := len(srv.Messages()); got != 1 {
+		t.Fatalf("messages = %d, want 1", got)
+	}
+	msg, _ := parse(t, m)
+	if got := msg.Header.Get("Subject"); got != "[org/app] #3 landed: Add feature" {
+		t.Errorf("Subject = %q", got)
+	}
+}
+
+func TestNotify_TemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "landed.tmpl"),
+		[]byte("Subject: 🚀 {{.Repo}}#{{.Number}}\n\nshipped by {{.Author}}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n, srv := newNotifier(t, notify.Config{NotifyAuthors: true, TemplateDir: dir})
+	mf := mockForge(map[int64][2]string{7: {"carol", "carol@example.com"}})
+
+	n.Notify(t.Context(), notify.Event{Kind: notify.Landed, Forge: mf, Owner: "org", Repo: "app", PR: 7})
+
+	msg, body := parse(t, srv.WaitMessage(t))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "🚀 org/app#7" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if strings.TrimSpace(body) != "shipped by carol" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestNew_RejectsBrokenTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "digest.tmpl"), []byte("{{.Repo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := notify.New(notify.Config{SMTP: notify.SMTP{From: "mq@example.com"}, TemplateDir: dir}, nil)
+	if err == nil || !strings.Contains(err.Error(), "digest.tmpl") {
+		t.Fatalf("expected digest.tmpl parse error, got %v", err)
+	}
+}
+
+// Failures are only recorded for repos with digest watchers, sent grouped by
+// repo, and dropped once delivered.
+func TestSendDigest(t *testing.T) {
+	svc, ctx, repoID := testutil.TestQueueService(t)
+	srv := testutil.NewSMTPServer(t)
+	watched := forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app"}
+	n, err := notify.New(notify.Config{
+		SMTP:        notify.SMTP{Addr: srv.Addr, From: "mq@example.com"},
+		ExternalURL: "https://mq.example.com",
+		Digest:      map[forge.RepoRef][]string{watched: {"ops@example.com", "lead@example.com"}},
+	}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf := &forge.MockForge{}
+	n.Notify(ctx, notify.Event{Kind: notify.Ejected, Forge: mf, RepoID: repoID, Owner: "org", Repo: "app", PR: 1, Reason: "Check failed: ci"})
+	n.Notify(ctx, notify.Event{Kind: notify.Ejected, Forge: mf, RepoID: repoID, Owner: "org", Repo: "app", PR: 2, Reason: "Merge conflict with target branch"})
+	n.Notify(ctx, notify.Event{Kind: notify.Landed, Forge: mf, RepoID: repoID, Owner: "org", Repo: "app", PR: 3})
+	n.Notify(ctx, notify.Event{Kind: notify.Ejected, Forge: mf, RepoID: other.ID, Owner: "org", Repo: "other", PR: 4, Reason: "x"})
+
+	rows, err := svc.ListFailures(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("recorded failures = %d, want 2 (landed and unwatched repos skipped)", len(rows))
+	}
+
+	if err := n.SendDigest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m := srv.WaitMessage(t)
+	if len(m.To) != 2 {
+		t.Fatalf("To = %v", m.To)
+	}
+	msg, body := parse(t, m)
+	if got := msg.Header.Get("Subject"); got != "[org/app] merge queue: 2 failures" {
+		t.Errorf("Subject = %q", got)
+	}
+	for _, want := range []string{"#1 ", "Check failed: ci", "#2 ", "Merge conflict", "https://mq.example.com/repo/gitea/org/app"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+
+	rows, err = svc.ListFailures(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("failures after digest = %d, want 0", len(rows))
+	}
+
+	// Nothing left: no second mail.
+	if err := n.SendDigest(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(srv.Messages()); got != 1 {
+		t.Fatalf("messages = %d, want 1", got)
+	}
+}
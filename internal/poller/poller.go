@@ -5,16 +5,20 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/Mic92/gitea-mq/internal/batch"
+	"github.com/Mic92/gitea-mq/internal/eta"
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/logutil"
 	"github.com/Mic92/gitea-mq/internal/merge"
 	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/notify"
 	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/ratelimit"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -50,9 +54,15 @@ type Deps struct {
 	// work. Safe only on forges that deliver CI status via webhooks (GitHub);
 	// must stay false for Gitea/Forgejo, which have no commit-status webhook.
 	IdleGating bool
+	// Notifier mails authors when their PR lands or is ejected. Nil
+	// disables notifications.
+	Notifier *notify.Notifier
 	// Now overrides the wall clock in timeout checks; nil means time.Now.
 	// Tests use it instead of sleeping past real timeouts.
 	Now func() time.Time
+	// Updates delivers new settings after a config reload; nil if the
+	// poller is never retuned.
+	Updates <-chan Update
 	// Ticks replaces Run's periodic ticker when non-nil so tests can drive
 	// polls deterministically.
 	Ticks <-chan time.Time
@@ -82,6 +92,9 @@ type removeOpts struct {
 	advance         bool
 	logMsg          string
 	logAttrs        []any
+	// notify, when set, mails the author with reason after dequeueing.
+	notify notify.Kind
+	reason string
 }
 
 func removePR(ctx context.Context, deps *Deps, result *PollResult, entry *pg.QueueEntry, opts removeOpts) error {
@@ -102,6 +115,13 @@ func removePR(ctx context.Context, deps *Deps, result *PollResult, entry *pg.Que
 		merge.CleanupMergeBranch(ctx, deps.Forge, deps.Owner, deps.Repo, entry)
 	}
 
+	if opts.notify != "" {
+		deps.Notifier.Notify(ctx, notify.Event{
+			Kind: opts.notify, Forge: deps.Forge, RepoID: deps.RepoID,
+			Owner: deps.Owner, Repo: deps.Repo, PR: entry.PrNumber, Reason: opts.reason,
+		})
+	}
+
 	result.Dequeued = append(result.Dequeued, entry.PrNumber)
 	if opts.advance {
 		result.Advanced = append(result.Advanced, entry.PrNumber)
@@ -111,17 +131,78 @@ func removePR(ctx context.Context, deps *Deps, result *PollResult, entry *pg.Que
 	return nil
 }
 
-// prChecksGreen reports whether the PR's own head-commit checks are passing.
-// True when all required checks pass, or when no CI is configured at all.
-func prChecksGreen(ctx context.Context, deps *Deps, pr *forge.PR) (bool, error) {
-	requiredChecks, err := monitor.ResolveRequiredChecks(ctx, deps.Forge, deps.Owner, deps.Repo, pr.BaseBranch, deps.FallbackChecks)
-	if err != nil {
-		return false, fmt.Errorf("resolve required checks for PR #%d: %w", pr.Number, err)
+// Eject removes a queued PR on an operator's request. The MQ status turns to
+// error and automerge is cancelled so the next poll does not re-enqueue it;
+// the author gets a comment carrying reason. A batch the PR belongs to is
+// rebuilt without it.
+func Eject(ctx context.Context, deps *Deps, prNumber int64, reason string) error {
+	comment := "⚠️ Removed from merge queue by an operator."
+	if reason != "" {
+		comment += " Reason: " + reason
 	}
+	return eject(ctx, deps, prNumber, "Removed by operator", comment, "removed PR on operator request")
+}
+
+// EjectBy is Eject for a user who pressed the forge's "Remove from queue"
+// button; status and comment name them.
+func EjectBy(ctx context.Context, deps *Deps, prNumber int64, login string) error {
+	return eject(ctx, deps, prNumber, "Removed by @"+login,
+		fmt.Sprintf("⚠️ Removed from merge queue by @%s.", login), "removed PR on user request")
+}
 
-	checks, err := deps.Forge.GetCheckStates(ctx, deps.Owner, deps.Repo, pr.HeadSHA)
+func eject(ctx context.Context, deps *Deps, prNumber int64, desc, comment, logMsg string) error {
+	entry, err := deps.Queue.GetEntry(ctx, deps.RepoID, prNumber)
 	if err != nil {
-		return false, fmt.Errorf("get check states for PR #%d: %w", pr.Number, err)
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("PR #%d is not queued", prNumber)
+	}
+
+	targetURL := forge.DashboardPRURL(deps.ExternalURL, forge.RefOf(deps.Forge, deps.Owner, deps.Repo), prNumber)
+	logutil.WarnIfErr(deps.Forge.SetMQStatus(ctx, deps.Owner, deps.Repo, entry.PrHeadSha, forge.MQStatus{
+		State: pg.CheckStateError, Description: desc, TargetURL: targetURL,
+	}), "set mq status failed", "pr", prNumber)
+
+	var result PollResult
+	if err := removePR(ctx, deps, &result, entry, removeOpts{
+		cancelAutomerge: true,
+		comment:         comment,
+		advance:         true,
+		logMsg:          logMsg,
+		notify:          notify.Ejected,
+		reason:          desc,
+	}); err != nil {
+		return err
+	}
+	if entry.ActiveBatchID.Valid && deps.Batch != nil {
+		return deps.Batch.OnMemberRemoved(ctx, entry.TargetBranch, entry.ActiveBatchID.Int64, entry.ID)
+	}
+	return nil
+}
+
+// prChecksGreen reports whether the PR's own head-commit checks are passing.
+// True when all required checks pass, or when no CI is configured at all.
+// With a snapshot, nothing is fetched.
+func prChecksGreen(ctx context.Context, deps *Deps, pr *forge.PR, snap *forge.Snapshot) (bool, error) {
+	var requiredChecks []string
+	var checks map[string]forge.Check
+	if snap != nil {
+		requiredChecks = snap.RequiredChecks[pr.BaseBranch]
+		if len(requiredChecks) == 0 {
+			requiredChecks = deps.FallbackChecks
+		}
+		checks = snap.Checks[pr.HeadSHA]
+	} else {
+		var err error
+		requiredChecks, err = monitor.ResolveRequiredChecks(ctx, deps.Forge, deps.Owner, deps.Repo, pr.BaseBranch, deps.FallbackChecks)
+		if err != nil {
+			return false, fmt.Errorf("resolve required checks for PR #%d: %w", pr.Number, err)
+		}
+		checks, err = deps.Forge.GetCheckStates(ctx, deps.Owner, deps.Repo, pr.HeadSHA)
+		if err != nil {
+			return false, fmt.Errorf("get check states for PR #%d: %w", pr.Number, err)
+		}
 	}
 
 	// gitea-mq/* mirrors are our own output, not external CI.
@@ -145,7 +226,7 @@ func prChecksGreen(ctx context.Context, deps *Deps, pr *forge.PR) (bool, error)
 func PollOnce(ctx context.Context, deps *Deps) (*PollResult, error) {
 	result := &PollResult{}
 
-	openPRs, err := deps.Forge.ListOpenPRs(ctx, deps.Owner, deps.Repo)
+	openPRs, snap, err := listOpenPRs(ctx, deps)
 	if err != nil {
 		return &PollResult{Paused: true, Errors: []error{err}}, nil
 	}
@@ -155,7 +236,7 @@ func PollOnce(ctx context.Context, deps *Deps) (*PollResult, error) {
 		openPRMap[openPRs[i].Number] = &openPRs[i]
 	}
 
-	enqueueAutoMergePRs(ctx, deps, result, openPRs)
+	enqueueAutoMergePRs(ctx, deps, result, openPRs, snap)
 	reconcileEntries(ctx, deps, result, openPRMap)
 	startQueuedHeads(ctx, deps, result)
 	pollMergeBranchChecks(ctx, deps, result)
@@ -163,6 +244,27 @@ func PollOnce(ctx context.Context, deps *Deps) (*PollResult, error) {
 	return result, nil
 }
 
+// listOpenPRs lists the open PRs. A forge with BulkReconcile delivers them
+// as a snapshot together with their checks; snap is nil otherwise, or when
+// the snapshot failed and the plain list was used instead.
+func listOpenPRs(ctx context.Context, deps *Deps) ([]forge.PR, *forge.Snapshot, error) {
+	if br, ok := deps.Forge.(forge.BulkReconciler); ok && deps.Forge.Capabilities().BulkReconcile {
+		snap, err := br.OpenPRSnapshot(ctx, deps.Owner, deps.Repo)
+		switch {
+		case err == nil:
+			return snap.PRs, snap, nil
+		case errors.Is(err, errors.ErrUnsupported):
+			slog.Debug("open PR snapshot unsupported, listing PRs", "owner", deps.Owner, "repo", deps.Repo, "error", err)
+		case errors.Is(err, ratelimit.ErrThrottled):
+			return nil, nil, err
+		default:
+			slog.Warn("open PR snapshot failed, listing PRs", "owner", deps.Owner, "repo", deps.Repo, "error", err)
+		}
+	}
+	openPRs, err := deps.Forge.ListOpenPRs(ctx, deps.Owner, deps.Repo)
+	return openPRs, nil, err
+}
+
 // monitorDeps adapts the poller's Deps into the monitor's Deps.
 func monitorDeps(deps *Deps) *monitor.Deps {
 	m := &monitor.Deps{
@@ -174,6 +276,7 @@ func monitorDeps(deps *Deps) *monitor.Deps {
 		ExternalURL:    deps.ExternalURL,
 		CheckTimeout:   deps.CheckTimeout,
 		FallbackChecks: deps.FallbackChecks,
+		Notifier:       deps.Notifier,
 	}
 	if deps.Batch.Enabled() {
 		m.Batch = deps.Batch
@@ -266,7 +369,7 @@ func pollMergeBranchChecks(ctx context.Context, deps *Deps, result *PollResult)
 
 // enqueueAutoMergePRs adds open PRs that have auto-merge enabled and green CI
 // to the queue if they are not already tracked.
-func enqueueAutoMergePRs(ctx context.Context, deps *Deps, result *PollResult, openPRs []forge.PR) {
+func enqueueAutoMergePRs(ctx context.Context, deps *Deps, result *PollResult, openPRs []forge.PR, snap *forge.Snapshot) {
 	for i := range openPRs {
 		pr := &openPRs[i]
 		if !pr.AutoMergeEnabled {
@@ -282,7 +385,7 @@ func enqueueAutoMergePRs(ctx context.Context, deps *Deps, result *PollResult, op
 			continue
 		}
 
-		green, err := prChecksGreen(ctx, deps, pr)
+		green, err := prChecksGreen(ctx, deps, pr, snap)
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Errorf("check CI status for PR #%d: %w", pr.Number, err))
 			continue
@@ -298,8 +401,19 @@ func enqueueAutoMergePRs(ctx context.Context, deps *Deps, result *PollResult, op
 		}
 
 		if enqResult.IsNew {
+			// Gate the target branch before the PR can merge around the
+			// queue; a failure is retried with the next enqueue.
+			if g, ok := deps.Forge.(forge.BranchGuard); ok {
+				if err := g.GuardBranch(ctx, deps.Owner, deps.Repo, pr.BaseBranch); err != nil {
+					result.Errors = append(result.Errors, fmt.Errorf("guard branch %s for PR #%d: %w", pr.BaseBranch, pr.Number, err))
+				}
+			}
+
 			desc := fmt.Sprintf("Queued (position #%d)", enqResult.Position)
-			targetURL := forge.DashboardPRURL(deps.ExternalURL, deps.Forge.Kind(), deps.Owner, deps.Repo, pr.Number)
+			if at, ok := landingTime(ctx, deps, pr.Number); ok {
+				desc = fmt.Sprintf("Queued (position #%d, ETA %s)", enqResult.Position, eta.Format(at, time.Now()))
+			}
+			targetURL := forge.DashboardPRURL(deps.ExternalURL, forge.RefOf(deps.Forge, deps.Owner, deps.Repo), pr.Number)
 			if err := deps.Forge.SetMQStatus(ctx, deps.Owner, deps.Repo, pr.HeadSHA, forge.MQStatus{
 				State: pg.CheckStatePending, Description: desc, TargetURL: targetURL,
 			}); err != nil {
@@ -312,6 +426,21 @@ func enqueueAutoMergePRs(ctx context.Context, deps *Deps, result *PollResult, op
 	}
 }
 
+// landingTime estimates when the PR lands from the repo's CI history.
+func landingTime(ctx context.Context, deps *Deps, prNumber int64) (time.Time, bool) {
+	batchMax := 1
+	if deps.Batch != nil {
+		batchMax = deps.Batch.Size()
+	}
+	etas, err := eta.ForRepo(ctx, deps.Queue, deps.RepoID, batchMax, time.Now())
+	if err != nil {
+		slog.Warn("failed to estimate landing time", "pr", prNumber, "error", err)
+		return time.Time{}, false
+	}
+	at, ok := etas[prNumber]
+	return at, ok
+}
+
 // reconcileEntries removes queue entries whose PR was merged, closed,
 // retargeted, pushed to, or had auto-merge cancelled.
 func reconcileEntries(ctx context.Context, deps *Deps, result *PollResult, openPRMap map[int64]*forge.PR) {
@@ -341,7 +470,12 @@ func reconcileEntries(ctx context.Context, deps *Deps, result *PollResult, openP
 			{
 				when:  !isOpen && pr.Merged,
 				label: "merged",
-				opts:  removeOpts{advance: true, logMsg: "removed merged PR from queue"},
+				opts: removeOpts{
+					advance: true,
+					logMsg:  "removed merged PR from queue",
+					notify:  notify.Landed,
+					reason:  fmt.Sprintf("Merged into %s", entry.TargetBranch),
+				},
 			},
 			{
 				when:  !isOpen,
@@ -455,7 +589,7 @@ type timedOutRemoval struct {
 // removeTimedOut marks the entry's MQ status and queue error, then dequeues it
 // (cancelling automerge and advancing the queue).
 func removeTimedOut(ctx context.Context, deps *Deps, result *PollResult, entry *pg.QueueEntry, opts timedOutRemoval) {
-	targetURL := forge.DashboardPRURL(deps.ExternalURL, deps.Forge.Kind(), deps.Owner, deps.Repo, entry.PrNumber)
+	targetURL := forge.DashboardPRURL(deps.ExternalURL, forge.RefOf(deps.Forge, deps.Owner, deps.Repo), entry.PrNumber)
 	logutil.WarnIfErr(deps.Forge.SetMQStatus(ctx, deps.Owner, deps.Repo, entry.PrHeadSha, forge.MQStatus{
 		State: pg.CheckStateError, Description: opts.statusDescription, TargetURL: targetURL,
 	}), "set mq status failed", "pr", entry.PrNumber)
@@ -466,6 +600,8 @@ func removeTimedOut(ctx context.Context, deps *Deps, result *PollResult, entry *
 		comment:         opts.comment,
 		advance:         true,
 		logMsg:          opts.logMsg,
+		notify:          notify.Ejected,
+		reason:          opts.statusDescription,
 	}); err != nil {
 		result.Errors = append(result.Errors, fmt.Errorf("dequeue timed-out PR #%d: %w", entry.PrNumber, err))
 	}
@@ -474,6 +610,9 @@ func removeTimedOut(ctx context.Context, deps *Deps, result *PollResult, entry *
 // startQueuedHeads kicks off testing for any target branch whose head entry is
 // still in the queued state.
 func startQueuedHeads(ctx context.Context, deps *Deps, result *PollResult) {
+	// Starting a head moves the queue: it goes ahead of other forge calls
+	// when the rate limit runs low, even in an idle-priority poll.
+	ctx = ratelimit.WithPriority(ctx, ratelimit.High)
 	activeEntries, err := deps.Queue.ListActiveEntries(ctx, deps.RepoID)
 	if err != nil {
 		result.Errors = append(result.Errors, fmt.Errorf("list active entries for testing: %w", err))
@@ -513,6 +652,10 @@ func startQueuedHeads(ctx context.Context, deps *Deps, result *PollResult) {
 			continue
 		}
 		if startResult.Removed {
+			deps.Notifier.Notify(ctx, notify.Event{
+				Kind: notify.Ejected, Forge: deps.Forge, RepoID: deps.RepoID,
+				Owner: deps.Owner, Repo: deps.Repo, PR: head.PrNumber, Reason: startResult.Reason,
+			})
 			result.Dequeued = append(result.Dequeued, head.PrNumber)
 			result.Errors = append(result.Errors, fmt.Errorf("removed PR #%d from queue during testing start", head.PrNumber))
 			slog.Info("head-of-queue was removed, will retry next cycle", "pr", head.PrNumber)
@@ -536,7 +679,7 @@ func tryFastForwardSuccess(ctx context.Context, deps *Deps, result *PollResult,
 		return false
 	}
 
-	targetURL := forge.DashboardPRURL(deps.ExternalURL, deps.Forge.Kind(), deps.Owner, deps.Repo, head.PrNumber)
+	targetURL := forge.DashboardPRURL(deps.ExternalURL, forge.RefOf(deps.Forge, deps.Owner, deps.Repo), head.PrNumber)
 	logutil.WarnIfErr(deps.Forge.SetMQStatus(ctx, deps.Owner, deps.Repo, head.PrHeadSha, forge.MQStatus{
 		State: pg.CheckStateSuccess, Description: "Already up to date with target branch", TargetURL: targetURL,
 	}), "set mq status failed", "pr", head.PrNumber)
@@ -560,12 +703,42 @@ func hasActiveWork(ctx context.Context, deps *Deps) bool {
 	return len(entries) > 0
 }
 
+// logPollResult reports a periodic poll. Calls refused by the rate-limit
+// budget are not failures: they are retried next poll and only summarised
+// at debug level.
+func logPollResult(deps *Deps, result *PollResult) {
+	throttled := 0
+	for _, e := range result.Errors {
+		if errors.Is(e, ratelimit.ErrThrottled) {
+			throttled++
+		}
+	}
+	if throttled > 0 {
+		slog.Debug("poll throttled by forge rate limit", "owner", deps.Owner, "repo", deps.Repo, "calls", throttled)
+	} else if result.Paused {
+		slog.Warn("forge unavailable, pausing", "owner", deps.Owner, "repo", deps.Repo)
+	}
+	for _, e := range result.Errors {
+		if !errors.Is(e, ratelimit.ErrThrottled) {
+			slog.Warn("poll issue", "owner", deps.Owner, "repo", deps.Repo, "error", e)
+		}
+	}
+}
+
 func notifyTickDone(deps *Deps) {
 	if deps.TickDone != nil {
 		deps.TickDone <- struct{}{}
 	}
 }
 
+// Update retunes a running poller. Run applies it between polls, so a
+// reconcile in progress finishes with the settings it started with.
+type Update struct {
+	Deps         *Deps
+	Interval     time.Duration
+	IdleInterval time.Duration
+}
+
 // Run starts the polling loop. The first poll happens immediately. idleInterval
 // throttles reconciles for idle repos only when deps.IdleGating is set.
 func Run(ctx context.Context, deps *Deps, interval, idleInterval time.Duration) {
@@ -573,8 +746,13 @@ func Run(ctx context.Context, deps *Deps, interval, idleInterval time.Duration)
 
 	// periodic ticks log paused/issue diagnostics; the initial and
 	// webhook-triggered polls stay quiet to avoid log noise on bursts.
-	doPoll := func(periodic bool) {
-		result, err := PollOnce(ctx, deps)
+	// Reconciles of idle repos spend the shared rate-limit budget last.
+	doPoll := func(periodic, idle bool) {
+		pctx := ctx
+		if idle {
+			pctx = ratelimit.WithPriority(ctx, ratelimit.Low)
+		}
+		result, err := PollOnce(pctx, deps)
 		if err != nil {
 			slog.Error("poll error", "owner", deps.Owner, "repo", deps.Repo, "error", err)
 			return
@@ -582,15 +760,10 @@ func Run(ctx context.Context, deps *Deps, interval, idleInterval time.Duration)
 		if !periodic {
 			return
 		}
-		if result.Paused {
-			slog.Warn("forge unavailable, pausing", "owner", deps.Owner, "repo", deps.Repo)
-		}
-		for _, e := range result.Errors {
-			slog.Warn("poll issue", "owner", deps.Owner, "repo", deps.Repo, "error", e)
-		}
+		logPollResult(deps, result)
 	}
 
-	doPoll(false)
+	doPoll(false, false)
 
 	// Idle repos reconcile at idleInterval instead of every tick, keeping
 	// periodic forge traffic proportional to repos with live queue work.
@@ -598,8 +771,9 @@ func Run(ctx context.Context, deps *Deps, interval, idleInterval time.Duration)
 		idleInterval = interval
 	}
 	ticks := deps.Ticks
+	var ticker *time.Ticker
 	if ticks == nil {
-		ticker := time.NewTicker(interval)
+		ticker = time.NewTicker(interval)
 		defer ticker.Stop()
 		ticks = ticker.C
 	}
@@ -610,14 +784,22 @@ func Run(ctx context.Context, deps *Deps, interval, idleInterval time.Duration)
 		case <-ctx.Done():
 			slog.Info("poller stopped", "owner", deps.Owner, "repo", deps.Repo)
 			return
+		case u := <-deps.Updates:
+			deps = u.Deps
+			interval, idleInterval = u.Interval, max(u.IdleInterval, u.Interval)
+			if ticker != nil {
+				ticker.Reset(interval)
+			}
+			slog.Info("poller retuned", "owner", deps.Owner, "repo", deps.Repo, "interval", interval, "idle_interval", idleInterval)
 		case <-deps.Trigger:
-			doPoll(false)
+			doPoll(false, false)
 			lastFull = time.Now()
 			notifyTickDone(deps)
 		case <-ticks:
-			skipIdle := deps.IdleGating && !hasActiveWork(ctx, deps) && time.Since(lastFull) < idleInterval
+			idle := !hasActiveWork(ctx, deps)
+			skipIdle := deps.IdleGating && idle && time.Since(lastFull) < idleInterval
 			if !skipIdle {
-				doPoll(true)
+				doPoll(true, idle)
 				lastFull = time.Now()
 			}
 			notifyTickDone(deps)
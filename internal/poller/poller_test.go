@@ -2,10 +2,12 @@ package poller_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/gitea"
 	"github.com/Mic92/gitea-mq/internal/merge"
 	"github.com/Mic92/gitea-mq/internal/poller"
@@ -619,3 +621,99 @@ func TestRun_TriggerPollsIdleRepo(t *testing.T) {
 		t.Fatalf("triggered idle repo polled forge %d times, want 2", listed)
 	}
 }
+
+// An Update swaps the deps between polls; the next tick already uses them.
+func TestRun_UpdateSwapsDeps(t *testing.T) {
+	deps, mock, _, ctx, _ := setupPollerTest(t)
+
+	ticks := make(chan time.Time)
+	tickDone := make(chan struct{})
+	updates := make(chan poller.Update)
+	deps.Ticks = ticks
+	deps.TickDone = tickDone
+	deps.Updates = updates
+
+	var owners []string
+	mock.ListOpenPRsFn = func(_ context.Context, owner, _ string) ([]gitea.PR, error) {
+		owners = append(owners, owner)
+		return nil, nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		poller.Run(runCtx, deps, time.Hour, time.Hour)
+		close(done)
+	}()
+
+	next := *deps
+	next.Owner = "retuned"
+	updates <- poller.Update{Deps: &next, Interval: time.Minute, IdleInterval: time.Hour}
+	ticks <- time.Now()
+	<-tickDone
+	cancel()
+	<-done
+
+	if len(owners) != 2 || owners[1] != "retuned" {
+		t.Fatalf("polled owners = %v, want the retuned deps on the tick after the update", owners)
+	}
+}
+
+// A forge with BulkReconcile gates enqueueing on its snapshot, without
+// per-PR lookups; a failed snapshot falls back to them.
+func TestPollOnce_Snapshot(t *testing.T) {
+	for _, snapshotFails := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fails=%v", snapshotFails), func(t *testing.T) {
+			svc, ctx, repoID := testutil.TestQueueService(t)
+			pr := forge.PR{Number: 42, State: "open", HeadSHA: "sha42", BaseBranch: "main", AutoMergeEnabled: true}
+			green := map[string]forge.Check{"ci/build": {State: pg.CheckStateSuccess}}
+			f := &forge.MockForge{
+				CapabilitiesVal: forge.Capabilities{BulkReconcile: true},
+				OpenPRSnapshotFn: func(context.Context, string, string) (*forge.Snapshot, error) {
+					if snapshotFails {
+						return nil, errors.New("graphql: 502 Bad Gateway")
+					}
+					return &forge.Snapshot{
+						PRs:            []forge.PR{pr},
+						Checks:         map[string]map[string]forge.Check{"sha42": green},
+						RequiredChecks: map[string][]string{"main": {"ci/build"}},
+					}, nil
+				},
+				ListOpenPRsFn: func(context.Context, string, string) ([]forge.PR, error) {
+					return []forge.PR{pr}, nil
+				},
+				GetRequiredChecksFn: func(context.Context, string, string, string) ([]string, error) {
+					return []string{"ci/build"}, nil
+				},
+				GetCheckStatesFn: func(context.Context, string, string, string) (map[string]forge.Check, error) {
+					return green, nil
+				},
+				CreateMergeBranchFn: func(context.Context, string, string, string, string, string) (string, bool, error) {
+					return "merge-sha", false, nil
+				},
+			}
+			deps := &poller.Deps{Forge: f, Queue: svc, RepoID: repoID, Owner: "org", Repo: "app", SuccessTimeout: 5 * time.Minute}
+
+			result, err := poller.PollOnce(ctx, deps)
+			if err != nil {
+				t.Fatalf("PollOnce: %v", err)
+			}
+			if len(result.Enqueued) != 1 {
+				t.Fatalf("enqueued = %v, errors = %v", result.Enqueued, result.Errors)
+			}
+			if calls := f.CallsTo("GuardBranch"); len(calls) != 1 || calls[0].Args[2] != "main" {
+				t.Errorf("GuardBranch calls = %+v, want one for main", calls)
+			}
+			var perPR int
+			for _, c := range f.CallsTo("GetCheckStates") {
+				if c.Args[2] == "sha42" {
+					perPR++
+				}
+			}
+			perPR += len(f.CallsTo("ListOpenPRs"))
+			if snapshotFails != (perPR > 0) {
+				t.Errorf("per-PR lookups = %d with failing snapshot %v", perPR, snapshotFails)
+			}
+		})
+	}
+}
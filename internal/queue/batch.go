@@ -127,6 +127,13 @@ func (s *Service) SaveBatch(ctx context.Context, b *pg.Batch) error {
 	return nil
 }
 
+// AppendBatchStep appends one JSON-encoded build outcome to the batch's
+// history. SaveBatch leaves the column alone, so steps are never lost to a
+// stale in-memory row.
+func (s *Service) AppendBatchStep(ctx context.Context, batchID int64, step []byte) error {
+	return s.queries().AppendBatchStep(ctx, pg.AppendBatchStepParams{Step: step, ID: batchID})
+}
+
 // GetEntriesByIDs loads queue entries by primary key, preserving the input
 // order so callers can rely on FIFO member ordering.
 func (s *Service) GetEntriesByIDs(ctx context.Context, ids []int64) ([]pg.QueueEntry, error) {
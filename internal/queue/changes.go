@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// changesChannel is the NOTIFY channel the 006 migration triggers publish on.
+const changesChannel = "gitea_mq_changes"
+
+// WatchChanges holds a dedicated connection LISTENing for queue, batch and
+// check status changes and calls fn with the affected repo ID for each one.
+// Because the notifications come from triggers, writes made by other
+// gitea-mq instances sharing the database are seen too. It returns when ctx
+// is cancelled or the connection fails; callers reconnect.
+func (s *Service) WatchChanges(ctx context.Context, fn func(repoID int64)) error {
+	return s.listen(ctx, changesChannel, func(payload string) {
+		if repoID, err := strconv.ParseInt(payload, 10, 64); err == nil {
+			fn(repoID)
+		}
+	})
+}
+
+// listen LISTENs on channel over a dedicated connection and calls fn with
+// every payload until ctx is cancelled or the connection fails.
+func (s *Service) listen(ctx context.Context, channel string, fn func(payload string)) error {
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire listen connection: %w", err)
+	}
+	// A connection that was LISTENing must not go back to the pool.
+	conn := pooled.Hijack()
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
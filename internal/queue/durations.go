@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DurationHistory is how far back CI durations are kept and considered.
+const DurationHistory = 30 * 24 * time.Hour
+
+// RecordBuild adds a finished merge-branch build of size PRs to the
+// duration history. Builds that never recorded a start time are skipped.
+// History older than DurationHistory is pruned on the way.
+func (s *Service) RecordBuild(ctx context.Context, repoID int64, targetBranch string, size int, passed bool, started pgtype.Timestamptz) error {
+	if !started.Valid {
+		return nil
+	}
+	q := s.queries()
+	now := time.Now()
+	if err := q.DeleteOldDurations(ctx, pgtype.Timestamptz{Time: now.Add(-DurationHistory), Valid: true}); err != nil {
+		return err
+	}
+	return q.RecordBuildDuration(ctx, pg.RecordBuildDurationParams{
+		RepoID:       repoID,
+		TargetBranch: targetBranch,
+		Size:         int32(size),
+		Passed:       passed,
+		DurationMs:   now.Sub(started.Time).Milliseconds(),
+	})
+}
+
+// RecentBuilds returns up to limit builds of the repo within
+// DurationHistory, newest first.
+func (s *Service) RecentBuilds(ctx context.Context, repoID int64, limit int) ([]pg.BuildDuration, error) {
+	return s.queries().ListRecentBuilds(ctx, pg.ListRecentBuildsParams{
+		RepoID:  repoID,
+		Since:   pgtype.Timestamptz{Time: time.Now().Add(-DurationHistory), Valid: true},
+		MaxRows: int32(limit),
+	})
+}
+
+// CheckDurations returns the median run time of every CI context seen on the
+// repo's merge branches within DurationHistory.
+func (s *Service) CheckDurations(ctx context.Context, repoID int64) (map[string]time.Duration, error) {
+	rows, err := s.queries().ListCheckDurationMedians(ctx, pg.ListCheckDurationMediansParams{
+		RepoID: repoID,
+		Since:  pgtype.Timestamptz{Time: time.Now().Add(-DurationHistory), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]time.Duration, len(rows))
+	for _, r := range rows {
+		out[r.Context] = time.Duration(r.MedianMs) * time.Millisecond
+	}
+	return out, nil
+}
@@ -0,0 +1,28 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// RecordFailure appends an ejection to the digest ledger. Rows live until a
+// digest containing them was delivered (see DeleteFailures).
+func (s *Service) RecordFailure(ctx context.Context, repoID, prNumber int64, reason string) error {
+	return s.queries().RecordFailure(ctx, pg.RecordFailureParams{
+		RepoID:   repoID,
+		PrNumber: prNumber,
+		Reason:   reason,
+	})
+}
+
+// ListFailures returns every undelivered failure across all repos, grouped
+// by repo and ordered oldest first.
+func (s *Service) ListFailures(ctx context.Context) ([]pg.ListFailuresRow, error) {
+	return s.queries().ListFailures(ctx)
+}
+
+// DeleteFailures drops delivered failures from the digest ledger.
+func (s *Service) DeleteFailures(ctx context.Context, ids []int64) error {
+	return s.queries().DeleteFailures(ctx, ids)
+}
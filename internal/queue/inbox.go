@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// inboxChannel wakes inbox workers, possibly in another replica, when a
+// delivery is stored.
+const inboxChannel = "gitea_mq_inbox"
+
+// StoreDelivery persists a verified webhook delivery. headers is a JSON
+// object of the (redacted) request headers. It returns false if a delivery
+// with the same ID was stored before.
+func (s *Service) StoreDelivery(ctx context.Context, forge, deliveryID, event, repo string, headers, payload []byte) (bool, error) {
+	n, err := s.queries().InsertDelivery(ctx, pg.InsertDeliveryParams{
+		Forge:      forge,
+		DeliveryID: deliveryID,
+		Event:      event,
+		Repo:       repo,
+		Headers:    headers,
+		Payload:    payload,
+	})
+	if err != nil || n == 0 {
+		return false, err
+	}
+	// Workers also poll, so a lost wake-up only delays processing.
+	_, _ = s.pool.Exec(ctx, "SELECT pg_notify($1, '')", inboxChannel)
+	return true, nil
+}
+
+// ClaimDelivery takes the next delivery that is due, leasing it for lease.
+// It returns nil when there is nothing to do.
+func (s *Service) ClaimDelivery(ctx context.Context, lease time.Duration) (*pg.WebhookDelivery, error) {
+	d, err := s.queries().ClaimDelivery(ctx, pgtype.Timestamptz{Time: time.Now().Add(lease), Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CompleteDelivery marks a delivery as processed.
+func (s *Service) CompleteDelivery(ctx context.Context, id int64) error {
+	return s.queries().CompleteDelivery(ctx, id)
+}
+
+// FailDelivery records a failed attempt. The delivery is retried at retryAt
+// unless it has used up maxAttempts, in which case it is dead-lettered.
+func (s *Service) FailDelivery(ctx context.Context, id int64, cause error, retryAt time.Time, maxAttempts int) error {
+	return s.queries().FailDelivery(ctx, pg.FailDeliveryParams{
+		ID:          id,
+		LastError:   pgtype.Text{String: cause.Error(), Valid: true},
+		RetryAt:     pgtype.Timestamptz{Time: retryAt, Valid: true},
+		MaxAttempts: int32(maxAttempts),
+	})
+}
+
+// InboxStats returns the number of deliveries waiting to be processed and
+// the number that were dead-lettered.
+func (s *Service) InboxStats(ctx context.Context) (pending, dead int64, err error) {
+	row, err := s.queries().CountDeliveries(ctx)
+	return row.Pending, row.Dead, err
+}
+
+// ListDeadDeliveries returns the most recently dead-lettered deliveries.
+func (s *Service) ListDeadDeliveries(ctx context.Context, limit int) ([]pg.WebhookDelivery, error) {
+	return s.queries().ListDeadDeliveries(ctx, int32(limit))
+}
+
+// ListDeliveries returns the most recently received deliveries.
+func (s *Service) ListDeliveries(ctx context.Context, limit int) ([]pg.WebhookDelivery, error) {
+	return s.queries().ListDeliveries(ctx, int32(limit))
+}
+
+// GetDelivery returns a stored delivery, or nil if it does not exist (any
+// more).
+func (s *Service) GetDelivery(ctx context.Context, id int64) (*pg.WebhookDelivery, error) {
+	d, err := s.queries().GetDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// PruneDeliveries drops finished deliveries received before before.
+func (s *Service) PruneDeliveries(ctx context.Context, before time.Time) error {
+	return s.queries().DeleteOldDeliveries(ctx, pgtype.Timestamptz{Time: before, Valid: true})
+}
+
+// WatchDeliveries calls fn whenever a delivery is stored, until ctx is
+// cancelled or the connection fails; callers reconnect.
+func (s *Service) WatchDeliveries(ctx context.Context, fn func()) error {
+	return s.listen(ctx, inboxChannel, func(string) { fn() })
+}
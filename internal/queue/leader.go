@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// leaderLockKey is the session advisory lock held by the leader replica.
+const leaderLockKey int64 = 0x67697465615f6d71 // "gitea_mq"
+
+// Lead tries to become the leader among all gitea-mq processes sharing the
+// database. If another process holds leadership it returns false at once.
+// Otherwise it runs fn with a context that lives as long as leadership: the
+// lock is tied to a dedicated connection, checked every interval, and fn's
+// context is cancelled when that connection fails or does not answer within
+// interval (Postgres then releases the lock for another replica) or ctx
+// ends. Lead waits for fn to return
+// before giving up the lock.
+func (s *Service) Lead(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) (bool, error) {
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire leader connection: %w", err)
+	}
+	// The lock belongs to the session; never hand it back to the pool.
+	conn := pooled.Hijack()
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockKey).Scan(&locked); err != nil {
+		return false, fmt.Errorf("try leader lock: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+
+	termCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(termCtx)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return true, nil
+		case <-ctx.Done():
+			<-done
+			return true, nil
+		case <-ticker.C:
+			// A ping stuck on a half-open connection must not outlive the
+			// interval: Postgres may already have handed the lock to
+			// another replica.
+			pingCtx, cancelPing := context.WithTimeout(ctx, interval)
+			err := conn.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				cancel()
+				<-done
+				return true, fmt.Errorf("leader connection lost: %w", err)
+			}
+		}
+	}
+}
+
+// SetRepoManaged records whether the leader currently manages the repo.
+func (s *Service) SetRepoManaged(ctx context.Context, repoID int64, managed bool) error {
+	return s.queries().SetRepoManaged(ctx, pg.SetRepoManagedParams{ID: repoID, Managed: managed})
+}
+
+// ListManagedRepos returns the repos the leader manages.
+func (s *Service) ListManagedRepos(ctx context.Context) ([]pg.Repo, error) {
+	return s.queries().ListManagedRepos(ctx)
+}
@@ -0,0 +1,24 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// RecordCreatedRule remembers that gitea-mq created the branch-protection
+// rule, so tearing the repo down deletes it again.
+func (s *Service) RecordCreatedRule(ctx context.Context, repoID int64, rule string) error {
+	return s.queries().RecordCreatedRule(ctx, pg.RecordCreatedRuleParams{RepoID: repoID, Rule: rule})
+}
+
+// CreatedRules returns the branch-protection rules gitea-mq created on the
+// repo.
+func (s *Service) CreatedRules(ctx context.Context, repoID int64) ([]string, error) {
+	return s.queries().ListCreatedRules(ctx, repoID)
+}
+
+// ForgetCreatedRules drops the record once the rules are deleted.
+func (s *Service) ForgetCreatedRules(ctx context.Context, repoID int64) error {
+	return s.queries().DeleteCreatedRules(ctx, repoID)
+}
@@ -5,9 +5,12 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -293,14 +296,30 @@ func (s *Service) GetEntry(ctx context.Context, repoID, prNumber int64) (*pg.Que
 	return &entry, nil
 }
 
-// SaveCheckStatus records or updates a check status for an entry.
+// SaveCheckStatus records or updates a check status for an entry. When the
+// check leaves pending, the time since it was first seen is added to the
+// repo's CI duration history.
 func (s *Service) SaveCheckStatus(ctx context.Context, entryID int64, checkContext string, state pg.CheckState, targetURL string) error {
-	return s.queries().SaveCheckStatus(ctx, pg.SaveCheckStatusParams{
+	cs, err := s.queries().SaveCheckStatus(ctx, pg.SaveCheckStatusParams{
 		QueueEntryID: entryID,
 		Context:      checkContext,
 		State:        state,
 		TargetUrl:    targetURL,
 	})
+	if err != nil {
+		return err
+	}
+	// completed_at equals updated_at only in the statement that set it. A
+	// check first seen already finished has no measurable duration.
+	if !cs.CompletedAt.Valid || !cs.CompletedAt.Time.Equal(cs.UpdatedAt.Time) ||
+		!cs.CompletedAt.Time.After(cs.FirstSeenAt.Time) {
+		return nil
+	}
+	return s.queries().RecordCheckDuration(ctx, pg.RecordCheckDurationParams{
+		Context:      checkContext,
+		DurationMs:   cs.CompletedAt.Time.Sub(cs.FirstSeenAt.Time).Milliseconds(),
+		QueueEntryID: entryID,
+	})
 }
 
 // GetCheckStatuses returns all check statuses for a queue entry.
@@ -313,15 +332,43 @@ func (s *Service) ListActiveEntries(ctx context.Context, repoID int64) ([]pg.Que
 	return s.queries().ListActiveEntriesByRepo(ctx, repoID)
 }
 
+// TargetBranches returns the sorted target branches of a repo's active
+// entries.
+func (s *Service) TargetBranches(ctx context.Context, repoID int64) ([]string, error) {
+	entries, err := s.ListActiveEntries(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, e := range entries {
+		branches = append(branches, e.TargetBranch)
+	}
+	slices.Sort(branches)
+	return slices.Compact(branches), nil
+}
+
 // GetOrCreateRepo ensures a repo row exists and returns it.
-func (s *Service) GetOrCreateRepo(ctx context.Context, forge, owner, name string) (pg.Repo, error) {
+func (s *Service) GetOrCreateRepo(ctx context.Context, ref forge.RepoRef) (pg.Repo, error) {
 	return s.queries().GetOrCreateRepo(ctx, pg.GetOrCreateRepoParams{
-		Forge: forge,
-		Owner: owner,
-		Name:  name,
+		Forge:    string(ref.Forge),
+		Instance: ref.Instance,
+		Owner:    ref.Owner,
+		Name:     ref.Name,
 	})
 }
 
+// GetRepo returns the repo row with the given ID, or nil if there is none.
+func (s *Service) GetRepo(ctx context.Context, id int64) (*pg.Repo, error) {
+	repo, err := s.queries().GetRepo(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &repo, nil
+}
+
 // DequeueAll removes all queue entries for a repo. Used when a repo is
 // removed from the registry to avoid leaving orphaned entries in the DB.
 func (s *Service) DequeueAll(ctx context.Context, repoID int64) error {
@@ -2,8 +2,11 @@ package queue_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/merge"
 	"github.com/Mic92/gitea-mq/internal/queue"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
@@ -137,8 +140,8 @@ func TestQueueIsolation(t *testing.T) {
 	svc := queue.NewService(pool)
 	ctx := t.Context()
 
-	repoA, _ := svc.GetOrCreateRepo(ctx, "gitea", "org", "app-a")
-	repoB, _ := svc.GetOrCreateRepo(ctx, "gitea", "org", "app-b")
+	repoA, _ := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app-a"})
+	repoB, _ := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app-b"})
 
 	// Different repos.
 	if _, err := svc.Enqueue(ctx, repoA.ID, 1, "sha", "main"); err != nil {
@@ -226,11 +229,11 @@ func TestGetOrCreateRepo_ForgeDisambiguates(t *testing.T) {
 	svc := queue.NewService(pool)
 	ctx := context.Background()
 
-	a, err := svc.GetOrCreateRepo(ctx, "gitea", "org", "app")
+	a, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app"})
 	if err != nil {
 		t.Fatalf("gitea: %v", err)
 	}
-	b, err := svc.GetOrCreateRepo(ctx, "github", "org", "app")
+	b, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"})
 	if err != nil {
 		t.Fatalf("github: %v", err)
 	}
@@ -239,7 +242,7 @@ func TestGetOrCreateRepo_ForgeDisambiguates(t *testing.T) {
 	}
 
 	// Idempotent on the (forge, owner, name) tuple.
-	a2, err := svc.GetOrCreateRepo(ctx, "gitea", "org", "app")
+	a2, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -318,3 +321,225 @@ func TestPosition(t *testing.T) {
 		t.Fatalf("unknown PR position = %d, want 0", pos)
 	}
 }
+
+// Triggers announce writes to queue_entries, batches and check_statuses with
+// the owning repo's ID.
+func TestWatchChanges(t *testing.T) {
+	svc, ctx, repoID := testutil.TestQueueService(t)
+
+	changes := make(chan int64, 64)
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- svc.WatchChanges(watchCtx, func(id int64) { changes <- id }) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	expect := func(what string) {
+		t.Helper()
+		for {
+			select {
+			case id := <-changes:
+				if id == repoID {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: no change notification for repo %d", what, repoID)
+			}
+		}
+	}
+
+	// The LISTEN may not be active yet; retry the first write until it is seen.
+	for i := int64(1); ; i++ {
+		if _, err := svc.Enqueue(ctx, repoID, i, "sha", "main"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+		case <-time.After(200 * time.Millisecond):
+			continue
+		}
+		break
+	}
+
+	entry := testutil.EnqueueTesting(t, svc, repoID, 100, "sha100", "mergesha")
+	expect("enqueue")
+	for len(changes) > 0 {
+		<-changes
+	}
+	if err := svc.SaveCheckStatus(ctx, entry.ID, "ci", pg.CheckStateSuccess, ""); err != nil {
+		t.Fatal(err)
+	}
+	expect("check status")
+	if _, err := svc.FormBatch(ctx, repoID, "main", 0); err != nil {
+		t.Fatal(err)
+	}
+	expect("batch")
+}
+
+// Durations feed the landing-time estimate: a check measured from pending to
+// done lands in the history, one first seen already finished does not.
+func TestDurationHistory(t *testing.T) {
+	svc, ctx, repoID := testutil.TestQueueService(t)
+	entry := testutil.EnqueueTesting(t, svc, repoID, 1, "sha1", "merge1")
+
+	if err := svc.SaveCheckStatus(ctx, entry.ID, "ci/build", pg.CheckStatePending, ""); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	for range 2 { // repeated terminal reports are recorded once
+		if err := svc.SaveCheckStatus(ctx, entry.ID, "ci/build", pg.CheckStateSuccess, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.SaveCheckStatus(ctx, entry.ID, "ci/lint", pg.CheckStateSuccess, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	durations, err := svc.CheckDurations(ctx, repoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(durations) != 1 || durations["ci/build"] < 20*time.Millisecond {
+		t.Errorf("check durations = %v, want only ci/build >= 20ms", durations)
+	}
+
+	if err := svc.RecordBuild(ctx, repoID, "main", 3, false, entry.TestingStartedAt); err != nil {
+		t.Fatal(err)
+	}
+	builds, err := svc.RecentBuilds(ctx, repoID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 1 || builds[0].Size != 3 || builds[0].Passed || builds[0].TargetBranch != "main" {
+		t.Errorf("recent builds = %+v", builds)
+	}
+}
+
+// Only one replica may lead: a second Lead must give up while the first
+// term runs and win once it has ended.
+func TestLead(t *testing.T) {
+	svc, ctx, _ := testutil.TestQueueService(t)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		won, err := svc.Lead(ctx, time.Second, func(context.Context) {
+			close(entered)
+			<-release
+		})
+		if err == nil && !won {
+			t.Error("first Lead did not win")
+		}
+		first <- err
+	}()
+	<-entered
+
+	won, err := svc.Lead(ctx, time.Second, func(context.Context) {
+		t.Error("second Lead ran while the first held leadership")
+	})
+	if err != nil || won {
+		t.Fatalf("second Lead: won=%v err=%v", won, err)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+
+	ran := false
+	won, err = svc.Lead(ctx, time.Second, func(context.Context) { ran = true })
+	if err != nil || !won || !ran {
+		t.Fatalf("Lead after release: won=%v ran=%v err=%v", won, ran, err)
+	}
+}
+
+// The inbox de-duplicates by delivery ID, applies a repo's deliveries in
+// order, and dead-letters a delivery once its attempts are used up.
+func TestInbox(t *testing.T) {
+	svc, ctx, _ := testutil.TestQueueService(t)
+
+	store := func(id, repo string) {
+		t.Helper()
+		if _, err := svc.StoreDelivery(ctx, "gitea", id, "status", repo, []byte(`{}`), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store("a1", "gitea:org/a")
+	store("a2", "gitea:org/a")
+	store("b1", "gitea:org/b")
+	if stored, err := svc.StoreDelivery(ctx, "gitea", "a1", "status", "gitea:org/a", []byte(`{}`), []byte(`{}`)); err != nil || stored {
+		t.Fatalf("redelivery: stored=%v err=%v", stored, err)
+	}
+
+	claim := func() *pg.WebhookDelivery {
+		t.Helper()
+		d, err := svc.ClaimDelivery(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// a2 waits behind a1 even though a worker is free.
+	first, second := claim(), claim()
+	if first == nil || first.DeliveryID != "a1" || second == nil || second.DeliveryID != "b1" {
+		t.Fatalf("claimed %+v, %+v; want a1, b1", first, second)
+	}
+	if d := claim(); d != nil {
+		t.Fatalf("claimed %s while a1 is in flight", d.DeliveryID)
+	}
+
+	// a1 fails for good: it is dead-lettered and unblocks a2.
+	if err := svc.FailDelivery(ctx, first.ID, errors.New("boom"), time.Now(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CompleteDelivery(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if d := claim(); d == nil || d.DeliveryID != "a2" {
+		t.Fatalf("claimed %+v, want a2", d)
+	}
+
+	pending, dead, err := svc.InboxStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 1 || dead != 1 {
+		t.Errorf("pending=%d dead=%d, want 1 and 1", pending, dead)
+	}
+	deadList, err := svc.ListDeadDeliveries(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadList) != 1 || deadList[0].LastError.String != "boom" {
+		t.Errorf("dead deliveries = %+v", deadList)
+	}
+
+	// Pruning keeps the still pending a2.
+	if err := svc.PruneDeliveries(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := svc.ListDeliveries(ctx, 10); err != nil || len(all) != 1 || all[0].DeliveryID != "a2" {
+		t.Errorf("after prune: %+v err=%v", all, err)
+	}
+}
+
+func TestShadowActions(t *testing.T) {
+	svc, ctx, repoID := testutil.TestQueueService(t)
+
+	for _, m := range []string{"SetMQStatus", "FastForward"} {
+		if err := svc.RecordShadowAction(ctx, repoID, 7, m, "detail"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	actions, err := svc.ShadowActions(ctx, repoID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Method != "FastForward" || actions[0].PrNumber != 7 {
+		t.Errorf("shadow actions = %+v, want the FastForward only", actions)
+	}
+}
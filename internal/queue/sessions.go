@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CreateSession stores a dashboard login and prunes expired ones.
+// sealedToken is the user's forge token, encrypted by the caller.
+func (s *Service) CreateSession(ctx context.Context, id, forge, login, sealedToken string, expires time.Time) error {
+	if err := s.queries().DeleteExpiredSessions(ctx); err != nil {
+		return err
+	}
+	return s.queries().CreateSession(ctx, pg.CreateSessionParams{
+		ID:          id,
+		Forge:       forge,
+		Login:       login,
+		SealedToken: sealedToken,
+		ExpiresAt:   pgtype.Timestamptz{Time: expires, Valid: true},
+	})
+}
+
+// GetSession returns an unexpired session, or nil if there is none.
+func (s *Service) GetSession(ctx context.Context, id string) (*pg.Session, error) {
+	sess, err := s.queries().GetSession(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// DeleteSession logs a session out.
+func (s *Service) DeleteSession(ctx context.Context, id string) error {
+	return s.queries().DeleteSession(ctx, id)
+}
@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ShadowHistory is how long the forge writes skipped in shadow mode are
+// kept for the dashboard.
+const ShadowHistory = 7 * 24 * time.Hour
+
+// RecordShadowAction logs a forge write that shadow mode did not perform.
+// prNumber is 0 when the write concerns no single PR. Entries older than
+// ShadowHistory are pruned on the way.
+func (s *Service) RecordShadowAction(ctx context.Context, repoID, prNumber int64, method, detail string) error {
+	q := s.queries()
+	if err := q.DeleteOldShadowActions(ctx, pgtype.Timestamptz{Time: time.Now().Add(-ShadowHistory), Valid: true}); err != nil {
+		return err
+	}
+	return q.RecordShadowAction(ctx, pg.RecordShadowActionParams{
+		RepoID:   repoID,
+		PrNumber: prNumber,
+		Method:   method,
+		Detail:   detail,
+	})
+}
+
+// ShadowActions returns up to limit skipped writes of the repo, newest
+// first.
+func (s *Service) ShadowActions(ctx context.Context, repoID int64, limit int) ([]pg.ShadowAction, error) {
+	return s.queries().ListShadowActions(ctx, pg.ListShadowActionsParams{
+		RepoID:  repoID,
+		MaxRows: int32(limit),
+	})
+}
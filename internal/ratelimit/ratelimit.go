@@ -0,0 +1,221 @@
+// Package ratelimit shares a forge's request budget between every repo that
+// uses it. A Budget follows the rate-limit headers the forge sends and backs
+// off when it answers 429 or a rate-limited 403; while the budget runs low,
+// calls are let through by priority, so head-of-queue work still lands
+// while idle reconciles wait for the next window.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Priority orders calls competing for a Budget.
+type Priority int
+
+const (
+	// Low is for idle reconciles. Throttled Low calls fail at once.
+	Low Priority = iota - 1
+	// Normal is the default.
+	Normal
+	// High is for head-of-queue work and FastForward. It may spend the
+	// reserve the other priorities leave.
+	High
+)
+
+type priorityKey struct{}
+
+// WithPriority marks the forge calls made with ctx.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityOf returns the priority ctx was marked with, Normal by default.
+func PriorityOf(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// ErrThrottled is returned instead of making a call the budget cannot
+// afford. It is not a forge failure: the call is retried in a later poll.
+var ErrThrottled = errors.New("rate limited")
+
+const (
+	// lowReserve and normalReserve are the fractions of the limit below
+	// which Low and Normal calls wait for the next window.
+	lowReserve    = 0.2
+	normalReserve = 0.05
+	// maxNormalWait bounds how long a Normal call waits before it gives
+	// up with ErrThrottled. High calls wait as long as their context lets
+	// them.
+	maxNormalWait = 30 * time.Second
+	minBackoff    = time.Second
+	maxBackoff    = 5 * time.Minute
+)
+
+// Budget is the request budget of one forge server, or of one GitHub App
+// installation. It is safe for concurrent use.
+type Budget struct {
+	name string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	limit     int // -1 while unknown
+	remaining int
+	reset     time.Time // when remaining refills
+	// backoffUntil blocks every call after a 429 or rate-limited 403;
+	// backoff is the next exponential step when the forge names no time.
+	backoffUntil time.Time
+	backoff      time.Duration
+}
+
+// NewBudget returns an unconstrained budget; name appears in errors.
+func NewBudget(name string) *Budget {
+	return &Budget{name: name, now: time.Now, limit: -1, backoff: minBackoff}
+}
+
+// blockedUntil returns when a call of priority p may go ahead; zero when it
+// may now. Callers hold b.mu.
+func (b *Budget) blockedUntil(p Priority) time.Time {
+	now := b.now()
+	if now.Before(b.backoffUntil) {
+		return b.backoffUntil
+	}
+	if b.limit <= 0 || !now.Before(b.reset) {
+		return time.Time{}
+	}
+	reserve := 0
+	switch p {
+	case Low:
+		reserve = int(float64(b.limit) * lowReserve)
+	case Normal:
+		reserve = int(float64(b.limit) * normalReserve)
+	}
+	if b.remaining > reserve {
+		return time.Time{}
+	}
+	return b.reset
+}
+
+// Wait blocks until the budget affords a call with ctx's priority. Low
+// calls do not wait and Normal calls only briefly; both get ErrThrottled
+// instead.
+func (b *Budget) Wait(ctx context.Context) error {
+	p := PriorityOf(ctx)
+	for {
+		b.mu.Lock()
+		until := b.blockedUntil(p)
+		if until.IsZero() {
+			// Count the call now so concurrent callers see it.
+			if b.remaining > 0 {
+				b.remaining--
+			}
+			b.mu.Unlock()
+			return nil
+		}
+		wait := until.Sub(b.now())
+		b.mu.Unlock()
+
+		if p == Low || (p == Normal && wait > maxNormalWait) {
+			return fmt.Errorf("%s: %w until %s", b.name, ErrThrottled, until.Format(time.TimeOnly))
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Observe updates the budget from a response's rate-limit headers: GitHub
+// and Gitea send X-RateLimit-*, GitLab RateLimit-*. A 429, or a 403 that
+// says the limit is used up, starts a backoff until Retry-After, the reset
+// time or an exponentially growing delay.
+func (b *Budget) Observe(resp *http.Response) {
+	h := resp.Header
+	limit, okLimit := headerInt(h, "X-RateLimit-Limit", "RateLimit-Limit")
+	remaining, okRemaining := headerInt(h, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	reset, okReset := headerInt(h, "X-RateLimit-Reset", "RateLimit-Reset")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if okLimit {
+		b.limit = limit
+	}
+	if okRemaining {
+		b.remaining = remaining
+	}
+	if okReset {
+		b.reset = time.Unix(int64(reset), 0)
+	}
+
+	limited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && ((okRemaining && remaining == 0) || h.Get("Retry-After") != ""))
+	if !limited {
+		if resp.StatusCode < 400 {
+			b.backoff = minBackoff
+		}
+		return
+	}
+
+	var until time.Time
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		until = now.Add(time.Duration(secs) * time.Second)
+	} else if okReset && okRemaining && remaining == 0 && b.reset.After(now) {
+		until = b.reset
+	} else {
+		until = now.Add(b.backoff)
+		b.backoff = min(2*b.backoff, maxBackoff)
+	}
+	if until.After(b.backoffUntil) {
+		b.backoffUntil = until
+	}
+}
+
+func headerInt(h http.Header, keys ...string) (int, bool) {
+	for _, k := range keys {
+		if v := h.Get(k); v != "" {
+			n, err := strconv.Atoi(v)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Transport charges every request to a Budget.
+type Transport struct {
+	Next   http.RoundTripper
+	Budget *Budget
+}
+
+// NewTransport returns next charging b; next defaults to
+// http.DefaultTransport.
+func NewTransport(next http.RoundTripper, b *Budget) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{Next: next, Budget: b}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Budget.Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.Budget.Observe(resp)
+	return resp, nil
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fixedBudget returns a budget whose clock stands still at now.
+func fixedBudget(now time.Time) *Budget {
+	b := NewBudget("test")
+	b.now = func() time.Time { return now }
+	return b
+}
+
+func response(status int, headers map[string]string) *http.Response {
+	rec := httptest.NewRecorder()
+	for k, v := range headers {
+		rec.Header().Set(k, v)
+	}
+	rec.WriteHeader(status)
+	return rec.Result()
+}
+
+func TestBudget_ReservesForHigherPriorities(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := fixedBudget(now)
+	// 100 of 1000 left: below Low's 20% reserve, above Normal's 5%.
+	b.Observe(response(http.StatusOK, map[string]string{
+		"X-RateLimit-Limit":     "1000",
+		"X-RateLimit-Remaining": "100",
+		"X-RateLimit-Reset":     strconv.FormatInt(now.Add(time.Hour).Unix(), 10),
+	}))
+
+	ctx := context.Background()
+	if err := b.Wait(WithPriority(ctx, Low)); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Low Wait = %v, want ErrThrottled", err)
+	}
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("Normal Wait = %v, want nil", err)
+	}
+
+	b.Observe(response(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "10"}))
+	if err := b.Wait(ctx); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Normal Wait under its reserve = %v, want ErrThrottled", err)
+	}
+	if err := b.Wait(WithPriority(ctx, High)); err != nil {
+		t.Fatalf("High Wait = %v, want nil", err)
+	}
+}
+
+func TestBudget_UnknownLimitIsUnconstrained(t *testing.T) {
+	b := NewBudget("test")
+	if err := b.Wait(WithPriority(context.Background(), Low)); err != nil {
+		t.Fatalf("Wait = %v, want nil", err)
+	}
+}
+
+func TestBudget_BacksOffOn429(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := fixedBudget(now)
+	b.Observe(response(http.StatusTooManyRequests, map[string]string{"Retry-After": "120"}))
+
+	err := b.Wait(context.Background())
+	if !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Wait = %v, want ErrThrottled", err)
+	}
+	if want := now.Add(120 * time.Second); !b.backoffUntil.Equal(want) {
+		t.Fatalf("backoffUntil = %v, want %v", b.backoffUntil, want)
+	}
+
+	// High calls wait for the backoff instead of failing.
+	ctx, cancel := context.WithTimeout(WithPriority(context.Background(), High), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("High Wait = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBudget_Forbidden(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := now.Add(10 * time.Minute)
+
+	// A permission 403 is not a rate limit.
+	b := fixedBudget(now)
+	b.Observe(response(http.StatusForbidden, nil))
+	if !b.backoffUntil.IsZero() {
+		t.Fatalf("plain 403 started a backoff until %v", b.backoffUntil)
+	}
+
+	// GitHub's primary limit: 403 with nothing remaining waits for the reset.
+	b.Observe(response(http.StatusForbidden, map[string]string{
+		"X-RateLimit-Limit":     "5000",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     strconv.FormatInt(reset.Unix(), 10),
+	}))
+	if !b.backoffUntil.Equal(reset) {
+		t.Fatalf("backoffUntil = %v, want reset %v", b.backoffUntil, reset)
+	}
+}
+
+func TestBudget_ExponentialBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := fixedBudget(now)
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		b.backoffUntil = time.Time{}
+		b.Observe(response(http.StatusTooManyRequests, nil))
+		if got := b.backoffUntil.Sub(now); got != want {
+			t.Fatalf("backoff = %v, want %v", got, want)
+		}
+	}
+	b.Observe(response(http.StatusOK, nil))
+	if b.backoff != minBackoff {
+		t.Fatalf("backoff after success = %v, want %v", b.backoff, minBackoff)
+	}
+}
+
+func TestTransport_GitLabHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Limit", "100")
+		w.Header().Set("RateLimit-Remaining", "3")
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	}))
+	defer srv.Close()
+
+	b := NewBudget("gitlab")
+	hc := &http.Client{Transport: NewTransport(nil, b)}
+	resp, err := hc.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("first GET: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// 3 of 100 is under Normal's reserve and the reset is an hour away.
+	_, err = hc.Get(srv.URL)
+	if !errors.Is(err, ErrThrottled) {
+		t.Fatalf("second GET = %v, want ErrThrottled", err)
+	}
+}
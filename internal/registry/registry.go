@@ -16,22 +16,38 @@ import (
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/merge"
 	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/notify"
 	"github.com/Mic92/gitea-mq/internal/poller"
 	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/report"
+	"github.com/Mic92/gitea-mq/internal/shadow"
 	"github.com/Mic92/gitea-mq/internal/webhook"
 )
 
 type ManagedRepo struct {
-	Ref     forge.RepoRef
-	RepoID  int64
+	Ref    forge.RepoRef
+	RepoID int64
+	// Monitor is replaced by Retune; read it through LookupMonitor.
 	Monitor *webhook.RepoMonitor
+
+	forge   forge.Forge
+	batch   *batch.Engine
+	deps    *Deps        // the registry Deps m is wired for
+	poller  *poller.Deps // replaced by Retune under RepoRegistry.mu
+	updates chan poller.Update
+	term    context.Context // leadership term the poller runs under; nil if stopped
 	cancel  context.CancelFunc
 }
 
+// Deps are the registry's dependencies. The tunables from PollInterval to
+// BisectMaxSteps can change at runtime through Retune.
 type Deps struct {
 	Forges              *forge.Set
 	Queue               *queue.Service
-	WebhookSecret       string
+	WebhookSecrets      map[forge.Host]string // by forge server; a GitHub App needs none
+	BranchPatterns      []string              // gated like the default branch by auto-setup
+	CreateProtection    bool                  // auto-setup creates missing Gitea/Forgejo protection rules
+	TeardownOnRemove    bool                  // Remove undoes auto-setup on the forge
 	ExternalURL         string
 	PollInterval        time.Duration
 	IdlePollInterval    time.Duration
@@ -41,60 +57,122 @@ type Deps struct {
 	SkipQueueIfUpToDate bool
 	BatchMax            int
 	BisectMaxSteps      int
+	Notifier            *notify.Notifier
+	// ShadowRepos run in shadow mode: their forge writes are recorded
+	// instead of performed and their authors get no notifications.
+	ShadowRepos []forge.RepoRef
+	// Standby starts the registry passive: repos are tracked for the
+	// dashboard but nothing runs until Activate.
+	Standby bool
 }
 
 // RepoRegistry manages the set of active repos. Thread-safe for concurrent
 // use by the webhook handler, web dashboard, and discovery loop.
+//
+// With several replicas only the leader's registry is active, i.e. runs
+// pollers and batch engines. Followers mirror the leader's repo set from the
+// database (Sync).
 type RepoRegistry struct {
 	mu    sync.RWMutex
 	repos map[string]*ManagedRepo // keyed by forge.RepoRef.String()
+	term  context.Context         // parent of per-repo contexts; nil while passive
 
-	parentCtx context.Context
-	deps      *Deps
+	deps *Deps // replaced, never mutated, by Retune
 }
 
 // New creates a new RepoRegistry. The parentCtx is used as the parent for
-// per-repo contexts (cancelling it stops all pollers).
+// per-repo contexts (cancelling it stops all pollers). A Standby registry
+// ignores parentCtx until Activate.
 func New(parentCtx context.Context, deps *Deps) *RepoRegistry {
-	return &RepoRegistry{
-		repos:     make(map[string]*ManagedRepo),
-		parentCtx: parentCtx,
-		deps:      deps,
+	r := &RepoRegistry{
+		repos: make(map[string]*ManagedRepo),
+		deps:  deps,
+	}
+	if !deps.Standby {
+		r.term = parentCtx
 	}
+	return r
 }
 
-// Add registers a repo and starts its poller. No-op if already managed.
-// Setup (forge auto-setup, DB registration, stale-branch cleanup) runs before
-// the repo becomes visible to Lookup/List.
+// Add registers a repo and, if the registry is active, starts its poller.
+// No-op if already managed. Setup (forge auto-setup, DB registration,
+// stale-branch cleanup) runs before the repo becomes visible to Lookup/List.
 func (r *RepoRegistry) Add(ctx context.Context, ref forge.RepoRef) error {
 	key := ref.String()
 
-	r.mu.RLock()
-	_, exists := r.repos[key]
-	r.mu.RUnlock()
-
-	if exists {
+	if r.Contains(key) {
 		return nil
 	}
 
-	f, err := r.deps.Forges.For(ref)
+	managed, err := r.build(ctx, ref)
 	if err != nil {
 		return err
 	}
 
-	if err := f.EnsureRepoSetup(ctx, ref.Owner, ref.Name, forge.SetupConfig{
-		ExternalURL:   r.deps.ExternalURL,
-		WebhookSecret: r.deps.WebhookSecret,
-	}); err != nil {
-		slog.Warn("auto-setup failed", "repo", key, "error", err)
+	if term := r.claim(managed); term != nil {
+		r.start(ctx, term, managed)
+	}
+
+	r.mu.Lock()
+	if _, exists := r.repos[key]; exists {
+		// Another Add won the race; drop our duplicate poller.
+		r.mu.Unlock()
+		managed.stop()
+		return nil
+	}
+	if managed.deps != r.deps {
+		managed.retune(r.deps) // Retune ran while we were building
+	}
+	r.repos[key] = managed
+	r.mu.Unlock()
+
+	// Activate may have run between claim and insertion without seeing us.
+	if term := r.claim(managed); term != nil {
+		r.start(ctx, term, managed)
+	}
+	return nil
+}
+
+// WrapForge layers a repo's forge the way the service runs it: a shadowed
+// repo only records its writes, and forges that show a report with the
+// gitea-mq status get one. fallbackChecks returns the configured required
+// checks, which can change at runtime. The CLI uses it too, so a PR it
+// ejects is reported like one the service ejects.
+func WrapForge(f forge.Forge, svc *queue.Service, repoID int64, shadowed bool, externalURL string, fallbackChecks func() []string) forge.Forge {
+	switch {
+	case shadowed:
+		return shadow.Wrap(f, shadow.Store(svc, repoID))
+	case f.Capabilities().StatusReport:
+		return report.Wrap(f, svc, repoID, externalURL, fallbackChecks)
 	}
+	return f
+}
 
-	repo, err := r.deps.Queue.GetOrCreateRepo(ctx, string(ref.Forge), ref.Owner, ref.Name)
+// build resolves the forge and DB row of a repo and wires its monitor,
+// batch engine and poller deps without starting anything.
+func (r *RepoRegistry) build(ctx context.Context, ref forge.RepoRef) (*ManagedRepo, error) {
+	d := r.currentDeps()
+	f, err := d.Forges.For(ref)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	repo, err := d.Queue.GetOrCreateRepo(ctx, ref)
+	if err != nil {
+		return nil, err
 	}
 
-	pollerCtx, cancel := context.WithCancel(r.parentCtx)
+	notifier := d.Notifier
+	// Buttons act on the forge directly; a shadowed repo's status shows
+	// none.
+	actions, _ := f.(forge.StatusActions)
+	shadowed := slices.Contains(d.ShadowRepos, ref)
+	if shadowed {
+		notifier, actions = nil, nil
+	}
+	f = WrapForge(f, d.Queue, repo.ID, shadowed, d.ExternalURL,
+		func() []string { return r.currentDeps().FallbackChecks })
+
 	// Buffer one so a webhook never blocks; coalescing is fine because the
 	// poller reconciles full state anyway.
 	trigger := make(chan struct{}, 1)
@@ -105,45 +183,37 @@ func (r *RepoRegistry) Add(ctx context.Context, ref forge.RepoRef) error {
 		}
 	}
 
+	// Buffer one; Retune replaces a pending update rather than block.
+	updates := make(chan poller.Update, 1)
+
 	var batchEngine *batch.Engine
-	if r.deps.BatchMax != 1 {
+	if d.BatchMax != 1 {
 		batchEngine = &batch.Engine{
 			Forge:          f,
-			Queue:          r.deps.Queue,
+			Queue:          d.Queue,
 			Owner:          ref.Owner,
 			Repo:           ref.Name,
 			RepoID:         repo.ID,
-			ExternalURL:    r.deps.ExternalURL,
-			BatchMax:       r.deps.BatchMax,
-			BisectMaxSteps: r.deps.BisectMaxSteps,
-			CheckTimeout:   r.deps.CheckTimeout,
-			FallbackChecks: r.deps.FallbackChecks,
+			ExternalURL:    d.ExternalURL,
+			BatchMax:       d.BatchMax,
+			BisectMaxSteps: d.BisectMaxSteps,
+			CheckTimeout:   d.CheckTimeout,
+			FallbackChecks: d.FallbackChecks,
 			Advance:        triggerPoll,
-		}
-	}
-
-	var spare []string
-	if batchEngine != nil {
-		spare, _ = batchEngine.LiveBranchNames(ctx)
-	}
-	if err := merge.CleanupStaleBranches(ctx, f, r.deps.Queue, ref.Owner, ref.Name, repo.ID, spare); err != nil {
-		slog.Warn("stale branch cleanup failed", "repo", key, "error", err)
-	}
-	if batchEngine != nil {
-		if err := batchEngine.ReconcileLive(ctx); err != nil {
-			slog.Warn("batch reconcile failed", "repo", key, "error", err)
+			Notifier:       notifier,
 		}
 	}
 
 	monDeps := &monitor.Deps{
 		Forge:          f,
-		Queue:          r.deps.Queue,
+		Queue:          d.Queue,
 		Owner:          ref.Owner,
 		Repo:           ref.Name,
 		RepoID:         repo.ID,
-		ExternalURL:    r.deps.ExternalURL,
-		CheckTimeout:   r.deps.CheckTimeout,
-		FallbackChecks: r.deps.FallbackChecks,
+		ExternalURL:    d.ExternalURL,
+		CheckTimeout:   d.CheckTimeout,
+		FallbackChecks: d.FallbackChecks,
+		Notifier:       notifier,
 	}
 	if batchEngine != nil {
 		monDeps.Batch = batchEngine
@@ -155,42 +225,299 @@ func (r *RepoRegistry) Add(ctx context.Context, ref forge.RepoRef) error {
 		Monitor: &webhook.RepoMonitor{
 			Deps:        monDeps,
 			TriggerPoll: triggerPoll,
+			Actions:     actions,
+		},
+		forge:   f,
+		batch:   batchEngine,
+		deps:    d,
+		updates: updates,
+		poller: &poller.Deps{
+			Forge:               f,
+			Queue:               d.Queue,
+			RepoID:              repo.ID,
+			Owner:               ref.Owner,
+			Repo:                ref.Name,
+			Trigger:             trigger,
+			Updates:             updates,
+			ExternalURL:         d.ExternalURL,
+			FallbackChecks:      d.FallbackChecks,
+			SuccessTimeout:      d.SuccessTimeout,
+			CheckTimeout:        d.CheckTimeout,
+			SkipQueueIfUpToDate: d.SkipQueueIfUpToDate,
+			Batch:               batchEngine,
+			IdleGating:          f.Capabilities().StatusWebhook,
+			Notifier:            notifier,
 		},
-		cancel: cancel,
-	}
-
-	pollerDeps := &poller.Deps{
-		Forge:               f,
-		Queue:               r.deps.Queue,
-		RepoID:              repo.ID,
-		Owner:               ref.Owner,
-		Repo:                ref.Name,
-		Trigger:             trigger,
-		ExternalURL:         r.deps.ExternalURL,
-		FallbackChecks:      r.deps.FallbackChecks,
-		SuccessTimeout:      r.deps.SuccessTimeout,
-		CheckTimeout:        r.deps.CheckTimeout,
-		SkipQueueIfUpToDate: r.deps.SkipQueueIfUpToDate,
-		Batch:               batchEngine,
-		IdleGating:          f.Capabilities().StatusWebhook,
-	}
-	go poller.Run(pollerCtx, pollerDeps, r.deps.PollInterval, r.deps.IdlePollInterval)
+	}
+	if actions != nil {
+		managed.Monitor.Dequeue = func(ctx context.Context, prNumber int64, login string) error {
+			r.mu.RLock()
+			deps := managed.poller
+			r.mu.RUnlock()
+			return poller.EjectBy(ctx, deps, prNumber, login)
+		}
+	}
+	return managed, nil
+}
+
+func (r *RepoRegistry) currentDeps() *Deps {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.deps
+}
+
+// Tuning holds the Deps fields a config reload may change.
+type Tuning struct {
+	PollInterval        time.Duration
+	IdlePollInterval    time.Duration
+	CheckTimeout        time.Duration
+	FallbackChecks      []string
+	SkipQueueIfUpToDate bool
+	BatchMax            int
+	BisectMaxSteps      int
+}
 
+// Retune applies new settings to every repo and to repos added later.
+// Pollers pick them up between polls and batch engines with the next batch,
+// so work in flight is not interrupted. Switching batching on or off
+// (BatchMax 1 vs. other) changes how a repo's queue is driven and needs a
+// restart; such a change is logged and ignored. Retune returns the settings
+// it applied.
+func (r *RepoRegistry) Retune(t Tuning) Tuning {
 	r.mu.Lock()
-	if _, exists := r.repos[key]; !exists {
-		r.repos[key] = managed
-	} else {
-		// Another Add won the race; drop our duplicate poller.
-		cancel()
+	defer r.mu.Unlock()
+
+	if (t.BatchMax == 1) != (r.deps.BatchMax == 1) {
+		slog.Warn("switching batching on or off requires a restart, keeping batch size",
+			"batch_max", r.deps.BatchMax, "requested", t.BatchMax)
+		t.BatchMax = r.deps.BatchMax
+	}
+
+	d := *r.deps
+	d.PollInterval = t.PollInterval
+	d.IdlePollInterval = t.IdlePollInterval
+	d.CheckTimeout = t.CheckTimeout
+	d.FallbackChecks = t.FallbackChecks
+	d.SkipQueueIfUpToDate = t.SkipQueueIfUpToDate
+	d.BatchMax = t.BatchMax
+	d.BisectMaxSteps = t.BisectMaxSteps
+	r.deps = &d
+
+	for _, m := range r.repos {
+		m.retune(&d)
+	}
+	return t
+}
+
+// retune rewires m for d. Callers hold r.mu.
+func (m *ManagedRepo) retune(d *Deps) {
+	m.deps = d
+
+	mon := *m.Monitor.Deps
+	mon.CheckTimeout = d.CheckTimeout
+	mon.FallbackChecks = d.FallbackChecks
+	rm := *m.Monitor
+	rm.Deps = &mon
+	m.Monitor = &rm
+
+	p := *m.poller
+	p.CheckTimeout = d.CheckTimeout
+	p.FallbackChecks = d.FallbackChecks
+	p.SkipQueueIfUpToDate = d.SkipQueueIfUpToDate
+	m.poller = &p
+	m.update(poller.Update{Deps: &p, Interval: d.PollInterval, IdleInterval: d.IdlePollInterval})
+
+	if m.batch != nil {
+		m.batch.Retune(batch.Tuning{
+			BatchMax:       d.BatchMax,
+			BisectMaxSteps: d.BisectMaxSteps,
+			CheckTimeout:   d.CheckTimeout,
+			FallbackChecks: d.FallbackChecks,
+		})
+	}
+}
+
+// update hands u to the repo's poller, replacing one it has not taken yet.
+func (m *ManagedRepo) update(u poller.Update) {
+	for {
+		select {
+		case m.updates <- u:
+			return
+		default:
+		}
+		select {
+		case <-m.updates:
+		default:
+		}
+	}
+}
+
+// claim returns the current term if m still has to be started in it, and
+// marks m as started so concurrent callers do not start it twice.
+func (r *RepoRegistry) claim(m *ManagedRepo) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.term == nil || m.term == r.term {
+		return nil
+	}
+	if m.cancel != nil {
+		m.cancel() // poller of an earlier term
+	}
+	m.term = r.term
+	pollerCtx, cancel := context.WithCancel(r.term)
+	m.cancel = cancel
+	return pollerCtx
+}
+
+// start sets a repo up on the forge, resumes in-flight work and runs its
+// poller until pollerCtx ends. This is also how a new leader takes over: the
+// same cleanup and ReconcileLive path as a restart.
+func (r *RepoRegistry) start(ctx context.Context, pollerCtx context.Context, m *ManagedRepo) {
+	key := m.Ref.String()
+	owner, name := m.Ref.Owner, m.Ref.Name
+	r.mu.RLock()
+	d, pollerDeps := r.deps, m.poller
+	r.mu.RUnlock()
+
+	branches, err := d.Queue.TargetBranches(ctx, m.RepoID)
+	if err != nil {
+		slog.Warn("failed to list target branches", "repo", key, "error", err)
+	}
+	if err := m.forge.EnsureRepoSetup(ctx, owner, name, forge.SetupConfig{
+		ExternalURL:      d.ExternalURL,
+		WebhookSecret:    d.WebhookSecrets[m.Ref.Host()],
+		TargetBranches:   branches,
+		BranchPatterns:   d.BranchPatterns,
+		CreateProtection: d.CreateProtection,
+		RequiredChecks:   d.FallbackChecks,
+		PushesTargets:    d.BatchMax != 1,
+		RuleCreated: func(ctx context.Context, rule string) error {
+			return d.Queue.RecordCreatedRule(ctx, m.RepoID, rule)
+		},
+	}); err != nil {
+		slog.Warn("auto-setup failed", "repo", key, "error", err)
+	}
+
+	var spare []string
+	if m.batch != nil {
+		spare, _ = m.batch.LiveBranchNames(ctx)
+	}
+	if _, err := merge.CleanupStaleBranches(ctx, m.forge, d.Queue, owner, name, m.RepoID, spare); err != nil {
+		slog.Warn("stale branch cleanup failed", "repo", key, "error", err)
+	}
+	if m.batch != nil {
+		if err := m.batch.ReconcileLive(ctx); err != nil {
+			slog.Warn("batch reconcile failed", "repo", key, "error", err)
+		}
+	}
+
+	if err := d.Queue.SetRepoManaged(ctx, m.RepoID, true); err != nil {
+		slog.Warn("failed to mark repo managed", "repo", key, "error", err)
+	}
+
+	go poller.Run(pollerCtx, pollerDeps, d.PollInterval, d.IdlePollInterval)
+}
+
+// stop cancels the repo's poller, if any.
+func (m *ManagedRepo) stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// Activate makes the registry active for the leadership term ctx: every
+// known repo is set up and gets a poller, and so does every repo added
+// later. Everything stops when ctx ends.
+func (r *RepoRegistry) Activate(ctx context.Context) {
+	r.mu.Lock()
+	r.term = ctx
+	repos := make([]*ManagedRepo, 0, len(r.repos))
+	for _, m := range r.repos {
+		repos = append(repos, m)
 	}
 	r.mu.Unlock()
 
+	for _, m := range repos {
+		if pollerCtx := r.claim(m); pollerCtx != nil {
+			r.start(ctx, pollerCtx, m)
+		}
+	}
+}
+
+// Deactivate returns the registry to passive mode after leadership ended.
+// The repos stay registered for the dashboard and webhook forwarding.
+func (r *RepoRegistry) Deactivate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.term = nil
+	for _, m := range r.repos {
+		m.stop()
+		m.term, m.cancel = nil, nil
+	}
+}
+
+// Sync mirrors the leader's repo set into a passive registry. No-op while
+// active, where discovery owns the set.
+func (r *RepoRegistry) Sync(ctx context.Context) error {
+	d := r.currentDeps()
+	if r.active() {
+		return nil
+	}
+	rows, err := d.Queue.ListManagedRepos(ctx)
+	if err != nil {
+		return err
+	}
+	want := make(map[string]struct{}, len(rows))
+	for _, row := range rows {
+		ref := forge.RepoRef{Forge: forge.Kind(row.Forge), Instance: row.Instance, Owner: row.Owner, Name: row.Name}
+		want[ref.String()] = struct{}{}
+		if err := r.Add(ctx, ref); err != nil {
+			slog.Warn("failed to follow repo", "repo", ref, "error", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.term != nil {
+		return nil // became leader meanwhile
+	}
+	for key := range r.repos {
+		if _, ok := want[key]; !ok {
+			delete(r.repos, key)
+		}
+	}
 	return nil
 }
 
+// Follow calls Sync every interval until ctx ends.
+func (r *RepoRegistry) Follow(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sync(ctx); err != nil {
+				slog.Warn("failed to sync managed repos", "error", err)
+			}
+		}
+	}
+}
+
+func (r *RepoRegistry) active() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.term != nil
+}
+
 // Remove stops a repo's poller, cleans up merge branches and DB entries,
-// and removes the repo from the registry. No-op if the repo is not managed.
+// and removes the repo from the registry. With TeardownOnRemove it also
+// undoes the forge setup. Discovery only removes repos a successful listing
+// no longer returns and never an explicitly listed one, so a repo that is
+// merely unreachable is not stripped. No-op if the repo is not managed.
 func (r *RepoRegistry) Remove(ref forge.RepoRef) {
+	d := r.currentDeps()
 	key := ref.String()
 
 	r.mu.Lock()
@@ -204,18 +531,17 @@ func (r *RepoRegistry) Remove(ref forge.RepoRef) {
 		return
 	}
 
-	managed.cancel()
-
-	f, err := r.deps.Forges.For(ref)
-	if err != nil {
-		slog.Warn("no forge for repo on removal", "repo", key, "error", err)
+	managed.stop()
+	// A follower only forgets the repo; cleanup is the leader's business.
+	if !r.active() {
 		return
 	}
 
+	f := managed.forge
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	entries, err := r.deps.Queue.ListActiveEntries(ctx, managed.RepoID)
+	entries, err := d.Queue.ListActiveEntries(ctx, managed.RepoID)
 	if err != nil {
 		slog.Warn("failed to list entries for cleanup", "repo", key, "error", err)
 	} else {
@@ -224,12 +550,31 @@ func (r *RepoRegistry) Remove(ref forge.RepoRef) {
 		}
 	}
 
-	if err := r.deps.Queue.CancelLiveBatches(ctx, managed.RepoID); err != nil {
+	if d.TeardownOnRemove {
+		// A failure is only logged: the repo may come back, and an
+		// unreachable one is better left gated than half stripped.
+		if created, err := d.Queue.CreatedRules(ctx, managed.RepoID); err != nil {
+			slog.Warn("failed to list created protection rules", "repo", key, "error", err)
+		} else if err := f.TeardownRepoSetup(ctx, ref.Owner, ref.Name, forge.SetupConfig{
+			ExternalURL:   d.ExternalURL,
+			WebhookSecret: d.WebhookSecrets[ref.Host()],
+			CreatedRules:  created,
+		}); err != nil {
+			slog.Warn("forge teardown failed", "repo", key, "error", err)
+		} else if err := d.Queue.ForgetCreatedRules(ctx, managed.RepoID); err != nil {
+			slog.Warn("failed to forget created protection rules", "repo", key, "error", err)
+		}
+	}
+
+	if err := d.Queue.CancelLiveBatches(ctx, managed.RepoID); err != nil {
 		slog.Warn("failed to cancel batches on removal", "repo", key, "error", err)
 	}
-	if err := r.deps.Queue.DequeueAll(ctx, managed.RepoID); err != nil {
+	if err := d.Queue.DequeueAll(ctx, managed.RepoID); err != nil {
 		slog.Warn("failed to dequeue entries on removal", "repo", key, "error", err)
 	}
+	if err := d.Queue.SetRepoManaged(ctx, managed.RepoID, false); err != nil {
+		slog.Warn("failed to mark repo unmanaged", "repo", key, "error", err)
+	}
 
 	slog.Info("removed repo from registry", "repo", key)
 }
@@ -245,7 +590,10 @@ func (r *RepoRegistry) Lookup(key string) (*ManagedRepo, bool) {
 
 // LookupMonitor implements webhook.RepoLookup.
 func (r *RepoRegistry) LookupMonitor(key string) (*webhook.RepoMonitor, bool) {
-	m, ok := r.Lookup(key)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.repos[key]
 	if !ok {
 		return nil, false
 	}
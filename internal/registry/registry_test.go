@@ -107,6 +107,42 @@ func TestAdd_RoutesByForgeKind(t *testing.T) {
 	}
 }
 
+// A shadowed repo is set up without touching the forge; the skipped setup
+// shows up in the repo's shadow log instead.
+func TestAdd_Shadow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	queueSvc := queue.NewService(testutil.TestDB(t))
+	mock := &forge.MockForge{KindVal: forge.KindGithub}
+	forges := forge.NewSet()
+	forges.Register(mock)
+
+	ref := forge.RepoRef{Forge: forge.KindGithub, Owner: "org", Name: "app"}
+	reg := registry.New(ctx, &registry.Deps{
+		Forges:       forges,
+		Queue:        queueSvc,
+		PollInterval: 1 * time.Hour,
+		CheckTimeout: 1 * time.Hour,
+		ShadowRepos:  []forge.RepoRef{ref},
+	})
+	if err := reg.Add(ctx, ref); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	if n := len(mock.CallsTo("EnsureRepoSetup")); n != 0 {
+		t.Errorf("EnsureRepoSetup calls = %d, want 0 in shadow mode", n)
+	}
+	m, _ := reg.Lookup(ref.String())
+	actions, err := queueSvc.ShadowActions(ctx, m.RepoID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Method != "EnsureRepoSetup" {
+		t.Errorf("shadow actions = %+v, want the skipped EnsureRepoSetup", actions)
+	}
+}
+
 func TestAddIdempotent(t *testing.T) {
 	reg, ctx := newTestRegistry(t)
 	ref := giteaRef("org", "app")
@@ -244,3 +280,98 @@ func TestConcurrentAccess(t *testing.T) {
 		t.Errorf("expected 10 repos, got %d", len(reg.List()))
 	}
 }
+
+// A standby registry tracks repos without claiming them; activation marks
+// them managed so other replicas can mirror the set.
+func TestStandbyUntilActivated(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	pool := testutil.TestDB(t)
+	queueSvc := queue.NewService(pool)
+
+	forges := forge.NewSet()
+	forges.Register(gitea.NewForge(&gitea.MockClient{}, "https://gitea.example.com"))
+
+	reg := registry.New(ctx, &registry.Deps{
+		Forges:         forges,
+		Queue:          queueSvc,
+		PollInterval:   1 * time.Hour,
+		CheckTimeout:   1 * time.Hour,
+		SuccessTimeout: 5 * time.Minute,
+		Standby:        true,
+	})
+
+	if err := reg.Add(ctx, giteaRef("org", "app")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, ok := reg.LookupMonitor("gitea:org/app"); !ok {
+		t.Fatal("standby registry must still expose the repo")
+	}
+
+	managed, err := queueSvc.ListManagedRepos(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(managed) != 0 {
+		t.Fatalf("standby must not claim repos, got %d managed", len(managed))
+	}
+
+	term, endTerm := context.WithCancel(ctx)
+	defer endTerm()
+	reg.Activate(term)
+	managed, err = queueSvc.ListManagedRepos(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(managed) != 1 {
+		t.Fatalf("expected the repo to be managed after Activate, got %d", len(managed))
+	}
+
+	// A second standby replica mirrors the leader's set.
+	follower := registry.New(ctx, &registry.Deps{
+		Forges:       forges,
+		Queue:        queueSvc,
+		PollInterval: 1 * time.Hour,
+		CheckTimeout: 1 * time.Hour,
+		Standby:      true,
+	})
+	if err := follower.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !follower.Contains("gitea:org/app") {
+		t.Error("follower did not pick up the leader's repo")
+	}
+}
+
+// Retune rewires managed repos and repos added afterwards.
+func TestRetune(t *testing.T) {
+	reg, ctx := newTestRegistry(t)
+	if err := reg.Add(ctx, giteaRef("org", "app")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	reg.Retune(registry.Tuning{
+		PollInterval:   time.Hour,
+		CheckTimeout:   2 * time.Hour,
+		FallbackChecks: []string{"ci/build"},
+		BatchMax:       1,
+	})
+	if err := reg.Add(ctx, giteaRef("org", "lib")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	for _, key := range []string{"gitea:org/app", "gitea:org/lib"} {
+		mon, ok := reg.LookupMonitor(key)
+		if !ok {
+			t.Fatalf("%s not found", key)
+		}
+		if mon.Deps.CheckTimeout != 2*time.Hour || len(mon.Deps.FallbackChecks) != 1 {
+			t.Errorf("%s: CheckTimeout=%v FallbackChecks=%v", key, mon.Deps.CheckTimeout, mon.Deps.FallbackChecks)
+		}
+	}
+
+	if got := reg.Retune(registry.Tuning{PollInterval: time.Hour, BatchMax: 5}); got.BatchMax != 1 {
+		t.Errorf("BatchMax = %d, switching batching on must wait for a restart", got.BatchMax)
+	}
+}
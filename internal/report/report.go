@@ -0,0 +1,308 @@
+// Package report renders the markdown shown with the gitea-mq status on
+// forges that have room for it (Capabilities.StatusReport): where the PR is
+// in the queue, the batch it is tested in, its required checks, the
+// bisection so far and why it was removed. Forge wraps a forge.Forge and
+// attaches the report to every SetMQStatus, so the poller, monitor and batch
+// engine need not know about it.
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Mic92/gitea-mq/internal/batch"
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// Data is everything a report shows about one PR.
+type Data struct {
+	PR           int64
+	State        pg.CheckState // of the status the report goes with
+	Description  string
+	TargetBranch string
+	Position     int64 // 1-based; 0 when not shown
+	Batch        *Batch
+	Checks       []pg.CheckStatus // required checks of the running build
+}
+
+// Batch is the PR's batch as the report shows it.
+type Batch struct {
+	ID      int64
+	Members []Member
+	History []batch.Step
+}
+
+// Member is a PR of a batch and where it stands.
+type Member struct {
+	PR     int64
+	Bucket batch.MemberBucket
+}
+
+var bucketText = map[batch.MemberBucket]string{
+	batch.BucketCurrent: "testing",
+	batch.BucketPending: "waiting to be retested",
+	batch.BucketLanded:  "merged",
+	batch.BucketEjected: "removed",
+}
+
+// Render formats d as markdown. prURL links a PR number.
+func Render(d Data, prURL func(int64) string) string {
+	var b strings.Builder
+	link := func(n int64) string {
+		if n == d.PR {
+			return fmt.Sprintf("**#%d** (this PR)", n)
+		}
+		return fmt.Sprintf("[#%d](%s)", n, prURL(n))
+	}
+
+	switch d.State {
+	case pg.CheckStateFailure, pg.CheckStateError:
+		fmt.Fprintf(&b, "### %s Removed from the queue\n\n%s\n\n", icon(d.State), d.Description)
+	case pg.CheckStateSuccess:
+		fmt.Fprintf(&b, "### %s Passed the queue\n\n%s\n\n", icon(d.State), d.Description)
+	default:
+		if d.Position > 0 {
+			fmt.Fprintf(&b, "### Queue\n\nPosition **%d** in the `%s` queue.\n\n", d.Position, d.TargetBranch)
+		}
+	}
+
+	if d.Batch != nil {
+		fmt.Fprintf(&b, "### Batch #%d\n\n| PR | Status |\n|---|---|\n", d.Batch.ID)
+		for _, m := range d.Batch.Members {
+			fmt.Fprintf(&b, "| %s | %s |\n", link(m.PR), bucketText[m.Bucket])
+		}
+		b.WriteString("\n")
+
+		if len(d.Batch.History) > 0 {
+			b.WriteString("### Bisection\n\n| Build | PRs | Result |\n|---|---|---|\n")
+			for _, s := range d.Batch.History {
+				prs := make([]string, len(s.PRs))
+				for i, n := range s.PRs {
+					prs[i] = link(n)
+				}
+				result := icon(pg.CheckStateSuccess) + " passed"
+				if !s.Passed {
+					result = icon(pg.CheckStateFailure) + " " + checkLink(s.Check, s.URL)
+				}
+				fmt.Fprintf(&b, "| %d | %s | %s |\n", s.Build, strings.Join(prs, ", "), result)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(d.Checks) > 0 {
+		b.WriteString("### Required checks\n\n| Check | State |\n|---|---|\n")
+		for _, c := range d.Checks {
+			fmt.Fprintf(&b, "| %s | %s %s |\n", checkLink(c.Context, c.TargetUrl), icon(c.State), c.State)
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+func icon(s pg.CheckState) string {
+	switch s {
+	case pg.CheckStateSuccess:
+		return "✅"
+	case pg.CheckStateFailure:
+		return "❌"
+	case pg.CheckStateError:
+		return "⚠️"
+	default:
+		return "⏳"
+	}
+}
+
+func checkLink(name, url string) string {
+	if url == "" {
+		return "`" + name + "`"
+	}
+	return fmt.Sprintf("[`%s`](%s)", name, url)
+}
+
+// Forge attaches a report to every gitea-mq status of one repo. Only wrap
+// adapters with Capabilities.StatusReport: the wrapper does not implement
+// forge.MergeStacker.
+type Forge struct {
+	forge.Forge
+	queue          *queue.Service
+	repoID         int64
+	externalURL    string
+	fallbackChecks func() []string
+
+	mu   sync.Mutex
+	last map[string]*pending // head SHA → pending status last set
+}
+
+// pending is a pending status and the mirrored check states its report has
+// seen, so polls that mirror unchanged checks cost no report.
+type pending struct {
+	st     forge.MQStatus
+	checks map[string]forge.CheckState
+}
+
+var (
+	_ forge.Forge          = (*Forge)(nil)
+	_ forge.Instanced      = (*Forge)(nil)
+	_ forge.EmailResolver  = (*Forge)(nil)
+	_ forge.BulkReconciler = (*Forge)(nil)
+	_ forge.BranchGuard    = (*Forge)(nil)
+)
+
+// Wrap returns f with reports for the repo repoID. fallbackChecks returns
+// the configured required checks, which can change at runtime.
+func Wrap(f forge.Forge, svc *queue.Service, repoID int64, externalURL string, fallbackChecks func() []string) *Forge {
+	return &Forge{
+		Forge:          f,
+		queue:          svc,
+		repoID:         repoID,
+		externalURL:    externalURL,
+		fallbackChecks: fallbackChecks,
+		last:           map[string]*pending{},
+	}
+}
+
+// Instance forwards to the wrapped adapter so repo refs stay the same.
+func (r *Forge) Instance() string { return forge.HostOf(r.Forge).Instance }
+
+// UserEmail forwards to the wrapped adapter for notifications.
+func (r *Forge) UserEmail(ctx context.Context, owner, name, login string) (string, error) {
+	if e, ok := r.Forge.(forge.EmailResolver); ok {
+		return e.UserEmail(ctx, owner, name, login)
+	}
+	return "", nil
+}
+
+// OpenPRSnapshot forwards to the wrapped adapter for the poller.
+func (r *Forge) OpenPRSnapshot(ctx context.Context, owner, name string) (*forge.Snapshot, error) {
+	if b, ok := r.Forge.(forge.BulkReconciler); ok {
+		return b.OpenPRSnapshot(ctx, owner, name)
+	}
+	return nil, errors.ErrUnsupported
+}
+
+// GuardBranch forwards to the wrapped adapter for the poller.
+func (r *Forge) GuardBranch(ctx context.Context, owner, name, branch string) error {
+	if g, ok := r.Forge.(forge.BranchGuard); ok {
+		return g.GuardBranch(ctx, owner, name, branch)
+	}
+	return nil
+}
+
+// SetMQStatus sets st with a fresh report. A pending status is remembered
+// so MirrorCheck can refresh its report as checks come in.
+func (r *Forge) SetMQStatus(ctx context.Context, owner, name, sha string, st forge.MQStatus) error {
+	st.Report = r.report(ctx, owner, name, sha, st, nil)
+	err := r.Forge.SetMQStatus(ctx, owner, name, sha, st)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil && st.State == pg.CheckStatePending {
+		r.last[sha] = &pending{st: st, checks: map[string]forge.CheckState{}}
+	} else {
+		delete(r.last, sha)
+	}
+	return err
+}
+
+// MirrorCheck mirrors c and, when its state is new and changes the report,
+// re-sends the pending status of sha with it. The check has not reached the
+// ledger yet (the monitor records it after mirroring), so it is laid over
+// the recorded ones.
+func (r *Forge) MirrorCheck(ctx context.Context, owner, name, sha, checkContext string, c forge.Check) error {
+	err := r.Forge.MirrorCheck(ctx, owner, name, sha, checkContext, c)
+
+	if c.State == forge.CheckState("skipped") || !strings.HasPrefix(checkContext, forge.MirrorContextPrefix) {
+		return err
+	}
+	r.mu.Lock()
+	p, ok := r.last[sha]
+	if !ok || p.checks[checkContext] == c.State {
+		r.mu.Unlock()
+		return err
+	}
+	p.checks[checkContext] = c.State
+	st := p.st
+	r.mu.Unlock()
+
+	mirrored := &pg.CheckStatus{
+		Context:   strings.TrimPrefix(checkContext, forge.MirrorContextPrefix),
+		State:     c.State,
+		TargetUrl: c.TargetURL,
+	}
+	report := r.report(ctx, owner, name, sha, st, mirrored)
+	if report == st.Report {
+		return err
+	}
+	st.Report = report
+	if serr := r.Forge.SetMQStatus(ctx, owner, name, sha, st); serr != nil {
+		slog.Warn("refresh status report failed", "sha", sha, "error", serr)
+		return err
+	}
+	r.mu.Lock()
+	if p, still := r.last[sha]; still {
+		p.st = st
+	}
+	r.mu.Unlock()
+	return err
+}
+
+// report gathers Data for the queue entry whose head is sha. It returns ""
+// when sha is not queued; lookup failures leave their section out.
+func (r *Forge) report(ctx context.Context, owner, name, sha string, st forge.MQStatus, mirrored *pg.CheckStatus) string {
+	entries, err := r.queue.ListActiveEntries(ctx, r.repoID)
+	if err != nil {
+		slog.Warn("load queue for status report failed", "sha", sha, "error", err)
+		return ""
+	}
+	i := slices.IndexFunc(entries, func(e pg.QueueEntry) bool { return e.PrHeadSha == sha })
+	if i < 0 {
+		return ""
+	}
+	entry := &entries[i]
+	d := Data{PR: entry.PrNumber, State: st.State, Description: st.Description, TargetBranch: entry.TargetBranch}
+
+	if st.State == pg.CheckStatePending {
+		if d.Position, err = r.queue.Position(ctx, r.repoID, entry.TargetBranch, entry.PrNumber); err != nil {
+			slog.Warn("queue position for status report failed", "pr", entry.PrNumber, "error", err)
+		}
+	}
+
+	if entry.ActiveBatchID.Valid {
+		if b, _ := r.queue.GetBatch(ctx, entry.ActiveBatchID.Int64); b != nil {
+			d.Batch = &Batch{ID: b.ID, History: batch.History(b)}
+			members, _ := r.queue.GetEntriesByIDs(ctx, b.MemberIds)
+			for _, m := range members {
+				d.Batch.Members = append(d.Batch.Members, Member{PR: m.PrNumber, Bucket: batch.Bucket(b, m.ID)})
+			}
+		}
+	}
+
+	// Checks belong to the build under test; queued PRs have none.
+	if entry.MergeBranchSha.Valid {
+		recorded, err := r.queue.GetCheckStatuses(ctx, entry.ID)
+		if err != nil {
+			slog.Warn("load checks for status report failed", "pr", entry.PrNumber, "error", err)
+		}
+		if mirrored != nil {
+			recorded = slices.DeleteFunc(recorded, func(c pg.CheckStatus) bool { return c.Context == mirrored.Context })
+			recorded = append(recorded, *mirrored)
+		}
+		required, err := monitor.ResolveRequiredChecks(ctx, r.Forge, owner, name, entry.TargetBranch, r.fallbackChecks())
+		if err != nil {
+			slog.Warn("resolve required checks for status report failed", "pr", entry.PrNumber, "error", err)
+		}
+		d.Checks = monitor.MergeCheckStatuses(recorded, required)
+	}
+
+	ref := forge.RefOf(r, owner, name)
+	return Render(d, func(n int64) string { return forge.DashboardPRURL(r.externalURL, ref, n) })
+}
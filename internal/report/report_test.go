@@ -0,0 +1,78 @@
+package report_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/batch"
+	"github.com/Mic92/gitea-mq/internal/report"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+func prURL(n int64) string { return fmt.Sprintf("http://mq/pr/%d", n) }
+
+func TestRender_Testing(t *testing.T) {
+	got := report.Render(report.Data{
+		PR:           20,
+		State:        pg.CheckStatePending,
+		TargetBranch: "main",
+		Position:     1,
+		Batch: &report.Batch{
+			ID: 3,
+			Members: []report.Member{
+				{PR: 10, Bucket: batch.BucketLanded},
+				{PR: 20, Bucket: batch.BucketCurrent},
+				{PR: 30, Bucket: batch.BucketPending},
+			},
+			History: []batch.Step{
+				{Build: 1, PRs: []int64{10, 20, 30}, Check: "ci/test", URL: "http://ci/1"},
+				{Build: 2, PRs: []int64{10}, Passed: true},
+			},
+		},
+		Checks: []pg.CheckStatus{
+			{Context: "ci/build", State: pg.CheckStateSuccess, TargetUrl: "http://ci/b"},
+			{Context: "ci/test", State: pg.CheckStatePending},
+		},
+	}, prURL)
+
+	for _, want := range []string{
+		"Position **1** in the `main` queue.",
+		"### Batch #3",
+		"| [#10](http://mq/pr/10) | merged |",
+		"| **#20** (this PR) | testing |",
+		"| [#30](http://mq/pr/30) | waiting to be retested |",
+		"| 1 | [#10](http://mq/pr/10), **#20** (this PR), [#30](http://mq/pr/30) | ❌ [`ci/test`](http://ci/1) |",
+		"| 2 | [#10](http://mq/pr/10) | ✅ passed |",
+		"| [`ci/build`](http://ci/b) | ✅ success |",
+		"| `ci/test` | ⏳ pending |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Removed") {
+		t.Errorf("pending report claims removal:\n%s", got)
+	}
+}
+
+func TestRender_Ejected(t *testing.T) {
+	got := report.Render(report.Data{
+		PR:          20,
+		State:       pg.CheckStateFailure,
+		Description: "Check failed: ci/test",
+		Position:    2,
+	}, prURL)
+	if !strings.HasPrefix(got, "### ❌ Removed from the queue\n\nCheck failed: ci/test") {
+		t.Errorf("report does not lead with the reason:\n%s", got)
+	}
+	if strings.Contains(got, "Position") {
+		t.Errorf("removed PR shows a queue position:\n%s", got)
+	}
+}
+
+func TestRender_NothingToSay(t *testing.T) {
+	if got := report.Render(report.Data{PR: 1, State: pg.CheckStatePending}, prURL); got != "" {
+		t.Errorf("Render = %q, want empty", got)
+	}
+}
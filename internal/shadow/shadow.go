@@ -0,0 +1,315 @@
+// Package shadow runs repos in dry-run mode. Forge wraps a forge.Forge so
+// that every write is logged and recorded instead of performed, while reads
+// pass through; the poller, monitor and batch engine run unchanged on top.
+//
+// So that the queue moves on as it would for real, the wrapper folds the
+// writes it skipped into later reads: a PR whose head was reported green
+// reads as merged, a closed PR as closed and a cancelled auto-merge as
+// cancelled. Merge branches are not built; the PR head stands in for them,
+// so the queue waits for the PR's own CI and never sees a conflict.
+package shadow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/logutil"
+	"github.com/Mic92/gitea-mq/internal/queue"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// Action is a forge write a shadowed repo did not perform.
+type Action struct {
+	PR     int64  // 0 when the write concerns no single PR
+	Method string // the forge.Forge method, e.g. "FastForward"
+	Detail string
+}
+
+// Recorder stores an Action.
+type Recorder func(ctx context.Context, a Action)
+
+// Store returns a Recorder that writes to the repo's shadow log in the
+// database, where the dashboard reads it.
+func Store(svc *queue.Service, repoID int64) Recorder {
+	return func(ctx context.Context, a Action) {
+		logutil.WarnIfErr(svc.RecordShadowAction(ctx, repoID, a.PR, a.Method, a.Detail),
+			"record shadow action failed", "method", a.Method)
+	}
+}
+
+// Forge is a forge.Forge whose writes are recorded, not performed. It does
+// not implement forge.MergeStacker, so the batch engine builds batches
+// through CreateMergeBranch and MergeInto, which are shadowed too.
+type Forge struct {
+	forge.Forge
+	record Recorder
+
+	mu        sync.Mutex
+	prs       map[string]int64  // head SHA → PR, from the last reads
+	heads     map[int64]string  // PR → head SHA
+	merged    map[string]bool   // heads reported green: the forge would merge them
+	closed    map[int64]bool    // PRs closed without merging
+	cancelled map[int64]string  // PR → head SHA its auto-merge was cancelled at
+	statuses  map[string]string // "<sha> <context>" → last state set
+}
+
+var (
+	_ forge.Forge          = (*Forge)(nil)
+	_ forge.Instanced      = (*Forge)(nil)
+	_ forge.EmailResolver  = (*Forge)(nil)
+	_ forge.BulkReconciler = (*Forge)(nil)
+	_ forge.BranchGuard    = (*Forge)(nil)
+)
+
+// Wrap returns f in shadow mode; every skipped write is passed to record.
+func Wrap(f forge.Forge, record Recorder) *Forge {
+	return &Forge{
+		Forge:     f,
+		record:    record,
+		prs:       map[string]int64{},
+		heads:     map[int64]string{},
+		merged:    map[string]bool{},
+		closed:    map[int64]bool{},
+		cancelled: map[int64]string{},
+		statuses:  map[string]string{},
+	}
+}
+
+// Instance forwards to the wrapped adapter so repo refs stay the same.
+func (s *Forge) Instance() string { return forge.HostOf(s.Forge).Instance }
+
+// UserEmail forwards to the wrapped adapter. Notifications are off for
+// shadowed repos, but the interface keeps them possible.
+func (s *Forge) UserEmail(ctx context.Context, owner, name, login string) (string, error) {
+	if r, ok := s.Forge.(forge.EmailResolver); ok {
+		return r.UserEmail(ctx, owner, name, login)
+	}
+	return "", nil
+}
+
+func (s *Forge) skip(ctx context.Context, a Action) {
+	slog.Info("shadow mode: skipped forge write", "method", a.Method, "pr", a.PR, "detail", a.Detail)
+	s.record(ctx, a)
+}
+
+// ListOpenPRs drops PRs the queue merged or closed and clears auto-merge
+// where the queue cancelled it. What is remembered about PRs that are no
+// longer open is forgotten.
+func (s *Forge) ListOpenPRs(ctx context.Context, owner, name string) ([]forge.PR, error) {
+	prs, err := s.Forge.ListOpenPRs(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.openPRs(prs), nil
+}
+
+// OpenPRSnapshot folds the skipped writes into the snapshot's PRs like
+// ListOpenPRs.
+func (s *Forge) OpenPRSnapshot(ctx context.Context, owner, name string) (*forge.Snapshot, error) {
+	b, ok := s.Forge.(forge.BulkReconciler)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	snap, err := b.OpenPRSnapshot(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	snap.PRs = s.openPRs(snap.PRs)
+	return snap, nil
+}
+
+func (s *Forge) openPRs(prs []forge.PR) []forge.PR {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prs, s.heads = map[string]int64{}, map[int64]string{}
+	for _, pr := range prs {
+		s.prs[pr.HeadSHA], s.heads[pr.Number] = pr.Number, pr.HeadSHA
+	}
+	for sha := range s.merged {
+		if _, open := s.prs[sha]; !open {
+			delete(s.merged, sha)
+		}
+	}
+	for n := range s.closed {
+		if _, open := s.heads[n]; !open {
+			delete(s.closed, n)
+		}
+	}
+	for n := range s.cancelled {
+		if _, open := s.heads[n]; !open {
+			delete(s.cancelled, n)
+		}
+	}
+	for key := range s.statuses {
+		sha, _, _ := strings.Cut(key, " ")
+		if _, open := s.prs[sha]; !open {
+			delete(s.statuses, key)
+		}
+	}
+
+	out := prs[:0]
+	for _, pr := range prs {
+		if s.merged[pr.HeadSHA] || s.closed[pr.Number] {
+			continue
+		}
+		s.fold(&pr)
+		out = append(out, pr)
+	}
+	return out
+}
+
+// GetPR folds the skipped writes into pr like ListOpenPRs.
+func (s *Forge) GetPR(ctx context.Context, owner, name string, number int64) (*forge.PR, error) {
+	pr, err := s.Forge.GetPR(ctx, owner, name, number)
+	if err != nil || pr.State != "open" {
+		return pr, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prs[pr.HeadSHA], s.heads[pr.Number] = pr.Number, pr.HeadSHA
+	switch {
+	case s.merged[pr.HeadSHA]:
+		pr.State, pr.Merged = "closed", true
+	case s.closed[pr.Number]:
+		pr.State = "closed"
+	}
+	s.fold(pr)
+	return pr, nil
+}
+
+// fold clears auto-merge on a PR the queue cancelled it on. New commits
+// lift the cancellation: the author would have re-scheduled by then.
+// Callers hold s.mu.
+func (s *Forge) fold(pr *forge.PR) {
+	sha, ok := s.cancelled[pr.Number]
+	switch {
+	case !ok:
+	case sha == "" || sha == pr.HeadSHA:
+		s.cancelled[pr.Number] = pr.HeadSHA
+		pr.AutoMergeEnabled = false
+	default:
+		delete(s.cancelled, pr.Number)
+	}
+}
+
+func (s *Forge) prFor(sha string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prs[sha]
+}
+
+// setStatus reports whether state differs from what was last set for key,
+// so repeated identical statuses (every poll mirrors every check) are
+// recorded once.
+func (s *Forge) setStatus(key, state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statuses[key] == state {
+		return false
+	}
+	s.statuses[key] = state
+	return true
+}
+
+// SetMQStatus records the status. Success means the forge would now merge
+// the PR, so its head reads as merged from here on.
+func (s *Forge) SetMQStatus(ctx context.Context, owner, name, sha string, st forge.MQStatus) error {
+	if st.State == pg.CheckStateSuccess {
+		s.mu.Lock()
+		s.merged[sha] = true
+		s.mu.Unlock()
+	}
+	if !s.setStatus(sha+" "+forge.MQContext, string(st.State)+" "+st.Description) {
+		return nil
+	}
+	s.skip(ctx, Action{PR: s.prFor(sha), Method: "SetMQStatus",
+		Detail: fmt.Sprintf("set %s to %s on %s: %s", forge.MQContext, st.State, short(sha), st.Description)})
+	return nil
+}
+
+func (s *Forge) MirrorCheck(ctx context.Context, owner, name, sha, checkContext string, c forge.Check) error {
+	if !s.setStatus(sha+" "+checkContext, string(c.State)) {
+		return nil
+	}
+	s.skip(ctx, Action{PR: s.prFor(sha), Method: "MirrorCheck",
+		Detail: fmt.Sprintf("set %s to %s on %s", checkContext, c.State, short(sha))})
+	return nil
+}
+
+// CreateMergeBranch returns headSHA as the merge result.
+func (s *Forge) CreateMergeBranch(ctx context.Context, owner, name, base, headSHA, branch string) (string, bool, error) {
+	s.skip(ctx, Action{PR: s.prFor(headSHA), Method: "CreateMergeBranch",
+		Detail: fmt.Sprintf("create %s from %s and merge %s", branch, base, short(headSHA))})
+	return headSHA, false, nil
+}
+
+// MergeInto returns headSHA as the new tip.
+func (s *Forge) MergeInto(ctx context.Context, owner, name, branch, headSHA string) (string, bool, error) {
+	s.skip(ctx, Action{PR: s.prFor(headSHA), Method: "MergeInto",
+		Detail: fmt.Sprintf("merge %s into %s", short(headSHA), branch)})
+	return headSHA, false, nil
+}
+
+func (s *Forge) DeleteBranch(ctx context.Context, owner, name, branch string) error {
+	s.skip(ctx, Action{Method: "DeleteBranch", Detail: "delete branch " + branch})
+	return nil
+}
+
+func (s *Forge) FastForward(ctx context.Context, owner, name, branch, sha string) error {
+	s.skip(ctx, Action{Method: "FastForward", Detail: fmt.Sprintf("fast-forward %s to %s", branch, short(sha))})
+	return nil
+}
+
+func (s *Forge) CancelAutoMerge(ctx context.Context, owner, name string, number int64) error {
+	s.mu.Lock()
+	s.cancelled[number] = s.heads[number]
+	s.mu.Unlock()
+	s.skip(ctx, Action{PR: number, Method: "CancelAutoMerge", Detail: "cancel auto-merge"})
+	return nil
+}
+
+func (s *Forge) Comment(ctx context.Context, owner, name string, number int64, body string) error {
+	s.skip(ctx, Action{PR: number, Method: "Comment", Detail: body})
+	return nil
+}
+
+func (s *Forge) ClosePR(ctx context.Context, owner, name string, number int64) error {
+	s.mu.Lock()
+	s.closed[number] = true
+	s.mu.Unlock()
+	s.skip(ctx, Action{PR: number, Method: "ClosePR", Detail: "close without merging"})
+	return nil
+}
+
+func (s *Forge) EnsureRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	s.skip(ctx, Action{Method: "EnsureRepoSetup",
+		Detail: fmt.Sprintf("set up the webhook and require %s on protected branches", forge.MQContext)})
+	return nil
+}
+
+func (s *Forge) TeardownRepoSetup(ctx context.Context, owner, name string, cfg forge.SetupConfig) error {
+	s.skip(ctx, Action{Method: "TeardownRepoSetup",
+		Detail: fmt.Sprintf("remove the webhook and stop requiring %s", forge.MQContext)})
+	return nil
+}
+
+func (s *Forge) GuardBranch(ctx context.Context, owner, name, branch string) error {
+	if _, ok := s.Forge.(forge.BranchGuard); ok {
+		s.skip(ctx, Action{Method: "GuardBranch",
+			Detail: fmt.Sprintf("require %s on %s", forge.MQContext, branch)})
+	}
+	return nil
+}
+
+func short(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
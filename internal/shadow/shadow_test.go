@@ -0,0 +1,128 @@
+package shadow_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/shadow"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+func setup(prs ...forge.PR) (*forge.MockForge, *shadow.Forge, *[]shadow.Action) {
+	mock := &forge.MockForge{
+		InstanceVal: "internal",
+		ListOpenPRsFn: func(context.Context, string, string) ([]forge.PR, error) {
+			return append([]forge.PR(nil), prs...), nil
+		},
+		GetPRFn: func(_ context.Context, _, _ string, n int64) (*forge.PR, error) {
+			for _, pr := range prs {
+				if pr.Number == n {
+					return &pr, nil
+				}
+			}
+			return &forge.PR{Number: n, State: "closed"}, nil
+		},
+	}
+	var actions []shadow.Action
+	s := shadow.Wrap(mock, func(_ context.Context, a shadow.Action) { actions = append(actions, a) })
+	return mock, s, &actions
+}
+
+func open(t *testing.T, s *shadow.Forge) map[int64]forge.PR {
+	t.Helper()
+	prs, err := s.ListOpenPRs(context.Background(), "org", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[int64]forge.PR{}
+	for _, pr := range prs {
+		out[pr.Number] = pr
+	}
+	return out
+}
+
+func TestWritesAreRecordedNotPerformed(t *testing.T) {
+	ctx := context.Background()
+	mock, s, actions := setup(forge.PR{Number: 1, State: "open", HeadSHA: "aaaa1111bbbb", AutoMergeEnabled: true})
+	open(t, s)
+
+	sha, conflict, err := s.CreateMergeBranch(ctx, "org", "app", "main", "aaaa1111bbbb", "gitea-mq/1")
+	if err != nil || conflict || sha != "aaaa1111bbbb" {
+		t.Errorf("CreateMergeBranch = %q, %v, %v; want the head SHA", sha, conflict, err)
+	}
+	_ = s.SetMQStatus(ctx, "org", "app", "aaaa1111bbbb", forge.MQStatus{State: pg.CheckStatePending, Description: "Testing"})
+	_ = s.FastForward(ctx, "org", "app", "main", "aaaa1111bbbb")
+	_ = s.Comment(ctx, "org", "app", 1, "hello")
+
+	if len(mock.Calls) != 1 { // the ListOpenPRs above and nothing else
+		t.Errorf("forge calls = %+v, want only reads", mock.Calls)
+	}
+	want := []shadow.Action{
+		{PR: 1, Method: "CreateMergeBranch", Detail: "create gitea-mq/1 from main and merge aaaa1111"},
+		{PR: 1, Method: "SetMQStatus", Detail: "set gitea-mq to pending on aaaa1111: Testing"},
+		{Method: "FastForward", Detail: "fast-forward main to aaaa1111"},
+		{PR: 1, Method: "Comment", Detail: "hello"},
+	}
+	if len(*actions) != len(want) {
+		t.Fatalf("actions = %+v, want %+v", *actions, want)
+	}
+	for i := range want {
+		if (*actions)[i] != want[i] {
+			t.Errorf("action %d = %+v, want %+v", i, (*actions)[i], want[i])
+		}
+	}
+	if s.Instance() != "internal" {
+		t.Errorf("Instance() = %q, want the wrapped adapter's", s.Instance())
+	}
+}
+
+func TestRepeatedStatusRecordedOnce(t *testing.T) {
+	ctx := context.Background()
+	_, s, actions := setup(forge.PR{Number: 1, State: "open", HeadSHA: "sha1"})
+	for range 3 {
+		_ = s.MirrorCheck(ctx, "org", "app", "sha1", "gitea-mq/ci", forge.Check{State: pg.CheckStatePending})
+	}
+	_ = s.MirrorCheck(ctx, "org", "app", "sha1", "gitea-mq/ci", forge.Check{State: pg.CheckStateSuccess})
+	if len(*actions) != 2 {
+		t.Errorf("actions = %+v, want pending and success once each", *actions)
+	}
+}
+
+func TestSkippedWritesFoldIntoReads(t *testing.T) {
+	ctx := context.Background()
+	_, s, _ := setup(
+		forge.PR{Number: 1, State: "open", HeadSHA: "sha1", AutoMergeEnabled: true},
+		forge.PR{Number: 2, State: "open", HeadSHA: "sha2", AutoMergeEnabled: true},
+		forge.PR{Number: 3, State: "open", HeadSHA: "sha3", AutoMergeEnabled: true},
+		forge.PR{Number: 4, State: "open", HeadSHA: "sha4", AutoMergeEnabled: true},
+	)
+	open(t, s)
+
+	_ = s.SetMQStatus(ctx, "org", "app", "sha1", forge.MQStatus{State: pg.CheckStateSuccess})
+	_ = s.ClosePR(ctx, "org", "app", 2)
+	_ = s.CancelAutoMerge(ctx, "org", "app", 3)
+
+	prs := open(t, s)
+	if _, ok := prs[1]; ok {
+		t.Error("PR 1 passed the queue but is still listed open")
+	}
+	if _, ok := prs[2]; ok {
+		t.Error("PR 2 was closed but is still listed open")
+	}
+	if prs[3].AutoMergeEnabled {
+		t.Error("PR 3 still has auto-merge after it was cancelled")
+	}
+	if !prs[4].AutoMergeEnabled {
+		t.Error("PR 4 lost auto-merge")
+	}
+
+	pr, err := s.GetPR(ctx, "org", "app", 1)
+	if err != nil || !pr.Merged || pr.State != "closed" {
+		t.Errorf("GetPR(1) = %+v, %v; want merged", pr, err)
+	}
+	pr, err = s.GetPR(ctx, "org", "app", 2)
+	if err != nil || pr.Merged || pr.State != "closed" {
+		t.Errorf("GetPR(2) = %+v, %v; want closed", pr, err)
+	}
+}
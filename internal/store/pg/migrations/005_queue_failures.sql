@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE queue_failures (
+    id          BIGSERIAL PRIMARY KEY,
+    repo_id     BIGINT NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    pr_number   BIGINT NOT NULL,
+    reason      TEXT   NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_queue_failures_repo ON queue_failures(repo_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_queue_failures_repo;
+DROP TABLE IF EXISTS queue_failures;
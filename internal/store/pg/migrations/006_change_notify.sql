@@ -0,0 +1,40 @@
+-- +goose Up
+
+-- Every change to queue state is announced on the gitea_mq_changes channel
+-- with the affected repo_id as payload, so dashboards can push updates
+-- instead of polling. Identical payloads within one transaction are folded by
+-- Postgres.
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_repo_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('gitea_mq_changes', COALESCE(NEW.repo_id, OLD.repo_id)::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_check_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('gitea_mq_changes', e.repo_id::text)
+    FROM queue_entries e
+    WHERE e.id = COALESCE(NEW.queue_entry_id, OLD.queue_entry_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER queue_entries_notify AFTER INSERT OR UPDATE OR DELETE ON queue_entries
+    FOR EACH ROW EXECUTE FUNCTION notify_repo_change();
+CREATE TRIGGER batches_notify AFTER INSERT OR UPDATE OR DELETE ON batches
+    FOR EACH ROW EXECUTE FUNCTION notify_repo_change();
+CREATE TRIGGER check_statuses_notify AFTER INSERT OR UPDATE OR DELETE ON check_statuses
+    FOR EACH ROW EXECUTE FUNCTION notify_check_change();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS check_statuses_notify ON check_statuses;
+DROP TRIGGER IF EXISTS batches_notify ON batches;
+DROP TRIGGER IF EXISTS queue_entries_notify ON queue_entries;
+DROP FUNCTION IF EXISTS notify_check_change();
+DROP FUNCTION IF EXISTS notify_repo_change();
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Dashboard login sessions. id is the SHA-256 of the cookie value so a
+-- database dump cannot be replayed as cookies; token is the user's forge
+-- OAuth access token, used for read-permission checks.
+CREATE TABLE sessions (
+    id          TEXT PRIMARY KEY,
+    forge       TEXT NOT NULL,
+    login       TEXT NOT NULL,
+    token       TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_sessions_expires ON sessions(expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sessions_expires;
+DROP TABLE IF EXISTS sessions;
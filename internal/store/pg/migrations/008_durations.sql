@@ -0,0 +1,36 @@
+-- +goose Up
+ALTER TABLE check_statuses
+    ADD COLUMN first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN completed_at  TIMESTAMPTZ;
+
+-- Finished CI runs per context. check_statuses rows die with their queue
+-- entry; this history outlives them and feeds the landing-time estimate.
+CREATE TABLE check_durations (
+    id          BIGSERIAL PRIMARY KEY,
+    repo_id     BIGINT NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    context     TEXT   NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_check_durations_repo ON check_durations(repo_id, finished_at);
+
+-- One row per finished merge-branch build (single PR or batch).
+CREATE TABLE build_durations (
+    id            BIGSERIAL PRIMARY KEY,
+    repo_id       BIGINT NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    target_branch TEXT    NOT NULL,
+    size          INT     NOT NULL,
+    passed        BOOLEAN NOT NULL,
+    duration_ms   BIGINT  NOT NULL,
+    finished_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_build_durations_repo ON build_durations(repo_id, finished_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS build_durations;
+DROP TABLE IF EXISTS check_durations;
+ALTER TABLE check_statuses
+    DROP COLUMN completed_at,
+    DROP COLUMN first_seen_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The leader records the repo set it manages so follower replicas can serve
+-- the dashboard without running discovery themselves.
+ALTER TABLE repos ADD COLUMN managed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE repos DROP COLUMN managed;
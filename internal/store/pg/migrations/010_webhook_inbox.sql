@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TYPE delivery_state AS ENUM ('pending', 'done', 'dead');
+
+-- Verified webhook deliveries, stored before they are acknowledged and
+-- processed asynchronously. Done rows are kept for a while so redeliveries
+-- of the same delivery ID are recognised as duplicates.
+CREATE TABLE webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    forge           TEXT  NOT NULL,
+    delivery_id     TEXT  NOT NULL,
+    event           TEXT  NOT NULL,
+    repo            TEXT  NOT NULL, -- forge:owner/name, '' for events without a repo
+    payload         BYTEA NOT NULL,
+    state           delivery_state NOT NULL DEFAULT 'pending',
+    attempts        INT   NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    received_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at    TIMESTAMPTZ,
+    UNIQUE (forge, delivery_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(repo, id) WHERE state = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TYPE IF EXISTS delivery_state;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Redacted request headers of each delivery, so a recorded delivery can be
+-- replayed as the forge sent it.
+ALTER TABLE webhook_deliveries ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_webhook_deliveries_received ON webhook_deliveries(received_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_webhook_deliveries_received;
+ALTER TABLE webhook_deliveries DROP COLUMN headers;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Several servers of one forge kind can be managed at once; instance names
+-- the server, empty for the default one.
+ALTER TABLE repos ADD COLUMN instance TEXT NOT NULL DEFAULT '';
+ALTER TABLE repos DROP CONSTRAINT repos_forge_owner_name_key;
+ALTER TABLE repos ADD CONSTRAINT repos_forge_instance_owner_name_key UNIQUE (forge, instance, owner, name);
+
+-- +goose Down
+-- Repos of named instances cannot be represented any more. queue_entries is
+-- the one table without ON DELETE CASCADE to repos, so clear it (and its
+-- check_statuses) first; the other per-repo tables cascade.
+DELETE FROM check_statuses WHERE queue_entry_id IN (
+    SELECT e.id FROM queue_entries e JOIN repos r ON r.id = e.repo_id WHERE r.instance <> ''
+);
+DELETE FROM queue_entries WHERE repo_id IN (SELECT id FROM repos WHERE instance <> '');
+DELETE FROM repos WHERE instance <> '';
+ALTER TABLE repos DROP CONSTRAINT repos_forge_instance_owner_name_key;
+ALTER TABLE repos ADD CONSTRAINT repos_forge_owner_name_key UNIQUE (forge, owner, name);
+ALTER TABLE repos DROP COLUMN instance;
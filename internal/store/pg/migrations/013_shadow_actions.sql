@@ -0,0 +1,16 @@
+-- +goose Up
+-- Forge writes gitea-mq skipped on repos in shadow mode, shown on the
+-- dashboard. pr_number is 0 for writes that concern no single PR.
+CREATE TABLE shadow_actions (
+    id         BIGSERIAL PRIMARY KEY,
+    repo_id    BIGINT NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    pr_number  BIGINT NOT NULL DEFAULT 0,
+    method     TEXT   NOT NULL,
+    detail     TEXT   NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_shadow_actions_repo ON shadow_actions(repo_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS shadow_actions;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- One JSON object per finished batch build, oldest first, so the bisection
+-- can be shown after the fact.
+ALTER TABLE batches ADD COLUMN history JSONB NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE batches DROP COLUMN IF EXISTS history;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Forge tokens are stored encrypted from now on. Sessions holding a
+-- plaintext token are dropped; their users log in again.
+DELETE FROM sessions;
+ALTER TABLE sessions RENAME COLUMN token TO sealed_token;
+
+-- +goose Down
+DELETE FROM sessions;
+ALTER TABLE sessions RENAME COLUMN sealed_token TO token;
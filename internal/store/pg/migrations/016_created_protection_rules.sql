@@ -0,0 +1,11 @@
+-- +goose Up
+-- Branch-protection rules gitea-mq created on Gitea/Forgejo. Tearing a repo
+-- down deletes these and only strips gitea-mq from the operator's own rules.
+CREATE TABLE created_protection_rules (
+    repo_id BIGINT NOT NULL REFERENCES repos(id) ON DELETE CASCADE,
+    rule    TEXT   NOT NULL,
+    PRIMARY KEY (repo_id, rule)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS created_protection_rules;
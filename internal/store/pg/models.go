@@ -99,6 +99,49 @@ func (ns NullCheckState) Value() (driver.Value, error) {
 	return string(ns.CheckState), nil
 }
 
+type DeliveryState string
+
+const (
+	DeliveryStatePending DeliveryState = "pending"
+	DeliveryStateDone    DeliveryState = "done"
+	DeliveryStateDead    DeliveryState = "dead"
+)
+
+func (e *DeliveryState) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DeliveryState(s)
+	case string:
+		*e = DeliveryState(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DeliveryState: %T", src)
+	}
+	return nil
+}
+
+type NullDeliveryState struct {
+	DeliveryState DeliveryState `json:"delivery_state"`
+	Valid         bool          `json:"valid"` // Valid is true if DeliveryState is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDeliveryState) Scan(value interface{}) error {
+	if value == nil {
+		ns.DeliveryState, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DeliveryState.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDeliveryState) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DeliveryState), nil
+}
+
 type EntryState string
 
 const (
@@ -161,6 +204,25 @@ type Batch struct {
 	Flaky            bool               `json:"flaky"`
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
 	TestingStartedAt pgtype.Timestamptz `json:"testing_started_at"`
+	History          []byte             `json:"history"`
+}
+
+type BuildDuration struct {
+	ID           int64              `json:"id"`
+	RepoID       int64              `json:"repo_id"`
+	TargetBranch string             `json:"target_branch"`
+	Size         int32              `json:"size"`
+	Passed       bool               `json:"passed"`
+	DurationMs   int64              `json:"duration_ms"`
+	FinishedAt   pgtype.Timestamptz `json:"finished_at"`
+}
+
+type CheckDuration struct {
+	ID         int64              `json:"id"`
+	RepoID     int64              `json:"repo_id"`
+	Context    string             `json:"context"`
+	DurationMs int64              `json:"duration_ms"`
+	FinishedAt pgtype.Timestamptz `json:"finished_at"`
 }
 
 type CheckStatus struct {
@@ -170,6 +232,13 @@ type CheckStatus struct {
 	State        CheckState         `json:"state"`
 	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
 	TargetUrl    string             `json:"target_url"`
+	FirstSeenAt  pgtype.Timestamptz `json:"first_seen_at"`
+	CompletedAt  pgtype.Timestamptz `json:"completed_at"`
+}
+
+type CreatedProtectionRule struct {
+	RepoID int64  `json:"repo_id"`
+	Rule   string `json:"rule"`
 }
 
 type QueueEntry struct {
@@ -188,10 +257,54 @@ type QueueEntry struct {
 	ActiveBatchID    pgtype.Int8        `json:"active_batch_id"`
 }
 
+type QueueFailure struct {
+	ID        int64              `json:"id"`
+	RepoID    int64              `json:"repo_id"`
+	PrNumber  int64              `json:"pr_number"`
+	Reason    string             `json:"reason"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Repo struct {
 	ID        int64              `json:"id"`
 	Owner     string             `json:"owner"`
 	Name      string             `json:"name"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 	Forge     string             `json:"forge"`
+	Managed   bool               `json:"managed"`
+	Instance  string             `json:"instance"`
+}
+
+type Session struct {
+	ID          string             `json:"id"`
+	Forge       string             `json:"forge"`
+	Login       string             `json:"login"`
+	SealedToken string             `json:"sealed_token"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+}
+
+type ShadowAction struct {
+	ID        int64              `json:"id"`
+	RepoID    int64              `json:"repo_id"`
+	PrNumber  int64              `json:"pr_number"`
+	Method    string             `json:"method"`
+	Detail    string             `json:"detail"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID            int64              `json:"id"`
+	Forge         string             `json:"forge"`
+	DeliveryID    string             `json:"delivery_id"`
+	Event         string             `json:"event"`
+	Repo          string             `json:"repo"`
+	Payload       []byte             `json:"payload"`
+	State         DeliveryState      `json:"state"`
+	Attempts      int32              `json:"attempts"`
+	LastError     pgtype.Text        `json:"last_error"`
+	ReceivedAt    pgtype.Timestamptz `json:"received_at"`
+	NextAttemptAt pgtype.Timestamptz `json:"next_attempt_at"`
+	ProcessedAt   pgtype.Timestamptz `json:"processed_at"`
+	Headers       []byte             `json:"headers"`
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"io/fs"
 	"log/slog"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,11 +17,9 @@ var embedMigrations embed.FS
 
 // Connect creates a pgx connection pool and runs migrations.
 func Connect(ctx context.Context, connString string) (*pgxpool.Pool, error) {
-	slog.Debug("connecting to database", "connection_string", connString)
-
-	pool, err := pgxpool.New(ctx, connString)
+	pool, err := Open(ctx, connString)
 	if err != nil {
-		return nil, fmt.Errorf("unable to connect to database: %w", err)
+		return nil, err
 	}
 
 	slog.Debug("migrating database")
@@ -37,6 +36,28 @@ func Connect(ctx context.Context, connString string) (*pgxpool.Pool, error) {
 	return pool, nil
 }
 
+// Open creates a pgx connection pool without migrating, for callers that
+// manage the schema themselves.
+func Open(ctx context.Context, connString string) (*pgxpool.Pool, error) {
+	slog.Debug("connecting to database", "connection_string", connString)
+
+	pool, err := pgxpool.New(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+	return pool, nil
+}
+
+// Migrations returns a goose provider for the embedded migrations, used by
+// `gitea-mq migrate` to step the schema up or down and report its state.
+func Migrations(pool *pgxpool.Pool) (*goose.Provider, error) {
+	fsys, err := fs.Sub(embedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, stdlib.OpenDBFromPool(pool), fsys)
+}
+
 // MigrateTo runs embedded migrations up to and including version on an
 // existing pool. Exposed so migration tests can stop partway, seed data,
 // then continue.
@@ -1,7 +1,7 @@
 -- name: GetOrCreateRepo :one
-INSERT INTO repos (forge, owner, name)
-VALUES ($1, $2, $3)
-ON CONFLICT (forge, owner, name) DO UPDATE SET owner = EXCLUDED.owner
+INSERT INTO repos (forge, instance, owner, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (forge, instance, owner, name) DO UPDATE SET owner = EXCLUDED.owner
 RETURNING *;
 
 -- name: EnqueuePR :one
@@ -45,11 +45,18 @@ UPDATE queue_entries
 SET error_message = $3
 WHERE repo_id = $1 AND pr_number = $2;
 
--- name: SaveCheckStatus :exec
-INSERT INTO check_statuses (queue_entry_id, context, state, target_url)
-VALUES ($1, $2, $3, $4)
+-- name: SaveCheckStatus :one
+INSERT INTO check_statuses (queue_entry_id, context, state, target_url, completed_at)
+VALUES (@queue_entry_id, @context, @state, @target_url,
+        CASE WHEN @state::check_state = 'pending' THEN NULL ELSE NOW() END)
 ON CONFLICT (queue_entry_id, context) DO UPDATE
-SET state = EXCLUDED.state, target_url = EXCLUDED.target_url, updated_at = NOW();
+SET state = EXCLUDED.state, target_url = EXCLUDED.target_url, updated_at = NOW(),
+    -- A re-run (terminal → pending) starts a new measurement.
+    first_seen_at = CASE WHEN check_statuses.completed_at IS NOT NULL AND EXCLUDED.state = 'pending'
+                         THEN NOW() ELSE check_statuses.first_seen_at END,
+    completed_at = CASE WHEN EXCLUDED.state = 'pending' THEN NULL
+                        ELSE COALESCE(check_statuses.completed_at, NOW()) END
+RETURNING *;
 
 -- name: GetCheckStatuses :many
 SELECT * FROM check_statuses
@@ -137,6 +144,160 @@ UPDATE batches SET
 WHERE id = $1
 RETURNING *;
 
+-- name: AppendBatchStep :exec
+UPDATE batches SET history = history || jsonb_build_array(@step::jsonb)
+WHERE id = @id;
+
 -- name: CancelBatchesByRepo :exec
 UPDATE batches SET state = 'cancelled'
 WHERE repo_id = $1 AND state IN ('forming', 'testing');
+
+-- name: RecordFailure :exec
+INSERT INTO queue_failures (repo_id, pr_number, reason)
+VALUES ($1, $2, $3);
+
+-- name: ListFailures :many
+SELECT f.*, r.forge, r.instance, r.owner, r.name AS repo_name
+FROM queue_failures f
+JOIN repos r ON r.id = f.repo_id
+ORDER BY r.forge, r.instance, r.owner, r.name, f.created_at ASC;
+
+-- name: DeleteFailures :exec
+DELETE FROM queue_failures
+WHERE id = ANY(@ids::bigint[]);
+
+-- name: CreateSession :exec
+INSERT INTO sessions (id, forge, login, sealed_token, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1 AND expires_at > NOW();
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at <= NOW();
+
+-- name: RecordCheckDuration :exec
+INSERT INTO check_durations (repo_id, context, duration_ms)
+SELECT qe.repo_id, @context::text, @duration_ms::bigint FROM queue_entries qe
+WHERE qe.id = @queue_entry_id;
+
+-- name: ListCheckDurationMedians :many
+SELECT context, percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms)::bigint AS median_ms
+FROM check_durations
+WHERE repo_id = @repo_id AND finished_at > @since
+GROUP BY context;
+
+-- name: RecordBuildDuration :exec
+INSERT INTO build_durations (repo_id, target_branch, size, passed, duration_ms)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ListRecentBuilds :many
+SELECT * FROM build_durations
+WHERE repo_id = @repo_id AND finished_at > @since
+ORDER BY finished_at DESC
+LIMIT @max_rows;
+
+-- name: DeleteOldDurations :exec
+WITH checks AS (
+    DELETE FROM check_durations WHERE check_durations.finished_at <= @before
+)
+DELETE FROM build_durations WHERE build_durations.finished_at <= @before;
+
+-- name: SetRepoManaged :exec
+UPDATE repos SET managed = $2 WHERE id = $1;
+
+-- name: GetRepo :one
+SELECT * FROM repos WHERE id = $1;
+
+-- name: ListManagedRepos :many
+SELECT * FROM repos WHERE managed
+ORDER BY forge, instance, owner, name;
+
+-- name: InsertDelivery :execrows
+INSERT INTO webhook_deliveries (forge, delivery_id, event, repo, headers, payload)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (forge, delivery_id) DO NOTHING;
+
+-- name: ClaimDelivery :one
+-- Takes the oldest due delivery whose repo has no older one still pending,
+-- so events of a repo are applied in arrival order. The lease delays a
+-- retry should the claiming process die mid-way.
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = @lease_until
+WHERE id = (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.state = 'pending' AND d.next_attempt_at <= NOW()
+      AND NOT EXISTS (
+          SELECT 1 FROM webhook_deliveries p
+          WHERE p.state = 'pending' AND p.repo = d.repo AND p.id < d.id
+      )
+    ORDER BY d.id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDelivery :exec
+UPDATE webhook_deliveries
+SET state = 'done', last_error = NULL, processed_at = NOW()
+WHERE id = $1;
+
+-- name: FailDelivery :exec
+UPDATE webhook_deliveries
+SET last_error = @last_error,
+    next_attempt_at = @retry_at,
+    state = CASE WHEN attempts >= @max_attempts::int THEN 'dead'::delivery_state ELSE 'pending'::delivery_state END,
+    processed_at = CASE WHEN attempts >= @max_attempts::int THEN NOW() END
+WHERE id = @id;
+
+-- name: CountDeliveries :one
+SELECT
+    COUNT(*) FILTER (WHERE state = 'pending') AS pending,
+    COUNT(*) FILTER (WHERE state = 'dead')    AS dead
+FROM webhook_deliveries;
+
+-- name: ListDeadDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE state = 'dead'
+ORDER BY id DESC
+LIMIT $1;
+
+-- name: DeleteOldDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE state <> 'pending' AND received_at <= $1;
+
+-- name: ListDeliveries :many
+SELECT * FROM webhook_deliveries
+ORDER BY id DESC
+LIMIT $1;
+
+-- name: GetDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = $1;
+
+-- name: RecordShadowAction :exec
+INSERT INTO shadow_actions (repo_id, pr_number, method, detail)
+VALUES ($1, $2, $3, $4);
+
+-- name: ListShadowActions :many
+SELECT * FROM shadow_actions
+WHERE repo_id = @repo_id
+ORDER BY id DESC
+LIMIT @max_rows;
+
+-- name: DeleteOldShadowActions :exec
+DELETE FROM shadow_actions WHERE created_at <= $1;
+
+-- name: RecordCreatedRule :exec
+INSERT INTO created_protection_rules (repo_id, rule)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: ListCreatedRules :many
+SELECT rule FROM created_protection_rules WHERE repo_id = $1 ORDER BY rule;
+
+-- name: DeleteCreatedRules :exec
+DELETE FROM created_protection_rules WHERE repo_id = $1;
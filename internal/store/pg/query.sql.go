@@ -11,6 +11,21 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const appendBatchStep = `-- name: AppendBatchStep :exec
+UPDATE batches SET history = history || jsonb_build_array($1::jsonb)
+WHERE id = $2
+`
+
+type AppendBatchStepParams struct {
+	Step []byte `json:"step"`
+	ID   int64  `json:"id"`
+}
+
+func (q *Queries) AppendBatchStep(ctx context.Context, arg AppendBatchStepParams) error {
+	_, err := q.db.Exec(ctx, appendBatchStep, arg.Step, arg.ID)
+	return err
+}
+
 const cancelBatchesByRepo = `-- name: CancelBatchesByRepo :exec
 UPDATE batches SET state = 'cancelled'
 WHERE repo_id = $1 AND state IN ('forming', 'testing')
@@ -21,6 +36,47 @@ func (q *Queries) CancelBatchesByRepo(ctx context.Context, repoID int64) error {
 	return err
 }
 
+const claimDelivery = `-- name: ClaimDelivery :one
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = $1
+WHERE id = (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.state = 'pending' AND d.next_attempt_at <= NOW()
+      AND NOT EXISTS (
+          SELECT 1 FROM webhook_deliveries p
+          WHERE p.state = 'pending' AND p.repo = d.repo AND p.id < d.id
+      )
+    ORDER BY d.id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, forge, delivery_id, event, repo, payload, state, attempts, last_error, received_at, next_attempt_at, processed_at, headers
+`
+
+// Takes the oldest due delivery whose repo has no older one still pending,
+// so events of a repo are applied in arrival order. The lease delays a
+// retry should the claiming process die mid-way.
+func (q *Queries) ClaimDelivery(ctx context.Context, leaseUntil pgtype.Timestamptz) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, claimDelivery, leaseUntil)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.Forge,
+		&i.DeliveryID,
+		&i.Event,
+		&i.Repo,
+		&i.Payload,
+		&i.State,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.NextAttemptAt,
+		&i.ProcessedAt,
+		&i.Headers,
+	)
+	return i, err
+}
+
 const clearCheckStatuses = `-- name: ClearCheckStatuses :exec
 DELETE FROM check_statuses
 WHERE queue_entry_id = ANY($1::bigint[])
@@ -42,6 +98,36 @@ func (q *Queries) ClearEntryMergeBranch(ctx context.Context, ids []int64) error
 	return err
 }
 
+const completeDelivery = `-- name: CompleteDelivery :exec
+UPDATE webhook_deliveries
+SET state = 'done', last_error = NULL, processed_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CompleteDelivery(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, completeDelivery, id)
+	return err
+}
+
+const countDeliveries = `-- name: CountDeliveries :one
+SELECT
+    COUNT(*) FILTER (WHERE state = 'pending') AS pending,
+    COUNT(*) FILTER (WHERE state = 'dead')    AS dead
+FROM webhook_deliveries
+`
+
+type CountDeliveriesRow struct {
+	Pending int64 `json:"pending"`
+	Dead    int64 `json:"dead"`
+}
+
+func (q *Queries) CountDeliveries(ctx context.Context) (CountDeliveriesRow, error) {
+	row := q.db.QueryRow(ctx, countDeliveries)
+	var i CountDeliveriesRow
+	err := row.Scan(&i.Pending, &i.Dead)
+	return i, err
+}
+
 const countQueuePosition = `-- name: CountQueuePosition :one
 SELECT COUNT(*) FROM queue_entries qe
 WHERE qe.repo_id = $1 AND qe.target_branch = $2
@@ -67,7 +153,7 @@ func (q *Queries) CountQueuePosition(ctx context.Context, arg CountQueuePosition
 const createBatch = `-- name: CreateBatch :one
 INSERT INTO batches (repo_id, target_branch, member_ids, current_ids)
 VALUES ($1, $2, $3, $3)
-RETURNING id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at
+RETURNING id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at, history
 `
 
 type CreateBatchParams struct {
@@ -96,10 +182,103 @@ func (q *Queries) CreateBatch(ctx context.Context, arg CreateBatchParams) (Batch
 		&i.Flaky,
 		&i.CreatedAt,
 		&i.TestingStartedAt,
+		&i.History,
 	)
 	return i, err
 }
 
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (id, forge, login, sealed_token, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateSessionParams struct {
+	ID          string             `json:"id"`
+	Forge       string             `json:"forge"`
+	Login       string             `json:"login"`
+	SealedToken string             `json:"sealed_token"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.Exec(ctx, createSession,
+		arg.ID,
+		arg.Forge,
+		arg.Login,
+		arg.SealedToken,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const deleteCreatedRules = `-- name: DeleteCreatedRules :exec
+DELETE FROM created_protection_rules WHERE repo_id = $1
+`
+
+func (q *Queries) DeleteCreatedRules(ctx context.Context, repoID int64) error {
+	_, err := q.db.Exec(ctx, deleteCreatedRules, repoID)
+	return err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, deleteExpiredSessions)
+	return err
+}
+
+const deleteFailures = `-- name: DeleteFailures :exec
+DELETE FROM queue_failures
+WHERE id = ANY($1::bigint[])
+`
+
+func (q *Queries) DeleteFailures(ctx context.Context, ids []int64) error {
+	_, err := q.db.Exec(ctx, deleteFailures, ids)
+	return err
+}
+
+const deleteOldDeliveries = `-- name: DeleteOldDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE state <> 'pending' AND received_at <= $1
+`
+
+func (q *Queries) DeleteOldDeliveries(ctx context.Context, receivedAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteOldDeliveries, receivedAt)
+	return err
+}
+
+const deleteOldDurations = `-- name: DeleteOldDurations :exec
+WITH checks AS (
+    DELETE FROM check_durations WHERE check_durations.finished_at <= $1
+)
+DELETE FROM build_durations WHERE build_durations.finished_at <= $1
+`
+
+func (q *Queries) DeleteOldDurations(ctx context.Context, before pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteOldDurations, before)
+	return err
+}
+
+const deleteOldShadowActions = `-- name: DeleteOldShadowActions :exec
+DELETE FROM shadow_actions WHERE created_at <= $1
+`
+
+func (q *Queries) DeleteOldShadowActions(ctx context.Context, createdAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteOldShadowActions, createdAt)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions WHERE id = $1
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteSession, id)
+	return err
+}
+
 const dequeueAllByRepo = `-- name: DequeueAllByRepo :exec
 DELETE FROM queue_entries
 WHERE repo_id = $1
@@ -166,8 +345,35 @@ func (q *Queries) EnqueuePR(ctx context.Context, arg EnqueuePRParams) (QueueEntr
 	return i, err
 }
 
+const failDelivery = `-- name: FailDelivery :exec
+UPDATE webhook_deliveries
+SET last_error = $1,
+    next_attempt_at = $2,
+    state = CASE WHEN attempts >= $3::int THEN 'dead'::delivery_state ELSE 'pending'::delivery_state END,
+    processed_at = CASE WHEN attempts >= $3::int THEN NOW() END
+WHERE id = $4
+`
+
+type FailDeliveryParams struct {
+	LastError   pgtype.Text        `json:"last_error"`
+	RetryAt     pgtype.Timestamptz `json:"retry_at"`
+	MaxAttempts int32              `json:"max_attempts"`
+	ID          int64              `json:"id"`
+}
+
+func (q *Queries) FailDelivery(ctx context.Context, arg FailDeliveryParams) error {
+	_, err := q.db.Exec(
+		ctx, failDelivery,
+		arg.LastError,
+		arg.RetryAt,
+		arg.MaxAttempts,
+		arg.ID,
+	)
+	return err
+}
+
 const getBatch = `-- name: GetBatch :one
-SELECT id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at FROM batches WHERE id = $1
+SELECT id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at, history FROM batches WHERE id = $1
 `
 
 func (q *Queries) GetBatch(ctx context.Context, id int64) (Batch, error) {
@@ -190,12 +396,13 @@ func (q *Queries) GetBatch(ctx context.Context, id int64) (Batch, error) {
 		&i.Flaky,
 		&i.CreatedAt,
 		&i.TestingStartedAt,
+		&i.History,
 	)
 	return i, err
 }
 
 const getCheckStatuses = `-- name: GetCheckStatuses :many
-SELECT id, queue_entry_id, context, state, updated_at, target_url FROM check_statuses
+SELECT id, queue_entry_id, context, state, updated_at, target_url, first_seen_at, completed_at FROM check_statuses
 WHERE queue_entry_id = $1
 `
 
@@ -215,6 +422,8 @@ func (q *Queries) GetCheckStatuses(ctx context.Context, queueEntryID int64) ([]C
 			&i.State,
 			&i.UpdatedAt,
 			&i.TargetUrl,
+			&i.FirstSeenAt,
+			&i.CompletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -226,6 +435,31 @@ func (q *Queries) GetCheckStatuses(ctx context.Context, queueEntryID int64) ([]C
 	return items, nil
 }
 
+const getDelivery = `-- name: GetDelivery :one
+SELECT id, forge, delivery_id, event, repo, payload, state, attempts, last_error, received_at, next_attempt_at, processed_at, headers FROM webhook_deliveries WHERE id = $1
+`
+
+func (q *Queries) GetDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.Forge,
+		&i.DeliveryID,
+		&i.Event,
+		&i.Repo,
+		&i.Payload,
+		&i.State,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.NextAttemptAt,
+		&i.ProcessedAt,
+		&i.Headers,
+	)
+	return i, err
+}
+
 const getEntriesByIDs = `-- name: GetEntriesByIDs :many
 SELECT id, repo_id, pr_number, pr_head_sha, target_branch, state, enqueued_at, testing_started_at, completed_at, merge_branch_name, merge_branch_sha, error_message, active_batch_id FROM queue_entries
 WHERE id = ANY($1::bigint[])
@@ -299,7 +533,7 @@ func (q *Queries) GetHeadOfQueue(ctx context.Context, arg GetHeadOfQueueParams)
 }
 
 const getLiveBatch = `-- name: GetLiveBatch :one
-SELECT id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at FROM batches
+SELECT id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at, history FROM batches
 WHERE repo_id = $1 AND target_branch = $2 AND state IN ('forming', 'testing')
 `
 
@@ -328,25 +562,32 @@ func (q *Queries) GetLiveBatch(ctx context.Context, arg GetLiveBatchParams) (Bat
 		&i.Flaky,
 		&i.CreatedAt,
 		&i.TestingStartedAt,
+		&i.History,
 	)
 	return i, err
 }
 
 const getOrCreateRepo = `-- name: GetOrCreateRepo :one
-INSERT INTO repos (forge, owner, name)
-VALUES ($1, $2, $3)
-ON CONFLICT (forge, owner, name) DO UPDATE SET owner = EXCLUDED.owner
-RETURNING id, owner, name, created_at, forge
+INSERT INTO repos (forge, instance, owner, name)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (forge, instance, owner, name) DO UPDATE SET owner = EXCLUDED.owner
+RETURNING id, owner, name, created_at, forge, managed, instance
 `
 
 type GetOrCreateRepoParams struct {
-	Forge string `json:"forge"`
-	Owner string `json:"owner"`
-	Name  string `json:"name"`
+	Forge    string `json:"forge"`
+	Instance string `json:"instance"`
+	Owner    string `json:"owner"`
+	Name     string `json:"name"`
 }
 
 func (q *Queries) GetOrCreateRepo(ctx context.Context, arg GetOrCreateRepoParams) (Repo, error) {
-	row := q.db.QueryRow(ctx, getOrCreateRepo, arg.Forge, arg.Owner, arg.Name)
+	row := q.db.QueryRow(ctx, getOrCreateRepo,
+		arg.Forge,
+		arg.Instance,
+		arg.Owner,
+		arg.Name,
+	)
 	var i Repo
 	err := row.Scan(
 		&i.ID,
@@ -354,6 +595,8 @@ func (q *Queries) GetOrCreateRepo(ctx context.Context, arg GetOrCreateRepoParams
 		&i.Name,
 		&i.CreatedAt,
 		&i.Forge,
+		&i.Managed,
+		&i.Instance,
 	)
 	return i, err
 }
@@ -389,6 +632,75 @@ func (q *Queries) GetQueueEntry(ctx context.Context, arg GetQueueEntryParams) (Q
 	return i, err
 }
 
+const getRepo = `-- name: GetRepo :one
+SELECT id, owner, name, created_at, forge, managed, instance FROM repos WHERE id = $1
+`
+
+func (q *Queries) GetRepo(ctx context.Context, id int64) (Repo, error) {
+	row := q.db.QueryRow(ctx, getRepo, id)
+	var i Repo
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Name,
+		&i.CreatedAt,
+		&i.Forge,
+		&i.Managed,
+		&i.Instance,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, forge, login, sealed_token, created_at, expires_at FROM sessions
+WHERE id = $1 AND expires_at > NOW()
+`
+
+func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRow(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.Forge,
+		&i.Login,
+		&i.SealedToken,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const insertDelivery = `-- name: InsertDelivery :execrows
+INSERT INTO webhook_deliveries (forge, delivery_id, event, repo, headers, payload)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (forge, delivery_id) DO NOTHING
+`
+
+type InsertDeliveryParams struct {
+	Forge      string `json:"forge"`
+	DeliveryID string `json:"delivery_id"`
+	Event      string `json:"event"`
+	Repo       string `json:"repo"`
+	Headers    []byte `json:"headers"`
+	Payload    []byte `json:"payload"`
+}
+
+func (q *Queries) InsertDelivery(ctx context.Context, arg InsertDeliveryParams) (int64, error) {
+	result, err := q.db.Exec(
+		ctx, insertDelivery,
+		arg.Forge,
+		arg.DeliveryID,
+		arg.Event,
+		arg.Repo,
+		arg.Headers,
+		arg.Payload,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const listActiveEntriesByRepo = `-- name: ListActiveEntriesByRepo :many
 SELECT id, repo_id, pr_number, pr_head_sha, target_branch, state, enqueued_at, testing_started_at, completed_at, merge_branch_name, merge_branch_sha, error_message, active_batch_id FROM queue_entries
 WHERE repo_id = $1 AND state NOT IN ('failed', 'cancelled')
@@ -429,8 +741,199 @@ func (q *Queries) ListActiveEntriesByRepo(ctx context.Context, repoID int64) ([]
 	return items, nil
 }
 
+const listCheckDurationMedians = `-- name: ListCheckDurationMedians :many
+SELECT context, percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms)::bigint AS median_ms
+FROM check_durations
+WHERE repo_id = $1 AND finished_at > $2
+GROUP BY context
+`
+
+type ListCheckDurationMediansParams struct {
+	RepoID int64              `json:"repo_id"`
+	Since  pgtype.Timestamptz `json:"since"`
+}
+
+type ListCheckDurationMediansRow struct {
+	Context  string `json:"context"`
+	MedianMs int64  `json:"median_ms"`
+}
+
+func (q *Queries) ListCheckDurationMedians(ctx context.Context, arg ListCheckDurationMediansParams) ([]ListCheckDurationMediansRow, error) {
+	rows, err := q.db.Query(ctx, listCheckDurationMedians, arg.RepoID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCheckDurationMediansRow
+	for rows.Next() {
+		var i ListCheckDurationMediansRow
+		if err := rows.Scan(&i.Context, &i.MedianMs); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCreatedRules = `-- name: ListCreatedRules :many
+SELECT rule FROM created_protection_rules WHERE repo_id = $1 ORDER BY rule
+`
+
+func (q *Queries) ListCreatedRules(ctx context.Context, repoID int64) ([]string, error) {
+	rows, err := q.db.Query(ctx, listCreatedRules, repoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var rule string
+		if err := rows.Scan(&rule); err != nil {
+			return nil, err
+		}
+		items = append(items, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeadDeliveries = `-- name: ListDeadDeliveries :many
+SELECT id, forge, delivery_id, event, repo, payload, state, attempts, last_error, received_at, next_attempt_at, processed_at, headers FROM webhook_deliveries
+WHERE state = 'dead'
+ORDER BY id DESC
+LIMIT $1
+`
+
+func (q *Queries) ListDeadDeliveries(ctx context.Context, limit int32) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listDeadDeliveries, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.Forge,
+			&i.DeliveryID,
+			&i.Event,
+			&i.Repo,
+			&i.Payload,
+			&i.State,
+			&i.Attempts,
+			&i.LastError,
+			&i.ReceivedAt,
+			&i.NextAttemptAt,
+			&i.ProcessedAt,
+			&i.Headers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeliveries = `-- name: ListDeliveries :many
+SELECT id, forge, delivery_id, event, repo, payload, state, attempts, last_error, received_at, next_attempt_at, processed_at, headers FROM webhook_deliveries
+ORDER BY id DESC
+LIMIT $1
+`
+
+func (q *Queries) ListDeliveries(ctx context.Context, limit int32) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listDeliveries, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.Forge,
+			&i.DeliveryID,
+			&i.Event,
+			&i.Repo,
+			&i.Payload,
+			&i.State,
+			&i.Attempts,
+			&i.LastError,
+			&i.ReceivedAt,
+			&i.NextAttemptAt,
+			&i.ProcessedAt,
+			&i.Headers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFailures = `-- name: ListFailures :many
+SELECT f.id, f.repo_id, f.pr_number, f.reason, f.created_at, r.forge, r.instance, r.owner, r.name AS repo_name
+FROM queue_failures f
+JOIN repos r ON r.id = f.repo_id
+ORDER BY r.forge, r.instance, r.owner, r.name, f.created_at ASC
+`
+
+type ListFailuresRow struct {
+	ID        int64              `json:"id"`
+	RepoID    int64              `json:"repo_id"`
+	PrNumber  int64              `json:"pr_number"`
+	Reason    string             `json:"reason"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Forge     string             `json:"forge"`
+	Instance  string             `json:"instance"`
+	Owner     string             `json:"owner"`
+	RepoName  string             `json:"repo_name"`
+}
+
+func (q *Queries) ListFailures(ctx context.Context) ([]ListFailuresRow, error) {
+	rows, err := q.db.Query(ctx, listFailures)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFailuresRow
+	for rows.Next() {
+		var i ListFailuresRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RepoID,
+			&i.PrNumber,
+			&i.Reason,
+			&i.CreatedAt,
+			&i.Forge,
+			&i.Instance,
+			&i.Owner,
+			&i.RepoName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listLiveBatchesByRepo = `-- name: ListLiveBatchesByRepo :many
-SELECT id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at FROM batches
+SELECT id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at, history FROM batches
 WHERE repo_id = $1 AND state IN ('forming', 'testing')
 `
 
@@ -460,6 +963,40 @@ func (q *Queries) ListLiveBatchesByRepo(ctx context.Context, repoID int64) ([]Ba
 			&i.Flaky,
 			&i.CreatedAt,
 			&i.TestingStartedAt,
+			&i.History,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listManagedRepos = `-- name: ListManagedRepos :many
+SELECT id, owner, name, created_at, forge, managed, instance FROM repos WHERE managed
+ORDER BY forge, instance, owner, name
+`
+
+func (q *Queries) ListManagedRepos(ctx context.Context) ([]Repo, error) {
+	rows, err := q.db.Query(ctx, listManagedRepos)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Repo
+	for rows.Next() {
+		var i Repo
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Name,
+			&i.CreatedAt,
+			&i.Forge,
+			&i.Managed,
+			&i.Instance,
 		); err != nil {
 			return nil, err
 		}
@@ -516,6 +1053,86 @@ func (q *Queries) ListQueue(ctx context.Context, arg ListQueueParams) ([]QueueEn
 	return items, nil
 }
 
+const listRecentBuilds = `-- name: ListRecentBuilds :many
+SELECT id, repo_id, target_branch, size, passed, duration_ms, finished_at FROM build_durations
+WHERE repo_id = $1 AND finished_at > $2
+ORDER BY finished_at DESC
+LIMIT $3
+`
+
+type ListRecentBuildsParams struct {
+	RepoID  int64              `json:"repo_id"`
+	Since   pgtype.Timestamptz `json:"since"`
+	MaxRows int32              `json:"max_rows"`
+}
+
+func (q *Queries) ListRecentBuilds(ctx context.Context, arg ListRecentBuildsParams) ([]BuildDuration, error) {
+	rows, err := q.db.Query(ctx, listRecentBuilds, arg.RepoID, arg.Since, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BuildDuration
+	for rows.Next() {
+		var i BuildDuration
+		if err := rows.Scan(
+			&i.ID,
+			&i.RepoID,
+			&i.TargetBranch,
+			&i.Size,
+			&i.Passed,
+			&i.DurationMs,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShadowActions = `-- name: ListShadowActions :many
+SELECT id, repo_id, pr_number, method, detail, created_at FROM shadow_actions
+WHERE repo_id = $1
+ORDER BY id DESC
+LIMIT $2
+`
+
+type ListShadowActionsParams struct {
+	RepoID  int64 `json:"repo_id"`
+	MaxRows int32 `json:"max_rows"`
+}
+
+func (q *Queries) ListShadowActions(ctx context.Context, arg ListShadowActionsParams) ([]ShadowAction, error) {
+	rows, err := q.db.Query(ctx, listShadowActions, arg.RepoID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ShadowAction
+	for rows.Next() {
+		var i ShadowAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.RepoID,
+			&i.PrNumber,
+			&i.Method,
+			&i.Detail,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const loadActiveQueues = `-- name: LoadActiveQueues :many
 SELECT qe.id, qe.repo_id, qe.pr_number, qe.pr_head_sha, qe.target_branch, qe.state, qe.enqueued_at, qe.testing_started_at, qe.completed_at, qe.merge_branch_name, qe.merge_branch_sha, qe.error_message, qe.active_batch_id, r.forge, r.owner, r.name AS repo_name
 FROM queue_entries qe
@@ -580,6 +1197,103 @@ func (q *Queries) LoadActiveQueues(ctx context.Context) ([]LoadActiveQueuesRow,
 	return items, nil
 }
 
+const recordBuildDuration = `-- name: RecordBuildDuration :exec
+INSERT INTO build_durations (repo_id, target_branch, size, passed, duration_ms)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type RecordBuildDurationParams struct {
+	RepoID       int64  `json:"repo_id"`
+	TargetBranch string `json:"target_branch"`
+	Size         int32  `json:"size"`
+	Passed       bool   `json:"passed"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+func (q *Queries) RecordBuildDuration(ctx context.Context, arg RecordBuildDurationParams) error {
+	_, err := q.db.Exec(
+		ctx, recordBuildDuration,
+		arg.RepoID,
+		arg.TargetBranch,
+		arg.Size,
+		arg.Passed,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const recordCheckDuration = `-- name: RecordCheckDuration :exec
+INSERT INTO check_durations (repo_id, context, duration_ms)
+SELECT qe.repo_id, $1::text, $2::bigint FROM queue_entries qe
+WHERE qe.id = $3
+`
+
+type RecordCheckDurationParams struct {
+	Context      string `json:"context"`
+	DurationMs   int64  `json:"duration_ms"`
+	QueueEntryID int64  `json:"queue_entry_id"`
+}
+
+func (q *Queries) RecordCheckDuration(ctx context.Context, arg RecordCheckDurationParams) error {
+	_, err := q.db.Exec(ctx, recordCheckDuration, arg.Context, arg.DurationMs, arg.QueueEntryID)
+	return err
+}
+
+const recordFailure = `-- name: RecordFailure :exec
+INSERT INTO queue_failures (repo_id, pr_number, reason)
+VALUES ($1, $2, $3)
+`
+
+type RecordFailureParams struct {
+	RepoID   int64  `json:"repo_id"`
+	PrNumber int64  `json:"pr_number"`
+	Reason   string `json:"reason"`
+}
+
+func (q *Queries) RecordFailure(ctx context.Context, arg RecordFailureParams) error {
+	_, err := q.db.Exec(ctx, recordFailure, arg.RepoID, arg.PrNumber, arg.Reason)
+	return err
+}
+
+const recordCreatedRule = `-- name: RecordCreatedRule :exec
+INSERT INTO created_protection_rules (repo_id, rule)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type RecordCreatedRuleParams struct {
+	RepoID int64  `json:"repo_id"`
+	Rule   string `json:"rule"`
+}
+
+func (q *Queries) RecordCreatedRule(ctx context.Context, arg RecordCreatedRuleParams) error {
+	_, err := q.db.Exec(ctx, recordCreatedRule, arg.RepoID, arg.Rule)
+	return err
+}
+
+const recordShadowAction = `-- name: RecordShadowAction :exec
+INSERT INTO shadow_actions (repo_id, pr_number, method, detail)
+VALUES ($1, $2, $3, $4)
+`
+
+type RecordShadowActionParams struct {
+	RepoID   int64  `json:"repo_id"`
+	PrNumber int64  `json:"pr_number"`
+	Method   string `json:"method"`
+	Detail   string `json:"detail"`
+}
+
+func (q *Queries) RecordShadowAction(ctx context.Context, arg RecordShadowActionParams) error {
+	_, err := q.db.Exec(
+		ctx, recordShadowAction,
+		arg.RepoID,
+		arg.PrNumber,
+		arg.Method,
+		arg.Detail,
+	)
+	return err
+}
+
 const saveBatch = `-- name: SaveBatch :one
 UPDATE batches SET
     state = $2,
@@ -594,7 +1308,7 @@ UPDATE batches SET
     flaky = $11,
     testing_started_at = $12
 WHERE id = $1
-RETURNING id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at
+RETURNING id, repo_id, target_branch, state, member_ids, current_ids, pending, landed_ids, ejected_ids, branch_name, branch_sha, builds, ff_retries, flaky, created_at, testing_started_at, history
 `
 
 type SaveBatchParams struct {
@@ -646,15 +1360,23 @@ func (q *Queries) SaveBatch(ctx context.Context, arg SaveBatchParams) (Batch, er
 		&i.Flaky,
 		&i.CreatedAt,
 		&i.TestingStartedAt,
+		&i.History,
 	)
 	return i, err
 }
 
-const saveCheckStatus = `-- name: SaveCheckStatus :exec
-INSERT INTO check_statuses (queue_entry_id, context, state, target_url)
-VALUES ($1, $2, $3, $4)
+const saveCheckStatus = `-- name: SaveCheckStatus :one
+INSERT INTO check_statuses (queue_entry_id, context, state, target_url, completed_at)
+VALUES ($1, $2, $3, $4,
+        CASE WHEN $3::check_state = 'pending' THEN NULL ELSE NOW() END)
 ON CONFLICT (queue_entry_id, context) DO UPDATE
-SET state = EXCLUDED.state, target_url = EXCLUDED.target_url, updated_at = NOW()
+SET state = EXCLUDED.state, target_url = EXCLUDED.target_url, updated_at = NOW(),
+    -- A re-run (terminal → pending) starts a new measurement.
+    first_seen_at = CASE WHEN check_statuses.completed_at IS NOT NULL AND EXCLUDED.state = 'pending'
+                         THEN NOW() ELSE check_statuses.first_seen_at END,
+    completed_at = CASE WHEN EXCLUDED.state = 'pending' THEN NULL
+                        ELSE COALESCE(check_statuses.completed_at, NOW()) END
+RETURNING id, queue_entry_id, context, state, updated_at, target_url, first_seen_at, completed_at
 `
 
 type SaveCheckStatusParams struct {
@@ -664,15 +1386,26 @@ type SaveCheckStatusParams struct {
 	TargetUrl    string     `json:"target_url"`
 }
 
-func (q *Queries) SaveCheckStatus(ctx context.Context, arg SaveCheckStatusParams) error {
-	_, err := q.db.Exec(
+func (q *Queries) SaveCheckStatus(ctx context.Context, arg SaveCheckStatusParams) (CheckStatus, error) {
+	row := q.db.QueryRow(
 		ctx, saveCheckStatus,
 		arg.QueueEntryID,
 		arg.Context,
 		arg.State,
 		arg.TargetUrl,
 	)
-	return err
+	var i CheckStatus
+	err := row.Scan(
+		&i.ID,
+		&i.QueueEntryID,
+		&i.Context,
+		&i.State,
+		&i.UpdatedAt,
+		&i.TargetUrl,
+		&i.FirstSeenAt,
+		&i.CompletedAt,
+	)
+	return i, err
 }
 
 const setEntryActiveBatch = `-- name: SetEntryActiveBatch :exec
@@ -692,6 +1425,20 @@ func (q *Queries) SetEntryActiveBatch(ctx context.Context, arg SetEntryActiveBat
 	return err
 }
 
+const setRepoManaged = `-- name: SetRepoManaged :exec
+UPDATE repos SET managed = $2 WHERE id = $1
+`
+
+type SetRepoManagedParams struct {
+	ID      int64 `json:"id"`
+	Managed bool  `json:"managed"`
+}
+
+func (q *Queries) SetRepoManaged(ctx context.Context, arg SetRepoManagedParams) error {
+	_, err := q.db.Exec(ctx, setRepoManaged, arg.ID, arg.Managed)
+	return err
+}
+
 const takeQueuedHead = `-- name: TakeQueuedHead :many
 SELECT id, repo_id, pr_number, pr_head_sha, target_branch, state, enqueued_at, testing_started_at, completed_at, merge_branch_name, merge_branch_sha, error_message, active_batch_id FROM queue_entries
 WHERE repo_id = $1 AND target_branch = $2 AND state = 'queued'
@@ -16,6 +16,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/queue"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -245,7 +246,7 @@ func TestQueueService(t *testing.T) (*queue.Service, context.Context, int64) {
 	svc := queue.NewService(pool)
 	ctx := t.Context()
 
-	repo, err := svc.GetOrCreateRepo(ctx, "gitea", "org", "app")
+	repo, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "app"})
 	if err != nil {
 		t.Fatalf("create test repo: %v", err)
 	}
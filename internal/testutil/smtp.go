@@ -0,0 +1,131 @@
+package testutil
+
+import (
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Mail is one message accepted by an SMTPServer.
+type Mail struct {
+	From string
+	To   []string
+	Data string // headers and body, dot-unstuffed with LF line endings
+}
+
+// SMTPServer is a minimal in-process SMTP stand-in. It speaks just enough of
+// RFC 5321 for net/smtp.SendMail (no STARTTLS, no AUTH) and records every
+// accepted message.
+type SMTPServer struct {
+	Addr string
+
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []Mail
+	received chan struct{}
+}
+
+// NewSMTPServer starts a stand-in on a random localhost port. It is shut down
+// when the test finishes.
+func NewSMTPServer(t *testing.T) *SMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("smtp listen: %v", err)
+	}
+	s := &SMTPServer{Addr: ln.Addr().String(), ln: ln, received: make(chan struct{}, 64)}
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+// Messages returns a copy of every message accepted so far.
+func (s *SMTPServer) Messages() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.messages...)
+}
+
+// WaitMessage blocks until the next message is accepted and returns it.
+func (s *SMTPServer) WaitMessage(t *testing.T) Mail {
+	t.Helper()
+
+	select {
+	case <-s.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for mail")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages[len(s.messages)-1]
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	tp := textproto.NewConn(conn)
+
+	reply := func(line string) bool { return tp.PrintfLine("%s", line) == nil }
+	if !reply("220 localhost ESMTP stand-in") {
+		return
+	}
+
+	var cur Mail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			cur = Mail{From: addrArg(arg)}
+			reply("250 OK")
+		case "RCPT":
+			cur.To = append(cur.To, addrArg(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			cur.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, cur)
+			s.mu.Unlock()
+			s.received <- struct{}{}
+			reply("250 OK")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// addrArg extracts the address from "FROM:<a@b>" / "TO:<a@b>".
+func addrArg(arg string) string {
+	_, v, _ := strings.Cut(arg, ":")
+	v, _, _ = strings.Cut(strings.TrimSpace(v), " ")
+	return strings.Trim(v, "<>")
+}
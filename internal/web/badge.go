@@ -0,0 +1,248 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// badgeMaxAge is the Cache-Control max-age for badges, in seconds. Short
+// enough that README images follow the queue, long enough that image proxies
+// (e.g. GitHub's camo) do not hit us on every page view.
+const badgeMaxAge = 30
+
+// Badge colours, matching the shields.io palette so they sit well next to
+// other README badges.
+const (
+	colorGreen  = "#4c1"
+	colorYellow = "#dfb317"
+	colorRed    = "#e05d44"
+	colorBlue   = "#007ec6"
+	colorGrey   = "#9f9f9f"
+)
+
+// Head-of-queue states shown by the status badge.
+const (
+	statusIdle    = "idle"    // nothing queued
+	statusTesting = "testing" // head (or batch) under test
+	statusFailing = "failing" // a check of the head failed or the batch is bisecting
+	statusPaused  = "paused"  // entries queued but nothing under test
+)
+
+var statusColors = map[string]string{
+	statusIdle:    colorGreen,
+	statusTesting: colorYellow,
+	statusFailing: colorRed,
+	statusPaused:  colorGrey,
+}
+
+var badgeTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">
+<title>{{.Label}}: {{.Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="{{.LabelWidth}}" height="20" fill="#555"/><rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/><rect width="{{.Width}}" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="15" fill="#010101" fill-opacity=".3" textLength="{{.LabelText}}">{{.Label}}</text><text x="{{.LabelX}}" y="14" textLength="{{.LabelText}}">{{.Label}}</text>
+<text x="{{.MessageX}}" y="15" fill="#010101" fill-opacity=".3" textLength="{{.MessageText}}">{{.Message}}</text><text x="{{.MessageX}}" y="14" textLength="{{.MessageText}}">{{.Message}}</text>
+</g>
+</svg>
+`))
+
+// badge is the template data for one two-part badge.
+type badge struct {
+	Label, Message, Color    string
+	LabelText, MessageText   int // text widths in px
+	LabelWidth, MessageWidth int // box widths in px
+	Width                    int
+	LabelX, MessageX         int
+}
+
+// newBadge lays out a badge. Glyph widths are approximated; textLength makes
+// the renderer stretch the text to fit, so the result looks right in any
+// font.
+func newBadge(label, message, color string) badge {
+	const charWidth, padding = 7, 10
+	b := badge{
+		Label:       label,
+		Message:     message,
+		Color:       color,
+		LabelText:   utf8.RuneCountInString(label) * charWidth,
+		MessageText: utf8.RuneCountInString(message) * charWidth,
+	}
+	b.LabelWidth = b.LabelText + 2*padding
+	b.MessageWidth = b.MessageText + 2*padding
+	b.Width = b.LabelWidth + b.MessageWidth
+	b.LabelX = b.LabelWidth / 2
+	b.MessageX = b.LabelWidth + b.MessageWidth/2
+	return b
+}
+
+func renderBadge(w http.ResponseWriter, b badge) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", badgeMaxAge))
+	if err := badgeTemplate.Execute(w, b); err != nil {
+		serverError(w, "failed to render badge", err)
+	}
+}
+
+// badgeRef resolves the {forge}/{owner}/{name} path values of a badge route,
+// replying 404 for unknown forges and unmanaged or hidden repos.
+func badgeRef(deps *Deps, w http.ResponseWriter, r *http.Request) (forge.RepoRef, bool) {
+	host, ok := forge.ParseHost(r.PathValue("forge"))
+	ref := forge.RepoRef{Forge: host.Kind, Instance: host.Instance, Owner: r.PathValue("owner"), Name: r.PathValue("name")}
+	if !ok || !canView(r, deps, ref) {
+		http.NotFound(w, r)
+		return forge.RepoRef{}, false
+	}
+	return ref, true
+}
+
+// repoBadgeHandler serves the per-repo badges:
+//   - GET /badge/{forge}/{owner}/{name}/queue.svg — number of queued PRs
+//   - GET /badge/{forge}/{owner}/{name}/status.svg — head-of-queue state
+//
+// ?branch=<target> restricts either badge to one target branch.
+func repoBadgeHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := r.PathValue("file")
+		if kind != "queue.svg" && kind != "status.svg" {
+			http.NotFound(w, r)
+			return
+		}
+		ref, ok := badgeRef(deps, w, r)
+		if !ok {
+			return
+		}
+		ctx := r.Context()
+		repo, err := deps.Queue.GetOrCreateRepo(ctx, ref)
+		if err != nil {
+			serverError(w, "failed to get repo", err, "repo", ref)
+			return
+		}
+		entries, err := deps.Queue.ListActiveEntries(ctx, repo.ID)
+		if err != nil {
+			serverError(w, "failed to list active entries", err, "repo", ref)
+			return
+		}
+		branch := r.URL.Query().Get("branch")
+		if branch != "" {
+			entries = filterBranch(entries, branch)
+		}
+
+		if kind == "queue.svg" {
+			color := colorBlue
+			if len(entries) == 0 {
+				color = colorGrey
+			}
+			renderBadge(w, newBadge("merge queue", strconv.Itoa(len(entries))+" queued", color))
+			return
+		}
+
+		status, err := headStatus(r, deps, repo.ID, branch, entries)
+		if err != nil {
+			serverError(w, "failed to compute queue status", err, "repo", ref)
+			return
+		}
+		renderBadge(w, newBadge("merge queue", status, statusColors[status]))
+	}
+}
+
+func filterBranch(entries []pg.QueueEntry, branch string) []pg.QueueEntry {
+	var out []pg.QueueEntry
+	for _, e := range entries {
+		if e.TargetBranch == branch {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// headStatus summarises the active entries into one of the status* values.
+// Across several target branches the worst state wins.
+func headStatus(r *http.Request, deps *Deps, repoID int64, branch string, entries []pg.QueueEntry) (string, error) {
+	if len(entries) == 0 {
+		return statusIdle, nil
+	}
+	ctx := r.Context()
+
+	batches, err := deps.Queue.ListLiveBatches(ctx, repoID)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range batches {
+		// A rebuild only happens after a failed build: the batch is bisecting.
+		if (branch == "" || b.TargetBranch == branch) && b.Builds > 1 {
+			return statusFailing, nil
+		}
+	}
+
+	testing := false
+	for _, e := range entries {
+		if e.State == pg.EntryStateQueued {
+			continue
+		}
+		testing = true
+		checks, err := deps.Queue.GetCheckStatuses(ctx, e.ID)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range checks {
+			if c.State == pg.CheckStateFailure || c.State == pg.CheckStateError {
+				return statusFailing, nil
+			}
+		}
+	}
+	if testing {
+		return statusTesting, nil
+	}
+	return statusPaused, nil
+}
+
+// prBadgeHandler serves GET /badge/{forge}/{owner}/{name}/pr/{number}.svg
+// with the PR's queue position, or its state once it is under test.
+func prBadgeHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		numStr, ok := strings.CutSuffix(r.PathValue("file"), ".svg")
+		prNumber, err := strconv.ParseInt(numStr, 10, 64)
+		if !ok || err != nil || prNumber <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		ref, ok := badgeRef(deps, w, r)
+		if !ok {
+			return
+		}
+		ctx := r.Context()
+		repo, err := deps.Queue.GetOrCreateRepo(ctx, ref)
+		if err != nil {
+			serverError(w, "failed to get repo", err, "repo", ref)
+			return
+		}
+		entry, err := deps.Queue.GetEntry(ctx, repo.ID, prNumber)
+		if err != nil {
+			serverError(w, "failed to get entry", err, "pr", prNumber)
+			return
+		}
+
+		label := "merge queue"
+		switch {
+		case entry == nil || entry.State == pg.EntryStateFailed || entry.State == pg.EntryStateCancelled:
+			renderBadge(w, newBadge(label, "not queued", colorGrey))
+		case entry.State == pg.EntryStateQueued:
+			pos, err := deps.Queue.Position(ctx, repo.ID, entry.TargetBranch, prNumber)
+			if err != nil {
+				serverError(w, "failed to compute queue position", err, "pr", prNumber)
+				return
+			}
+			renderBadge(w, newBadge(label, fmt.Sprintf("#%d in queue", pos), colorBlue))
+		default:
+			renderBadge(w, newBadge(label, string(entry.State), colorYellow))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/queue"
+)
+
+// sseKeepalive is how often an idle event stream gets a comment line so
+// proxies do not time it out.
+const sseKeepalive = 30 * time.Second
+
+// Hub fans repo change notifications out to connected dashboard clients.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[chan int64]struct{}
+
+	// refs caches the ref of each repo ID seen in a notification for all
+	// clients; a repo row never changes its ref. IDs without a row map to
+	// the zero ref, so they are looked up once rather than on every event.
+	refsMu sync.Mutex
+	refs   map[int64]forge.RepoRef
+}
+
+// NewHub creates an empty hub. Feed it with Run or Publish.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[chan int64]struct{}), refs: make(map[int64]forge.RepoRef)}
+}
+
+// ref maps a notified repo ID to its ref, reading the repos table on a cache
+// miss. ok is false for IDs without a repo.
+func (h *Hub) ref(ctx context.Context, q *queue.Service, id int64) (ref forge.RepoRef, ok bool, err error) {
+	h.refsMu.Lock()
+	defer h.refsMu.Unlock()
+	if ref, hit := h.refs[id]; hit {
+		return ref, ref != (forge.RepoRef{}), nil
+	}
+	repo, err := q.GetRepo(ctx, id)
+	if err != nil {
+		return forge.RepoRef{}, false, err
+	}
+	if repo != nil {
+		ref = forge.RepoRef{Forge: forge.Kind(repo.Forge), Instance: repo.Instance, Owner: repo.Owner, Name: repo.Name}
+	}
+	h.refs[id] = ref
+	return ref, repo != nil, nil
+}
+
+// Run publishes every change reported by q.WatchChanges until ctx is
+// cancelled, reconnecting after connection failures.
+func (h *Hub) Run(ctx context.Context, q *queue.Service) {
+	for {
+		err := q.WatchChanges(ctx, h.Publish)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("dashboard change feed lost, reconnecting", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// Publish announces a change to repoID. Slow subscribers that already have
+// a backlog miss the event; the refresh they still owe picks it up.
+func (h *Hub) Publish(repoID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- repoID:
+		default:
+		}
+	}
+}
+
+func (h *Hub) subscribe() (<-chan int64, func()) {
+	ch := make(chan int64, 16)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// eventsHandler serves GET /events as a Server-Sent Events stream. With
+// ?repo=<forge>:<owner>/<name> only changes to that repo are sent, otherwise
+// every change. Each event is a "change" carrying the repo ref as data; the
+// client re-fetches the page it is showing.
+func eventsHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.Events == nil {
+			http.NotFound(w, r)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+
+		var only forge.RepoRef
+		if s := r.URL.Query().Get("repo"); s != "" {
+			ref, ok := forge.ParseRepoRef(s)
+			if !ok || !canView(r, deps, ref) {
+				http.NotFound(w, r)
+				return
+			}
+			only = ref
+		}
+
+		// Notifications carry repo IDs, which the hub maps back to refs.
+		// Whether the visitor may see a ref is decided once per connection;
+		// a repo that is not managed yet is asked about again later.
+		visible := make(map[forge.RepoRef]bool)
+		for _, ref := range visibleRepos(r, deps) {
+			visible[ref] = true
+		}
+
+		changes, unsubscribe := deps.Events.subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		// Tell the client how long to wait before reconnecting.
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", max(deps.RefreshInterval, 1)*1000)
+		flusher.Flush()
+
+		keepalive := time.NewTicker(sseKeepalive)
+		defer keepalive.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-keepalive.C:
+				_, _ = fmt.Fprint(w, ": keepalive\n\n")
+			case id := <-changes:
+				ref, ok, err := deps.Events.ref(ctx, deps.Queue, id)
+				if err != nil {
+					slog.Warn("failed to resolve repo for event stream", "repo_id", id, "error", err)
+					continue
+				}
+				if !ok || (only != (forge.RepoRef{}) && ref != only) {
+					continue
+				}
+				seen, decided := visible[ref]
+				if !decided && deps.Repos.Contains(ref.String()) {
+					seen = canView(r, deps, ref)
+					visible[ref] = seen
+				}
+				if !seen {
+					continue
+				}
+				_, _ = fmt.Fprintf(w, "event: change\ndata: %s\n\n", ref)
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -1,24 +1,32 @@
 // Package web provides the server-rendered HTML dashboard for gitea-mq.
 // No JavaScript frameworks — pages are functional with JS disabled, using
-// <meta http-equiv="refresh"> for auto-refresh.
+// <meta http-equiv="refresh"> for auto-refresh. With JS, live.js subscribes
+// to /events and re-fetches the page content in place when its repo changes.
 package web
 
 import (
+	"context"
 	"embed"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/Mic92/gitea-mq/internal/admin"
+	"github.com/Mic92/gitea-mq/internal/auth"
 	"github.com/Mic92/gitea-mq/internal/batch"
+	"github.com/Mic92/gitea-mq/internal/doctor"
+	"github.com/Mic92/gitea-mq/internal/eta"
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/monitor"
 	"github.com/Mic92/gitea-mq/internal/queue"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 )
 
-//go:embed templates/*.html templates/*.css
+//go:embed templates/*.html templates/*.css templates/*.js
 var templateFS embed.FS
 
 // funcMap provides template helper functions.
@@ -37,10 +45,24 @@ var funcMap = template.FuncMap{
 			return "⏳"
 		}
 	},
+	"doctorIcon": func(s forge.DoctorStatus) string {
+		switch s {
+		case forge.DoctorPass:
+			return "✅"
+		case forge.DoctorFail:
+			return "❌"
+		default:
+			return "⚠️"
+		}
+	},
 	"forgeName": func(k forge.Kind) string {
 		switch k {
 		case forge.KindGithub:
 			return "GitHub"
+		case forge.KindForgejo:
+			return "Forgejo"
+		case forge.KindGitlab:
+			return "GitLab"
 		default:
 			return "Gitea"
 		}
@@ -95,24 +117,57 @@ func pluralize(n int, unit string) string {
 
 // RepoOverview holds the data for one repo in the overview page.
 type RepoOverview struct {
-	Forge     forge.Kind
+	Forge     forge.Host
 	Owner     string
 	Name      string
 	QueueSize int
+	Shadow    bool // in shadow mode
+}
+
+// Viewer is the login box shown when dashboard login is enabled.
+type Viewer struct {
+	Login     string     // empty when anonymous
+	Forge     forge.Kind // forge of Login
+	Providers []forge.Kind
+	Next      string // path to return to after login
 }
 
 // OverviewData is the template data for the overview page.
 type OverviewData struct {
 	Repos           []RepoOverview
-	RefreshInterval int // seconds
+	RefreshInterval int  // seconds
+	Live            bool // /events is available
+	Viewer          *Viewer
+	Inbox           *InboxSummary // nil when the inbox could not be read
 }
 
+// InboxSummary surfaces the webhook inbox on the overview page.
+type InboxSummary struct {
+	Pending int64 // deliveries waiting to be processed
+	Dead    int64 // deliveries that failed for good
+	Failed  []FailedDelivery
+}
+
+// FailedDelivery is a dead-lettered webhook delivery.
+type FailedDelivery struct {
+	Forge      forge.Host
+	Event      string
+	Repo       string // owner/name; empty for app-level events
+	Attempts   int32
+	Error      string
+	ReceivedAt time.Time
+}
+
+// failedDeliveriesShown caps the failed deliveries listed on the overview.
+const failedDeliveriesShown = 20
+
 // RepoDetailEntry holds one queue entry for the repo detail page.
 type RepoDetailEntry struct {
 	PrNumber     int64
 	TargetBranch string
 	State        string
 	BatchBucket  string // current/pending/landed when in a live batch
+	ETA          string // expected landing, relative; empty without CI history
 }
 
 // RepoDetailBatch surfaces a live batch on the repo detail page.
@@ -126,20 +181,41 @@ type RepoDetailBatch struct {
 	Members      int
 }
 
+// ShadowAction is a forge write gitea-mq skipped because the repo is in
+// shadow mode.
+type ShadowAction struct {
+	PrNumber int64 // 0 when the write concerns no single PR
+	Method   string
+	Detail   string
+	At       time.Time
+}
+
+// shadowActionsShown caps the skipped writes listed on the repo page.
+const shadowActionsShown = 50
+
+// doctorTTL is how long a doctor report is reused; each run costs several
+// forge API calls and a dry-run push.
+const doctorTTL = time.Minute
+
 // RepoDetailData is the template data for the repo detail page.
 type RepoDetailData struct {
-	Forge           forge.Kind
+	Forge           forge.Host
 	Owner           string
 	Name            string
 	RepoURL         string // link to the repo on the forge
 	Entries         []RepoDetailEntry
 	Batches         []RepoDetailBatch
-	RefreshInterval int // seconds
+	Shadow          bool // in shadow mode; ShadowActions lists what it skipped
+	ShadowActions   []ShadowAction
+	Doctor          bool // the visitor may run the doctor
+	RefreshInterval int  // seconds
+	Live            bool // /events is available
+	Viewer          *Viewer
 }
 
 // PRDetailData is the template data for the PR detail page.
 type PRDetailData struct {
-	Forge           forge.Kind
+	Forge           forge.Host
 	Owner           string
 	Name            string
 	PrNumber        int64
@@ -148,6 +224,8 @@ type PRDetailData struct {
 	State           string
 	Position        int
 	EnqueuedAt      time.Time
+	ETA             string    // expected landing, relative; empty without CI history
+	ETAAt           time.Time // absolute form of ETA
 	CheckStatuses   []pg.CheckStatus
 	InQueue         bool
 	PRURL           string
@@ -155,7 +233,25 @@ type PRDetailData struct {
 	BatchID         int64
 	BatchBucket     string
 	BatchPRs        []int64
-	RefreshInterval int // seconds
+	RefreshInterval int  // seconds
+	Live            bool // /events is available
+	Viewer          *Viewer
+}
+
+// DoctorData is the template data for the repo doctor page.
+type DoctorData struct {
+	Forge     forge.Host
+	Owner     string
+	Name      string
+	Checks    []forge.DoctorCheck
+	CheckedAt time.Time // when the (possibly cached) report was made
+	Viewer    *Viewer
+}
+
+// doctorReport is a cached doctor run.
+type doctorReport struct {
+	checks []forge.DoctorCheck
+	at     time.Time
 }
 
 // RepoLister abstracts how the dashboard gets the current managed repo set.
@@ -172,16 +268,69 @@ type Deps struct {
 	Forges          *forge.Set
 	FallbackChecks  []string // from GITEA_MQ_REQUIRED_CHECKS
 	RefreshInterval int      // seconds
+	BatchMax        int      // GITEA_MQ_BATCH_MAX, for landing-time estimates
+	// Events feeds /events. Nil disables live updates; pages then fall back
+	// to reloading every RefreshInterval.
+	Events *Hub
+	// Auth enables login and limits every page to the repos the visitor may
+	// read on the forge. Nil shows all managed repos to everyone.
+	Auth *auth.Authenticator
+	// ExternalURL is GITEA_MQ_EXTERNAL_URL; the doctor page checks the
+	// repo's webhook against it.
+	ExternalURL string
+	// BranchPatterns is GITEA_MQ_BRANCH_PATTERNS; the doctor page checks
+	// that the forge gates them.
+	BranchPatterns []string
+	// ShadowRepos are in shadow mode; their repo page lists the forge writes
+	// gitea-mq skipped.
+	ShadowRepos []forge.RepoRef
+	// AdminToken is GITEA_MQ_ADMIN_TOKEN; its bearer may run the doctor on
+	// any repo. Otherwise only logged-in users with write access may.
+	AdminToken string
+
+	tuneMu sync.RWMutex // guards FallbackChecks and BatchMax after Retune
+
+	doctorMu      sync.Mutex
+	doctorReports map[forge.RepoRef]doctorReport
+}
+
+// Retune replaces the settings a config reload may change.
+func (d *Deps) Retune(fallbackChecks []string, batchMax int) {
+	d.tuneMu.Lock()
+	defer d.tuneMu.Unlock()
+	d.FallbackChecks, d.BatchMax = fallbackChecks, batchMax
+}
+
+func (d *Deps) fallbackChecks() []string {
+	d.tuneMu.RLock()
+	defer d.tuneMu.RUnlock()
+	return d.FallbackChecks
+}
+
+func (d *Deps) batchMax() int {
+	d.tuneMu.RLock()
+	defer d.tuneMu.RUnlock()
+	return d.BatchMax
 }
 
 // NewMux creates an http.ServeMux with the dashboard routes registered.
 func NewMux(deps *Deps) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/static/style.css", staticCSSHandler)
+	mux.HandleFunc("/static/style.css", staticHandler("style.css", "text/css; charset=utf-8"))
+	mux.HandleFunc("/static/live.js", staticHandler("live.js", "text/javascript; charset=utf-8"))
+	mux.HandleFunc("/events", eventsHandler(deps))
+	mux.HandleFunc("/badge/{forge}/{owner}/{name}/{file}", repoBadgeHandler(deps))
+	mux.HandleFunc("/badge/{forge}/{owner}/{name}/pr/{file}", prBadgeHandler(deps))
+	if deps.Auth != nil {
+		mux.HandleFunc("GET /auth/login/{forge}", deps.Auth.LoginHandler)
+		mux.HandleFunc("GET /auth/callback/{forge}", deps.Auth.CallbackHandler)
+		mux.HandleFunc("POST /auth/logout", deps.Auth.LogoutHandler)
+	}
 	mux.HandleFunc("/{$}", overviewHandler(deps))
 	repo := repoHandler(deps)
 	mux.HandleFunc("/repo/{forge}/{owner}/{name}", repo)
 	mux.HandleFunc("/repo/{forge}/{owner}/{name}/pr/{number}", repo)
+	mux.HandleFunc("GET /doctor/{forge}/{owner}/{name}", doctorHandler(deps))
 	// Legacy paths without {forge} resolve as gitea so existing MQStatus
 	// target_urls and bookmarks keep working.
 	mux.HandleFunc("/repo/{owner}/{name}", repo)
@@ -189,16 +338,18 @@ func NewMux(deps *Deps) *http.ServeMux {
 	return mux
 }
 
-// staticCSSHandler serves the shared stylesheet from the embedded FS.
-func staticCSSHandler(w http.ResponseWriter, _ *http.Request) {
-	data, err := templateFS.ReadFile("templates/style.css")
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
-		return
+// staticHandler serves a shared asset from the embedded FS.
+func staticHandler(name, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		data, err := templateFS.ReadFile("templates/" + name)
+		if err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_, _ = w.Write(data)
 	}
-	w.Header().Set("Content-Type", "text/css; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=3600")
-	_, _ = w.Write(data)
 }
 
 // overviewHandler serves the overview page at GET /.
@@ -207,12 +358,17 @@ func overviewHandler(deps *Deps) http.HandlerFunc {
 		ctx := r.Context()
 		data := OverviewData{
 			RefreshInterval: deps.RefreshInterval,
+			Live:            deps.Events != nil,
+			Viewer:          viewerFor(r, deps),
 		}
 
-		for _, ref := range deps.Repos.List() {
-			overview := RepoOverview{Forge: ref.Forge, Owner: ref.Owner, Name: ref.Name}
+		visible := visibleRepos(r, deps)
+		data.Inbox = inboxSummary(ctx, deps, visible)
 
-			repo, err := deps.Queue.GetOrCreateRepo(ctx, string(ref.Forge), ref.Owner, ref.Name)
+		for _, ref := range visible {
+			overview := RepoOverview{Forge: ref.Host(), Owner: ref.Owner, Name: ref.Name, Shadow: slices.Contains(deps.ShadowRepos, ref)}
+
+			repo, err := deps.Queue.GetOrCreateRepo(ctx, ref)
 			if err != nil {
 				slog.Error("failed to get repo", "repo", ref, "error", err)
 				data.Repos = append(data.Repos, overview)
@@ -234,6 +390,77 @@ func overviewHandler(deps *Deps) http.HandlerFunc {
 	}
 }
 
+// inboxSummary reports the webhook backlog and the latest failed deliveries
+// of repos the visitor may see.
+func inboxSummary(ctx context.Context, deps *Deps, visible []forge.RepoRef) *InboxSummary {
+	pending, dead, err := deps.Queue.InboxStats(ctx)
+	if err != nil {
+		slog.Error("failed to read webhook inbox", "error", err)
+		return nil
+	}
+	sum := &InboxSummary{Pending: pending, Dead: dead}
+	if dead == 0 {
+		return sum
+	}
+
+	failed, err := deps.Queue.ListDeadDeliveries(ctx, failedDeliveriesShown)
+	if err != nil {
+		slog.Error("failed to list failed webhook deliveries", "error", err)
+		return sum
+	}
+	keys := make(map[string]bool, len(visible))
+	for _, ref := range visible {
+		keys[ref.String()] = true
+	}
+	for _, d := range failed {
+		if d.Repo != "" && !keys[d.Repo] {
+			continue
+		}
+		host, _ := forge.ParseHost(d.Forge)
+		fd := FailedDelivery{
+			Forge:      host,
+			Event:      d.Event,
+			Attempts:   d.Attempts,
+			Error:      d.LastError.String,
+			ReceivedAt: d.ReceivedAt.Time,
+		}
+		if ref, ok := forge.ParseRepoRef(d.Repo); ok {
+			fd.Repo = ref.Owner + "/" + ref.Name
+		}
+		sum.Failed = append(sum.Failed, fd)
+	}
+	return sum
+}
+
+// visibleRepos returns the managed repos the visitor behind r may see.
+func visibleRepos(r *http.Request, deps *Deps) []forge.RepoRef {
+	refs := deps.Repos.List()
+	if deps.Auth == nil {
+		return refs
+	}
+	return deps.Auth.Filter(r, refs)
+}
+
+// canView reports whether ref is managed and visible to the visitor behind r.
+func canView(r *http.Request, deps *Deps, ref forge.RepoRef) bool {
+	if !deps.Repos.Contains(ref.String()) {
+		return false
+	}
+	return deps.Auth == nil || deps.Auth.CanView(r, ref)
+}
+
+// viewerFor builds the login box data, or nil when login is disabled.
+func viewerFor(r *http.Request, deps *Deps) *Viewer {
+	if deps.Auth == nil {
+		return nil
+	}
+	v := &Viewer{Providers: deps.Auth.Providers(), Next: r.URL.Path}
+	if sess := deps.Auth.Session(r); sess != nil {
+		v.Login, v.Forge = sess.Login, forge.Kind(sess.Forge)
+	}
+	return v
+}
+
 // forgeFor resolves the forge for ref, returning nil when no forge set is
 // configured or the ref's forge is unknown.
 func forgeFor(deps *Deps, ref forge.RepoRef) forge.Forge {
@@ -262,21 +489,12 @@ func renderHTML(w http.ResponseWriter, name string, data any) {
 //   - GET /repo/{forge}/{owner}/{name} — repo queue listing
 //   - GET /repo/{forge}/{owner}/{name}/pr/{number} — PR detail
 //
-// When the {forge} path value is absent (legacy routes) the forge defaults to
-// gitea.
+// {forge} is a forge.Host ("gitea", "gitea@internal"). When it is absent
+// (legacy routes) the forge defaults to the default Gitea instance.
 func repoHandler(deps *Deps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		kind := forge.KindGitea
-		if k := r.PathValue("forge"); k != "" {
-			kind = forge.Kind(k)
-			if !kind.Valid() {
-				http.NotFound(w, r)
-				return
-			}
-		}
-
-		ref := forge.RepoRef{Forge: kind, Owner: r.PathValue("owner"), Name: r.PathValue("name")}
-		if !deps.Repos.Contains(ref.String()) {
+		ref, ok := viewableRepo(r, deps)
+		if !ok {
 			http.NotFound(w, r)
 			return
 		}
@@ -289,11 +507,100 @@ func repoHandler(deps *Deps) http.HandlerFunc {
 	}
 }
 
+// viewableRepo parses the repo from the request path and reports whether
+// the visitor may see it. Hidden repos 404 rather than 403 so their
+// existence does not leak.
+func viewableRepo(r *http.Request, deps *Deps) (forge.RepoRef, bool) {
+	host := forge.Host{Kind: forge.KindGitea}
+	if k := r.PathValue("forge"); k != "" {
+		var ok bool
+		if host, ok = forge.ParseHost(k); !ok {
+			return forge.RepoRef{}, false
+		}
+	}
+	ref := forge.RepoRef{Forge: host.Kind, Instance: host.Instance, Owner: r.PathValue("owner"), Name: r.PathValue("name")}
+	return ref, canView(r, deps, ref)
+}
+
+// doctorHandler serves GET /doctor/{forge}/{owner}/{name}, the
+// preflight diagnostics of one repo. Reports are reused for doctorTTL, so
+// the page does not auto-refresh.
+func doctorHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, ok := viewableRepo(r, deps)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if !mayDiagnose(r, deps, ref) {
+			http.Error(w, "the doctor needs write access to the repo", http.StatusForbidden)
+			return
+		}
+		f := forgeFor(deps, ref)
+		if f == nil {
+			http.NotFound(w, r)
+			return
+		}
+		report, err := deps.doctorReport(r.Context(), f, ref)
+		if err != nil {
+			serverError(w, "failed to get repo", err, "repo", ref)
+			return
+		}
+		renderHTML(w, "doctor.html", DoctorData{
+			Forge:     ref.Host(),
+			Owner:     ref.Owner,
+			Name:      ref.Name,
+			Checks:    report.checks,
+			CheckedAt: report.at,
+			Viewer:    viewerFor(r, deps),
+		})
+	}
+}
+
+// mayDiagnose reports whether the visitor behind r may run the doctor on
+// ref: its report names protection whitelists and webhook URLs, and a run
+// pushes to the forge.
+func mayDiagnose(r *http.Request, deps *Deps, ref forge.RepoRef) bool {
+	if admin.Authorized(r, deps.AdminToken) {
+		return true
+	}
+	return deps.Auth != nil && deps.Auth.CanWrite(r, ref)
+}
+
+// doctorReport returns ref's doctor report, running the doctor when the
+// cached one is older than doctorTTL. Concurrent requests share one run.
+func (d *Deps) doctorReport(ctx context.Context, f forge.Forge, ref forge.RepoRef) (doctorReport, error) {
+	d.doctorMu.Lock()
+	defer d.doctorMu.Unlock()
+	now := time.Now()
+	if rep, ok := d.doctorReports[ref]; ok && now.Sub(rep.at) < doctorTTL {
+		return rep, nil
+	}
+	repo, err := d.Queue.GetOrCreateRepo(ctx, ref)
+	if err != nil {
+		return doctorReport{}, err
+	}
+	report := doctor.Run(ctx, f, d.Queue, ref, repo.ID, forge.SetupConfig{
+		ExternalURL: d.ExternalURL, BranchPatterns: d.BranchPatterns,
+	})
+	if d.doctorReports == nil {
+		d.doctorReports = make(map[forge.RepoRef]doctorReport)
+	}
+	for k, v := range d.doctorReports {
+		if now.Sub(v.at) >= doctorTTL {
+			delete(d.doctorReports, k)
+		}
+	}
+	rep := doctorReport{checks: report.Checks, at: now}
+	d.doctorReports[ref] = rep
+	return rep, nil
+}
+
 // serveRepoDetail renders the repo queue listing page.
 func serveRepoDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref forge.RepoRef) {
 	owner, name := ref.Owner, ref.Name
 	ctx := r.Context()
-	repo, err := deps.Queue.GetOrCreateRepo(ctx, string(ref.Forge), owner, name)
+	repo, err := deps.Queue.GetOrCreateRepo(ctx, ref)
 	if err != nil {
 		serverError(w, "failed to get repo", err, "owner", owner, "name", name)
 		return
@@ -306,10 +613,25 @@ func serveRepoDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref for
 	}
 
 	data := RepoDetailData{
-		Forge:           ref.Forge,
+		Forge:           ref.Host(),
 		Owner:           owner,
 		Name:            name,
+		Shadow:          slices.Contains(deps.ShadowRepos, ref),
+		Doctor:          mayDiagnose(r, deps, ref),
 		RefreshInterval: deps.RefreshInterval,
+		Live:            deps.Events != nil,
+		Viewer:          viewerFor(r, deps),
+	}
+	if data.Shadow {
+		actions, err := deps.Queue.ShadowActions(ctx, repo.ID, shadowActionsShown)
+		if err != nil {
+			slog.Warn("list shadow actions", "error", err)
+		}
+		for _, a := range actions {
+			data.ShadowActions = append(data.ShadowActions, ShadowAction{
+				PrNumber: a.PrNumber, Method: a.Method, Detail: a.Detail, At: a.CreatedAt.Time,
+			})
+		}
 	}
 	f := forgeFor(deps, ref)
 	if f != nil {
@@ -338,12 +660,17 @@ func serveRepoDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref for
 		data.Batches = append(data.Batches, rb)
 	}
 
+	now := time.Now()
+	etas := estimate(ctx, deps, repo.ID, entries, batches, now)
 	for _, e := range entries {
 		de := RepoDetailEntry{
 			PrNumber:     e.PrNumber,
 			TargetBranch: e.TargetBranch,
 			State:        string(e.State),
 		}
+		if at, ok := etas[e.PrNumber]; ok {
+			de.ETA = eta.Format(at, now)
+		}
 		if e.ActiveBatchID.Valid {
 			if b := byID[e.ActiveBatchID.Int64]; b != nil {
 				de.BatchBucket = string(batch.Bucket(b, e.ID))
@@ -355,6 +682,17 @@ func serveRepoDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref for
 	renderHTML(w, "repo.html", data)
 }
 
+// estimate predicts landing times for the repo page, which already holds the
+// entries and batches. Failures only cost the ETA column.
+func estimate(ctx context.Context, deps *Deps, repoID int64, entries []pg.QueueEntry, batches []pg.Batch, now time.Time) map[int64]time.Time {
+	m, err := eta.Load(ctx, deps.Queue, repoID, deps.batchMax())
+	if err != nil {
+		slog.Warn("failed to estimate landing times", "error", err)
+		return nil
+	}
+	return m.Predict(entries, batches, now)
+}
+
 // servePRDetail renders the PR detail page.
 func servePRDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref forge.RepoRef, prNumberStr string) {
 	owner, name := ref.Owner, ref.Name
@@ -365,7 +703,7 @@ func servePRDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref forge
 	}
 
 	ctx := r.Context()
-	repo, err := deps.Queue.GetOrCreateRepo(ctx, string(ref.Forge), owner, name)
+	repo, err := deps.Queue.GetOrCreateRepo(ctx, ref)
 	if err != nil {
 		serverError(w, "failed to get repo", err, "owner", owner, "name", name)
 		return
@@ -378,13 +716,15 @@ func servePRDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref forge
 	}
 
 	data := PRDetailData{
-		Forge:           ref.Forge,
+		Forge:           ref.Host(),
 		Owner:           owner,
 		Name:            name,
 		PrNumber:        prNumber,
 		Title:           "—",
 		Author:          "—",
 		RefreshInterval: deps.RefreshInterval,
+		Live:            deps.Events != nil,
+		Viewer:          viewerFor(r, deps),
 	}
 
 	if entry == nil {
@@ -406,6 +746,16 @@ func servePRDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref forge
 	}
 	data.Position = int(pos)
 
+	now := time.Now()
+	etas, err := eta.ForRepo(ctx, deps.Queue, repo.ID, deps.batchMax(), now)
+	if err != nil {
+		slog.Warn("failed to estimate landing time", "pr", prNumber, "error", err)
+	}
+	if at, ok := etas[prNumber]; ok {
+		data.ETA = eta.Format(at, now)
+		data.ETAAt = at.UTC()
+	}
+
 	// Fetch PR title/author from the forge (graceful degradation).
 	f := forgeFor(deps, ref)
 	if f != nil {
@@ -450,52 +800,14 @@ func servePRDetail(w http.ResponseWriter, r *http.Request, deps *Deps, ref forge
 
 		var required []string
 		if f != nil {
-			required, err = monitor.ResolveRequiredChecks(ctx, f, owner, name, entry.TargetBranch, deps.FallbackChecks)
+			required, err = monitor.ResolveRequiredChecks(ctx, f, owner, name, entry.TargetBranch, deps.fallbackChecks())
 			if err != nil {
 				slog.Warn("failed to resolve required checks", "pr", prNumber, "error", err)
 			}
 		}
 
-		data.CheckStatuses = mergeCheckStatuses(recorded, required)
+		data.CheckStatuses = monitor.MergeCheckStatuses(recorded, required)
 	}
 
 	renderHTML(w, "pr.html", data)
 }
-
-// mergeCheckStatuses combines recorded check statuses with the required checks
-// list. Any required check that hasn't reported yet appears as pending.
-// If required is empty (meaning "any single success"), only recorded statuses
-// are returned.
-func mergeCheckStatuses(recorded []pg.CheckStatus, required []string) []pg.CheckStatus {
-	if len(required) == 0 {
-		return recorded
-	}
-
-	result := make([]pg.CheckStatus, 0, len(required))
-	// Add required checks in order, using recorded state or pending.
-	recordedMap := make(map[string]pg.CheckStatus, len(recorded))
-	for _, s := range recorded {
-		recordedMap[s.Context] = s
-	}
-	for _, ctx := range required {
-		if s, ok := recordedMap[ctx]; ok {
-			result = append(result, s)
-		} else {
-			result = append(result, pg.CheckStatus{Context: ctx, State: pg.CheckStatePending})
-		}
-	}
-	// Append any recorded checks not in the required list (unexpected extras).
-	for _, s := range recorded {
-		found := false
-		for _, req := range required {
-			if s.Context == req {
-				found = true
-				break
-			}
-		}
-		if !found {
-			result = append(result, s)
-		}
-	}
-	return result
-}
@@ -0,0 +1,63 @@
+// Live updates for the dashboard. Without JS the <noscript> meta refresh
+// reloads the page; with JS we instead re-fetch the page and swap #live in
+// place whenever /events reports a change to what is shown, or every
+// data-refresh seconds when the event stream is unavailable.
+(() => {
+  "use strict";
+
+  const body = document.body;
+  const interval = (parseInt(body.dataset.refresh, 10) || 10) * 1000;
+  const eventsURL = body.dataset.events;
+
+  let inflight = false;
+  let again = false;
+  let timer = 0;
+
+  async function refresh() {
+    if (inflight) {
+      again = true;
+      return;
+    }
+    inflight = true;
+    try {
+      const res = await fetch(location.href, { headers: { Accept: "text/html" } });
+      if (res.ok) {
+        const doc = new DOMParser().parseFromString(await res.text(), "text/html");
+        const next = doc.getElementById("live");
+        const cur = document.getElementById("live");
+        if (next && cur) {
+          cur.replaceWith(next);
+          document.title = doc.title;
+        }
+      }
+    } catch {
+      // Server unreachable; the next event or tick tries again.
+    } finally {
+      inflight = false;
+      if (again) {
+        again = false;
+        refresh();
+      }
+    }
+  }
+
+  // A single queue operation touches several rows; coalesce the burst.
+  function schedule() {
+    clearTimeout(timer);
+    timer = setTimeout(refresh, 250);
+  }
+
+  if (!eventsURL || !window.EventSource) {
+    setInterval(refresh, interval);
+    return;
+  }
+
+  const source = new EventSource(eventsURL);
+  let connected = false;
+  source.addEventListener("change", schedule);
+  source.addEventListener("open", () => {
+    // Changes may have been missed while reconnecting.
+    if (connected) schedule();
+    connected = true;
+  });
+})();
@@ -1,14 +1,18 @@
 package web_test
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Mic92/gitea-mq/internal/auth"
 	"github.com/Mic92/gitea-mq/internal/forge"
 	"github.com/Mic92/gitea-mq/internal/gitea"
 	"github.com/Mic92/gitea-mq/internal/queue"
@@ -123,6 +127,43 @@ func TestOverviewNoReposShowsHelpMessage(t *testing.T) {
 	}
 }
 
+// The overview shows the webhook backlog and failed deliveries, but not
+// those of repos the visitor cannot see.
+func TestOverviewShowsInbox(t *testing.T) {
+	svc, ctx, _ := testutil.TestQueueService(t)
+	for i, repo := range []string{"gitea:org/app", "gitea:org/app", "gitea:org/hidden"} {
+		if _, err := svc.StoreDelivery(ctx, "gitea", fmt.Sprint(i), "status", repo, []byte(`{}`), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for {
+		d, err := svc.ClaimDelivery(ctx, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d == nil {
+			break
+		}
+		if err := svc.FailDelivery(ctx, d.ID, errors.New("forge said no"), time.Now(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.StoreDelivery(ctx, "gitea", "fresh", "status", "gitea:org/app", []byte(`{}`), []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	body := getPage(t, newDeps(svc, nil, giteaRef("org", "app")), "/")
+	if !strings.Contains(body, "1 waiting to be processed, 2 failed") {
+		t.Errorf("expected inbox counts in body:\n%s", body)
+	}
+	if !strings.Contains(body, "forge said no") || !strings.Contains(body, `href="/repo/gitea/org/app"`) {
+		t.Errorf("expected failed delivery of org/app:\n%s", body)
+	}
+	if strings.Contains(body, "org/hidden") {
+		t.Error("failed delivery of an unlisted repo leaked")
+	}
+}
+
 func TestRepoDetailShowsPRs(t *testing.T) {
 	svc, ctx, repoID := testutil.TestQueueService(t)
 
@@ -315,3 +356,273 @@ func TestRepoHandler_ForgeRouting(t *testing.T) {
 		}
 	}
 }
+
+func TestDoctorPage(t *testing.T) {
+	svc, _, _ := testutil.TestQueueService(t)
+	mock := &gitea.MockClient{
+		GetRepoFn: func(_ context.Context, owner, repo string) (*gitea.Repo, error) {
+			return &gitea.Repo{
+				DefaultBranch:     "main",
+				Permissions:       gitea.RepoPermissions{Admin: true, Push: true, Pull: true},
+				HasPullRequests:   true,
+				AllowMergeCommits: true,
+			}, nil
+		},
+	}
+	deps := newDeps(svc, giteaForges(mock), giteaRef("org", "app"))
+	doctorGet := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		web.NewMux(deps).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The report names whitelists and webhook URLs: viewers may not run it.
+	if rec := doctorGet("/doctor/gitea/org/app", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("anonymous: code=%d want 403", rec.Code)
+	}
+	if strings.Contains(getPage(t, deps, "/repo/gitea/org/app"), "/doctor/") {
+		t.Error("repo page links to the doctor for a visitor who may not run it")
+	}
+	deps.AdminToken = "admin-secret"
+	if rec := doctorGet("/doctor/gitea/org/app", "wrong"); rec.Code != http.StatusForbidden {
+		t.Errorf("wrong token: code=%d want 403", rec.Code)
+	}
+
+	rec := doctorGet("/doctor/gitea/org/app", "admin-secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin token: code=%d want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	// No protection rule covers main, so the required check fails with a fix.
+	if !strings.Contains(body, "no branch protection rule covers main") {
+		t.Errorf("expected required-check failure, body:\n%s", body)
+	}
+	if !strings.Contains(body, "GITEA_MQ_EXTERNAL_URL is not set") {
+		t.Errorf("expected webhook warning without external URL, body:\n%s", body)
+	}
+	if !strings.Contains(body, "no CI result on a gitea-mq/* branch") {
+		t.Errorf("expected CI warning for a repo without history, body:\n%s", body)
+	}
+
+	// A second visit within the TTL reuses the report.
+	before := len(mock.CallsTo("GetRepo"))
+	if rec := doctorGet("/doctor/gitea/org/app", "admin-secret"); rec.Code != http.StatusOK {
+		t.Fatalf("cached visit: code=%d want 200", rec.Code)
+	}
+	if after := len(mock.CallsTo("GetRepo")); after != before {
+		t.Errorf("GetRepo calls went from %d to %d; expected a cached report", before, after)
+	}
+
+	if rec := doctorGet("/doctor/gitea/org/unknown", "admin-secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("unmanaged repo: code=%d want 404", rec.Code)
+	}
+}
+
+func TestRepoPage_Shadow(t *testing.T) {
+	svc, ctx, _ := testutil.TestQueueService(t)
+	ref := giteaRef("org", "app")
+	deps := newDeps(svc, giteaForges(&gitea.MockClient{}), ref)
+
+	if strings.Contains(getPage(t, deps, "/repo/gitea/org/app"), "shadow mode") {
+		t.Error("repo not in shadow mode shows the shadow section")
+	}
+
+	deps.ShadowRepos = []forge.RepoRef{ref}
+	repo, err := svc.GetOrCreateRepo(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RecordShadowAction(ctx, repo.ID, 42, "FastForward", "fast-forward main to abc12345"); err != nil {
+		t.Fatal(err)
+	}
+	body := getPage(t, deps, "/repo/gitea/org/app")
+	for _, want := range []string{"shadow mode", "FastForward", "fast-forward main to abc12345", "/pr/42"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q on the repo page, body:\n%s", want, body)
+		}
+	}
+	if !strings.Contains(getPage(t, deps, "/"), "badge-shadow") {
+		t.Error("overview does not mark the shadowed repo")
+	}
+}
+
+// readEvent returns the next "change" event's data from an SSE stream.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var event string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event == "change":
+			return strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEvents_StreamsRepoChanges(t *testing.T) {
+	svc, ctx, repoID := testutil.TestQueueService(t)
+	lib, err := svc.GetOrCreateRepo(ctx, forge.RepoRef{Forge: forge.KindGitea, Owner: "org", Name: "lib"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := newDeps(svc, nil, giteaRef("org", "app"), giteaRef("org", "lib"))
+	deps.Events = web.NewHub()
+	srv := httptest.NewServer(web.NewMux(deps))
+	t.Cleanup(srv.Close)
+
+	// Pages advertise the stream for live.js and keep meta refresh for no-JS.
+	body := getPage(t, deps, "/repo/gitea/org/app")
+	if !strings.Contains(body, `data-events="/events?repo=gitea:org/app"`) {
+		t.Errorf("expected data-events attribute, body:\n%s", body)
+	}
+	if !strings.Contains(body, `<noscript><meta http-equiv="refresh" content="10"></noscript>`) {
+		t.Errorf("expected noscript meta refresh, body:\n%s", body)
+	}
+
+	open := func(query string) *bufio.Reader {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/events"+query, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = res.Body.Close() })
+		if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		return bufio.NewReader(res.Body)
+	}
+	all := open("")
+	app := open("?repo=gitea:org/app")
+
+	deps.Events.Publish(-1) // no such repo: skipped
+	deps.Events.Publish(lib.ID)
+	deps.Events.Publish(repoID)
+
+	if got := readEvent(t, all); got != "gitea:org/lib" {
+		t.Errorf("unfiltered stream: first event = %q, want gitea:org/lib", got)
+	}
+	if got := readEvent(t, all); got != "gitea:org/app" {
+		t.Errorf("unfiltered stream: second event = %q, want gitea:org/app", got)
+	}
+	if got := readEvent(t, app); got != "gitea:org/app" {
+		t.Errorf("repo stream: event = %q, want only gitea:org/app", got)
+	}
+
+	rec := httptest.NewRecorder()
+	web.NewMux(deps).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?repo=gitea:org/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown repo: code=%d want 404", rec.Code)
+	}
+}
+
+func TestBadges(t *testing.T) {
+	svc, ctx, repoID := testutil.TestQueueService(t)
+	deps := newDeps(svc, nil, giteaRef("org", "app"))
+	mux := web.NewMux(deps)
+
+	get := func(path string) (int, http.Header, string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Header(), rec.Body.String()
+	}
+	expect := func(path, want string) {
+		t.Helper()
+		code, hdr, body := get(path)
+		if code != http.StatusOK {
+			t.Fatalf("GET %s: code=%d", path, code)
+		}
+		if ct := hdr.Get("Content-Type"); ct != "image/svg+xml" {
+			t.Errorf("GET %s: Content-Type=%q", path, ct)
+		}
+		if cc := hdr.Get("Cache-Control"); !strings.Contains(cc, "max-age=") {
+			t.Errorf("GET %s: Cache-Control=%q", path, cc)
+		}
+		if !strings.Contains(body, `aria-label="merge queue: `+want+`"`) {
+			t.Errorf("GET %s: want %q, body:\n%s", path, want, body)
+		}
+	}
+
+	expect("/badge/gitea/org/app/queue.svg", "0 queued")
+	expect("/badge/gitea/org/app/status.svg", "idle")
+
+	if _, err := svc.Enqueue(ctx, repoID, 7, "sha7", "release"); err != nil {
+		t.Fatal(err)
+	}
+	expect("/badge/gitea/org/app/status.svg", "paused")
+
+	entry := testutil.EnqueueTesting(t, svc, repoID, 42, "abc123", "mergesha")
+	if _, err := svc.Enqueue(ctx, repoID, 43, "def456", "main"); err != nil {
+		t.Fatal(err)
+	}
+	expect("/badge/gitea/org/app/queue.svg", "3 queued")
+	expect("/badge/gitea/org/app/queue.svg?branch=main", "2 queued")
+	expect("/badge/gitea/org/app/status.svg?branch=main", "testing")
+	expect("/badge/gitea/org/app/status.svg?branch=release", "paused")
+	expect("/badge/gitea/org/app/pr/42.svg", "testing")
+	expect("/badge/gitea/org/app/pr/43.svg", "#2 in queue")
+	expect("/badge/gitea/org/app/pr/99.svg", "not queued")
+
+	if err := svc.SaveCheckStatus(ctx, entry.ID, "ci/build", pg.CheckStateFailure, ""); err != nil {
+		t.Fatal(err)
+	}
+	expect("/badge/gitea/org/app/status.svg", "failing")
+
+	for _, path := range []string{
+		"/badge/gitea/org/unknown/queue.svg",
+		"/badge/gitlab/org/app/queue.svg",
+		"/badge/gitea/org/app/other.svg",
+		"/badge/gitea/org/app/pr/42",
+		"/badge/gitea/org/app/pr/x.svg",
+	} {
+		if code, _, _ := get(path); code != http.StatusNotFound {
+			t.Errorf("GET %s: code=%d want 404", path, code)
+		}
+	}
+}
+
+func TestLogin_AnonymousSeesPublicReposOnly(t *testing.T) {
+	svc, _, _ := testutil.TestQueueService(t)
+	mock := &gitea.MockClient{
+		GetRepoFn: func(_ context.Context, _, name string) (*gitea.Repo, error) {
+			return &gitea.Repo{Name: name, Private: name == "secret"}, nil
+		},
+	}
+	forges := giteaForges(mock)
+	deps := newDeps(svc, forges, giteaRef("org", "app"), giteaRef("org", "secret"))
+	deps.Auth = auth.New(auth.Config{
+		Providers: []*auth.Provider{auth.NewGiteaProvider("https://gitea.example.com", "client", "secret")},
+		Queue:     svc,
+		Forges:    forges,
+	})
+
+	body := getPage(t, deps, "/")
+	if !strings.Contains(body, "org/app") || strings.Contains(body, "org/secret") {
+		t.Errorf("overview should list only the public repo:\n%s", body)
+	}
+	if !strings.Contains(body, `href="/auth/login/gitea?next=`) {
+		t.Errorf("overview should offer a login link:\n%s", body)
+	}
+
+	for _, path := range []string{
+		"/repo/gitea/org/secret",
+		"/repo/gitea/org/secret/pr/1",
+		"/badge/gitea/org/secret/queue.svg",
+	} {
+		rec := httptest.NewRecorder()
+		web.NewMux(deps).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404 for private repo, got %d", path, rec.Code)
+		}
+	}
+	getPage(t, deps, "/repo/gitea/org/app")
+}
@@ -7,8 +7,6 @@ import (
 	gh "github.com/google/go-github/v84/github"
 
 	"github.com/Mic92/gitea-mq/internal/forge"
-	"github.com/Mic92/gitea-mq/internal/github"
-	"github.com/Mic92/gitea-mq/internal/queue"
 	"github.com/Mic92/gitea-mq/internal/store/pg"
 )
 
@@ -24,10 +22,10 @@ var prTriggerActions = map[string]bool{
 	"edited":              true, // base-branch retarget arrives as edited
 }
 
-// GithubHandler validates X-Hub-Signature-256, dispatches by X-GitHub-Event,
-// and returns 200 for anything it does not act on so GitHub does not mark the
-// delivery as failed.
-func GithubHandler(secret []byte, repos RepoLookup, queueSvc *queue.Service, triggerDiscovery func()) http.Handler {
+// GithubHandler validates X-Hub-Signature-256 and records every verified
+// event in the inbox; routing decides later which ones matter. Anything that
+// cannot be stored gets a 500 so GitHub redelivers it.
+func GithubHandler(secret []byte, inbox *Inbox) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		payload, err := gh.ValidatePayload(r, secret)
 		if err != nil {
@@ -35,64 +33,18 @@ func GithubHandler(secret []byte, repos RepoLookup, queueSvc *queue.Service, tri
 			return
 		}
 
-		event, err := gh.ParseWebHook(gh.WebHookType(r), payload)
-		if err != nil {
-			slog.Warn("github webhook: parse failed", "type", gh.WebHookType(r), "err", err)
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		switch e := event.(type) {
-		case *gh.PullRequestEvent:
-			if !prTriggerActions[e.GetAction()] {
-				break
-			}
-			if rm, ok := lookupGithubRepo(repos, e.GetRepo()); ok && rm.TriggerPoll != nil {
-				rm.TriggerPoll()
-			}
-
-		case *gh.CheckRunEvent:
-			cr := e.GetCheckRun()
-			if e.GetAction() != "completed" || cr.GetStatus() != "completed" {
-				break
-			}
-			if forge.IsOwnContext(cr.GetName()) {
-				break
-			}
-			rm, ok := lookupGithubRepo(repos, e.GetRepo())
-			if !ok {
-				break
-			}
-			check := forge.Check{
-				State:       github.CheckRunToState(cr.GetStatus(), cr.GetConclusion()),
-				Description: cr.GetOutput().GetSummary(),
-				TargetURL:   cr.GetDetailsURL(),
-			}
-			routeCheck(r.Context(), rm, queueSvc, cr.GetHeadSHA(), cr.GetName(), check)
-			maybeTriggerPoll(rm, check.State)
-
-		case *gh.StatusEvent:
-			if forge.IsOwnContext(e.GetContext()) {
-				break
-			}
-			rm, ok := lookupGithubRepo(repos, e.GetRepo())
-			if !ok {
-				break
-			}
-			check := forge.Check{
-				State:       forge.ParseCheckState(e.GetState()),
-				Description: e.GetDescription(),
-				TargetURL:   e.GetTargetURL(),
-			}
-			routeCheck(r.Context(), rm, queueSvc, e.GetSHA(), e.GetContext(), check)
-			maybeTriggerPoll(rm, check.State)
-
-		case *gh.InstallationEvent, *gh.InstallationRepositoriesEvent:
-			if triggerDiscovery != nil {
-				triggerDiscovery()
+		eventType := gh.WebHookType(r)
+		var repoKey string
+		if event, err := gh.ParseWebHook(eventType, payload); err == nil {
+			if e, ok := event.(interface{ GetRepo() *gh.Repository }); ok {
+				repoKey = githubRepoKey(e.GetRepo())
 			}
 		}
-
+		if err := inbox.store(r.Context(), forge.Host{Kind: forge.KindGithub}, r, eventType, repoKey, payload); err != nil {
+			slog.Error("failed to store webhook delivery", "type", eventType, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 	})
 }
@@ -105,10 +57,10 @@ func maybeTriggerPoll(rm *RepoMonitor, state pg.CheckState) {
 	}
 }
 
-func lookupGithubRepo(repos RepoLookup, r *gh.Repository) (*RepoMonitor, bool) {
+func githubRepoKey(r *gh.Repository) string {
 	owner, name := r.GetOwner().GetLogin(), r.GetName()
 	if owner == "" || name == "" {
-		return nil, false
+		return ""
 	}
-	return repos.LookupMonitor(string(forge.KindGithub) + ":" + owner + "/" + name)
+	return string(forge.KindGithub) + ":" + owner + "/" + name
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/testutil"
 	"github.com/Mic92/gitea-mq/internal/webhook"
 )
 
@@ -23,14 +24,24 @@ func ghPost(t *testing.T, h http.Handler, event, body string, sign bool) *httpte
 	return w
 }
 
-func ghHandler(repos webhook.MapRepoLookup, disc func()) http.Handler {
-	return webhook.GithubHandler([]byte(testSecret), repos, nil, disc)
+// ghHandler returns a handler whose deliveries are processed on every
+// request, as if the inbox workers were instant.
+func ghHandler(t *testing.T, repos webhook.MapRepoLookup, disc func()) http.Handler {
+	svc, ctx, _ := testutil.TestQueueService(t)
+	inbox := &webhook.Inbox{Queue: svc, Repos: repos, TriggerDiscovery: disc}
+	h := webhook.GithubHandler([]byte(testSecret), inbox)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		if err := inbox.Drain(ctx); err != nil {
+			t.Error(err)
+		}
+	})
 }
 
 func TestGithubHandler_PRTriggersPoll(t *testing.T) {
 	var polled int
 	rm := &webhook.RepoMonitor{TriggerPoll: func() { polled++ }}
-	h := ghHandler(webhook.MapRepoLookup{"github:org/app": rm}, nil)
+	h := ghHandler(t, webhook.MapRepoLookup{"github:org/app": rm}, nil)
 
 	for _, tc := range []struct {
 		action string
@@ -54,11 +65,15 @@ func TestGithubHandler_PRTriggersPoll(t *testing.T) {
 }
 
 // check_run events for our own context (gitea-mq, gitea-mq/*) must be dropped
-// before any monitor work to avoid feedback loops. The handler is constructed
-// with a nil queue: if routing were attempted the test would panic.
+// before any monitor work to avoid feedback loops; even a green one must not
+// poke the poller.
 func TestGithubHandler_CheckRunIgnoresOwn(t *testing.T) {
-	rm := &webhook.RepoMonitor{Deps: &monitor.Deps{Owner: "org", Repo: "app"}}
-	h := ghHandler(webhook.MapRepoLookup{"github:org/app": rm}, nil)
+	var polled int
+	rm := &webhook.RepoMonitor{
+		Deps:        &monitor.Deps{Owner: "org", Repo: "app"},
+		TriggerPoll: func() { polled++ },
+	}
+	h := ghHandler(t, webhook.MapRepoLookup{"github:org/app": rm}, nil)
 
 	for _, name := range []string{"gitea-mq", "gitea-mq/ci"} {
 		body := `{"action":"completed","check_run":{"name":"` + name + `","status":"completed","conclusion":"success","head_sha":"abc"},"repository":{"name":"app","owner":{"login":"org"}}}`
@@ -66,11 +81,14 @@ func TestGithubHandler_CheckRunIgnoresOwn(t *testing.T) {
 			t.Errorf("%s: code=%d", name, w.Code)
 		}
 	}
+	if polled != 0 {
+		t.Errorf("own check runs triggered %d polls", polled)
+	}
 }
 
 func TestGithubHandler_InstallationTriggersDiscovery(t *testing.T) {
 	var fired int
-	h := ghHandler(webhook.MapRepoLookup{}, func() { fired++ })
+	h := ghHandler(t, webhook.MapRepoLookup{}, func() { fired++ })
 
 	ghPost(t, h, "installation", `{"action":"created"}`, true)
 	ghPost(t, h, "installation_repositories", `{"action":"added"}`, true)
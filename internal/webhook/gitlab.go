@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/gitlab"
+)
+
+// gitlabMRTriggerActions are the merge request hook actions that can change
+// the queue. Toggling "merge when pipeline succeeds" arrives as update.
+var gitlabMRTriggerActions = map[string]bool{
+	"open":   true,
+	"reopen": true,
+	"close":  true,
+	"merge":  true,
+	"update": true,
+}
+
+// GitlabHandler checks X-Gitlab-Token against secret and records every
+// verified event in the inbox. GitLab sends the secret itself rather than a
+// signature, so it is compared in constant time and never stored.
+func GitlabHandler(secret string, inbox *Inbox) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get("X-Gitlab-Token")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		eventType := r.Header.Get("X-Gitlab-Event")
+		var e gitlabEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			slog.Warn("malformed webhook payload", "forge", forge.KindGitlab, "error", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := inbox.store(r.Context(), forge.Host{Kind: forge.KindGitlab}, r, eventType, e.repoKey(), body); err != nil {
+			slog.Error("failed to store webhook delivery", "type", eventType, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// gitlabEvent is the subset of GitLab's pipeline and merge request hook
+// payloads we need.
+type gitlabEvent struct {
+	ObjectKind       string `json:"object_kind"` // pipeline, merge_request, ...
+	ObjectAttributes struct {
+		ID     int64  `json:"id"`
+		SHA    string `json:"sha"`
+		Status string `json:"status"`
+		Action string `json:"action"`
+	} `json:"object_attributes"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+}
+
+func (e *gitlabEvent) repoKey() string {
+	if e.Project.PathWithNamespace == "" {
+		return ""
+	}
+	return string(forge.KindGitlab) + ":" + e.Project.PathWithNamespace
+}
+
+// routeGitlab feeds pipeline results into the check path under
+// gitlab.PipelineContext; merge request changes reconcile the poller.
+func (in *Inbox) routeGitlab(ctx context.Context, eventType string, payload []byte) (*route, error) {
+	var e gitlabEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	switch e.ObjectKind {
+	case "pipeline":
+		a := e.ObjectAttributes
+		return in.routeCheck(ctx, e.repoKey(), a.SHA, gitlab.PipelineContext, forge.Check{
+			State:       gitlab.CheckState(a.Status),
+			Description: "pipeline " + a.Status,
+			TargetURL:   e.Project.WebURL + "/-/pipelines/" + strconv.FormatInt(a.ID, 10),
+		})
+	case "merge_request":
+		r := &route{Decision: Decision{Action: ActionPoll, Repo: e.repoKey(), Poll: true}}
+		if !gitlabMRTriggerActions[e.ObjectAttributes.Action] {
+			return r.ignore("merge_request action " + e.ObjectAttributes.Action + " does not change the queue"), nil
+		}
+		rm, ok := in.lookup(r.Repo)
+		if !ok {
+			return r.ignore("repo not managed"), nil
+		}
+		r.rm = rm
+		return r, nil
+	}
+	return (&route{}).ignore(eventType + " events are not handled"), nil
+}
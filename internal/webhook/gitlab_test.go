@@ -0,0 +1,94 @@
+package webhook_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Mic92/gitea-mq/internal/testutil"
+	"github.com/Mic92/gitea-mq/internal/webhook"
+)
+
+func glPost(t *testing.T, h http.Handler, event, body, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhook/gitlab", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitlab-Event", event)
+	if token != "" {
+		req.Header.Set("X-Gitlab-Token", token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGitlabHandler_VerifiesToken(t *testing.T) {
+	svc, _, _ := testutil.TestQueueService(t)
+	h := webhook.GitlabHandler(testSecret, &webhook.Inbox{Queue: svc})
+	body := `{"object_kind":"pipeline","project":{"path_with_namespace":"org/app"}}`
+
+	for _, token := range []string{"", "wrong"} {
+		if w := glPost(t, h, "Pipeline Hook", body, token); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: code=%d, want 401", token, w.Code)
+		}
+	}
+	if w := glPost(t, h, "Pipeline Hook", body, testSecret); w.Code != http.StatusOK {
+		t.Errorf("valid token: code=%d", w.Code)
+	}
+}
+
+func TestGitlabHandler_MRTriggersPoll(t *testing.T) {
+	svc, ctx, _ := testutil.TestQueueService(t)
+	var polled int
+	inbox := &webhook.Inbox{Queue: svc, Repos: webhook.MapRepoLookup{
+		"gitlab:org/app": {TriggerPoll: func() { polled++ }},
+	}}
+	h := webhook.GitlabHandler(testSecret, inbox)
+
+	for _, tc := range []struct {
+		action string
+		want   int
+	}{
+		{"update", 1}, // also covers toggling merge when pipeline succeeds
+		{"close", 1},
+		{"approved", 0},
+	} {
+		polled = 0
+		body := `{"object_kind":"merge_request","object_attributes":{"iid":1,"action":"` + tc.action + `"},"project":{"path_with_namespace":"org/app"}}`
+		if w := glPost(t, h, "Merge Request Hook", body, testSecret); w.Code != http.StatusOK {
+			t.Fatalf("%s: code=%d", tc.action, w.Code)
+		}
+		if err := inbox.Drain(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if polled != tc.want {
+			t.Errorf("%s: polled=%d want %d", tc.action, polled, tc.want)
+		}
+	}
+}
+
+// A finished pipeline on a merge branch is a check result for the PR being
+// tested and is mirrored onto its head.
+func TestGitlabHandler_PipelineRoutesToCheck(t *testing.T) {
+	env := setup(t)
+	testutil.EnqueueTesting(t, env.svc, env.repoID, 7, "pr-head", "merge-sha")
+	repos := env.inbox.Repos.(webhook.MapRepoLookup)
+	repos["gitlab:org/app"] = repos["gitea:org/app"]
+	h := webhook.GitlabHandler(testSecret, env.inbox)
+
+	body := `{"object_kind":"pipeline","object_attributes":{"id":42,"sha":"merge-sha","status":"failed"},"project":{"path_with_namespace":"org/app","web_url":"https://gitlab.example.com/org/app"}}`
+	if w := glPost(t, h, "Pipeline Hook", body, testSecret); w.Code != http.StatusOK {
+		t.Fatalf("code=%d", w.Code)
+	}
+	if err := env.inbox.Drain(env.ctx); err != nil {
+		t.Fatal(err)
+	}
+	calls := env.mock.CallsTo("CreateCommitStatus")
+	if len(calls) == 0 {
+		t.Fatal("pipeline result not mirrored onto the PR head")
+	}
+	if sha := calls[0].Args[2]; sha != "pr-head" {
+		t.Errorf("mirrored onto %v, want pr-head", sha)
+	}
+}
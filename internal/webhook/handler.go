@@ -1,8 +1,10 @@
-// Package webhook implements the HTTP handler that receives Gitea webhook
-// events (commit_status) and routes them to the check monitor.
+// Package webhook implements the HTTP handlers that receive forge webhook
+// events, persist them in an inbox and route them to the check monitor and
+// pollers.
 package webhook
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -23,6 +25,12 @@ type RepoMonitor struct {
 	// for PR-level webhooks (auto-merge toggle, close, push) where the
 	// poller already owns the correct enqueue/dequeue logic.
 	TriggerPoll func()
+	// Actions handles the buttons of the gitea-mq status. Nil when the
+	// forge offers none.
+	Actions forge.StatusActions
+	// Dequeue removes a queued PR on the request of login, who pressed
+	// "Remove from queue".
+	Dequeue func(ctx context.Context, prNumber int64, login string) error
 }
 
 // RepoLookup abstracts how the webhook handler finds a repo's monitor.
@@ -40,8 +48,34 @@ func (m MapRepoLookup) LookupMonitor(fullName string) (*RepoMonitor, bool) {
 	return rm, ok
 }
 
-// Handler returns an http.Handler that processes Gitea webhook events.
-func Handler(secret string, repos RepoLookup, queueSvc *queue.Service) http.Handler {
+// Handler returns an http.Handler that verifies Gitea webhook events and
+// records them in the inbox for asynchronous processing.
+func Handler(secret string, inbox *Inbox) http.Handler {
+	return statusHandler(forge.Host{Kind: forge.KindGitea}, secret, inbox)
+}
+
+// GiteaInstanceHandler is Handler for a named Gitea instance. Its deliveries
+// are recorded under gitea@<instance>, so equally named repos of different
+// servers do not mix.
+func GiteaInstanceHandler(instance, secret string, inbox *Inbox) http.Handler {
+	return statusHandler(forge.Host{Kind: forge.KindGitea, Instance: instance}, secret, inbox)
+}
+
+// ForgejoHandler is Handler for Forgejo instances. Their deliveries are
+// recorded under the forgejo kind, so a Gitea and a Forgejo repo of the same
+// name do not mix.
+func ForgejoHandler(secret string, inbox *Inbox) http.Handler {
+	return statusHandler(forge.Host{Kind: forge.KindForgejo}, secret, inbox)
+}
+
+// signatureHeaders names the header carrying each Gitea-like forge's HMAC.
+// Forgejo also sends X-Gitea-Signature, which is the fallback.
+var signatureHeaders = map[forge.Kind]string{
+	forge.KindGitea:   "X-Gitea-Signature",
+	forge.KindForgejo: "X-Forgejo-Signature",
+}
+
+func statusHandler(host forge.Host, secret string, inbox *Inbox) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -54,7 +88,7 @@ func Handler(secret string, repos RepoLookup, queueSvc *queue.Service) http.Hand
 			return
 		}
 
-		sig := r.Header.Get("X-Gitea-Signature")
+		sig := cmp.Or(r.Header.Get(signatureHeaders[host.Kind]), r.Header.Get("X-Gitea-Signature"))
 		if !ValidateSignature(body, sig, secret) {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
@@ -62,60 +96,29 @@ func Handler(secret string, repos RepoLookup, queueSvc *queue.Service) http.Hand
 
 		var event statusEvent
 		if err := json.Unmarshal(body, &event); err != nil {
-			slog.Warn("malformed webhook payload", "error", err)
+			slog.Warn("malformed webhook payload", "forge", host, "error", err)
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		if err := event.validate(); err != nil {
-			slog.Warn("invalid webhook payload", "error", err)
+			slog.Warn("invalid webhook payload", "forge", host, "error", err)
 			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Ignore our own status updates to prevent feedback loops.
-		if forge.IsOwnContext(event.Context) {
-			w.WriteHeader(http.StatusOK)
+		repoKey := host.String() + ":" + event.Repository.FullName
+		if err := inbox.store(r.Context(), host, r, "status", repoKey, body); err != nil {
+			slog.Error("failed to store webhook delivery", "repo", repoKey, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-
-		// Gitea payloads identify repos as owner/name; the registry keys by
-		// forge:owner/name.
-		repoKey := string(forge.KindGitea) + ":" + event.Repository.FullName
-		rm, ok := repos.LookupMonitor(repoKey)
-		if !ok {
-			slog.Debug("webhook for unmanaged repo", "repo", repoKey)
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		check := forge.Check{
-			State:       forge.ParseCheckState(event.State),
-			Description: event.Description,
-			TargetURL:   event.TargetURL,
-		}
-		routeCheck(r.Context(), rm, queueSvc, event.SHA, event.Context, check)
-		maybeTriggerPoll(rm, check.State)
 		w.WriteHeader(http.StatusOK)
 	})
 }
 
-// routeCheck is the shared status/check-run path for both forges: match the
-// SHA to a testing queue entry, mirror onto the PR head, and feed the monitor.
-// Returning 200 even on internal errors avoids forge retries causing duplicate
-// processing.
-func routeCheck(ctx context.Context, rm *RepoMonitor, svc *queue.Service, sha, checkCtx string, c forge.Check) {
-	entry := findEntryForCommit(ctx, svc, rm.Deps.RepoID, sha)
-	if entry == nil {
-		return
-	}
-
-	if err := monitor.ApplyCheck(ctx, rm.Deps, entry, checkCtx, c); err != nil {
-		slog.Error("failed to process check status", "pr", entry.PrNumber, "err", err)
-	}
-}
-
-// statusEvent is the subset of Gitea's commit_status webhook payload we need.
+// statusEvent is the subset of Gitea's (and Forgejo's) commit_status webhook
+// payload we need.
 type statusEvent struct {
 	SHA         string `json:"sha"`
 	Context     string `json:"context"`
@@ -145,18 +148,17 @@ func (e *statusEvent) validate() error {
 
 // findEntryForCommit looks up a queue entry by merge branch SHA. This is how
 // we correlate a commit status event to a specific PR in the queue.
-func findEntryForCommit(ctx context.Context, svc *queue.Service, repoID int64, sha string) *pg.QueueEntry {
+func findEntryForCommit(ctx context.Context, svc *queue.Service, repoID int64, sha string) (*pg.QueueEntry, error) {
 	entries, err := svc.ListActiveEntries(ctx, repoID)
 	if err != nil {
-		slog.Error("failed to list active entries", "error", err)
-		return nil
+		return nil, fmt.Errorf("list active entries: %w", err)
 	}
 
 	for i := range entries {
 		if entries[i].MergeBranchSha.Valid && entries[i].MergeBranchSha.String == sha {
-			return &entries[i]
+			return &entries[i], nil
 		}
 	}
 
-	return nil
+	return nil, nil
 }
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/logutil"
+	"github.com/Mic92/gitea-mq/internal/queue"
+)
+
+const (
+	defaultInboxWorkers = 4
+	defaultMaxAttempts  = 8
+	// processLease bounds how long a claimed delivery may take before
+	// another worker retries it.
+	processLease = 5 * time.Minute
+	// inboxPollInterval picks up retries that are due and deliveries whose
+	// wake-up notification was lost.
+	inboxPollInterval = 5 * time.Second
+	maxRetryDelay     = 5 * time.Minute
+	// DefaultRetention is how long finished deliveries are kept.
+	DefaultRetention = 7 * 24 * time.Hour
+)
+
+// Inbox persists verified webhook deliveries and processes them
+// asynchronously, so the forge gets its acknowledgement at once and
+// transient failures are retried instead of dropped. Deliveries of the same
+// repo are applied in arrival order. Finished deliveries stay recorded for
+// Retention, for de-duplication and replay.
+type Inbox struct {
+	Queue *queue.Service
+	Repos RepoLookup
+	// TriggerDiscovery runs a discovery cycle on GitHub installation events.
+	TriggerDiscovery func()
+	// Workers is how many deliveries are processed concurrently. Defaults
+	// to 4.
+	Workers int
+	// MaxAttempts is how often a delivery is tried before it is
+	// dead-lettered. Defaults to 8.
+	MaxAttempts int
+	// Retention is how long finished deliveries are kept. Defaults to
+	// DefaultRetention.
+	Retention time.Duration
+}
+
+// store records a delivery with its redacted headers. Forges that send no
+// delivery ID get one derived from the payload, which still folds identical
+// redeliveries.
+func (in *Inbox) store(ctx context.Context, host forge.Host, r *http.Request, event, repo string, payload []byte) error {
+	deliveryID := r.Header.Get(deliveryHeaders[host.Kind])
+	if deliveryID == "" {
+		sum := sha256.Sum256(append([]byte(event+"\n"), payload...))
+		deliveryID = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	headers, err := json.Marshal(redactHeaders(r.Header))
+	if err != nil {
+		return err
+	}
+	stored, err := in.Queue.StoreDelivery(ctx, host.String(), deliveryID, event, repo, headers, redactPayload(payload))
+	if err != nil {
+		return err
+	}
+	if !stored {
+		slog.Debug("duplicate webhook delivery", "forge", host, "delivery", deliveryID)
+	}
+	return nil
+}
+
+// Run processes stored deliveries until ctx ends. With several replicas
+// only the leader runs it; the others just store.
+func (in *Inbox) Run(ctx context.Context) {
+	// Buffer one so a burst of notifications collapses into one wake-up.
+	wake := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+
+	go func() {
+		for {
+			err := in.Queue.WatchDeliveries(ctx, signal)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("webhook inbox feed lost, reconnecting", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		retention := in.Retention
+		if retention <= 0 {
+			retention = DefaultRetention
+		}
+		for {
+			logutil.WarnIfErr(in.Queue.PruneDeliveries(ctx, time.Now().Add(-retention)), "failed to prune webhook inbox")
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	workers := in.Workers
+	if workers <= 0 {
+		workers = defaultInboxWorkers
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() { in.work(ctx, wake, signal) })
+	}
+	wg.Wait()
+}
+
+func (in *Inbox) work(ctx context.Context, wake <-chan struct{}, signal func()) {
+	ticker := time.NewTicker(inboxPollInterval)
+	defer ticker.Stop()
+	for {
+		found, err := in.processNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("failed to claim webhook delivery", "error", err)
+		}
+		if found {
+			// There may be more: let an idle worker help.
+			signal()
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain processes deliveries until none is due. Retries scheduled for later
+// are left alone.
+func (in *Inbox) Drain(ctx context.Context) error {
+	for {
+		found, err := in.processNext(ctx)
+		if err != nil || !found {
+			return err
+		}
+	}
+}
+
+// processNext claims and processes one delivery. It reports whether there
+// was one.
+func (in *Inbox) processNext(ctx context.Context) (bool, error) {
+	d, err := in.Queue.ClaimDelivery(ctx, processLease)
+	if err != nil || d == nil {
+		return false, err
+	}
+
+	r, err := in.route(ctx, d.Forge, d.Event, d.Payload)
+	if err == nil {
+		err = in.apply(ctx, r)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return true, nil // shutdown; the lease expires and a leader retries
+		}
+		maxAttempts := in.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = defaultMaxAttempts
+		}
+		if int(d.Attempts) >= maxAttempts {
+			slog.Error("webhook delivery dead-lettered", "forge", d.Forge, "event", d.Event, "repo", d.Repo, "delivery", d.DeliveryID, "attempts", d.Attempts, "error", err)
+		} else {
+			slog.Warn("webhook delivery failed, will retry", "forge", d.Forge, "event", d.Event, "repo", d.Repo, "delivery", d.DeliveryID, "attempts", d.Attempts, "error", err)
+		}
+		logutil.WarnIfErr(
+			in.Queue.FailDelivery(ctx, d.ID, err, time.Now().Add(retryDelay(int(d.Attempts))), maxAttempts),
+			"failed to record webhook delivery failure", "delivery", d.DeliveryID)
+		return true, nil
+	}
+
+	logutil.WarnIfErr(in.Queue.CompleteDelivery(ctx, d.ID), "failed to complete webhook delivery", "delivery", d.DeliveryID)
+	return true, nil
+}
+
+// retryDelay backs off exponentially from 5s after the given attempt.
+func retryDelay(attempt int) time.Duration {
+	d := 5 * time.Second
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// deliveryHeaders names the header carrying each forge's delivery ID.
+var deliveryHeaders = map[forge.Kind]string{
+	forge.KindGitea:   "X-Gitea-Delivery",
+	forge.KindForgejo: "X-Forgejo-Delivery",
+	forge.KindGithub:  "X-GitHub-Delivery",
+	forge.KindGitlab:  "X-Gitlab-Event-UUID",
+}
+
+// redactedHeaders are dropped before a delivery is recorded: signatures would
+// let anyone reading the log forge deliveries for the same body, and GitLab's
+// token is the webhook secret itself.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"X-Forgejo-Signature": true,
+	"X-Gitea-Signature":   true,
+	"X-Gitlab-Token":      true,
+	"X-Gogs-Signature":    true,
+	"X-Hub-Signature":     true,
+	"X-Hub-Signature-256": true,
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if len(v) == 0 || redactedHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		out[http.CanonicalHeaderKey(k)] = v[0]
+	}
+	return out
+}
+
+// redactPayload blanks the webhook secret older Gitea and Forgejo versions
+// echo in the body. Other payloads are kept byte for byte.
+func redactPayload(payload []byte) []byte {
+	var m map[string]json.RawMessage
+	if json.Unmarshal(payload, &m) != nil {
+		return payload
+	}
+	if s, ok := m["secret"]; !ok || string(s) == `""` {
+		return payload
+	}
+	m["secret"] = json.RawMessage(`""`)
+	out, err := json.Marshal(m)
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+// ReplayRequest rebuilds the request of a recorded delivery, signed with the
+// forge's webhook secret. It gets a fresh delivery ID, so the inbox stores it
+// next to the original instead of folding it in as a duplicate.
+func ReplayRequest(ctx context.Context, d *pg.WebhookDelivery, secret string) (*http.Request, error) {
+	host, ok := forge.ParseHost(d.Forge)
+	if !ok {
+		return nil, fmt.Errorf("unknown forge %q", d.Forge)
+	}
+	kind := host.Kind
+
+	path := "/webhook/" + string(kind)
+	if host.Instance != "" {
+		path += "/" + host.Instance
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(d.Payload))
+	if err != nil {
+		return nil, err
+	}
+	var headers map[string]string
+	if len(d.Headers) > 0 {
+		if err := json.Unmarshal(d.Headers, &headers); err != nil {
+			return nil, fmt.Errorf("decode headers of delivery %d: %w", d.ID, err)
+		}
+	}
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set(deliveryHeaders[kind], fmt.Sprintf("replay-%d-%d", d.ID, time.Now().UnixNano()))
+
+	sig := ComputeSignature(d.Payload, secret)
+	switch kind {
+	case forge.KindGitea, forge.KindForgejo:
+		r.Header.Set(signatureHeaders[kind], sig)
+	case forge.KindGithub:
+		r.Header.Set("X-GitHub-Event", d.Event)
+		r.Header.Set("X-Hub-Signature-256", "sha256="+sig)
+	case forge.KindGitlab:
+		r.Header.Set("X-Gitlab-Event", d.Event)
+		r.Header.Set("X-Gitlab-Token", secret)
+	}
+	return r, nil
+}
+
+// Replay feeds a recorded delivery through its forge's webhook handler, as
+// if the forge had redelivered it, and returns the handler's status code.
+func Replay(ctx context.Context, inbox *Inbox, d *pg.WebhookDelivery, secret string) (int, error) {
+	r, err := ReplayRequest(ctx, d, secret)
+	if err != nil {
+		return 0, err
+	}
+	// ReplayRequest has validated the host.
+	host, _ := forge.ParseHost(d.Forge)
+	var h http.Handler
+	switch host.Kind {
+	case forge.KindGithub:
+		h = GithubHandler([]byte(secret), inbox)
+	case forge.KindGitlab:
+		h = GitlabHandler(secret, inbox)
+	default:
+		h = statusHandler(host, secret, inbox)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec.Code, nil
+}
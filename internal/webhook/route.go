@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	gh "github.com/google/go-github/v84/github"
+
+	"github.com/Mic92/gitea-mq/internal/forge"
+	"github.com/Mic92/gitea-mq/internal/github"
+	"github.com/Mic92/gitea-mq/internal/monitor"
+	"github.com/Mic92/gitea-mq/internal/store/pg"
+)
+
+// Actions a delivery can lead to.
+const (
+	ActionIgnore   = "ignore"
+	ActionCheck    = "check"    // CI result for a merge branch
+	ActionPoll     = "poll"     // reconcile the repo's poller
+	ActionDiscover = "discover" // re-run repo discovery
+	ActionButton   = "button"   // a button on the gitea-mq status was pressed
+)
+
+// Decision describes how a delivery is routed. A dry replay reports it
+// without acting.
+type Decision struct {
+	Action  string `json:"action"`
+	Reason  string `json:"reason,omitempty"` // why it is ignored or has no effect
+	Repo    string `json:"repo,omitempty"`
+	SHA     string `json:"sha,omitempty"`
+	Context string `json:"context,omitempty"`
+	State   string `json:"state,omitempty"`
+	// PR is the queue entry testing SHA, as found by findEntryForCommit.
+	PR   int64 `json:"pr,omitempty"`
+	Poll bool  `json:"poll,omitempty"` // the repo's poller is triggered
+	// Button is the identifier of the pressed button and Sender who
+	// pressed it.
+	Button string `json:"button,omitempty"`
+	Sender string `json:"sender,omitempty"`
+}
+
+func (d Decision) String() string {
+	s := d.Action
+	if d.Repo != "" {
+		s += " " + d.Repo
+	}
+	if d.Context != "" {
+		s += fmt.Sprintf(" %s=%s@%s", d.Context, d.State, d.SHA)
+	}
+	if d.Button != "" {
+		s += fmt.Sprintf(" %s by @%s", d.Button, d.Sender)
+	}
+	if d.PR != 0 {
+		s += fmt.Sprintf(" → PR #%d", d.PR)
+	}
+	if d.Poll {
+		s += ", poll"
+	}
+	if d.Reason != "" {
+		s += " (" + d.Reason + ")"
+	}
+	return s
+}
+
+// route is a Decision plus what acting on it needs.
+type route struct {
+	Decision
+	rm    *RepoMonitor
+	entry *pg.QueueEntry
+	check forge.Check
+}
+
+func (r *route) ignore(reason string) *route {
+	r.Action, r.Reason, r.Poll = ActionIgnore, reason, false
+	return r
+}
+
+// Explain routes a stored delivery without acting on it.
+func (in *Inbox) Explain(ctx context.Context, d *pg.WebhookDelivery) (Decision, error) {
+	r, err := in.route(ctx, d.Forge, d.Event, d.Payload)
+	if err != nil {
+		return Decision{}, err
+	}
+	return r.Decision, nil
+}
+
+// route decides what a delivery does. It only reads state. hostName is the
+// forge.Host the delivery was recorded under.
+func (in *Inbox) route(ctx context.Context, hostName, event string, payload []byte) (*route, error) {
+	host, ok := forge.ParseHost(hostName)
+	if !ok {
+		return nil, fmt.Errorf("unknown forge %q", hostName)
+	}
+	switch host.Kind {
+	case forge.KindGitea, forge.KindForgejo:
+		var e statusEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, fmt.Errorf("decode payload: %w", err)
+		}
+		// Gitea payloads identify repos as owner/name; the registry keys by
+		// host:owner/name.
+		return in.routeCheck(ctx, host.String()+":"+e.Repository.FullName, e.SHA, e.Context, forge.Check{
+			State:       forge.ParseCheckState(e.State),
+			Description: e.Description,
+			TargetURL:   e.TargetURL,
+		})
+	case forge.KindGithub:
+		return in.routeGithub(ctx, event, payload)
+	case forge.KindGitlab:
+		return in.routeGitlab(ctx, event, payload)
+	default:
+		return nil, fmt.Errorf("unknown forge %q", hostName)
+	}
+}
+
+func (in *Inbox) routeGithub(ctx context.Context, eventType string, payload []byte) (*route, error) {
+	event, err := gh.ParseWebHook(eventType, payload)
+	if err != nil {
+		return (&route{}).ignore(fmt.Sprintf("unparsable %s event", eventType)), nil
+	}
+
+	switch e := event.(type) {
+	case *gh.PullRequestEvent:
+		r := &route{Decision: Decision{Action: ActionPoll, Repo: githubRepoKey(e.GetRepo()), Poll: true}}
+		if !prTriggerActions[e.GetAction()] {
+			return r.ignore("pull_request action " + e.GetAction() + " does not change the queue"), nil
+		}
+		rm, ok := in.lookup(r.Repo)
+		if !ok {
+			return r.ignore("repo not managed"), nil
+		}
+		r.rm = rm
+		return r, nil
+
+	case *gh.CheckRunEvent:
+		if e.GetAction() == "requested_action" {
+			return in.routeButton(ctx, e)
+		}
+		cr := e.GetCheckRun()
+		key := githubRepoKey(e.GetRepo())
+		if e.GetAction() != "completed" || cr.GetStatus() != "completed" {
+			r := &route{Decision: Decision{Repo: key, SHA: cr.GetHeadSHA(), Context: cr.GetName()}}
+			return r.ignore("check run not completed"), nil
+		}
+		return in.routeCheck(ctx, key, cr.GetHeadSHA(), cr.GetName(), forge.Check{
+			State:       github.CheckRunToState(cr.GetStatus(), cr.GetConclusion()),
+			Description: cr.GetOutput().GetSummary(),
+			TargetURL:   cr.GetDetailsURL(),
+		})
+
+	case *gh.StatusEvent:
+		return in.routeCheck(ctx, githubRepoKey(e.GetRepo()), e.GetSHA(), e.GetContext(), forge.Check{
+			State:       forge.ParseCheckState(e.GetState()),
+			Description: e.GetDescription(),
+			TargetURL:   e.GetTargetURL(),
+		})
+
+	case *gh.InstallationEvent, *gh.InstallationRepositoriesEvent:
+		return &route{Decision: Decision{Action: ActionDiscover}}, nil
+	}
+	return (&route{}).ignore(eventType + " events are not handled"), nil
+}
+
+// routeButton handles a press on a button of the gitea-mq check run. A
+// press on a closed PR or on a button left on an earlier head is ignored.
+// Whether the sender may press it is asked of the forge in apply.
+func (in *Inbox) routeButton(ctx context.Context, e *gh.CheckRunEvent) (*route, error) {
+	cr := e.GetCheckRun()
+	r := &route{Decision: Decision{
+		Action: ActionButton,
+		Repo:   githubRepoKey(e.GetRepo()),
+		SHA:    cr.GetHeadSHA(),
+		Sender: e.GetSender().GetLogin(),
+	}}
+	if a := e.GetRequestedAction(); a != nil {
+		r.Button = a.Identifier
+	}
+	if cr.GetName() != forge.MQContext {
+		return r.ignore("not gitea-mq's check run"), nil
+	}
+	if len(cr.PullRequests) == 0 {
+		return r.ignore("check run belongs to no PR"), nil
+	}
+	r.PR = int64(cr.PullRequests[0].GetNumber())
+	rm, ok := in.lookup(r.Repo)
+	if !ok {
+		return r.ignore("repo not managed"), nil
+	}
+	if rm.Actions == nil {
+		return r.ignore("repo's status has no buttons"), nil
+	}
+	r.rm = rm
+
+	switch r.Button {
+	case github.ActionDequeue:
+		entry, err := in.Queue.GetEntry(ctx, rm.Deps.RepoID, r.PR)
+		if err != nil {
+			return nil, err
+		}
+		// A button left on an earlier head must not remove the PR's
+		// current attempt.
+		if entry == nil || entry.PrHeadSha != r.SHA {
+			return r.ignore("PR is not queued at this commit"), nil
+		}
+		r.entry = entry
+	case github.ActionRetry:
+		pr, err := rm.Deps.Forge.GetPR(ctx, rm.Deps.Owner, rm.Deps.Repo, r.PR)
+		if err != nil {
+			return nil, fmt.Errorf("get PR #%d: %w", r.PR, err)
+		}
+		// Auto-merge cannot be enabled on a closed PR, and a button from an
+		// earlier head must not re-queue code nobody retried.
+		if pr.State != "open" || pr.Merged {
+			return r.ignore("PR is closed"), nil
+		}
+		if pr.HeadSHA != r.SHA {
+			return r.ignore("PR head moved since the button was shown"), nil
+		}
+	default:
+		return r.ignore("unknown button " + r.Button), nil
+	}
+	return r, nil
+}
+
+// routeCheck is the shared status/check-run path for all forges: match the
+// SHA to a testing queue entry, whose PR head the result is then mirrored
+// onto and whose monitor is fed.
+func (in *Inbox) routeCheck(ctx context.Context, repoKey, sha, checkCtx string, c forge.Check) (*route, error) {
+	r := &route{
+		Decision: Decision{
+			Action:  ActionCheck,
+			Repo:    repoKey,
+			SHA:     sha,
+			Context: checkCtx,
+			State:   string(c.State),
+			// A green check is the sole transition that can newly enqueue
+			// an auto-merge PR.
+			Poll: c.State == pg.CheckStateSuccess,
+		},
+		check: c,
+	}
+	// Ignore our own status updates to prevent feedback loops.
+	if forge.IsOwnContext(checkCtx) {
+		return r.ignore("gitea-mq's own status"), nil
+	}
+	rm, ok := in.lookup(repoKey)
+	if !ok {
+		return r.ignore("repo not managed"), nil
+	}
+	r.rm = rm
+
+	entry, err := findEntryForCommit(ctx, in.Queue, rm.Deps.RepoID, sha)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		r.Reason = "no queue entry is testing this commit"
+		return r, nil
+	}
+	r.entry, r.PR = entry, entry.PrNumber
+	return r, nil
+}
+
+// apply carries out a routing decision.
+func (in *Inbox) apply(ctx context.Context, r *route) error {
+	switch r.Action {
+	case ActionCheck:
+		if r.entry != nil {
+			if err := monitor.ApplyCheck(ctx, r.rm.Deps, r.entry, r.Context, r.check); err != nil {
+				return fmt.Errorf("process check status for PR #%d: %w", r.entry.PrNumber, err)
+			}
+		}
+		maybeTriggerPoll(r.rm, r.check.State)
+	case ActionPoll:
+		if r.rm.TriggerPoll != nil {
+			r.rm.TriggerPoll()
+		}
+	case ActionDiscover:
+		if in.TriggerDiscovery != nil {
+			in.TriggerDiscovery()
+		}
+	case ActionButton:
+		return applyButton(ctx, r)
+	}
+	return nil
+}
+
+// applyButton carries out a button press by a user with write access.
+// Presses by anyone else are dropped.
+func applyButton(ctx context.Context, r *route) error {
+	owner, name := r.rm.Deps.Owner, r.rm.Deps.Repo
+	ok, err := r.rm.Actions.CanWrite(ctx, owner, name, r.Sender)
+	if err != nil {
+		return fmt.Errorf("check permission of %s: %w", r.Sender, err)
+	}
+	if !ok {
+		slog.Info("ignoring status button pressed without write access", "repo", r.Repo, "pr", r.PR, "user", r.Sender, "button", r.Button)
+		return nil
+	}
+
+	switch r.Button {
+	case github.ActionDequeue:
+		if r.rm.Dequeue == nil {
+			return nil
+		}
+		if err := r.rm.Dequeue(ctx, r.PR, r.Sender); err != nil {
+			return fmt.Errorf("remove PR #%d: %w", r.PR, err)
+		}
+	case github.ActionRetry:
+		if err := r.rm.Actions.EnableAutoMerge(ctx, owner, name, r.PR); err != nil {
+			return fmt.Errorf("re-enable auto-merge of PR #%d: %w", r.PR, err)
+		}
+		if r.rm.TriggerPoll != nil {
+			r.rm.TriggerPoll()
+		}
+	}
+	return nil
+}
+
+func (in *Inbox) lookup(key string) (*RepoMonitor, bool) {
+	if key == "" || in.Repos == nil {
+		return nil, false
+	}
+	return in.Repos.LookupMonitor(key)
+}
@@ -3,6 +3,7 @@ package webhook_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -22,6 +23,7 @@ func sign(body []byte) string { return webhook.ComputeSignature(body, testSecret
 
 type testEnv struct {
 	handler http.Handler
+	inbox   *webhook.Inbox
 	mock    *gitea.MockClient
 	svc     *queue.Service
 	ctx     context.Context
@@ -54,8 +56,10 @@ func setup(t *testing.T) *testEnv {
 		"gitea:org/app": {Deps: deps},
 	}
 
+	inbox := &webhook.Inbox{Queue: svc, Repos: repos}
 	return &testEnv{
-		handler: webhook.Handler(testSecret, repos, svc),
+		handler: webhook.Handler(testSecret, inbox),
+		inbox:   inbox,
 		mock:    mock,
 		svc:     svc,
 		ctx:     ctx,
@@ -88,6 +92,7 @@ func doRequest(handler http.Handler, body []byte, sig string) *httptest.Response
 
 // HMAC is the security boundary for both forge endpoints.
 func TestHandler_SignatureValidation(t *testing.T) {
+	svc, _, _ := testutil.TestQueueService(t)
 	giteaBody := string(makePayload("abc", "ci/build", "success", "x/y"))
 	for _, tc := range []struct {
 		name    string
@@ -97,11 +102,11 @@ func TestHandler_SignatureValidation(t *testing.T) {
 		good    string
 	}{
 		{
-			"gitea", webhook.Handler(testSecret, webhook.MapRepoLookup{}, nil),
+			"gitea", webhook.Handler(testSecret, &webhook.Inbox{Queue: svc}),
 			"X-Gitea-Signature", giteaBody, sign([]byte(giteaBody)),
 		},
 		{
-			"github", webhook.GithubHandler([]byte(testSecret), webhook.MapRepoLookup{}, nil, nil),
+			"github", webhook.GithubHandler([]byte(testSecret), &webhook.Inbox{Queue: svc}),
 			"X-Hub-Signature-256", `{}`, "sha256=" + sign([]byte(`{}`)),
 		},
 	} {
@@ -151,6 +156,9 @@ func TestHandler_IgnoresOwnContexts(t *testing.T) {
 			t.Fatalf("%s: expected 200, got %d", checkCtx, rec.Code)
 		}
 	}
+	if err := env.inbox.Drain(env.ctx); err != nil {
+		t.Fatal(err)
+	}
 	if n := len(env.mock.CallsTo("CreateCommitStatus")); n != 0 {
 		t.Fatalf("own-context status was re-processed: %d CreateCommitStatus calls", n)
 	}
@@ -183,6 +191,9 @@ func TestHandler_MirrorsStatusToPRHead(t *testing.T) {
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rec.Code)
 	}
+	if err := env.inbox.Drain(env.ctx); err != nil {
+		t.Fatal(err)
+	}
 
 	// Find the mirror call: CreateCommitStatus on the PR head with prefixed context.
 	var mirror *gitea.MockCall
@@ -210,3 +221,161 @@ func TestHandler_MirrorsStatusToPRHead(t *testing.T) {
 		t.Errorf("mirror target_url = %q, want %q", status.TargetURL, "https://ci.example.com/build/1")
 	}
 }
+
+// A transient forge error must not lose the check result: the delivery is
+// kept for a retry, and a redelivery of it is ignored.
+func TestHandler_RetriesFailedDelivery(t *testing.T) {
+	env := setup(t)
+	testutil.EnqueueTesting(t, env.svc, env.repoID, 7, "pr-head", "merge-sha")
+	env.mock.GetBranchProtectionFn = func(_ context.Context, _, _, _ string) (*gitea.BranchProtection, error) {
+		return nil, errors.New("gitea unavailable")
+	}
+
+	body := makePayload("merge-sha", "ci/build", "success", "org/app")
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(string(body)))
+		req.Header.Set("X-Gitea-Signature", sign(body))
+		req.Header.Set("X-Gitea-Delivery", "d-1")
+		rec := httptest.NewRecorder()
+		env.handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	if err := env.inbox.Drain(env.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pending, dead, _ := env.svc.InboxStats(env.ctx); pending != 1 || dead != 0 {
+		t.Fatalf("pending=%d dead=%d, want the delivery queued for retry", pending, dead)
+	}
+	if n := len(env.mock.CallsTo("GetBranchProtection")); n != 1 {
+		t.Fatalf("redelivery was processed too: %d GetBranchProtection calls", n)
+	}
+}
+
+// A recorded delivery keeps its headers minus the signature, can be
+// explained without side effects and, replayed, is processed like a fresh
+// redelivery.
+func TestReplay(t *testing.T) {
+	env := setup(t)
+	testutil.EnqueueTesting(t, env.svc, env.repoID, 7, "pr-head", "merge-sha")
+
+	body := makePayload("merge-sha", "ci/build", "pending", "org/app")
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(string(body)))
+	req.Header.Set("X-Gitea-Signature", sign(body))
+	req.Header.Set("X-Gitea-Delivery", "d-1")
+	req.Header.Set("X-Gitea-Event", "status")
+	env.handler.ServeHTTP(httptest.NewRecorder(), req)
+	if err := env.inbox.Drain(env.ctx); err != nil {
+		t.Fatal(err)
+	}
+	mirrored := len(env.mock.CallsTo("CreateCommitStatus"))
+
+	ds, err := env.svc.ListDeliveries(env.ctx, 10)
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("deliveries=%v err=%v", ds, err)
+	}
+	d := &ds[0]
+	var headers map[string]string
+	if err := json.Unmarshal(d.Headers, &headers); err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Gitea-Event"] != "status" || headers["X-Gitea-Signature"] != "" {
+		t.Fatalf("headers not recorded redacted: %v", headers)
+	}
+
+	dec, err := env.inbox.Explain(env.ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Action != webhook.ActionCheck || dec.PR != 7 {
+		t.Fatalf("decision = %+v, want check routed to PR #7", dec)
+	}
+	if n := len(env.mock.CallsTo("CreateCommitStatus")); n != mirrored {
+		t.Fatalf("dry run acted: %d CreateCommitStatus calls, want %d", n, mirrored)
+	}
+
+	code, err := webhook.Replay(env.ctx, env.inbox, d, testSecret)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("replay: code=%d err=%v", code, err)
+	}
+	if err := env.inbox.Drain(env.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(env.mock.CallsTo("CreateCommitStatus")); n <= mirrored {
+		t.Fatal("replayed delivery was not processed")
+	}
+}
+
+// Forgejo deliveries are signed with X-Forgejo-Signature and only reach the
+// forgejo: repo, never a Gitea repo of the same name.
+func TestForgejoHandler_RoutesByKind(t *testing.T) {
+	env := setup(t)
+	testutil.EnqueueTesting(t, env.svc, env.repoID, 7, "pr-head", "merge-sha")
+	h := webhook.ForgejoHandler(testSecret, env.inbox)
+
+	post := func(delivery string) {
+		t.Helper()
+		body := makePayload("merge-sha", "ci/build", "pending", "org/app")
+		req := httptest.NewRequest(http.MethodPost, "/webhook/forgejo", strings.NewReader(string(body)))
+		req.Header.Set("X-Forgejo-Signature", sign(body))
+		req.Header.Set("X-Forgejo-Delivery", delivery)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if err := env.inbox.Drain(env.ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post("d-1")
+	if n := len(env.mock.CallsTo("CreateCommitStatus")); n != 0 {
+		t.Fatalf("forgejo status reached the gitea repo: %d CreateCommitStatus calls", n)
+	}
+
+	repos := env.inbox.Repos.(webhook.MapRepoLookup)
+	repos["forgejo:org/app"] = repos["gitea:org/app"]
+	post("d-2")
+	if n := len(env.mock.CallsTo("CreateCommitStatus")); n != 1 {
+		t.Fatalf("forgejo status not mirrored: %d CreateCommitStatus calls", n)
+	}
+}
+
+// A named Gitea instance records its deliveries under gitea@<instance>, so
+// they reach only the instance's repo of that name.
+func TestGiteaInstanceHandler_RoutesByInstance(t *testing.T) {
+	env := setup(t)
+	testutil.EnqueueTesting(t, env.svc, env.repoID, 7, "pr-head", "merge-sha")
+	h := webhook.GiteaInstanceHandler("internal", testSecret, env.inbox)
+
+	post := func(delivery string) {
+		t.Helper()
+		body := makePayload("merge-sha", "ci/build", "pending", "org/app")
+		req := httptest.NewRequest(http.MethodPost, "/webhook/gitea/internal", strings.NewReader(string(body)))
+		req.Header.Set("X-Gitea-Signature", sign(body))
+		req.Header.Set("X-Gitea-Delivery", delivery)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if err := env.inbox.Drain(env.ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post("d-1")
+	if n := len(env.mock.CallsTo("CreateCommitStatus")); n != 0 {
+		t.Fatalf("internal status reached the default instance's repo: %d CreateCommitStatus calls", n)
+	}
+
+	repos := env.inbox.Repos.(webhook.MapRepoLookup)
+	repos["gitea@internal:org/app"] = repos["gitea:org/app"]
+	post("d-2")
+	if n := len(env.mock.CallsTo("CreateCommitStatus")); n != 1 {
+		t.Fatalf("internal status not mirrored: %d CreateCommitStatus calls", n)
+	}
+}
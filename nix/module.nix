@@ -7,7 +7,14 @@
 let
   cfg = config.services.gitea-mq;
   giteaEnabled = cfg.giteaUrl != null;
-  githubEnabled = cfg.github.appId != null;
+  forgejoEnabled = cfg.forgejo.url != null;
+  gitlabEnabled = cfg.gitlab.url != null;
+  giteaInstances = lib.attrNames cfg.giteaInstances;
+  # Environment variable infix of a named Gitea instance.
+  instanceVar = name: lib.toUpper (lib.replaceStrings [ "-" ] [ "_" ] name);
+  githubApp = cfg.github.appId != null;
+  githubToken = cfg.github.tokenFile != null;
+  githubEnabled = githubApp || githubToken;
 
   # Configure uploadpack.hideRefs in the forge's global git config so its
   # git upload-pack won't advertise gitea-mq/* merge branches to clients.
@@ -51,12 +58,131 @@ in
       description = "Path to a file containing the Gitea API token.";
     };
 
+    giteaInstances = lib.mkOption {
+      default = { };
+      description = ''
+        Further Gitea servers, keyed by instance name (lower-case letters,
+        digits and dashes). Their repos appear as gitea@<name>:owner/repo and
+        their webhook endpoint is /webhook/gitea/<name>.
+      '';
+      example = lib.literalExpression ''
+        {
+          internal = {
+            url = "https://git.corp.example.com";
+            tokenFile = "/run/secrets/gitea-internal-token";
+            webhookSecretFile = "/run/secrets/gitea-internal-webhook";
+            topic = "merge-queue";
+          };
+        }
+      '';
+      type = lib.types.attrsOf (
+        lib.types.submodule {
+          options = {
+            url = lib.mkOption {
+              type = lib.types.str;
+              description = "Gitea instance URL.";
+            };
+            tokenFile = lib.mkOption {
+              type = lib.types.path;
+              description = "Path to a file containing the instance's API token.";
+            };
+            webhookSecretFile = lib.mkOption {
+              type = lib.types.path;
+              description = "Path to a file containing the instance's webhook HMAC secret.";
+            };
+            repos = lib.mkOption {
+              type = lib.types.listOf lib.types.str;
+              default = [ ];
+              description = "Repos to manage in owner/name format. Optional when topic is set.";
+            };
+            topic = lib.mkOption {
+              type = lib.types.nullOr lib.types.str;
+              default = null;
+              description = "Topic to discover repos by.";
+            };
+          };
+        }
+      );
+    };
+
+    forgejo = {
+      url = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "Forgejo instance URL. Setting this enables the Forgejo backend.";
+        example = "https://forgejo.example.com";
+      };
+      tokenFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = "Path to a file containing the Forgejo API token.";
+      };
+      webhookSecretFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = "Path to a file containing the Forgejo webhook HMAC secret.";
+      };
+      repos = lib.mkOption {
+        type = lib.types.listOf lib.types.str;
+        default = [ ];
+        description = "Forgejo repos to manage in owner/name format. Optional when forgejo.topic is set.";
+      };
+      topic = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "Forgejo topic to discover repos by.";
+      };
+    };
+
+    gitlab = {
+      url = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "GitLab instance URL. Setting this enables the GitLab backend.";
+        example = "https://gitlab.example.com";
+      };
+      tokenFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = "Path to a file containing a GitLab access token with api scope.";
+      };
+      webhookSecretFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = "Path to a file containing the GitLab webhook secret token.";
+      };
+      repos = lib.mkOption {
+        type = lib.types.listOf lib.types.str;
+        default = [ ];
+        description = "GitLab projects to manage in group/project format. Optional when gitlab.topic is set.";
+      };
+      topic = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "GitLab topic to discover projects by.";
+      };
+    };
+
     github = {
       appId = lib.mkOption {
         type = lib.types.nullOr lib.types.int;
         default = null;
         description = "GitHub App ID. Setting this enables the GitHub backend.";
       };
+      tokenFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = ''
+          Path to a file containing a fine-grained personal access token or
+          bot account token. Enables the GitHub backend without an App;
+          mutually exclusive with `appId`.
+        '';
+      };
+      topic = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "GitHub topic to discover repos by (token mode only).";
+      };
       privateKeyFile = lib.mkOption {
         type = lib.types.nullOr lib.types.path;
         default = null;
@@ -77,6 +203,27 @@ in
         default = null;
         description = "Override the reconcile poll interval for GitHub. Defaults to `pollInterval`.";
       };
+      url = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "Web root of a GitHub Enterprise Server. Unset means github.com.";
+        example = "https://ghe.example.com";
+      };
+      apiUrl = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "REST API root. Defaults to `<url>/api/v3`.";
+      };
+      uploadUrl = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "Upload API root. Defaults to `<url>/api/uploads`.";
+      };
+      graphqlUrl = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "GraphQL endpoint. Defaults to `<url>/api/graphql`.";
+      };
     };
 
     repos = lib.mkOption {
@@ -150,6 +297,31 @@ in
       description = "Fallback required check contexts (if branch protection doesn't specify them).";
     };
 
+    branchPatterns = lib.mkOption {
+      type = lib.types.listOf lib.types.str;
+      default = [ ];
+      example = [ "release/*" ];
+      description = "Branch patterns auto-setup gates like the default branch.";
+    };
+
+    createBranchProtection = lib.mkOption {
+      type = lib.types.bool;
+      default = false;
+      description = ''
+        Let auto-setup create Gitea/Forgejo branch protection rules for the
+        default branch and `branchPatterns` where none exist.
+      '';
+    };
+
+    teardownOnRemove = lib.mkOption {
+      type = lib.types.bool;
+      default = false;
+      description = ''
+        Undo auto-setup (webhook, required check, GitHub ruleset) when a
+        repo is no longer managed.
+      '';
+    };
+
     batchMax = lib.mkOption {
       type = lib.types.int;
       default = 1;
@@ -167,6 +339,17 @@ in
       description = "Cap on CI builds spent bisecting one failing batch. 0 means unlimited.";
     };
 
+    shadowRepos = lib.mkOption {
+      type = lib.types.listOf lib.types.str;
+      default = [ ];
+      example = [ "gitea:org/critical-service" ];
+      description = ''
+        Repos (`<forge>:<owner>/<name>`) to run in shadow mode: the queue runs
+        as usual, but gitea-mq changes nothing on the forge and lists what it
+        would have done on the repo's dashboard page.
+      '';
+    };
+
     refreshInterval = lib.mkOption {
       type = lib.types.str;
       default = "10s";
@@ -179,6 +362,34 @@ in
       description = "How often to re-discover repos by topic. Only used when topic is set.";
     };
 
+    leaderCheckInterval = lib.mkOption {
+      type = lib.types.str;
+      default = "5s";
+      description = "How often the leader re-checks its database lock and standby replicas refresh their repo list.";
+    };
+
+    webhookRetention = lib.mkOption {
+      type = lib.types.str;
+      default = "168h";
+      description = "How long processed webhook deliveries are kept for de-duplication and replay.";
+    };
+
+    adminTokenFile = lib.mkOption {
+      type = lib.types.nullOr lib.types.path;
+      default = null;
+      description = "File containing the bearer token for the /admin/ API. The API is disabled when null.";
+    };
+
+    configFile = lib.mkOption {
+      type = lib.types.nullOr lib.types.path;
+      default = null;
+      description = ''
+        TOML config file read in addition to the environment. Settings the
+        other options put into the environment take precedence over it. The
+        service reloads the file when it changes or on `systemctl reload`.
+      '';
+    };
+
     logLevel = lib.mkOption {
       type = lib.types.enum [
         "debug"
@@ -190,6 +401,94 @@ in
       description = "Log level.";
     };
 
+    smtp = {
+      addr = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "SMTP relay host:port. Setting this enables e-mail notifications.";
+        example = "mail.example.com:587";
+      };
+      from = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "Sender address for notification mails.";
+        example = "gitea-mq <mq@example.com>";
+      };
+      username = lib.mkOption {
+        type = lib.types.nullOr lib.types.str;
+        default = null;
+        description = "SMTP AUTH user.";
+      };
+      passwordFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = "Path to a file containing the SMTP AUTH password.";
+      };
+      notifyAuthors = lib.mkOption {
+        type = lib.types.bool;
+        default = true;
+        description = "Mail PR authors when their PR lands or is removed from the queue.";
+      };
+      digest = lib.mkOption {
+        type = lib.types.attrsOf (lib.types.listOf lib.types.str);
+        default = { };
+        description = "Failure digest recipients per repo, keyed by `<forge>:<owner>/<name>`.";
+        example = {
+          "gitea:org/app" = [ "ops@example.com" ];
+        };
+      };
+      digestInterval = lib.mkOption {
+        type = lib.types.str;
+        default = "24h";
+        description = "How often the failure digest is sent.";
+      };
+      templateDir = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = "Directory with ejected.tmpl, landed.tmpl and/or digest.tmpl overriding the built-in mail templates.";
+      };
+    };
+
+    auth = {
+      gitea = {
+        clientId = lib.mkOption {
+          type = lib.types.nullOr lib.types.str;
+          default = null;
+          description = "Client ID of a Gitea OAuth2 application; enables dashboard login via Gitea.";
+        };
+        clientSecretFile = lib.mkOption {
+          type = lib.types.nullOr lib.types.path;
+          default = null;
+          description = "Path to a file containing the Gitea OAuth2 client secret.";
+        };
+      };
+      github = {
+        clientId = lib.mkOption {
+          type = lib.types.nullOr lib.types.str;
+          default = null;
+          description = "Client ID of the GitHub App; enables dashboard login via GitHub.";
+        };
+        clientSecretFile = lib.mkOption {
+          type = lib.types.nullOr lib.types.path;
+          default = null;
+          description = "Path to a file containing the GitHub App client secret.";
+        };
+      };
+      sessionKeyFile = lib.mkOption {
+        type = lib.types.nullOr lib.types.path;
+        default = null;
+        description = ''
+          Path to a file containing the secret that encrypts forge tokens in
+          login sessions. Without it, logins do not survive a restart.
+        '';
+      };
+      cacheTTL = lib.mkOption {
+        type = lib.types.str;
+        default = "1m";
+        description = "How long repo permission answers for dashboard visitors are cached.";
+      };
+    };
+
     hideRefFromClients = lib.mkOption {
       type = lib.types.bool;
       default = config.services.gitea.enable || config.services.forgejo.enable;
@@ -213,17 +512,47 @@ in
   config = lib.mkIf cfg.enable {
     assertions = [
       {
-        assertion = giteaEnabled || githubEnabled;
-        message = "services.gitea-mq: configure at least one backend (giteaUrl or github.appId).";
+        assertion =
+          giteaEnabled || giteaInstances != [ ] || forgejoEnabled || gitlabEnabled || githubEnabled;
+        message = "services.gitea-mq: configure at least one backend (giteaUrl, giteaInstances, forgejo.url, gitlab.url, github.appId or github.tokenFile).";
+      }
+      {
+        assertion =
+          !gitlabEnabled || (cfg.gitlab.tokenFile != null && cfg.gitlab.webhookSecretFile != null);
+        message = "services.gitea-mq: gitlab.tokenFile and gitlab.webhookSecretFile are required when gitlab.url is set.";
+      }
+      {
+        assertion =
+          !forgejoEnabled || (cfg.forgejo.tokenFile != null && cfg.forgejo.webhookSecretFile != null);
+        message = "services.gitea-mq: forgejo.tokenFile and forgejo.webhookSecretFile are required when forgejo.url is set.";
       }
       {
         assertion = !giteaEnabled || (cfg.giteaTokenFile != null && cfg.webhookSecretFile != null);
         message = "services.gitea-mq: giteaTokenFile and webhookSecretFile are required when giteaUrl is set.";
       }
       {
-        assertion =
-          !githubEnabled || (cfg.github.privateKeyFile != null && cfg.github.webhookSecretFile != null);
-        message = "services.gitea-mq: github.privateKeyFile and github.webhookSecretFile are required when github.appId is set.";
+        assertion = cfg.smtp.addr == null || cfg.smtp.from != null;
+        message = "services.gitea-mq: smtp.from is required when smtp.addr is set.";
+      }
+      {
+        assertion = cfg.auth.gitea.clientId == null || cfg.auth.gitea.clientSecretFile != null;
+        message = "services.gitea-mq: auth.gitea.clientSecretFile is required when auth.gitea.clientId is set.";
+      }
+      {
+        assertion = cfg.auth.github.clientId == null || cfg.auth.github.clientSecretFile != null;
+        message = "services.gitea-mq: auth.github.clientSecretFile is required when auth.github.clientId is set.";
+      }
+      {
+        assertion = !githubApp || cfg.github.privateKeyFile != null;
+        message = "services.gitea-mq: github.privateKeyFile is required when github.appId is set.";
+      }
+      {
+        assertion = !githubEnabled || cfg.github.webhookSecretFile != null;
+        message = "services.gitea-mq: github.webhookSecretFile is required when github.appId or github.tokenFile is set.";
+      }
+      {
+        assertion = !(githubApp && githubToken);
+        message = "services.gitea-mq: github.appId and github.tokenFile are mutually exclusive.";
       }
     ];
 
@@ -251,6 +580,7 @@ in
         DynamicUser = true;
         Restart = "on-failure";
         RestartSec = 5;
+        ExecReload = "${pkgs.coreutils}/bin/kill -HUP $MAINPID";
 
         # Persistent bare git clones used for merge operations.
         CacheDirectory = "gitea-mq";
@@ -261,9 +591,41 @@ in
             "gitea-token:${cfg.giteaTokenFile}"
             "webhook-secret:${cfg.webhookSecretFile}"
           ]
-          ++ lib.optionals githubEnabled [
+          ++ lib.concatMap (name: [
+            "gitea-${name}-token:${cfg.giteaInstances.${name}.tokenFile}"
+            "gitea-${name}-webhook-secret:${cfg.giteaInstances.${name}.webhookSecretFile}"
+          ]) giteaInstances
+          ++ lib.optionals forgejoEnabled [
+            "forgejo-token:${cfg.forgejo.tokenFile}"
+            "forgejo-webhook-secret:${cfg.forgejo.webhookSecretFile}"
+          ]
+          ++ lib.optionals gitlabEnabled [
+            "gitlab-token:${cfg.gitlab.tokenFile}"
+            "gitlab-webhook-secret:${cfg.gitlab.webhookSecretFile}"
+          ]
+          ++ lib.optionals githubApp [
             "github-private-key:${cfg.github.privateKeyFile}"
+          ]
+          ++ lib.optionals githubToken [
+            "github-token:${cfg.github.tokenFile}"
+          ]
+          ++ lib.optionals githubEnabled [
             "github-webhook-secret:${cfg.github.webhookSecretFile}"
+          ]
+          ++ lib.optionals (cfg.smtp.passwordFile != null) [
+            "smtp-password:${cfg.smtp.passwordFile}"
+          ]
+          ++ lib.optionals (cfg.adminTokenFile != null) [
+            "admin-token:${cfg.adminTokenFile}"
+          ]
+          ++ lib.optionals (cfg.auth.gitea.clientId != null) [
+            "gitea-oauth-secret:${cfg.auth.gitea.clientSecretFile}"
+          ]
+          ++ lib.optionals (cfg.auth.github.clientId != null) [
+            "github-oauth-secret:${cfg.auth.github.clientSecretFile}"
+          ]
+          ++ lib.optionals (cfg.auth.sessionKeyFile != null) [
+            "auth-session-key:${cfg.auth.sessionKeyFile}"
           ];
       };
 
@@ -275,13 +637,20 @@ in
         GITEA_MQ_POLL_INTERVAL = cfg.pollInterval;
         GITEA_MQ_CHECK_TIMEOUT = cfg.checkTimeout;
         GITEA_MQ_SKIP_QUEUE_IF_UP_TO_DATE = lib.boolToString cfg.skipQueueIfUpToDate;
+        GITEA_MQ_CREATE_BRANCH_PROTECTION = lib.boolToString cfg.createBranchProtection;
+        GITEA_MQ_TEARDOWN_ON_REMOVE = lib.boolToString cfg.teardownOnRemove;
         GITEA_MQ_BATCH_MAX = toString cfg.batchMax;
         GITEA_MQ_BISECT_MAX_STEPS = toString cfg.bisectMaxSteps;
         GITEA_MQ_REFRESH_INTERVAL = cfg.refreshInterval;
         GITEA_MQ_DISCOVERY_INTERVAL = cfg.discoveryInterval;
+        GITEA_MQ_LEADER_CHECK_INTERVAL = cfg.leaderCheckInterval;
+        GITEA_MQ_WEBHOOK_RETENTION = cfg.webhookRetention;
         GITEA_MQ_LOG_LEVEL = cfg.logLevel;
         GITEA_MQ_CACHE_DIR = "/var/cache/gitea-mq";
       }
+      // lib.optionalAttrs (cfg.configFile != null) {
+        GITEA_MQ_CONFIG_FILE = cfg.configFile;
+      }
       // lib.optionalAttrs (cfg.repos != [ ]) {
         GITEA_MQ_REPOS = lib.concatStringsSep "," cfg.repos;
       }
@@ -291,20 +660,106 @@ in
       // lib.optionalAttrs (cfg.requiredChecks != [ ]) {
         GITEA_MQ_REQUIRED_CHECKS = lib.concatStringsSep "," cfg.requiredChecks;
       }
+      // lib.optionalAttrs (cfg.branchPatterns != [ ]) {
+        GITEA_MQ_BRANCH_PATTERNS = lib.concatStringsSep "," cfg.branchPatterns;
+      }
+      // lib.optionalAttrs (cfg.shadowRepos != [ ]) {
+        GITEA_MQ_SHADOW_REPOS = lib.concatStringsSep "," cfg.shadowRepos;
+      }
       // lib.optionalAttrs giteaEnabled {
         GITEA_MQ_GITEA_URL = cfg.giteaUrl;
       }
-      // lib.optionalAttrs githubEnabled (
+      // lib.optionalAttrs (giteaInstances != [ ]) {
+        GITEA_MQ_GITEA_INSTANCES = lib.concatStringsSep "," giteaInstances;
+      }
+      // lib.concatMapAttrs (
+        name: inst:
         {
+          "GITEA_MQ_GITEA_${instanceVar name}_URL" = inst.url;
+        }
+        // lib.optionalAttrs (inst.repos != [ ]) {
+          "GITEA_MQ_GITEA_${instanceVar name}_REPOS" = lib.concatStringsSep "," inst.repos;
+        }
+        // lib.optionalAttrs (inst.topic != null) {
+          "GITEA_MQ_GITEA_${instanceVar name}_TOPIC" = inst.topic;
+        }
+      ) cfg.giteaInstances
+      // lib.optionalAttrs forgejoEnabled (
+        {
+          GITEA_MQ_FORGEJO_URL = cfg.forgejo.url;
+        }
+        // lib.optionalAttrs (cfg.forgejo.repos != [ ]) {
+          GITEA_MQ_FORGEJO_REPOS = lib.concatStringsSep "," cfg.forgejo.repos;
+        }
+        // lib.optionalAttrs (cfg.forgejo.topic != null) {
+          GITEA_MQ_FORGEJO_TOPIC = cfg.forgejo.topic;
+        }
+      )
+      // lib.optionalAttrs gitlabEnabled (
+        {
+          GITEA_MQ_GITLAB_URL = cfg.gitlab.url;
+        }
+        // lib.optionalAttrs (cfg.gitlab.repos != [ ]) {
+          GITEA_MQ_GITLAB_REPOS = lib.concatStringsSep "," cfg.gitlab.repos;
+        }
+        // lib.optionalAttrs (cfg.gitlab.topic != null) {
+          GITEA_MQ_GITLAB_TOPIC = cfg.gitlab.topic;
+        }
+      )
+      // lib.optionalAttrs githubEnabled (
+        lib.optionalAttrs githubApp {
           GITEA_MQ_GITHUB_APP_ID = toString cfg.github.appId;
         }
+        // lib.optionalAttrs (cfg.github.topic != null) {
+          GITEA_MQ_GITHUB_TOPIC = cfg.github.topic;
+        }
         // lib.optionalAttrs (cfg.github.repos != [ ]) {
           GITEA_MQ_GITHUB_REPOS = lib.concatStringsSep "," cfg.github.repos;
         }
         // lib.optionalAttrs (cfg.github.pollInterval != null) {
           GITEA_MQ_GITHUB_POLL_INTERVAL = cfg.github.pollInterval;
         }
-      );
+        // lib.optionalAttrs (cfg.github.url != null) {
+          GITEA_MQ_GITHUB_URL = cfg.github.url;
+        }
+        // lib.optionalAttrs (cfg.github.apiUrl != null) {
+          GITEA_MQ_GITHUB_API_URL = cfg.github.apiUrl;
+        }
+        // lib.optionalAttrs (cfg.github.uploadUrl != null) {
+          GITEA_MQ_GITHUB_UPLOAD_URL = cfg.github.uploadUrl;
+        }
+        // lib.optionalAttrs (cfg.github.graphqlUrl != null) {
+          GITEA_MQ_GITHUB_GRAPHQL_URL = cfg.github.graphqlUrl;
+        }
+      )
+      // lib.optionalAttrs (cfg.smtp.addr != null) (
+        {
+          GITEA_MQ_SMTP_ADDR = cfg.smtp.addr;
+          GITEA_MQ_SMTP_FROM = cfg.smtp.from;
+          GITEA_MQ_NOTIFY_AUTHORS = lib.boolToString cfg.smtp.notifyAuthors;
+          GITEA_MQ_NOTIFY_DIGEST_INTERVAL = cfg.smtp.digestInterval;
+        }
+        // lib.optionalAttrs (cfg.smtp.username != null) {
+          GITEA_MQ_SMTP_USERNAME = cfg.smtp.username;
+        }
+        // lib.optionalAttrs (cfg.smtp.digest != { }) {
+          GITEA_MQ_NOTIFY_DIGEST = lib.concatStringsSep ";" (
+            lib.mapAttrsToList (repo: addrs: "${repo}=${lib.concatStringsSep "," addrs}") cfg.smtp.digest
+          );
+        }
+        // lib.optionalAttrs (cfg.smtp.templateDir != null) {
+          GITEA_MQ_NOTIFY_TEMPLATE_DIR = toString cfg.smtp.templateDir;
+        }
+      )
+      // lib.optionalAttrs (cfg.auth.gitea.clientId != null || cfg.auth.github.clientId != null) {
+        GITEA_MQ_AUTH_CACHE_TTL = cfg.auth.cacheTTL;
+      }
+      // lib.optionalAttrs (cfg.auth.gitea.clientId != null) {
+        GITEA_MQ_GITEA_OAUTH_CLIENT_ID = cfg.auth.gitea.clientId;
+      }
+      // lib.optionalAttrs (cfg.auth.github.clientId != null) {
+        GITEA_MQ_GITHUB_OAUTH_CLIENT_ID = cfg.auth.github.clientId;
+      };
 
       path = [ pkgs.git ];
 
@@ -314,10 +769,42 @@ in
           export GITEA_MQ_GITEA_TOKEN="$(< "$CREDENTIALS_DIRECTORY/gitea-token")"
           export GITEA_MQ_WEBHOOK_SECRET="$(< "$CREDENTIALS_DIRECTORY/webhook-secret")"
         ''}
-        ${lib.optionalString githubEnabled ''
+        ${lib.concatMapStrings (name: ''
+          export GITEA_MQ_GITEA_${instanceVar name}_TOKEN="$(< "$CREDENTIALS_DIRECTORY/gitea-${name}-token")"
+          export GITEA_MQ_GITEA_${instanceVar name}_WEBHOOK_SECRET="$(< "$CREDENTIALS_DIRECTORY/gitea-${name}-webhook-secret")"
+        '') giteaInstances}
+        ${lib.optionalString forgejoEnabled ''
+          export GITEA_MQ_FORGEJO_TOKEN="$(< "$CREDENTIALS_DIRECTORY/forgejo-token")"
+          export GITEA_MQ_FORGEJO_WEBHOOK_SECRET="$(< "$CREDENTIALS_DIRECTORY/forgejo-webhook-secret")"
+        ''}
+        ${lib.optionalString gitlabEnabled ''
+          export GITEA_MQ_GITLAB_TOKEN="$(< "$CREDENTIALS_DIRECTORY/gitlab-token")"
+          export GITEA_MQ_GITLAB_WEBHOOK_SECRET="$(< "$CREDENTIALS_DIRECTORY/gitlab-webhook-secret")"
+        ''}
+        ${lib.optionalString githubApp ''
           export GITEA_MQ_GITHUB_PRIVATE_KEY_FILE="$CREDENTIALS_DIRECTORY/github-private-key"
+        ''}
+        ${lib.optionalString githubToken ''
+          export GITEA_MQ_GITHUB_TOKEN_FILE="$CREDENTIALS_DIRECTORY/github-token"
+        ''}
+        ${lib.optionalString githubEnabled ''
           export GITEA_MQ_GITHUB_WEBHOOK_SECRET="$(< "$CREDENTIALS_DIRECTORY/github-webhook-secret")"
         ''}
+        ${lib.optionalString (cfg.smtp.passwordFile != null) ''
+          export GITEA_MQ_SMTP_PASSWORD_FILE="$CREDENTIALS_DIRECTORY/smtp-password"
+        ''}
+        ${lib.optionalString (cfg.adminTokenFile != null) ''
+          export GITEA_MQ_ADMIN_TOKEN_FILE="$CREDENTIALS_DIRECTORY/admin-token"
+        ''}
+        ${lib.optionalString (cfg.auth.gitea.clientId != null) ''
+          export GITEA_MQ_GITEA_OAUTH_CLIENT_SECRET_FILE="$CREDENTIALS_DIRECTORY/gitea-oauth-secret"
+        ''}
+        ${lib.optionalString (cfg.auth.github.clientId != null) ''
+          export GITEA_MQ_GITHUB_OAUTH_CLIENT_SECRET_FILE="$CREDENTIALS_DIRECTORY/github-oauth-secret"
+        ''}
+        ${lib.optionalString (cfg.auth.sessionKeyFile != null) ''
+          export GITEA_MQ_AUTH_SESSION_KEY_FILE="$CREDENTIALS_DIRECTORY/auth-session-key"
+        ''}
         exec ${lib.getExe cfg.package}
       '';
     };